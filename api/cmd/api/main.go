@@ -2,9 +2,14 @@ package main
 
 import (
 	"api/config"
+	"api/internal/audit"
 	"api/internal/db"
+	"api/internal/idempotency"
+	"api/internal/machines"
 	"api/internal/migrations"
+	"api/internal/oee"
 	"api/internal/observability"
+	"api/internal/webhooks"
 	"api/server"
 	"context"
 	"log"
@@ -54,6 +59,44 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Background workers stop when the process receives a shutdown signal
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	if cfg.Webhooks.Enabled {
+		dispatcher := webhooks.NewDispatcher(webhooks.NewRepository(database), cfg)
+		go dispatcher.Run(workersCtx)
+	}
+
+	if cfg.Webhooks.Retention > 0 {
+		outboxPurger := webhooks.NewPurger(webhooks.NewRepository(database), cfg)
+		go outboxPurger.Run(workersCtx)
+	}
+
+	if cfg.Audit.Retention > 0 {
+		purger := audit.NewPurger(audit.NewRepository(database), cfg)
+		go purger.Run(workersCtx)
+	}
+
+	idempotencyPurger := idempotency.NewPurger(idempotency.NewRepository(database))
+	go idempotencyPurger.Run(workersCtx)
+
+	oeeCollector := oee.NewCollector(oee.NewService(oee.NewRepository(database)))
+	if err := oeeCollector.Register(); err != nil {
+		slog.Error("Unable to register OEE metrics", slog.Any("error", err))
+		log.Fatal(err)
+	}
+	go oeeCollector.Run(workersCtx)
+
+	if cfg.MQTT.Enabled {
+		ingestor := machines.NewIngestor(machines.NewRepository(database), cfg)
+		go func() {
+			if err := ingestor.Run(workersCtx); err != nil {
+				slog.Error("MQTT ingestor error", slog.Any("error", err))
+			}
+		}()
+	}
+
 	// Start server in a goroutine
 	go func() {
 		if err := server.Run(); err != nil {
@@ -67,4 +110,5 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	slog.Info("Shutting down server...")
+	stopWorkers()
 }
\ No newline at end of file
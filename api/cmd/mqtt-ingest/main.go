@@ -0,0 +1,54 @@
+// Command mqtt-ingest runs the machine signal ingestor on its own, for
+// deployments where the API should not hold the broker connection. It uses
+// the same configuration as the API; MQTT_ENABLED is not required here.
+// Migrations are left to the API.
+//
+// To try it locally with mosquitto:
+//
+//	mosquitto -p 1883
+//	MQTT_TOPICS='turniq/#' go run ./cmd/mqtt-ingest
+//
+// Create a machine signal through POST /api/machine-signals, e.g. topic
+// "turniq/press-1/state" with signal "state", then publish:
+//
+//	mosquitto_pub -t turniq/press-1/state -m stop
+//	mosquitto_pub -t turniq/press-1/state -m '{"state":"run","ts":"2025-01-01T08:30:00Z"}'
+//	mosquitto_pub -t turniq/press-1/count -m 5
+//	mosquitto_pub -t turniq/press-1/count -m '{"good":12,"scrap":1}'
+//
+// A stop opens an unplanned downtime on the workcenter, a run closes it and
+// counts are added to the production counts used by the OEE report.
+package main
+
+import (
+	"api/config"
+	"api/internal/db"
+	"api/internal/machines"
+	"context"
+	"log"
+	"log/slog"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatal("Unable to load config: ", err)
+	}
+
+	database, err := db.NewPostgresConnection(cfg)
+	if err != nil {
+		slog.Error("Unable to connect to database", slog.Any("error", err))
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	ingestor := machines.NewIngestor(machines.NewRepository(database), cfg)
+	if err := ingestor.Run(ctx); err != nil {
+		slog.Error("MQTT ingestor error", slog.Any("error", err))
+		log.Fatal(err)
+	}
+}
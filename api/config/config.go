@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -12,6 +13,8 @@ import (
 type Config struct {
 	App struct {
 		Port string
+		// URL of the web app, used for the links sent by email
+		URL string
 	}
 	Database struct {
 		Host     string
@@ -22,7 +25,16 @@ type Config struct {
 	}
 	Auth struct {
 		Secret string
-		TTL    time.Duration
+		// TTL is the lifetime of access tokens; sessions last RefreshTTL
+		TTL        time.Duration
+		RefreshTTL time.Duration
+		// ImpersonationTTL is how long an admin can act as a tenant user
+		ImpersonationTTL time.Duration
+	}
+	SSO struct {
+		// CallbackURL is where identity providers send users back to, the
+		// public URL of /auth/sso/callback
+		CallbackURL string
 	}
 	Email struct {
 		Host     string
@@ -31,9 +43,39 @@ type Config struct {
 		Password string
 		From     string
 	}
+	Audit struct {
+		// Retention is how long audit log entries are kept; zero keeps them forever
+		Retention time.Duration
+	}
+	Idempotency struct {
+		// TTL is how long the response to a request with an Idempotency-Key
+		// is replayed to retries
+		TTL time.Duration
+		// Lease is how long a request holds its key before answering. The
+		// key of a request that never answers can be used again after it.
+		Lease time.Duration
+	}
 	Migration struct {
 		Path string
 	}
+	Webhooks struct {
+		Enabled      bool
+		PollInterval time.Duration
+		MaxAttempts  int
+		Timeout      time.Duration
+		// Retention is how long outbox events and their deliveries are kept; zero keeps them forever
+		Retention time.Duration
+	}
+	MQTT struct {
+		Enabled         bool
+		BrokerURL       string
+		ClientID        string
+		Username        string
+		Password        string
+		Topics          []string
+		QoS             byte
+		RefreshInterval time.Duration
+	}
 	Observability struct {
 		// Loki (logs)
 		LokiURL      string
@@ -57,6 +99,7 @@ func LoadConfig() (Config, error) {
 	
 	var cfg Config
 	cfg.App.Port = getenvDefault("APP_PORT", "8080")
+	cfg.App.URL = strings.TrimSuffix(getenvDefault("APP_URL", "http://localhost:5173"), "/")
 	
 	// Database config...
 	cfg.Database.Host = getenvDefault("DATABASE_HOST", "localhost")
@@ -85,23 +128,102 @@ func LoadConfig() (Config, error) {
 	if cfg.Auth.Secret == "" {
 		return Config{}, errors.New("AUTH_SECRET is required")
 	}
-	ttlString := getenvDefault("AUTH_TTL", "604800")
+	ttlString := getenvDefault("AUTH_TTL", "900")
 	ttlSeconds, err := strconv.Atoi(ttlString)
 	if err != nil {
 		return Config{}, errors.New("AUTH_TTL must be an integer representing seconds")
 	}
 	cfg.Auth.TTL = time.Duration(ttlSeconds) * time.Second
+	sessionSeconds, err := strconv.Atoi(getenvDefault("AUTH_REFRESH_TTL", "2592000"))
+	if err != nil || sessionSeconds <= 0 {
+		return Config{}, errors.New("AUTH_REFRESH_TTL must be a positive integer representing seconds")
+	}
+	cfg.Auth.RefreshTTL = time.Duration(sessionSeconds) * time.Second
+	impersonationSeconds, err := strconv.Atoi(getenvDefault("AUTH_IMPERSONATION_TTL", "3600"))
+	if err != nil || impersonationSeconds <= 0 {
+		return Config{}, errors.New("AUTH_IMPERSONATION_TTL must be a positive integer representing seconds")
+	}
+	cfg.Auth.ImpersonationTTL = time.Duration(impersonationSeconds) * time.Second
 	
+	// SSO config...
+	cfg.SSO.CallbackURL = getenvDefault("SSO_CALLBACK_URL", "http://localhost:"+cfg.App.Port+"/auth/sso/callback")
+
 	// Email config...
 	cfg.Email.Host = getenvDefault("EMAIL_HOST", "localhost")
 	cfg.Email.Port = getenvDefault("EMAIL_PORT", "1025")
 	cfg.Email.User = getenvDefault("EMAIL_USER", "")
 	cfg.Email.Password = getenvDefault("EMAIL_PASSWORD", "")
-	cfg.Email.From = getenvDefault("EMAIL_FROM", "")
+	cfg.Email.From = getenvDefault("EMAIL_FROM", "Turniq <no-reply@turniq.com>")
 	
 	// Migration config...
 	cfg.Migration.Path = getenvDefault("MIGRATION_PATH", "./migrations")
 	
+	// Audit config...
+	retentionDays, err := strconv.Atoi(getenvDefault("AUDIT_RETENTION_DAYS", "365"))
+	if err != nil || retentionDays < 0 {
+		return Config{}, errors.New("AUDIT_RETENTION_DAYS must be a non-negative integer representing days")
+	}
+	cfg.Audit.Retention = time.Duration(retentionDays) * 24 * time.Hour
+
+	// Idempotency config...
+	idempotencyHours, err := strconv.Atoi(getenvDefault("IDEMPOTENCY_TTL_HOURS", "24"))
+	if err != nil || idempotencyHours <= 0 {
+		return Config{}, errors.New("IDEMPOTENCY_TTL_HOURS must be a positive integer representing hours")
+	}
+	cfg.Idempotency.TTL = time.Duration(idempotencyHours) * time.Hour
+	leaseSeconds, err := strconv.Atoi(getenvDefault("IDEMPOTENCY_LEASE_SECONDS", "300"))
+	if err != nil || leaseSeconds <= 0 {
+		return Config{}, errors.New("IDEMPOTENCY_LEASE_SECONDS must be a positive integer representing seconds")
+	}
+	cfg.Idempotency.Lease = time.Duration(leaseSeconds) * time.Second
+
+	// Webhooks config...
+	cfg.Webhooks.Enabled = getenvDefault("WEBHOOKS_ENABLED", "true") == "true"
+	pollSeconds, err := strconv.Atoi(getenvDefault("WEBHOOKS_POLL_INTERVAL", "5"))
+	if err != nil || pollSeconds <= 0 {
+		return Config{}, errors.New("WEBHOOKS_POLL_INTERVAL must be a positive integer representing seconds")
+	}
+	cfg.Webhooks.PollInterval = time.Duration(pollSeconds) * time.Second
+	cfg.Webhooks.MaxAttempts, err = strconv.Atoi(getenvDefault("WEBHOOKS_MAX_ATTEMPTS", "10"))
+	if err != nil || cfg.Webhooks.MaxAttempts <= 0 {
+		return Config{}, errors.New("WEBHOOKS_MAX_ATTEMPTS must be a positive integer")
+	}
+	timeoutSeconds, err := strconv.Atoi(getenvDefault("WEBHOOKS_TIMEOUT", "10"))
+	if err != nil || timeoutSeconds <= 0 {
+		return Config{}, errors.New("WEBHOOKS_TIMEOUT must be a positive integer representing seconds")
+	}
+	cfg.Webhooks.Timeout = time.Duration(timeoutSeconds) * time.Second
+	webhookRetentionDays, err := strconv.Atoi(getenvDefault("WEBHOOKS_RETENTION_DAYS", "30"))
+	if err != nil || webhookRetentionDays < 0 {
+		return Config{}, errors.New("WEBHOOKS_RETENTION_DAYS must be a non-negative integer representing days")
+	}
+	cfg.Webhooks.Retention = time.Duration(webhookRetentionDays) * 24 * time.Hour
+	
+	// MQTT config...
+	cfg.MQTT.Enabled = getenvDefault("MQTT_ENABLED", "false") == "true"
+	cfg.MQTT.BrokerURL = getenvDefault("MQTT_BROKER_URL", "tcp://localhost:1883")
+	cfg.MQTT.ClientID = getenvDefault("MQTT_CLIENT_ID", "turniq-api")
+	cfg.MQTT.Username = getenvDefault("MQTT_USERNAME", "")
+	cfg.MQTT.Password = getenvDefault("MQTT_PASSWORD", "")
+	for _, topic := range strings.Split(getenvDefault("MQTT_TOPICS", "turniq/#"), ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			cfg.MQTT.Topics = append(cfg.MQTT.Topics, topic)
+		}
+	}
+	if cfg.MQTT.Enabled && len(cfg.MQTT.Topics) == 0 {
+		return Config{}, errors.New("MQTT_TOPICS is required when MQTT_ENABLED is true")
+	}
+	qos, err := strconv.Atoi(getenvDefault("MQTT_QOS", "1"))
+	if err != nil || qos < 0 || qos > 2 {
+		return Config{}, errors.New("MQTT_QOS must be 0, 1 or 2")
+	}
+	cfg.MQTT.QoS = byte(qos)
+	refreshSeconds, err := strconv.Atoi(getenvDefault("MQTT_REFRESH_INTERVAL", "60"))
+	if err != nil || refreshSeconds <= 0 {
+		return Config{}, errors.New("MQTT_REFRESH_INTERVAL must be a positive integer representing seconds")
+	}
+	cfg.MQTT.RefreshInterval = time.Duration(refreshSeconds) * time.Second
+	
 	// Observability configuration
 	cfg.Observability.LokiURL = getenvDefault("LOKI_URL", "")
 	cfg.Observability.LokiUser = getenvDefault("LOKI_USER", "")
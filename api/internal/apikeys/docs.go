@@ -0,0 +1,11 @@
+package apikeys
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/api-keys", Tag: "api-keys", Summary: "Create an API key; the key is only returned here", Request: APIKeyRequest{}, Response: APIKey{}, Status: 201},
+	{Method: "GET", Path: "/api/api-keys", Tag: "api-keys", Summary: "List the API keys of the user", Response: []APIKey{}},
+	{Method: "GET", Path: "/api/api-keys/:id", Tag: "api-keys", Summary: "Get an API key", Response: APIKey{}},
+	{Method: "DELETE", Path: "/api/api-keys/:id", Tag: "api-keys", Summary: "Revoke an API key"},
+}
@@ -0,0 +1,71 @@
+package apikeys
+
+import (
+	"api/internal/apperr"
+	"api/internal/tenant"
+	"api/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+// Create returns the key itself; it can't be read again afterwards
+func (h *Handler) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request APIKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.Create(ctx, middleware.GetUserID(c), request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "API key created successfully", "data": response})
+}
+
+func (h *Handler) FindAll(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.FindAll(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API keys found successfully", "data": response})
+}
+
+func (h *Handler) FindByID(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.FindByID(ctx, c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("api_key_not_found", "API key not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key found successfully", "data": response})
+}
+
+func (h *Handler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.Delete(ctx, c.Param("id")); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("api_key_not_found", "API key not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
+}
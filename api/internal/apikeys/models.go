@@ -0,0 +1,37 @@
+package apikeys
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// keyPrefix starts every key so leaked keys are easy to recognise
+const keyPrefix = "tq_"
+
+// prefixLength is how much of the key is kept to identify it in listings
+const prefixLength = 11
+
+// APIKey lets an integration call the API on behalf of a tenant. Its scopes
+// are permissions of the role catalogue, e.g. "jobs:write".
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	CustomerID uuid.UUID  `json:"customer_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedBy  *uuid.UUID `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	// Key is only returned when the key is created
+	Key string `json:"key,omitempty"`
+}
+
+type APIKeyRequest struct {
+	CustomerID string     `json:"customer_id" binding:"omitempty,uuid"`
+	Name       string     `json:"name" binding:"required,max=100"`
+	Scopes     []string   `json:"scopes" binding:"required"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
@@ -0,0 +1,97 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Repository interface {
+	Create(ctx context.Context, key APIKey, hash string) (APIKey, error)
+	FindAll(ctx context.Context) ([]APIKey, error)
+	FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]APIKey, error)
+	FindByID(ctx context.Context, id uuid.UUID) (APIKey, error)
+	FindByHash(ctx context.Context, hash string) (APIKey, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	Touch(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+const selectKeys = `SELECT id, customer_id, name, prefix, scopes, expires_at, last_used_at, created_by, created_at, updated_at
+	FROM api_keys`
+
+func scanKey(scanner interface{ Scan(...interface{}) error }) (APIKey, error) {
+	var key APIKey
+	err := scanner.Scan(&key.ID, &key.CustomerID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.ExpiresAt,
+		&key.LastUsedAt, &key.CreatedBy, &key.CreatedAt, &key.UpdatedAt)
+	return key, err
+}
+
+func (r *repository) Create(ctx context.Context, key APIKey, hash string) (APIKey, error) {
+	query := `INSERT INTO api_keys (id, customer_id, name, prefix, key_hash, scopes, expires_at, created_by, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err := r.db.ExecContext(ctx, query, key.ID, key.CustomerID, key.Name, key.Prefix, hash, pq.Array(key.Scopes),
+		key.ExpiresAt, key.CreatedBy, key.CreatedAt, key.UpdatedAt)
+	if err != nil {
+		return APIKey{}, err
+	}
+	return key, nil
+}
+
+func (r *repository) FindAll(ctx context.Context) ([]APIKey, error) {
+	return r.list(ctx, selectKeys+` ORDER BY created_at ASC`)
+}
+
+func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]APIKey, error) {
+	return r.list(ctx, selectKeys+` WHERE customer_id = $1 ORDER BY created_at ASC`, customerID)
+}
+
+func (r *repository) list(ctx context.Context, query string, args ...interface{}) ([]APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (APIKey, error) {
+	return scanKey(r.db.QueryRowContext(ctx, selectKeys+` WHERE id = $1`, id))
+}
+
+func (r *repository) FindByHash(ctx context.Context, hash string) (APIKey, error) {
+	return scanKey(r.db.QueryRowContext(ctx, selectKeys+` WHERE key_hash = $1`, hash))
+}
+
+func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM api_keys WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// Touch records the use of a key. It writes at most once a minute per key
+// so busy integrations don't update the row on every request.
+func (r *repository) Touch(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	query := `UPDATE api_keys SET last_used_at = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)`
+	_, err := r.db.ExecContext(ctx, query, id, usedAt, usedAt.Add(-time.Minute))
+	return err
+}
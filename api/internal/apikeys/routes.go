@@ -0,0 +1,10 @@
+package apikeys
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.POST("/api-keys", handler.Create)
+	router.GET("/api-keys", handler.FindAll)
+	router.GET("/api-keys/:id", handler.FindByID)
+	router.DELETE("/api-keys/:id", handler.Delete)
+}
@@ -0,0 +1,179 @@
+package apikeys
+
+import (
+	"api/internal/apperr"
+	"api/internal/roles"
+	"api/internal/tenant"
+	"api/middleware"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNoScopes     = apperr.Field("scopes", "no_scopes", "an API key needs at least one scope")
+	ErrExpired      = apperr.Field("expires_at", "invalid_expires_at", "expires_at must be in the future")
+	ErrScopeNotHeld = apperr.Forbidden("scope_not_held", "you can't grant a scope you don't have")
+)
+
+type Service interface {
+	Create(ctx context.Context, userID string, request APIKeyRequest) (APIKey, error)
+	FindAll(ctx context.Context) ([]APIKey, error)
+	FindByID(ctx context.Context, id string) (APIKey, error)
+	Delete(ctx context.Context, id string) error
+	AuthenticateKey(ctx context.Context, key string) (*middleware.AuthUser, error)
+}
+
+type service struct {
+	repo        Repository
+	roleService roles.Service
+}
+
+func NewService(repo Repository, roleService roles.Service) Service {
+	return &service{repo: repo, roleService: roleService}
+}
+
+// Create issues a key for the tenant. Users other than system admins can
+// only grant the permissions of their own role.
+func (s *service) Create(ctx context.Context, userID string, request APIKeyRequest) (APIKey, error) {
+	customerID, err := tenant.Customer(ctx, request.CustomerID)
+	if err != nil {
+		return APIKey{}, err
+	}
+	creatorID, err := uuid.Parse(userID)
+	if err != nil {
+		return APIKey{}, err
+	}
+	if len(request.Scopes) == 0 {
+		return APIKey{}, ErrNoScopes
+	}
+	scopes, err := roles.ValidPermissions(request.Scopes)
+	if err != nil {
+		return APIKey{}, err
+	}
+	if isAdmin, _ := middleware.GetIsAdminFromCtx(ctx); !isAdmin {
+		for _, scope := range scopes {
+			held, err := s.roleService.HasPermission(ctx, creatorID, scope)
+			if err != nil {
+				return APIKey{}, err
+			}
+			if !held {
+				return APIKey{}, ErrScopeNotHeld
+			}
+		}
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return APIKey{}, ErrExpired
+	}
+
+	value, err := generateKey()
+	if err != nil {
+		return APIKey{}, err
+	}
+	now := time.Now()
+	key := APIKey{
+		ID:         uuid.New(),
+		CustomerID: customerID,
+		Name:       strings.TrimSpace(request.Name),
+		Prefix:     value[:prefixLength],
+		Scopes:     scopes,
+		ExpiresAt:  request.ExpiresAt,
+		CreatedBy:  &creatorID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	created, err := s.repo.Create(ctx, key, hashKey(value))
+	if err != nil {
+		return APIKey{}, err
+	}
+	created.Key = value
+	return created, nil
+}
+
+func (s *service) FindAll(ctx context.Context) ([]APIKey, error) {
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	if scope == nil {
+		return s.repo.FindAll(ctx)
+	}
+	return s.repo.FindByCustomerID(ctx, *scope)
+}
+
+func (s *service) FindByID(ctx context.Context, id string) (APIKey, error) {
+	return s.findOwned(ctx, id)
+}
+
+// Delete revokes the key; requests with it are rejected from then on
+func (s *service) Delete(ctx context.Context, id string) error {
+	key, err := s.findOwned(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, key.ID)
+}
+
+func (s *service) findOwned(ctx context.Context, id string) (APIKey, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return APIKey{}, err
+	}
+	key, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return APIKey{}, err
+	}
+	if err := tenant.Check(ctx, key.CustomerID); err != nil {
+		return APIKey{}, err
+	}
+	return key, nil
+}
+
+// AuthenticateKey returns the identity an API key acts as, a non-admin user
+// of its tenant limited to its scopes, or nil when the key is unknown or
+// expired. It records when the key was used.
+func (s *service) AuthenticateKey(ctx context.Context, value string) (*middleware.AuthUser, error) {
+	key, err := s.repo.FindByHash(ctx, hashKey(value))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return nil, nil
+	}
+	if err := s.repo.Touch(ctx, key.ID, now); err != nil {
+		slog.Warn("Unable to record API key use", slog.String("api_key_id", key.ID.String()), slog.Any("error", err))
+	}
+	return &middleware.AuthUser{
+		Username:   key.Name,
+		CustomerID: key.CustomerID.String(),
+		APIKeyID:   key.ID.String(),
+		Scopes:     key.Scopes,
+	}, nil
+}
+
+// generateKey returns a random key like "tq_3q2-Zk..."
+func generateKey() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,184 @@
+// Package apperr holds the errors that tell clients what went wrong.
+// Repositories and services return them (or wrap other errors in them), and
+// ErrorMiddleware turns them into problem responses. Any other error answers
+// 500 without its message, so SQL errors never reach clients.
+package apperr
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Kind is the class of an error, which decides the HTTP status
+type Kind string
+
+const (
+	KindValidation      Kind = "validation"
+	KindUnauthorized    Kind = "unauthorized"
+	KindForbidden       Kind = "forbidden"
+	KindNotFound        Kind = "not_found"
+	KindConflict        Kind = "conflict"
+	KindQuotaExceeded   Kind = "quota_exceeded"
+	KindTooManyRequests Kind = "too_many_requests"
+	KindInternal        Kind = "internal"
+)
+
+var statuses = map[Kind]int{
+	KindValidation:      http.StatusBadRequest,
+	KindUnauthorized:    http.StatusUnauthorized,
+	KindForbidden:       http.StatusForbidden,
+	KindNotFound:        http.StatusNotFound,
+	KindConflict:        http.StatusConflict,
+	KindQuotaExceeded:   http.StatusForbidden,
+	KindTooManyRequests: http.StatusTooManyRequests,
+	KindInternal:        http.StatusInternalServerError,
+}
+
+// Error is an error a client can act on. Code is stable and machine
+// readable, Message is shown to people, and Details are added as extra
+// members of the problem response.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Details map[string]interface{}
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same kind and code, so copies made with With,
+// Wrap and Explain still match the package variable they come from
+func (e *Error) Is(target error) bool {
+	other, ok := target.(*Error)
+	return ok && other.Kind == e.Kind && other.Code == e.Code
+}
+
+// Status is the HTTP status the error answers with
+func (e *Error) Status() int {
+	if status, ok := statuses[e.Kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// With returns a copy of the error with an extra member in the response.
+// Errors are often package variables, so they are never changed in place.
+func (e *Error) With(key string, value interface{}) *Error {
+	copied := *e
+	copied.Details = map[string]interface{}{}
+	for k, v := range e.Details {
+		copied.Details[k] = v
+	}
+	copied.Details[key] = value
+	return &copied
+}
+
+// Wrap returns a copy of the error that keeps err as its cause, for
+// errors.Is and the logs
+func (e *Error) Wrap(err error) *Error {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+// Explain returns a copy of the error whose message ends with the message of
+// err, for causes that are safe to show such as a parse error of an upload
+func (e *Error) Explain(err error) *Error {
+	copied := e.Wrap(err)
+	copied.Message = e.Message + ": " + err.Error()
+	return copied
+}
+
+func newError(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Validation is for requests with missing or wrong values
+func Validation(code, message string) *Error {
+	return newError(KindValidation, code, message)
+}
+
+// Unauthorized is for requests without valid credentials
+func Unauthorized(code, message string) *Error {
+	return newError(KindUnauthorized, code, message)
+}
+
+// Forbidden is for users that can't do what they asked for
+func Forbidden(code, message string) *Error {
+	return newError(KindForbidden, code, message)
+}
+
+// NotFound is for records that don't exist or belong to another tenant
+func NotFound(code, message string) *Error {
+	return newError(KindNotFound, code, message)
+}
+
+// Conflict is for requests that clash with the current state of the data
+func Conflict(code, message string) *Error {
+	return newError(KindConflict, code, message)
+}
+
+// QuotaExceeded is for requests that go over a limit of the customer's plan
+func QuotaExceeded(code, message string) *Error {
+	return newError(KindQuotaExceeded, code, message)
+}
+
+// TooManyRequests is for clients that have to wait before trying again
+func TooManyRequests(code, message string) *Error {
+	return newError(KindTooManyRequests, code, message)
+}
+
+// Invalid wraps an error about the request itself, such as a body that
+// doesn't bind, as a validation error. Failed binding rules and values of the
+// wrong type are listed per field, see Fields. Errors that are already typed
+// are returned as they are.
+func Invalid(err error) *Error {
+	var typed *Error
+	if errors.As(err, &typed) {
+		return typed
+	}
+	if fields := bindError(err); fields != nil {
+		return fields
+	}
+	return Validation("invalid_request", err.Error()).Wrap(err)
+}
+
+// From returns the error to answer with. Typed errors are returned as they
+// are; missing rows, malformed IDs and constraint violations get a typed
+// error with a generic message; anything else is internal.
+func From(err error) *Error {
+	var typed *Error
+	if errors.As(err, &typed) {
+		return typed
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFound("not_found", "Record not found").Wrap(err)
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "unique_violation":
+			return Conflict("duplicate", "A record with the same values already exists").Wrap(err)
+		case "foreign_key_violation":
+			return Conflict("referenced", "The record is linked to another record that doesn't allow it").Wrap(err)
+		case "check_violation", "not_null_violation", "invalid_text_representation", "string_data_right_truncation", "invalid_datetime_format":
+			return Validation("invalid_value", "A value is not valid").Wrap(err)
+		}
+	}
+	// uuid.Parse doesn't export its errors
+	if message := err.Error(); strings.HasPrefix(message, "invalid UUID") || strings.HasPrefix(message, "invalid urn prefix") {
+		return Validation("invalid_id", message).Wrap(err)
+	}
+	return newError(KindInternal, "internal", "Internal server error").Wrap(err)
+}
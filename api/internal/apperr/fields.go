@@ -0,0 +1,146 @@
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is what is wrong with one field of a request. Field is the json
+// name, with its path for nested values (entries[2].end_time).
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Fields is the validation error of a request with wrong fields, listed in
+// the "fields" member of the response. A single field keeps its own code and
+// message, so the error still matches the variable it was built from.
+func Fields(fields ...FieldError) *Error {
+	if len(fields) == 1 {
+		return Validation(fields[0].Code, fields[0].Message).With("fields", fields)
+	}
+	return Validation("invalid_fields", "Some fields are not valid").With("fields", fields)
+}
+
+// Field is the validation error of a request with one wrong field
+func Field(field, code, message string) *Error {
+	return Fields(FieldError{Field: field, Code: code, Message: message})
+}
+
+// bindError turns the errors of binding a body into field errors: the rules
+// of the binding tags that failed, or a value of the wrong JSON type
+func bindError(err error) *Error {
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		fields := make([]FieldError, 0, len(invalid))
+		for _, fe := range invalid {
+			fields = append(fields, fieldError(fe))
+		}
+		return Fields(fields...).Wrap(err)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return Field(typeErr.Field, "invalid_type", fmt.Sprintf("%s must be %s", typeErr.Field, jsonType(typeErr.Type.String()))).Wrap(err)
+	}
+	return nil
+}
+
+// fieldError describes a failed binding rule. The field is named after its
+// json tag once validation.Register has run.
+func fieldError(fe validator.FieldError) FieldError {
+	field := fe.Namespace()
+	// The namespace starts with the name of the request struct
+	if _, rest, ok := strings.Cut(field, "."); ok {
+		field = rest
+	}
+	param := fe.Param()
+	code, rule := "invalid_value", "is not valid"
+	switch fe.Tag() {
+	case "required", "required_with", "required_without", "required_if":
+		code, rule = "required", "is required"
+	case "min", "gte":
+		code, rule = "too_small", "must be at least "+param+unit(fe)
+	case "gt":
+		code, rule = "too_small", "must be greater than "+param+unit(fe)
+	case "max", "lte":
+		code, rule = "too_large", "must be at most "+param+unit(fe)
+	case "lt":
+		code, rule = "too_large", "must be less than "+param+unit(fe)
+	case "oneof":
+		code, rule = "not_allowed", "must be one of "+strings.Join(strings.Fields(param), ", ")
+	case "gtfield":
+		code, rule = "out_of_order", "must be after "+snakeCase(param)
+	case "gtefield":
+		code, rule = "out_of_order", "must not be before "+snakeCase(param)
+	case "email":
+		code, rule = "invalid_format", "must be an email address"
+	case "url", "http_url":
+		code, rule = "invalid_format", "must be an absolute URL"
+	case "uuid", "uuid4":
+		code, rule = "invalid_format", "must be a UUID"
+	case "hexcolor":
+		code, rule = "invalid_format", "must be a hex color such as #3B82F6"
+	case "iso4217":
+		code, rule = "invalid_format", "must be a currency code such as EUR"
+	case "datetime":
+		code, rule = "invalid_format", "must be formatted as "+layouts[param]
+	}
+	return FieldError{Field: field, Code: code, Message: field + " " + rule}
+}
+
+// layouts name the time layouts of datetime rules the way people write them
+var layouts = map[string]string{
+	"2006-01-02": "YYYY-MM-DD",
+	"15:04":      "HH:MM",
+}
+
+// unit is what a min or max rule counts: characters of a string, items of a
+// list, nothing for a number
+func unit(fe validator.FieldError) string {
+	switch fe.Kind().String() {
+	case "string":
+		return " characters"
+	case "slice", "array", "map":
+		return " items"
+	}
+	return ""
+}
+
+func jsonType(goType string) string {
+	switch strings.TrimLeft(goType, "*") {
+	case "string", "uuid.UUID", "time.Time":
+		return "a string"
+	case "bool":
+		return "a boolean"
+	case "float32", "float64":
+		return "a number"
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return "an integer"
+	}
+	if strings.HasPrefix(goType, "[]") {
+		return "a list"
+	}
+	return "an object"
+}
+
+// snakeCase turns the Go name of a field into its json name, for the other
+// field of a cross-field rule (StartTime is start_time)
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Fields left out of the diff: timestamps every update touches
+var ignored = map[string]bool{"updated_at": true}
+
+// Fields whose values are never written to the audit log
+var redacted = map[string]bool{"password": true, "client_secret": true, "totp_secret": true}
+
+// fields returns the JSON fields of a record, or nil for no record
+func fields(record interface{}) (map[string]interface{}, error) {
+	if record == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// diff returns the fields of before and after that differ. Creations have
+// no before and deletions no after, so they keep every field. changed is
+// false when nothing but ignored fields changed.
+func diff(before interface{}, after interface{}) (json.RawMessage, json.RawMessage, bool, error) {
+	old, err := fields(before)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	updated, err := fields(after)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if old != nil && updated != nil {
+		changedOld := map[string]interface{}{}
+		changedNew := map[string]interface{}{}
+		for _, values := range []map[string]interface{}{old, updated} {
+			for name := range values {
+				if !ignored[name] && !reflect.DeepEqual(old[name], updated[name]) {
+					changedOld[name] = old[name]
+					changedNew[name] = updated[name]
+				}
+			}
+		}
+		if len(changedNew) == 0 {
+			return nil, nil, false, nil
+		}
+		old, updated = changedOld, changedNew
+	}
+	beforeJSON, err := marshal(old)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	afterJSON, err := marshal(updated)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return beforeJSON, afterJSON, true, nil
+}
+
+func marshal(values map[string]interface{}) (json.RawMessage, error) {
+	if values == nil {
+		return nil, nil
+	}
+	for name := range values {
+		if redacted[name] {
+			values[name] = "[redacted]"
+		}
+	}
+	return json.Marshal(values)
+}
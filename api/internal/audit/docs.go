@@ -0,0 +1,18 @@
+package audit
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "GET", Path: "/api/audit", Tag: "audit", Summary: "List the audit log, newest first", Response: []Entry{}, Query: []openapi.Param{
+		openapi.CustomerID,
+		{Name: "entity", Description: "Kind of record, e.g. job"},
+		{Name: "entity_id"},
+		{Name: "action"},
+		{Name: "actor_id", Description: "User who made the change"},
+		{Name: "request_id"},
+		{Name: "from", Description: "RFC 3339 time"},
+		{Name: "to", Description: "RFC 3339 time"},
+		{Name: "limit", Type: "integer"},
+	}},
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"api/internal/apperr"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+// FindAll returns the audit log of the tenant. Admins see every tenant
+// unless they filter by customer_id.
+func (h *Handler) FindAll(c *gin.Context) {
+	filter := Filter{
+		Entity:    c.Query("entity"),
+		Action:    c.Query("action"),
+		RequestID: c.Query("request_id"),
+	}
+	var err error
+	if filter.ActorID, err = queryID(c, "actor_id"); err != nil {
+		c.Error(apperr.Validation("invalid_request", "Invalid actor_id"))
+		return
+	}
+	if filter.EntityID, err = queryID(c, "entity_id"); err != nil {
+		c.Error(apperr.Validation("invalid_request", "Invalid entity_id"))
+		return
+	}
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		c.Error(apperr.Validation("invalid_request", "Invalid from, use RFC 3339"))
+		return
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		c.Error(apperr.Validation("invalid_request", "Invalid to, use RFC 3339"))
+		return
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+
+	entries, err := h.service.Find(c.Request.Context(), c.Query("customer_id"), filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Audit log found successfully", "data": entries})
+}
+
+func queryID(c *gin.Context, name string) (*uuid.UUID, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
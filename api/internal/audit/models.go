@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions of the audit log. Requests made while impersonating a user are
+// recorded as a whole besides the changes they make.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionRequest = "request"
+)
+
+// Entities whose changes are audited
+const (
+	EntityCustomer      = "customer"
+	EntityUser          = "user"
+	EntityOperator      = "operator"
+	EntityJob           = "job"
+	EntityShift         = "shift"
+	EntityWorkcenter    = "workcenter"
+	EntityShopfloor     = "shopfloor"
+	EntityScheduleEntry = "schedule_entry"
+	EntityTimeEntry     = "time_entry"
+	EntityPayment       = "payment"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Entry is a record of the audit log. ActorID is the user the change was
+// made as, ImpersonatedBy the admin behind it and APIKeyID the key it came
+// through, if any. Before and After hold only the fields that changed.
+type Entry struct {
+	ID             uuid.UUID       `json:"id"`
+	CustomerID     *uuid.UUID      `json:"customer_id"`
+	ActorID        *uuid.UUID      `json:"actor_id"`
+	ImpersonatedBy *uuid.UUID      `json:"impersonated_by"`
+	APIKeyID       *uuid.UUID      `json:"api_key_id"`
+	RequestID      string          `json:"request_id"`
+	Action         string          `json:"action"`
+	Entity         string          `json:"entity"`
+	EntityID       *uuid.UUID      `json:"entity_id"`
+	Before         json.RawMessage `json:"before"`
+	After          json.RawMessage `json:"after"`
+	Method         string          `json:"method,omitempty"`
+	Path           string          `json:"path,omitempty"`
+	Status         int             `json:"status,omitempty"`
+	IP             string          `json:"ip,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type Filter struct {
+	CustomerID *uuid.UUID
+	ActorID    *uuid.UUID
+	Entity     string
+	EntityID   *uuid.UUID
+	Action     string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+}
@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"api/config"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// How often the purger looks for expired entries
+const purgeInterval = time.Hour
+
+// Purger deletes the entries of the audit log older than the retention period
+type Purger struct {
+	repo      Repository
+	retention time.Duration
+}
+
+func NewPurger(repo Repository, cfg config.Config) *Purger {
+	return &Purger{repo: repo, retention: cfg.Audit.Retention}
+}
+
+// Run purges the audit log every purgeInterval until ctx is cancelled
+func (p *Purger) Run(ctx context.Context) {
+	slog.Info("Audit log purger started", slog.Duration("retention", p.retention))
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		purged, err := p.repo.Purge(ctx, time.Now().Add(-p.retention))
+		if err != nil {
+			slog.Error("Failed to purge the audit log", slog.Any("error", err))
+		} else if purged > 0 {
+			slog.Info("Audit log purged", slog.Int64("entries", purged))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Audit log purger stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type Repository interface {
+	Create(ctx context.Context, entry Entry) error
+	Find(ctx context.Context, filter Filter) ([]Entry, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// nullJSON stores an empty diff as NULL
+func nullJSON(value []byte) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	return string(value)
+}
+
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func (r *repository) Create(ctx context.Context, entry Entry) error {
+	query := `INSERT INTO audit_logs (id, customer_id, actor_id, impersonated_by, api_key_id, request_id, action, entity,
+		entity_id, before, after, method, path, status, ip, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
+	var status interface{}
+	if entry.Status != 0 {
+		status = entry.Status
+	}
+	_, err := r.db.ExecContext(ctx, query, entry.ID, entry.CustomerID, entry.ActorID, entry.ImpersonatedBy, entry.APIKeyID,
+		nullString(entry.RequestID), entry.Action, nullString(entry.Entity), entry.EntityID, nullJSON(entry.Before),
+		nullJSON(entry.After), nullString(entry.Method), nullString(entry.Path), status, nullString(entry.IP), entry.CreatedAt)
+	return err
+}
+
+// Find returns the entries matching the filter, newest first
+func (r *repository) Find(ctx context.Context, filter Filter) ([]Entry, error) {
+	query := `SELECT id, customer_id, actor_id, impersonated_by, api_key_id, COALESCE(request_id, ''), action,
+		COALESCE(entity, ''), entity_id, before, after, COALESCE(method, ''), COALESCE(path, ''), COALESCE(status, 0),
+		COALESCE(ip, ''), created_at
+	FROM audit_logs WHERE 1=1`
+	args := []interface{}{}
+	argId := 1
+	add := func(condition string, value interface{}) {
+		query += fmt.Sprintf(" AND "+condition, argId)
+		args = append(args, value)
+		argId++
+	}
+	if filter.CustomerID != nil {
+		add("customer_id = $%d", *filter.CustomerID)
+	}
+	if filter.ActorID != nil {
+		add("(actor_id = $%[1]d OR impersonated_by = $%[1]d)", *filter.ActorID)
+	}
+	if filter.Entity != "" {
+		add("entity = $%d", filter.Entity)
+	}
+	if filter.EntityID != nil {
+		add("entity_id = $%d", *filter.EntityID)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.RequestID != "" {
+		add("request_id = $%d", filter.RequestID)
+	}
+	if filter.From != nil {
+		add("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < $%d", *filter.To)
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", argId)
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.CustomerID, &e.ActorID, &e.ImpersonatedBy, &e.APIKeyID, &e.RequestID, &e.Action,
+			&e.Entity, &e.EntityID, &before, &after, &e.Method, &e.Path, &e.Status, &e.IP, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Purge deletes the entries older than before and returns how many
+func (r *repository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM audit_logs WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
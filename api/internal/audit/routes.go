@@ -0,0 +1,7 @@
+package audit
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/audit", handler.FindAll)
+}
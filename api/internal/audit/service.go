@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"api/internal/tenant"
+	"api/middleware"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Recorder writes the changes services make to the audit log. The actor,
+// tenant and request come from the context. A change that can't be
+// recorded is logged and doesn't fail the request.
+type Recorder interface {
+	Created(ctx context.Context, entity string, id uuid.UUID, customerID uuid.UUID, after interface{})
+	Updated(ctx context.Context, entity string, id uuid.UUID, customerID uuid.UUID, before interface{}, after interface{})
+	Deleted(ctx context.Context, entity string, id uuid.UUID, customerID uuid.UUID, before interface{})
+	Restored(ctx context.Context, entity string, id uuid.UUID, customerID uuid.UUID, after interface{})
+}
+
+type Service interface {
+	Recorder
+	RecordRequest(ctx context.Context, user *middleware.AuthUser, method string, path string, status int, ip string) error
+	Find(ctx context.Context, customerID string, filter Filter) ([]Entry, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) Created(ctx context.Context, entity string, id uuid.UUID, customerID uuid.UUID, after interface{}) {
+	s.record(ctx, ActionCreate, entity, id, customerID, nil, after)
+}
+
+func (s *service) Updated(ctx context.Context, entity string, id uuid.UUID, customerID uuid.UUID, before interface{}, after interface{}) {
+	s.record(ctx, ActionUpdate, entity, id, customerID, before, after)
+}
+
+func (s *service) Deleted(ctx context.Context, entity string, id uuid.UUID, customerID uuid.UUID, before interface{}) {
+	s.record(ctx, ActionDelete, entity, id, customerID, before, nil)
+}
+
+func (s *service) Restored(ctx context.Context, entity string, id uuid.UUID, customerID uuid.UUID, after interface{}) {
+	s.record(ctx, ActionRestore, entity, id, customerID, nil, after)
+}
+
+func (s *service) record(ctx context.Context, action string, entity string, id uuid.UUID, customerID uuid.UUID, before interface{}, after interface{}) {
+	beforeJSON, afterJSON, changed, err := diff(before, after)
+	if err == nil && !changed {
+		return
+	}
+	if err == nil {
+		entry := actorEntry(ctx)
+		entry.Action = action
+		entry.Entity = entity
+		entry.EntityID = &id
+		if customerID != uuid.Nil {
+			entry.CustomerID = &customerID
+		}
+		entry.Before = beforeJSON
+		entry.After = afterJSON
+		err = s.repo.Create(ctx, entry)
+	}
+	if err != nil {
+		slog.Error("Unable to record change in the audit log", slog.String("action", action), slog.String("entity", entity),
+			slog.String("entity_id", id.String()), slog.Any("error", err))
+	}
+}
+
+// actorEntry starts an entry with who made the request, as put in the
+// context by ContextMiddleware and RequestIDMiddleware. Flows without a
+// logged in user, such as registration, have no actor.
+func actorEntry(ctx context.Context) Entry {
+	userID, _ := ctx.Value("user_id").(string)
+	impersonatedBy, _ := ctx.Value("impersonated_by").(string)
+	apiKeyID, _ := ctx.Value("api_key_id").(string)
+	requestID, _ := ctx.Value("request_id").(string)
+	return Entry{
+		ID:             uuid.New(),
+		ActorID:        optionalID(userID),
+		ImpersonatedBy: optionalID(impersonatedBy),
+		APIKeyID:       optionalID(apiKeyID),
+		RequestID:      requestID,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// RecordRequest writes a request of the user to the audit log
+func (s *service) RecordRequest(ctx context.Context, user *middleware.AuthUser, method string, path string, status int, ip string) error {
+	entry := actorEntry(ctx)
+	entry.CustomerID = optionalID(user.CustomerID)
+	entry.ActorID = optionalID(user.ID)
+	entry.ImpersonatedBy = optionalID(user.ImpersonatedBy)
+	entry.Action = ActionRequest
+	entry.Method = method
+	entry.Path = path
+	entry.Status = status
+	entry.IP = ip
+	return s.repo.Create(ctx, entry)
+}
+
+// Find returns the audit log of the tenant, newest first
+func (s *service) Find(ctx context.Context, customerID string, filter Filter) ([]Entry, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	filter.CustomerID = scope
+	if filter.Limit <= 0 || filter.Limit > maxLimit {
+		filter.Limit = defaultLimit
+	}
+	return s.repo.Find(ctx, filter)
+}
+
+// optionalID parses an ID of the token, which may be missing
+func optionalID(value string) *uuid.UUID {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
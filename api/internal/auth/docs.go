@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"api/internal/openapi"
+	"api/internal/users"
+)
+
+// Docs documents the routes of RegisterRoutes, RegisterSessionRoutes and
+// RegisterUserRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/auth/login", Tag: "auth", Summary: "Log in with email and password; answers a ChallengeResponse instead when a second factor is needed", Public: true, Request: LoginRequest{}, Response: LoginResponse{}, Raw: true},
+	{Method: "POST", Path: "/auth/register", Tag: "auth", Summary: "Sign up a new tenant and its owner", Public: true, Request: RegisterRequest{}, Response: RegisterResponse{}, Raw: true, Status: 201},
+	{Method: "POST", Path: "/auth/refresh_token", Tag: "auth", Summary: "Trade a refresh token for new tokens", Public: true, Request: RefreshRequest{}, Response: RefreshResponse{}, Raw: true},
+	{Method: "POST", Path: "/auth/password/forgot", Tag: "auth", Summary: "Send a password reset link", Public: true, Request: EmailRequest{}, Status: 202},
+	{Method: "POST", Path: "/auth/password/reset", Tag: "auth", Summary: "Set a new password with a reset token", Public: true, Request: PasswordRequest{}},
+	{Method: "POST", Path: "/auth/email/verify", Tag: "auth", Summary: "Verify an email address", Public: true, Request: TokenRequest{}},
+	{Method: "POST", Path: "/auth/email/resend", Tag: "auth", Summary: "Send the verification link again", Public: true, Request: EmailRequest{}, Status: 202},
+	{Method: "POST", Path: "/auth/invitations/accept", Tag: "auth", Summary: "Accept an invitation by choosing a password", Public: true, Request: PasswordRequest{}},
+	{Method: "POST", Path: "/auth/2fa/verify", Tag: "auth", Summary: "Finish a login with a TOTP or recovery code", Public: true, Request: TwoFactorRequest{}, Response: LoginResponse{}, Raw: true},
+	{Method: "POST", Path: "/auth/2fa/enroll", Tag: "auth", Summary: "Start the enrollment required to finish a login", Public: true, Request: TokenRequest{}, Response: Enrollment{}},
+	{Method: "POST", Path: "/auth/2fa/enroll/confirm", Tag: "auth", Summary: "Confirm the enrollment and finish the login", Public: true, Request: TwoFactorRequest{}, Response: LoginResponse{}, Raw: true},
+	{Method: "POST", Path: "/auth/sso/exchange", Tag: "auth", Summary: "Trade the one-time code of a single sign-on for tokens", Public: true, Request: TokenRequest{}, Response: LoginResponse{}, Raw: true},
+
+	{Method: "POST", Path: "/api/auth/logout", Tag: "auth", Summary: "Revoke the current session"},
+	{Method: "POST", Path: "/api/auth/logout-all", Tag: "auth", Summary: "Revoke every session of the user"},
+	{Method: "GET", Path: "/api/auth/2fa", Tag: "auth", Summary: "Two-factor status of the user", Response: TwoFactor{}},
+	{Method: "POST", Path: "/api/auth/2fa/enroll", Tag: "auth", Summary: "Start a two-factor enrollment", Response: Enrollment{}},
+	{Method: "POST", Path: "/api/auth/2fa/confirm", Tag: "auth", Summary: "Enable two-factor authentication; returns the recovery codes", Request: CodeRequest{}, Response: []string{}},
+	{Method: "POST", Path: "/api/auth/2fa/disable", Tag: "auth", Summary: "Disable two-factor authentication", Request: CodeRequest{}},
+	{Method: "POST", Path: "/api/auth/2fa/recovery-codes", Tag: "auth", Summary: "Replace the recovery codes", Request: CodeRequest{}, Response: []string{}},
+	{Method: "GET", Path: "/api/auth/impersonations", Tag: "auth", Summary: "When admins acted as the current user", Response: []Impersonation{}},
+
+	{Method: "POST", Path: "/api/users/invite", Tag: "users", Summary: "Invite a user by email", Request: InviteRequest{}, Response: users.User{}, Status: 201},
+	{Method: "POST", Path: "/api/users/:id/invite", Tag: "users", Summary: "Send an invitation again"},
+	{Method: "DELETE", Path: "/api/users/:id/two-factor", Tag: "users", Summary: "Reset the two-factor authentication of a user"},
+	{Method: "GET", Path: "/api/users/logins", Tag: "users", Summary: "List login attempts, newest first", Response: []LoginAttempt{}, Query: []openapi.Param{
+		openapi.CustomerID,
+		{Name: "user_id"},
+		{Name: "success", Type: "boolean"},
+		{Name: "limit", Type: "integer"},
+	}},
+	{Method: "POST", Path: "/api/users/:id/unlock", Tag: "users", Summary: "Unlock a user locked out by failed logins"},
+	{Method: "POST", Path: "/api/users/:id/impersonate", Tag: "users", Summary: "Act as a user of a tenant, for admins", Request: ImpersonateRequest{}, Response: ImpersonationResponse{}, Raw: true},
+}
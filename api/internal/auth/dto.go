@@ -12,7 +12,43 @@ type LoginRequest struct {
 type LoginResponse struct {
 	Token  string `json:"token"`
 	Expire string `json:"expire"`
+	RefreshToken  string `json:"refresh_token"`
+	RefreshExpire string `json:"refresh_expire"`
 	User   users.User   `json:"user"`	
+	// RecoveryCodes are only set when the login enrolled the user in 2FA
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// ChallengeResponse is returned instead of the tokens when the login needs
+// the second factor. The two-factor token is sent with the code, or with
+// the enrollment when the tenant requires 2FA and the user has none yet.
+type ChallengeResponse struct {
+	TwoFactorRequired      bool   `json:"two_factor_required"`
+	TwoFactorSetupRequired bool   `json:"two_factor_setup_required"`
+	TwoFactorToken         string `json:"two_factor_token"`
+	Expire                 string `json:"expire"`
+}
+
+type TwoFactorRequest struct {
+	Token string `json:"token" binding:"required"`
+	Code  string `json:"code" binding:"required"`
+}
+
+// CodeRequest carries a code of the authenticator app or a recovery code
+type CodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshResponse carries the new access token and the rotated refresh token
+type RefreshResponse struct {
+	Token         string `json:"token"`
+	Expire        string `json:"expire"`
+	RefreshToken  string `json:"refresh_token"`
+	RefreshExpire string `json:"refresh_expire"`
 }
 
 type RegisterRequest struct {
@@ -25,4 +61,41 @@ type RegisterRequest struct {
 type RegisterResponse struct {
 	ID       string `json:"id"`
 	Email    string `json:"email"`
-}
\ No newline at end of file
+}
+// EmailRequest asks for a link to be sent to an email address
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// TokenRequest carries the token of a link sent by email
+type TokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// PasswordRequest sets a password with the token of a link sent by email
+type PasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type InviteRequest struct {
+	CustomerID string `json:"customer_id" binding:"omitempty,uuid"`
+	Username   string `json:"username" binding:"max=100"`
+	Email      string `json:"email" binding:"required,email"`
+	Role       string `json:"role"`
+}
+
+// ImpersonateRequest gives the reason an admin acts as a user, which the
+// user can see
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ImpersonationResponse carries the token to act as the user. It can't be
+// refreshed; the impersonation ends when it expires or on logout.
+type ImpersonationResponse struct {
+	Token         string        `json:"token"`
+	Expire        string        `json:"expire"`
+	User          users.User    `json:"user"`
+	Impersonation Impersonation `json:"impersonation"`
+}
@@ -1,9 +1,20 @@
 package auth
 
-import "errors"
+import "api/internal/apperr"
 
 var (
-    ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrUserNotFound      = errors.New("user not found")
-	ErrInactiveUser      = errors.New("inactive user")
-)
\ No newline at end of file
+    ErrInvalidCredentials = apperr.Unauthorized("invalid_credentials", "invalid credentials")
+	ErrUserNotFound      = apperr.NotFound("user_not_found", "user not found")
+	ErrInactiveUser      = apperr.Forbidden("inactive_user", "inactive user")
+	ErrEmailNotVerified  = apperr.Forbidden("email_not_verified", "email not verified")
+	ErrInvalidToken      = apperr.Unauthorized("invalid_token", "invalid or expired token")
+	ErrInvitationAccepted = apperr.Conflict("invitation_accepted", "invitation already accepted")
+	ErrInvalidCode       = apperr.Unauthorized("invalid_code", "invalid two-factor code")
+	ErrTwoFactorEnabled  = apperr.Conflict("two_factor_enabled", "two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled = apperr.Conflict("two_factor_not_enabled", "two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled = apperr.Conflict("two_factor_not_enrolled", "two-factor enrollment has not been started")
+	ErrTwoFactorRequired = apperr.Conflict("two_factor_required", "two-factor authentication is required for this tenant")
+	ErrAccountLocked     = apperr.TooManyRequests("account_locked", "account locked after too many failed logins")
+	ErrTooManyAttempts   = apperr.TooManyRequests("too_many_attempts", "too many failed logins, try again later")
+	ErrImpersonateAdmin  = apperr.Forbidden("impersonate_admin", "admins can't be impersonated")
+)
@@ -1,7 +1,12 @@
 package auth
 
 import (
+	"api/internal/apperr"
+	"api/internal/tenant"
+	"api/internal/users"
+	"api/middleware"
 	"net/http"
+	"strconv"
 	"time"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
@@ -24,48 +29,221 @@ func NewAuthHandler(authService AuthService, jwtMiddleware *jwt.GinJWTMiddleware
 func (h *AuthHandler) Login(c *gin.Context) {
     var loginRequest LoginRequest
     if err := c.ShouldBindJSON(&loginRequest); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login request"})
+        c.Error(apperr.Validation("invalid_request", "Invalid login request"))
         return
     }
     
-    token,user, expire, err := h.authService.Login(c.Request.Context(), loginRequest)
+    client := Client{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+    tokens, user, challenge, err := h.authService.Login(c.Request.Context(), loginRequest, client)
     if err != nil {
-        var statusCode int
-        switch err {
-        case ErrInvalidCredentials:
-            statusCode = http.StatusUnauthorized
-        case ErrInactiveUser:
-            statusCode = http.StatusForbidden
-        default:
-            statusCode = http.StatusInternalServerError
+        if err == ErrUserNotFound {
+            // No revelar quins emails existeixen
+            err = ErrInvalidCredentials
         }
-        
-        c.JSON(statusCode, gin.H{"error": err.Error()})
+        c.Error(err)
+        return
+    }
+
+    if challenge.Token != "" {
+        c.JSON(http.StatusOK, ChallengeResponse{
+            TwoFactorRequired:      !challenge.Setup,
+            TwoFactorSetupRequired: challenge.Setup,
+            TwoFactorToken:         challenge.Token,
+            Expire:                 challenge.ExpiresAt.Format(time.RFC3339),
+        })
         return
     }
     
-    c.JSON(http.StatusOK, LoginResponse{
-        Token:  token,
-        Expire: expire.Format(time.RFC3339),
-        User:   user,
-    })
+    c.JSON(http.StatusOK, loginResponse(tokens, user, nil))
+}
+
+func loginResponse(tokens Tokens, user users.User, recoveryCodes []string) LoginResponse {
+	return LoginResponse{
+		Token:         tokens.AccessToken,
+		Expire:        tokens.Expire.Format(time.RFC3339),
+		RefreshToken:  tokens.RefreshToken,
+		RefreshExpire: tokens.RefreshExpire.Format(time.RFC3339),
+		User:          user,
+		RecoveryCodes: recoveryCodes,
+	}
+}
+
+// VerifyTwoFactor completes a login with a code of the authenticator app or
+// a recovery code
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req TwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	client := Client{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+	tokens, user, err := h.authService.VerifyTwoFactor(c.Request.Context(), req, client)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, loginResponse(tokens, user, nil))
+}
+
+// StartEnrollment returns the secret for a login that must enroll in 2FA
+func (h *AuthHandler) StartEnrollment(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	enrollment, err := h.authService.StartEnrollment(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor enrollment started", "data": enrollment})
+}
+
+// FinishEnrollment enables 2FA and completes the login
+func (h *AuthHandler) FinishEnrollment(c *gin.Context) {
+	var req TwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	client := Client{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+	tokens, user, codes, err := h.authService.FinishEnrollment(c.Request.Context(), req, client)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, loginResponse(tokens, user, codes))
+}
+
+func (h *AuthHandler) TwoFactorStatus(c *gin.Context) {
+	status, err := h.authService.TwoFactorStatus(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor status found successfully", "data": status})
+}
+
+// Enroll starts the enrollment of the current user. 2FA is enabled once a
+// code is confirmed.
+func (h *AuthHandler) Enroll(c *gin.Context) {
+	enrollment, err := h.authService.Enroll(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor enrollment started", "data": enrollment})
+}
+
+func (h *AuthHandler) ConfirmEnrollment(c *gin.Context) {
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	codes, err := h.authService.ConfirmEnrollment(c.Request.Context(), middleware.GetUserID(c), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "data": codes})
 }
 
-// RefreshToken utilitza el middleware JWT per refrescar el token
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	if err := h.authService.DisableTwoFactor(c.Request.Context(), middleware.GetUserID(c), req); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	codes, err := h.authService.RegenerateRecoveryCodes(c.Request.Context(), middleware.GetUserID(c), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Recovery codes regenerated", "data": codes})
+}
+
+// ResetTwoFactor turns 2FA off for a user of the tenant
+func (h *AuthHandler) ResetTwoFactor(c *gin.Context) {
+	if err := h.authService.ResetTwoFactor(c.Request.Context(), c.Param("id")); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("user_not_found", "User not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// RefreshToken canvia el refresh token per un de nou i un nou token d'accés
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-    h.jwtMiddleware.RefreshHandler(c)
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	tokens, err := h.authService.Refresh(c.Request.Context(), req)
+	if err != nil {
+		if err == ErrInactiveUser {
+			// The client signs out on 401 from a refresh
+			err = apperr.Unauthorized("inactive_user", err.Error())
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, RefreshResponse{
+		Token:         tokens.AccessToken,
+		Expire:        tokens.Expire.Format(time.RFC3339),
+		RefreshToken:  tokens.RefreshToken,
+		RefreshExpire: tokens.RefreshExpire.Format(time.RFC3339),
+	})
+}
+
+// Logout ends the session of the current access token
+func (h *AuthHandler) Logout(c *gin.Context) {
+	user := middleware.GetUser(c)
+	if err := h.authService.Logout(c.Request.Context(), user.ID, user.SessionID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll ends every session of the current user
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	user := middleware.GetUser(c)
+	if err := h.authService.LogoutAll(c.Request.Context(), user.ID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of every session successfully"})
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 
 	user, err := h.authService.Register(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -74,3 +252,200 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Email:    user.Email,
 	})
 }
+
+// ForgotPassword emails a password reset link. It answers the same whether
+// the address exists or not.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	if err := h.authService.ForgotPassword(c.Request.Context(), req); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email belongs to an account, a reset link has been sent"})
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req PasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	if err := h.authService.ResetPassword(c.Request.Context(), req); err != nil {
+		if err == ErrInvalidToken {
+			c.Error(apperr.Invalid(err))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// ExchangeLoginCode completes a single sign-on with the one-time code the
+// web app received
+func (h *AuthHandler) ExchangeLoginCode(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	client := Client{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+	tokens, user, err := h.authService.ExchangeLoginCode(c.Request.Context(), req, client)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, loginResponse(tokens, user, nil))
+}
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	if err := h.authService.VerifyEmail(c.Request.Context(), req); err != nil {
+		if err == ErrInvalidToken {
+			c.Error(apperr.Invalid(err))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	if err := h.authService.ResendVerification(c.Request.Context(), req); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email needs verification, a new link has been sent"})
+}
+
+func (h *AuthHandler) AcceptInvitation(c *gin.Context) {
+	var req PasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	if err := h.authService.AcceptInvitation(c.Request.Context(), req); err != nil {
+		if err == ErrInvalidToken {
+			c.Error(apperr.Invalid(err))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted successfully"})
+}
+
+func (h *AuthHandler) Invite(c *gin.Context) {
+	var req InviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	user, err := h.authService.Invite(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "User invited successfully", "data": user})
+}
+
+func (h *AuthHandler) ResendInvitation(c *gin.Context) {
+	err := h.authService.ResendInvitation(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("user_not_found", "User not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation sent successfully"})
+}
+
+// Logins returns the recent logins of the tenant, optionally of one user or
+// only the failed or successful ones
+func (h *AuthHandler) Logins(c *gin.Context) {
+	var success *bool
+	if value := c.Query("success"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.Error(apperr.Validation("invalid_request", "Invalid success filter"))
+			return
+		}
+		success = &parsed
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	attempts, err := h.authService.Logins(c.Request.Context(), c.Query("customer_id"), c.Query("user_id"), success, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logins found successfully", "data": attempts})
+}
+
+// Unlock reopens an account locked after too many failed logins
+func (h *AuthHandler) Unlock(c *gin.Context) {
+	if err := h.authService.Unlock(c.Request.Context(), c.Param("id")); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("user_not_found", "User not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// Impersonate lets a system admin act as a tenant user for a while
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	if !middleware.IsAdmin(c) {
+		c.Error(apperr.Forbidden("forbidden", "Forbidden"))
+		return
+	}
+	var req ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	client := Client{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+	tokens, user, impersonation, err := h.authService.Impersonate(c.Request.Context(), middleware.GetUserID(c), c.Param("id"), req, client)
+	if err != nil {
+		switch {
+		case tenant.IsNotFound(err):
+			c.Error(apperr.NotFound("user_not_found", "User not found"))
+		default:
+			c.Error(err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, ImpersonationResponse{
+		Token:         tokens.AccessToken,
+		Expire:        tokens.Expire.Format(time.RFC3339),
+		User:          user,
+		Impersonation: impersonation,
+	})
+}
+
+// Impersonations lists when admins acted as the current user
+func (h *AuthHandler) Impersonations(c *gin.Context) {
+	impersonations, err := h.authService.Impersonations(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Impersonations found successfully", "data": impersonations})
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"api/internal/users"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Impersonation is a period in which a system admin acted as a user. The
+// admin's email is kept in case the admin is deleted.
+type Impersonation struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	AdminID    *uuid.UUID `json:"admin_id"`
+	AdminEmail string     `json:"admin_email"`
+	SessionID  uuid.UUID  `json:"-"`
+	Reason     string     `json:"reason"`
+	StartedAt  time.Time  `json:"started_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	EndedAt    *time.Time `json:"ended_at"`
+}
+
+// Impersonate opens a session of a tenant user for a system admin, so the
+// admin sees exactly what the user sees. The token names the admin, it
+// lasts impersonationTTL and can't be refreshed.
+func (s *authService) Impersonate(ctx context.Context, adminID string, userID string, req ImpersonateRequest, client Client) (Tokens, users.User, Impersonation, error) {
+	parsedAdminID, err := uuid.Parse(adminID)
+	if err != nil {
+		return Tokens{}, users.User{}, Impersonation{}, err
+	}
+	admin, err := s.userService.Lookup(ctx, parsedAdminID)
+	if err != nil {
+		return Tokens{}, users.User{}, Impersonation{}, err
+	}
+	user, err := s.userService.FindByID(ctx, userID)
+	if err != nil {
+		return Tokens{}, users.User{}, Impersonation{}, err
+	}
+	if user.IsAdmin {
+		return Tokens{}, users.User{}, Impersonation{}, ErrImpersonateAdmin
+	}
+	if !user.IsActive {
+		return Tokens{}, users.User{}, Impersonation{}, ErrInactiveUser
+	}
+
+	// The session has a refresh token nobody knows, so it can't be renewed
+	refreshToken, err := randomValue()
+	if err != nil {
+		return Tokens{}, users.User{}, Impersonation{}, err
+	}
+	now := time.Now()
+	session := Session{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		ExpiresAt: now.Add(s.impersonationTTL),
+		CreatedAt: now,
+	}
+	if err := s.repo.CreateSession(ctx, session, hashToken(refreshToken)); err != nil {
+		return Tokens{}, users.User{}, Impersonation{}, err
+	}
+	impersonation := Impersonation{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		AdminID:    &admin.ID,
+		AdminEmail: admin.Email,
+		SessionID:  session.ID,
+		Reason:     req.Reason,
+		StartedAt:  now,
+		ExpiresAt:  session.ExpiresAt,
+	}
+	if err := s.repo.CreateImpersonation(ctx, impersonation); err != nil {
+		return Tokens{}, users.User{}, Impersonation{}, err
+	}
+
+	claims := authUser(user, session)
+	claims.ImpersonatedBy = admin.ID.String()
+	token, expire, err := s.jwtMiddleware.TokenGenerator(claims)
+	if err != nil {
+		return Tokens{}, users.User{}, Impersonation{}, err
+	}
+	slog.Info("Admin impersonating user", slog.String("admin_id", admin.ID.String()),
+		slog.String("user_id", user.ID.String()), slog.String("reason", req.Reason))
+
+	user.Password = ""
+	return Tokens{AccessToken: token, Expire: expire}, user, impersonation, nil
+}
+
+// Impersonations returns when admins acted as the user, newest first
+func (s *authService) Impersonations(ctx context.Context, userID string) ([]Impersonation, error) {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindImpersonations(ctx, parsedUserID)
+}
@@ -0,0 +1,438 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Repository stores the tokens sent by email and the account changes they
+// allow
+type Repository interface {
+	CreateToken(ctx context.Context, token Token) error
+	ConsumeToken(ctx context.Context, purpose string, hash string) (uuid.UUID, error)
+	FindRecipient(ctx context.Context, userID uuid.UUID) (Recipient, error)
+	SetPassword(ctx context.Context, userID uuid.UUID, password string) error
+	MarkEmailVerified(ctx context.Context, userID uuid.UUID) error
+	Activate(ctx context.Context, userID uuid.UUID, password string) error
+	CreateSession(ctx context.Context, session Session, refreshHash string) error
+	RotateSession(ctx context.Context, refreshHash string, newHash string, expiresAt time.Time) (Session, error)
+	RevokeReused(ctx context.Context, refreshHash string) (bool, error)
+	RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) error
+	RevokeSessions(ctx context.Context, userID uuid.UUID) error
+	IsSessionActive(ctx context.Context, sessionID uuid.UUID) (bool, error)
+	FindToken(ctx context.Context, purpose string, hash string) (uuid.UUID, error)
+	FailToken(ctx context.Context, hash string) error
+	FindTwoFactor(ctx context.Context, userID uuid.UUID) (TwoFactor, error)
+	SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error
+	EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error
+	DisableTOTP(ctx context.Context, userID uuid.UUID) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	LockedUntil(ctx context.Context, userID uuid.UUID) (*time.Time, error)
+	FailLogin(ctx context.Context, userID uuid.UUID) (int, error)
+	LockUser(ctx context.Context, userID uuid.UUID, until time.Time) error
+	Unlock(ctx context.Context, userID uuid.UUID) error
+	CountFailedLogins(ctx context.Context, ip string, since time.Time) (int, error)
+	CreateImpersonation(ctx context.Context, impersonation Impersonation) error
+	EndImpersonation(ctx context.Context, sessionID uuid.UUID) error
+	FindImpersonations(ctx context.Context, userID uuid.UUID) ([]Impersonation, error)
+	CreateLoginAttempt(ctx context.Context, attempt LoginAttempt) error
+	FindLoginAttempts(ctx context.Context, filter LoginFilter) ([]LoginAttempt, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// CreateToken stores a token and revokes the unused tokens of the user for
+// the same purpose, so only the latest link works
+func (r *repository) CreateToken(ctx context.Context, token Token) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	revoke := `UPDATE user_tokens SET used_at = $3 WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+	if _, err := tx.ExecContext(ctx, revoke, token.UserID, token.Purpose, token.CreatedAt); err != nil {
+		return err
+	}
+	query := `INSERT INTO user_tokens (id, user_id, purpose, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := tx.ExecContext(ctx, query, token.ID, token.UserID, token.Purpose, token.Hash, token.ExpiresAt, token.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ConsumeToken marks a valid token as used and returns its user. It returns
+// sql.ErrNoRows when the token is unknown, used or expired.
+func (r *repository) ConsumeToken(ctx context.Context, purpose string, hash string) (uuid.UUID, error) {
+	query := `UPDATE user_tokens SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING user_id`
+	var userID uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, hash, purpose, time.Now()).Scan(&userID); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// FindRecipient returns who to send an email to and in which language
+func (r *repository) FindRecipient(ctx context.Context, userID uuid.UUID) (Recipient, error) {
+	query := `SELECT u.id, u.email, COALESCE(u.username, ''), c.name, COALESCE(c.language, '')
+		FROM users u JOIN customers c ON c.id = u.customer_id WHERE u.id = $1 AND u.deleted_at IS NULL`
+	var recipient Recipient
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&recipient.UserID, &recipient.Email, &recipient.Username, &recipient.Customer, &recipient.Language)
+	if err != nil {
+		return Recipient{}, err
+	}
+	return recipient, nil
+}
+
+func (r *repository) SetPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	query := `UPDATE users SET password = $2, updated_at = $3 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID, password, time.Now())
+	return err
+}
+
+func (r *repository) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, $2), updated_at = $2 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID, time.Now())
+	return err
+}
+
+// Activate sets the password of an invited user. Following the invitation
+// link also proves the email address.
+func (r *repository) Activate(ctx context.Context, userID uuid.UUID, password string) error {
+	query := `UPDATE users SET password = $2, is_active = TRUE, email_verified_at = COALESCE(email_verified_at, $3), updated_at = $3 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID, password, time.Now())
+	return err
+}
+
+func (r *repository) CreateSession(ctx context.Context, session Session, refreshHash string) error {
+	query := `INSERT INTO sessions (id, user_id, refresh_hash, user_agent, ip, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.ExecContext(ctx, query, session.ID, session.UserID, refreshHash, session.UserAgent, session.IP, session.ExpiresAt, session.CreatedAt)
+	return err
+}
+
+// RotateSession replaces the refresh token of an active session and extends
+// it. It returns sql.ErrNoRows when the token isn't the current one of an
+// active session.
+func (r *repository) RotateSession(ctx context.Context, refreshHash string, newHash string, expiresAt time.Time) (Session, error) {
+	query := `UPDATE sessions SET refresh_hash = $2, previous_hash = refresh_hash, expires_at = $3, last_used_at = $4
+		WHERE refresh_hash = $1 AND revoked_at IS NULL AND expires_at > $4
+		RETURNING id, user_id, COALESCE(user_agent, ''), COALESCE(ip, ''), expires_at, created_at, last_used_at`
+	var session Session
+	err := r.db.QueryRowContext(ctx, query, refreshHash, newHash, expiresAt, time.Now()).Scan(
+		&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.ExpiresAt, &session.CreatedAt, &session.LastUsedAt)
+	if err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// RevokeReused revokes the session a rotated out refresh token belonged to.
+// A reused token means it was copied, so the whole session is dropped.
+func (r *repository) RevokeReused(ctx context.Context, refreshHash string) (bool, error) {
+	query := `UPDATE sessions SET revoked_at = $2 WHERE previous_hash = $1 AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, refreshHash, time.Now())
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+func (r *repository) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = $3 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, sessionID, userID, time.Now())
+	return err
+}
+
+func (r *repository) RevokeSessions(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, userID, time.Now())
+	return err
+}
+
+// IsSessionActive reports whether the session is neither revoked nor expired
+// and its user is still active and not deleted
+func (r *repository) IsSessionActive(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (
+		SELECT 1 FROM sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.id = $1 AND s.revoked_at IS NULL AND s.expires_at > $2 AND u.is_active AND u.deleted_at IS NULL
+	)`
+	var active bool
+	if err := r.db.QueryRowContext(ctx, query, sessionID, time.Now()).Scan(&active); err != nil {
+		return false, err
+	}
+	return active, nil
+}
+
+// FindToken returns the user of a valid token without using it. It returns
+// sql.ErrNoRows when the token is unknown, used, expired or out of attempts.
+func (r *repository) FindToken(ctx context.Context, purpose string, hash string) (uuid.UUID, error) {
+	query := `SELECT user_id FROM user_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3 AND attempts < $4`
+	var userID uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, hash, purpose, time.Now(), maxTokenAttempts).Scan(&userID); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// FailToken counts a wrong code entered with the token
+func (r *repository) FailToken(ctx context.Context, hash string) error {
+	query := `UPDATE user_tokens SET attempts = attempts + 1 WHERE token_hash = $1`
+	_, err := r.db.ExecContext(ctx, query, hash)
+	return err
+}
+
+func (r *repository) FindTwoFactor(ctx context.Context, userID uuid.UUID) (TwoFactor, error) {
+	query := `SELECT COALESCE(u.totp_secret, ''), u.totp_enabled_at, c.require_two_factor,
+		(SELECT COUNT(*) FROM user_recovery_codes rc WHERE rc.user_id = u.id AND rc.used_at IS NULL)
+		FROM users u JOIN customers c ON c.id = u.customer_id WHERE u.id = $1 AND u.deleted_at IS NULL`
+	var twoFactor TwoFactor
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&twoFactor.Secret, &twoFactor.EnabledAt, &twoFactor.Required, &twoFactor.RecoveryCodesLeft)
+	if err != nil {
+		return TwoFactor{}, err
+	}
+	twoFactor.Enabled = twoFactor.EnabledAt != nil
+	return twoFactor, nil
+}
+
+// SetTOTPSecret stores the secret of a pending enrollment
+func (r *repository) SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	query := `UPDATE users SET totp_secret = $2, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID, secret)
+	return err
+}
+
+// EnableTOTP completes the enrollment and stores the recovery codes
+func (r *repository) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET totp_enabled_at = $2, totp_last_step = $3 WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, userID, time.Now(), step); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) DisableTOTP(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records the time step of an accepted code. It returns false
+// when a code of that step or a later one was already used.
+func (r *repository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := `UPDATE users SET totp_last_step = $2 WHERE id = $1 AND COALESCE(totp_last_step, -1) < $2`
+	result, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used
+func (r *repository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) (bool, error) {
+	query := `UPDATE user_recovery_codes SET used_at = $3 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, userID, hash, time.Now())
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+func (r *repository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID uuid.UUID, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		query := `INSERT INTO user_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, query, uuid.New(), userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repository) LockedUntil(ctx context.Context, userID uuid.UUID) (*time.Time, error) {
+	query := `SELECT locked_until FROM users WHERE id = $1`
+	var lockedUntil *time.Time
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&lockedUntil); err != nil {
+		return nil, err
+	}
+	return lockedUntil, nil
+}
+
+// FailLogin counts a failed login of the user and returns the consecutive failures
+func (r *repository) FailLogin(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `UPDATE users SET failed_logins = failed_logins + 1 WHERE id = $1 RETURNING failed_logins`
+	var failures int
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&failures); err != nil {
+		return 0, err
+	}
+	return failures, nil
+}
+
+func (r *repository) LockUser(ctx context.Context, userID uuid.UUID, until time.Time) error {
+	query := `UPDATE users SET locked_until = $2 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID, until)
+	return err
+}
+
+// Unlock clears the failed logins and the lock of the user
+func (r *repository) Unlock(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE users SET failed_logins = 0, locked_until = NULL
+		WHERE id = $1 AND (failed_logins > 0 OR locked_until IS NOT NULL)`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (r *repository) CountFailedLogins(ctx context.Context, ip string, since time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM login_attempts WHERE ip = $1 AND success = FALSE AND created_at >= $2`
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, ip, since).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *repository) CreateLoginAttempt(ctx context.Context, attempt LoginAttempt) error {
+	query := `INSERT INTO login_attempts (id, user_id, customer_id, email, ip, user_agent, success, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9)`
+	_, err := r.db.ExecContext(ctx, query, attempt.ID, attempt.UserID, attempt.CustomerID, attempt.Email,
+		attempt.IP, attempt.UserAgent, attempt.Success, attempt.Reason, attempt.CreatedAt)
+	return err
+}
+
+func (r *repository) FindLoginAttempts(ctx context.Context, filter LoginFilter) ([]LoginAttempt, error) {
+	query := `SELECT id, user_id, customer_id, email, COALESCE(ip, ''), COALESCE(user_agent, ''), success,
+		COALESCE(reason, ''), created_at
+	FROM login_attempts WHERE 1=1`
+
+	var args []interface{}
+	argId := 1
+
+	if filter.CustomerID != nil {
+		query += fmt.Sprintf(" AND customer_id = $%d", argId)
+		args = append(args, *filter.CustomerID)
+		argId++
+	}
+	if filter.UserID != nil {
+		query += fmt.Sprintf(" AND user_id = $%d", argId)
+		args = append(args, *filter.UserID)
+		argId++
+	}
+	if filter.Success != nil {
+		query += fmt.Sprintf(" AND success = $%d", argId)
+		args = append(args, *filter.Success)
+		argId++
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", argId)
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	attempts := []LoginAttempt{}
+	for rows.Next() {
+		var a LoginAttempt
+		if err := rows.Scan(&a.ID, &a.UserID, &a.CustomerID, &a.Email, &a.IP, &a.UserAgent, &a.Success,
+			&a.Reason, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+func (r *repository) CreateImpersonation(ctx context.Context, impersonation Impersonation) error {
+	query := `INSERT INTO impersonations (id, user_id, admin_id, admin_email, session_id, reason, started_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := r.db.ExecContext(ctx, query, impersonation.ID, impersonation.UserID, impersonation.AdminID,
+		impersonation.AdminEmail, impersonation.SessionID, impersonation.Reason, impersonation.StartedAt, impersonation.ExpiresAt)
+	return err
+}
+
+// EndImpersonation marks the impersonation of a session as ended, if it is one
+func (r *repository) EndImpersonation(ctx context.Context, sessionID uuid.UUID) error {
+	query := `UPDATE impersonations SET ended_at = $2 WHERE session_id = $1 AND ended_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, sessionID, time.Now())
+	return err
+}
+
+func (r *repository) FindImpersonations(ctx context.Context, userID uuid.UUID) ([]Impersonation, error) {
+	query := `SELECT id, user_id, admin_id, admin_email, reason, started_at, expires_at, ended_at
+	FROM impersonations WHERE user_id = $1 ORDER BY started_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	impersonations := []Impersonation{}
+	for rows.Next() {
+		var i Impersonation
+		if err := rows.Scan(&i.ID, &i.UserID, &i.AdminID, &i.AdminEmail, &i.Reason, &i.StartedAt, &i.ExpiresAt, &i.EndedAt); err != nil {
+			return nil, err
+		}
+		impersonations = append(impersonations, i)
+	}
+	return impersonations, rows.Err()
+}
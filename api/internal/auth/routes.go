@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"api/middleware"
+
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
 )
@@ -8,5 +10,41 @@ import (
 func RegisterRoutes(router *gin.RouterGroup, handler *AuthHandler, jwtMiddleware *jwt.GinJWTMiddleware) {
 	router.POST("/login", handler.Login)
 	router.POST("/register", handler.Register)
-	router.GET("/refresh_token", jwtMiddleware.RefreshHandler)
+	router.POST("/refresh_token", handler.RefreshToken)
+	router.POST("/password/forgot", handler.ForgotPassword)
+	router.POST("/password/reset", handler.ResetPassword)
+	router.POST("/email/verify", handler.VerifyEmail)
+	router.POST("/email/resend", handler.ResendVerification)
+	router.POST("/invitations/accept", handler.AcceptInvitation)
+	router.POST("/2fa/verify", handler.VerifyTwoFactor)
+	router.POST("/2fa/enroll", handler.StartEnrollment)
+	router.POST("/2fa/enroll/confirm", handler.FinishEnrollment)
+	router.POST("/sso/exchange", handler.ExchangeLoginCode)
+}
+
+// RegisterSessionRoutes registers the routes of the logged in user's sessions.
+// Admins impersonating the user can only log out, which ends the
+// impersonation, and read.
+func RegisterSessionRoutes(router *gin.RouterGroup, handler *AuthHandler) {
+	router.POST("/auth/logout", handler.Logout)
+	router.GET("/auth/2fa", handler.TwoFactorStatus)
+	router.GET("/auth/impersonations", handler.Impersonations)
+
+	secured := router.Group("", middleware.RejectImpersonation())
+	secured.POST("/auth/logout-all", handler.LogoutAll)
+	secured.POST("/auth/2fa/enroll", handler.Enroll)
+	secured.POST("/auth/2fa/confirm", handler.ConfirmEnrollment)
+	secured.POST("/auth/2fa/disable", handler.DisableTwoFactor)
+	secured.POST("/auth/2fa/recovery-codes", handler.RegenerateRecoveryCodes)
+}
+
+// RegisterUserRoutes registers the routes admins use to invite users and
+// manage their accounts
+func RegisterUserRoutes(router *gin.RouterGroup, handler *AuthHandler) {
+	router.POST("/users/invite", handler.Invite)
+	router.POST("/users/:id/invite", handler.ResendInvitation)
+	router.DELETE("/users/:id/two-factor", handler.ResetTwoFactor)
+	router.GET("/users/logins", handler.Logins)
+	router.POST("/users/:id/unlock", handler.Unlock)
+	router.POST("/users/:id/impersonate", handler.Impersonate)
 }
\ No newline at end of file
@@ -1,97 +1,498 @@
 package auth
 
 import (
+	"api/config"
 	"api/internal/customers"
+	"api/internal/email"
 	"api/internal/users"
 	"api/middleware"
 	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/url"
 
 	"time"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService interface {
-    Login(ctx context.Context, req LoginRequest) (string, users.User, time.Time, error)
+    Login(ctx context.Context, req LoginRequest, client Client) (Tokens, users.User, Challenge, error)
+	VerifyTwoFactor(ctx context.Context, req TwoFactorRequest, client Client) (Tokens, users.User, error)
+	StartEnrollment(ctx context.Context, req TokenRequest) (Enrollment, error)
+	FinishEnrollment(ctx context.Context, req TwoFactorRequest, client Client) (Tokens, users.User, []string, error)
+	TwoFactorStatus(ctx context.Context, userID string) (TwoFactor, error)
+	Enroll(ctx context.Context, userID string) (Enrollment, error)
+	ConfirmEnrollment(ctx context.Context, userID string, req CodeRequest) ([]string, error)
+	DisableTwoFactor(ctx context.Context, userID string, req CodeRequest) error
+	RegenerateRecoveryCodes(ctx context.Context, userID string, req CodeRequest) ([]string, error)
+	ResetTwoFactor(ctx context.Context, userID string) error
+	Logins(ctx context.Context, customerID string, userID string, success *bool, limit int) ([]LoginAttempt, error)
+	Unlock(ctx context.Context, userID string) error
+	Impersonate(ctx context.Context, adminID string, userID string, req ImpersonateRequest, client Client) (Tokens, users.User, Impersonation, error)
+	Impersonations(ctx context.Context, userID string) ([]Impersonation, error)
+	IssueLoginCode(ctx context.Context, userID uuid.UUID) (string, error)
+	ExchangeLoginCode(ctx context.Context, req TokenRequest, client Client) (Tokens, users.User, error)
+	Refresh(ctx context.Context, req RefreshRequest) (Tokens, error)
+	Logout(ctx context.Context, userID string, sessionID string) error
+	LogoutAll(ctx context.Context, userID string) error
+	ActiveSession(ctx context.Context, sessionID uuid.UUID) (bool, error)
     Register(ctx context.Context, req RegisterRequest) (users.User, error)
     ValidateUser(ctx context.Context, username, password string) (users.User, error)
+	ForgotPassword(ctx context.Context, req EmailRequest) error
+	ResetPassword(ctx context.Context, req PasswordRequest) error
+	VerifyEmail(ctx context.Context, req TokenRequest) error
+	ResendVerification(ctx context.Context, req EmailRequest) error
+	Invite(ctx context.Context, req InviteRequest) (users.User, error)
+	ResendInvitation(ctx context.Context, userID string) error
+	AcceptInvitation(ctx context.Context, req PasswordRequest) error
 }
 
 type authService struct {
 	userService users.Service
     customerService customers.Service
 	jwtMiddleware *jwt.GinJWTMiddleware
+	repo Repository
+	sender email.Sender
+	appURL string
+	sessionTTL time.Duration
+	impersonationTTL time.Duration
 }
 
-func NewAuthService(userService users.Service, customerService customers.Service, jwtMiddleware *jwt.GinJWTMiddleware) AuthService {
+func NewAuthService(userService users.Service, customerService customers.Service, jwtMiddleware *jwt.GinJWTMiddleware, repo Repository, sender email.Sender, cfg config.Config) AuthService {
 	return &authService{
 		userService: userService,
 		customerService: customerService,
 		jwtMiddleware: jwtMiddleware,
+		repo: repo,
+		sender: sender,
+		appURL: cfg.App.URL,
+		sessionTTL: cfg.Auth.RefreshTTL,
+		impersonationTTL: cfg.Auth.ImpersonationTTL,
 	}
 }
 
-// Login verifica les credencials i retorna un token JWT si són vàlides
-func (s *authService) Login(ctx context.Context, req LoginRequest) (string, users.User, time.Time, error) {
+// Login verifica les credencials i retorna un token JWT si són vàlides.
+// Amb 2FA retorna un repte en lloc dels tokens.
+func (s *authService) Login(ctx context.Context, req LoginRequest, client Client) (Tokens, users.User, Challenge, error) {
+    // Frenar les IPs amb massa intents fallits
+    if err := s.checkIP(ctx, client.IP); err != nil {
+        s.recordLogin(ctx, req.Email, users.User{}, client, err)
+        return Tokens{}, users.User{}, Challenge{}, err
+    }
+
     // Validar les credencials
-    user, err := s.ValidateUser(ctx, req.Email, req.Password)
+    user, err := s.authenticate(ctx, req.Email, req.Password)
+    if err != nil {
+        s.recordLogin(ctx, req.Email, user, client, err)
+        return Tokens{}, users.User{}, Challenge{}, err
+    }
+
+    // Demanar el segon factor, o l'alta si el tenant l'exigeix
+    twoFactor, err := s.repo.FindTwoFactor(ctx, user.ID)
     if err != nil {
-        return "", users.User{}, time.Time{}, err
+        return Tokens{}, users.User{}, Challenge{}, err
+    }
+    // El login es registra quan es completa el segon factor
+    if twoFactor.Enabled || twoFactor.Required {
+        challenge, err := s.challenge(ctx, user.ID, !twoFactor.Enabled)
+        return Tokens{}, users.User{}, challenge, err
     }
     
-    // Generar token JWT
-    authUser := &middleware.AuthUser{
-        ID:         user.ID.String(),
-        CustomerID:   user.CustomerID.String(),
-        Username:   user.Username,
-        Email:      user.Email,        
-        IsAdmin:    user.IsAdmin,
+    if err := s.repo.Unlock(ctx, user.ID); err != nil {
+        return Tokens{}, users.User{}, Challenge{}, err
     }
-    token, expire, err := s.jwtMiddleware.TokenGenerator(authUser)
+
+    // Obrir una sessió i generar els tokens
+    tokens, err := s.startSession(ctx, user, client)
     if err != nil {
-        return "", users.User{}, time.Time{}, err
+        return Tokens{}, users.User{}, Challenge{}, err
     }
+    s.recordLogin(ctx, req.Email, user, client, nil)
    
     user.Password = "" // No retornar la contrasenya en la resposta
-    return token, user,  expire, nil
+    return tokens, user, Challenge{}, nil
 }
 
-func (s *authService) Register(ctx context.Context, req RegisterRequest) (users.User, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-    if err != nil {
-        return users.User{}, err
-    }
+// startSession opens a session for the user and issues its tokens
+func (s *authService) startSession(ctx context.Context, user users.User, client Client) (Tokens, error) {
+	refreshToken, err := randomValue()
+	if err != nil {
+		return Tokens{}, err
+	}
+	now := time.Now()
+	session := Session{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		ExpiresAt: now.Add(s.sessionTTL),
+		CreatedAt: now,
+	}
+	if err := s.repo.CreateSession(ctx, session, hashToken(refreshToken)); err != nil {
+		return Tokens{}, err
+	}
+	return s.issue(user, session, refreshToken)
+}
+
+// issue generates an access token bound to the session
+func (s *authService) issue(user users.User, session Session, refreshToken string) (Tokens, error) {
+	token, expire, err := s.jwtMiddleware.TokenGenerator(authUser(user, session))
+	if err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{
+		AccessToken:   token,
+		Expire:        expire,
+		RefreshToken:  refreshToken,
+		RefreshExpire: session.ExpiresAt,
+	}, nil
+}
+
+// authUser returns the claims of an access token of the session
+func authUser(user users.User, session Session) *middleware.AuthUser {
+	return &middleware.AuthUser{
+		ID:         user.ID.String(),
+		CustomerID: user.CustomerID.String(),
+		Username:   user.Username,
+		Email:      user.Email,
+		IsAdmin:    user.IsAdmin,
+		SessionID:  session.ID.String(),
+	}
+}
+
+// Refresh rotates the refresh token and issues a new access token. Using a
+// refresh token that was already rotated out revokes its session.
+func (s *authService) Refresh(ctx context.Context, req RefreshRequest) (Tokens, error) {
+	refreshToken, err := randomValue()
+	if err != nil {
+		return Tokens{}, err
+	}
+	hash := hashToken(req.RefreshToken)
+	session, err := s.repo.RotateSession(ctx, hash, hashToken(refreshToken), time.Now().Add(s.sessionTTL))
+	if errors.Is(err, sql.ErrNoRows) {
+		reused, err := s.repo.RevokeReused(ctx, hash)
+		if err != nil {
+			return Tokens{}, err
+		}
+		if reused {
+			slog.Warn("Refresh token reused, session revoked")
+		}
+		return Tokens{}, ErrInvalidToken
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+	user, err := s.userService.Lookup(ctx, session.UserID)
+	if err != nil {
+		return Tokens{}, err
+	}
+	if !user.IsActive {
+		if err := s.repo.RevokeSessions(ctx, user.ID); err != nil {
+			return Tokens{}, err
+		}
+		return Tokens{}, ErrInactiveUser
+	}
+	return s.issue(user, session, refreshToken)
+}
 
-    user := users.UserRequest{
+// Logout revokes the session of the access token, ending the impersonation
+// it was opened for, if any
+func (s *authService) Logout(ctx context.Context, userID string, sessionID string) error {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+	parsedSessionID, err := uuid.Parse(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.RevokeSession(ctx, parsedUserID, parsedSessionID); err != nil {
+		return err
+	}
+	return s.repo.EndImpersonation(ctx, parsedSessionID)
+}
+
+// LogoutAll revokes every session of the user, on every device
+func (s *authService) LogoutAll(ctx context.Context, userID string) error {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+	return s.repo.RevokeSessions(ctx, parsedUserID)
+}
+
+func (s *authService) ActiveSession(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	return s.repo.IsSessionActive(ctx, sessionID)
+}
+
+// Register creates the user and sends the link to verify the email address.
+// A failed delivery doesn't undo the registration; the link can be resent.
+func (s *authService) Register(ctx context.Context, req RegisterRequest) (users.User, error) {
+    request := users.UserRequest{
         Email:      req.Email,
-        Password:   string(hashedPassword),
+        Password:   req.Password,
         CustomerID: req.CustomerID,
     }
 
-    return s.userService.Create(ctx, user)
+    user, err := s.userService.Register(ctx, request)
+    if err != nil {
+        return users.User{}, err
+    }
+    if err := s.send(ctx, user.ID, PurposeEmailVerification); err != nil {
+        slog.Error("Unable to send verification email", slog.String("user_id", user.ID.String()), slog.Any("error", err))
+    }
+    return user, nil
 }
 
 // ValidateUser verifica si les credencials són vàlides i retorna l'ID de l'usuari
 func (s *authService) ValidateUser(ctx context.Context, email, password string) (users.User, error) {
+    user, err := s.authenticate(ctx, email, password)
+    if err != nil {
+        return users.User{}, err
+    }
+    return user, nil
+}
+
+// authenticate verifica les credencials. Retorna l'usuari, si existeix, també
+// quan falla, per registrar l'intent.
+func (s *authService) authenticate(ctx context.Context, email, password string) (users.User, error) {
     // Obtenir l'usuari per email
     user, err := s.userService.FindByEmail(ctx, email)
 
     if err != nil {
         return users.User{}, ErrUserNotFound
     }
+
+    // Rebutjar els comptes bloquejats sense comprovar la contrasenya
+    if err := s.checkLocked(ctx, user.ID); err != nil {
+        return user, err
+    }
     
     // Verificar que l'usuari estigui actiu
     if !user.IsActive {
-        return users.User{}, ErrInactiveUser
+        return user, ErrInactiveUser
     }
     
     // Verificar la contrasenya
     err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
     if err != nil {
-        return users.User{}, ErrInvalidCredentials
+        if err := s.failLogin(ctx, user.ID); err != nil {
+            return user, err
+        }
+        return user, ErrInvalidCredentials
+    }
+    // Els intents fallits es netegen quan el login es completa, amb el segon factor si cal
+
+    if user.EmailVerifiedAt == nil {
+        return user, ErrEmailNotVerified
     }
     
     // Retornar l'ID de l'usuari com a identificador principal
     return user, nil
-}
\ No newline at end of file
+}
+
+// link describes the email sent for each token purpose
+type link struct {
+	template string
+	path     string
+	ttl      time.Duration
+}
+
+var links = map[string]link{
+	PurposePasswordReset:     {template: email.TemplatePasswordReset, path: "/reset-password", ttl: passwordResetTTL},
+	PurposeEmailVerification: {template: email.TemplateVerifyEmail, path: "/verify-email", ttl: emailVerificationTTL},
+	PurposeInvitation:        {template: email.TemplateInvitation, path: "/accept-invitation", ttl: invitationTTL},
+}
+
+// send issues a new token for the user and emails the link in the language
+// of their customer
+func (s *authService) send(ctx context.Context, userID uuid.UUID, purpose string) error {
+	l := links[purpose]
+	token, value, err := newToken(userID, purpose, l.ttl)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.CreateToken(ctx, token); err != nil {
+		return err
+	}
+	recipient, err := s.repo.FindRecipient(ctx, userID)
+	if err != nil {
+		return err
+	}
+	name := recipient.Username
+	if name == "" {
+		name = recipient.Email
+	}
+	message, err := email.Render(recipient.Language, l.template, recipient.Email, email.Data{
+		Name:     name,
+		Customer: recipient.Customer,
+		Link:     s.appURL + l.path + "?token=" + url.QueryEscape(value),
+		Hours:    int(l.ttl.Hours()),
+	})
+	if err != nil {
+		return err
+	}
+	return s.sender.Send(ctx, message)
+}
+
+// consume uses a token and returns its user
+func (s *authService) consume(ctx context.Context, purpose string, value string) (uuid.UUID, error) {
+	userID, err := s.repo.ConsumeToken(ctx, purpose, hashToken(value))
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	return userID, err
+}
+
+// IssueLoginCode returns a one-time code the web app exchanges for the
+// tokens of the user. Logins that end outside the app, such as single
+// sign-on, use it so the tokens never travel in a URL.
+func (s *authService) IssueLoginCode(ctx context.Context, userID uuid.UUID) (string, error) {
+	token, value, err := newToken(userID, PurposeLoginCode, loginCodeTTL)
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.CreateToken(ctx, token); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// ExchangeLoginCode opens a session for the user of a login code
+func (s *authService) ExchangeLoginCode(ctx context.Context, req TokenRequest, client Client) (Tokens, users.User, error) {
+	userID, err := s.consume(ctx, PurposeLoginCode, req.Token)
+	if err != nil {
+		return Tokens{}, users.User{}, err
+	}
+	user, err := s.userService.Lookup(ctx, userID)
+	if err != nil {
+		return Tokens{}, users.User{}, err
+	}
+	if !user.IsActive {
+		s.recordLogin(ctx, user.Email, user, client, ErrInactiveUser)
+		return Tokens{}, users.User{}, ErrInactiveUser
+	}
+	tokens, err := s.startSession(ctx, user, client)
+	if err != nil {
+		return Tokens{}, users.User{}, err
+	}
+	s.recordLogin(ctx, user.Email, user, client, nil)
+	user.Password = ""
+	return tokens, user, nil
+}
+
+// ForgotPassword emails a password reset link. Unknown and inactive
+// addresses are ignored so the answer doesn't reveal which accounts exist.
+func (s *authService) ForgotPassword(ctx context.Context, req EmailRequest) error {
+	user, err := s.userService.FindByEmail(ctx, req.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.IsActive {
+		return nil
+	}
+	if err := s.send(ctx, user.ID, PurposePasswordReset); err != nil {
+		slog.Error("Unable to send password reset email", slog.String("user_id", user.ID.String()), slog.Any("error", err))
+	}
+	return nil
+}
+
+// ResetPassword sets a new password and ends the sessions of the user.
+// Receiving the link also proves the email address.
+func (s *authService) ResetPassword(ctx context.Context, req PasswordRequest) error {
+	userID, err := s.consume(ctx, PurposePasswordReset, req.Token)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SetPassword(ctx, userID, string(hashedPassword)); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeSessions(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.repo.Unlock(ctx, userID); err != nil {
+		return err
+	}
+	return s.repo.MarkEmailVerified(ctx, userID)
+}
+
+func (s *authService) VerifyEmail(ctx context.Context, req TokenRequest) error {
+	userID, err := s.consume(ctx, PurposeEmailVerification, req.Token)
+	if err != nil {
+		return err
+	}
+	return s.repo.MarkEmailVerified(ctx, userID)
+}
+
+// ResendVerification emails a new verification link to an unverified user
+func (s *authService) ResendVerification(ctx context.Context, req EmailRequest) error {
+	user, err := s.userService.FindByEmail(ctx, req.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil || !user.IsActive {
+		return nil
+	}
+	if err := s.send(ctx, user.ID, PurposeEmailVerification); err != nil {
+		slog.Error("Unable to send verification email", slog.String("user_id", user.ID.String()), slog.Any("error", err))
+	}
+	return nil
+}
+
+// Invite creates an inactive user and emails the invitation. If the email
+// can't be delivered the user is kept and the invitation can be resent.
+func (s *authService) Invite(ctx context.Context, req InviteRequest) (users.User, error) {
+	user, err := s.userService.Invite(ctx, users.UserRequest{
+		CustomerID: req.CustomerID,
+		Username:   req.Username,
+		Email:      req.Email,
+		Role:       req.Role,
+	})
+	if err != nil {
+		return users.User{}, err
+	}
+	if err := s.send(ctx, user.ID, PurposeInvitation); err != nil {
+		slog.Error("Unable to send invitation email", slog.String("user_id", user.ID.String()), slog.Any("error", err))
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// ResendInvitation emails a new invitation and revokes the previous one
+func (s *authService) ResendInvitation(ctx context.Context, userID string) error {
+	user, err := s.userService.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.IsActive || user.EmailVerifiedAt != nil {
+		return ErrInvitationAccepted
+	}
+	return s.send(ctx, user.ID, PurposeInvitation)
+}
+
+// AcceptInvitation sets the password chosen by the invitee and activates the user
+func (s *authService) AcceptInvitation(ctx context.Context, req PasswordRequest) error {
+	userID, err := s.consume(ctx, PurposeInvitation, req.Token)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.repo.Activate(ctx, userID, string(hashedPassword))
+}
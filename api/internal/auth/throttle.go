@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"api/internal/tenant"
+	"api/internal/users"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Brute-force protection of the login
+const (
+	// lockoutThreshold consecutive failed logins lock the account. Every
+	// further threshold doubles the lockout, up to maxLockout.
+	lockoutThreshold = 5
+	baseLockout      = time.Minute
+	maxLockout       = time.Hour
+	// maxIPFailures failed logins from an IP within ipWindow block it
+	maxIPFailures = 20
+	ipWindow      = 15 * time.Minute
+)
+
+const (
+	defaultLoginLimit = 100
+	maxLoginLimit     = 1000
+)
+
+// LoginAttempt is an entry of the login audit trail. The user and customer
+// are empty when the email matched no user.
+type LoginAttempt struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     *uuid.UUID `json:"user_id"`
+	CustomerID *uuid.UUID `json:"customer_id"`
+	Email      string     `json:"email"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	Success    bool       `json:"success"`
+	Reason     string     `json:"reason"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type LoginFilter struct {
+	CustomerID *uuid.UUID
+	UserID     *uuid.UUID
+	Success    *bool
+	Limit      int
+}
+
+// lockout returns how long the account is locked after failures consecutive
+// failed logins, or zero when it stays open
+func lockout(failures int) time.Duration {
+	if failures < lockoutThreshold || failures%lockoutThreshold != 0 {
+		return 0
+	}
+	duration := baseLockout
+	for i := lockoutThreshold; i < failures && duration < maxLockout; i += lockoutThreshold {
+		duration *= 2
+	}
+	if duration > maxLockout {
+		return maxLockout
+	}
+	return duration
+}
+
+// checkIP rejects the logins of an IP with too many recent failures
+func (s *authService) checkIP(ctx context.Context, ip string) error {
+	if ip == "" {
+		return nil
+	}
+	failures, err := s.repo.CountFailedLogins(ctx, ip, time.Now().Add(-ipWindow))
+	if err != nil {
+		return err
+	}
+	if failures >= maxIPFailures {
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+// checkLocked rejects the logins of an account while it is locked
+func (s *authService) checkLocked(ctx context.Context, userID uuid.UUID) error {
+	lockedUntil, err := s.repo.LockedUntil(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if lockedUntil != nil && lockedUntil.After(time.Now()) {
+		return ErrAccountLocked
+	}
+	return nil
+}
+
+// failLogin counts a wrong password or second factor code and locks the
+// account when it reaches the next threshold
+func (s *authService) failLogin(ctx context.Context, userID uuid.UUID) error {
+	failures, err := s.repo.FailLogin(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if duration := lockout(failures); duration > 0 {
+		return s.repo.LockUser(ctx, userID, time.Now().Add(duration))
+	}
+	return nil
+}
+
+// recordLogin adds a login to the audit trail. The login goes on when it
+// can't be recorded.
+func (s *authService) recordLogin(ctx context.Context, email string, user users.User, client Client, loginErr error) {
+	attempt := LoginAttempt{
+		ID:        uuid.New(),
+		Email:     email,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Success:   loginErr == nil,
+		CreatedAt: time.Now(),
+	}
+	if loginErr != nil {
+		attempt.Reason = loginErr.Error()
+	}
+	if user.ID != uuid.Nil {
+		attempt.UserID = &user.ID
+		attempt.CustomerID = &user.CustomerID
+	}
+	if err := s.repo.CreateLoginAttempt(ctx, attempt); err != nil {
+		slog.Warn("Unable to record login", slog.String("email", email), slog.Any("error", err))
+	}
+}
+
+// Logins returns the recent logins of the tenant, newest first
+func (s *authService) Logins(ctx context.Context, customerID string, userID string, success *bool, limit int) ([]LoginAttempt, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	filter := LoginFilter{CustomerID: scope, Success: success, Limit: limit}
+	if userID != "" {
+		parsedUserID, err := uuid.Parse(userID)
+		if err != nil {
+			return nil, err
+		}
+		filter.UserID = &parsedUserID
+	}
+	if filter.Limit <= 0 || filter.Limit > maxLoginLimit {
+		filter.Limit = defaultLoginLimit
+	}
+	return s.repo.FindLoginAttempts(ctx, filter)
+}
+
+// Unlock reopens a locked account of the tenant and clears its failed logins
+func (s *authService) Unlock(ctx context.Context, userID string) error {
+	user, err := s.userService.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.repo.Unlock(ctx, user.ID)
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purposes of the tokens sent by email
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+	PurposeInvitation        = "invitation"
+	// Interim tokens of a login waiting for the second factor
+	PurposeTwoFactor      = "two_factor"
+	PurposeTwoFactorSetup = "two_factor_setup"
+	// One-time code that hands a single sign-on over to the web app
+	PurposeLoginCode = "login_code"
+)
+
+// How long the links sent by email are valid
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 48 * time.Hour
+	invitationTTL        = 7 * 24 * time.Hour
+	twoFactorTTL         = 5 * time.Minute
+	twoFactorSetupTTL    = 15 * time.Minute
+	loginCodeTTL         = time.Minute
+)
+
+// maxTokenAttempts is how many wrong codes an interim token survives
+const maxTokenAttempts = 5
+
+// Token is a single-use token sent by email. Only its hash is stored.
+type Token struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Purpose   string
+	Hash      string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// Recipient is the user an email is sent to
+type Recipient struct {
+	UserID   uuid.UUID
+	Email    string
+	Username string
+	Customer string
+	Language string
+}
+
+// Session is a login of a user. Access tokens carry its ID and the refresh
+// token, of which only the hash is stored, renews them.
+type Session struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	UserAgent  string
+	IP         string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// Client describes where a login comes from
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// Tokens are the access and refresh tokens of a session
+type Tokens struct {
+	AccessToken   string
+	Expire        time.Time
+	RefreshToken  string
+	RefreshExpire time.Time
+}
+
+// randomValue returns a random URL-safe value for a token
+func randomValue() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// newToken returns a random token for the user and the value to put in the link
+func newToken(userID uuid.UUID, purpose string, ttl time.Duration) (Token, string, error) {
+	value, err := randomValue()
+	if err != nil {
+		return Token{}, "", err
+	}
+	now := time.Now()
+	return Token{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		Hash:      hashToken(value),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, value, nil
+}
+
+func hashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// TwoFactor is the two-factor state of a user
+type TwoFactor struct {
+	Secret            string     `json:"-"`
+	EnabledAt         *time.Time `json:"enabled_at"`
+	Enabled           bool       `json:"enabled"`
+	Required          bool       `json:"required"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+}
+
+// Challenge is returned by a login that still needs the second factor.
+// Setup is set when the tenant requires 2FA and the user must enroll first.
+type Challenge struct {
+	Token     string
+	Setup     bool
+	ExpiresAt time.Time
+}
+
+// Enrollment is the secret to add to an authenticator app
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
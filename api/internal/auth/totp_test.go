@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 secret of the test vectors of RFC 6238
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// TestTOTPCode checks the codes against the SHA-1 vectors of RFC 6238,
+// truncated to the 6 digits of the authenticator apps
+func TestTOTPCode(t *testing.T) {
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		code, err := totpCode(rfcSecret, v.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("code at %d: %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("code at %d = %s, want %s", v.unix, code, v.code)
+		}
+	}
+
+	if _, err := totpCode("not base32!", 1); err == nil {
+		t.Error("invalid secret was accepted")
+	}
+}
+
+// TestMatchTOTPSkew makes sure the codes of one period before and after now
+// are accepted and the ones further away are not
+func TestMatchTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	for offset := int64(-3); offset <= 3; offset++ {
+		code, err := totpCode(rfcSecret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := matchTOTP(rfcSecret, code, now)
+		accepted := offset >= -totpSkew && offset <= totpSkew
+		if ok != accepted {
+			t.Errorf("code %d steps from now accepted = %v, want %v", offset, ok, accepted)
+		}
+		if ok && step != current+offset {
+			t.Errorf("code %d steps from now matched step %d, want %d", offset, step, current+offset)
+		}
+	}
+
+	code, _ := totpCode(rfcSecret, current)
+	if _, ok := matchTOTP(rfcSecret, " "+code+"\n", now); !ok {
+		t.Error("code with surrounding spaces was refused")
+	}
+	for _, code := range []string{"", "12345", "1234567", code[:5]} {
+		if _, ok := matchTOTP(rfcSecret, code, now); ok {
+			t.Errorf("code %q was accepted", code)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package auth
+
+import (
+	"api/internal/users"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// challenge issues the interim token of a login that still needs the second
+// factor, or of one that must enroll first when the tenant requires 2FA
+func (s *authService) challenge(ctx context.Context, userID uuid.UUID, setup bool) (Challenge, error) {
+	purpose, ttl := PurposeTwoFactor, twoFactorTTL
+	if setup {
+		purpose, ttl = PurposeTwoFactorSetup, twoFactorSetupTTL
+	}
+	token, value, err := newToken(userID, purpose, ttl)
+	if err != nil {
+		return Challenge{}, err
+	}
+	if err := s.repo.CreateToken(ctx, token); err != nil {
+		return Challenge{}, err
+	}
+	return Challenge{Token: value, Setup: setup, ExpiresAt: token.ExpiresAt}, nil
+}
+
+// interimUser returns the user of a valid interim token
+func (s *authService) interimUser(ctx context.Context, purpose string, value string) (users.User, error) {
+	userID, err := s.repo.FindToken(ctx, purpose, hashToken(value))
+	if errors.Is(err, sql.ErrNoRows) {
+		return users.User{}, ErrInvalidToken
+	}
+	if err != nil {
+		return users.User{}, err
+	}
+	user, err := s.userService.Lookup(ctx, userID)
+	if err != nil {
+		return users.User{}, err
+	}
+	if !user.IsActive {
+		return users.User{}, ErrInactiveUser
+	}
+	return user, nil
+}
+
+// checkCode accepts a TOTP code, each time step once, or an unused recovery code
+func (s *authService) checkCode(ctx context.Context, userID uuid.UUID, twoFactor TwoFactor, code string) error {
+	if step, ok := matchTOTP(twoFactor.Secret, code, time.Now()); ok {
+		fresh, err := s.repo.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if fresh {
+			return nil
+		}
+		return ErrInvalidCode
+	}
+	used, err := s.repo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// failCode uses up an attempt of the interim token and counts a failed login
+// of the account, so guessing codes locks it like guessing passwords
+func (s *authService) failCode(ctx context.Context, userID uuid.UUID, token string) error {
+	if err := s.repo.FailToken(ctx, hashToken(token)); err != nil {
+		return err
+	}
+	return s.failLogin(ctx, userID)
+}
+
+// verifyCode checks the code a logged in user confirms a 2FA change with.
+// Wrong codes count as failed logins, so the lockout stops guessing codes
+// with a stolen session as it does at the login.
+func (s *authService) verifyCode(ctx context.Context, userID uuid.UUID, twoFactor TwoFactor, code string) error {
+	if err := s.checkLocked(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.checkCode(ctx, userID, twoFactor, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			if err := s.failLogin(ctx, userID); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	return s.repo.Unlock(ctx, userID)
+}
+
+// VerifyTwoFactor completes a login with the code of the authenticator app
+// or a recovery code. Wrong codes use up the attempts of the interim token
+// and count as failed logins of the account.
+func (s *authService) VerifyTwoFactor(ctx context.Context, req TwoFactorRequest, client Client) (Tokens, users.User, error) {
+	user, err := s.interimUser(ctx, PurposeTwoFactor, req.Token)
+	if err != nil {
+		return Tokens{}, users.User{}, err
+	}
+	if err := s.checkLocked(ctx, user.ID); err != nil {
+		s.recordLogin(ctx, user.Email, user, client, err)
+		return Tokens{}, users.User{}, err
+	}
+	twoFactor, err := s.repo.FindTwoFactor(ctx, user.ID)
+	if err != nil {
+		return Tokens{}, users.User{}, err
+	}
+	if !twoFactor.Enabled {
+		return Tokens{}, users.User{}, ErrTwoFactorNotEnabled
+	}
+	if err := s.checkCode(ctx, user.ID, twoFactor, req.Code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			s.recordLogin(ctx, user.Email, user, client, err)
+			if err := s.failCode(ctx, user.ID, req.Token); err != nil {
+				return Tokens{}, users.User{}, err
+			}
+		}
+		return Tokens{}, users.User{}, err
+	}
+	if _, err := s.consume(ctx, PurposeTwoFactor, req.Token); err != nil {
+		return Tokens{}, users.User{}, err
+	}
+	if err := s.repo.Unlock(ctx, user.ID); err != nil {
+		return Tokens{}, users.User{}, err
+	}
+	tokens, err := s.startSession(ctx, user, client)
+	if err != nil {
+		return Tokens{}, users.User{}, err
+	}
+	s.recordLogin(ctx, user.Email, user, client, nil)
+	user.Password = ""
+	return tokens, user, nil
+}
+
+// StartEnrollment begins the enrollment of a user who must set up 2FA
+// before their first login completes
+func (s *authService) StartEnrollment(ctx context.Context, req TokenRequest) (Enrollment, error) {
+	user, err := s.interimUser(ctx, PurposeTwoFactorSetup, req.Token)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	return s.enroll(ctx, user)
+}
+
+// FinishEnrollment enables 2FA with the first code of the app and completes
+// the login. The recovery codes are only returned here.
+func (s *authService) FinishEnrollment(ctx context.Context, req TwoFactorRequest, client Client) (Tokens, users.User, []string, error) {
+	user, err := s.interimUser(ctx, PurposeTwoFactorSetup, req.Token)
+	if err != nil {
+		return Tokens{}, users.User{}, nil, err
+	}
+	if err := s.checkLocked(ctx, user.ID); err != nil {
+		s.recordLogin(ctx, user.Email, user, client, err)
+		return Tokens{}, users.User{}, nil, err
+	}
+	codes, err := s.confirm(ctx, user.ID, req.Code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			s.recordLogin(ctx, user.Email, user, client, err)
+			if err := s.failCode(ctx, user.ID, req.Token); err != nil {
+				return Tokens{}, users.User{}, nil, err
+			}
+		}
+		return Tokens{}, users.User{}, nil, err
+	}
+	if _, err := s.consume(ctx, PurposeTwoFactorSetup, req.Token); err != nil {
+		return Tokens{}, users.User{}, nil, err
+	}
+	if err := s.repo.Unlock(ctx, user.ID); err != nil {
+		return Tokens{}, users.User{}, nil, err
+	}
+	tokens, err := s.startSession(ctx, user, client)
+	if err != nil {
+		return Tokens{}, users.User{}, nil, err
+	}
+	s.recordLogin(ctx, user.Email, user, client, nil)
+	user.Password = ""
+	return tokens, user, codes, nil
+}
+
+// enroll stores a new secret for the user. It only takes effect once a code
+// of it is confirmed.
+func (s *authService) enroll(ctx context.Context, user users.User) (Enrollment, error) {
+	twoFactor, err := s.repo.FindTwoFactor(ctx, user.ID)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	if twoFactor.Enabled {
+		return Enrollment{}, ErrTwoFactorEnabled
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return Enrollment{}, err
+	}
+	if err := s.repo.SetTOTPSecret(ctx, user.ID, secret); err != nil {
+		return Enrollment{}, err
+	}
+	return Enrollment{Secret: secret, URI: provisioningURI(secret, user.Email)}, nil
+}
+
+// confirm enables 2FA when the code matches the pending secret and returns
+// new recovery codes
+func (s *authService) confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	twoFactor, err := s.repo.FindTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if twoFactor.Secret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	step, ok := matchTOTP(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, hashes, err := recoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.EnableTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// recoveryCodes returns new recovery codes and their hashes
+func recoveryCodes() ([]string, []string, error) {
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// currentUser returns the logged in user by the ID of the access token
+func (s *authService) currentUser(ctx context.Context, userID string) (users.User, error) {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return users.User{}, err
+	}
+	return s.userService.Lookup(ctx, parsedUserID)
+}
+
+func (s *authService) TwoFactorStatus(ctx context.Context, userID string) (TwoFactor, error) {
+	user, err := s.currentUser(ctx, userID)
+	if err != nil {
+		return TwoFactor{}, err
+	}
+	return s.repo.FindTwoFactor(ctx, user.ID)
+}
+
+func (s *authService) Enroll(ctx context.Context, userID string) (Enrollment, error) {
+	user, err := s.currentUser(ctx, userID)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	return s.enroll(ctx, user)
+}
+
+func (s *authService) ConfirmEnrollment(ctx context.Context, userID string, req CodeRequest) ([]string, error) {
+	user, err := s.currentUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.confirm(ctx, user.ID, req.Code)
+}
+
+// DisableTwoFactor turns 2FA off after checking a code. Users of tenants
+// that require 2FA can't turn it off.
+func (s *authService) DisableTwoFactor(ctx context.Context, userID string, req CodeRequest) error {
+	user, err := s.currentUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	twoFactor, err := s.repo.FindTwoFactor(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if !twoFactor.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if twoFactor.Required {
+		return ErrTwoFactorRequired
+	}
+	if err := s.verifyCode(ctx, user.ID, twoFactor, req.Code); err != nil {
+		return err
+	}
+	return s.repo.DisableTOTP(ctx, user.ID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a code
+func (s *authService) RegenerateRecoveryCodes(ctx context.Context, userID string, req CodeRequest) ([]string, error) {
+	user, err := s.currentUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	twoFactor, err := s.repo.FindTwoFactor(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !twoFactor.Enabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := s.verifyCode(ctx, user.ID, twoFactor, req.Code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := recoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetTwoFactor turns 2FA off for a user of the tenant who lost their
+// device and recovery codes. They enroll again on their next login if the
+// tenant requires it.
+func (s *authService) ResetTwoFactor(ctx context.Context, userID string) error {
+	user, err := s.userService.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.repo.DisableTOTP(ctx, user.ID)
+}
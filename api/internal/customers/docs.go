@@ -0,0 +1,13 @@
+package customers
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/customers", Tag: "customers", Summary: "Create a customer", Request: CustomerRequest{}, Response: Customer{}, Status: 201},
+	{Method: "GET", Path: "/api/customers", Tag: "customers", Summary: "List customers", Response: []Customer{}, List: &listSpec},
+	{Method: "GET", Path: "/api/customers/:id", Tag: "customers", Summary: "Get a customer", Response: Customer{}},
+	{Method: "PUT", Path: "/api/customers/:id", Tag: "customers", Summary: "Update a customer", Request: CustomerRequest{}, Response: Customer{}},
+	{Method: "PUT", Path: "/api/customers/:id/two-factor", Tag: "customers", Summary: "Require two-factor authentication of the users of a customer", Request: TwoFactorRequest{}, Response: Customer{}},
+	{Method: "DELETE", Path: "/api/customers/:id", Tag: "customers", Summary: "Delete a customer"},
+}
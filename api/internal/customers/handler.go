@@ -1,6 +1,9 @@
 package customers
 
 import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
 	"api/middleware"
 	"net/http"
 
@@ -17,18 +20,18 @@ func NewHandler(service Service) Handler {
 
 func (h *Handler) Create(c *gin.Context) {
 	if !middleware.IsAdmin(c) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		c.Error(apperr.Forbidden("forbidden", "Forbidden"))
 		return
 	}
 	ctx := c.Request.Context()
 	var request CustomerRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Create(ctx, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"message": "Customer created successfully", "data": response})
@@ -36,34 +39,34 @@ func (h *Handler) Create(c *gin.Context) {
 
 func (h *Handler) FindAll(c *gin.Context) {
 	if !middleware.IsAdmin(c) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		c.Error(apperr.Forbidden("forbidden", "Forbidden"))
 		return
 	}
 	ctx := c.Request.Context()
-	response, err := h.service.FindAll(ctx)
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Customers found successfully", "data": response})
+	response, total, err := h.service.List(ctx, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Customers found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindByID(c *gin.Context) {
 	id := c.Param("id")
-	
-	// Access Control: Admin OR Own Customer
-	if !middleware.IsAdmin(c) {
-		currentUserCustomerID := middleware.GetCustomerID(c)
-		if currentUserCustomerID != id {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
-			return
-		}
-	}
-
+	// Users other than admins only find their own customer
 	ctx := c.Request.Context()
 	response, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("customer_not_found", "Customer not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Customer found successfully", "data": response})
@@ -71,19 +74,23 @@ func (h *Handler) FindByID(c *gin.Context) {
 
 func (h *Handler) Update(c *gin.Context) {
 	if !middleware.IsAdmin(c) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		c.Error(apperr.Forbidden("forbidden", "Forbidden"))
 		return
 	}
 	ctx := c.Request.Context()
 	id := c.Param("id")
 	var request CustomerRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Update(ctx, id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("customer_not_found", "Customer not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Customer updated successfully", "data": response})
@@ -91,15 +98,43 @@ func (h *Handler) Update(c *gin.Context) {
 
 func (h *Handler) Delete(c *gin.Context) {
 	if !middleware.IsAdmin(c) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		c.Error(apperr.Forbidden("forbidden", "Forbidden"))
 		return
 	}
 	ctx := c.Request.Context()
 	id := c.Param("id")
 	err := h.service.Delete(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("customer_not_found", "Customer not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Customer deleted successfully"})
 }
+
+// SetTwoFactor makes two-factor authentication mandatory for the tenant
+func (h *Handler) SetTwoFactor(c *gin.Context) {
+	if !middleware.IsAdmin(c) {
+		c.Error(apperr.Forbidden("forbidden", "Forbidden"))
+		return
+	}
+	ctx := c.Request.Context()
+	var request TwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.SetTwoFactorRequired(ctx, c.Param("id"), request)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("customer_not_found", "Customer not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor requirement updated successfully", "data": response})
+}
@@ -30,13 +30,15 @@ type Customer struct {
 	MaxShopFloors int `json:"max_shop_floors"`
 	MaxUsers int `json:"max_users"`
 	MaxJobs int `json:"max_jobs"`
+	// RequireTwoFactor makes every user of the tenant enroll in TOTP
+	RequireTwoFactor bool `json:"require_two_factor"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
 type CustomerRequest struct {
-	Name          string     `json:"name"`
-	Email         string     `json:"email"`
+	Name          string     `json:"name" binding:"required,max=200"`
+	Email         string     `json:"email" binding:"omitempty,email"`
 	VatNumber     string     `json:"vat_number"`
 	Phone         string     `json:"phone"`
 	Address       string     `json:"address"`
@@ -46,15 +48,19 @@ type CustomerRequest struct {
 	Country       string     `json:"country"`
 	Language      string     `json:"language"`
 	ContactName   string     `json:"contact_name"`
-	Status        string     `json:"status"`
+	Status        string     `json:"status" binding:"omitempty,oneof=active inactive suspended"`
 	Plan          string     `json:"plan"`           
-	BillingCycle  string     `json:"billing_cycle"`  
-	Price         float64    `json:"price"`          
+	BillingCycle  string     `json:"billing_cycle" binding:"omitempty,oneof=monthly yearly"`  
+	Price         float64    `json:"price" binding:"gte=0"`          
 	TrialEndsAt   *time.Time `json:"trial_ends_at"`
 	InternalNotes string     `json:"internal_notes"`
-	MaxOperators int `json:"max_operators"`
-	MaxWorkcenters int `json:"max_workcenters"`
-	MaxShopFloors int `json:"max_shop_floors"`
-	MaxUsers int `json:"max_users"`
-	MaxJobs int `json:"max_jobs"`
-}
\ No newline at end of file
+	MaxOperators int `json:"max_operators" binding:"gte=0"`
+	MaxWorkcenters int `json:"max_workcenters" binding:"gte=0"`
+	MaxShopFloors int `json:"max_shop_floors" binding:"gte=0"`
+	MaxUsers int `json:"max_users" binding:"gte=0"`
+	MaxJobs int `json:"max_jobs" binding:"gte=0"`
+}
+
+type TwoFactorRequest struct {
+	Required bool `json:"required"`
+}
@@ -1,18 +1,42 @@
 package customers
 
 import (
+	"api/internal/listing"
 	"context"
 	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+// listSpec is what the customer list can be sorted, filtered and searched on
+var listSpec = listing.Spec{
+	Sort: map[string]string{
+		"name":          "name",
+		"email":         "email",
+		"city":          "city",
+		"country":       "country",
+		"status":        "status",
+		"plan":          "plan",
+		"trial_ends_at": "trial_ends_at",
+		"created_at":    "created_at",
+	},
+	Filter: map[string]string{
+		"status":        "status",
+		"plan":          "plan",
+		"billing_cycle": "billing_cycle",
+		"country":       "country",
+	},
+	Search:  []string{"name", "email", "vat_number", "contact_name", "city"},
+	Default: []listing.Order{{Field: "name"}},
+}
+
 type Repository interface {
 	Create(ctx context.Context, customer Customer) (Customer, error)
-	FindAll(ctx context.Context) ([]Customer, error)
+	List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Customer, int, error)
 	FindByID(ctx context.Context, id uuid.UUID) (Customer, error)
 	Update(ctx context.Context, customer Customer) (Customer, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	SetTwoFactorRequired(ctx context.Context, id uuid.UUID, required bool) error
 }
 
 type repository struct {
@@ -39,22 +63,20 @@ func (r *repository) Create(ctx context.Context, customer Customer) (Customer, e
 	return customer, nil
 }
 
-func (r *repository) FindAll(ctx context.Context) ([]Customer, error) {
-	query := `SELECT id, name, email, vat_number, 
+// List returns a page of the customers, only customerID when it isn't nil
+func (r *repository) List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Customer, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	if customerID != nil {
+		q.Where("id = ?", *customerID)
+	}
+	return listing.Find(ctx, r.db, q, `SELECT id, name, email, vat_number, 
 					phone, address, city, state, 
 					zip_code, country, language, 
 					contact_name, status, plan, 
 					billing_cycle, price, trial_ends_at, 
 					internal_notes, max_operators, 
 					max_workcenters, max_shop_floors, max_users, 
-					max_jobs, created_at, updated_at FROM customers`
-	rows, err := r.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	customers := []Customer{}
-	for rows.Next() {
+					max_jobs, require_two_factor, created_at, updated_at FROM customers`, func(rows *sql.Rows) (Customer, error) {
 		var customer Customer
 		err := rows.Scan(&customer.ID, &customer.Name, &customer.Email, &customer.VatNumber, 
 						&customer.Phone, &customer.Address, &customer.City, &customer.State,
@@ -63,13 +85,9 @@ func (r *repository) FindAll(ctx context.Context) ([]Customer, error) {
 						&customer.BillingCycle, &customer.Price, &customer.TrialEndsAt, 
 						&customer.InternalNotes, &customer.MaxOperators, 
 						&customer.MaxWorkcenters, &customer.MaxShopFloors, &customer.MaxUsers, 
-						&customer.MaxJobs, &customer.CreatedAt, &customer.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		customers = append(customers, customer)
-	}
-	return customers, nil
+						&customer.MaxJobs, &customer.RequireTwoFactor, &customer.CreatedAt, &customer.UpdatedAt)
+		return customer, err
+	})
 }
 
 func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (Customer, error) {
@@ -80,7 +98,7 @@ func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (Customer, erro
 					billing_cycle, price, trial_ends_at, 
 					internal_notes, max_operators, 
 					max_workcenters, max_shop_floors, max_users, 
-					max_jobs, created_at, updated_at FROM customers WHERE id = $1`
+					max_jobs, require_two_factor, created_at, updated_at FROM customers WHERE id = $1`
 	row := r.db.QueryRowContext(ctx, query, id)
 	var customer Customer
 	err := row.Scan(&customer.ID, &customer.Name, &customer.Email, &customer.VatNumber, 
@@ -90,7 +108,7 @@ func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (Customer, erro
 						&customer.BillingCycle, &customer.Price, &customer.TrialEndsAt, 
 						&customer.InternalNotes, &customer.MaxOperators, 
 						&customer.MaxWorkcenters, &customer.MaxShopFloors, &customer.MaxUsers, 
-						&customer.MaxJobs, &customer.CreatedAt, &customer.UpdatedAt)
+						&customer.MaxJobs, &customer.RequireTwoFactor, &customer.CreatedAt, &customer.UpdatedAt)
 	if err != nil {
 		return Customer{}, err
 	}
@@ -121,4 +139,10 @@ func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
 		return err
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+func (r *repository) SetTwoFactorRequired(ctx context.Context, id uuid.UUID, required bool) error {
+	query := `UPDATE customers SET require_two_factor = $2, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, required)
+	return err
+}
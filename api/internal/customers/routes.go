@@ -8,4 +8,5 @@ func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.GET("/customers/:id", handler.FindByID)
 	router.PUT("/customers/:id", handler.Update)
 	router.DELETE("/customers/:id", handler.Delete)
+	router.PUT("/customers/:id/two-factor", handler.SetTwoFactor)
 }
\ No newline at end of file
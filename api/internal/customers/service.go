@@ -1,6 +1,9 @@
 package customers
 
 import (
+	"api/internal/audit"
+	"api/internal/listing"
+	"api/internal/tenant"
 	"context"
 	"time"
 
@@ -9,18 +12,21 @@ import (
 
 type Service interface {
 	Create(ctx context.Context, request CustomerRequest) (Customer, error)
-	FindAll(ctx context.Context) ([]Customer, error)
+	List(ctx context.Context, params listing.Params) ([]Customer, int, error)
 	FindByID(ctx context.Context, id string) (Customer, error)
+	Lookup(ctx context.Context, id uuid.UUID) (Customer, error)
 	Update(ctx context.Context, id string, request CustomerRequest) (Customer, error)
 	Delete(ctx context.Context, id string) error
+	SetTwoFactorRequired(ctx context.Context, id string, request TwoFactorRequest) (Customer, error)
 }
 
 type service struct {
 	repository Repository
+	audit      audit.Recorder
 }
 
-func NewService(repository Repository) Service {
-	return &service{repository: repository}
+func NewService(repository Repository, recorder audit.Recorder) Service {
+	return &service{repository: repository, audit: recorder}
 }
 
 func (s *service) Create(ctx context.Context, request CustomerRequest) (Customer, error) {
@@ -51,11 +57,21 @@ func (s *service) Create(ctx context.Context, request CustomerRequest) (Customer
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
-	return s.repository.Create(ctx, customer)
+	created, err := s.repository.Create(ctx, customer)
+	if err != nil {
+		return Customer{}, err
+	}
+	s.audit.Created(ctx, audit.EntityCustomer, created.ID, created.ID, created)
+	return created, nil
 }
 
-func (s *service) FindAll(ctx context.Context) ([]Customer, error) {
-	return s.repository.FindAll(ctx)
+// List returns a page of every customer to admins and only their own to tenants
+func (s *service) List(ctx context.Context, params listing.Params) ([]Customer, int, error) {
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repository.List(ctx, scope, params)
 }
 
 func (s *service) FindByID(ctx context.Context, id string) (Customer, error) {
@@ -63,18 +79,24 @@ func (s *service) FindByID(ctx context.Context, id string) (Customer, error) {
 	if err != nil {
 		return Customer{}, err
 	}
+	if err := tenant.Check(ctx, parsedId); err != nil {
+		return Customer{}, err
+	}
 	return s.repository.FindByID(ctx, parsedId)
 }
 
+// Lookup returns the customer without checking the tenant of the current
+// user. It is meant for flows without a logged in user, such as registration.
+func (s *service) Lookup(ctx context.Context, id uuid.UUID) (Customer, error) {
+	return s.repository.FindByID(ctx, id)
+}
+
 func (s *service) Update(ctx context.Context, id string, request CustomerRequest) (Customer, error) {
-	parsedId, err := uuid.Parse(id)
-	if err != nil {
-		return Customer{}, err
-	}
-	customer, err := s.repository.FindByID(ctx, parsedId)
+	customer, err := s.FindByID(ctx, id)
 	if err != nil {
 		return Customer{}, err
 	}
+	before := customer
 	customer.Name = request.Name
 	customer.Email = request.Email
 	customer.VatNumber = request.VatNumber
@@ -98,13 +120,37 @@ func (s *service) Update(ctx context.Context, id string, request CustomerRequest
 	customer.MaxUsers = request.MaxUsers
 	customer.MaxJobs = request.MaxJobs
 	customer.UpdatedAt = time.Now()
-	return s.repository.Update(ctx, customer)
+	updated, err := s.repository.Update(ctx, customer)
+	if err != nil {
+		return Customer{}, err
+	}
+	s.audit.Updated(ctx, audit.EntityCustomer, updated.ID, updated.ID, before, updated)
+	return updated, nil
 }
 
 func (s *service) Delete(ctx context.Context, id string) error {
-	parsedId, err := uuid.Parse(id)
+	customer, err := s.FindByID(ctx, id)
 	if err != nil {
 		return err
 	}
-	return s.repository.Delete(ctx, parsedId)
+	if err := s.repository.Delete(ctx, customer.ID); err != nil {
+		return err
+	}
+	s.audit.Deleted(ctx, audit.EntityCustomer, customer.ID, customer.ID, customer)
+	return nil
+}
+
+// SetTwoFactorRequired turns the two-factor requirement of the tenant on or off
+func (s *service) SetTwoFactorRequired(ctx context.Context, id string, request TwoFactorRequest) (Customer, error) {
+	customer, err := s.FindByID(ctx, id)
+	if err != nil {
+		return Customer{}, err
+	}
+	if err := s.repository.SetTwoFactorRequired(ctx, customer.ID, request.Required); err != nil {
+		return Customer{}, err
+	}
+	before := customer
+	customer.RequireTwoFactor = request.Required
+	s.audit.Updated(ctx, audit.EntityCustomer, customer.ID, customer.ID, before, customer)
+	return customer, nil
 }
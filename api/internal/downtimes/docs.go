@@ -0,0 +1,31 @@
+package downtimes
+
+import (
+	"api/internal/openapi"
+	"fmt"
+)
+
+// filterParams are the query params of filterFromQuery
+var filterParams = []openapi.Param{
+	openapi.CustomerID,
+	{Name: "workcenter_id"},
+	{Name: "reason_id"},
+	{Name: "kind", Description: "planned or unplanned"},
+	{Name: "from", Description: "YYYY-MM-DD or RFC 3339"},
+	{Name: "to", Description: "YYYY-MM-DD or RFC 3339"},
+}
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/downtime-reasons", Tag: "downtimes", Summary: "Create a downtime reason", Request: ReasonRequest{}, Response: Reason{}, Status: 201},
+	{Method: "GET", Path: "/api/downtime-reasons", Tag: "downtimes", Summary: "List the downtime reasons", Response: []Reason{}, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "PUT", Path: "/api/downtime-reasons/:id", Tag: "downtimes", Summary: "Update a downtime reason", Request: ReasonRequest{}, Response: Reason{}},
+	{Method: "DELETE", Path: "/api/downtime-reasons/:id", Tag: "downtimes", Summary: "Delete a downtime reason"},
+
+	{Method: "GET", Path: "/api/downtimes/report", Tag: "downtimes", Summary: fmt.Sprintf("Downtime per reason, over the last %d days by default", DefaultReportDays), Response: Report{}, Query: filterParams},
+	{Method: "POST", Path: "/api/downtimes", Tag: "downtimes", Summary: "Record a downtime", Request: DowntimeRequest{}, Response: Downtime{}, Status: 201},
+	{Method: "GET", Path: "/api/downtimes", Tag: "downtimes", Summary: "List downtimes", Response: []Downtime{}, List: &listSpec, Query: filterParams},
+	{Method: "GET", Path: "/api/downtimes/:id", Tag: "downtimes", Summary: "Get a downtime", Response: Downtime{}},
+	{Method: "PUT", Path: "/api/downtimes/:id", Tag: "downtimes", Summary: "Update a downtime", Request: DowntimeRequest{}, Response: Downtime{}},
+	{Method: "DELETE", Path: "/api/downtimes/:id", Tag: "downtimes", Summary: "Delete a downtime"},
+}
@@ -0,0 +1,227 @@
+package downtimes
+
+import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+// DefaultReportDays is the period covered by the report when no range is given
+const DefaultReportDays = 7
+
+func (h *Handler) CreateReason(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request ReasonRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.CreateReason(ctx, request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Downtime reason created successfully", "data": response})
+}
+
+func (h *Handler) FindReasons(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.FindReasons(ctx, c.Query("customer_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Downtime reasons found successfully", "data": response})
+}
+
+func (h *Handler) UpdateReason(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request ReasonRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.UpdateReason(ctx, c.Param("id"), request)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("downtime_reason_not_found", "Downtime reason not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Downtime reason updated successfully", "data": response})
+}
+
+func (h *Handler) DeleteReason(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.DeleteReason(ctx, c.Param("id")); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("downtime_reason_not_found", "Downtime reason not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Downtime reason deleted successfully"})
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request DowntimeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.Create(ctx, request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Downtime created successfully", "data": response})
+}
+
+func (h *Handler) FindByID(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.FindByID(ctx, c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("downtime_not_found", "Downtime not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Downtime found successfully", "data": response})
+}
+
+func (h *Handler) Search(c *gin.Context) {
+	ctx := c.Request.Context()
+	filter, err := filterFromQuery(c)
+	if err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	params, err := listing.Parse(c, listSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.Search(ctx, filter, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Downtimes found successfully", "data": response, "meta": params.Page(total)})
+}
+
+func (h *Handler) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request DowntimeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.Update(ctx, c.Param("id"), request)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("downtime_not_found", "Downtime not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Downtime updated successfully", "data": response})
+}
+
+func (h *Handler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.Delete(ctx, c.Param("id")); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("downtime_not_found", "Downtime not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Downtime deleted successfully"})
+}
+
+// Report aggregates downtime per reason. Accepts the same filters as Search;
+// the range defaults to the last DefaultReportDays days.
+func (h *Handler) Report(c *gin.Context) {
+	ctx := c.Request.Context()
+	filter, err := filterFromQuery(c)
+	if err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	to := time.Now()
+	if filter.To != nil {
+		to = *filter.To
+	}
+	from := to.AddDate(0, 0, -DefaultReportDays)
+	if filter.From != nil {
+		from = *filter.From
+	}
+	response, err := h.service.Report(ctx, filter, from, to)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Downtime report generated successfully", "data": response})
+}
+
+func filterFromQuery(c *gin.Context) (DowntimeFilter, error) {
+	var filter DowntimeFilter
+	for param, target := range map[string]**uuid.UUID{
+		"customer_id":   &filter.CustomerID,
+		"workcenter_id": &filter.WorkcenterID,
+		"reason_id":     &filter.ReasonID,
+	} {
+		if v := c.Query(param); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return DowntimeFilter{}, fmt.Errorf("invalid %s", param)
+			}
+			*target = &id
+		}
+	}
+	if v := c.Query("kind"); v != "" {
+		filter.Kind = &v
+	}
+	for param, target := range map[string]**time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	} {
+		if v := c.Query(param); v != "" {
+			t, err := parseTime(v)
+			if err != nil {
+				return DowntimeFilter{}, fmt.Errorf("invalid %s, expected YYYY-MM-DD or RFC3339", param)
+			}
+			*target = &t
+		}
+	}
+	return filter, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
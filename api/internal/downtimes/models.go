@@ -0,0 +1,90 @@
+package downtimes
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Downtime kinds
+const (
+	KindPlanned   = "planned"
+	KindUnplanned = "unplanned"
+)
+
+// Downtime sources
+const (
+	SourceManual  = "manual"
+	SourceMachine = "machine" // opened and closed by machine signals
+)
+
+type Reason struct {
+	ID         uuid.UUID `json:"id"`
+	CustomerID uuid.UUID `json:"customer_id"`
+	Code       string    `json:"code"`
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"`
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type ReasonRequest struct {
+	CustomerID string `json:"customer_id" binding:"omitempty,uuid"`
+	Code       string `json:"code" binding:"required,max=50"`
+	Name       string `json:"name" binding:"required,max=200"`
+	Kind       string `json:"kind"`
+	IsActive   bool   `json:"is_active"`
+}
+
+// Downtime is an interval in which a workcenter is not available.
+// EndTime is nil while the workcenter is still down.
+type Downtime struct {
+	ID           uuid.UUID     `json:"id"`
+	CustomerID   uuid.UUID     `json:"customer_id"`
+	WorkcenterID uuid.UUID     `json:"workcenter_id"`
+	ReasonID     uuid.NullUUID `json:"reason_id"`
+	Kind         string        `json:"kind"`
+	StartTime    time.Time     `json:"start_time"`
+	EndTime      *time.Time    `json:"end_time"`
+	Notes        string        `json:"notes"`
+	Source       string        `json:"source"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+type DowntimeRequest struct {
+	CustomerID   string     `json:"customer_id" binding:"omitempty,uuid"`
+	WorkcenterID string     `json:"workcenter_id" binding:"required,uuid"`
+	ReasonID     string     `json:"reason_id" binding:"omitempty,uuid"`
+	Kind         string     `json:"kind"`
+	StartTime    time.Time  `json:"start_time" binding:"required"`
+	EndTime      *time.Time `json:"end_time" binding:"omitempty,gtfield=StartTime"`
+	Notes        string     `json:"notes"`
+}
+
+type DowntimeFilter struct {
+	CustomerID   *uuid.UUID
+	WorkcenterID *uuid.UUID
+	ReasonID     *uuid.UUID
+	Kind         *string
+	From         *time.Time
+	To           *time.Time
+}
+
+// ReasonSummary aggregates the downtime of one reason in a period
+type ReasonSummary struct {
+	ReasonID     uuid.NullUUID `json:"reason_id"`
+	Code         string        `json:"code"`
+	Name         string        `json:"name"`
+	Kind         string        `json:"kind"`
+	Occurrences  int           `json:"occurrences"`
+	TotalMinutes int           `json:"total_minutes"`
+}
+
+type Report struct {
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	Reasons      []ReasonSummary `json:"reasons"`
+	TotalMinutes int             `json:"total_minutes"`
+}
@@ -0,0 +1,252 @@
+package downtimes
+
+import (
+	"api/internal/listing"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// listSpec is what the downtime list can be sorted and searched on, besides
+// the filters of DowntimeFilter
+var listSpec = listing.Spec{
+	Sort: map[string]string{
+		"start_time": "start_time",
+		"end_time":   "end_time",
+		"kind":       "kind",
+		"source":     "source",
+		"created_at": "created_at",
+	},
+	Filter: map[string]string{
+		"source": "source",
+	},
+	Search:  []string{"notes"},
+	Default: []listing.Order{{Field: "start_time"}},
+}
+
+type Repository interface {
+	CreateReason(ctx context.Context, reason Reason) (Reason, error)
+	FindReasonByID(ctx context.Context, id uuid.UUID) (Reason, error)
+	FindReasons(ctx context.Context, customerID *uuid.UUID) ([]Reason, error)
+	UpdateReason(ctx context.Context, reason Reason) (Reason, error)
+	DeleteReason(ctx context.Context, id uuid.UUID) error
+	Create(ctx context.Context, downtime Downtime) (Downtime, error)
+	FindByID(ctx context.Context, id uuid.UUID) (Downtime, error)
+	Search(ctx context.Context, filter DowntimeFilter, params listing.Params) ([]Downtime, int, error)
+	Update(ctx context.Context, downtime Downtime) (Downtime, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error)
+	SummarizeByReason(ctx context.Context, filter DowntimeFilter, from, to time.Time) ([]ReasonSummary, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) CreateReason(ctx context.Context, reason Reason) (Reason, error) {
+	query := `INSERT INTO downtime_reasons (id, customer_id, code, name, kind, is_active, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := r.db.ExecContext(ctx, query, reason.ID, reason.CustomerID, reason.Code, reason.Name, reason.Kind, reason.IsActive, reason.CreatedAt, reason.UpdatedAt)
+	if err != nil {
+		return Reason{}, err
+	}
+	return reason, nil
+}
+
+func (r *repository) FindReasonByID(ctx context.Context, id uuid.UUID) (Reason, error) {
+	query := `SELECT id, customer_id, code, name, kind, is_active, created_at, updated_at FROM downtime_reasons WHERE id = $1`
+	var reason Reason
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&reason.ID, &reason.CustomerID, &reason.Code, &reason.Name, &reason.Kind, &reason.IsActive, &reason.CreatedAt, &reason.UpdatedAt)
+	if err != nil {
+		return Reason{}, err
+	}
+	return reason, nil
+}
+
+func (r *repository) FindReasons(ctx context.Context, customerID *uuid.UUID) ([]Reason, error) {
+	query := `SELECT id, customer_id, code, name, kind, is_active, created_at, updated_at FROM downtime_reasons`
+	var args []interface{}
+	if customerID != nil {
+		query += " WHERE customer_id = $1"
+		args = append(args, *customerID)
+	}
+	query += " ORDER BY code"
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var reasons []Reason
+	for rows.Next() {
+		var reason Reason
+		if err := rows.Scan(&reason.ID, &reason.CustomerID, &reason.Code, &reason.Name, &reason.Kind, &reason.IsActive, &reason.CreatedAt, &reason.UpdatedAt); err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, reason)
+	}
+	return reasons, nil
+}
+
+func (r *repository) UpdateReason(ctx context.Context, reason Reason) (Reason, error) {
+	query := `UPDATE downtime_reasons SET code = $2, name = $3, kind = $4, is_active = $5, updated_at = $6 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, reason.ID, reason.Code, reason.Name, reason.Kind, reason.IsActive, reason.UpdatedAt)
+	if err != nil {
+		return Reason{}, err
+	}
+	return reason, nil
+}
+
+func (r *repository) DeleteReason(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM downtime_reasons WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *repository) Create(ctx context.Context, downtime Downtime) (Downtime, error) {
+	query := `INSERT INTO downtimes (id, customer_id, workcenter_id, reason_id, kind, start_time, end_time, notes, source, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := r.db.ExecContext(ctx, query, downtime.ID, downtime.CustomerID, downtime.WorkcenterID, downtime.ReasonID, downtime.Kind,
+		downtime.StartTime, downtime.EndTime, downtime.Notes, downtime.Source, downtime.CreatedAt, downtime.UpdatedAt)
+	if err != nil {
+		return Downtime{}, err
+	}
+	return downtime, nil
+}
+
+func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (Downtime, error) {
+	query := `SELECT id, customer_id, workcenter_id, reason_id, kind, start_time, end_time, notes, source, created_at, updated_at FROM downtimes WHERE id = $1`
+	var downtime Downtime
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&downtime.ID, &downtime.CustomerID, &downtime.WorkcenterID, &downtime.ReasonID, &downtime.Kind,
+		&downtime.StartTime, &downtime.EndTime, &downtime.Notes, &downtime.Source, &downtime.CreatedAt, &downtime.UpdatedAt)
+	if err != nil {
+		return Downtime{}, err
+	}
+	return downtime, nil
+}
+
+// Search returns a page of the downtimes matching the filter. From and To
+// select the downtimes that overlap the interval, not only the ones starting
+// in it.
+func (r *repository) Search(ctx context.Context, filter DowntimeFilter, params listing.Params) ([]Downtime, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	conditions, args := filterConditions(filter, "")
+	for i, condition := range conditions {
+		q.Where(condition, args[i])
+	}
+	return listing.Find(ctx, r.db, q, `SELECT id, customer_id, workcenter_id, reason_id, kind, start_time, end_time, notes, source, created_at, updated_at FROM downtimes`, func(rows *sql.Rows) (Downtime, error) {
+		var downtime Downtime
+		err := rows.Scan(&downtime.ID, &downtime.CustomerID, &downtime.WorkcenterID, &downtime.ReasonID, &downtime.Kind,
+			&downtime.StartTime, &downtime.EndTime, &downtime.Notes, &downtime.Source, &downtime.CreatedAt, &downtime.UpdatedAt)
+		return downtime, err
+	})
+}
+
+func (r *repository) Update(ctx context.Context, downtime Downtime) (Downtime, error) {
+	query := `UPDATE downtimes SET workcenter_id = $2, reason_id = $3, kind = $4, start_time = $5, end_time = $6, notes = $7, updated_at = $8 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, downtime.ID, downtime.WorkcenterID, downtime.ReasonID, downtime.Kind,
+		downtime.StartTime, downtime.EndTime, downtime.Notes, downtime.UpdatedAt)
+	if err != nil {
+		return Downtime{}, err
+	}
+	return downtime, nil
+}
+
+func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM downtimes WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *repository) FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error) {
+	query := `SELECT customer_id FROM workcenters WHERE id = $1 AND deleted_at IS NULL`
+	var customerID uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, workcenterID).Scan(&customerID); err != nil {
+		return uuid.Nil, err
+	}
+	return customerID, nil
+}
+
+// SummarizeByReason adds up the downtime per reason, clipping every interval
+// to [from, to]. Downtimes that are still open count until now.
+func (r *repository) SummarizeByReason(ctx context.Context, filter DowntimeFilter, from, to time.Time) ([]ReasonSummary, error) {
+	filter.From = &from
+	filter.To = &to
+	where, args := filterClause(filter, "d.")
+	fromArg := len(args) + 1
+	toArg := len(args) + 2
+	args = append(args, from, to)
+
+	query := fmt.Sprintf(`SELECT d.reason_id, COALESCE(dr.code, ''), COALESCE(dr.name, ''), d.kind, COUNT(*),
+		COALESCE(SUM(GREATEST(EXTRACT(EPOCH FROM (LEAST(COALESCE(d.end_time, NOW()), $%d) - GREATEST(d.start_time, $%d))), 0) / 60), 0)::INT
+	FROM downtimes d
+	LEFT JOIN downtime_reasons dr ON dr.id = d.reason_id
+	WHERE 1=1%s
+	GROUP BY d.reason_id, dr.code, dr.name, d.kind
+	ORDER BY 6 DESC`, toArg, fromArg, where)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var summaries []ReasonSummary
+	for rows.Next() {
+		var summary ReasonSummary
+		if err := rows.Scan(&summary.ReasonID, &summary.Code, &summary.Name, &summary.Kind, &summary.Occurrences, &summary.TotalMinutes); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// filterClause builds the WHERE conditions of a filter. prefix is the table
+// alias used by the query, so the aggregated report can share it.
+func filterClause(filter DowntimeFilter, prefix string) (string, []interface{}) {
+	var query string
+	conditions, args := filterConditions(filter, prefix)
+	for i, condition := range conditions {
+		query += " AND " + strings.Replace(condition, "?", fmt.Sprintf("$%d", i+1), 1)
+	}
+	return query, args
+}
+
+// filterConditions returns the conditions of a filter, each with a ? for
+// its argument
+func filterConditions(filter DowntimeFilter, prefix string) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.CustomerID != nil {
+		conditions = append(conditions, prefix+"customer_id = ?")
+		args = append(args, *filter.CustomerID)
+	}
+	if filter.WorkcenterID != nil {
+		conditions = append(conditions, prefix+"workcenter_id = ?")
+		args = append(args, *filter.WorkcenterID)
+	}
+	if filter.ReasonID != nil {
+		conditions = append(conditions, prefix+"reason_id = ?")
+		args = append(args, *filter.ReasonID)
+	}
+	if filter.Kind != nil {
+		conditions = append(conditions, prefix+"kind = ?")
+		args = append(args, *filter.Kind)
+	}
+	if filter.To != nil {
+		conditions = append(conditions, prefix+"start_time < ?")
+		args = append(args, *filter.To)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, fmt.Sprintf("(%[1]send_time IS NULL OR %[1]send_time > ?)", prefix))
+		args = append(args, *filter.From)
+	}
+	return conditions, args
+}
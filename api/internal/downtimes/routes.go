@@ -0,0 +1,17 @@
+package downtimes
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.POST("/downtime-reasons", handler.CreateReason)
+	router.GET("/downtime-reasons", handler.FindReasons)
+	router.PUT("/downtime-reasons/:id", handler.UpdateReason)
+	router.DELETE("/downtime-reasons/:id", handler.DeleteReason)
+
+	router.GET("/downtimes/report", handler.Report)
+	router.POST("/downtimes", handler.Create)
+	router.GET("/downtimes", handler.Search)
+	router.GET("/downtimes/:id", handler.FindByID)
+	router.PUT("/downtimes/:id", handler.Update)
+	router.DELETE("/downtimes/:id", handler.Delete)
+}
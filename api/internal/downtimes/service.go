@@ -0,0 +1,259 @@
+package downtimes
+
+import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	CreateReason(ctx context.Context, request ReasonRequest) (Reason, error)
+	FindReasons(ctx context.Context, customerID string) ([]Reason, error)
+	UpdateReason(ctx context.Context, id string, request ReasonRequest) (Reason, error)
+	DeleteReason(ctx context.Context, id string) error
+	Create(ctx context.Context, request DowntimeRequest) (Downtime, error)
+	FindByID(ctx context.Context, id string) (Downtime, error)
+	Search(ctx context.Context, filter DowntimeFilter, params listing.Params) ([]Downtime, int, error)
+	Update(ctx context.Context, id string, request DowntimeRequest) (Downtime, error)
+	Delete(ctx context.Context, id string) error
+	Report(ctx context.Context, filter DowntimeFilter, from, to time.Time) (Report, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func normalizeKind(kind string) (string, error) {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	switch kind {
+	case "":
+		return KindUnplanned, nil
+	case KindPlanned, KindUnplanned:
+		return kind, nil
+	}
+	return "", apperr.Field("kind", "invalid_kind", "kind must be planned or unplanned")
+}
+
+func (s *service) CreateReason(ctx context.Context, request ReasonRequest) (Reason, error) {
+	scope, err := tenant.Scope(ctx, request.CustomerID)
+	if err != nil {
+		return Reason{}, err
+	}
+	if scope == nil {
+		return Reason{}, apperr.Field("customer_id", "customer_id_required", "customer_id is required")
+	}
+	kind, err := normalizeKind(request.Kind)
+	if err != nil {
+		return Reason{}, err
+	}
+	reason := Reason{
+		ID:         uuid.New(),
+		CustomerID: *scope,
+		Code:       strings.TrimSpace(request.Code),
+		Name:       request.Name,
+		Kind:       kind,
+		IsActive:   request.IsActive,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	return s.repo.CreateReason(ctx, reason)
+}
+
+func (s *service) FindReasons(ctx context.Context, customerID string) ([]Reason, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindReasons(ctx, scope)
+}
+
+func (s *service) findOwnedReason(ctx context.Context, id string) (Reason, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Reason{}, err
+	}
+	reason, err := s.repo.FindReasonByID(ctx, parsedID)
+	if err != nil {
+		return Reason{}, err
+	}
+	if err := tenant.Check(ctx, reason.CustomerID); err != nil {
+		return Reason{}, err
+	}
+	return reason, nil
+}
+
+func (s *service) UpdateReason(ctx context.Context, id string, request ReasonRequest) (Reason, error) {
+	reason, err := s.findOwnedReason(ctx, id)
+	if err != nil {
+		return Reason{}, err
+	}
+	kind, err := normalizeKind(request.Kind)
+	if err != nil {
+		return Reason{}, err
+	}
+	reason.Code = strings.TrimSpace(request.Code)
+	reason.Name = request.Name
+	reason.Kind = kind
+	reason.IsActive = request.IsActive
+	reason.UpdatedAt = time.Now()
+	return s.repo.UpdateReason(ctx, reason)
+}
+
+func (s *service) DeleteReason(ctx context.Context, id string) error {
+	reason, err := s.findOwnedReason(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteReason(ctx, reason.ID)
+}
+
+// apply validates a request and copies it into downtime
+func (s *service) apply(ctx context.Context, downtime *Downtime, request DowntimeRequest) error {
+	workcenterID, err := uuid.Parse(request.WorkcenterID)
+	if err != nil {
+		return err
+	}
+	customerID, err := s.repo.FindWorkcenterCustomerID(ctx, workcenterID)
+	if err != nil {
+		return err
+	}
+	ok, err := tenant.Owns(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return apperr.NotFound("workcenter_not_found", "workcenter not found")
+	}
+
+	kind := request.Kind
+	var reasonID uuid.NullUUID
+	if request.ReasonID != "" {
+		reason, err := s.findOwnedReason(ctx, request.ReasonID)
+		if err != nil {
+			return err
+		}
+		if reason.CustomerID != customerID {
+			return apperr.Field("reason_id", "foreign_reason", "downtime reason belongs to another customer")
+		}
+		reasonID = uuid.NullUUID{UUID: reason.ID, Valid: true}
+		// The reason decides the kind unless the request overrides it
+		if kind == "" {
+			kind = reason.Kind
+		}
+	}
+	kind, err = normalizeKind(kind)
+	if err != nil {
+		return err
+	}
+	if request.EndTime != nil && !request.EndTime.After(request.StartTime) {
+		return apperr.Field("end_time", "invalid_range", "end_time must be after start_time")
+	}
+
+	downtime.CustomerID = customerID
+	downtime.WorkcenterID = workcenterID
+	downtime.ReasonID = reasonID
+	downtime.Kind = kind
+	downtime.StartTime = request.StartTime
+	downtime.EndTime = request.EndTime
+	downtime.Notes = request.Notes
+	return nil
+}
+
+func (s *service) Create(ctx context.Context, request DowntimeRequest) (Downtime, error) {
+	downtime := Downtime{
+		ID:        uuid.New(),
+		Source:    SourceManual,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.apply(ctx, &downtime, request); err != nil {
+		return Downtime{}, err
+	}
+	return s.repo.Create(ctx, downtime)
+}
+
+func (s *service) FindByID(ctx context.Context, id string) (Downtime, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Downtime{}, err
+	}
+	downtime, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return Downtime{}, err
+	}
+	if err := tenant.Check(ctx, downtime.CustomerID); err != nil {
+		return Downtime{}, err
+	}
+	return downtime, nil
+}
+
+func (s *service) Search(ctx context.Context, filter DowntimeFilter, params listing.Params) ([]Downtime, int, error) {
+	requested := ""
+	if filter.CustomerID != nil {
+		requested = filter.CustomerID.String()
+	}
+	scope, err := tenant.Scope(ctx, requested)
+	if err != nil {
+		return nil, 0, err
+	}
+	filter.CustomerID = scope
+	return s.repo.Search(ctx, filter, params)
+}
+
+func (s *service) Update(ctx context.Context, id string, request DowntimeRequest) (Downtime, error) {
+	downtime, err := s.FindByID(ctx, id)
+	if err != nil {
+		return Downtime{}, err
+	}
+	if err := s.apply(ctx, &downtime, request); err != nil {
+		return Downtime{}, err
+	}
+	downtime.UpdatedAt = time.Now()
+	return s.repo.Update(ctx, downtime)
+}
+
+func (s *service) Delete(ctx context.Context, id string) error {
+	downtime, err := s.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, downtime.ID)
+}
+
+// Report aggregates the downtime between from and to per reason
+func (s *service) Report(ctx context.Context, filter DowntimeFilter, from, to time.Time) (Report, error) {
+	if !to.After(from) {
+		return Report{}, apperr.Validation("invalid_range", "to must be after from")
+	}
+	requested := ""
+	if filter.CustomerID != nil {
+		requested = filter.CustomerID.String()
+	}
+	scope, err := tenant.Scope(ctx, requested)
+	if err != nil {
+		return Report{}, err
+	}
+	filter.CustomerID = scope
+
+	summaries, err := s.repo.SummarizeByReason(ctx, filter, from, to)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{From: from, To: to, Reasons: summaries}
+	if report.Reasons == nil {
+		report.Reasons = []ReasonSummary{}
+	}
+	for _, summary := range summaries {
+		report.TotalMinutes += summary.TotalMinutes
+	}
+	return report, nil
+}
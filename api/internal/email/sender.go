@@ -0,0 +1,93 @@
+// Package email sends the transactional emails of the API through the SMTP
+// server in Config.Email. For local development point it at a sink such as
+// MailHog, which listens on localhost:1025 and shows the mails on :8025:
+//
+//	docker run -p 1025:1025 -p 8025:8025 mailhog/mailhog
+package email
+
+import (
+	"api/config"
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+type smtpSender struct {
+	addr     string
+	host     string
+	user     string
+	password string
+	from     *mail.Address
+}
+
+// NewSender returns a Sender for the SMTP server in the configuration.
+// Authentication is only used when a user is configured.
+func NewSender(cfg config.Config) (Sender, error) {
+	from, err := mail.ParseAddress(cfg.Email.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_FROM: %w", err)
+	}
+	return &smtpSender{
+		addr:     net.JoinHostPort(cfg.Email.Host, cfg.Email.Port),
+		host:     cfg.Email.Host,
+		user:     cfg.Email.User,
+		password: cfg.Email.Password,
+		from:     from,
+	}, nil
+}
+
+func (s *smtpSender) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	writer := quotedprintable.NewWriter(&body)
+	if _, err := writer.Write([]byte(message.Body)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", to.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", uuid.NewString(), s.host)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	msg.Write(body.Bytes())
+
+	var auth smtp.Auth
+	if s.user != "" {
+		auth = smtp.PlainAuth("", s.user, s.password, s.host)
+	}
+	return smtp.SendMail(s.addr, auth, s.from.Address, []string{to.Address}, msg.Bytes())
+}
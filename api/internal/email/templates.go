@@ -0,0 +1,180 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Templates
+const (
+	TemplatePasswordReset = "password_reset"
+	TemplateVerifyEmail   = "verify_email"
+	TemplateInvitation    = "invitation"
+)
+
+// DefaultLanguage is used when the customer has no language or one without templates
+const DefaultLanguage = "en"
+
+// Data fills a template
+type Data struct {
+	Name     string
+	Customer string
+	Link     string
+	// Hours the link is valid for
+	Hours int
+}
+
+type localized struct {
+	subject string
+	body    string
+}
+
+var sources = map[string]map[string]localized{
+	"en": {
+		TemplatePasswordReset: {
+			subject: "Reset your Turniq password",
+			body: `Hi {{.Name}},
+
+Someone asked to reset the password of your Turniq account. Follow this link to choose a new one:
+
+{{.Link}}
+
+The link can be used once and expires in {{.Hours}} hours. If you didn't ask for it, ignore this email.
+`,
+		},
+		TemplateVerifyEmail: {
+			subject: "Confirm your email address",
+			body: `Hi {{.Name}},
+
+Confirm the email address of your Turniq account at {{.Customer}} with this link:
+
+{{.Link}}
+
+The link expires in {{.Hours}} hours.
+`,
+		},
+		TemplateInvitation: {
+			subject: "You have been invited to Turniq",
+			body: `Hi {{.Name}},
+
+You have been invited to join {{.Customer}} on Turniq. Follow this link to set your password and activate your account:
+
+{{.Link}}
+
+The invitation expires in {{.Hours}} hours.
+`,
+		},
+	},
+	"ca": {
+		TemplatePasswordReset: {
+			subject: "Restableix la contrasenya de Turniq",
+			body: `Hola {{.Name}},
+
+Algú ha demanat restablir la contrasenya del teu compte de Turniq. Segueix aquest enllaç per triar-ne una de nova:
+
+{{.Link}}
+
+L'enllaç només es pot fer servir una vegada i caduca d'aquí a {{.Hours}} hores. Si no ho has demanat, ignora aquest correu.
+`,
+		},
+		TemplateVerifyEmail: {
+			subject: "Confirma la teva adreça de correu",
+			body: `Hola {{.Name}},
+
+Confirma l'adreça de correu del teu compte de Turniq a {{.Customer}} amb aquest enllaç:
+
+{{.Link}}
+
+L'enllaç caduca d'aquí a {{.Hours}} hores.
+`,
+		},
+		TemplateInvitation: {
+			subject: "T'han convidat a Turniq",
+			body: `Hola {{.Name}},
+
+T'han convidat a unir-te a {{.Customer}} a Turniq. Segueix aquest enllaç per triar la contrasenya i activar el compte:
+
+{{.Link}}
+
+La invitació caduca d'aquí a {{.Hours}} hores.
+`,
+		},
+	},
+	"es": {
+		TemplatePasswordReset: {
+			subject: "Restablece tu contraseña de Turniq",
+			body: `Hola {{.Name}},
+
+Alguien ha pedido restablecer la contraseña de tu cuenta de Turniq. Sigue este enlace para elegir una nueva:
+
+{{.Link}}
+
+El enlace solo se puede usar una vez y caduca en {{.Hours}} horas. Si no lo has pedido, ignora este correo.
+`,
+		},
+		TemplateVerifyEmail: {
+			subject: "Confirma tu dirección de correo",
+			body: `Hola {{.Name}},
+
+Confirma la dirección de correo de tu cuenta de Turniq en {{.Customer}} con este enlace:
+
+{{.Link}}
+
+El enlace caduca en {{.Hours}} horas.
+`,
+		},
+		TemplateInvitation: {
+			subject: "Te han invitado a Turniq",
+			body: `Hola {{.Name}},
+
+Te han invitado a unirte a {{.Customer}} en Turniq. Sigue este enlace para elegir tu contraseña y activar la cuenta:
+
+{{.Link}}
+
+La invitación caduca en {{.Hours}} horas.
+`,
+		},
+	},
+}
+
+// languages maps the values found in Customer.Language to a template language
+var languages = map[string]string{
+	"en": "en", "english": "en", "anglès": "en", "inglés": "en",
+	"ca": "ca", "catalan": "ca", "català": "ca", "catalán": "ca",
+	"es": "es", "spanish": "es", "castellà": "es", "español": "es", "castellano": "es",
+}
+
+var templates = map[string]map[string]*template.Template{}
+
+func init() {
+	for language, named := range sources {
+		templates[language] = map[string]*template.Template{}
+		for name, source := range named {
+			templates[language][name] = template.Must(template.New(language + "/" + name).Parse(source.body))
+		}
+	}
+}
+
+// Language returns the template language for the language of a customer
+func Language(language string) string {
+	if code, ok := languages[strings.ToLower(strings.TrimSpace(language))]; ok {
+		return code
+	}
+	return DefaultLanguage
+}
+
+// Render builds the message for template name in the language of a customer
+func Render(language, name, to string, data Data) (Message, error) {
+	code := Language(language)
+	source, ok := sources[code][name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %s", name)
+	}
+	var body bytes.Buffer
+	if err := templates[code][name].Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: source.subject, Body: body.String()}, nil
+}
@@ -0,0 +1,33 @@
+// Package idempotency stores the responses of the POST requests sent with an
+// Idempotency-Key header, so middleware.Idempotency can replay them when a
+// client retries. Keys are scoped to the tenant and the caller, the user or
+// the API key that sent the request, and expire after the configured window;
+// the Purger removes the expired ones.
+package idempotency
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scope is whose key it is: a caller of a tenant
+type Scope struct {
+	CustomerID uuid.UUID
+	// CallerID is the user, or the API key, that sent the request
+	CallerID uuid.UUID
+}
+
+// Record is the request a key was first used for and its response
+type Record struct {
+	// Fingerprint is the SHA-256 of the method, path and body of the request
+	Fingerprint string
+	// Status is zero while the first request is still running
+	Status      int
+	ContentType string
+	Location    string
+	Body        []byte
+	// ReservedAt tells the reservations of a key apart, so a request whose
+	// lease ran out can't store its response over the one that took the key
+	ReservedAt time.Time
+}
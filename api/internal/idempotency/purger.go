@@ -0,0 +1,40 @@
+package idempotency
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// How often the purger looks for expired keys
+const purgeInterval = time.Hour
+
+// Purger deletes the expired idempotency keys
+type Purger struct {
+	repo Repository
+}
+
+func NewPurger(repo Repository) *Purger {
+	return &Purger{repo: repo}
+}
+
+// Run purges the expired keys every purgeInterval until ctx is cancelled
+func (p *Purger) Run(ctx context.Context) {
+	slog.Info("Idempotency key purger started")
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		purged, err := p.repo.Purge(ctx, time.Now())
+		if err != nil {
+			slog.Error("Failed to purge idempotency keys", slog.Any("error", err))
+		} else if purged > 0 {
+			slog.Info("Idempotency keys purged", slog.Int64("keys", purged))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Idempotency key purger stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
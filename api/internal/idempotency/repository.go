@@ -0,0 +1,89 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type Repository interface {
+	Reserve(ctx context.Context, scope Scope, key string, fingerprint string, lockedUntil, expiresAt time.Time) (Record, bool, error)
+	Complete(ctx context.Context, scope Scope, key string, record Record) error
+	Release(ctx context.Context, scope Scope, key string, reservedAt time.Time) error
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// Reserve claims key for a request until lockedUntil and reports true. When
+// a live record already holds the key it returns that record and false
+// instead; an expired one, or one whose request didn't answer before its
+// lease ran out, is taken over.
+func (r *repository) Reserve(ctx context.Context, scope Scope, key string, fingerprint string, lockedUntil, expiresAt time.Time) (Record, bool, error) {
+	query := `INSERT INTO idempotency_keys (customer_id, caller_id, key, fingerprint, locked_until, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (customer_id, caller_id, key) DO UPDATE SET
+		fingerprint = EXCLUDED.fingerprint, status = NULL, content_type = NULL, location = NULL, body = NULL,
+		created_at = NOW(), locked_until = EXCLUDED.locked_until, expires_at = EXCLUDED.expires_at
+	WHERE idempotency_keys.expires_at <= NOW()
+		OR (idempotency_keys.status IS NULL AND idempotency_keys.locked_until <= NOW())
+	RETURNING fingerprint, created_at`
+	var reserved Record
+	err := r.db.QueryRowContext(ctx, query, scope.CustomerID, scope.CallerID, key, fingerprint, lockedUntil, expiresAt).
+		Scan(&reserved.Fingerprint, &reserved.ReservedAt)
+	if err == nil {
+		return reserved, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Record{}, false, err
+	}
+
+	var record Record
+	var status sql.NullInt64
+	var contentType, location sql.NullString
+	err = r.db.QueryRowContext(ctx, `SELECT fingerprint, status, content_type, location, body, created_at
+	FROM idempotency_keys WHERE customer_id = $1 AND caller_id = $2 AND key = $3`, scope.CustomerID, scope.CallerID, key).
+		Scan(&record.Fingerprint, &status, &contentType, &location, &record.Body, &record.ReservedAt)
+	if err != nil {
+		return Record{}, false, err
+	}
+	record.Status = int(status.Int64)
+	record.ContentType = contentType.String
+	record.Location = location.String
+	return record, false, nil
+}
+
+// Complete stores the response of the request that reserved key at
+// record.ReservedAt. It does nothing once another request took the key over.
+func (r *repository) Complete(ctx context.Context, scope Scope, key string, record Record) error {
+	query := `UPDATE idempotency_keys SET status = $4, content_type = $5, location = $6, body = $7
+	WHERE customer_id = $1 AND caller_id = $2 AND key = $3 AND created_at = $8 AND status IS NULL`
+	_, err := r.db.ExecContext(ctx, query, scope.CustomerID, scope.CallerID, key,
+		record.Status, record.ContentType, record.Location, record.Body, record.ReservedAt)
+	return err
+}
+
+// Release frees a key whose request, reserved at reservedAt, failed, so it
+// can be retried
+func (r *repository) Release(ctx context.Context, scope Scope, key string, reservedAt time.Time) error {
+	query := `DELETE FROM idempotency_keys
+	WHERE customer_id = $1 AND caller_id = $2 AND key = $3 AND created_at = $4 AND status IS NULL`
+	_, err := r.db.ExecContext(ctx, query, scope.CustomerID, scope.CallerID, key, reservedAt)
+	return err
+}
+
+// Purge deletes the keys that expired before before and returns how many
+func (r *repository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
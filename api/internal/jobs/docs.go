@@ -0,0 +1,27 @@
+package jobs
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/jobs", Tag: "jobs", Summary: "Create a job", Request: JobRequest{}, Response: Job{}},
+	{Method: "POST", Path: "/api/jobs/import", Tag: "jobs", Summary: "Create and update jobs from a CSV or XLSX file", Response: ImportResult{}, Form: []openapi.Param{
+		{Name: "file", Description: ".csv or .xlsx", Type: "binary"},
+		{Name: "mapping", Description: "JSON object of import fields to the column headers of the file"},
+		{Name: "dry_run", Description: "Validate the file without saving", Type: "boolean"},
+		{Name: "customer_id", Description: "Tenant to import into, for admins"},
+	}},
+	{Method: "GET", Path: "/api/jobs", Tag: "jobs", Summary: "List jobs", Response: []Job{}, List: &listSpec},
+	{Method: "GET", Path: "/api/jobs/lateness", Tag: "jobs", Summary: "Jobs planned to finish after their due date", Response: LatenessReport{}, Query: []openapi.Param{
+		openapi.CustomerID,
+		{Name: "horizon_days", Description: "Days ahead to look at", Type: "integer"},
+	}},
+	{Method: "GET", Path: "/api/jobs/trash", Tag: "jobs", Summary: "List deleted jobs", Response: []Job{}, List: &trashSpec, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "GET", Path: "/api/jobs/:id", Tag: "jobs", Summary: "Get a job", Response: Job{}},
+	{Method: "GET", Path: "/api/jobs/workcenter/:workcenterID", Tag: "jobs", Summary: "List the jobs of a workcenter", Response: []Job{}, List: &listSpec},
+	{Method: "GET", Path: "/api/jobs/shopfloor/:shopfloorID", Tag: "jobs", Summary: "List the jobs of a shop floor", Response: []Job{}, List: &listSpec},
+	{Method: "GET", Path: "/api/jobs/customer/:customerID", Tag: "jobs", Summary: "List the jobs of a customer", Response: []Job{}, List: &listSpec},
+	{Method: "PUT", Path: "/api/jobs/:id", Tag: "jobs", Summary: "Update a job", Request: JobRequest{}, Response: Job{}},
+	{Method: "DELETE", Path: "/api/jobs/:id", Tag: "jobs", Summary: "Move a job and its schedule entries to the trash", Query: []openapi.Param{openapi.Confirm}},
+	{Method: "POST", Path: "/api/jobs/:id/restore", Tag: "jobs", Summary: "Restore a deleted job with the schedule entries deleted along with it", Response: Job{}},
+}
@@ -1,7 +1,14 @@
 package jobs
 
 import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
+	"api/internal/trash"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,13 +25,13 @@ func (h *Handler) Create(c *gin.Context) {
 	ctx := c.Request.Context()
 	var request JobRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 
 	response, err := h.service.Create(ctx, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Job created successfully", "data": response})
@@ -33,16 +40,17 @@ func (h *Handler) Create(c *gin.Context) {
 func (h *Handler) FindAll(c *gin.Context) {
 	ctx := c.Request.Context()
 	
-	var response []Job
-	var err error
-
-	response, err = h.service.FindAll(ctx)	
-
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Jobs found successfully", "data": response})
+	response, total, err := h.service.List(ctx, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Jobs found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindByID(c *gin.Context) {
@@ -50,7 +58,11 @@ func (h *Handler) FindByID(c *gin.Context) {
 	id := c.Param("id")
 	response, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("job_not_found", "Job not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Job found successfully", "data": response})
@@ -59,34 +71,49 @@ func (h *Handler) FindByID(c *gin.Context) {
 func (h *Handler) FindByWorkcenterID(c *gin.Context) {
 	ctx := c.Request.Context()
 	workcenterID := c.Param("workcenterID")
-	response, err := h.service.FindByWorkcenterID(ctx, workcenterID)
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Jobs found successfully", "data": response})
+	response, total, err := h.service.FindByWorkcenterID(ctx, workcenterID, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Jobs found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func(h *Handler) FindByShopFloorID(c *gin.Context) {
 	ctx := c.Request.Context()
-	shopFloorID := c.Param("shopFloorID")
-	response, err := h.service.FindByShopFloorID(ctx, shopFloorID)
+	shopFloorID := c.Param("shopfloorID")
+	params, err := listing.Parse(c, listSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.FindByShopFloorID(ctx, shopFloorID, params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Jobs found successfully", "data": response})
+	c.JSON(http.StatusOK, gin.H{"message": "Jobs found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindByCustomerID(c *gin.Context) {
 	ctx := c.Request.Context()
 	customerID := c.Param("customerID")
-	response, err := h.service.FindByCustomerID(ctx, customerID)
+	params, err := listing.Parse(c, listSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.FindByCustomerID(ctx, customerID, params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Jobs found successfully", "data": response})
+	c.JSON(http.StatusOK, gin.H{"message": "Jobs found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) Update(c *gin.Context) {
@@ -94,12 +121,16 @@ func (h *Handler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var request JobRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Update(ctx, id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("job_not_found", "Job not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Job updated successfully", "data": response})
@@ -108,10 +139,109 @@ func (h *Handler) Update(c *gin.Context) {
 func (h *Handler) Delete(c *gin.Context) {
 	ctx := c.Request.Context()
 	id := c.Param("id")
-	if err := h.service.Delete(ctx, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.Delete(ctx, id, trash.Confirmed(c.Query("confirm"))); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("job_not_found", "Job not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Job deleted successfully"})
 }
-	
\ No newline at end of file
+
+func (h *Handler) Trash(c *gin.Context) {
+	ctx := c.Request.Context()
+	params, err := listing.Parse(c, trashSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.Trash(ctx, c.Query("customer_id"), params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted jobs found successfully", "data": response, "meta": params.Page(total)})
+}
+
+func (h *Handler) Restore(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.Restore(ctx, c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("job_not_found", "Job not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job restored successfully", "data": response})
+}
+
+func (h *Handler) Lateness(c *gin.Context) {
+	ctx := c.Request.Context()
+	horizonDays := DefaultLatenessHorizonDays
+	if v := c.Query("horizon_days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			c.Error(apperr.Validation("invalid_request", "horizon_days must be an integer"))
+			return
+		}
+		horizonDays = parsed
+	}
+	response, err := h.service.Lateness(ctx, c.Query("customer_id"), horizonDays)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Lateness report generated successfully", "data": response})
+}
+
+// Import accepts a multipart upload with a "file" part (.csv or .xlsx),
+// an optional JSON "mapping" of fields to column headers and a "dry_run" flag.
+func (h *Handler) Import(c *gin.Context) {
+	ctx := c.Request.Context()
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.Error(apperr.Validation("invalid_request", "file is required"))
+		return
+	}
+	mapping := map[string]string{}
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.Error(apperr.Validation("invalid_request", "mapping must be a JSON object"))
+			return
+		}
+	}
+	dryRun, _ := strconv.ParseBool(c.DefaultPostForm("dry_run", c.Query("dry_run")))
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	defer file.Close()
+
+	response, err := h.service.Import(ctx, ImportRequest{
+		CustomerID: c.PostForm("customer_id"),
+		FileName:   fileHeader.Filename,
+		Content:    file,
+		Mapping:    mapping,
+		DryRun:     dryRun,
+	})
+	if err != nil {
+		if errors.Is(err, ErrImportRejected) {
+			// The rows and their errors tell what to fix
+			c.Error(ErrImportRejected.With("data", response))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	message := "Jobs imported successfully"
+	if dryRun {
+		message = "Import validated successfully"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "data": response})
+}
@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"api/internal/apperr"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Fields that can be mapped from an import file
+const (
+	ImportFieldJobCode           = "job_code"
+	ImportFieldProductCode       = "product_code"
+	ImportFieldDescription       = "description"
+	ImportFieldEstimatedDuration = "estimated_duration"
+	ImportFieldShopFloor         = "shop_floor"
+	ImportFieldWorkcenter        = "workcenter"
+	ImportFieldDueDate           = "due_date"
+	ImportFieldPriority          = "priority"
+	ImportFieldCustomerOrderRef  = "customer_order_ref"
+)
+
+var importFields = []string{
+	ImportFieldJobCode,
+	ImportFieldProductCode,
+	ImportFieldDescription,
+	ImportFieldEstimatedDuration,
+	ImportFieldShopFloor,
+	ImportFieldWorkcenter,
+	ImportFieldDueDate,
+	ImportFieldPriority,
+	ImportFieldCustomerOrderRef,
+}
+
+var requiredImportFields = []string{ImportFieldJobCode, ImportFieldShopFloor, ImportFieldWorkcenter}
+
+var importDateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05", "02/01/2006", "02-01-2006"}
+
+var (
+	ErrInvalidImportFile       = apperr.Validation("invalid_import_file", "invalid import file")
+	ErrUnsupportedImportFormat = apperr.Validation("unsupported_import_format", "unsupported file format, expected .csv or .xlsx")
+)
+
+// importRecord is a single data row of an import file, keyed by import field
+type importRecord struct {
+	Line   int
+	Values map[string]string
+}
+
+// readImportFile parses a CSV or XLSX file and maps its columns to import fields.
+// mapping translates import fields to the header names used in the file;
+// unmapped fields are looked up by their own name.
+func readImportFile(fileName string, content io.Reader, mapping map[string]string) ([]importRecord, error) {
+	var rows [][]string
+	var err error
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		rows, err = readCSV(content)
+	case ".xlsx":
+		rows, err = readXLSX(content)
+	default:
+		return nil, ErrUnsupportedImportFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("import file is empty")
+	}
+
+	headerIndex := map[string]int{}
+	for i, header := range rows[0] {
+		headerIndex[normalizeHeader(header)] = i
+	}
+
+	columns := map[string]int{}
+	for _, field := range importFields {
+		header := field
+		if mapped, ok := mapping[field]; ok && mapped != "" {
+			header = mapped
+		}
+		if idx, ok := headerIndex[normalizeHeader(header)]; ok {
+			columns[field] = idx
+		}
+	}
+	for _, field := range requiredImportFields {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("missing column for required field %q", field)
+		}
+	}
+
+	var records []importRecord
+	for i, row := range rows[1:] {
+		if isBlankRow(row) {
+			continue
+		}
+		record := importRecord{Line: i + 2, Values: map[string]string{}}
+		for field, idx := range columns {
+			if idx < len(row) {
+				record.Values[field] = strings.TrimSpace(row[idx])
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func readCSV(content io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 BOM added by Excel
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	// ERP exports in Europe usually separate fields with semicolons
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+func readXLSX(content io.Reader) ([][]string, error) {
+	file, err := excelize.OpenReader(content)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sheets := file.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("workbook has no sheets")
+	}
+	return file.GetRows(sheets[0])
+}
+
+func normalizeHeader(header string) string {
+	header = strings.TrimPrefix(header, "\ufeff")
+	return strings.ToLower(strings.TrimSpace(header))
+}
+
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func parseImportDate(value string) (time.Time, error) {
+	for _, layout := range importDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+func parseImportInt(value string) (int, error) {
+	// Spreadsheets often render integers as "120.0"
+	if f, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64); err == nil && f == float64(int(f)) {
+		return int(f), nil
+	}
+	return 0, fmt.Errorf("invalid number %q", value)
+}
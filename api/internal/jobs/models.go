@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"io"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,16 +16,67 @@ type Job struct {
 	ProductCode string    `json:"product_code"`
 	Description string    `json:"description"`
 	EstimatedDuration int `json:"estimated_duration"`
+	DueDate     *time.Time `json:"due_date"`
+	Priority    int       `json:"priority"`
+	CustomerOrderRef string `json:"customer_order_ref"`
+	IdealCycleSeconds float64 `json:"ideal_cycle_seconds"` // ideal time per piece, used by OEE
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 }
 
 type JobRequest struct {
-	CustomerID    string `json:"customer_id"`
-	ShopFloorID string `json:"shop_floor_id"`
-	WorkcenterID string `json:"workcenter_id"`
-	JobCode     string `json:"job_code"`
+	CustomerID    string `json:"customer_id" binding:"omitempty,uuid"`
+	ShopFloorID string `json:"shop_floor_id" binding:"required,uuid"`
+	WorkcenterID string `json:"workcenter_id" binding:"required,uuid"`
+	JobCode     string `json:"job_code" binding:"required,max=100"`
 	ProductCode string `json:"product_code"`
 	Description string `json:"description"`
-	EstimatedDuration int `json:"estimated_duration"`
-}
\ No newline at end of file
+	EstimatedDuration int `json:"estimated_duration" binding:"gte=0"`
+	DueDate     *time.Time `json:"due_date"`
+	Priority    int    `json:"priority"`
+	CustomerOrderRef string `json:"customer_order_ref"`
+	IdealCycleSeconds float64 `json:"ideal_cycle_seconds" binding:"gte=0"` // ideal time per piece, used by OEE
+}
+
+type LateJob struct {
+	Job
+	LastScheduledEnd time.Time `json:"last_scheduled_end"`
+	DelayMinutes     int       `json:"delay_minutes"`
+}
+
+type LatenessReport struct {
+	// Jobs whose last scheduled entry ends after their due date
+	Late []LateJob `json:"late"`
+	// Jobs without any schedule entry whose due date falls within the horizon
+	Unscheduled []Job `json:"unscheduled"`
+	HorizonDays int   `json:"horizon_days"`
+}
+type ImportRequest struct {
+	CustomerID string
+	FileName   string
+	Content    io.Reader
+	// Mapping translates import fields (job_code, workcenter...) to the column headers of the file
+	Mapping map[string]string
+	DryRun  bool
+}
+
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportResult struct {
+	DryRun    bool             `json:"dry_run"`
+	TotalRows int              `json:"total_rows"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Errors    []ImportRowError `json:"errors"`
+}
+
+// WorkcenterRef is the minimal workcenter data needed to resolve names during an import
+type WorkcenterRef struct {
+	ID          uuid.UUID
+	ShopFloorID uuid.NullUUID
+}
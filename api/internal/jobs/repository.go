@@ -1,86 +1,191 @@
 package jobs
 
 import (
+	"api/internal/listing"
+	"api/internal/outbox"
+	"api/internal/shopfloors"
+	"api/internal/trash"
 	"context"
 	"database/sql"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// listSpec is what the job lists can be sorted, filtered and searched on
+var listSpec = listing.Spec{
+	Sort: map[string]string{
+		"due_date":           "due_date",
+		"priority":           "priority",
+		"customer_order_ref": "customer_order_ref",
+		"job_code":           "job_code",
+		"created_at":         "created_at",
+	},
+	Filter: map[string]string{
+		"customer_id":   "customer_id",
+		"shop_floor_id": "shop_floor_id",
+		"shopfloor_id":  "shop_floor_id",
+		"workcenter_id": "workcenter_id",
+		"priority":      "priority",
+	},
+	Search:  []string{"job_code", "product_code", "description", "customer_order_ref"},
+	Default: []listing.Order{{Field: "created_at"}},
+}
+
+// trashSpec is what the trash can be sorted and filtered on
+var trashSpec = trash.Spec(listSpec)
+
 type Repository interface {
-	Create(ctx context.Context, job Job) (Job, error)
-	FindAll(ctx context.Context) ([]Job, error)
+	Create(ctx context.Context, job Job, events ...outbox.Event) (Job, error)
+	List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Job, int, error)
 	FindByID(ctx context.Context, id uuid.UUID) (Job, error)
-	FindByWorkcenterID(ctx context.Context, workcenterId uuid.UUID) ([]Job, error)
-	FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]Job, error)
-	FindByShopFloorID(ctx context.Context, shopFloorID uuid.UUID) ([]Job, error)
+	FindByWorkcenterID(ctx context.Context, workcenterId uuid.UUID, customerID *uuid.UUID, params listing.Params) ([]Job, int, error)
+	FindByCustomerID(ctx context.Context, customerID uuid.UUID, params listing.Params) ([]Job, int, error)
+	FindByShopFloorID(ctx context.Context, shopFloorID uuid.UUID, customerID *uuid.UUID, params listing.Params) ([]Job, int, error)
+	FindLate(ctx context.Context, customerID *uuid.UUID) ([]LateJob, error)
+	FindUnscheduledDueBefore(ctx context.Context, customerID *uuid.UUID, limit time.Time) ([]Job, error)
 	CountByCustomerID(ctx context.Context, customerID uuid.UUID) (int, error)
+	FindCodesByCustomerID(ctx context.Context, customerID uuid.UUID) (map[string]Job, error)
+	FindShopFloorNames(ctx context.Context, customerID uuid.UUID) (map[string]uuid.UUID, error)
+	FindWorkcenterNames(ctx context.Context, customerID uuid.UUID) (map[string][]WorkcenterRef, error)
+	FindShopFloorCustomerID(ctx context.Context, shopFloorID uuid.UUID) (uuid.UUID, error)
+	FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error)
+	WorkcenterInShopFloor(ctx context.Context, workcenterID, shopFloorID uuid.UUID) (bool, error)
+	Import(ctx context.Context, creates []Job, updates []Job, events ...outbox.Event) error
 	Update(ctx context.Context, job Job) (Job,error)
-	Delete(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID, confirm func(trash.Preview) error) error
+	FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Job, int, error)
+	FindDeletedByID(ctx context.Context, id uuid.UUID) (Job, error)
+	Restore(ctx context.Context, id uuid.UUID) error
 }
 
 type repository struct {
-	db *sql.DB	
+	db *sql.DB
 }
 
 func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
-func (r *repository) Create(ctx context.Context, job Job) (Job,error) {
-	query := `INSERT INTO jobs (id, customer_id, shop_floor_id, workcenter_id, 
-								job_code, product_code, description, 
-								estimated_duration, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
-	_, err := r.db.ExecContext(ctx, query, job.ID, job.CustomerID, job.ShopFloorID, job.WorkcenterID, 
-								job.JobCode, job.ProductCode, job.Description, 
-								job.EstimatedDuration, job.CreatedAt, job.UpdatedAt)
+// Create inserts the job and writes the given outbox events in the same transaction
+func (r *repository) Create(ctx context.Context, job Job, events ...outbox.Event) (Job,error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Job{}, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO jobs (id, customer_id, shop_floor_id, workcenter_id,
+								job_code, product_code, description,
+								estimated_duration, due_date, priority, customer_order_ref, ideal_cycle_seconds,
+								created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+	_, err = tx.ExecContext(ctx, query, job.ID, job.CustomerID, job.ShopFloorID, job.WorkcenterID,
+								job.JobCode, job.ProductCode, job.Description,
+								job.EstimatedDuration, job.DueDate, job.Priority, job.CustomerOrderRef, job.IdealCycleSeconds,
+								job.CreatedAt, job.UpdatedAt)
 	if err != nil {
 		return Job{},err
 	}
+	if err := outbox.Write(ctx, tx, events...); err != nil {
+		return Job{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Job{}, err
+	}
 	return job,nil
 }
 
-func (r *repository) FindAll(ctx context.Context) ([]Job, error) {
-	query := "SELECT id, customer_id, shop_floor_id, workcenter_id, job_code, product_code, description, estimated_duration, created_at, updated_at FROM jobs"
-	rows, err := r.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
+// List returns a page of the jobs of customerID, or of every customer when nil
+func (r *repository) List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Job, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
 	}
-	defer rows.Close()
-	var jobs []Job
-	for rows.Next() {
+	return r.findPage(ctx, q)
+}
+
+// findPage runs a job list query. Users restricted to some shop floors only
+// see their jobs.
+func (r *repository) findPage(ctx context.Context, q *listing.Query) ([]Job, int, error) {
+	shopfloors.Restrict(ctx, q, "shop_floor_id")
+	return listing.Find(ctx, r.db, q, "SELECT id, customer_id, shop_floor_id, workcenter_id, job_code, product_code, description, estimated_duration, due_date, priority, customer_order_ref, ideal_cycle_seconds, created_at, updated_at FROM jobs", func(rows *sql.Rows) (Job, error) {
 		var job Job
-		err := rows.Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.CreatedAt, &job.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		jobs = append(jobs, job)
-	}
-	return jobs, nil
+		err := rows.Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.DueDate, &job.Priority, &job.CustomerOrderRef, &job.IdealCycleSeconds, &job.CreatedAt, &job.UpdatedAt)
+		return job, err
+	})
 }
 
 func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (Job, error) {
-	query := "SELECT id, customer_id, shop_floor_id, workcenter_id, job_code, product_code, description, estimated_duration, created_at, updated_at FROM jobs WHERE id = $1"
+	query := "SELECT id, customer_id, shop_floor_id, workcenter_id, job_code, product_code, description, estimated_duration, due_date, priority, customer_order_ref, ideal_cycle_seconds, created_at, updated_at FROM jobs WHERE id = $1 AND deleted_at IS NULL"
 	row := r.db.QueryRowContext(ctx, query, id)
 	var job Job
-	err := row.Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.CreatedAt, &job.UpdatedAt)
+	err := row.Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.DueDate, &job.Priority, &job.CustomerOrderRef, &job.IdealCycleSeconds, &job.CreatedAt, &job.UpdatedAt)
 	if err != nil {
 		return Job{}, err
 	}
 	return job, nil
 }
 
-func(r *repository) FindByWorkcenterID(ctx context.Context, workcenterId uuid.UUID) ([]Job, error) {
-	query := "SELECT id, customer_id, shop_floor_id, workcenter_id, job_code, product_code, description, estimated_duration, created_at, updated_at FROM jobs WHERE workcenter_id = $1"
-	rows, err := r.db.QueryContext(ctx, query, workcenterId)
+// FindByWorkcenterID returns a page of the jobs of the workcenter
+func (r *repository) FindByWorkcenterID(ctx context.Context, workcenterId uuid.UUID, customerID *uuid.UUID, params listing.Params) ([]Job, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	q.Where("workcenter_id = ?", workcenterId)
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return r.findPage(ctx, q)
+}
+
+// FindByCustomerID returns a page of the jobs of the customer
+func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID, params listing.Params) ([]Job, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	q.Where("customer_id = ?", customerID)
+	return r.findPage(ctx, q)
+}
+
+// FindByShopFloorID returns a page of the jobs of the shop floor and of every shop floor below it
+func (r *repository) FindByShopFloorID(ctx context.Context, shopFloorID uuid.UUID, customerID *uuid.UUID, params listing.Params) ([]Job, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	shopfloors.InSubtree(q, "shop_floor_id", shopFloorID)
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return r.findPage(ctx, q)
+}
+
+// FindLate returns the jobs whose last scheduled entry ends after their due date.
+// Entries without an explicit end time are considered to end on their planned date.
+func (r *repository) FindLate(ctx context.Context, customerID *uuid.UUID) ([]LateJob, error) {
+	query := `SELECT j.id, j.customer_id, j.shop_floor_id, j.workcenter_id, j.job_code, j.product_code, j.description,
+		j.estimated_duration, j.due_date, j.priority, j.customer_order_ref, j.ideal_cycle_seconds, j.created_at, j.updated_at,
+		MAX(COALESCE(se.end_time, se.date)) AS last_scheduled_end
+	FROM jobs j
+	JOIN schedule_entries se ON se.job_id = j.id AND se.deleted_at IS NULL
+	WHERE j.due_date IS NOT NULL AND j.deleted_at IS NULL`
+
+	var args []interface{}
+	if customerID != nil {
+		query += " AND j.customer_id = $1"
+		args = append(args, *customerID)
+	}
+	query += ` GROUP BY j.id
+	HAVING MAX(COALESCE(se.end_time, se.date)) > j.due_date
+	ORDER BY j.due_date ASC, j.priority DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var jobs []Job
+	var jobs []LateJob
 	for rows.Next() {
-		var job Job
-		err := rows.Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.CreatedAt, &job.UpdatedAt)
+		var job LateJob
+		err := rows.Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.DueDate, &job.Priority, &job.CustomerOrderRef, &job.IdealCycleSeconds, &job.CreatedAt, &job.UpdatedAt, &job.LastScheduledEnd)
 		if err != nil {
 			return nil, err
 		}
@@ -89,9 +194,23 @@ func(r *repository) FindByWorkcenterID(ctx context.Context, workcenterId uuid.UU
 	return jobs, nil
 }
 
-func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]Job, error) {
-	query := "SELECT id, customer_id, shop_floor_id, workcenter_id, job_code, product_code, description, estimated_duration, created_at, updated_at FROM jobs WHERE customer_id = $1"
-	rows, err := r.db.QueryContext(ctx, query, customerID)
+// FindUnscheduledDueBefore returns the jobs without any schedule entry whose due date is before limit.
+// Jobs that are already overdue are included as well.
+func (r *repository) FindUnscheduledDueBefore(ctx context.Context, customerID *uuid.UUID, limit time.Time) ([]Job, error) {
+	query := `SELECT j.id, j.customer_id, j.shop_floor_id, j.workcenter_id, j.job_code, j.product_code, j.description,
+		j.estimated_duration, j.due_date, j.priority, j.customer_order_ref, j.ideal_cycle_seconds, j.created_at, j.updated_at
+	FROM jobs j
+	WHERE j.due_date IS NOT NULL AND j.due_date <= $1 AND j.deleted_at IS NULL
+	AND NOT EXISTS (SELECT 1 FROM schedule_entries se WHERE se.job_id = j.id AND se.deleted_at IS NULL)`
+
+	args := []interface{}{limit}
+	if customerID != nil {
+		query += " AND j.customer_id = $2"
+		args = append(args, *customerID)
+	}
+	query += " ORDER BY j.due_date ASC, j.priority DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -99,7 +218,7 @@ func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID)
 	var jobs []Job
 	for rows.Next() {
 		var job Job
-		err := rows.Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.CreatedAt, &job.UpdatedAt)
+		err := rows.Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.DueDate, &job.Priority, &job.CustomerOrderRef, &job.IdealCycleSeconds, &job.CreatedAt, &job.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -108,50 +227,260 @@ func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID)
 	return jobs, nil
 }
 
-func (r *repository) FindByShopFloorID(ctx context.Context, shopFloorID uuid.UUID) ([]Job, error) {
-	query := "SELECT id, customer_id, shop_floor_id, workcenter_id, job_code, product_code, description, estimated_duration, created_at, updated_at FROM jobs WHERE shop_floor_id = $1"
-	rows, err := r.db.QueryContext(ctx, query, shopFloorID)
+// FindShopFloorCustomerID returns the tenant of a shop floor
+func (r *repository) FindShopFloorCustomerID(ctx context.Context, shopFloorID uuid.UUID) (uuid.UUID, error) {
+	var customerID uuid.UUID
+	err := r.db.QueryRowContext(ctx, `SELECT customer_id FROM shopfloors WHERE id = $1 AND deleted_at IS NULL`, shopFloorID).Scan(&customerID)
+	return customerID, err
+}
+
+// FindWorkcenterCustomerID returns the tenant of a workcenter
+func (r *repository) FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error) {
+	var customerID uuid.UUID
+	err := r.db.QueryRowContext(ctx, `SELECT customer_id FROM workcenters WHERE id = $1 AND deleted_at IS NULL`, workcenterID).Scan(&customerID)
+	return customerID, err
+}
+
+// WorkcenterInShopFloor reports whether a workcenter can run jobs of a shop
+// floor: it has no shop floor or one below it
+func (r *repository) WorkcenterInShopFloor(ctx context.Context, workcenterID, shopFloorID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM workcenters WHERE id = $1 AND (shop_floor_id IS NULL OR ` + shopfloors.SubtreeCondition("shop_floor_id", 2) + `))`
+	var ok bool
+	err := r.db.QueryRowContext(ctx, query, workcenterID, shopFloorID).Scan(&ok)
+	return ok, err
+}
+
+func (r *repository) CountByCustomerID(ctx context.Context, customerID uuid.UUID) (int, error) {
+	query := "SELECT COUNT(*) FROM jobs WHERE customer_id = $1 AND deleted_at IS NULL"
+	var count int
+	err := r.db.QueryRowContext(ctx, query, customerID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindCodesByCustomerID returns every job of a customer keyed by job code.
+// It ignores the shop floors the user is restricted to: codes are unique
+// across the tenant.
+func (r *repository) FindCodesByCustomerID(ctx context.Context, customerID uuid.UUID) (map[string]Job, error) {
+	query := "SELECT id, customer_id, shop_floor_id, workcenter_id, job_code, product_code, description, estimated_duration, due_date, priority, customer_order_ref, ideal_cycle_seconds, created_at, updated_at FROM jobs WHERE customer_id = $1 AND deleted_at IS NULL"
+	rows, err := r.db.QueryContext(ctx, query, customerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var jobs []Job
+	byCode := map[string]Job{}
 	for rows.Next() {
 		var job Job
-		err := rows.Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.CreatedAt, &job.UpdatedAt)
+		err := rows.Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.DueDate, &job.Priority, &job.CustomerOrderRef, &job.IdealCycleSeconds, &job.CreatedAt, &job.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
-		jobs = append(jobs, job)
+		byCode[job.JobCode] = job
 	}
-	return jobs, nil
+	return byCode, rows.Err()
 }
 
-func (r *repository) CountByCustomerID(ctx context.Context, customerID uuid.UUID) (int, error) {
-	query := "SELECT COUNT(*) FROM jobs WHERE customer_id = $1"
-	var count int
-	err := r.db.QueryRowContext(ctx, query, customerID).Scan(&count)
+// FindShopFloorNames returns the shopfloor IDs of a customer keyed by lowercase name
+func (r *repository) FindShopFloorNames(ctx context.Context, customerID uuid.UUID) (map[string]uuid.UUID, error) {
+	query := "SELECT id, name FROM shopfloors WHERE customer_id = $1 AND deleted_at IS NULL"
+	rows, err := r.db.QueryContext(ctx, query, customerID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return count, nil
+	defer rows.Close()
+	names := map[string]uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[strings.ToLower(strings.TrimSpace(name))] = id
+	}
+	return names, rows.Err()
+}
+
+// FindWorkcenterNames returns the workcenters of a customer keyed by lowercase name.
+// The same name may exist on several shopfloors.
+func (r *repository) FindWorkcenterNames(ctx context.Context, customerID uuid.UUID) (map[string][]WorkcenterRef, error) {
+	query := "SELECT id, shop_floor_id, name FROM workcenters WHERE customer_id = $1 AND deleted_at IS NULL"
+	rows, err := r.db.QueryContext(ctx, query, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := map[string][]WorkcenterRef{}
+	for rows.Next() {
+		var ref WorkcenterRef
+		var name string
+		if err := rows.Scan(&ref.ID, &ref.ShopFloorID, &name); err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(strings.TrimSpace(name))
+		names[key] = append(names[key], ref)
+	}
+	return names, rows.Err()
+}
+
+// Import inserts and updates the given jobs in a single transaction
+func (r *repository) Import(ctx context.Context, creates []Job, updates []Job, events ...outbox.Event) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insertQuery := `INSERT INTO jobs (id, customer_id, shop_floor_id, workcenter_id,
+								job_code, product_code, description,
+								estimated_duration, due_date, priority, customer_order_ref, ideal_cycle_seconds,
+								created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+	insertStmt, err := tx.PrepareContext(ctx, insertQuery)
+	if err != nil {
+		return err
+	}
+	defer insertStmt.Close()
+	for _, job := range creates {
+		_, err := insertStmt.ExecContext(ctx, job.ID, job.CustomerID, job.ShopFloorID, job.WorkcenterID,
+			job.JobCode, job.ProductCode, job.Description,
+			job.EstimatedDuration, job.DueDate, job.Priority, job.CustomerOrderRef, job.IdealCycleSeconds,
+			job.CreatedAt, job.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	updateQuery := `UPDATE jobs SET shop_floor_id = $2, workcenter_id = $3, product_code = $4, description = $5,
+		estimated_duration = $6, due_date = $7, priority = $8, customer_order_ref = $9, updated_at = $10 WHERE id = $1`
+	updateStmt, err := tx.PrepareContext(ctx, updateQuery)
+	if err != nil {
+		return err
+	}
+	defer updateStmt.Close()
+	for _, job := range updates {
+		_, err := updateStmt.ExecContext(ctx, job.ID, job.ShopFloorID, job.WorkcenterID, job.ProductCode, job.Description,
+			job.EstimatedDuration, job.DueDate, job.Priority, job.CustomerOrderRef, job.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := outbox.Write(ctx, tx, events...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *repository) Update(ctx context.Context, job Job) (Job, error) {
-	query := "UPDATE jobs SET tenant_id = $2, shop_floor_id = $3, workcenter_id = $4, job_code = $5, product_code = $6, description = $7, estimated_duration = $8, updated_at = $9 WHERE id = $1"
-	_, err := r.db.ExecContext(ctx, query, job.ID, job.CustomerID, job.ShopFloorID, job.WorkcenterID, job.JobCode, job.ProductCode, job.Description, job.EstimatedDuration, job.UpdatedAt)
+	query := `UPDATE jobs SET customer_id = $2, shop_floor_id = $3, workcenter_id = $4, job_code = $5, product_code = $6, description = $7,
+		estimated_duration = $8, due_date = $9, priority = $10, customer_order_ref = $11, ideal_cycle_seconds = $12, updated_at = $13 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, job.ID, job.CustomerID, job.ShopFloorID, job.WorkcenterID, job.JobCode, job.ProductCode, job.Description,
+		job.EstimatedDuration, job.DueDate, job.Priority, job.CustomerOrderRef, job.IdealCycleSeconds, job.UpdatedAt)
 	if err != nil {
 		return Job{},err
 	}
 	return job, nil
 }
 
-func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := "DELETE FROM jobs WHERE id = $1"
-	_, err := r.db.ExecContext(ctx, query, id)
+// deleteSteps move the schedule entries of a job to the trash along with it
+var deleteSteps = []trash.Step{
+	{Kind: "schedule_entries", Query: `UPDATE schedule_entries SET deleted_at = $2 WHERE job_id = $1 AND deleted_at IS NULL`},
+}
+
+// restoreSteps take the schedule entries deleted along with a job out of the trash
+var restoreSteps = []trash.Step{
+	{Kind: "schedule_entries", Query: `UPDATE schedule_entries SET deleted_at = NULL WHERE job_id = $1 AND deleted_at = $2
+	AND ` + trash.ScheduleEntryParentsLive},
+}
+
+// Delete moves the job and its schedule entries to the trash, marked with the
+// same time so they are restored together. Its production counts are kept.
+// confirm gets the preview before anything is committed and cancels the
+// deletion when it fails.
+func (r *repository) Delete(ctx context.Context, id uuid.UUID, confirm func(trash.Preview) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	var preview trash.Preview
+	query := `SELECT COUNT(*) FROM production_counts WHERE job_id = $1`
+	var productionCounts int
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&productionCounts); err != nil {
+		return err
+	}
+	preview.Kept = trash.Dependents{"production_counts": productionCounts}
+
+	var deletedAt time.Time
+	query = `UPDATE jobs SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL RETURNING deleted_at`
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&deletedAt); err != nil {
+		return err
+	}
+	if preview.Deleted, err = trash.Cascade(ctx, tx, deleteSteps, id, deletedAt); err != nil {
+		return err
+	}
+	if err := confirm(preview); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindDeleted returns a page of the jobs in the trash of the tenant, or of
+// every tenant when customerID is nil
+func (r *repository) FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Job, int, error) {
+	q := listing.NewQuery(trashSpec, params)
+	q.Where("deleted_at IS NOT NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return listing.Find(ctx, r.db, q, `SELECT id, customer_id, shop_floor_id, workcenter_id, job_code, product_code, description, estimated_duration, due_date, priority, customer_order_ref, ideal_cycle_seconds, created_at, updated_at, deleted_at FROM jobs`, func(rows *sql.Rows) (Job, error) {
+		var job Job
+		err := rows.Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.DueDate, &job.Priority, &job.CustomerOrderRef, &job.IdealCycleSeconds, &job.CreatedAt, &job.UpdatedAt, &job.DeletedAt)
+		return job, err
+	})
+}
+
+func (r *repository) FindDeletedByID(ctx context.Context, id uuid.UUID) (Job, error) {
+	query := `SELECT id, customer_id, shop_floor_id, workcenter_id, job_code, product_code, description, estimated_duration, due_date, priority, customer_order_ref, ideal_cycle_seconds, created_at, updated_at, deleted_at
+	FROM jobs WHERE id = $1 AND deleted_at IS NOT NULL`
+	var job Job
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&job.ID, &job.CustomerID, &job.ShopFloorID, &job.WorkcenterID, &job.JobCode, &job.ProductCode, &job.Description, &job.EstimatedDuration, &job.DueDate, &job.Priority, &job.CustomerOrderRef, &job.IdealCycleSeconds, &job.CreatedAt, &job.UpdatedAt, &job.DeletedAt)
+	if err != nil {
+		return Job{}, err
+	}
+	return job, nil
 }
 
+// Restore takes the job out of the trash together with the schedule entries
+// deleted with it, unless its shop floor or workcenter is still in the trash
+func (r *repository) Restore(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	query := `SELECT deleted_at FROM jobs WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&deletedAt); err != nil {
+		return err
+	}
+	query = `UPDATE jobs SET deleted_at = NULL WHERE id = $1 AND ` + trash.JobParentsLive
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return trash.ErrParentDeleted
+	}
+	if _, err := trash.Cascade(ctx, tx, restoreSteps, id, deletedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,9 +1,20 @@
 package jobs
 
 import (
+	"api/internal/apperr"
+	"api/internal/audit"
 	"api/internal/customers"
+	"api/internal/listing"
+	"api/internal/outbox"
+	"api/internal/shopfloors"
+	"api/internal/tenant"
+	"api/internal/trash"
+	"api/internal/validation"
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,47 +22,39 @@ import (
 
 type Service interface {
 	Create(ctx context.Context, request JobRequest) (Job, error)
-	FindAll(ctx context.Context) ([]Job, error)
+	List(ctx context.Context, params listing.Params) ([]Job, int, error)
 	FindByID(ctx context.Context, id string) (Job, error)
-	FindByWorkcenterID(ctx context.Context, workcenterId string) ([]Job, error)
-	FindByShopFloorID(ctx context.Context, shopFloorID string) ([]Job, error)
-	FindByCustomerID(ctx context.Context, customerID string) ([]Job, error)
+	FindByWorkcenterID(ctx context.Context, workcenterId string, params listing.Params) ([]Job, int, error)
+	FindByShopFloorID(ctx context.Context, shopFloorID string, params listing.Params) ([]Job, int, error)
+	FindByCustomerID(ctx context.Context, customerID string, params listing.Params) ([]Job, int, error)
+	Lateness(ctx context.Context, customerID string, horizonDays int) (LatenessReport, error)
+	Import(ctx context.Context, request ImportRequest) (ImportResult, error)
 	Update(ctx context.Context, id string, request JobRequest) (Job, error)
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string, confirmed bool) error
+	Trash(ctx context.Context, customerID string, params listing.Params) ([]Job, int, error)
+	Restore(ctx context.Context, id string) (Job, error)
 }
 
+// DefaultLatenessHorizonDays is how far ahead the lateness report looks for unplanned jobs.
+const DefaultLatenessHorizonDays = 7
+
+// ErrImportRejected is returned when an import file contains invalid rows and nothing was written
+var ErrImportRejected = apperr.Validation("import_rejected", "import rejected, the file contains invalid rows")
+
 type service struct {
 	repository Repository	
 	customerService customers.Service
+	audit audit.Recorder
 }
 
-func NewService(repository Repository, customerService customers.Service) Service {
-	return &service{repository: repository, customerService: customerService}
+func NewService(repository Repository, customerService customers.Service, recorder audit.Recorder) Service {
+	return &service{repository: repository, customerService: customerService, audit: recorder}
 }
 
 func (s *service) Create(ctx context.Context, request JobRequest) (Job, error) {
-	var customerID uuid.UUID
-	isAdminVal := ctx.Value("is_admin")
-	isAdmin, ok := isAdminVal.(bool)
-	if !ok {
-		return Job{}, errors.New("invalid or missing is_admin in context")
-	}
-	
-	if isAdmin {
-		// Si és admin, usar el customerID del request
-		parsedCustomerID, err := uuid.Parse(request.CustomerID)
-		if err != nil {
-			return Job{}, err
-		}
-		customerID = parsedCustomerID
-	} else {
-		// Si no és admin, usar el customerID del context
-		customerIDVal := ctx.Value("customer_id")
-		customerIDFromCtx, ok := customerIDVal.(uuid.UUID)
-		if !ok {
-			return Job{}, errors.New("invalid or missing customer_id in context")
-		}
-		customerID = customerIDFromCtx
+	customerID, err := tenant.Customer(ctx, request.CustomerID)
+	if err != nil {
+		return Job{}, err
 	}
 
 	// Check limits
@@ -66,7 +69,7 @@ func (s *service) Create(ctx context.Context, request JobRequest) (Job, error) {
 	}
 
 	if count >= customer.MaxJobs {
-		return Job{}, errors.New("max jobs limit reached for this customer")
+		return Job{}, apperr.QuotaExceeded("max_jobs", "max jobs limit reached for this customer")
 	}
 
 	shopFloorParsedID, err := uuid.Parse(request.ShopFloorID)
@@ -86,28 +89,31 @@ func (s *service) Create(ctx context.Context, request JobRequest) (Job, error) {
 		ProductCode: request.ProductCode,
 		Description: request.Description,
 		EstimatedDuration: request.EstimatedDuration,
+		DueDate:     request.DueDate,
+		Priority:    request.Priority,
+		CustomerOrderRef: request.CustomerOrderRef,
+		IdealCycleSeconds: request.IdealCycleSeconds,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	return s.repository.Create(ctx, job)
+	if err := s.checkReferences(ctx, job); err != nil {
+		return Job{}, err
+	}
+	created, err := s.repository.Create(ctx, job, outbox.NewEvent(customerID, outbox.EventJobCreated, job))
+	if err != nil {
+		return Job{}, err
+	}
+	s.audit.Created(ctx, audit.EntityJob, created.ID, created.CustomerID, created)
+	return created, nil
 }
 
-func(s *service) FindAll(ctx context.Context) ([]Job, error) {
-	isAdminVal := ctx.Value("is_admin")
-	isAdmin, ok := isAdminVal.(bool)
-	if !ok {
-		return nil, errors.New("invalid or missing is_admin in context")
-	}
-	if isAdmin{
-		return s.repository.FindAll(ctx)
+// List returns a page of the jobs of the tenant, every tenant for admins
+func(s *service) List(ctx context.Context, params listing.Params) ([]Job, int, error) {
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, 0, err
 	}
-	customerIDVal := ctx.Value("customer_id")
-		customerIDFromCtx, ok := customerIDVal.(uuid.UUID)
-		if !ok {
-			return nil, errors.New("invalid or missing customer_id in context")
-		}
-		customerID := customerIDFromCtx
-	return s.repository.FindByCustomerID(ctx, customerID)
+	return s.repository.List(ctx, scope, params)
 }
 
 func(s *service) FindByID(ctx context.Context, id string) (Job, error) {
@@ -115,39 +121,89 @@ func(s *service) FindByID(ctx context.Context, id string) (Job, error) {
 	if err != nil {
 		return Job{}, err
 	}
-	return s.repository.FindByID(ctx, jobParsedID)
+	job, err := s.repository.FindByID(ctx, jobParsedID)
+	if err != nil {
+		return Job{}, err
+	}
+	if err := tenant.Check(ctx, job.CustomerID); err != nil {
+		return Job{}, err
+	}
+	if err := shopfloors.Check(ctx, job.ShopFloorID); err != nil {
+		return Job{}, err
+	}
+	return job, nil
 }
 
-func(s *service) FindByWorkcenterID(ctx context.Context, workcenterId string) ([]Job, error) {	
+func(s *service) FindByWorkcenterID(ctx context.Context, workcenterId string, params listing.Params) ([]Job, int, error) {	
 	workcenterParsedID, err := uuid.Parse(workcenterId)
 	if err != nil {
-		return []Job{}, err
+		return nil, 0, err
 	}
-	return s.repository.FindByWorkcenterID(ctx, workcenterParsedID)
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repository.FindByWorkcenterID(ctx, workcenterParsedID, scope, params)
 }
 
-func (s *service) FindByCustomerID(ctx context.Context, customerID string) ([]Job, error) {
-	parsedID, err := uuid.Parse(customerID)
+func (s *service) FindByCustomerID(ctx context.Context, customerID string, params listing.Params) ([]Job, int, error) {
+	parsedID, err := tenant.Customer(ctx, customerID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return s.repository.FindByCustomerID(ctx, parsedID)
+	return s.repository.FindByCustomerID(ctx, parsedID, params)
 }
 
-func(s *service) FindByShopFloorID(ctx context.Context, shopFloorID string) ([]Job, error) {
+func(s *service) FindByShopFloorID(ctx context.Context, shopFloorID string, params listing.Params) ([]Job, int, error) {
 	shopFloorParsedID, err := uuid.Parse(shopFloorID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, 0, err
 	}
-	return s.repository.FindByShopFloorID(ctx, shopFloorParsedID)
+	return s.repository.FindByShopFloorID(ctx, shopFloorParsedID, scope, params)
 }
 
-func(s *service) Update(ctx context.Context, id string, request JobRequest) (Job, error) {
-	jobParsedID, err := uuid.Parse(id)
+// Lateness reports the jobs that will miss their due date as currently planned,
+// and the unplanned jobs whose due date falls within horizonDays from now.
+func(s *service) Lateness(ctx context.Context, customerID string, horizonDays int) (LatenessReport, error) {
+	// Admins see every tenant unless they ask for one
+	scope, err := tenant.Scope(ctx, customerID)
 	if err != nil {
-		return Job{}, err
+		return LatenessReport{}, err
+	}
+
+	if horizonDays <= 0 {
+		horizonDays = DefaultLatenessHorizonDays
 	}
-	customerParsedID, err := uuid.Parse(request.CustomerID)
+
+	late, err := s.repository.FindLate(ctx, scope)
+	if err != nil {
+		return LatenessReport{}, err
+	}
+	for i := range late {
+		late[i].DelayMinutes = int(late[i].LastScheduledEnd.Sub(*late[i].DueDate).Minutes())
+	}
+
+	limit := time.Now().AddDate(0, 0, horizonDays)
+	unscheduled, err := s.repository.FindUnscheduledDueBefore(ctx, scope, limit)
+	if err != nil {
+		return LatenessReport{}, err
+	}
+
+	if late == nil {
+		late = []LateJob{}
+	}
+	if unscheduled == nil {
+		unscheduled = []Job{}
+	}
+	return LatenessReport{Late: late, Unscheduled: unscheduled, HorizonDays: horizonDays}, nil
+}
+
+func(s *service) Update(ctx context.Context, id string, request JobRequest) (Job, error) {
+	customerParsedID, err := tenant.Customer(ctx, request.CustomerID)
 	if err != nil {
 		return Job{}, err
 	}
@@ -159,10 +215,11 @@ func(s *service) Update(ctx context.Context, id string, request JobRequest) (Job
 	if err != nil {
 		return Job{}, err
 	}
-	job, err := s.repository.FindByID(ctx, jobParsedID)
+	job, err := s.FindByID(ctx, id)
 	if err != nil {
 		return Job{}, err
 	}	
+	before := job
 	job.CustomerID = customerParsedID
 	job.ShopFloorID = shopFloorParsedID
 	job.WorkcenterID = workcenterParsedID
@@ -170,15 +227,275 @@ func(s *service) Update(ctx context.Context, id string, request JobRequest) (Job
 	job.ProductCode = request.ProductCode
 	job.Description = request.Description
 	job.EstimatedDuration = request.EstimatedDuration
+	job.DueDate = request.DueDate
+	job.Priority = request.Priority
+	job.CustomerOrderRef = request.CustomerOrderRef
+	job.IdealCycleSeconds = request.IdealCycleSeconds
 	job.UpdatedAt = time.Now()
-	return s.repository.Update(ctx, job)
+	if err := s.checkReferences(ctx, job); err != nil {
+		return Job{}, err
+	}
+	updated, err := s.repository.Update(ctx, job)
+	if err != nil {
+		return Job{}, err
+	}
+	s.audit.Updated(ctx, audit.EntityJob, updated.ID, updated.CustomerID, before, updated)
+	return updated, nil
 }
 
-func(s *service) Delete(ctx context.Context, id string) error {
-	jobParsedID, err := uuid.Parse(id)
+// checkReferences checks that the shop floor and the workcenter of a job
+// belong to its customer and that the workcenter is on the shop floor. Shop
+// floors out of the reach of the user look missing.
+func (s *service) checkReferences(ctx context.Context, job Job) error {
+	var invalid validation.Errors
+	shopFloorCustomerID, err := s.repository.FindShopFloorCustomerID(ctx, job.ShopFloorID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || (err == nil && !shopfloors.Permits(ctx, job.ShopFloorID)):
+		invalid.Add("shop_floor_id", "unknown_shopfloor", "shop floor not found")
+	case err != nil:
+		return err
+	case shopFloorCustomerID != job.CustomerID:
+		invalid.Add("shop_floor_id", "foreign_shopfloor", "shop floor belongs to another customer")
+	}
+	workcenterCustomerID, err := s.repository.FindWorkcenterCustomerID(ctx, job.WorkcenterID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		invalid.Add("workcenter_id", "unknown_workcenter", "workcenter not found")
+	case err != nil:
+		return err
+	case workcenterCustomerID != job.CustomerID:
+		invalid.Add("workcenter_id", "foreign_workcenter", "workcenter belongs to another customer")
+	}
+	if len(invalid) > 0 {
+		return invalid.Err()
+	}
+
+	ok, err := s.repository.WorkcenterInShopFloor(ctx, job.WorkcenterID, job.ShopFloorID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return apperr.Field("workcenter_id", "workcenter_not_in_shopfloor", "workcenter is not on this shop floor")
+	}
+	return nil
+}
+
+// Delete moves the job and its schedule entries to the trash. Jobs that are
+// planned or have production reported are only deleted once confirmed.
+func(s *service) Delete(ctx context.Context, id string, confirmed bool) error {
+	job, err := s.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = s.repository.Delete(ctx, job.ID, func(preview trash.Preview) error {
+		return trash.Confirm(preview, confirmed)
+	})
 	if err != nil {
 		return err
 	}
-	return s.repository.Delete(ctx, jobParsedID)
+	s.audit.Deleted(ctx, audit.EntityJob, job.ID, job.CustomerID, job)
+	return nil
+}
+
+// Trash returns the deleted jobs of the tenant
+func (s *service) Trash(ctx context.Context, customerID string, params listing.Params) ([]Job, int, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repository.FindDeleted(ctx, scope, params)
 }
 
+func (s *service) Restore(ctx context.Context, id string) (Job, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Job{}, err
+	}
+	job, err := s.repository.FindDeletedByID(ctx, parsedID)
+	if err != nil {
+		return Job{}, err
+	}
+	if err := tenant.Check(ctx, job.CustomerID); err != nil {
+		return Job{}, err
+	}
+	if err := shopfloors.Check(ctx, job.ShopFloorID); err != nil {
+		return Job{}, err
+	}
+	customer, err := s.customerService.FindByID(ctx, job.CustomerID.String())
+	if err != nil {
+		return Job{}, err
+	}
+	count, err := s.repository.CountByCustomerID(ctx, job.CustomerID)
+	if err != nil {
+		return Job{}, err
+	}
+	if count >= customer.MaxJobs {
+		return Job{}, apperr.QuotaExceeded("max_jobs", "max jobs limit reached for this customer")
+	}
+	if err := s.repository.Restore(ctx, job.ID); err != nil {
+		return Job{}, err
+	}
+	job.DeletedAt = nil
+	s.audit.Restored(ctx, audit.EntityJob, job.ID, job.CustomerID, job)
+	return job, nil
+}
+
+// Import creates or updates jobs from an ERP export. Rows are matched to existing jobs by job code,
+// and shopfloors and workcenters are resolved by name within the customer.
+// Nothing is written when the request is a dry run or when any row is invalid.
+func(s *service) Import(ctx context.Context, request ImportRequest) (ImportResult, error) {
+	customerID, err := tenant.Customer(ctx, request.CustomerID)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	records, err := readImportFile(request.FileName, request.Content, request.Mapping)
+	if err != nil {
+		return ImportResult{}, ErrInvalidImportFile.Explain(err)
+	}
+
+	customer, err := s.customerService.FindByID(ctx, customerID.String())
+	if err != nil {
+		return ImportResult{}, err
+	}
+	existing, err := s.repository.FindCodesByCustomerID(ctx, customerID)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	count, err := s.repository.CountByCustomerID(ctx, customerID)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	shopFloors, err := s.repository.FindShopFloorNames(ctx, customerID)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	workcenters, err := s.repository.FindWorkcenterNames(ctx, customerID)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	result := ImportResult{DryRun: request.DryRun, TotalRows: len(records), Errors: []ImportRowError{}}
+	var creates, updates []Job
+	seen := map[string]int{}
+	now := time.Now()
+
+	for _, record := range records {
+		rowErrors := []ImportRowError{}
+		fail := func(field, message string) {
+			rowErrors = append(rowErrors, ImportRowError{Row: record.Line, Field: field, Message: message})
+		}
+		values := record.Values
+
+		jobCode := values[ImportFieldJobCode]
+		if jobCode == "" {
+			fail(ImportFieldJobCode, "job code is required")
+		} else if line, dup := seen[jobCode]; dup {
+			fail(ImportFieldJobCode, fmt.Sprintf("duplicated job code, already present on row %d", line))
+		} else if job, exists := existing[jobCode]; exists && !shopfloors.Permits(ctx, job.ShopFloorID) {
+			fail(ImportFieldJobCode, "job code is used by a job of a shopfloor you can't access")
+		} else {
+			seen[jobCode] = record.Line
+		}
+
+		shopFloorID, ok := shopFloors[strings.ToLower(values[ImportFieldShopFloor])]
+		if !ok || !shopfloors.Permits(ctx, shopFloorID) {
+			fail(ImportFieldShopFloor, fmt.Sprintf("unknown shopfloor %q", values[ImportFieldShopFloor]))
+		}
+
+		var workcenterID uuid.UUID
+		candidates := workcenters[strings.ToLower(values[ImportFieldWorkcenter])]
+		for _, candidate := range candidates {
+			if !candidate.ShopFloorID.Valid || candidate.ShopFloorID.UUID == shopFloorID {
+				workcenterID = candidate.ID
+				break
+			}
+		}
+		if workcenterID == uuid.Nil {
+			if len(candidates) == 0 {
+				fail(ImportFieldWorkcenter, fmt.Sprintf("unknown workcenter %q", values[ImportFieldWorkcenter]))
+			} else {
+				fail(ImportFieldWorkcenter, fmt.Sprintf("workcenter %q does not belong to shopfloor %q", values[ImportFieldWorkcenter], values[ImportFieldShopFloor]))
+			}
+		}
+
+		var estimatedDuration, priority int
+		if v := values[ImportFieldEstimatedDuration]; v != "" {
+			if estimatedDuration, err = parseImportInt(v); err != nil || estimatedDuration < 0 {
+				fail(ImportFieldEstimatedDuration, fmt.Sprintf("invalid estimated duration %q", v))
+			}
+		}
+		if v := values[ImportFieldPriority]; v != "" {
+			if priority, err = parseImportInt(v); err != nil {
+				fail(ImportFieldPriority, err.Error())
+			}
+		}
+		var dueDate *time.Time
+		if v := values[ImportFieldDueDate]; v != "" {
+			parsed, err := parseImportDate(v)
+			if err != nil {
+				fail(ImportFieldDueDate, err.Error())
+			} else {
+				dueDate = &parsed
+			}
+		}
+
+		if len(rowErrors) > 0 {
+			result.Errors = append(result.Errors, rowErrors...)
+			continue
+		}
+
+		job, exists := existing[jobCode]
+		if !exists {
+			job = Job{ID: uuid.New(), CustomerID: customerID, JobCode: jobCode, CreatedAt: now}
+		}
+		job.ShopFloorID = shopFloorID
+		job.WorkcenterID = workcenterID
+		job.ProductCode = values[ImportFieldProductCode]
+		job.Description = values[ImportFieldDescription]
+		job.EstimatedDuration = estimatedDuration
+		job.DueDate = dueDate
+		job.Priority = priority
+		job.CustomerOrderRef = values[ImportFieldCustomerOrderRef]
+		job.UpdatedAt = now
+		if exists {
+			updates = append(updates, job)
+		} else {
+			creates = append(creates, job)
+		}
+	}
+
+	result.Created = len(creates)
+	result.Updated = len(updates)
+
+	if count+len(creates) > customer.MaxJobs {
+		result.Errors = append(result.Errors, ImportRowError{
+			Message: fmt.Sprintf("import would create %d jobs but only %d are left for this customer", len(creates), max(customer.MaxJobs-count, 0)),
+		})
+	}
+
+	if len(result.Errors) > 0 {
+		if request.DryRun {
+			return result, nil
+		}
+		return result, ErrImportRejected
+	}
+	if request.DryRun {
+		return result, nil
+	}
+
+	events := make([]outbox.Event, 0, len(creates))
+	for _, job := range creates {
+		events = append(events, outbox.NewEvent(customerID, outbox.EventJobCreated, job))
+	}
+	if err := s.repository.Import(ctx, creates, updates, events...); err != nil {
+		return ImportResult{}, err
+	}
+	for _, job := range creates {
+		s.audit.Created(ctx, audit.EntityJob, job.ID, job.CustomerID, job)
+	}
+	for _, job := range updates {
+		s.audit.Updated(ctx, audit.EntityJob, job.ID, job.CustomerID, existing[job.JobCode], job)
+	}
+	return result, nil
+}
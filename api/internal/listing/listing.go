@@ -0,0 +1,321 @@
+// Package listing is the query layer of the list endpoints. Parse reads the
+// page, the order and the filters from the query string, Query turns them
+// into SQL and Find runs it, so every repository pages, sorts and filters
+// the same way.
+//
+//	?limit=50&cursor=...           page size and the next_cursor of the last page
+//	?offset=100 or ?page=3         offset pagination, page counts from 1
+//	?sort=-due_date,job_code       fields to sort on, - for descending
+//	?status=active,paused          filter on a field, several values match any
+//	?q=press                       text search
+package listing
+
+import (
+	"api/internal/apperr"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultLimit is the page size when the request doesn't ask for one
+	DefaultLimit = 100
+	// MaxLimit is the largest page a request can ask for
+	MaxLimit = 1000
+)
+
+// Order sorts on a field of the list
+type Order struct {
+	Field string
+	Desc  bool
+}
+
+// Params are the page, the order and the filters a request asks for
+type Params struct {
+	Limit   int
+	Offset  int
+	Sort    []Order
+	Filters map[string][]string
+	Search  string
+}
+
+// Spec is what a list supports. Sort and Filter map the fields of the API
+// to SQL expressions, Search holds the text columns q looks into and Key
+// breaks ties so pages never overlap ("id" when empty).
+type Spec struct {
+	Sort    map[string]string
+	Filter  map[string]string
+	Search  []string
+	Default []Order
+	Key     string
+}
+
+// Page is the part of the response that tells where the page is
+type Page struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Parse reads the params of a list request. sort_by and sort_desc, search
+// and page_size are understood too, as the web app sends them.
+func Parse(c *gin.Context, spec Spec) (Params, error) {
+	params := Params{Limit: DefaultLimit, Filters: map[string][]string{}}
+
+	limit := c.Query("limit")
+	if limit == "" {
+		limit = c.Query("page_size")
+	}
+	if limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 {
+			return Params{}, apperr.Validation("invalid_limit", "limit must be a positive integer")
+		}
+		params.Limit = min(parsed, MaxLimit)
+	}
+
+	switch {
+	case c.Query("cursor") != "":
+		offset, err := decodeCursor(c.Query("cursor"))
+		if err != nil {
+			return Params{}, apperr.Validation("invalid_cursor", "cursor is not valid")
+		}
+		params.Offset = offset
+	case c.Query("offset") != "":
+		offset, err := strconv.Atoi(c.Query("offset"))
+		if err != nil || offset < 0 {
+			return Params{}, apperr.Validation("invalid_offset", "offset must be a non-negative integer")
+		}
+		params.Offset = offset
+	case c.Query("page") != "":
+		page, err := strconv.Atoi(c.Query("page"))
+		if err != nil || page < 1 {
+			return Params{}, apperr.Validation("invalid_page", "page must be a positive integer")
+		}
+		params.Offset = (page - 1) * params.Limit
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		for _, field := range strings.Split(sort, ",") {
+			order := Order{Field: strings.TrimSpace(field)}
+			if strings.HasPrefix(order.Field, "-") {
+				order.Field, order.Desc = order.Field[1:], true
+			}
+			if _, ok := spec.Sort[order.Field]; !ok {
+				return Params{}, apperr.Validation("invalid_sort", "can't sort on "+strconv.Quote(order.Field))
+			}
+			params.Sort = append(params.Sort, order)
+		}
+	} else if field := c.Query("sort_by"); field != "" {
+		// The tables of the web app ask for any column, the ones that can't
+		// be sorted keep the default order
+		if _, ok := spec.Sort[field]; ok {
+			desc, _ := strconv.ParseBool(c.Query("sort_desc"))
+			params.Sort = []Order{{Field: field, Desc: desc}}
+		}
+	}
+	if len(params.Sort) == 0 {
+		params.Sort = spec.Default
+	}
+
+	for field := range spec.Filter {
+		for _, value := range c.QueryArray(field) {
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					params.Filters[field] = append(params.Filters[field], v)
+				}
+			}
+		}
+	}
+
+	params.Search = strings.TrimSpace(c.Query("q"))
+	if params.Search == "" {
+		params.Search = strings.TrimSpace(c.Query("search"))
+	}
+	return params, nil
+}
+
+// Filter returns the values the request filters field on, for services that
+// check them before they reach the query
+func (p Params) Filter(field string) []string {
+	return p.Filters[field]
+}
+
+// Page returns where the page is in a list of total rows
+func (p Params) Page(total int) Page {
+	page := Page{Total: total, Limit: p.Limit, Offset: p.Offset}
+	if next := p.Offset + p.Limit; next < total {
+		page.NextCursor = encodeCursor(next)
+	}
+	return page
+}
+
+// Cursors are opaque to clients; today they hold the offset of the next page
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := strings.CutPrefix(string(raw), "o:")
+	if !ok {
+		return 0, fmt.Errorf("unknown cursor %q", cursor)
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("unknown cursor %q", cursor)
+	}
+	return offset, nil
+}
+
+// Query builds the SQL of a list: the conditions of the repository, then
+// the filters and the search of the request, the order and the page
+type Query struct {
+	spec       Spec
+	params     Params
+	conditions []string
+	args       []interface{}
+}
+
+// NewQuery starts a query with the filters and the search of params
+func NewQuery(spec Spec, params Params) *Query {
+	q := &Query{spec: spec, params: params}
+	for field, values := range params.Filters {
+		expression, ok := spec.Filter[field]
+		if !ok || len(values) == 0 {
+			continue
+		}
+		placeholders := make([]string, len(values))
+		args := make([]interface{}, len(values))
+		for i, value := range values {
+			placeholders[i] = "?"
+			args[i] = value
+		}
+		q.Where(fmt.Sprintf("%s IN (%s)", expression, strings.Join(placeholders, ", ")), args...)
+	}
+	if params.Search != "" && len(spec.Search) > 0 {
+		pattern := "%" + escapeLike(params.Search) + "%"
+		matches := make([]string, len(spec.Search))
+		args := make([]interface{}, len(spec.Search))
+		for i, column := range spec.Search {
+			matches[i] = column + " ILIKE ?"
+			args[i] = pattern
+		}
+		q.Where("("+strings.Join(matches, " OR ")+")", args...)
+	}
+	return q
+}
+
+// Where adds a condition, with ? standing for each of args in turn
+func (q *Query) Where(condition string, args ...interface{}) {
+	q.conditions = append(q.conditions, condition)
+	q.args = append(q.args, args...)
+}
+
+// SQL returns base, a SELECT without WHERE, with the conditions, the order
+// and the page, and its arguments
+func (q *Query) SQL(base string) (string, []interface{}) {
+	var query strings.Builder
+	query.WriteString(base)
+	query.WriteString(q.where())
+
+	key := q.spec.Key
+	if key == "" {
+		key = "id"
+	}
+	orders := []string{}
+	for _, order := range q.params.Sort {
+		expression, ok := q.spec.Sort[order.Field]
+		if !ok {
+			continue
+		}
+		direction := "ASC"
+		if order.Desc {
+			direction = "DESC"
+		}
+		orders = append(orders, expression+" "+direction+" NULLS LAST")
+	}
+	orders = append(orders, key+" ASC")
+	query.WriteString(" ORDER BY " + strings.Join(orders, ", "))
+
+	args := append([]interface{}{}, q.args...)
+	args = append(args, q.params.Limit, q.params.Offset)
+	query.WriteString(" LIMIT ? OFFSET ?")
+	return numbered(query.String()), args
+}
+
+// CountSQL returns the query that counts every row of base with the
+// conditions, and its arguments
+func (q *Query) CountSQL(base string) (string, []interface{}) {
+	return numbered("SELECT COUNT(*) FROM (" + base + q.where() + ") AS counted"), q.args
+}
+
+func (q *Query) where() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// numbered turns the ? of a query into $1, $2...
+func numbered(query string) string {
+	var out strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			out.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// Querier is what Find needs of *sql.DB
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Find runs the query on base and returns the page of rows, read with scan,
+// and the total of rows of every page
+func Find[T any](ctx context.Context, db Querier, q *Query, base string, scan func(*sql.Rows) (T, error)) ([]T, int, error) {
+	countQuery, countArgs := q.CountSQL(base)
+	var total int
+	if err := db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query, args := q.SQL(base)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
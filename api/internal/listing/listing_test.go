@@ -0,0 +1,129 @@
+package listing
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testSpec = Spec{
+	Sort: map[string]string{
+		"due_date": "due_date",
+		"order":    `"order"`,
+	},
+	Filter: map[string]string{
+		"status": "status",
+	},
+	Search:  []string{"code", "name"},
+	Default: []Order{{Field: "due_date"}},
+}
+
+func parse(t *testing.T, query url.Values) (Params, error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/list?"+query.Encode(), nil)
+	return Parse(c, testSpec)
+}
+
+// TestCursor makes sure the cursor of a page leads to the next one and that
+// cursors not made by the API are refused
+func TestCursor(t *testing.T) {
+	for _, offset := range []int{0, 1, 100, 123456} {
+		decoded, err := decodeCursor(encodeCursor(offset))
+		if err != nil || decoded != offset {
+			t.Errorf("cursor of offset %d decoded to %d, %v", offset, decoded, err)
+		}
+	}
+
+	page := Params{Limit: 50, Offset: 100}.Page(200)
+	params, err := parse(t, url.Values{"limit": {"50"}, "cursor": {page.NextCursor}})
+	if err != nil {
+		t.Fatalf("parsing the next cursor: %v", err)
+	}
+	if params.Offset != 150 {
+		t.Errorf("next cursor leads to offset %d, want 150", params.Offset)
+	}
+	if last := (Params{Limit: 50, Offset: 150}).Page(200); last.NextCursor != "" {
+		t.Errorf("last page has next cursor %q", last.NextCursor)
+	}
+
+	for _, cursor := range []string{"not base64!", encode("x:10"), encode("o:-1"), encode("o:ten")} {
+		if _, err := parse(t, url.Values{"cursor": {cursor}}); err == nil {
+			t.Errorf("cursor %q was accepted", cursor)
+		}
+	}
+}
+
+func encode(raw string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// TestSortWhitelist makes sure only the fields of the spec can be sorted on
+func TestSortWhitelist(t *testing.T) {
+	params, err := parse(t, url.Values{"sort": {"-due_date,order"}})
+	if err != nil {
+		t.Fatalf("sorting on whitelisted fields: %v", err)
+	}
+	want := []Order{{Field: "due_date", Desc: true}, {Field: "order"}}
+	if !reflect.DeepEqual(params.Sort, want) {
+		t.Errorf("sort = %v, want %v", params.Sort, want)
+	}
+
+	for _, sort := range []string{"password", "-due_date,id;DROP TABLE jobs", "status"} {
+		if _, err := parse(t, url.Values{"sort": {sort}}); err == nil {
+			t.Errorf("sort %q was accepted", sort)
+		}
+	}
+
+	// The tables of the web app fall back to the default order
+	params, err = parse(t, url.Values{"sort_by": {"password"}, "sort_desc": {"true"}})
+	if err != nil {
+		t.Fatalf("sort_by on an unknown field: %v", err)
+	}
+	if !reflect.DeepEqual(params.Sort, testSpec.Default) {
+		t.Errorf("sort_by on an unknown field sorts on %v, want the default", params.Sort)
+	}
+
+	q := NewQuery(testSpec, Params{Limit: 10, Sort: []Order{{Field: "secret"}, {Field: "order", Desc: true}}})
+	query, _ := q.SQL("SELECT id FROM jobs")
+	if want := `SELECT id FROM jobs ORDER BY "order" DESC NULLS LAST, id ASC LIMIT $1 OFFSET $2`; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+}
+
+// TestNumbered makes sure the ? of the conditions, the filters, the search
+// and the page are numbered in the order of their arguments
+func TestNumbered(t *testing.T) {
+	if got := numbered("a = ? AND b IN (?, ?)"); got != "a = $1 AND b IN ($2, $3)" {
+		t.Errorf("numbered = %q", got)
+	}
+
+	params := Params{Limit: 20, Offset: 40, Sort: testSpec.Default, Filters: map[string][]string{"status": {"open", "late"}}, Search: "50%"}
+	q := NewQuery(testSpec, params)
+	q.Where("deleted_at IS NULL")
+	q.Where("customer_id = ?", "tenant")
+
+	query, args := q.SQL("SELECT id FROM jobs")
+	wantQuery := `SELECT id FROM jobs WHERE status IN ($1, $2) AND (code ILIKE $3 OR name ILIKE $4) AND deleted_at IS NULL AND customer_id = $5 ORDER BY due_date ASC NULLS LAST, id ASC LIMIT $6 OFFSET $7`
+	wantArgs := []interface{}{"open", "late", `%50\%%`, `%50\%%`, "tenant", 20, 40}
+	if query != wantQuery {
+		t.Errorf("query = %q, want %q", query, wantQuery)
+	}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+
+	count, countArgs := q.CountSQL("SELECT id FROM jobs")
+	wantCount := `SELECT COUNT(*) FROM (SELECT id FROM jobs WHERE status IN ($1, $2) AND (code ILIKE $3 OR name ILIKE $4) AND deleted_at IS NULL AND customer_id = $5) AS counted`
+	if count != wantCount {
+		t.Errorf("count query = %q, want %q", count, wantCount)
+	}
+	if !reflect.DeepEqual(countArgs, wantArgs[:5]) {
+		t.Errorf("count args = %v, want %v", countArgs, wantArgs[:5])
+	}
+}
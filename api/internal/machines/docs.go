@@ -0,0 +1,17 @@
+package machines
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "GET", Path: "/api/machine-signals/events", Tag: "machines", Summary: "Latest machine events, newest first", Response: []MachineEvent{}, Query: []openapi.Param{
+		openapi.CustomerID,
+		{Name: "workcenter_id"},
+		{Name: "limit", Type: "integer"},
+	}},
+	{Method: "POST", Path: "/api/machine-signals", Tag: "machines", Summary: "Map a machine signal to a workcenter", Request: MachineSignalRequest{}, Response: MachineSignal{}, Status: 201},
+	{Method: "GET", Path: "/api/machine-signals", Tag: "machines", Summary: "List machine signals", Response: []MachineSignal{}, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "GET", Path: "/api/machine-signals/:id", Tag: "machines", Summary: "Get a machine signal", Response: MachineSignal{}},
+	{Method: "PUT", Path: "/api/machine-signals/:id", Tag: "machines", Summary: "Update a machine signal", Request: MachineSignalRequest{}, Response: MachineSignal{}},
+	{Method: "DELETE", Path: "/api/machine-signals/:id", Tag: "machines", Summary: "Delete a machine signal"},
+}
@@ -0,0 +1,109 @@
+package machines
+
+import (
+	"api/internal/apperr"
+	"api/internal/tenant"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request MachineSignalRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.Create(ctx, request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Machine signal created successfully", "data": response})
+}
+
+func (h *Handler) FindAll(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.FindAll(ctx, c.Query("customer_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Machine signals found successfully", "data": response})
+}
+
+func (h *Handler) FindByID(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.FindByID(ctx, c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("machine_signal_not_found", "Machine signal not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Machine signal found successfully", "data": response})
+}
+
+func (h *Handler) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request MachineSignalRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.Update(ctx, c.Param("id"), request)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("machine_signal_not_found", "Machine signal not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Machine signal updated successfully", "data": response})
+}
+
+func (h *Handler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.Delete(ctx, c.Param("id")); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("machine_signal_not_found", "Machine signal not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Machine signal deleted successfully"})
+}
+
+// FindEvents returns the latest machine events, newest first
+func (h *Handler) FindEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	limit := MaxEvents
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			c.Error(apperr.Validation("invalid_request", "invalid limit"))
+			return
+		}
+		limit = parsed
+	}
+	response, err := h.service.FindEvents(ctx, c.Query("customer_id"), c.Query("workcenter_id"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Machine events found successfully", "data": response})
+}
@@ -0,0 +1,252 @@
+package machines
+
+import (
+	"api/config"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+)
+
+// Ingestor subscribes to the MQTT broker and turns the messages of the
+// configured machine signals into machine events, downtimes and production
+// counts. Topics without an active signal are ignored.
+type Ingestor struct {
+	repo Repository
+	cfg  config.Config
+
+	mu      sync.RWMutex
+	signals map[string]MachineSignal
+	// last known state per workcenter, so repeated messages are not recorded
+	states map[uuid.UUID]string
+}
+
+func NewIngestor(repo Repository, cfg config.Config) *Ingestor {
+	return &Ingestor{
+		repo:    repo,
+		cfg:     cfg,
+		signals: map[string]MachineSignal{},
+		states:  map[uuid.UUID]string{},
+	}
+}
+
+// Run connects to the broker and ingests messages until ctx is cancelled
+func (i *Ingestor) Run(ctx context.Context) error {
+	if err := i.refresh(ctx); err != nil {
+		return err
+	}
+	stopped, err := i.repo.FindStoppedWorkcenters(ctx)
+	if err != nil {
+		return err
+	}
+	for _, workcenterID := range stopped {
+		i.states[workcenterID] = StateStop
+	}
+
+	options := mqtt.NewClientOptions().
+		AddBroker(i.cfg.MQTT.BrokerURL).
+		SetClientID(i.cfg.MQTT.ClientID).
+		SetUsername(i.cfg.MQTT.Username).
+		SetPassword(i.cfg.MQTT.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetCleanSession(false)
+	// Subscriptions are made on every (re)connection
+	options.SetOnConnectHandler(func(client mqtt.Client) {
+		for _, topic := range i.cfg.MQTT.Topics {
+			token := client.Subscribe(topic, i.cfg.MQTT.QoS, func(_ mqtt.Client, message mqtt.Message) {
+				i.handle(ctx, message.Topic(), message.Payload())
+			})
+			if token.Wait() && token.Error() != nil {
+				slog.Error("Failed to subscribe to MQTT topic", slog.String("topic", topic), slog.Any("error", token.Error()))
+				continue
+			}
+			slog.Info("Subscribed to MQTT topic", slog.String("topic", topic))
+		}
+	})
+	options.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		slog.Warn("MQTT connection lost", slog.Any("error", err))
+	})
+
+	client := mqtt.NewClient(options)
+	client.Connect()
+	slog.Info("MQTT ingestor started", slog.String("broker", i.cfg.MQTT.BrokerURL), slog.Any("topics", i.cfg.MQTT.Topics))
+
+	ticker := time.NewTicker(i.cfg.MQTT.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			client.Disconnect(250)
+			slog.Info("MQTT ingestor stopped")
+			return nil
+		case <-ticker.C:
+			if err := i.refresh(ctx); err != nil {
+				slog.Error("Failed to refresh machine signals", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// refresh reloads the topic to signal map, so signals configured through the
+// API are picked up without a restart
+func (i *Ingestor) refresh(ctx context.Context) error {
+	active, err := i.repo.FindActive(ctx)
+	if err != nil {
+		return err
+	}
+	signals := make(map[string]MachineSignal, len(active))
+	for _, signal := range active {
+		signals[signal.Topic] = signal
+	}
+	i.mu.Lock()
+	i.signals = signals
+	i.mu.Unlock()
+	return nil
+}
+
+func (i *Ingestor) handle(ctx context.Context, topic string, payload []byte) {
+	i.mu.RLock()
+	signal, ok := i.signals[topic]
+	i.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	event := MachineEvent{
+		ID:           uuid.New(),
+		CustomerID:   signal.CustomerID,
+		WorkcenterID: signal.WorkcenterID,
+		SignalID:     uuid.NullUUID{UUID: signal.ID, Valid: true},
+		Signal:       signal.Signal,
+		Payload:      string(payload),
+	}
+
+	var err error
+	switch signal.Signal {
+	case SignalState:
+		var state string
+		state, event.ReceivedAt, err = parseState(payload)
+		if err != nil {
+			break
+		}
+		i.mu.Lock()
+		unchanged := i.states[signal.WorkcenterID] == state
+		i.mu.Unlock()
+		if unchanged {
+			return
+		}
+		event.State = state
+		if err = i.repo.RecordState(ctx, event); err == nil {
+			i.mu.Lock()
+			i.states[signal.WorkcenterID] = state
+			i.mu.Unlock()
+		}
+	case SignalCount, SignalScrap:
+		var good, scrap int
+		good, scrap, event.ReceivedAt, err = parseCount(signal.Signal, payload)
+		if err != nil {
+			break
+		}
+		if good+scrap == 0 {
+			return
+		}
+		event.Quantity = good + scrap
+		err = i.repo.RecordCount(ctx, event, good, scrap)
+	}
+	if err != nil {
+		slog.Error("Failed to ingest machine message",
+			slog.String("topic", topic),
+			slog.String("payload", string(payload)),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// statePayload is the JSON form of a state message: {"state":"stop","ts":"..."}
+type statePayload struct {
+	State string     `json:"state"`
+	TS    *time.Time `json:"ts"`
+}
+
+// countPayload is the JSON form of a count message: {"good":10,"scrap":1,"ts":"..."}
+type countPayload struct {
+	Good  *int       `json:"good"`
+	Scrap *int       `json:"scrap"`
+	Count *int       `json:"count"`
+	TS    *time.Time `json:"ts"`
+}
+
+// parseState accepts a plain value (run/stop, 1/0, true/false, on/off) or
+// the JSON form. The timestamp defaults to the reception time.
+func parseState(payload []byte) (string, time.Time, error) {
+	raw := strings.TrimSpace(string(payload))
+	receivedAt := time.Now()
+	if strings.HasPrefix(raw, "{") {
+		var message statePayload
+		if err := json.Unmarshal(payload, &message); err != nil {
+			return "", time.Time{}, err
+		}
+		raw = message.State
+		if message.TS != nil {
+			receivedAt = *message.TS
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "run", "running", "1", "true", "on":
+		return StateRun, receivedAt, nil
+	case "stop", "stopped", "0", "false", "off", "idle", "fault":
+		return StateStop, receivedAt, nil
+	}
+	return "", time.Time{}, errors.New("unknown machine state " + strconv.Quote(raw))
+}
+
+// parseCount accepts a plain integer, counted as good or scrap pieces
+// depending on the signal, or the JSON form. Quantities are the pieces made
+// since the previous message, not a running total.
+func parseCount(signal string, payload []byte) (int, int, time.Time, error) {
+	raw := strings.TrimSpace(string(payload))
+	receivedAt := time.Now()
+	var good, scrap int
+	if strings.HasPrefix(raw, "{") {
+		var message countPayload
+		if err := json.Unmarshal(payload, &message); err != nil {
+			return 0, 0, time.Time{}, err
+		}
+		switch {
+		case message.Good != nil:
+			good = *message.Good
+		case message.Count != nil && signal == SignalCount:
+			good = *message.Count
+		case message.Count != nil:
+			scrap = *message.Count
+		}
+		if message.Scrap != nil {
+			scrap = *message.Scrap
+		}
+		if message.TS != nil {
+			receivedAt = *message.TS
+		}
+	} else {
+		quantity, err := strconv.Atoi(raw)
+		if err != nil {
+			return 0, 0, time.Time{}, errors.New("count must be an integer")
+		}
+		if signal == SignalScrap {
+			scrap = quantity
+		} else {
+			good = quantity
+		}
+	}
+	if good < 0 || scrap < 0 {
+		return 0, 0, time.Time{}, errors.New("count must not be negative")
+	}
+	return good, scrap, receivedAt, nil
+}
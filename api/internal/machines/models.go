@@ -0,0 +1,52 @@
+package machines
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Signal types a topic can carry
+const (
+	SignalState = "state" // run / stop
+	SignalCount = "count" // good pieces made since the previous message
+	SignalScrap = "scrap" // scrap pieces made since the previous message
+)
+
+// Machine states
+const (
+	StateRun  = "run"
+	StateStop = "stop"
+)
+
+// MachineSignal maps an MQTT topic to a workcenter
+type MachineSignal struct {
+	ID           uuid.UUID `json:"id"`
+	CustomerID   uuid.UUID `json:"customer_id"`
+	WorkcenterID uuid.UUID `json:"workcenter_id"`
+	Topic        string    `json:"topic"`
+	Signal       string    `json:"signal"`
+	IsActive     bool      `json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type MachineSignalRequest struct {
+	WorkcenterID string `json:"workcenter_id" binding:"required,uuid"`
+	Topic        string `json:"topic" binding:"required,max=255"`
+	Signal       string `json:"signal" binding:"required"`
+	IsActive     bool   `json:"is_active"`
+}
+
+// MachineEvent is a state change or a count received from a machine
+type MachineEvent struct {
+	ID           uuid.UUID     `json:"id"`
+	CustomerID   uuid.UUID     `json:"customer_id"`
+	WorkcenterID uuid.UUID     `json:"workcenter_id"`
+	SignalID     uuid.NullUUID `json:"signal_id"`
+	Signal       string        `json:"signal"`
+	State        string        `json:"state"`
+	Quantity     int           `json:"quantity"`
+	Payload      string        `json:"payload"`
+	ReceivedAt   time.Time     `json:"received_at"`
+}
@@ -0,0 +1,225 @@
+package machines
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(ctx context.Context, signal MachineSignal) (MachineSignal, error)
+	FindByID(ctx context.Context, id uuid.UUID) (MachineSignal, error)
+	FindAll(ctx context.Context, customerID *uuid.UUID) ([]MachineSignal, error)
+	FindActive(ctx context.Context) ([]MachineSignal, error)
+	Update(ctx context.Context, signal MachineSignal) (MachineSignal, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error)
+	FindEvents(ctx context.Context, customerID *uuid.UUID, workcenterID *uuid.UUID, limit int) ([]MachineEvent, error)
+	FindStoppedWorkcenters(ctx context.Context) ([]uuid.UUID, error)
+	RecordState(ctx context.Context, event MachineEvent) error
+	RecordCount(ctx context.Context, event MachineEvent, good, scrap int) error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(ctx context.Context, signal MachineSignal) (MachineSignal, error) {
+	query := `INSERT INTO machine_signals (id, customer_id, workcenter_id, topic, signal, is_active, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := r.db.ExecContext(ctx, query, signal.ID, signal.CustomerID, signal.WorkcenterID, signal.Topic, signal.Signal, signal.IsActive, signal.CreatedAt, signal.UpdatedAt)
+	if err != nil {
+		return MachineSignal{}, err
+	}
+	return signal, nil
+}
+
+func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (MachineSignal, error) {
+	query := `SELECT id, customer_id, workcenter_id, topic, signal, is_active, created_at, updated_at FROM machine_signals WHERE id = $1`
+	var signal MachineSignal
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&signal.ID, &signal.CustomerID, &signal.WorkcenterID, &signal.Topic, &signal.Signal, &signal.IsActive, &signal.CreatedAt, &signal.UpdatedAt)
+	if err != nil {
+		return MachineSignal{}, err
+	}
+	return signal, nil
+}
+
+func (r *repository) FindAll(ctx context.Context, customerID *uuid.UUID) ([]MachineSignal, error) {
+	query := `SELECT id, customer_id, workcenter_id, topic, signal, is_active, created_at, updated_at FROM machine_signals`
+	var args []interface{}
+	if customerID != nil {
+		query += " WHERE customer_id = $1"
+		args = append(args, *customerID)
+	}
+	query += " ORDER BY topic"
+	return r.findSignals(ctx, query, args...)
+}
+
+func (r *repository) FindActive(ctx context.Context) ([]MachineSignal, error) {
+	query := `SELECT id, customer_id, workcenter_id, topic, signal, is_active, created_at, updated_at FROM machine_signals WHERE is_active = TRUE`
+	return r.findSignals(ctx, query)
+}
+
+func (r *repository) findSignals(ctx context.Context, query string, args ...interface{}) ([]MachineSignal, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var signals []MachineSignal
+	for rows.Next() {
+		var signal MachineSignal
+		if err := rows.Scan(&signal.ID, &signal.CustomerID, &signal.WorkcenterID, &signal.Topic, &signal.Signal, &signal.IsActive, &signal.CreatedAt, &signal.UpdatedAt); err != nil {
+			return nil, err
+		}
+		signals = append(signals, signal)
+	}
+	return signals, nil
+}
+
+func (r *repository) Update(ctx context.Context, signal MachineSignal) (MachineSignal, error) {
+	query := `UPDATE machine_signals SET workcenter_id = $2, topic = $3, signal = $4, is_active = $5, updated_at = $6 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, signal.ID, signal.WorkcenterID, signal.Topic, signal.Signal, signal.IsActive, signal.UpdatedAt)
+	if err != nil {
+		return MachineSignal{}, err
+	}
+	return signal, nil
+}
+
+func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM machine_signals WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *repository) FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error) {
+	query := `SELECT customer_id FROM workcenters WHERE id = $1 AND deleted_at IS NULL`
+	var customerID uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, workcenterID).Scan(&customerID); err != nil {
+		return uuid.Nil, err
+	}
+	return customerID, nil
+}
+
+func (r *repository) FindEvents(ctx context.Context, customerID *uuid.UUID, workcenterID *uuid.UUID, limit int) ([]MachineEvent, error) {
+	query := `SELECT id, customer_id, workcenter_id, signal_id, signal, state, quantity, payload, received_at FROM machine_events WHERE 1=1`
+	var args []interface{}
+	argId := 1
+	if customerID != nil {
+		query += fmt.Sprintf(" AND customer_id = $%d", argId)
+		args = append(args, *customerID)
+		argId++
+	}
+	if workcenterID != nil {
+		query += fmt.Sprintf(" AND workcenter_id = $%d", argId)
+		args = append(args, *workcenterID)
+		argId++
+	}
+	query += fmt.Sprintf(" ORDER BY received_at DESC LIMIT $%d", argId)
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []MachineEvent
+	for rows.Next() {
+		var event MachineEvent
+		if err := rows.Scan(&event.ID, &event.CustomerID, &event.WorkcenterID, &event.SignalID, &event.Signal, &event.State, &event.Quantity, &event.Payload, &event.ReceivedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// FindStoppedWorkcenters returns the workcenters with a machine downtime still open
+func (r *repository) FindStoppedWorkcenters(ctx context.Context) ([]uuid.UUID, error) {
+	query := `SELECT DISTINCT workcenter_id FROM downtimes WHERE source = 'machine' AND end_time IS NULL`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func insertEvent(ctx context.Context, tx *sql.Tx, event MachineEvent) error {
+	query := `INSERT INTO machine_events (id, customer_id, workcenter_id, signal_id, signal, state, quantity, payload, received_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := tx.ExecContext(ctx, query, event.ID, event.CustomerID, event.WorkcenterID, event.SignalID, event.Signal,
+		event.State, event.Quantity, event.Payload, event.ReceivedAt)
+	return err
+}
+
+// RecordState stores a state change. A stop opens an unplanned machine
+// downtime on the workcenter and a run closes it, so downtime and OEE
+// follow the machine without anyone typing it in.
+func (r *repository) RecordState(ctx context.Context, event MachineEvent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	switch event.State {
+	case StateStop:
+		query := `INSERT INTO downtimes (id, customer_id, workcenter_id, kind, start_time, notes, source, created_at, updated_at)
+		SELECT $1, $2, $3, 'unplanned', $4, 'Machine stopped', 'machine', NOW(), NOW()
+		WHERE NOT EXISTS (SELECT 1 FROM downtimes WHERE workcenter_id = $3 AND source = 'machine' AND end_time IS NULL)`
+		if _, err := tx.ExecContext(ctx, query, uuid.New(), event.CustomerID, event.WorkcenterID, event.ReceivedAt); err != nil {
+			return err
+		}
+	case StateRun:
+		// GREATEST keeps the check constraint when messages arrive out of order
+		query := `UPDATE downtimes SET end_time = GREATEST($2, start_time + INTERVAL '1 second'), updated_at = NOW()
+		WHERE workcenter_id = $1 AND source = 'machine' AND end_time IS NULL`
+		if _, err := tx.ExecContext(ctx, query, event.WorkcenterID, event.ReceivedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RecordCount stores a count and adds it to the production counts, linked
+// to the job the workcenter is working on according to today's planning
+func (r *repository) RecordCount(ctx context.Context, event MachineEvent, good, scrap int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO production_counts (id, customer_id, workcenter_id, job_id, recorded_at, good_qty, scrap_qty, source, created_at)
+	VALUES ($1, $2, $3, (
+		SELECT job_id FROM schedule_entries
+		WHERE workcenter_id = $3 AND job_id IS NOT NULL AND is_completed = FALSE AND date::date = $4::date AND deleted_at IS NULL
+		ORDER BY "order" LIMIT 1
+	), $4, $5, $6, 'machine', NOW())`
+	if _, err := tx.ExecContext(ctx, query, uuid.New(), event.CustomerID, event.WorkcenterID, event.ReceivedAt, good, scrap); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,12 @@
+package machines
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/machine-signals/events", handler.FindEvents)
+	router.POST("/machine-signals", handler.Create)
+	router.GET("/machine-signals", handler.FindAll)
+	router.GET("/machine-signals/:id", handler.FindByID)
+	router.PUT("/machine-signals/:id", handler.Update)
+	router.DELETE("/machine-signals/:id", handler.Delete)
+}
@@ -0,0 +1,148 @@
+package machines
+
+import (
+	"api/internal/apperr"
+	"api/internal/tenant"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	Create(ctx context.Context, request MachineSignalRequest) (MachineSignal, error)
+	FindByID(ctx context.Context, id string) (MachineSignal, error)
+	FindAll(ctx context.Context, customerID string) ([]MachineSignal, error)
+	Update(ctx context.Context, id string, request MachineSignalRequest) (MachineSignal, error)
+	Delete(ctx context.Context, id string) error
+	FindEvents(ctx context.Context, customerID string, workcenterID string, limit int) ([]MachineEvent, error)
+}
+
+// MaxEvents caps the number of events returned at once
+const MaxEvents = 500
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func normalizeSignal(signal string) (string, error) {
+	signal = strings.ToLower(strings.TrimSpace(signal))
+	switch signal {
+	case SignalState, SignalCount, SignalScrap:
+		return signal, nil
+	}
+	return "", apperr.Field("signal", "invalid_signal", "signal must be state, count or scrap")
+}
+
+// apply validates a request and copies it into signal
+func (s *service) apply(ctx context.Context, signal *MachineSignal, request MachineSignalRequest) error {
+	workcenterID, err := uuid.Parse(request.WorkcenterID)
+	if err != nil {
+		return err
+	}
+	customerID, err := s.repo.FindWorkcenterCustomerID(ctx, workcenterID)
+	if err != nil {
+		return err
+	}
+	ok, err := tenant.Owns(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return apperr.NotFound("workcenter_not_found", "workcenter not found")
+	}
+	kind, err := normalizeSignal(request.Signal)
+	if err != nil {
+		return err
+	}
+	topic := strings.TrimSpace(request.Topic)
+	if topic == "" || strings.ContainsAny(topic, "+#") {
+		return apperr.Field("topic", "invalid_topic", "topic must be a concrete topic without wildcards")
+	}
+
+	signal.CustomerID = customerID
+	signal.WorkcenterID = workcenterID
+	signal.Topic = topic
+	signal.Signal = kind
+	signal.IsActive = request.IsActive
+	return nil
+}
+
+func (s *service) Create(ctx context.Context, request MachineSignalRequest) (MachineSignal, error) {
+	signal := MachineSignal{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.apply(ctx, &signal, request); err != nil {
+		return MachineSignal{}, err
+	}
+	return s.repo.Create(ctx, signal)
+}
+
+func (s *service) FindByID(ctx context.Context, id string) (MachineSignal, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return MachineSignal{}, err
+	}
+	signal, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return MachineSignal{}, err
+	}
+	if err := tenant.Check(ctx, signal.CustomerID); err != nil {
+		return MachineSignal{}, err
+	}
+	return signal, nil
+}
+
+func (s *service) FindAll(ctx context.Context, customerID string) ([]MachineSignal, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindAll(ctx, scope)
+}
+
+func (s *service) Update(ctx context.Context, id string, request MachineSignalRequest) (MachineSignal, error) {
+	signal, err := s.FindByID(ctx, id)
+	if err != nil {
+		return MachineSignal{}, err
+	}
+	if err := s.apply(ctx, &signal, request); err != nil {
+		return MachineSignal{}, err
+	}
+	signal.UpdatedAt = time.Now()
+	return s.repo.Update(ctx, signal)
+}
+
+func (s *service) Delete(ctx context.Context, id string) error {
+	signal, err := s.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, signal.ID)
+}
+
+func (s *service) FindEvents(ctx context.Context, customerID string, workcenterID string, limit int) ([]MachineEvent, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	var parsedWorkcenterID *uuid.UUID
+	if workcenterID != "" {
+		parsedID, err := uuid.Parse(workcenterID)
+		if err != nil {
+			return nil, err
+		}
+		parsedWorkcenterID = &parsedID
+	}
+	if limit <= 0 || limit > MaxEvents {
+		limit = MaxEvents
+	}
+	return s.repo.FindEvents(ctx, scope, parsedWorkcenterID, limit)
+}
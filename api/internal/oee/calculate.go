@@ -0,0 +1,191 @@
+package oee
+
+import (
+	"api/internal/shifts"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const dateLayout = "2006-01-02"
+
+// totals accumulates the raw ingredients so ratios of aggregates are
+// computed from sums, not averaged
+type totals struct {
+	planned, plannedDown, unplannedDown, run float64
+	ideal                                    float64
+	good, scrap                              int
+}
+
+func (t *totals) add(o totals) {
+	t.planned += o.planned
+	t.plannedDown += o.plannedDown
+	t.unplannedDown += o.unplannedDown
+	t.run += o.run
+	t.ideal += o.ideal
+	t.good += o.good
+	t.scrap += o.scrap
+}
+
+func (t totals) figures() Figures {
+	f := Figures{
+		PlannedMinutes:           int(math.Round(t.planned)),
+		PlannedDowntimeMinutes:   int(math.Round(t.plannedDown)),
+		UnplannedDowntimeMinutes: int(math.Round(t.unplannedDown)),
+		RunMinutes:               int(math.Round(t.run)),
+		IdealMinutes:             round(t.ideal),
+		GoodQty:                  t.good,
+		ScrapQty:                 t.scrap,
+		Quality:                  1,
+	}
+	if t.planned > 0 {
+		f.Availability = round(t.run / t.planned)
+	}
+	if t.run > 0 {
+		f.Performance = round(math.Min(t.ideal/t.run, 1))
+	}
+	if t.good+t.scrap > 0 {
+		f.Quality = round(float64(t.good) / float64(t.good+t.scrap))
+	}
+	f.OEE = round(f.Availability * f.Performance * f.Quality)
+	return f
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// overlap returns the minutes of [start, end) covered by the intervals,
+// counting time covered by several intervals only once
+func overlap(start, end time.Time, intervals []interval) float64 {
+	var clipped []interval
+	for _, i := range intervals {
+		s, e := i.Start, i.End
+		if s.Before(start) {
+			s = start
+		}
+		if e.After(end) {
+			e = end
+		}
+		if e.After(s) {
+			clipped = append(clipped, interval{Start: s, End: e})
+		}
+	}
+	sort.Slice(clipped, func(a, b int) bool { return clipped[a].Start.Before(clipped[b].Start) })
+
+	var total time.Duration
+	var cursor time.Time
+	for _, i := range clipped {
+		if i.Start.Before(cursor) {
+			i.Start = cursor
+		}
+		if i.End.After(i.Start) {
+			total += i.End.Sub(i.Start)
+			cursor = i.End
+		}
+	}
+	return total.Minutes()
+}
+
+// calculate builds the report for every day in [from, to], every active
+// workcenter and every shift that applies to it.
+//
+// For each cell:
+//   - planned time is the shift length minus planned downtime
+//   - run time is planned time minus unplanned downtime, capped by the time
+//     operators were checked in on the workcenter when there are time entries
+//   - ideal time is counted pieces times the ideal cycle time of their job, or
+//     the estimated duration of the completed schedule entries if nothing was counted
+func calculate(data dataset, from, to time.Time) Report {
+	byWorkcenter := func(intervals []interval) map[uuid.UUID][]interval {
+		grouped := map[uuid.UUID][]interval{}
+		for _, i := range intervals {
+			grouped[i.WorkcenterID] = append(grouped[i.WorkcenterID], i)
+		}
+		return grouped
+	}
+	plannedDowntimes := map[uuid.UUID][]interval{}
+	unplannedDowntimes := map[uuid.UUID][]interval{}
+	for _, downtime := range data.downtimes {
+		if downtime.Kind == "planned" {
+			plannedDowntimes[downtime.WorkcenterID] = append(plannedDowntimes[downtime.WorkcenterID], downtime)
+		} else {
+			unplannedDowntimes[downtime.WorkcenterID] = append(unplannedDowntimes[downtime.WorkcenterID], downtime)
+		}
+	}
+	timeEntries := byWorkcenter(data.timeEntries)
+	counts := map[uuid.UUID][]countRow{}
+	for _, count := range data.counts {
+		counts[count.WorkcenterID] = append(counts[count.WorkcenterID], count)
+	}
+	type cellKey struct {
+		date         string
+		shiftID      uuid.UUID
+		workcenterID uuid.UUID
+	}
+	completedMinutes := map[cellKey]float64{}
+	for _, entry := range data.completed {
+		completedMinutes[cellKey{entry.Date, entry.ShiftID, entry.WorkcenterID}] += float64(entry.EstimatedDuration)
+	}
+
+	report := Report{From: from.Format(dateLayout), To: to.Format(dateLayout), Cells: []Cell{}, Workcenters: []WorkcenterSummary{}}
+	var grandTotal totals
+	for _, workcenter := range data.workcenters {
+		var workcenterTotal totals
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			date := day.Format(dateLayout)
+			for _, shift := range data.shifts {
+				// Shifts without shopfloor apply to every workcenter of the tenant,
+				// the others to the workcenters of their shop floor and below
+				if shift.ShopFloorID.Valid && (!workcenter.ShopFloorID.Valid || !data.tree.Within(workcenter.ShopFloorID.UUID, shift.ShopFloorID.UUID)) {
+					continue
+				}
+				start, end := shifts.Window(day, shift.StartTime, shift.EndTime)
+
+				var t totals
+				t.plannedDown = overlap(start, end, plannedDowntimes[workcenter.ID])
+				t.planned = math.Max(end.Sub(start).Minutes()-t.plannedDown, 0)
+				t.unplannedDown = overlap(start, end, unplannedDowntimes[workcenter.ID])
+				t.run = math.Max(t.planned-t.unplannedDown, 0)
+				if entries := timeEntries[workcenter.ID]; len(entries) > 0 {
+					t.run = math.Min(t.run, overlap(start, end, entries))
+				}
+
+				counted := false
+				for _, count := range counts[workcenter.ID] {
+					if count.RecordedAt.Before(start) || !count.RecordedAt.Before(end) {
+						continue
+					}
+					counted = true
+					t.good += count.GoodQty
+					t.scrap += count.ScrapQty
+					t.ideal += float64(count.GoodQty+count.ScrapQty) * count.IdealCycleSeconds / 60
+				}
+				if !counted {
+					t.ideal = completedMinutes[cellKey{date, shift.ID, workcenter.ID}]
+				}
+
+				report.Cells = append(report.Cells, Cell{
+					Date:           date,
+					ShiftID:        shift.ID,
+					ShiftName:      shift.Name,
+					WorkcenterID:   workcenter.ID,
+					WorkcenterName: workcenter.Name,
+					Figures:        t.figures(),
+				})
+				workcenterTotal.add(t)
+			}
+		}
+		report.Workcenters = append(report.Workcenters, WorkcenterSummary{
+			WorkcenterID:   workcenter.ID,
+			CustomerID:     workcenter.CustomerID,
+			WorkcenterName: workcenter.Name,
+			Figures:        workcenterTotal.figures(),
+		})
+		grandTotal.add(workcenterTotal)
+	}
+	report.Total = grandTotal.figures()
+	return report
+}
@@ -0,0 +1,20 @@
+package oee
+
+import "api/internal/openapi"
+
+// filterParams are the query params of filterFromQuery
+var filterParams = []openapi.Param{
+	openapi.CustomerID,
+	{Name: "shop_floor_id"},
+	{Name: "workcenter_id"},
+	{Name: "from", Description: "YYYY-MM-DD, today by default"},
+	{Name: "to", Description: "YYYY-MM-DD, today by default"},
+}
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "GET", Path: "/api/oee", Tag: "oee", Summary: "OEE per workcenter, shift and day", Response: Report{}, Query: filterParams},
+	{Method: "POST", Path: "/api/oee/counts", Tag: "oee", Summary: "Record a production count", Request: ProductionCountRequest{}, Response: ProductionCount{}, Status: 201},
+	{Method: "GET", Path: "/api/oee/counts", Tag: "oee", Summary: "List production counts", Response: []ProductionCount{}, Query: filterParams},
+	{Method: "DELETE", Path: "/api/oee/counts/:id", Tag: "oee", Summary: "Delete a production count"},
+}
@@ -0,0 +1,76 @@
+package oee
+
+import (
+	"api/internal/apperr"
+	"api/internal/tenant"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+func filterFromQuery(c *gin.Context) Filter {
+	return Filter{
+		CustomerID:   c.Query("customer_id"),
+		ShopFloorID:  c.Query("shop_floor_id"),
+		WorkcenterID: c.Query("workcenter_id"),
+		From:         c.Query("from"),
+		To:           c.Query("to"),
+	}
+}
+
+func (h *Handler) RecordCount(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request ProductionCountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.RecordCount(ctx, request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Production count recorded successfully", "data": response})
+}
+
+func (h *Handler) FindCounts(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.FindCounts(ctx, filterFromQuery(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Production counts found successfully", "data": response})
+}
+
+func (h *Handler) DeleteCount(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.DeleteCount(ctx, c.Param("id")); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("production_count_not_found", "Production count not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Production count deleted successfully"})
+}
+
+// Report returns the OEE per workcenter, shift and day between from and to (YYYY-MM-DD, default today)
+func (h *Handler) Report(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.Report(ctx, filterFromQuery(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "OEE report generated successfully", "data": response})
+}
@@ -0,0 +1,89 @@
+package oee
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// How often the collector computes the report behind the gauges
+const metricsInterval = time.Minute
+
+// Collector computes today's OEE of the whole platform in the background,
+// so scraping /metrics never runs the report. The gauges carry no tenant,
+// workcenter or other identifying label since /metrics is public.
+type Collector struct {
+	service Service
+
+	mu    sync.Mutex
+	total Figures
+	ready bool
+}
+
+func NewCollector(service Service) *Collector {
+	return &Collector{service: service}
+}
+
+// Register exposes the last computed figures as gauges on the service meter,
+// so they are scraped from /metrics with the rest
+func (c *Collector) Register() error {
+	meter := otel.Meter("turniq-api")
+
+	gauges := map[string]metric.Float64ObservableGauge{}
+	for name, description := range map[string]string{
+		"oee.overall":      "OEE of all workcenters today (0..1)",
+		"oee.availability": "Availability of all workcenters today (0..1)",
+		"oee.performance":  "Performance of all workcenters today (0..1)",
+		"oee.quality":      "Quality of all workcenters today (0..1)",
+	} {
+		gauge, err := meter.Float64ObservableGauge(name, metric.WithDescription(description), metric.WithUnit("1"))
+		if err != nil {
+			return err
+		}
+		gauges[name] = gauge
+	}
+
+	_, err := meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		c.mu.Lock()
+		total, ready := c.total, c.ready
+		c.mu.Unlock()
+		if !ready {
+			return nil
+		}
+		observer.ObserveFloat64(gauges["oee.overall"], total.OEE)
+		observer.ObserveFloat64(gauges["oee.availability"], total.Availability)
+		observer.ObserveFloat64(gauges["oee.performance"], total.Performance)
+		observer.ObserveFloat64(gauges["oee.quality"], total.Quality)
+		return nil
+	}, gauges["oee.overall"], gauges["oee.availability"], gauges["oee.performance"], gauges["oee.quality"])
+	return err
+}
+
+// Run computes the report every metricsInterval until ctx is cancelled
+func (c *Collector) Run(ctx context.Context) {
+	slog.Info("OEE metrics collector started")
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+	for {
+		// Metrics cover every tenant
+		adminCtx := context.WithValue(ctx, "is_admin", true)
+		report, err := c.service.Report(adminCtx, Filter{})
+		if err != nil {
+			slog.Error("Failed to compute OEE metrics", slog.Any("error", err))
+		} else {
+			c.mu.Lock()
+			c.total, c.ready = report.Total, true
+			c.mu.Unlock()
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("OEE metrics collector stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package oee
+
+import (
+	"api/internal/shopfloors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sources of production counts
+const (
+	SourceManual  = "manual"
+	SourceMachine = "machine"
+)
+
+// ProductionCount is a number of good and scrap pieces made on a workcenter
+type ProductionCount struct {
+	ID           uuid.UUID     `json:"id"`
+	CustomerID   uuid.UUID     `json:"customer_id"`
+	WorkcenterID uuid.UUID     `json:"workcenter_id"`
+	JobID        uuid.NullUUID `json:"job_id"`
+	RecordedAt   time.Time     `json:"recorded_at"`
+	GoodQty      int           `json:"good_qty"`
+	ScrapQty     int           `json:"scrap_qty"`
+	Source       string        `json:"source"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+type ProductionCountRequest struct {
+	WorkcenterID string     `json:"workcenter_id" binding:"required,uuid"`
+	JobID        string     `json:"job_id" binding:"omitempty,uuid"`
+	RecordedAt   *time.Time `json:"recorded_at"`
+	GoodQty      int        `json:"good_qty" binding:"min=0"`
+	ScrapQty     int        `json:"scrap_qty" binding:"min=0"`
+}
+
+type Filter struct {
+	CustomerID   string
+	ShopFloorID  string
+	WorkcenterID string
+	From         string // YYYY-MM-DD
+	To           string // YYYY-MM-DD
+}
+
+// Figures holds the OEE ingredients and the resulting ratios (0..1).
+// When no pieces were counted Quality is 1 and Performance is based on the
+// estimated duration of the completed schedule entries.
+type Figures struct {
+	PlannedMinutes           int     `json:"planned_minutes"`
+	PlannedDowntimeMinutes   int     `json:"planned_downtime_minutes"`
+	UnplannedDowntimeMinutes int     `json:"unplanned_downtime_minutes"`
+	RunMinutes               int     `json:"run_minutes"`
+	IdealMinutes             float64 `json:"ideal_minutes"`
+	GoodQty                  int     `json:"good_qty"`
+	ScrapQty                 int     `json:"scrap_qty"`
+	Availability             float64 `json:"availability"`
+	Performance              float64 `json:"performance"`
+	Quality                  float64 `json:"quality"`
+	OEE                      float64 `json:"oee"`
+}
+
+// Cell is the OEE of one workcenter in one shift of one day
+type Cell struct {
+	Date           string    `json:"date"`
+	ShiftID        uuid.UUID `json:"shift_id"`
+	ShiftName      string    `json:"shift_name"`
+	WorkcenterID   uuid.UUID `json:"workcenter_id"`
+	WorkcenterName string    `json:"workcenter_name"`
+	Figures
+}
+
+// WorkcenterSummary is the OEE of a workcenter over the whole range
+type WorkcenterSummary struct {
+	WorkcenterID   uuid.UUID `json:"workcenter_id"`
+	CustomerID     uuid.UUID `json:"customer_id"`
+	WorkcenterName string    `json:"workcenter_name"`
+	Figures
+}
+
+type Report struct {
+	From        string              `json:"from"`
+	To          string              `json:"to"`
+	Cells       []Cell              `json:"cells"`
+	Workcenters []WorkcenterSummary `json:"workcenters"`
+	Total       Figures             `json:"total"`
+}
+
+type workcenterRef struct {
+	ID          uuid.UUID
+	CustomerID  uuid.UUID
+	ShopFloorID uuid.NullUUID
+	Name        string
+}
+
+type shiftRef struct {
+	ID          uuid.UUID
+	ShopFloorID uuid.NullUUID
+	Name        string
+	StartTime   time.Time
+	EndTime     time.Time
+}
+
+// interval is a downtime or a time entry on a workcenter
+type interval struct {
+	WorkcenterID uuid.UUID
+	Kind         string
+	Start        time.Time
+	End          time.Time
+}
+
+type countRow struct {
+	WorkcenterID      uuid.UUID
+	RecordedAt        time.Time
+	GoodQty           int
+	ScrapQty          int
+	IdealCycleSeconds float64
+}
+
+// completedEntry is a completed schedule entry with the duration of its job
+type completedEntry struct {
+	WorkcenterID      uuid.UUID
+	ShiftID           uuid.UUID
+	Date              string
+	EstimatedDuration int
+}
+
+// dataset is everything the calculation needs for a range
+type dataset struct {
+	workcenters []workcenterRef
+	shifts      []shiftRef
+	tree        shopfloors.Tree
+	downtimes   []interval
+	timeEntries []interval
+	counts      []countRow
+	completed   []completedEntry
+}
@@ -0,0 +1,250 @@
+package oee
+
+import (
+	"api/internal/shopfloors"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	CreateCount(ctx context.Context, count ProductionCount) (ProductionCount, error)
+	FindCountByID(ctx context.Context, id uuid.UUID) (ProductionCount, error)
+	FindCounts(ctx context.Context, customerID *uuid.UUID, workcenterID *uuid.UUID, from, to time.Time) ([]ProductionCount, error)
+	DeleteCount(ctx context.Context, id uuid.UUID) error
+	FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error)
+	FindJobCustomerID(ctx context.Context, jobID uuid.UUID) (uuid.UUID, error)
+	FindWorkcenters(ctx context.Context, customerID *uuid.UUID) ([]workcenterRef, error)
+	FindShifts(ctx context.Context, customerID *uuid.UUID) ([]shiftRef, error)
+	FindShopfloorTree(ctx context.Context, customerID *uuid.UUID) (shopfloors.Tree, error)
+	FindDowntimes(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]interval, error)
+	FindTimeEntries(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]interval, error)
+	FindCountRows(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]countRow, error)
+	FindCompletedEntries(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]completedEntry, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) CreateCount(ctx context.Context, count ProductionCount) (ProductionCount, error) {
+	query := `INSERT INTO production_counts (id, customer_id, workcenter_id, job_id, recorded_at, good_qty, scrap_qty, source, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := r.db.ExecContext(ctx, query, count.ID, count.CustomerID, count.WorkcenterID, count.JobID, count.RecordedAt,
+		count.GoodQty, count.ScrapQty, count.Source, count.CreatedAt)
+	if err != nil {
+		return ProductionCount{}, err
+	}
+	return count, nil
+}
+
+func (r *repository) FindCountByID(ctx context.Context, id uuid.UUID) (ProductionCount, error) {
+	query := `SELECT id, customer_id, workcenter_id, job_id, recorded_at, good_qty, scrap_qty, source, created_at FROM production_counts WHERE id = $1`
+	var count ProductionCount
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&count.ID, &count.CustomerID, &count.WorkcenterID, &count.JobID, &count.RecordedAt,
+		&count.GoodQty, &count.ScrapQty, &count.Source, &count.CreatedAt)
+	if err != nil {
+		return ProductionCount{}, err
+	}
+	return count, nil
+}
+
+func (r *repository) FindCounts(ctx context.Context, customerID *uuid.UUID, workcenterID *uuid.UUID, from, to time.Time) ([]ProductionCount, error) {
+	query := `SELECT id, customer_id, workcenter_id, job_id, recorded_at, good_qty, scrap_qty, source, created_at FROM production_counts
+	WHERE recorded_at >= $1 AND recorded_at < $2
+	AND ($3::uuid IS NULL OR customer_id = $3)
+	AND ($4::uuid IS NULL OR workcenter_id = $4)
+	ORDER BY recorded_at`
+	rows, err := r.db.QueryContext(ctx, query, from, to, nullUUID(customerID), nullUUID(workcenterID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var counts []ProductionCount
+	for rows.Next() {
+		var count ProductionCount
+		if err := rows.Scan(&count.ID, &count.CustomerID, &count.WorkcenterID, &count.JobID, &count.RecordedAt,
+			&count.GoodQty, &count.ScrapQty, &count.Source, &count.CreatedAt); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+func (r *repository) DeleteCount(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM production_counts WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *repository) FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error) {
+	query := `SELECT customer_id FROM workcenters WHERE id = $1 AND deleted_at IS NULL`
+	var customerID uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, workcenterID).Scan(&customerID); err != nil {
+		return uuid.Nil, err
+	}
+	return customerID, nil
+}
+
+func (r *repository) FindJobCustomerID(ctx context.Context, jobID uuid.UUID) (uuid.UUID, error) {
+	query := `SELECT customer_id FROM jobs WHERE id = $1 AND deleted_at IS NULL`
+	var customerID uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, jobID).Scan(&customerID); err != nil {
+		return uuid.Nil, err
+	}
+	return customerID, nil
+}
+
+func (r *repository) FindWorkcenters(ctx context.Context, customerID *uuid.UUID) ([]workcenterRef, error) {
+	query := `SELECT id, customer_id, shop_floor_id, name FROM workcenters
+	WHERE is_active = TRUE AND deleted_at IS NULL AND ($1::uuid IS NULL OR customer_id = $1)
+	ORDER BY name`
+	rows, err := r.db.QueryContext(ctx, query, nullUUID(customerID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var workcenters []workcenterRef
+	for rows.Next() {
+		var workcenter workcenterRef
+		if err := rows.Scan(&workcenter.ID, &workcenter.CustomerID, &workcenter.ShopFloorID, &workcenter.Name); err != nil {
+			return nil, err
+		}
+		workcenters = append(workcenters, workcenter)
+	}
+	return workcenters, nil
+}
+
+func (r *repository) FindShifts(ctx context.Context, customerID *uuid.UUID) ([]shiftRef, error) {
+	query := `SELECT id, shopfloor_id, name, start_time, end_time FROM shifts
+	WHERE is_active = TRUE AND deleted_at IS NULL AND ($1::uuid IS NULL OR customer_id = $1)
+	ORDER BY start_time`
+	rows, err := r.db.QueryContext(ctx, query, nullUUID(customerID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var shifts []shiftRef
+	for rows.Next() {
+		var shift shiftRef
+		if err := rows.Scan(&shift.ID, &shift.ShopFloorID, &shift.Name, &shift.StartTime, &shift.EndTime); err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, shift)
+	}
+	return shifts, nil
+}
+
+// FindShopfloorTree returns the parent of every shop floor of the tenant
+func (r *repository) FindShopfloorTree(ctx context.Context, customerID *uuid.UUID) (shopfloors.Tree, error) {
+	query := `SELECT id, parent_id FROM shopfloors WHERE deleted_at IS NULL AND ($1::uuid IS NULL OR customer_id = $1)`
+	rows, err := r.db.QueryContext(ctx, query, nullUUID(customerID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tree := shopfloors.Tree{}
+	for rows.Next() {
+		var id uuid.UUID
+		var parentID uuid.NullUUID
+		if err := rows.Scan(&id, &parentID); err != nil {
+			return nil, err
+		}
+		tree[id] = parentID
+	}
+	return tree, nil
+}
+
+// FindDowntimes returns the downtimes overlapping [from, to). Open downtimes end now.
+func (r *repository) FindDowntimes(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]interval, error) {
+	query := `SELECT workcenter_id, kind, start_time, COALESCE(end_time, NOW()) FROM downtimes
+	WHERE start_time < $2 AND (end_time IS NULL OR end_time > $1)
+	AND ($3::uuid IS NULL OR customer_id = $3)`
+	return r.findIntervals(ctx, query, from, to, nullUUID(customerID))
+}
+
+// FindTimeEntries returns the operator time on workcenters overlapping [from, to).
+// Entries still open end now.
+func (r *repository) FindTimeEntries(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]interval, error) {
+	query := `SELECT te.workcenter_id, '', te.check_in, COALESCE(te.check_out, NOW())
+	FROM time_entries te
+	JOIN workcenters w ON w.id = te.workcenter_id
+	WHERE te.check_in < $2 AND (te.check_out IS NULL OR te.check_out > $1) AND te.deleted_at IS NULL
+	AND ($3::uuid IS NULL OR w.customer_id = $3)`
+	return r.findIntervals(ctx, query, from, to, nullUUID(customerID))
+}
+
+func (r *repository) findIntervals(ctx context.Context, query string, args ...interface{}) ([]interval, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var intervals []interval
+	for rows.Next() {
+		var i interval
+		if err := rows.Scan(&i.WorkcenterID, &i.Kind, &i.Start, &i.End); err != nil {
+			return nil, err
+		}
+		intervals = append(intervals, i)
+	}
+	return intervals, nil
+}
+
+func (r *repository) FindCountRows(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]countRow, error) {
+	query := `SELECT pc.workcenter_id, pc.recorded_at, pc.good_qty, pc.scrap_qty, COALESCE(j.ideal_cycle_seconds, 0)
+	FROM production_counts pc
+	LEFT JOIN jobs j ON j.id = pc.job_id
+	WHERE pc.recorded_at >= $1 AND pc.recorded_at < $2
+	AND ($3::uuid IS NULL OR pc.customer_id = $3)`
+	rows, err := r.db.QueryContext(ctx, query, from, to, nullUUID(customerID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var counts []countRow
+	for rows.Next() {
+		var count countRow
+		if err := rows.Scan(&count.WorkcenterID, &count.RecordedAt, &count.GoodQty, &count.ScrapQty, &count.IdealCycleSeconds); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+func (r *repository) FindCompletedEntries(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]completedEntry, error) {
+	query := `SELECT se.workcenter_id, se.shift_id, to_char(se.date, 'YYYY-MM-DD'), COALESCE(j.estimated_duration, 0)
+	FROM schedule_entries se
+	JOIN jobs j ON j.id = se.job_id
+	WHERE se.is_completed = TRUE AND se.date >= $1 AND se.date < $2 AND se.deleted_at IS NULL
+	AND ($3::uuid IS NULL OR se.customer_id = $3)`
+	rows, err := r.db.QueryContext(ctx, query, from, to, nullUUID(customerID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []completedEntry
+	for rows.Next() {
+		var entry completedEntry
+		if err := rows.Scan(&entry.WorkcenterID, &entry.ShiftID, &entry.Date, &entry.EstimatedDuration); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func nullUUID(id *uuid.UUID) uuid.NullUUID {
+	if id == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: *id, Valid: true}
+}
@@ -0,0 +1,10 @@
+package oee
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/oee", handler.Report)
+	router.POST("/oee/counts", handler.RecordCount)
+	router.GET("/oee/counts", handler.FindCounts)
+	router.DELETE("/oee/counts/:id", handler.DeleteCount)
+}
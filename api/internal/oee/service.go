@@ -0,0 +1,233 @@
+package oee
+
+import (
+	"api/internal/apperr"
+	"api/internal/tenant"
+	"api/internal/validation"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	RecordCount(ctx context.Context, request ProductionCountRequest) (ProductionCount, error)
+	FindCounts(ctx context.Context, filter Filter) ([]ProductionCount, error)
+	DeleteCount(ctx context.Context, id string) error
+	Report(ctx context.Context, filter Filter) (Report, error)
+}
+
+// MaxReportDays caps the range of the OEE report
+const MaxReportDays = 31
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+// parseRange parses the from/to dates of a filter, defaulting to today
+func parseRange(filter Filter) (time.Time, time.Time, error) {
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	if filter.From != "" {
+		parsed, err := time.Parse(dateLayout, filter.From)
+		if err != nil {
+			return time.Time{}, time.Time{}, apperr.Validation("invalid_from", "from must be a date (YYYY-MM-DD)")
+		}
+		from = parsed
+	}
+	to := from
+	if filter.To != "" {
+		parsed, err := time.Parse(dateLayout, filter.To)
+		if err != nil {
+			return time.Time{}, time.Time{}, apperr.Validation("invalid_to", "to must be a date (YYYY-MM-DD)")
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, apperr.Validation("invalid_range", "to must not be before from")
+	}
+	if to.Sub(from) >= MaxReportDays*24*time.Hour {
+		return time.Time{}, time.Time{}, apperr.Validation("range_too_large", "date range is too large")
+	}
+	return from, to, nil
+}
+
+func (s *service) RecordCount(ctx context.Context, request ProductionCountRequest) (ProductionCount, error) {
+	workcenterID, err := uuid.Parse(request.WorkcenterID)
+	if err != nil {
+		return ProductionCount{}, err
+	}
+	customerID, err := s.repo.FindWorkcenterCustomerID(ctx, workcenterID)
+	if err != nil {
+		return ProductionCount{}, err
+	}
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return ProductionCount{}, err
+	}
+	if scope != nil && *scope != customerID {
+		return ProductionCount{}, apperr.NotFound("workcenter_not_found", "workcenter not found")
+	}
+	var invalid validation.Errors
+	if request.GoodQty < 0 {
+		invalid.Add("good_qty", "negative_quantity", "good_qty must not be negative")
+	}
+	if request.ScrapQty < 0 {
+		invalid.Add("scrap_qty", "negative_quantity", "scrap_qty must not be negative")
+	}
+	if request.GoodQty+request.ScrapQty == 0 {
+		invalid.Add("good_qty", "empty_count", "good_qty or scrap_qty must be greater than 0")
+	}
+
+	var jobID uuid.NullUUID
+	if request.JobID != "" {
+		parsedID, err := uuid.Parse(request.JobID)
+		if err != nil {
+			return ProductionCount{}, err
+		}
+		// The job must belong to the tenant of the workcenter
+		jobCustomerID, err := s.repo.FindJobCustomerID(ctx, parsedID)
+		switch {
+		case tenant.IsNotFound(err):
+			invalid.Add("job_id", "unknown_job", "job not found")
+		case err != nil:
+			return ProductionCount{}, err
+		case jobCustomerID != customerID:
+			invalid.Add("job_id", "foreign_job", "job belongs to another customer")
+		}
+		jobID = uuid.NullUUID{UUID: parsedID, Valid: true}
+	}
+	if err := invalid.Err(); err != nil {
+		return ProductionCount{}, err
+	}
+	recordedAt := time.Now()
+	if request.RecordedAt != nil {
+		recordedAt = *request.RecordedAt
+	}
+
+	count := ProductionCount{
+		ID:           uuid.New(),
+		CustomerID:   customerID,
+		WorkcenterID: workcenterID,
+		JobID:        jobID,
+		RecordedAt:   recordedAt,
+		GoodQty:      request.GoodQty,
+		ScrapQty:     request.ScrapQty,
+		Source:       SourceManual,
+		CreatedAt:    time.Now(),
+	}
+	return s.repo.CreateCount(ctx, count)
+}
+
+func (s *service) FindCounts(ctx context.Context, filter Filter) ([]ProductionCount, error) {
+	scope, err := tenant.Scope(ctx, filter.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := parseRange(filter)
+	if err != nil {
+		return nil, err
+	}
+	var workcenterID *uuid.UUID
+	if filter.WorkcenterID != "" {
+		parsedID, err := uuid.Parse(filter.WorkcenterID)
+		if err != nil {
+			return nil, err
+		}
+		workcenterID = &parsedID
+	}
+	return s.repo.FindCounts(ctx, scope, workcenterID, from, to.AddDate(0, 0, 1))
+}
+
+func (s *service) DeleteCount(ctx context.Context, id string) error {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+	count, err := s.repo.FindCountByID(ctx, parsedID)
+	if err != nil {
+		return err
+	}
+	if err := tenant.Check(ctx, count.CustomerID); err != nil {
+		return err
+	}
+	return s.repo.DeleteCount(ctx, count.ID)
+}
+
+// Report computes the OEE per workcenter, shift and day between from and to
+func (s *service) Report(ctx context.Context, filter Filter) (Report, error) {
+	scope, err := tenant.Scope(ctx, filter.CustomerID)
+	if err != nil {
+		return Report{}, err
+	}
+	from, to, err := parseRange(filter)
+	if err != nil {
+		return Report{}, err
+	}
+	var shopFloorID, workcenterID uuid.NullUUID
+	if filter.ShopFloorID != "" {
+		parsedID, err := uuid.Parse(filter.ShopFloorID)
+		if err != nil {
+			return Report{}, err
+		}
+		shopFloorID = uuid.NullUUID{UUID: parsedID, Valid: true}
+	}
+	if filter.WorkcenterID != "" {
+		parsedID, err := uuid.Parse(filter.WorkcenterID)
+		if err != nil {
+			return Report{}, err
+		}
+		workcenterID = uuid.NullUUID{UUID: parsedID, Valid: true}
+	}
+
+	data, err := s.load(ctx, scope, from, to)
+	if err != nil {
+		return Report{}, err
+	}
+	var workcenters []workcenterRef
+	for _, workcenter := range data.workcenters {
+		// A shop floor includes the areas and lines below it
+		if shopFloorID.Valid && (!workcenter.ShopFloorID.Valid || !data.tree.Within(workcenter.ShopFloorID.UUID, shopFloorID.UUID)) {
+			continue
+		}
+		if workcenterID.Valid && workcenter.ID != workcenterID.UUID {
+			continue
+		}
+		workcenters = append(workcenters, workcenter)
+	}
+	data.workcenters = workcenters
+	return calculate(data, from, to), nil
+}
+
+// load reads everything needed for the days [from, to]. Night shifts of the
+// last day end on the next one, so intervals are read one day further.
+func (s *service) load(ctx context.Context, scope *uuid.UUID, from, to time.Time) (dataset, error) {
+	var data dataset
+	var err error
+	end := to.AddDate(0, 0, 2)
+	if data.workcenters, err = s.repo.FindWorkcenters(ctx, scope); err != nil {
+		return dataset{}, err
+	}
+	if data.shifts, err = s.repo.FindShifts(ctx, scope); err != nil {
+		return dataset{}, err
+	}
+	if data.tree, err = s.repo.FindShopfloorTree(ctx, scope); err != nil {
+		return dataset{}, err
+	}
+	if data.downtimes, err = s.repo.FindDowntimes(ctx, scope, from, end); err != nil {
+		return dataset{}, err
+	}
+	if data.timeEntries, err = s.repo.FindTimeEntries(ctx, scope, from, end); err != nil {
+		return dataset{}, err
+	}
+	if data.counts, err = s.repo.FindCountRows(ctx, scope, from, end); err != nil {
+		return dataset{}, err
+	}
+	if data.completed, err = s.repo.FindCompletedEntries(ctx, scope, from, to.AddDate(0, 0, 1)); err != nil {
+		return dataset{}, err
+	}
+	return data, nil
+}
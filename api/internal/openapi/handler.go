@@ -0,0 +1,46 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Docs are the routes Register adds
+var Docs = []Route{
+	{Method: "GET", Path: "/openapi.json", Tag: "docs", Summary: "OpenAPI description of the API", Public: true, Raw: true},
+	{Method: "GET", Path: "/docs", Tag: "docs", Summary: "API documentation", Public: true, ContentType: "text/html"},
+}
+
+// docsPage renders /openapi.json with Swagger UI
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>API documentation</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="docs"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+SwaggerUIBundle({ url: "/openapi.json", dom_id: "#docs" });
+</script>
+</body>
+</html>`
+
+// Register serves the document at /openapi.json and its docs UI at /docs
+func Register(router gin.IRouter, doc *Document) error {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	router.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", body)
+	})
+	router.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+	})
+	return nil
+}
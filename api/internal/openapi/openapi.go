@@ -0,0 +1,347 @@
+// Package openapi builds the OpenAPI 3 description of the API. Every module
+// lists its routes in a Docs variable next to RegisterRoutes; New turns them
+// into a Document, with the schemas of the request and response structs read
+// from their json tags, and Register serves it at /openapi.json with a docs
+// UI at /docs.
+//
+// Missing reports the routes of a router that have no entry and Stale the
+// entries of routes that are gone, so the server tests catch the drift.
+package openapi
+
+import (
+	"api/internal/listing"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route documents an endpoint
+type Route struct {
+	Method  string
+	Path    string // as registered with gin, e.g. /api/jobs/:id
+	Tag     string
+	Summary string
+	// Public routes need no credentials
+	Public bool
+	// Request is the JSON body, nil when the route takes none
+	Request interface{}
+	// Response is the "data" member of the response, nil when there is none
+	Response interface{}
+	// Raw responses are Response itself instead of the message envelope
+	Raw bool
+	// ContentType of a response that isn't JSON, e.g. text/html
+	ContentType string
+	// Status of a success, 200 when zero
+	Status int
+	// List documents a paged list: the listing params and the "meta" member
+	List  *listing.Spec
+	Query []Param
+	// Form is the multipart form of an upload
+	Form []Param
+}
+
+// Param is a query or form parameter
+type Param struct {
+	Name        string
+	Description string
+	Type        string // string when empty; "binary" for files
+}
+
+// CustomerID is the customer_id query param of the routes scoped with
+// tenant.Scope: admins pick the tenant, other users always get their own
+var CustomerID = Param{Name: "customer_id", Description: "Tenant to read, for admins; ignored for tenant users"}
+
+// Confirm is the confirm query param of the routes that move a record with
+// dependents to the trash
+var Confirm = Param{Name: "confirm", Description: "true to delete a record that has dependents. Without it such a delete answers 409 with the dependents moved to the trash along with it and the history kept", Type: "boolean"}
+
+// Info describes the API itself
+type Info struct {
+	Title   string
+	Version string
+}
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI    string                            `json:"openapi"`
+	Info       map[string]string                 `json:"info"`
+	Paths      map[string]map[string]interface{} `json:"paths"`
+	Components map[string]interface{}            `json:"components"`
+
+	routes map[string]bool
+}
+
+// New builds the document of the routes of every module
+func New(info Info, modules ...[]Route) *Document {
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    map[string]string{"title": info.Title, "version": info.Version},
+		Paths:   map[string]map[string]interface{}{},
+		routes:  map[string]bool{},
+	}
+	schemas := newSchemas()
+	schemas.components["Problem"] = problemSchema
+
+	for _, routes := range modules {
+		for _, route := range routes {
+			path, params := pathOf(route.Path)
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = map[string]interface{}{}
+			}
+			doc.Paths[path][strings.ToLower(route.Method)] = operation(route, params, schemas)
+			doc.routes[route.Method+" "+route.Path] = true
+		}
+	}
+
+	doc.Components = map[string]interface{}{
+		"schemas": schemas.components,
+		"securitySchemes": map[string]interface{}{
+			"bearer": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+		},
+	}
+	return doc
+}
+
+// Missing returns the routes of the router that the document doesn't cover
+func (d *Document) Missing(routes gin.RoutesInfo) []string {
+	var missing []string
+	for _, route := range routes {
+		if !d.routes[route.Method+" "+route.Path] {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Stale returns the documented routes the router doesn't have
+func (d *Document) Stale(routes gin.RoutesInfo) []string {
+	registered := map[string]bool{}
+	for _, route := range routes {
+		registered[route.Method+" "+route.Path] = true
+	}
+	var stale []string
+	for route := range d.routes {
+		if !registered[route] {
+			stale = append(stale, route)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// pathOf turns the :params of a gin path into {params}
+func pathOf(ginPath string) (string, []string) {
+	segments := strings.Split(ginPath, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func operation(route Route, pathParams []string, schemas *schemas) map[string]interface{} {
+	op := map[string]interface{}{
+		"summary":     route.Summary,
+		"operationId": operationID(route),
+	}
+	if route.Tag != "" {
+		op["tags"] = []string{route.Tag}
+	}
+	if route.Public {
+		op["security"] = []interface{}{}
+	} else {
+		op["security"] = []interface{}{
+			map[string]interface{}{"bearer": []string{}},
+			map[string]interface{}{"apiKey": []string{}},
+		}
+	}
+
+	var parameters []interface{}
+	for _, name := range pathParams {
+		parameters = append(parameters, map[string]interface{}{
+			"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, param := range listParams(route.List) {
+		parameters = append(parameters, param)
+	}
+	if route.Method == http.MethodPost && strings.HasPrefix(route.Path, "/api/") {
+		// See middleware.Idempotency
+		parameters = append(parameters, map[string]interface{}{
+			"name": "Idempotency-Key", "in": "header", "schema": map[string]interface{}{"type": "string", "maxLength": 255},
+			"description": "Makes the request safe to retry: a retry by the same caller with the same key and body gets the first response again",
+		})
+	}
+	for _, param := range route.Query {
+		if route.List != nil && route.List.Filter[param.Name] != "" {
+			continue // already a filter of the list
+		}
+		parameters = append(parameters, queryParam(param.Name, param.Description, paramSchema(param)))
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+
+	if route.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": schemas.of(route.Request)}},
+		}
+	} else if len(route.Form) > 0 {
+		properties := map[string]interface{}{}
+		for _, param := range route.Form {
+			schema := paramSchema(param)
+			if param.Description != "" {
+				schema["description"] = param.Description
+			}
+			properties[param.Name] = schema
+		}
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{"multipart/form-data": map[string]interface{}{
+				"schema": map[string]interface{}{"type": "object", "properties": properties},
+			}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{
+		"description": http.StatusText(status),
+		"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": responseSchema(route, schemas)}},
+	}
+	if route.ContentType != "" {
+		success["content"] = map[string]interface{}{route.ContentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+	}
+	if status >= 300 && status < 400 {
+		success = map[string]interface{}{
+			"description": http.StatusText(status),
+			"headers":     map[string]interface{}{"Location": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}},
+		}
+	}
+	op["responses"] = map[string]interface{}{
+		fmt.Sprint(status): success,
+		"default": map[string]interface{}{
+			"description": "Error",
+			"content":     map[string]interface{}{"application/problem+json": map[string]interface{}{"schema": ref("Problem")}},
+		},
+	}
+	return op
+}
+
+func responseSchema(route Route, schemas *schemas) map[string]interface{} {
+	if route.Raw {
+		if route.Response == nil {
+			return map[string]interface{}{"type": "object"}
+		}
+		return schemas.of(route.Response)
+	}
+	properties := map[string]interface{}{"message": map[string]interface{}{"type": "string"}}
+	if route.Response != nil {
+		properties["data"] = schemas.of(route.Response)
+	}
+	if route.List != nil {
+		properties["meta"] = schemas.of(listing.Page{})
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// listParams are the query params that listing.Parse reads for spec
+func listParams(spec *listing.Spec) []interface{} {
+	if spec == nil {
+		return nil
+	}
+	integer := map[string]interface{}{"type": "integer"}
+	text := map[string]interface{}{"type": "string"}
+	params := []interface{}{
+		queryParam("limit", fmt.Sprintf("Page size, %d by default and %d at most", listing.DefaultLimit, listing.MaxLimit), integer),
+		queryParam("cursor", "next_cursor of the previous page", text),
+		queryParam("offset", "Rows to skip", integer),
+		queryParam("page", "Page number, from 1", integer),
+	}
+	if len(spec.Sort) > 0 {
+		params = append(params, queryParam("sort", "Comma separated fields, - for descending: "+strings.Join(sortedKeys(spec.Sort), ", "), text))
+	}
+	if len(spec.Search) > 0 {
+		params = append(params, queryParam("q", "Text to look for in "+strings.Join(spec.Search, ", "), text))
+	}
+	for _, field := range sortedKeys(spec.Filter) {
+		params = append(params, queryParam(field, "Comma separated values, any of them matches", text))
+	}
+	return params
+}
+
+func queryParam(name, description string, schema map[string]interface{}) map[string]interface{} {
+	param := map[string]interface{}{"name": name, "in": "query", "schema": schema}
+	if description != "" {
+		param["description"] = description
+	}
+	return param
+}
+
+func paramSchema(param Param) map[string]interface{} {
+	switch param.Type {
+	case "", "string":
+		return map[string]interface{}{"type": "string"}
+	case "binary":
+		return map[string]interface{}{"type": "string", "format": "binary"}
+	default:
+		return map[string]interface{}{"type": param.Type}
+	}
+}
+
+func operationID(route Route) string {
+	id := strings.ToLower(route.Method)
+	for _, segment := range strings.Split(route.Path, "/") {
+		segment = strings.TrimLeft(segment, ":*")
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' }) {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return id
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// problemSchema is the body of every error, see middleware.ErrorMiddleware
+var problemSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"type":       map[string]interface{}{"type": "string"},
+		"title":      map[string]interface{}{"type": "string"},
+		"status":     map[string]interface{}{"type": "integer"},
+		"detail":     map[string]interface{}{"type": "string"},
+		"code":       map[string]interface{}{"type": "string"},
+		"error":      map[string]interface{}{"type": "string"},
+		"request_id": map[string]interface{}{"type": "string"},
+		// The wrong fields of a validation error, see apperr.Fields
+		"fields": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"field":   map[string]interface{}{"type": "string"},
+					"code":    map[string]interface{}{"type": "string"},
+					"message": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	},
+}
@@ -0,0 +1,16 @@
+package operators
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/operators", Tag: "operators", Summary: "Create an operator", Request: OperatorRequest{}, Response: Operator{}},
+	{Method: "GET", Path: "/api/operators", Tag: "operators", Summary: "List operators", Response: []Operator{}, List: &listSpec},
+	{Method: "GET", Path: "/api/operators/trash", Tag: "operators", Summary: "List deleted operators", Response: []Operator{}, List: &trashSpec, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "GET", Path: "/api/operators/:id", Tag: "operators", Summary: "Get an operator", Response: Operator{}},
+	{Method: "GET", Path: "/api/operators/code/:code", Tag: "operators", Summary: "Get an operator by code", Response: Operator{}},
+	{Method: "GET", Path: "/api/operators/shopfloor/:shopFloorID", Tag: "operators", Summary: "List the operators of a shop floor", Response: []Operator{}, List: &listSpec},
+	{Method: "PUT", Path: "/api/operators/:id", Tag: "operators", Summary: "Update an operator", Request: OperatorRequest{}, Response: Operator{}},
+	{Method: "DELETE", Path: "/api/operators/:id", Tag: "operators", Summary: "Move an operator and its schedule and time entries to the trash", Query: []openapi.Param{openapi.Confirm}},
+	{Method: "POST", Path: "/api/operators/:id/restore", Tag: "operators", Summary: "Restore a deleted operator with the entries deleted along with it", Response: Operator{}},
+}
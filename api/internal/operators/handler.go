@@ -1,6 +1,10 @@
 package operators
 
 import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
+	"api/internal/trash"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -18,12 +22,12 @@ func (h *Handler) Create(c *gin.Context) {
 	ctx := c.Request.Context()
 	var request OperatorRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Create(ctx, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Operator created successfully", "data": response})
@@ -34,7 +38,11 @@ func (h *Handler) FindByID(c *gin.Context) {
 	id := c.Param("id")
 	response, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("operator_not_found", "Operator not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Operator found successfully", "data": response})
@@ -45,7 +53,11 @@ func (h *Handler) FindByCode(c *gin.Context) {
 	code := c.Param("code")
 	response, err := h.service.FindByCode(ctx, code)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("operator_not_found", "Operator not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Operator found successfully", "data": response})
@@ -54,16 +66,32 @@ func (h *Handler) FindByCode(c *gin.Context) {
 func (h *Handler) FindAll(c *gin.Context) {
 	ctx := c.Request.Context()
 	
-	var response []Operator
-	var err error
-
-	response, err = h.service.FindAll(ctx)
+	params, err := listing.Parse(c, listSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.List(ctx, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Operators found successfully", "data": response, "meta": params.Page(total)})
+}
 
+func (h *Handler) FindByShopFloorID(c *gin.Context) {
+	ctx := c.Request.Context()
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Operators found successfully", "data": response})
+	response, total, err := h.service.FindByShopFloorID(ctx, c.Param("shopFloorID"), params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Operators found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) Update(c *gin.Context) {
@@ -71,12 +99,16 @@ func (h *Handler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var request OperatorRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Update(ctx, id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("operator_not_found", "Operator not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Operator updated successfully", "data": response})
@@ -85,9 +117,42 @@ func (h *Handler) Update(c *gin.Context) {
 func (h *Handler) Delete(c *gin.Context) {
 	ctx := c.Request.Context()
 	id := c.Param("id")
-	if err := h.service.Delete(ctx, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.Delete(ctx, id, trash.Confirmed(c.Query("confirm"))); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("operator_not_found", "Operator not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Operator deleted successfully"})
 }
+
+func (h *Handler) Trash(c *gin.Context) {
+	ctx := c.Request.Context()
+	params, err := listing.Parse(c, trashSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.Trash(ctx, c.Query("customer_id"), params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted operators found successfully", "data": response, "meta": params.Page(total)})
+}
+
+func (h *Handler) Restore(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.Restore(ctx, c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("operator_not_found", "Operator not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Operator restored successfully", "data": response})
+}
@@ -17,14 +17,15 @@ type Operator struct {
 	IsActive    bool      `json:"is_active"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 }
 
 type OperatorRequest struct {
-	ShopFloorID uuid.UUID `json:"shop_floor_id"`
-	CustomerID  string `json:"customer_id"`
-	Code        string `json:"code"`
-	Name        string    `json:"name"`
-	Surname     string    `json:"surname"`
+	ShopFloorID uuid.UUID `json:"shop_floor_id" binding:"required"`
+	CustomerID  string `json:"customer_id" binding:"omitempty,uuid"`
+	Code        string `json:"code" binding:"required,max=50"`
+	Name        string    `json:"name" binding:"required,max=100"`
+	Surname     string    `json:"surname" binding:"max=100"`
 	VatNumber   string    `json:"vat_number"`
 	IsActive    bool      `json:"is_active"`
 }
\ No newline at end of file
@@ -1,23 +1,54 @@
 package operators
 
 import (
+	"api/internal/listing"
+	"api/internal/shopfloors"
+	"api/internal/trash"
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// listSpec is what the operator list can be sorted, filtered and searched on
+var listSpec = listing.Spec{
+	Sort: map[string]string{
+		"code":       "code",
+		"name":       "name",
+		"surname":    "surname",
+		"is_active":  "is_active",
+		"created_at": "created_at",
+	},
+	Filter: map[string]string{
+		"customer_id":   "customer_id",
+		"shop_floor_id": "shop_floor_id",
+		"shopfloor_id":  "shop_floor_id",
+		"is_active":     "is_active",
+	},
+	Search:  []string{"code", "name", "surname", "vat_number"},
+	Default: []listing.Order{{Field: "name"}},
+}
+
+// trashSpec is what the trash can be sorted and filtered on
+var trashSpec = trash.Spec(listSpec)
+
 type Repository interface {
 	Create(ctx context.Context, operator Operator) (Operator, error)
 	FindByID(ctx context.Context, id uuid.UUID) (Operator, error)
-	FindAll(ctx context.Context) ([]Operator, error)
+	List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Operator, int, error)
 	FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]Operator, error)
-	FindByCode(ctx context.Context, code string) (Operator, error)
+	FindByShopFloorID(ctx context.Context, shopFloorID uuid.UUID, customerID *uuid.UUID, params listing.Params) ([]Operator, int, error)
+	FindByCode(ctx context.Context, code string, customerID *uuid.UUID) (Operator, error)
 	CountByCustomerID(ctx context.Context, customerID uuid.UUID) (int, error)
 	Update(ctx context.Context, operator Operator) (Operator, error)
-	Delete(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID, confirm func(trash.Preview) error) error
+	FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Operator, int, error)
+	FindDeletedByID(ctx context.Context, id uuid.UUID) (Operator, error)
+	Restore(ctx context.Context, id uuid.UUID) error
 	LogIn(ctx context.Context, operatorID uuid.UUID) error
 	LogOut(ctx context.Context, operatorID uuid.UUID) error
+	FindShopFloorCustomerID(ctx context.Context, shopFloorID uuid.UUID) (uuid.UUID, error)
 }
 
 type repository struct {
@@ -40,7 +71,7 @@ func (r *repository) Create(ctx context.Context, operator Operator) (Operator, e
 
 func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (Operator, error) {
 	query := `SELECT id, shop_floor_id, customer_id, code, name, surname, vat_number, is_active, created_at, updated_at 
-	FROM operators WHERE id = $1`
+	FROM operators WHERE id = $1 AND deleted_at IS NULL`
 	row := r.db.QueryRowContext(ctx, query, id)
 	var operator Operator
 	err := row.Scan(&operator.ID, &operator.ShopFloorID, &operator.CustomerID, &operator.Code, &operator.Name, &operator.Surname, &operator.VatNumber, &operator.IsActive, &operator.CreatedAt, &operator.UpdatedAt)
@@ -50,30 +81,37 @@ func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (Operator, erro
 	return operator, nil
 }
 
-func (r *repository) FindAll(ctx context.Context) ([]Operator, error) {
-	query := `SELECT id, shop_floor_id, customer_id, code, name, surname, vat_number, is_active, created_at, updated_at 
-	FROM operators`
-	rows, err := r.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
+// List returns a page of the operators of customerID, or of every customer when nil
+func (r *repository) List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Operator, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
 	}
-	defer rows.Close()
-	operators := []Operator{}
-	for rows.Next() {
+	return r.findPage(ctx, q)
+}
+
+// findPage runs a list query on the operators the user can see
+func (r *repository) findPage(ctx context.Context, q *listing.Query) ([]Operator, int, error) {
+	// Users restricted to some shop floors only see their operators
+	shopfloors.Restrict(ctx, q, "shop_floor_id")
+	return listing.Find(ctx, r.db, q, `SELECT id, shop_floor_id, customer_id, code, name, surname, vat_number, is_active, created_at, updated_at 
+	FROM operators`, func(rows *sql.Rows) (Operator, error) {
 		var operator Operator
 		err := rows.Scan(&operator.ID, &operator.ShopFloorID, &operator.CustomerID, &operator.Code, &operator.Name, &operator.Surname, &operator.VatNumber, &operator.IsActive, &operator.CreatedAt, &operator.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		operators = append(operators, operator)
-	}
-	return operators, nil
+		return operator, err
+	})
 }
 
 func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]Operator, error) {
 	query := `SELECT id, shop_floor_id, customer_id, code, name, surname, vat_number, is_active, created_at, updated_at 
-	FROM operators WHERE customer_id = $1`
-	rows, err := r.db.QueryContext(ctx, query, customerID)
+	FROM operators WHERE customer_id = $1 AND deleted_at IS NULL`
+	args := []interface{}{customerID}
+	// Users restricted to some shop floors only see their operators
+	condition, scopeArgs := shopfloors.ScopeCondition(ctx, "shop_floor_id", 2)
+	query += condition
+	args = append(args, scopeArgs...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -90,10 +128,27 @@ func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID)
 	return operators, nil
 }
 
-func (r *repository) FindByCode(ctx context.Context, code string) (Operator, error) {
+// FindByShopFloorID returns a page of the operators of the shop floor and of every shop floor below it
+func (r *repository) FindByShopFloorID(ctx context.Context, shopFloorID uuid.UUID, customerID *uuid.UUID, params listing.Params) ([]Operator, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	shopfloors.InSubtree(q, "shop_floor_id", shopFloorID)
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return r.findPage(ctx, q)
+}
+
+// FindByCode looks an operator up by code within the tenant, or across every
+// tenant when customerID is nil
+func (r *repository) FindByCode(ctx context.Context, code string, customerID *uuid.UUID) (Operator, error) {
 	query := `SELECT id, shop_floor_id, customer_id, code, name, surname, vat_number, is_active, created_at, updated_at 
-	FROM operators WHERE code = $1`
-	row := r.db.QueryRowContext(ctx, query, code)
+	FROM operators WHERE code = $1 AND deleted_at IS NULL AND ($2::uuid IS NULL OR customer_id = $2)`
+	var tenant uuid.NullUUID
+	if customerID != nil {
+		tenant = uuid.NullUUID{UUID: *customerID, Valid: true}
+	}
+	row := r.db.QueryRowContext(ctx, query, code, tenant)
 	var operator Operator
 	err := row.Scan(&operator.ID, &operator.ShopFloorID, &operator.CustomerID, &operator.Code, &operator.Name, &operator.Surname, &operator.VatNumber, &operator.IsActive, &operator.CreatedAt, &operator.UpdatedAt)
 	if err != nil {
@@ -103,7 +158,7 @@ func (r *repository) FindByCode(ctx context.Context, code string) (Operator, err
 }
 
 func (r *repository) CountByCustomerID(ctx context.Context, customerID uuid.UUID) (int, error) {
-	query := `SELECT COUNT(*) FROM operators WHERE customer_id = $1`
+	query := `SELECT COUNT(*) FROM operators WHERE customer_id = $1 AND deleted_at IS NULL`
 	var count int
 	err := r.db.QueryRowContext(ctx, query, customerID).Scan(&count)
 	if err != nil {
@@ -112,6 +167,13 @@ func (r *repository) CountByCustomerID(ctx context.Context, customerID uuid.UUID
 	return count, nil
 }
 
+// FindShopFloorCustomerID returns the tenant of a shop floor
+func (r *repository) FindShopFloorCustomerID(ctx context.Context, shopFloorID uuid.UUID) (uuid.UUID, error) {
+	var customerID uuid.UUID
+	err := r.db.QueryRowContext(ctx, `SELECT customer_id FROM shopfloors WHERE id = $1 AND deleted_at IS NULL`, shopFloorID).Scan(&customerID)
+	return customerID, err
+}
+
 func (r *repository) Update(ctx context.Context, operator Operator) (Operator, error) {
 	query := `UPDATE operators SET shop_floor_id = $2, customer_id = $4, code = $5, name = $6, surname = $7, vat_number = $8, is_active = $9, updated_at = $10 WHERE id = $1`
 	_, err := r.db.ExecContext(ctx, query, operator.ID, operator.ShopFloorID, operator.CustomerID, operator.Code, operator.Name, operator.Surname, operator.VatNumber, operator.IsActive, operator.UpdatedAt)
@@ -121,10 +183,102 @@ func (r *repository) Update(ctx context.Context, operator Operator) (Operator, e
 	return operator, nil
 }
 
-func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM operators WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+// deleteSteps move the schedule and time entries of an operator to the trash along with it
+var deleteSteps = []trash.Step{
+	{Kind: "schedule_entries", Query: `UPDATE schedule_entries SET deleted_at = $2 WHERE operator_id = $1 AND deleted_at IS NULL`},
+	{Kind: "time_entries", Query: `UPDATE time_entries SET deleted_at = $2 WHERE operator_id = $1 AND deleted_at IS NULL`},
+}
+
+// restoreSteps take the entries deleted along with an operator out of the trash
+var restoreSteps = []trash.Step{
+	{Kind: "schedule_entries", Query: `UPDATE schedule_entries SET deleted_at = NULL WHERE operator_id = $1 AND deleted_at = $2
+	AND ` + trash.ScheduleEntryParentsLive},
+	{Kind: "time_entries", Query: `UPDATE time_entries SET deleted_at = NULL WHERE operator_id = $1 AND deleted_at = $2
+	AND ` + trash.TimeEntryParentsLive},
+}
+
+// Delete moves the operator and its schedule and time entries to the trash,
+// marked with the same time so they are restored together. confirm gets the
+// preview before anything is committed and cancels the deletion when it fails.
+func (r *repository) Delete(ctx context.Context, id uuid.UUID, confirm func(trash.Preview) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	query := `UPDATE operators SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL RETURNING deleted_at`
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&deletedAt); err != nil {
+		return err
+	}
+	deleted, err := trash.Cascade(ctx, tx, deleteSteps, id, deletedAt)
+	if err != nil {
+		return err
+	}
+	if err := confirm(trash.Preview{Deleted: deleted}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindDeleted returns a page of the operators in the trash of the tenant,
+// or of every tenant when customerID is nil
+func (r *repository) FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Operator, int, error) {
+	q := listing.NewQuery(trashSpec, params)
+	q.Where("deleted_at IS NOT NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return listing.Find(ctx, r.db, q, `SELECT id, shop_floor_id, customer_id, code, name, surname, vat_number, is_active, created_at, updated_at, deleted_at FROM operators`, func(rows *sql.Rows) (Operator, error) {
+		var operator Operator
+		err := rows.Scan(&operator.ID, &operator.ShopFloorID, &operator.CustomerID, &operator.Code, &operator.Name, &operator.Surname, &operator.VatNumber, &operator.IsActive, &operator.CreatedAt, &operator.UpdatedAt, &operator.DeletedAt)
+		return operator, err
+	})
+}
+
+func (r *repository) FindDeletedByID(ctx context.Context, id uuid.UUID) (Operator, error) {
+	query := `SELECT id, shop_floor_id, customer_id, code, name, surname, vat_number, is_active, created_at, updated_at, deleted_at
+	FROM operators WHERE id = $1 AND deleted_at IS NOT NULL`
+	var operator Operator
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&operator.ID, &operator.ShopFloorID, &operator.CustomerID, &operator.Code, &operator.Name, &operator.Surname, &operator.VatNumber, &operator.IsActive, &operator.CreatedAt, &operator.UpdatedAt, &operator.DeletedAt)
+	if err != nil {
+		return Operator{}, err
+	}
+	return operator, nil
+}
+
+// Restore takes the operator out of the trash together with the entries
+// deleted with it, unless its shop floor is still in the trash
+func (r *repository) Restore(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	query := `SELECT deleted_at FROM operators WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&deletedAt); err != nil {
+		return err
+	}
+	query = `UPDATE operators SET deleted_at = NULL WHERE id = $1
+	AND NOT EXISTS (SELECT 1 FROM shopfloors WHERE id = operators.shop_floor_id AND deleted_at IS NOT NULL)`
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return trash.ErrParentDeleted
+	}
+	if _, err := trash.Cascade(ctx, tx, restoreSteps, id, deletedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *repository) LogIn(ctx context.Context, operatorID uuid.UUID) error {
@@ -5,8 +5,11 @@ import "github.com/gin-gonic/gin"
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/operators", handler.Create)
 	router.GET("/operators", handler.FindAll)
+	router.GET("/operators/trash", handler.Trash)
 	router.GET("/operators/:id", handler.FindByID)
 	router.GET("/operators/code/:code", handler.FindByCode)
+	router.GET("/operators/shopfloor/:shopFloorID", handler.FindByShopFloorID)
 	router.PUT("/operators/:id", handler.Update)
 	router.DELETE("/operators/:id", handler.Delete)
+	router.POST("/operators/:id/restore", handler.Restore)
 }	
\ No newline at end of file
@@ -1,8 +1,15 @@
 package operators
 
 import (
+	"api/internal/apperr"
+	"api/internal/audit"
 	"api/internal/customers"
+	"api/internal/listing"
+	"api/internal/shopfloors"
+	"api/internal/tenant"
+	"api/internal/trash"
 	"context"
+	"database/sql"
 	"errors"
 	"time"
 
@@ -12,20 +19,24 @@ import (
 type Service interface {
 	Create(ctx context.Context, request OperatorRequest) (Operator, error)
 	FindByID(ctx context.Context, id string) (Operator, error)
-	FindAll(ctx context.Context) ([]Operator, error)
+	List(ctx context.Context, params listing.Params) ([]Operator, int, error)
 	FindByCustomerID(ctx context.Context, customerID string) ([]Operator, error)
+	FindByShopFloorID(ctx context.Context, shopFloorID string, params listing.Params) ([]Operator, int, error)
 	FindByCode(ctx context.Context, code string) (Operator, error)
 	Update(ctx context.Context, id string, request OperatorRequest) (Operator, error)
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string, confirmed bool) error
+	Trash(ctx context.Context, customerID string, params listing.Params) ([]Operator, int, error)
+	Restore(ctx context.Context, id string) (Operator, error)
 }
 
 type service struct {
 	repo Repository	
 	customerService customers.Service
+	audit audit.Recorder
 }
 
-func NewService(repo Repository, customerService customers.Service) Service {
-	return &service{repo: repo, customerService: customerService}
+func NewService(repo Repository, customerService customers.Service, recorder audit.Recorder) Service {
+	return &service{repo: repo, customerService: customerService, audit: recorder}
 }
 
 func (s *service) Create(ctx context.Context, request OperatorRequest) (Operator, error) {
@@ -65,9 +76,11 @@ func (s *service) Create(ctx context.Context, request OperatorRequest) (Operator
 	}
 
 	if count >= customer.MaxOperators {
-		return Operator{}, errors.New("max operators limit reached for this customer")
+		return Operator{}, apperr.QuotaExceeded("max_operators", "max operators limit reached for this customer")
+	}
+	if err := s.checkShopFloor(ctx, request.ShopFloorID, customerID); err != nil {
+		return Operator{}, err
 	}
-	
 
 	operator := Operator{
 		ID:          uuid.New(),
@@ -85,6 +98,7 @@ func (s *service) Create(ctx context.Context, request OperatorRequest) (Operator
 	if err != nil {
 		return Operator{}, err
 	}
+	s.audit.Created(ctx, audit.EntityOperator, operator.ID, operator.CustomerID, operator)
 	return operator, nil
 }
 
@@ -93,42 +107,82 @@ func (s *service) FindByID(ctx context.Context, id string) (Operator, error) {
 	if err != nil {
 		return Operator{}, err
 	}
-	return s.repo.FindByID(ctx, parsedID)
+	operator, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return Operator{}, err
+	}
+	if err := tenant.Check(ctx, operator.CustomerID); err != nil {
+		return Operator{}, err
+	}
+	if err := shopfloors.Check(ctx, operator.ShopFloorID); err != nil {
+		return Operator{}, err
+	}
+	return operator, nil
 }
 
 func (s *service) FindByCustomerID(ctx context.Context, customerID string) ([]Operator, error) {
-	parsedCustomerID, err := uuid.Parse(customerID)
+	parsedCustomerID, err := tenant.Customer(ctx, customerID)
 	if err != nil {
 		return nil, err
 	}
 	return s.repo.FindByCustomerID(ctx, parsedCustomerID)
 }
 
-func (s *service) FindAll(ctx context.Context) ([]Operator, error) {
+// List returns a page of the operators of the tenant, every tenant for admins
+func (s *service) List(ctx context.Context, params listing.Params) ([]Operator, int, error) {
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repo.List(ctx, scope, params)
+}
+
+// FindByShopFloorID returns the operators of a shop floor, including the
+// ones attached to areas and lines below it
+func (s *service) FindByShopFloorID(ctx context.Context, shopFloorID string, params listing.Params) ([]Operator, int, error) {
+	parsedID, err := uuid.Parse(shopFloorID)
+	if err != nil {
+		return nil, 0, err
+	}
 	isAdminVal := ctx.Value("is_admin")
 	isAdmin, ok := isAdminVal.(bool)
 	if !ok {
-		return nil, errors.New("invalid or missing is_admin in context")
+		return nil, 0, errors.New("invalid or missing is_admin in context")
 	}
-	if isAdmin{
-		return s.repo.FindAll(ctx)
+	if isAdmin {
+		return s.repo.FindByShopFloorID(ctx, parsedID, nil, params)
 	}
 	customerIDVal := ctx.Value("customer_id")
-		customerIDFromCtx, ok := customerIDVal.(uuid.UUID)
-		if !ok {
-			return nil, errors.New("invalid or missing customer_id in context")
-		}
-		customerID := customerIDFromCtx
-	return s.repo.FindByCustomerID(ctx, customerID)
+	customerID, ok := customerIDVal.(uuid.UUID)
+	if !ok {
+		return nil, 0, errors.New("invalid or missing customer_id in context")
+	}
+	return s.repo.FindByShopFloorID(ctx, parsedID, &customerID, params)
+}
+
+// checkShopFloor checks that a shop floor exists and belongs to customerID.
+// Shop floors out of the reach of the user look missing.
+func (s *service) checkShopFloor(ctx context.Context, shopFloorID, customerID uuid.UUID) error {
+	owner, err := s.repo.FindShopFloorCustomerID(ctx, shopFloorID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !shopfloors.Permits(ctx, shopFloorID)) {
+		return apperr.Field("shop_floor_id", "unknown_shopfloor", "shop floor not found")
+	}
+	if err != nil {
+		return err
+	}
+	if owner != customerID {
+		return apperr.Field("shop_floor_id", "foreign_shopfloor", "shop floor belongs to another customer")
+	}
+	return nil
 }
 
 func (s *service) Update(ctx context.Context, id string, request OperatorRequest) (Operator, error) {
-	parsedID, err := uuid.Parse(id)
+	operator, err := s.FindByID(ctx, id)
 	if err != nil {
 		return Operator{}, err
 	}
-	operator, err := s.repo.FindByID(ctx, parsedID)
-	if err != nil {
+	before := operator
+	if err := s.checkShopFloor(ctx, request.ShopFloorID, operator.CustomerID); err != nil {
 		return Operator{}, err
 	}
 	operator.ShopFloorID = request.ShopFloorID
@@ -142,17 +196,81 @@ func (s *service) Update(ctx context.Context, id string, request OperatorRequest
 	if err != nil {
 		return Operator{}, err
 	}
+	s.audit.Updated(ctx, audit.EntityOperator, operator.ID, operator.CustomerID, before, operator)
 	return operator, nil
 }
 
 func (s *service) FindByCode(ctx context.Context, code string) (Operator, error) {
-	return s.repo.FindByCode(ctx, code)
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return Operator{}, err
+	}
+	operator, err := s.repo.FindByCode(ctx, code, scope)
+	if err != nil {
+		return Operator{}, err
+	}
+	if err := shopfloors.Check(ctx, operator.ShopFloorID); err != nil {
+		return Operator{}, err
+	}
+	return operator, nil
 }
 
-func (s *service) Delete(ctx context.Context, id string) error {
-	parsedID, err := uuid.Parse(id)
+// Delete moves the operator and its schedule and time entries to the trash.
+// Operators with entries are only deleted once confirmed.
+func (s *service) Delete(ctx context.Context, id string, confirmed bool) error {
+	operator, err := s.FindByID(ctx, id)
 	if err != nil {
 		return err
 	}
-	return s.repo.Delete(ctx, parsedID)
+	err = s.repo.Delete(ctx, operator.ID, func(preview trash.Preview) error {
+		return trash.Confirm(preview, confirmed)
+	})
+	if err != nil {
+		return err
+	}
+	s.audit.Deleted(ctx, audit.EntityOperator, operator.ID, operator.CustomerID, operator)
+	return nil
+}
+
+// Trash returns the deleted operators of the tenant
+func (s *service) Trash(ctx context.Context, customerID string, params listing.Params) ([]Operator, int, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repo.FindDeleted(ctx, scope, params)
+}
+
+func (s *service) Restore(ctx context.Context, id string) (Operator, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Operator{}, err
+	}
+	operator, err := s.repo.FindDeletedByID(ctx, parsedID)
+	if err != nil {
+		return Operator{}, err
+	}
+	if err := tenant.Check(ctx, operator.CustomerID); err != nil {
+		return Operator{}, err
+	}
+	if err := shopfloors.Check(ctx, operator.ShopFloorID); err != nil {
+		return Operator{}, err
+	}
+	customer, err := s.customerService.FindByID(ctx, operator.CustomerID.String())
+	if err != nil {
+		return Operator{}, err
+	}
+	count, err := s.repo.CountByCustomerID(ctx, operator.CustomerID)
+	if err != nil {
+		return Operator{}, err
+	}
+	if count >= customer.MaxOperators {
+		return Operator{}, apperr.QuotaExceeded("max_operators", "max operators limit reached for this customer")
+	}
+	if err := s.repo.Restore(ctx, operator.ID); err != nil {
+		return Operator{}, err
+	}
+	operator.DeletedAt = nil
+	s.audit.Restored(ctx, audit.EntityOperator, operator.ID, operator.CustomerID, operator)
+	return operator, nil
 }
@@ -0,0 +1,69 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types published to the outbox
+const (
+	EventJobCreated             = "job.created"
+	EventSchedulePublished      = "schedule.published"
+	EventScheduleEntryCompleted = "schedule_entry.completed"
+	EventTimeEntryOpened        = "time_entry.opened"
+	EventTimeEntryClosed        = "time_entry.closed"
+)
+
+// EventTypes lists every event type a webhook endpoint can subscribe to
+var EventTypes = []string{
+	EventJobCreated,
+	EventSchedulePublished,
+	EventScheduleEntryCompleted,
+	EventTimeEntryOpened,
+	EventTimeEntryClosed,
+}
+
+// Event is a domain event waiting to be dispatched to the customer's webhook endpoints
+type Event struct {
+	ID         uuid.UUID
+	CustomerID uuid.UUID
+	Type       string
+	Payload    interface{}
+	CreatedAt  time.Time
+}
+
+// Execer is satisfied by both *sql.DB and *sql.Tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func NewEvent(customerID uuid.UUID, eventType string, payload interface{}) Event {
+	return Event{
+		ID:         uuid.New(),
+		CustomerID: customerID,
+		Type:       eventType,
+		Payload:    payload,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// Write stores the events in the outbox table. It must be called with the same
+// transaction that persists the change the events describe, so that both are
+// committed or rolled back together.
+func Write(ctx context.Context, exec Execer, events ...Event) error {
+	query := `INSERT INTO outbox_events (id, customer_id, event_type, payload, created_at) VALUES ($1, $2, $3, $4, $5)`
+	for _, event := range events {
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return err
+		}
+		if _, err := exec.ExecContext(ctx, query, event.ID, event.CustomerID, event.Type, payload, event.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
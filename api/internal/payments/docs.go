@@ -0,0 +1,17 @@
+package payments
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/payments", Tag: "payments", Summary: "Record a payment", Request: PaymentRequest{}, Response: Payment{}},
+	{Method: "GET", Path: "/api/payments", Tag: "payments", Summary: "List payments", Response: []Payment{}, List: &listSpec, Query: []openapi.Param{
+		openapi.CustomerID,
+		{Name: "from", Description: "YYYY-MM-DD or RFC 3339"},
+		{Name: "to", Description: "YYYY-MM-DD or RFC 3339"},
+	}},
+	{Method: "GET", Path: "/api/payments/:id", Tag: "payments", Summary: "Get a payment", Response: Payment{}},
+	{Method: "GET", Path: "/api/payments/customer/:customer_id", Tag: "payments", Summary: "List the payments of a customer", Response: []Payment{}, List: &listSpec},
+	{Method: "PUT", Path: "/api/payments/:id", Tag: "payments", Summary: "Update a payment", Request: PaymentRequest{}, Response: Payment{}},
+	{Method: "DELETE", Path: "/api/payments/:id", Tag: "payments", Summary: "Delete a payment"},
+}
@@ -1,6 +1,9 @@
 package payments
 
 import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
 	"api/middleware"
 	"net/http"
 	"time"
@@ -19,18 +22,18 @@ func NewHandler(service Service) Handler {
 
 func (h *Handler) Create(c *gin.Context) {
 	if !middleware.IsAdmin(c) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.Error(apperr.Unauthorized("unauthorized", "Unauthorized"))
 		return
 	}
 	ctx := c.Request.Context()
 	var request PaymentRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Create(ctx, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Payment created successfully", "data": response})
@@ -38,7 +41,7 @@ func (h *Handler) Create(c *gin.Context) {
 
 func (h *Handler) FindAll(c *gin.Context) {
 	if !middleware.IsAdmin(c) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.Error(apperr.Unauthorized("unauthorized", "Unauthorized"))
 		return
 	}
 	ctx := c.Request.Context()
@@ -78,24 +81,33 @@ func (h *Handler) FindAll(c *gin.Context) {
         }
 	}
 
-	response, err := h.service.Search(ctx, filter)
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Payments found successfully", "data": response})
+	response, total, err := h.service.Search(ctx, filter, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Payments found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindById(c *gin.Context) {
 	if !middleware.IsAdmin(c) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.Error(apperr.Unauthorized("unauthorized", "Unauthorized"))
 		return
 	}
 	ctx := c.Request.Context()
 	id := c.Param("id")
 	response, err := h.service.FindById(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("payment_not_found", "Payment not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Payment found successfully", "data": response})
@@ -103,34 +115,43 @@ func (h *Handler) FindById(c *gin.Context) {
 
 func (h *Handler) FindByCustomerId(c *gin.Context) {
 	if !middleware.IsAdmin(c) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.Error(apperr.Unauthorized("unauthorized", "Unauthorized"))
 		return
 	}
 	ctx := c.Request.Context()
 	customerId := c.Param("customer_id")
-	response, err := h.service.FindByCustomerId(ctx, customerId)
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Payments found successfully", "data": response})
+	response, total, err := h.service.FindByCustomerId(ctx, customerId, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Payments found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) Update(c *gin.Context) {
 	if !middleware.IsAdmin(c) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.Error(apperr.Unauthorized("unauthorized", "Unauthorized"))
 		return
 	}
 	ctx := c.Request.Context()
 	id := c.Param("id")
 	var request PaymentRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Update(ctx, id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("payment_not_found", "Payment not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Payment updated successfully", "data": response})
@@ -138,13 +159,17 @@ func (h *Handler) Update(c *gin.Context) {
 
 func (h *Handler) Delete(c *gin.Context) {
 	if !middleware.IsAdmin(c) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.Error(apperr.Unauthorized("unauthorized", "Unauthorized"))
 		return
 	}
 	ctx := c.Request.Context()
 	id := c.Param("id")
 	if err := h.service.Delete(ctx, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("payment_not_found", "Payment not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Payment deleted successfully"})
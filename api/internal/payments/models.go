@@ -20,11 +20,11 @@ type Payment struct {
 }
 
 type PaymentRequest struct {
-	CustomerID    string    `json:"customer_id"`
-	Amount        float64   `json:"amount"`
-	Currency      string    `json:"currency"`
+	CustomerID    string    `json:"customer_id" binding:"required,uuid"`
+	Amount        float64   `json:"amount" binding:"gte=0"`
+	Currency      string    `json:"currency" binding:"omitempty,iso4217"`
 	PaymentMethod string    `json:"payment_method"`
-	Status        string    `json:"status"`
+	Status        string    `json:"status" binding:"omitempty,oneof=pending paid succeeded failed"`
 	DueDate       time.Time `json:"due_date"`
 	PaidAt        time.Time `json:"paid_at"`
 }
\ No newline at end of file
@@ -1,9 +1,9 @@
 package payments
 
 import (
+	"api/internal/listing"
 	"context"
 	"database/sql"
-	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,12 +15,29 @@ type PaymentFilter struct {
 	EndDate    *time.Time
 }
 
+// listSpec is what the payment list can be sorted and filtered on, besides
+// the filters of PaymentFilter
+var listSpec = listing.Spec{
+	Sort: map[string]string{
+		"amount":   "amount",
+		"status":   "status",
+		"due_date": "due_date",
+		"paid_at":  "paid_at",
+	},
+	Filter: map[string]string{
+		"status":         "status",
+		"currency":       "currency",
+		"payment_method": "payment_method",
+	},
+	Default: []listing.Order{{Field: "paid_at", Desc: true}},
+}
+
 type Repository interface {
 	Create(ctx context.Context, payment Payment) (Payment, error)
 	FindAll(ctx context.Context) ([]Payment, error)
 	FindById(ctx context.Context, id uuid.UUID) (Payment, error)
-	FindByCustomerId(ctx context.Context, customerId uuid.UUID) ([]Payment, error)
-	Search(ctx context.Context, filter PaymentFilter) ([]Payment, error)
+	FindByCustomerId(ctx context.Context, customerId uuid.UUID, params listing.Params) ([]Payment, int, error)
+	Search(ctx context.Context, filter PaymentFilter, params listing.Params) ([]Payment, int, error)
 	Update(ctx context.Context, payment Payment) (Payment, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
@@ -71,62 +88,29 @@ func (r *repository) FindById(ctx context.Context, id uuid.UUID) (Payment, error
 	return payment, nil
 }
 
-func (r *repository) FindByCustomerId(ctx context.Context, customerId uuid.UUID) ([]Payment, error) {
-	query := `SELECT id, customer_id, amount, currency, payment_method, status, due_date, paid_at FROM payments WHERE customer_id = $1`
-	rows, err := r.db.QueryContext(ctx, query, customerId)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var payments []Payment
-	for rows.Next() {
-		payment := Payment{}
-		if err := rows.Scan(&payment.ID, &payment.CustomerID, &payment.Amount, &payment.Currency, &payment.PaymentMethod, &payment.Status, &payment.DueDate, &payment.PaidAt); err != nil {
-			return nil, err
-		}
-		payments = append(payments, payment)
-	}
-	return payments, nil
+// FindByCustomerId returns a page of the payments of the customer
+func (r *repository) FindByCustomerId(ctx context.Context, customerId uuid.UUID, params listing.Params) ([]Payment, int, error) {
+	return r.Search(ctx, PaymentFilter{CustomerID: &customerId}, params)
 }
 
-func (r *repository) Search(ctx context.Context, filter PaymentFilter) ([]Payment, error) {
-	query := `SELECT id, customer_id, amount, currency, payment_method, status, due_date, paid_at FROM payments WHERE 1=1`
-	var args []interface{}
-	argId := 1
-
+// Search returns a page of the payments matching the filter
+func (r *repository) Search(ctx context.Context, filter PaymentFilter, params listing.Params) ([]Payment, int, error) {
+	q := listing.NewQuery(listSpec, params)
 	if filter.CustomerID != nil {
-		query += fmt.Sprintf(" AND customer_id = $%d", argId)
-		args = append(args, *filter.CustomerID)
-		argId++
+		q.Where("customer_id = ?", *filter.CustomerID)
 	}
 	if filter.StartDate != nil {
-		query += fmt.Sprintf(" AND paid_at >= $%d", argId)
-		args = append(args, *filter.StartDate)
-		argId++
+		q.Where("paid_at >= ?", *filter.StartDate)
 	}
 	if filter.EndDate != nil {
-		query += fmt.Sprintf(" AND paid_at <= $%d", argId)
-		args = append(args, *filter.EndDate)
-		argId++
-	}
-
-	query += " ORDER BY paid_at DESC"
-
-	rows, err := r.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
+		q.Where("paid_at <= ?", *filter.EndDate)
 	}
-	defer rows.Close()
 
-	var payments []Payment
-	for rows.Next() {
+	return listing.Find(ctx, r.db, q, `SELECT id, customer_id, amount, currency, payment_method, status, due_date, paid_at FROM payments`, func(rows *sql.Rows) (Payment, error) {
 		payment := Payment{}
-		if err := rows.Scan(&payment.ID, &payment.CustomerID, &payment.Amount, &payment.Currency, &payment.PaymentMethod, &payment.Status, &payment.DueDate, &payment.PaidAt); err != nil {
-			return nil, err
-		}
-		payments = append(payments, payment)
-	}
-	return payments, nil
+		err := rows.Scan(&payment.ID, &payment.CustomerID, &payment.Amount, &payment.Currency, &payment.PaymentMethod, &payment.Status, &payment.DueDate, &payment.PaidAt)
+		return payment, err
+	})
 }
 
 func (r *repository) Update(ctx context.Context, payment Payment) (Payment, error) {
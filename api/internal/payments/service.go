@@ -1,6 +1,9 @@
 package payments
 
 import (
+	"api/internal/apperr"
+	"api/internal/audit"
+	"api/internal/listing"
 	"context"
 	"errors"
 	"time"
@@ -12,18 +15,19 @@ type Service interface {
 	Create(ctx context.Context, request PaymentRequest) (Payment, error)
 	FindAll(ctx context.Context) ([]Payment, error)
 	FindById(ctx context.Context, id string) (Payment, error)
-	FindByCustomerId(ctx context.Context, customerId string) ([]Payment, error)
-	Search(ctx context.Context, filter PaymentFilter) ([]Payment, error)
+	FindByCustomerId(ctx context.Context, customerId string, params listing.Params) ([]Payment, int, error)
+	Search(ctx context.Context, filter PaymentFilter, params listing.Params) ([]Payment, int, error)
 	Update(ctx context.Context, id string, request PaymentRequest) (Payment, error)
 	Delete(ctx context.Context, id string) error
 }
 
 type service struct {
-	repo Repository
+	repo  Repository
+	audit audit.Recorder
 }
 
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+func NewService(repo Repository, recorder audit.Recorder) Service {
+	return &service{repo: repo, audit: recorder}
 }
 
 func (s *service) Create(ctx context.Context, request PaymentRequest) (Payment, error) {
@@ -33,7 +37,7 @@ func (s *service) Create(ctx context.Context, request PaymentRequest) (Payment,
 		return Payment{}, errors.New("invalid or missing is_admin in context")
 	}
 	if !isAdmin {
-		return Payment{}, errors.New("user is not admin")
+		return Payment{}, apperr.Forbidden("admin_only", "user is not admin")
 	}
 	customerParsedId, err := uuid.Parse(request.CustomerID)
 	if err != nil {
@@ -51,7 +55,12 @@ func (s *service) Create(ctx context.Context, request PaymentRequest) (Payment,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
-	return s.repo.Create(ctx, payment)
+	created, err := s.repo.Create(ctx, payment)
+	if err != nil {
+		return Payment{}, err
+	}
+	s.audit.Created(ctx, audit.EntityPayment, created.ID, created.CustomerID, created)
+	return created, nil
 }
 
 func (s *service) FindAll(ctx context.Context) ([]Payment, error) {
@@ -61,7 +70,7 @@ func (s *service) FindAll(ctx context.Context) ([]Payment, error) {
 		return nil, errors.New("invalid or missing is_admin in context")
 	}
 	if !isAdmin {
-		return nil, errors.New("user is not admin")
+		return nil, apperr.Forbidden("admin_only", "user is not admin")
 	}
 	return s.repo.FindAll(ctx)
 }
@@ -73,7 +82,7 @@ func (s *service) FindById(ctx context.Context, id string) (Payment, error) {
 		return Payment{}, errors.New("invalid or missing is_admin in context")
 	}
 	if !isAdmin {
-		return Payment{}, errors.New("user is not admin")
+		return Payment{}, apperr.Forbidden("admin_only", "user is not admin")
 	}
 	parsedID, err := uuid.Parse(id)
 	if err != nil {
@@ -82,33 +91,33 @@ func (s *service) FindById(ctx context.Context, id string) (Payment, error) {
 	return s.repo.FindById(ctx, parsedID)
 }
 
-func (s *service) FindByCustomerId(ctx context.Context, customerId string) ([]Payment, error) {
+func (s *service) FindByCustomerId(ctx context.Context, customerId string, params listing.Params) ([]Payment, int, error) {
 	isAdminVal := ctx.Value("is_admin")
 	isAdmin, ok := isAdminVal.(bool)
 	if !ok {
-		return nil, errors.New("invalid or missing is_admin in context")
+		return nil, 0, errors.New("invalid or missing is_admin in context")
 	}
 	if !isAdmin {
-		return nil, errors.New("user is not admin")
+		return nil, 0, apperr.Forbidden("admin_only", "user is not admin")
 	}
 	customerParsedId, err := uuid.Parse(customerId)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return s.repo.FindByCustomerId(ctx, customerParsedId)
+	return s.repo.FindByCustomerId(ctx, customerParsedId, params)
 }
 
-func (s *service) Search(ctx context.Context, filter PaymentFilter) ([]Payment, error) {
+func (s *service) Search(ctx context.Context, filter PaymentFilter, params listing.Params) ([]Payment, int, error) {
 	// Only Admin can search payments (Billing Report)
 	isAdminVal := ctx.Value("is_admin")
 	isAdmin, ok := isAdminVal.(bool)
 	if !ok {
-		return nil, errors.New("invalid or missing is_admin in context")
+		return nil, 0, errors.New("invalid or missing is_admin in context")
 	}
 	if !isAdmin {
-		return nil, errors.New("user is not admin")
+		return nil, 0, apperr.Forbidden("admin_only", "user is not admin")
 	}
-	return s.repo.Search(ctx, filter)
+	return s.repo.Search(ctx, filter, params)
 }
 
 func (s *service) Update(ctx context.Context, id string, request PaymentRequest) (Payment, error) {
@@ -118,7 +127,7 @@ func (s *service) Update(ctx context.Context, id string, request PaymentRequest)
 		return Payment{}, errors.New("invalid or missing is_admin in context")
 	}
 	if !isAdmin {
-		return Payment{}, errors.New("user is not admin")
+		return Payment{}, apperr.Forbidden("admin_only", "user is not admin")
 	}
 	parsedID, err := uuid.Parse(id)
 	if err != nil {
@@ -132,6 +141,7 @@ func (s *service) Update(ctx context.Context, id string, request PaymentRequest)
 	if err != nil {
 		return Payment{}, err
 	}
+	before := payment
 
 	payment.CustomerID = customerParsedId
 	payment.Amount = request.Amount
@@ -141,7 +151,12 @@ func (s *service) Update(ctx context.Context, id string, request PaymentRequest)
 	payment.DueDate = request.DueDate
 	payment.PaidAt = request.PaidAt
 	payment.UpdatedAt = time.Now()
-	return s.repo.Update(ctx, payment)
+	updated, err := s.repo.Update(ctx, payment)
+	if err != nil {
+		return Payment{}, err
+	}
+	s.audit.Updated(ctx, audit.EntityPayment, updated.ID, updated.CustomerID, before, updated)
+	return updated, nil
 }
 
 func (s *service) Delete(ctx context.Context, id string) error {
@@ -151,11 +166,19 @@ func (s *service) Delete(ctx context.Context, id string) error {
 		return errors.New("invalid or missing is_admin in context")
 	}
 	if !isAdmin {
-		return errors.New("user is not admin")
+		return apperr.Forbidden("admin_only", "user is not admin")
 	}
 	parsedID, err := uuid.Parse(id)
 	if err != nil {
 		return err
 	}
-	return s.repo.Delete(ctx, parsedID)
+	payment, err := s.repo.FindById(ctx, parsedID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, parsedID); err != nil {
+		return err
+	}
+	s.audit.Deleted(ctx, audit.EntityPayment, payment.ID, payment.CustomerID, payment)
+	return nil
 }
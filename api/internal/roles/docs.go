@@ -0,0 +1,12 @@
+package roles
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "GET", Path: "/api/roles/permissions", Tag: "roles", Summary: "Every permission a role can grant", Response: []string{}},
+	{Method: "POST", Path: "/api/roles", Tag: "roles", Summary: "Create a role", Request: RoleRequest{}, Response: Role{}, Status: 201},
+	{Method: "GET", Path: "/api/roles", Tag: "roles", Summary: "List the roles", Response: []Role{}, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "PUT", Path: "/api/roles/:id", Tag: "roles", Summary: "Update a role", Request: RoleRequest{}, Response: Role{}},
+	{Method: "DELETE", Path: "/api/roles/:id", Tag: "roles", Summary: "Delete a role"},
+}
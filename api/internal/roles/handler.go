@@ -0,0 +1,80 @@
+package roles
+
+import (
+	"api/internal/apperr"
+	"api/internal/tenant"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+// Catalogue returns every permission a custom role can grant
+func (h *Handler) Catalogue(c *gin.Context) {
+	ctx := c.Request.Context()
+	c.JSON(http.StatusOK, gin.H{"message": "Permissions found successfully", "data": h.service.Catalogue(ctx)})
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request RoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.Create(ctx, request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Role created successfully", "data": response})
+}
+
+func (h *Handler) FindAll(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.FindAll(ctx, c.Query("customer_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Roles found successfully", "data": response})
+}
+
+func (h *Handler) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request RoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.Update(ctx, c.Param("id"), request)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("role_not_found", "Role not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully", "data": response})
+}
+
+func (h *Handler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.Delete(ctx, c.Param("id")); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("role_not_found", "Role not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
@@ -0,0 +1,174 @@
+package roles
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Resources of the permission catalogue. Each one maps to a route group and
+// has a read and a write permission, e.g. "jobs:read" and "jobs:write".
+const (
+	ResourceCustomers   = "customers"
+	ResourceUsers       = "users"
+	ResourceRoles       = "roles"
+	ResourceShopfloors  = "shopfloors"
+	ResourceWorkcenters = "workcenters"
+	ResourceOperators   = "operators"
+	ResourceJobs        = "jobs"
+	ResourceShifts      = "shifts"
+	ResourcePlanning    = "planning"
+	ResourceTimeEntries = "timeentries"
+	ResourceDowntimes   = "downtimes"
+	ResourceOEE         = "oee"
+	ResourceMachines    = "machines"
+	ResourceWebhooks    = "webhooks"
+	ResourcePayments    = "payments"
+	ResourceAPIKeys     = "api_keys"
+	ResourceAudit       = "audit"
+)
+
+// Actions of a permission
+const (
+	ActionRead  = "read"
+	ActionWrite = "write"
+)
+
+// Built-in roles
+const (
+	RoleOwner      = "owner"
+	RolePlanner    = "planner"
+	RoleSupervisor = "supervisor"
+	RoleOperator   = "operator"
+	RoleReadOnly   = "read_only"
+)
+
+// platform resources are managed by system admins; tenant roles can only read them
+var platform = []string{ResourceCustomers, ResourcePayments, ResourceAudit}
+
+var resources = []string{
+	ResourceCustomers, ResourceUsers, ResourceRoles, ResourceShopfloors, ResourceWorkcenters,
+	ResourceOperators, ResourceJobs, ResourceShifts, ResourcePlanning, ResourceTimeEntries,
+	ResourceDowntimes, ResourceOEE, ResourceMachines, ResourceWebhooks, ResourcePayments, ResourceAPIKeys,
+	ResourceAudit,
+}
+
+// Permission builds the name of a permission, e.g. "jobs:write"
+func Permission(resource, action string) string {
+	return resource + ":" + action
+}
+
+// Catalogue returns every permission that can be granted to a tenant role
+func Catalogue() []string {
+	writable := map[string]bool{}
+	for _, resource := range resources {
+		writable[resource] = true
+	}
+	for _, resource := range platform {
+		writable[resource] = false
+	}
+	var permissions []string
+	for _, resource := range resources {
+		permissions = append(permissions, Permission(resource, ActionRead))
+		if writable[resource] {
+			permissions = append(permissions, Permission(resource, ActionWrite))
+		}
+	}
+	return permissions
+}
+
+// grant returns read on every resource in read plus write on the ones in write
+func grant(read []string, write []string) []string {
+	set := map[string]bool{}
+	for _, resource := range read {
+		set[Permission(resource, ActionRead)] = true
+	}
+	for _, resource := range write {
+		set[Permission(resource, ActionRead)] = true
+		set[Permission(resource, ActionWrite)] = true
+	}
+	var permissions []string
+	for permission := range set {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// builtIn are the roles every tenant has
+var builtIn = map[string]Role{
+	RoleOwner: {
+		Code: RoleOwner,
+		Name: "Owner",
+		Permissions: grant(platform, []string{
+			ResourceUsers, ResourceRoles, ResourceShopfloors, ResourceWorkcenters, ResourceOperators, ResourceJobs,
+			ResourceShifts, ResourcePlanning, ResourceTimeEntries, ResourceDowntimes, ResourceOEE, ResourceMachines, ResourceWebhooks,
+			ResourceAPIKeys,
+		}),
+		IsBuiltIn: true,
+	},
+	RolePlanner: {
+		Code: RolePlanner,
+		Name: "Planner",
+		Permissions: grant(
+			[]string{ResourceTimeEntries, ResourceOEE, ResourceMachines},
+			[]string{ResourceShopfloors, ResourceWorkcenters, ResourceOperators, ResourceJobs, ResourceShifts, ResourcePlanning, ResourceDowntimes},
+		),
+		IsBuiltIn: true,
+	},
+	RoleSupervisor: {
+		Code: RoleSupervisor,
+		Name: "Supervisor",
+		Permissions: grant(
+			[]string{ResourceShopfloors, ResourceWorkcenters, ResourceJobs, ResourceShifts, ResourceMachines},
+			[]string{ResourceOperators, ResourcePlanning, ResourceTimeEntries, ResourceDowntimes, ResourceOEE},
+		),
+		IsBuiltIn: true,
+	},
+	// Operators clock in and out and report production on the shop floor
+	RoleOperator: {
+		Code: RoleOperator,
+		Name: "Operator",
+		Permissions: grant(
+			[]string{ResourceShopfloors, ResourceWorkcenters, ResourceOperators, ResourceJobs, ResourceShifts, ResourcePlanning},
+			[]string{ResourceTimeEntries, ResourceOEE},
+		),
+		IsBuiltIn: true,
+	},
+	RoleReadOnly: {
+		Code: RoleReadOnly,
+		Name: "Read only",
+		Permissions: grant(
+			[]string{ResourceShopfloors, ResourceWorkcenters, ResourceOperators, ResourceJobs, ResourceShifts,
+				ResourcePlanning, ResourceTimeEntries, ResourceDowntimes, ResourceOEE, ResourceMachines},
+			nil,
+		),
+		IsBuiltIn: true,
+	},
+}
+
+// IsBuiltIn reports whether code is one of the built-in roles
+func IsBuiltIn(code string) bool {
+	_, ok := builtIn[code]
+	return ok
+}
+
+// Role is a named set of permissions. Built-in roles have no ID.
+type Role struct {
+	ID          uuid.NullUUID `json:"id"`
+	CustomerID  uuid.NullUUID `json:"customer_id"`
+	Code        string        `json:"code"`
+	Name        string        `json:"name"`
+	Permissions []string      `json:"permissions"`
+	IsBuiltIn   bool          `json:"is_built_in"`
+	CreatedAt   *time.Time    `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time    `json:"updated_at,omitempty"`
+}
+
+type RoleRequest struct {
+	CustomerID  string   `json:"customer_id" binding:"omitempty,uuid"`
+	Code        string   `json:"code" binding:"required,max=50"`
+	Name        string   `json:"name" binding:"required,max=100"`
+	Permissions []string `json:"permissions"`
+}
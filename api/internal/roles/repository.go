@@ -0,0 +1,119 @@
+package roles
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Repository interface {
+	Create(ctx context.Context, role Role) (Role, error)
+	FindByID(ctx context.Context, id uuid.UUID) (Role, error)
+	FindByCode(ctx context.Context, customerID uuid.UUID, code string) (Role, error)
+	FindAll(ctx context.Context, customerID *uuid.UUID) ([]Role, error)
+	Update(ctx context.Context, role Role) (Role, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	CountUsers(ctx context.Context, customerID uuid.UUID, code string) (int, error)
+	FindUserRole(ctx context.Context, userID uuid.UUID) (uuid.UUID, string, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(ctx context.Context, role Role) (Role, error) {
+	query := `INSERT INTO roles (id, customer_id, code, name, permissions, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.ExecContext(ctx, query, role.ID, role.CustomerID, role.Code, role.Name, pq.Array(role.Permissions), role.CreatedAt, role.UpdatedAt)
+	if err != nil {
+		return Role{}, err
+	}
+	return role, nil
+}
+
+func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (Role, error) {
+	query := `SELECT id, customer_id, code, name, permissions, created_at, updated_at FROM roles WHERE id = $1`
+	var role Role
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&role.ID, &role.CustomerID, &role.Code, &role.Name, pq.Array(&role.Permissions), &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		return Role{}, err
+	}
+	return role, nil
+}
+
+func (r *repository) FindByCode(ctx context.Context, customerID uuid.UUID, code string) (Role, error) {
+	query := `SELECT id, customer_id, code, name, permissions, created_at, updated_at FROM roles WHERE customer_id = $1 AND code = $2`
+	var role Role
+	err := r.db.QueryRowContext(ctx, query, customerID, code).Scan(&role.ID, &role.CustomerID, &role.Code, &role.Name, pq.Array(&role.Permissions), &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		return Role{}, err
+	}
+	return role, nil
+}
+
+func (r *repository) FindAll(ctx context.Context, customerID *uuid.UUID) ([]Role, error) {
+	query := `SELECT id, customer_id, code, name, permissions, created_at, updated_at FROM roles`
+	var args []interface{}
+	if customerID != nil {
+		query += " WHERE customer_id = $1"
+		args = append(args, *customerID)
+	}
+	query += " ORDER BY name"
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var roles []Role
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.ID, &role.CustomerID, &role.Code, &role.Name, pq.Array(&role.Permissions), &role.CreatedAt, &role.UpdatedAt); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (r *repository) Update(ctx context.Context, role Role) (Role, error) {
+	query := `UPDATE roles SET name = $2, permissions = $3, updated_at = $4 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, role.ID, role.Name, pq.Array(role.Permissions), role.UpdatedAt)
+	if err != nil {
+		return Role{}, err
+	}
+	return role, nil
+}
+
+func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM roles WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// CountUsers returns how many users of the tenant have the role. The ones in
+// the trash don't count: restoring them falls back to the read-only role
+// once theirs is gone.
+func (r *repository) CountUsers(ctx context.Context, customerID uuid.UUID, code string) (int, error) {
+	query := `SELECT COUNT(*) FROM users WHERE customer_id = $1 AND role = $2 AND deleted_at IS NULL`
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, customerID, code).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindUserRole returns the tenant and the role of an active, not deleted user
+func (r *repository) FindUserRole(ctx context.Context, userID uuid.UUID) (uuid.UUID, string, error) {
+	query := `SELECT customer_id, role FROM users WHERE id = $1 AND is_active = TRUE AND deleted_at IS NULL`
+	var customerID uuid.UUID
+	var role string
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&customerID, &role); err != nil {
+		return uuid.Nil, "", err
+	}
+	return customerID, role, nil
+}
@@ -0,0 +1,11 @@
+package roles
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/roles/permissions", handler.Catalogue)
+	router.POST("/roles", handler.Create)
+	router.GET("/roles", handler.FindAll)
+	router.PUT("/roles/:id", handler.Update)
+	router.DELETE("/roles/:id", handler.Delete)
+}
@@ -0,0 +1,289 @@
+package roles
+
+import (
+	"api/internal/apperr"
+	"api/internal/tenant"
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	Catalogue(ctx context.Context) []string
+	Create(ctx context.Context, request RoleRequest) (Role, error)
+	FindAll(ctx context.Context, customerID string) ([]Role, error)
+	Update(ctx context.Context, id string, request RoleRequest) (Role, error)
+	Delete(ctx context.Context, id string) error
+	Resolve(ctx context.Context, customerID uuid.UUID, code string) (Role, error)
+	HasPermission(ctx context.Context, userID uuid.UUID, permission string) (bool, error)
+	CheckGrantable(ctx context.Context, permissions []string) error
+}
+
+// permissionsTTL is how long the permissions of a user are cached. Role
+// changes take effect for logged in users after at most this long.
+const permissionsTTL = 30 * time.Second
+
+// ErrUnknownRole is returned when a code is neither built-in nor a custom role of the tenant
+var ErrUnknownRole = apperr.Validation("unknown_role", "unknown role")
+
+// ErrNotGrantable is returned when the caller creates or assigns a role with
+// permissions they don't hold themselves
+var ErrNotGrantable = apperr.Forbidden("role_not_grantable", "role grants permissions you don't have")
+
+var codePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,39}$`)
+
+type cachedPermissions struct {
+	permissions map[string]bool
+	expires     time.Time
+}
+
+type service struct {
+	repo Repository
+
+	mu    sync.Mutex
+	cache map[uuid.UUID]cachedPermissions
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo, cache: map[uuid.UUID]cachedPermissions{}}
+}
+
+// ValidPermissions checks every permission against the catalogue and adds
+// the read permission of every write one
+func ValidPermissions(permissions []string) ([]string, error) {
+	catalogue := map[string]bool{}
+	for _, permission := range Catalogue() {
+		catalogue[permission] = true
+	}
+	set := map[string]bool{}
+	for _, permission := range permissions {
+		permission = strings.TrimSpace(permission)
+		if !catalogue[permission] {
+			return nil, apperr.Field("permissions", "unknown_permission", "unknown permission "+permission)
+		}
+		set[permission] = true
+	}
+	// Writing implies reading
+	for permission := range set {
+		if resource, ok := strings.CutSuffix(permission, ":"+ActionWrite); ok {
+			set[Permission(resource, ActionRead)] = true
+		}
+	}
+	valid := []string{}
+	for permission := range set {
+		valid = append(valid, permission)
+	}
+	sort.Strings(valid)
+	return valid, nil
+}
+
+func (s *service) Catalogue(ctx context.Context) []string {
+	return Catalogue()
+}
+
+func (s *service) Create(ctx context.Context, request RoleRequest) (Role, error) {
+	scope, err := tenant.Scope(ctx, request.CustomerID)
+	if err != nil {
+		return Role{}, err
+	}
+	if scope == nil {
+		return Role{}, apperr.Field("customer_id", "customer_id_required", "customer_id is required")
+	}
+	code := strings.ToLower(strings.TrimSpace(request.Code))
+	if !codePattern.MatchString(code) {
+		return Role{}, apperr.Field("code", "invalid_code", "code must be lowercase letters, digits or underscores")
+	}
+	if IsBuiltIn(code) {
+		return Role{}, apperr.Conflict("reserved_code", "code is reserved for a built-in role")
+	}
+	permissions, err := ValidPermissions(request.Permissions)
+	if err != nil {
+		return Role{}, err
+	}
+	if err := s.CheckGrantable(ctx, permissions); err != nil {
+		return Role{}, err
+	}
+	now := time.Now()
+	role := Role{
+		ID:          uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		CustomerID:  uuid.NullUUID{UUID: *scope, Valid: true},
+		Code:        code,
+		Name:        request.Name,
+		Permissions: permissions,
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+	}
+	return s.repo.Create(ctx, role)
+}
+
+// FindAll returns the built-in roles followed by the custom roles of the tenant
+func (s *service) FindAll(ctx context.Context, customerID string) ([]Role, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	custom, err := s.repo.FindAll(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	roles := []Role{}
+	for _, code := range []string{RoleOwner, RolePlanner, RoleSupervisor, RoleOperator, RoleReadOnly} {
+		roles = append(roles, builtIn[code])
+	}
+	return append(roles, custom...), nil
+}
+
+func (s *service) findOwned(ctx context.Context, id string) (Role, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Role{}, err
+	}
+	role, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return Role{}, err
+	}
+	if err := tenant.Check(ctx, role.CustomerID.UUID); err != nil {
+		return Role{}, err
+	}
+	return role, nil
+}
+
+// Update changes the name and permissions of a custom role. The code is
+// what users reference, so it can't change.
+func (s *service) Update(ctx context.Context, id string, request RoleRequest) (Role, error) {
+	role, err := s.findOwned(ctx, id)
+	if err != nil {
+		return Role{}, err
+	}
+	permissions, err := ValidPermissions(request.Permissions)
+	if err != nil {
+		return Role{}, err
+	}
+	if err := s.CheckGrantable(ctx, permissions); err != nil {
+		return Role{}, err
+	}
+	now := time.Now()
+	role.Name = request.Name
+	role.Permissions = permissions
+	role.UpdatedAt = &now
+	role, err = s.repo.Update(ctx, role)
+	if err != nil {
+		return Role{}, err
+	}
+	s.flush()
+	return role, nil
+}
+
+func (s *service) Delete(ctx context.Context, id string) error {
+	role, err := s.findOwned(ctx, id)
+	if err != nil {
+		return err
+	}
+	users, err := s.repo.CountUsers(ctx, role.CustomerID.UUID, role.Code)
+	if err != nil {
+		return err
+	}
+	if users > 0 {
+		return apperr.Conflict("role_in_use", "role is assigned to users")
+	}
+	if err := s.repo.Delete(ctx, role.ID.UUID); err != nil {
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+// Resolve returns the built-in or custom role with the given code
+func (s *service) Resolve(ctx context.Context, customerID uuid.UUID, code string) (Role, error) {
+	if role, ok := builtIn[code]; ok {
+		return role, nil
+	}
+	role, err := s.repo.FindByCode(ctx, customerID, code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Role{}, ErrUnknownRole
+	}
+	return role, err
+}
+
+// HasPermission reports whether the role of the user grants permission.
+// Inactive or unknown users have no permissions.
+func (s *service) HasPermission(ctx context.Context, userID uuid.UUID, permission string) (bool, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[userID]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.permissions[permission], nil
+	}
+
+	permissions := map[string]bool{}
+	customerID, code, err := s.repo.FindUserRole(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if err == nil {
+		role, err := s.Resolve(ctx, customerID, code)
+		if err != nil && !errors.Is(err, ErrUnknownRole) {
+			return false, err
+		}
+		for _, granted := range role.Permissions {
+			permissions[granted] = true
+		}
+	}
+
+	s.mu.Lock()
+	s.cache[userID] = cachedPermissions{permissions: permissions, expires: time.Now().Add(permissionsTTL)}
+	s.mu.Unlock()
+	return permissions[permission], nil
+}
+
+// CheckGrantable rejects permissions the caller doesn't hold, so nobody can
+// hand out more access than they have. System admins grant anything, API
+// keys what their scopes allow, and flows without a logged in user, such as
+// single sign-on, are trusted.
+func (s *service) CheckGrantable(ctx context.Context, permissions []string) error {
+	if isAdmin, _ := ctx.Value("is_admin").(bool); isAdmin {
+		return nil
+	}
+	userIDVal, _ := ctx.Value("user_id").(string)
+	if userIDVal == "" {
+		return nil
+	}
+	if _, ok := ctx.Value("api_key_id").(string); ok {
+		scopes, _ := ctx.Value("scopes").([]string)
+		for _, permission := range permissions {
+			if !slices.Contains(scopes, permission) {
+				return ErrNotGrantable
+			}
+		}
+		return nil
+	}
+	userID, err := uuid.Parse(userIDVal)
+	if err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		allowed, err := s.HasPermission(ctx, userID, permission)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return ErrNotGrantable
+		}
+	}
+	return nil
+}
+
+// flush drops the cached permissions after a custom role changes
+func (s *service) flush() {
+	s.mu.Lock()
+	s.cache = map[uuid.UUID]cachedPermissions{}
+	s.mu.Unlock()
+}
@@ -0,0 +1,120 @@
+package roles
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// stubRepository holds one user with a built-in role and one custom role of
+// their tenant. Any other method panics through the embedded interface.
+type stubRepository struct {
+	Repository
+	customerID uuid.UUID
+	userID     uuid.UUID
+	code       string
+	custom     Role
+}
+
+func (r stubRepository) FindUserRole(ctx context.Context, userID uuid.UUID) (uuid.UUID, string, error) {
+	return r.customerID, r.code, nil
+}
+
+func (r stubRepository) FindByID(ctx context.Context, id uuid.UUID) (Role, error) {
+	return r.custom, nil
+}
+
+func (r stubRepository) Create(ctx context.Context, role Role) (Role, error) {
+	return role, nil
+}
+
+func (r stubRepository) Update(ctx context.Context, role Role) (Role, error) {
+	return role, nil
+}
+
+func newStub(code string) stubRepository {
+	customerID := uuid.New()
+	return stubRepository{
+		customerID: customerID,
+		userID:     uuid.New(),
+		code:       code,
+		custom: Role{
+			ID:          uuid.NullUUID{UUID: uuid.New(), Valid: true},
+			CustomerID:  uuid.NullUUID{UUID: customerID, Valid: true},
+			Code:        "custom",
+			Permissions: []string{Permission(ResourceJobs, ActionRead)},
+		},
+	}
+}
+
+func userContext(repo stubRepository) context.Context {
+	ctx := context.WithValue(context.Background(), "is_admin", false)
+	ctx = context.WithValue(ctx, "customer_id", repo.customerID)
+	return context.WithValue(ctx, "user_id", repo.userID.String())
+}
+
+// TestCreateRoleGrantable makes sure a role can only be created with the
+// permissions of the caller
+func TestCreateRoleGrantable(t *testing.T) {
+	repo := newStub(RolePlanner)
+	service := NewService(repo)
+	ctx := userContext(repo)
+
+	held := RoleRequest{Code: "scheduler", Name: "Scheduler", Permissions: []string{Permission(ResourceJobs, ActionWrite)}}
+	if _, err := service.Create(ctx, held); err != nil {
+		t.Fatalf("creating a role with held permissions: %v", err)
+	}
+
+	escalating := RoleRequest{Code: "escalated", Name: "Escalated", Permissions: []string{Permission(ResourceRoles, ActionWrite)}}
+	if _, err := service.Create(ctx, escalating); !errors.Is(err, ErrNotGrantable) {
+		t.Fatalf("creating a role with permissions the caller lacks answered %v, want ErrNotGrantable", err)
+	}
+}
+
+// TestUpdateRoleGrantable makes sure a role can't be widened beyond the
+// permissions of the caller
+func TestUpdateRoleGrantable(t *testing.T) {
+	repo := newStub(RolePlanner)
+	service := NewService(repo)
+	ctx := userContext(repo)
+	id := repo.custom.ID.UUID.String()
+
+	held := RoleRequest{Name: "Custom", Permissions: []string{Permission(ResourceShifts, ActionWrite)}}
+	if _, err := service.Update(ctx, id, held); err != nil {
+		t.Fatalf("updating a role with held permissions: %v", err)
+	}
+
+	escalating := RoleRequest{Name: "Custom", Permissions: []string{Permission(ResourceUsers, ActionWrite)}}
+	if _, err := service.Update(ctx, id, escalating); !errors.Is(err, ErrNotGrantable) {
+		t.Fatalf("updating a role with permissions the caller lacks answered %v, want ErrNotGrantable", err)
+	}
+}
+
+// TestCheckGrantable covers the callers that grant without a role: system
+// admins, API keys and flows without a user
+func TestCheckGrantable(t *testing.T) {
+	repo := newStub(RoleReadOnly)
+	service := NewService(repo)
+	write := []string{Permission(ResourceJobs, ActionWrite)}
+
+	admin := context.WithValue(context.Background(), "is_admin", true)
+	if err := service.CheckGrantable(admin, write); err != nil {
+		t.Errorf("system admin: %v", err)
+	}
+	if err := service.CheckGrantable(context.Background(), write); err != nil {
+		t.Errorf("no user: %v", err)
+	}
+
+	key := context.WithValue(userContext(repo), "api_key_id", uuid.NewString())
+	if err := service.CheckGrantable(context.WithValue(key, "scopes", write), write); err != nil {
+		t.Errorf("API key with the scope: %v", err)
+	}
+	if err := service.CheckGrantable(context.WithValue(key, "scopes", []string{}), write); !errors.Is(err, ErrNotGrantable) {
+		t.Errorf("API key without the scope answered %v, want ErrNotGrantable", err)
+	}
+	if err := service.CheckGrantable(userContext(repo), write); !errors.Is(err, ErrNotGrantable) {
+		t.Errorf("read-only user answered %v, want ErrNotGrantable", err)
+	}
+}
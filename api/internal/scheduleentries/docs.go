@@ -0,0 +1,32 @@
+package scheduleentries
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/schedule-entries/sync", Tag: "planning", Summary: "Replace the planning of a shop floor and day, moving the dropped entries to the trash; answers the downtime warnings", Request: SyncRequest{}},
+	{Method: "POST", Path: "/api/schedule-entries", Tag: "planning", Summary: "Create a schedule entry", Request: ScheduleEntryRequest{}, Response: ScheduleEntry{}},
+	{Method: "GET", Path: "/api/schedule-entries", Tag: "planning", Summary: "List schedule entries", Response: []ScheduleEntry{}, List: &listSpec, Query: []openapi.Param{
+		openapi.CustomerID,
+		{Name: "shopfloor_id"},
+		{Name: "workcenter_id"},
+		{Name: "operator_id"},
+		{Name: "job_id"},
+		{Name: "date", Description: "YYYY-MM-DD, same as from and to"},
+		{Name: "from", Description: "YYYY-MM-DD"},
+		{Name: "to", Description: "YYYY-MM-DD"},
+	}},
+	{Method: "GET", Path: "/api/schedule-entries/trash", Tag: "planning", Summary: "List deleted schedule entries", Response: []ScheduleEntry{}, List: &trashSpec, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "GET", Path: "/api/schedule-entries/:id", Tag: "planning", Summary: "Get a schedule entry", Response: ScheduleEntry{}},
+	{Method: "GET", Path: "/api/schedule-entries/filtered", Tag: "planning", Summary: "Planning of a shop floor and day", Response: []ScheduleEntry{}, Query: []openapi.Param{
+		{Name: "shopfloor_id"},
+		{Name: "date", Description: "YYYY-MM-DD"},
+	}},
+	{Method: "GET", Path: "/api/schedule-entries/validate", Tag: "planning", Summary: "Downtime warnings of the planning of a shop floor and day", Response: []ScheduleWarning{}, Query: []openapi.Param{
+		{Name: "shopfloor_id"},
+		{Name: "date", Description: "YYYY-MM-DD"},
+	}},
+	{Method: "PUT", Path: "/api/schedule-entries/:id", Tag: "planning", Summary: "Update a schedule entry", Request: ScheduleEntryRequest{}, Response: ScheduleEntry{}},
+	{Method: "DELETE", Path: "/api/schedule-entries/:id", Tag: "planning", Summary: "Move a schedule entry to the trash"},
+	{Method: "POST", Path: "/api/schedule-entries/:id/restore", Tag: "planning", Summary: "Restore a deleted schedule entry", Response: ScheduleEntry{}},
+}
@@ -1,6 +1,9 @@
 package scheduleentries
 
 import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -19,16 +22,21 @@ func (h *Handler) Create(c *gin.Context) {
 	ctx := c.Request.Context()
 	var request ScheduleEntryRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 
 	response, err := h.service.Create(ctx, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Schedule entry created successfully", "data": response})
+	warnings, err := h.service.Warnings(ctx, []ScheduleEntry{response})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule entry created successfully", "data": response, "warnings": warnings})
 }
 
 func (h *Handler) FindByID(c *gin.Context) {
@@ -36,7 +44,11 @@ func (h *Handler) FindByID(c *gin.Context) {
 	id := c.Param("id")
 	response, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("schedule_entry_not_found", "Schedule entry not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Schedule entry found successfully", "data": response})
@@ -85,12 +97,17 @@ func (h *Handler) FindAll(c *gin.Context) {
 		filter.EndDate = &to
 	}
 
-	response, err := h.service.Search(ctx, filter)
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": response})
+	response, total, err := h.service.Search(ctx, filter, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindFiltered(c *gin.Context) {
@@ -109,7 +126,11 @@ func (h *Handler) FindFiltered(c *gin.Context) {
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shop_floor_not_found", "Shop floor not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"data": response})
@@ -120,46 +141,108 @@ func (h *Handler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var request ScheduleEntryRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Update(ctx, id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("schedule_entry_not_found", "Schedule entry not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Schedule entry updated successfully", "data": response})
+	warnings, err := h.service.Warnings(ctx, []ScheduleEntry{response})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule entry updated successfully", "data": response, "warnings": warnings})
 }
 
 func (h *Handler) Delete(c *gin.Context) {
 	ctx := c.Request.Context()
 	id := c.Param("id")
 	if err := h.service.Delete(ctx, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("schedule_entry_not_found", "Schedule entry not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Schedule entry deleted successfully"})
 }
 
-func (h *Handler) Sync(c *gin.Context) {
+func (h *Handler) Trash(c *gin.Context) {
 	ctx := c.Request.Context()
-	
-	type SyncRequest struct {
-		ShopfloorID string                 `json:"shopfloor_id" binding:"required"`
-		Date        string                 `json:"date" binding:"required"`
-		Entries     []ScheduleEntryRequest `json:"entries" binding:"required"`
+	params, err := listing.Parse(c, trashSpec)
+	if err != nil {
+		c.Error(err)
+		return
 	}
+	response, total, err := h.service.Trash(ctx, c.Query("customer_id"), params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted schedule entries found successfully", "data": response, "meta": params.Page(total)})
+}
+
+func (h *Handler) Restore(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.Restore(ctx, c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("schedule_entry_not_found", "Schedule entry not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule entry restored successfully", "data": response})
+}
+
+func (h *Handler) Sync(c *gin.Context) {
+	ctx := c.Request.Context()
 
 	var req SyncRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 
-	if err := h.service.Sync(ctx, req.ShopfloorID, req.Date, req.Entries); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	warnings, err := h.service.Sync(ctx, req.ShopfloorID, req.Date, req.Entries)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shop_floor_not_found", "Shop floor not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Planning synced successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Planning synced successfully", "warnings": warnings})
+}
+
+// Validate returns the downtime warnings of the planning of a shopfloor and day
+func (h *Handler) Validate(c *gin.Context) {
+	ctx := c.Request.Context()
+	shopfloorID := c.Query("shopfloor_id")
+	date := c.Query("date")
+	if shopfloorID == "" || date == "" {
+		c.Error(apperr.Validation("invalid_request", "shopfloor_id and date are required"))
+		return
+	}
+	warnings, err := h.service.Validate(ctx, shopfloorID, date)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shop_floor_not_found", "Shop floor not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Planning validated successfully", "data": warnings})
 }
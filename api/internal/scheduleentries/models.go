@@ -21,19 +21,62 @@ type ScheduleEntry struct {
 	IsCompleted bool   `json:"is_completed"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 }
 
 type ScheduleEntryRequest struct {
-	ID          string `json:"id"`
-	CustomerID  string `json:"customer_id"`
-	ShopfloorID string `json:"shopfloor_id"`
-	ShiftID     string `json:"shift_id"`
-	WorkcenterID string `json:"workcenter_id"`
-	JobID       string `json:"job_id"`
-	OperatorID  string `json:"operator_id"`
+	ID          string `json:"id" binding:"omitempty,uuid"`
+	CustomerID  string `json:"customer_id" binding:"omitempty,uuid"`
+	ShopfloorID string `json:"shopfloor_id" binding:"omitempty,uuid"`
+	ShiftID     string `json:"shift_id" binding:"omitempty,uuid"`
+	WorkcenterID string `json:"workcenter_id" binding:"omitempty,uuid"`
+	JobID       string `json:"job_id" binding:"omitempty,uuid"`
+	OperatorID  string `json:"operator_id" binding:"omitempty,uuid"`
 	Date string `json:"date"`
-	Order int `json:"order"`
+	Order int `json:"order" binding:"gte=0"`
 	StartTime   *time.Time `json:"start_time"`
-	EndTime     *time.Time `json:"end_time"`
+	EndTime     *time.Time `json:"end_time" binding:"omitempty,gtfield=StartTime"`
 	IsCompleted bool   `json:"is_completed"`
-}
\ No newline at end of file
+}
+
+// SyncRequest replaces the planning of a shop floor and day
+type SyncRequest struct {
+	ShopfloorID string                 `json:"shopfloor_id" binding:"required,uuid"`
+	Date        string                 `json:"date" binding:"required"`
+	Entries     []ScheduleEntryRequest `json:"entries" binding:"required,dive"`
+}
+
+// SchedulePublishedPayload is the data of the schedule.published event
+type SchedulePublishedPayload struct {
+	ShopfloorID uuid.UUID       `json:"shopfloor_id"`
+	Date        string          `json:"date"`
+	Entries     []ScheduleEntry `json:"entries"`
+}
+
+// ScheduleWarning flags a planned entry that overlaps a workcenter downtime.
+// Warnings don't block saving the planning.
+type ScheduleWarning struct {
+	EntryID      uuid.UUID `json:"entry_id"`
+	WorkcenterID uuid.UUID `json:"workcenter_id"`
+	DowntimeID   uuid.UUID `json:"downtime_id"`
+	Kind         string    `json:"kind"`
+	Reason       string    `json:"reason"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      *time.Time `json:"end_time"`
+	Message      string    `json:"message"`
+}
+
+// downtimeWindow is a workcenter downtime as seen by the planning
+type downtimeWindow struct {
+	ID           uuid.UUID
+	WorkcenterID uuid.UUID
+	Kind         string
+	Reason       string
+	StartTime    time.Time
+	EndTime      *time.Time
+}
+
+type shiftTimes struct {
+	StartTime time.Time
+	EndTime   time.Time
+}
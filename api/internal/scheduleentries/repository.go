@@ -1,11 +1,16 @@
 package scheduleentries
 
 import (
+	"api/internal/listing"
+	"api/internal/outbox"
+	"api/internal/shopfloors"
+	"api/internal/trash"
 	"context"
 	"database/sql"
-	"fmt"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type ScheduleFilter struct {
@@ -19,6 +24,26 @@ type ScheduleFilter struct {
 	EndDate      *string // YYYY-MM-DD
 }
 
+// listSpec is what the schedule entry list can be sorted and filtered on,
+// besides the filters of ScheduleFilter
+var listSpec = listing.Spec{
+	Sort: map[string]string{
+		"date":         "date",
+		"order":        `"order"`,
+		"start_time":   "start_time",
+		"end_time":     "end_time",
+		"is_completed": "is_completed",
+		"created_at":   "created_at",
+	},
+	Filter: map[string]string{
+		"is_completed": "is_completed",
+	},
+	Default: []listing.Order{{Field: "date", Desc: true}, {Field: "order"}},
+}
+
+// trashSpec is what the trash can be sorted and filtered on
+var trashSpec = trash.Spec(listSpec)
+
 type Repository interface {
 	Create(ctx context.Context, entry ScheduleEntry) (ScheduleEntry, error)
 	FindByID(ctx context.Context, id uuid.UUID) (ScheduleEntry, error)
@@ -26,10 +51,21 @@ type Repository interface {
 	FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]ScheduleEntry, error)
 	FindByShopfloorAndDate(ctx context.Context, shopfloorID uuid.UUID, date string) ([]ScheduleEntry, error)
 	FindByOperatorAndDate(ctx context.Context, operatorID uuid.UUID, date string) ([]ScheduleEntry, error)
-	Search(ctx context.Context, filter ScheduleFilter) ([]ScheduleEntry, error)
-	Update(ctx context.Context, entry ScheduleEntry) (ScheduleEntry, error)
+	Search(ctx context.Context, filter ScheduleFilter, params listing.Params) ([]ScheduleEntry, int, error)
+	FindShopfloorCustomerID(ctx context.Context, shopfloorID uuid.UUID) (uuid.UUID, error)
+	FindOperatorCustomerID(ctx context.Context, operatorID uuid.UUID) (uuid.UUID, error)
+	FindShiftCustomerID(ctx context.Context, shiftID uuid.UUID) (uuid.UUID, error)
+	FindJobCustomerID(ctx context.Context, jobID uuid.UUID) (uuid.UUID, error)
+	FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error)
+	WorkcenterInShopfloor(ctx context.Context, workcenterID, shopfloorID uuid.UUID) (bool, error)
+	Update(ctx context.Context, entry ScheduleEntry, events ...outbox.Event) (ScheduleEntry, error)
 	Delete(ctx context.Context, id uuid.UUID) error
-	Sync(ctx context.Context, shopfloorID uuid.UUID, date string, entries []ScheduleEntry) error
+	FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]ScheduleEntry, int, error)
+	FindDeletedByID(ctx context.Context, id uuid.UUID) (ScheduleEntry, error)
+	Restore(ctx context.Context, id uuid.UUID) error
+	Sync(ctx context.Context, shopfloorID uuid.UUID, date string, entries []ScheduleEntry, events ...outbox.Event) error
+	FindShiftTimes(ctx context.Context, shiftIDs []string) (map[uuid.UUID]shiftTimes, error)
+	FindDowntimes(ctx context.Context, workcenterIDs []string, from, to time.Time) ([]downtimeWindow, error)
 }
 
 type repository struct {
@@ -60,7 +96,7 @@ func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (ScheduleEntry,
 	query := `SELECT 
 		id, customer_id, shopfloor_id, shift_id, workcenter_id, job_id, operator_id,
 		date, "order", start_time, end_time, is_completed, created_at, updated_at
-	FROM schedule_entries WHERE id = $1`
+	FROM schedule_entries WHERE id = $1 AND deleted_at IS NULL`
 
 	row := r.db.QueryRowContext(ctx, query, id)
 	var entry ScheduleEntry
@@ -78,7 +114,7 @@ func (r *repository) FindAll(ctx context.Context) ([]ScheduleEntry, error) {
 	query := `SELECT 
 		id, customer_id, shopfloor_id, shift_id, workcenter_id, job_id, operator_id,
 		date, "order", start_time, end_time, is_completed, created_at, updated_at
-	FROM schedule_entries`
+	FROM schedule_entries WHERE deleted_at IS NULL`
 
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
@@ -105,9 +141,14 @@ func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID)
 	query := `SELECT 
 		id, customer_id, shopfloor_id, shift_id, workcenter_id, job_id, operator_id,
 		date, "order", start_time, end_time, is_completed, created_at, updated_at
-	FROM schedule_entries WHERE customer_id = $1`
+	FROM schedule_entries WHERE customer_id = $1 AND deleted_at IS NULL`
+	args := []interface{}{customerID}
+	// Users restricted to some shop floors only see their planning
+	condition, scopeArgs := shopfloors.ScopeCondition(ctx, "shopfloor_id", 2)
+	query += condition
+	args = append(args, scopeArgs...)
 
-	rows, err := r.db.QueryContext(ctx, query, customerID)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -128,108 +169,188 @@ func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID)
 	return entries, nil
 }
 
-func (r *repository) Search(ctx context.Context, filter ScheduleFilter) ([]ScheduleEntry, error) {
-	query := `SELECT 
-		id, customer_id, shopfloor_id, shift_id, workcenter_id, job_id, operator_id,
-		date, "order", start_time, end_time, is_completed, created_at, updated_at
-	FROM schedule_entries 
-	WHERE 1=1`
-
-	var args []interface{}
-	argId := 1
-
+// Search returns a page of the entries matching the filter
+func (r *repository) Search(ctx context.Context, filter ScheduleFilter, params listing.Params) ([]ScheduleEntry, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
 	if filter.CustomerID != nil {
-		query += fmt.Sprintf(" AND customer_id = $%d", argId)
-		args = append(args, *filter.CustomerID)
-		argId++
+		q.Where("customer_id = ?", *filter.CustomerID)
 	}
 	if filter.ShopfloorID != nil {
-		query += fmt.Sprintf(" AND shopfloor_id = $%d", argId)
-		args = append(args, *filter.ShopfloorID)
-		argId++
+		// Includes the entries of the areas and lines below the shop floor
+		shopfloors.InSubtree(q, "shopfloor_id", *filter.ShopfloorID)
 	}
 	if filter.ShiftID != nil {
-		query += fmt.Sprintf(" AND shift_id = $%d", argId)
-		args = append(args, *filter.ShiftID)
-		argId++
+		q.Where("shift_id = ?", *filter.ShiftID)
 	}
 	if filter.WorkcenterID != nil {
-		query += fmt.Sprintf(" AND workcenter_id = $%d", argId)
-		args = append(args, *filter.WorkcenterID)
-		argId++
+		q.Where("workcenter_id = ?", *filter.WorkcenterID)
 	}
 	if filter.JobID != nil {
-		query += fmt.Sprintf(" AND job_id = $%d", argId)
-		args = append(args, *filter.JobID)
-		argId++
+		q.Where("job_id = ?", *filter.JobID)
 	}
 	if filter.OperatorID != nil {
-		query += fmt.Sprintf(" AND operator_id = $%d", argId)
-		args = append(args, *filter.OperatorID)
-		argId++
+		q.Where("operator_id = ?", *filter.OperatorID)
 	}
 	if filter.StartDate != nil {
-		query += fmt.Sprintf(" AND date::date >= $%d::date", argId)
-		args = append(args, *filter.StartDate)
-		argId++
+		q.Where("date::date >= ?::date", *filter.StartDate)
 	}
 	if filter.EndDate != nil {
-		query += fmt.Sprintf(" AND date::date <= $%d::date", argId)
-		args = append(args, *filter.EndDate)
-		argId++
-	}
-
-	query += ` ORDER BY date DESC, "order" ASC`
-
-	rows, err := r.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
+		q.Where("date::date <= ?::date", *filter.EndDate)
 	}
-	defer rows.Close()
+	shopfloors.Restrict(ctx, q, "shopfloor_id")
 
-	var entries []ScheduleEntry
-	for rows.Next() {
+	return listing.Find(ctx, r.db, q, `SELECT 
+		id, customer_id, shopfloor_id, shift_id, workcenter_id, job_id, operator_id,
+		date, "order", start_time, end_time, is_completed, created_at, updated_at
+	FROM schedule_entries`, func(rows *sql.Rows) (ScheduleEntry, error) {
 		var entry ScheduleEntry
 		err := rows.Scan(
 			&entry.ID, &entry.CustomerID, &entry.ShopfloorID, &entry.ShiftID, &entry.WorkcenterID, &entry.JobID, &entry.OperatorID,
 			&entry.Date, &entry.Order, &entry.StartTime, &entry.EndTime, &entry.IsCompleted, &entry.CreatedAt, &entry.UpdatedAt,
 		)
-		if err != nil {
-			return nil, err
-		}
-		entries = append(entries, entry)
+		return entry, err
+	})
+}
+
+func (r *repository) FindShopfloorCustomerID(ctx context.Context, shopfloorID uuid.UUID) (uuid.UUID, error) {
+	return r.findCustomerID(ctx, "shopfloors", shopfloorID)
+}
+
+func (r *repository) FindOperatorCustomerID(ctx context.Context, operatorID uuid.UUID) (uuid.UUID, error) {
+	return r.findCustomerID(ctx, "operators", operatorID)
+}
+
+func (r *repository) FindShiftCustomerID(ctx context.Context, shiftID uuid.UUID) (uuid.UUID, error) {
+	return r.findCustomerID(ctx, "shifts", shiftID)
+}
+
+func (r *repository) FindJobCustomerID(ctx context.Context, jobID uuid.UUID) (uuid.UUID, error) {
+	return r.findCustomerID(ctx, "jobs", jobID)
+}
+
+func (r *repository) FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error) {
+	return r.findCustomerID(ctx, "workcenters", workcenterID)
+}
+
+// findCustomerID returns the tenant of a live record of table
+func (r *repository) findCustomerID(ctx context.Context, table string, id uuid.UUID) (uuid.UUID, error) {
+	query := `SELECT customer_id FROM ` + table + ` WHERE id = $1 AND deleted_at IS NULL`
+	var customerID uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&customerID); err != nil {
+		return uuid.Nil, err
 	}
-	return entries, nil
+	return customerID, nil
 }
 
-func (r *repository) Update(ctx context.Context, entry ScheduleEntry) (ScheduleEntry, error) {
+// WorkcenterInShopfloor reports whether a workcenter can be planned on a
+// shop floor: it has no shop floor or one below it
+func (r *repository) WorkcenterInShopfloor(ctx context.Context, workcenterID, shopfloorID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM workcenters WHERE id = $1 AND (shop_floor_id IS NULL OR ` + shopfloors.SubtreeCondition("shop_floor_id", 2) + `))`
+	var ok bool
+	err := r.db.QueryRowContext(ctx, query, workcenterID, shopfloorID).Scan(&ok)
+	return ok, err
+}
+
+// Update saves the entry and writes the given outbox events in the same transaction
+func (r *repository) Update(ctx context.Context, entry ScheduleEntry, events ...outbox.Event) (ScheduleEntry, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ScheduleEntry{}, err
+	}
+	defer tx.Rollback()
+
 	query := `UPDATE schedule_entries SET 
 		customer_id = $2, shopfloor_id = $3, shift_id = $4, workcenter_id = $5, job_id = $6, operator_id = $7,
 		date = $8, "order" = $9, start_time = $10, end_time = $11, is_completed = $12, updated_at = $13
 	WHERE id = $1`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = tx.ExecContext(ctx, query,
 		entry.ID, entry.CustomerID, entry.ShopfloorID, entry.ShiftID, entry.WorkcenterID, entry.JobID, entry.OperatorID,
 		entry.Date, entry.Order, entry.StartTime, entry.EndTime, entry.IsCompleted, entry.UpdatedAt,
 	)
 	if err != nil {
 		return ScheduleEntry{}, err
 	}
+	if err := outbox.Write(ctx, tx, events...); err != nil {
+		return ScheduleEntry{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return ScheduleEntry{}, err
+	}
 	return entry, nil
 }
 
+// Delete moves the entry to the trash
 func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM schedule_entries WHERE id = $1`
+	query := `UPDATE schedule_entries SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
 	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
 
+// FindDeleted returns a page of the entries in the trash of the tenant, or
+// of every tenant when customerID is nil
+func (r *repository) FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]ScheduleEntry, int, error) {
+	q := listing.NewQuery(trashSpec, params)
+	q.Where("deleted_at IS NOT NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return listing.Find(ctx, r.db, q, `SELECT 
+		id, customer_id, shopfloor_id, shift_id, workcenter_id, job_id, operator_id,
+		date, "order", start_time, end_time, is_completed, created_at, updated_at, deleted_at
+	FROM schedule_entries`, func(rows *sql.Rows) (ScheduleEntry, error) {
+		var entry ScheduleEntry
+		err := rows.Scan(
+			&entry.ID, &entry.CustomerID, &entry.ShopfloorID, &entry.ShiftID, &entry.WorkcenterID, &entry.JobID, &entry.OperatorID,
+			&entry.Date, &entry.Order, &entry.StartTime, &entry.EndTime, &entry.IsCompleted, &entry.CreatedAt, &entry.UpdatedAt, &entry.DeletedAt,
+		)
+		return entry, err
+	})
+}
+
+func (r *repository) FindDeletedByID(ctx context.Context, id uuid.UUID) (ScheduleEntry, error) {
+	query := `SELECT 
+		id, customer_id, shopfloor_id, shift_id, workcenter_id, job_id, operator_id,
+		date, "order", start_time, end_time, is_completed, created_at, updated_at, deleted_at
+	FROM schedule_entries WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	var entry ScheduleEntry
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&entry.ID, &entry.CustomerID, &entry.ShopfloorID, &entry.ShiftID, &entry.WorkcenterID, &entry.JobID, &entry.OperatorID,
+		&entry.Date, &entry.Order, &entry.StartTime, &entry.EndTime, &entry.IsCompleted, &entry.CreatedAt, &entry.UpdatedAt, &entry.DeletedAt,
+	)
+	if err != nil {
+		return ScheduleEntry{}, err
+	}
+	return entry, nil
+}
+
+// Restore takes the entry out of the trash unless its shop floor, shift,
+// workcenter, job or operator is still in it
+func (r *repository) Restore(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE schedule_entries SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL
+	AND ` + trash.ScheduleEntryParentsLive
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return trash.ErrParentDeleted
+	}
+	return nil
+}
+
 func (r *repository) FindByShopfloorAndDate(ctx context.Context, shopfloorID uuid.UUID, date string) ([]ScheduleEntry, error) {
 	query := `SELECT 
 		id, customer_id, shopfloor_id, shift_id, workcenter_id, job_id, operator_id,
 		date, "order", start_time, end_time, is_completed, created_at, updated_at
 	FROM schedule_entries 
-	WHERE shopfloor_id = $1 AND date::date = $2::date
+	WHERE shopfloor_id = $1 AND date::date = $2::date AND deleted_at IS NULL
 	ORDER BY "order" ASC`
 
 	rows, err := r.db.QueryContext(ctx, query, shopfloorID, date)
@@ -258,7 +379,7 @@ func (r *repository) FindByOperatorAndDate(ctx context.Context, operatorID uuid.
 		id, customer_id, shopfloor_id, shift_id, workcenter_id, job_id, operator_id,
 		date, "order", start_time, end_time, is_completed, created_at, updated_at
 	FROM schedule_entries 
-	WHERE operator_id = $1 AND date::date = $2::date
+	WHERE operator_id = $1 AND date::date = $2::date AND deleted_at IS NULL
 	ORDER BY "order" ASC`
 
 	rows, err := r.db.QueryContext(ctx, query, operatorID, date)
@@ -282,33 +403,62 @@ func (r *repository) FindByOperatorAndDate(ctx context.Context, operatorID uuid.
 	return entries, nil
 }
 
-func (r *repository) Sync(ctx context.Context, shopfloorID uuid.UUID, date string, entries []ScheduleEntry) error {
+// Sync replaces the planning of a shop floor and day. The entries it keeps
+// are updated in place, the ones it drops go to the trash and the new ones
+// are inserted. An entry ID of another planning answers a unique violation.
+func (r *repository) Sync(ctx context.Context, shopfloorID uuid.UUID, date string, entries []ScheduleEntry, events ...outbox.Event) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Delete existing for this shopfloor and date
-	deleteQuery := `DELETE FROM schedule_entries WHERE shopfloor_id = $1 AND date::date = $2::date`
-	if _, err := tx.ExecContext(ctx, deleteQuery, shopfloorID, date); err != nil {
+	ids := make([]uuid.UUID, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	trashQuery := `UPDATE schedule_entries SET deleted_at = NOW()
+	WHERE shopfloor_id = $1 AND date::date = $2::date AND deleted_at IS NULL AND NOT (id = ANY($3::uuid[]))`
+	if _, err := tx.ExecContext(ctx, trashQuery, shopfloorID, date, pq.Array(ids)); err != nil {
 		return err
 	}
 
-	// Insert new ones
+	updateQuery := `UPDATE schedule_entries SET
+		customer_id = $2, shopfloor_id = $3, shift_id = $4, workcenter_id = $5, job_id = $6, operator_id = $7,
+		date = $8, "order" = $9, start_time = $10, end_time = $11, is_completed = $12, updated_at = $13
+	WHERE id = $1 AND shopfloor_id = $14 AND date::date = $15::date AND deleted_at IS NULL`
+	update, err := tx.PrepareContext(ctx, updateQuery)
+	if err != nil {
+		return err
+	}
+	defer update.Close()
+
 	insertQuery := `INSERT INTO schedule_entries (
 		id, customer_id, shopfloor_id, shift_id, workcenter_id, job_id, operator_id,
 		date, "order", start_time, end_time, is_completed, created_at, updated_at
 	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
-
-	stmt, err := tx.PrepareContext(ctx, insertQuery)
+	insert, err := tx.PrepareContext(ctx, insertQuery)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	defer insert.Close()
 
 	for _, entry := range entries {
-		_, err := stmt.ExecContext(ctx,
+		result, err := update.ExecContext(ctx,
+			entry.ID, entry.CustomerID, entry.ShopfloorID, entry.ShiftID, entry.WorkcenterID, entry.JobID, entry.OperatorID,
+			entry.Date, entry.Order, entry.StartTime, entry.EndTime, entry.IsCompleted, entry.UpdatedAt, shopfloorID, date,
+		)
+		if err != nil {
+			return err
+		}
+		kept, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if kept > 0 {
+			continue
+		}
+		_, err = insert.ExecContext(ctx,
 			entry.ID, entry.CustomerID, entry.ShopfloorID, entry.ShiftID, entry.WorkcenterID, entry.JobID, entry.OperatorID,
 			entry.Date, entry.Order, entry.StartTime, entry.EndTime, entry.IsCompleted, entry.CreatedAt, entry.UpdatedAt,
 		)
@@ -317,5 +467,50 @@ func (r *repository) Sync(ctx context.Context, shopfloorID uuid.UUID, date strin
 		}
 	}
 
+	if err := outbox.Write(ctx, tx, events...); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
+
+func (r *repository) FindShiftTimes(ctx context.Context, shiftIDs []string) (map[uuid.UUID]shiftTimes, error) {
+	query := `SELECT id, start_time, end_time FROM shifts WHERE id = ANY($1::uuid[])`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(shiftIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	times := map[uuid.UUID]shiftTimes{}
+	for rows.Next() {
+		var id uuid.UUID
+		var shift shiftTimes
+		if err := rows.Scan(&id, &shift.StartTime, &shift.EndTime); err != nil {
+			return nil, err
+		}
+		times[id] = shift
+	}
+	return times, nil
+}
+
+// FindDowntimes returns the downtimes of the workcenters overlapping [from, to)
+func (r *repository) FindDowntimes(ctx context.Context, workcenterIDs []string, from, to time.Time) ([]downtimeWindow, error) {
+	query := `SELECT d.id, d.workcenter_id, d.kind, COALESCE(dr.name, ''), d.start_time, d.end_time
+	FROM downtimes d
+	LEFT JOIN downtime_reasons dr ON dr.id = d.reason_id
+	WHERE d.workcenter_id = ANY($1::uuid[]) AND d.start_time < $3 AND (d.end_time IS NULL OR d.end_time > $2)
+	ORDER BY d.start_time`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(workcenterIDs), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var windows []downtimeWindow
+	for rows.Next() {
+		var window downtimeWindow
+		if err := rows.Scan(&window.ID, &window.WorkcenterID, &window.Kind, &window.Reason, &window.StartTime, &window.EndTime); err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
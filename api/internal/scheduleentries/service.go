@@ -1,8 +1,18 @@
 package scheduleentries
 
 import (
+	"api/internal/apperr"
+	"api/internal/audit"
+	"api/internal/listing"
+	"api/internal/outbox"
+	"api/internal/shifts"
+	"api/internal/shopfloors"
+	"api/internal/tenant"
+	"api/internal/validation"
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,25 +24,38 @@ type Service interface {
 	FindAll(ctx context.Context) ([]ScheduleEntry, error)
 	GetPlanning(ctx context.Context, shopfloorID string, date string) ([]ScheduleEntry, error)
 	GetOperatorPlanning(ctx context.Context, operatorID string, date string) ([]ScheduleEntry, error)
-	Search(ctx context.Context, filter ScheduleFilter) ([]ScheduleEntry, error)
+	Search(ctx context.Context, filter ScheduleFilter, params listing.Params) ([]ScheduleEntry, int, error)
 	Update(ctx context.Context, id string, request ScheduleEntryRequest) (ScheduleEntry, error)
 	Delete(ctx context.Context, id string) error
-	Sync(ctx context.Context, shopfloorID string, date string, requests []ScheduleEntryRequest) error
+	Trash(ctx context.Context, customerID string, params listing.Params) ([]ScheduleEntry, int, error)
+	Restore(ctx context.Context, id string) (ScheduleEntry, error)
+	Sync(ctx context.Context, shopfloorID string, date string, requests []ScheduleEntryRequest) ([]ScheduleWarning, error)
+	Validate(ctx context.Context, shopfloorID string, date string) ([]ScheduleWarning, error)
+	Warnings(ctx context.Context, entries []ScheduleEntry) ([]ScheduleWarning, error)
 }
 
 type service struct {
-	repo Repository
+	repo  Repository
+	audit audit.Recorder
 }
 
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+func NewService(repo Repository, recorder audit.Recorder) Service {
+	return &service{repo: repo, audit: recorder}
 }
 
 func (s *service) Create(ctx context.Context, request ScheduleEntryRequest) (ScheduleEntry, error) {
-	customerID, err := uuid.Parse(request.CustomerID)
+	customerID, err := tenant.Customer(ctx, request.CustomerID)
 	if err != nil {
 		return ScheduleEntry{}, err
 	}
+	var invalid validation.Errors
+	requireEntry(&invalid, "", request)
+	if request.Date == "" {
+		invalid.Add("date", "required", "date is required")
+	}
+	if err := invalid.Err(); err != nil {
+		return ScheduleEntry{}, err
+	}
 	shopfloorID, err := uuid.Parse(request.ShopfloorID)
 	if err != nil {
 		return ScheduleEntry{}, err
@@ -73,7 +96,7 @@ func (s *service) Create(ctx context.Context, request ScheduleEntryRequest) (Sch
 	if err != nil {
 		parsedDate, err = time.Parse(time.RFC3339, request.Date)
 		if err != nil {
-			return ScheduleEntry{}, err
+			return ScheduleEntry{}, apperr.Field("date", "invalid_date", "date must be a date (YYYY-MM-DD)")
 		}
 	}
 
@@ -95,10 +118,18 @@ func (s *service) Create(ctx context.Context, request ScheduleEntryRequest) (Sch
 		UpdatedAt:    time.Now().Format(time.RFC3339),
 	}
 
+	if err := s.checkReferences(ctx, "", entry, &invalid); err != nil {
+		return ScheduleEntry{}, err
+	}
+	if err := invalid.Err(); err != nil {
+		return ScheduleEntry{}, err
+	}
+
 	_, err = s.repo.Create(ctx, entry)
 	if err != nil {
 		return ScheduleEntry{}, err
 	}
+	s.audit.Created(ctx, audit.EntityScheduleEntry, entry.ID, entry.CustomerID, entry)
 	return entry, nil
 }
 
@@ -107,7 +138,32 @@ func (s *service) FindByID(ctx context.Context, id string) (ScheduleEntry, error
 	if err != nil {
 		return ScheduleEntry{}, err
 	}
-	return s.repo.FindByID(ctx, parsedID)
+	entry, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return ScheduleEntry{}, err
+	}
+	if err := tenant.Check(ctx, entry.CustomerID); err != nil {
+		return ScheduleEntry{}, err
+	}
+	if err := shopfloors.Check(ctx, entry.ShopfloorID); err != nil {
+		return ScheduleEntry{}, err
+	}
+	return entry, nil
+}
+
+// ownedShopfloor returns the tenant of a shop floor the current user can access
+func (s *service) ownedShopfloor(ctx context.Context, shopfloorID uuid.UUID) (uuid.UUID, error) {
+	customerID, err := s.repo.FindShopfloorCustomerID(ctx, shopfloorID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := tenant.Check(ctx, customerID); err != nil {
+		return uuid.Nil, err
+	}
+	if err := shopfloors.Check(ctx, shopfloorID); err != nil {
+		return uuid.Nil, err
+	}
+	return customerID, nil
 }
 
 func (s *service) FindAll(ctx context.Context) ([]ScheduleEntry, error) {
@@ -133,6 +189,9 @@ func (s *service) GetPlanning(ctx context.Context, shopfloorID string, date stri
 	if err != nil {
 		return nil, err
 	}
+	if _, err := s.ownedShopfloor(ctx, parsedShopfloorID); err != nil {
+		return nil, err
+	}
 	// date validation?
 	return s.repo.FindByShopfloorAndDate(ctx, parsedShopfloorID, date)
 }
@@ -142,21 +201,28 @@ func (s *service) GetOperatorPlanning(ctx context.Context, operatorID string, da
 	if err != nil {
 		return nil, err
 	}
+	customerID, err := s.repo.FindOperatorCustomerID(ctx, parsedOperatorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tenant.Check(ctx, customerID); err != nil {
+		return nil, err
+	}
 	return s.repo.FindByOperatorAndDate(ctx, parsedOperatorID, date)
 }
 
-func (s *service) Search(ctx context.Context, filter ScheduleFilter) ([]ScheduleEntry, error) {
+func (s *service) Search(ctx context.Context, filter ScheduleFilter, params listing.Params) ([]ScheduleEntry, int, error) {
 	isAdminVal := ctx.Value("is_admin")
 	isAdmin, ok := isAdminVal.(bool)
 	if !ok {
-		return nil, errors.New("invalid or missing is_admin in context")
+		return nil, 0, errors.New("invalid or missing is_admin in context")
 	}
 
 	if !isAdmin {
 		customerIDVal := ctx.Value("customer_id")
 		customerIDFromCtx, ok := customerIDVal.(uuid.UUID)
 		if !ok {
-			return nil, errors.New("invalid or missing customer_id in context")
+			return nil, 0, errors.New("invalid or missing customer_id in context")
 		}
 		// Force customer ID
 		filter.CustomerID = &customerIDFromCtx
@@ -165,23 +231,20 @@ func (s *service) Search(ctx context.Context, filter ScheduleFilter) ([]Schedule
 		// This strictly enforces that logic.
 		filter.OperatorID = nil
 	}
-	return s.repo.Search(ctx, filter)
+	return s.repo.Search(ctx, filter, params)
 }
 
 func (s *service) Update(ctx context.Context, id string, request ScheduleEntryRequest) (ScheduleEntry, error) {
-	parsedID, err := uuid.Parse(id)
-	if err != nil {
-		return ScheduleEntry{}, err
-	}
-
-	entry, err := s.repo.FindByID(ctx, parsedID)
+	entry, err := s.FindByID(ctx, id)
 	if err != nil {
 		return ScheduleEntry{}, err
 	}
+	before := entry
+	wasCompleted := entry.IsCompleted
 
-	// Update fields
+	// Update fields. Only admins can move an entry to another tenant.
 	if request.CustomerID != "" {
-		if customerID, err := uuid.Parse(request.CustomerID); err == nil {
+		if customerID, err := tenant.Customer(ctx, request.CustomerID); err == nil {
 			entry.CustomerID = customerID
 		}
 	}
@@ -216,7 +279,7 @@ func (s *service) Update(ctx context.Context, id string, request ScheduleEntryRe
 		if err != nil {
 			parsedDate, err = time.Parse(time.RFC3339, request.Date)
 			if err != nil {
-				return ScheduleEntry{}, err
+				return ScheduleEntry{}, apperr.Field("date", "invalid_date", "date must be a date (YYYY-MM-DD)")
 			}
 		}
 		entry.Date = parsedDate
@@ -228,25 +291,80 @@ func (s *service) Update(ctx context.Context, id string, request ScheduleEntryRe
 	entry.IsCompleted = request.IsCompleted
 	entry.UpdatedAt = time.Now().Format(time.RFC3339)
 
-	_, err = s.repo.Update(ctx, entry)
+	var invalid validation.Errors
+	if err := s.checkReferences(ctx, "", entry, &invalid); err != nil {
+		return ScheduleEntry{}, err
+	}
+	if err := invalid.Err(); err != nil {
+		return ScheduleEntry{}, err
+	}
+
+	var events []outbox.Event
+	if !wasCompleted && entry.IsCompleted {
+		events = append(events, outbox.NewEvent(entry.CustomerID, outbox.EventScheduleEntryCompleted, entry))
+	}
+
+	_, err = s.repo.Update(ctx, entry, events...)
 	if err != nil {
 		return ScheduleEntry{}, err
 	}
+	s.audit.Updated(ctx, audit.EntityScheduleEntry, entry.ID, entry.CustomerID, before, entry)
 	return entry, nil
 }
 
+// Delete moves the entry to the trash
 func (s *service) Delete(ctx context.Context, id string) error {
-	parsedID, err := uuid.Parse(id)
+	entry, err := s.FindByID(ctx, id)
 	if err != nil {
 		return err
 	}
-	return s.repo.Delete(ctx, parsedID)
+	if err := s.repo.Delete(ctx, entry.ID); err != nil {
+		return err
+	}
+	s.audit.Deleted(ctx, audit.EntityScheduleEntry, entry.ID, entry.CustomerID, entry)
+	return nil
+}
+
+// Trash returns the deleted entries of the tenant
+func (s *service) Trash(ctx context.Context, customerID string, params listing.Params) ([]ScheduleEntry, int, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repo.FindDeleted(ctx, scope, params)
 }
 
-func (s *service) Sync(ctx context.Context, shopfloorID string, date string, requests []ScheduleEntryRequest) error {
+func (s *service) Restore(ctx context.Context, id string) (ScheduleEntry, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return ScheduleEntry{}, err
+	}
+	entry, err := s.repo.FindDeletedByID(ctx, parsedID)
+	if err != nil {
+		return ScheduleEntry{}, err
+	}
+	if err := tenant.Check(ctx, entry.CustomerID); err != nil {
+		return ScheduleEntry{}, err
+	}
+	if err := shopfloors.Check(ctx, entry.ShopfloorID); err != nil {
+		return ScheduleEntry{}, err
+	}
+	if err := s.repo.Restore(ctx, entry.ID); err != nil {
+		return ScheduleEntry{}, err
+	}
+	entry.DeletedAt = nil
+	s.audit.Restored(ctx, audit.EntityScheduleEntry, entry.ID, entry.CustomerID, entry)
+	return entry, nil
+}
+
+func (s *service) Sync(ctx context.Context, shopfloorID string, date string, requests []ScheduleEntryRequest) ([]ScheduleWarning, error) {
 	parsedShopfloorID, err := uuid.Parse(shopfloorID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	customerID, err := s.ownedShopfloor(ctx, parsedShopfloorID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse sync date
@@ -255,30 +373,31 @@ func (s *service) Sync(ctx context.Context, shopfloorID string, date string, req
 		// Try RFC3339 if partial fails, or just error out
 		parsedDate, err = time.Parse(time.RFC3339, date)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	var entries []ScheduleEntry
-	for _, req := range requests {
-		customerID, err := uuid.Parse(req.CustomerID)
-		if err != nil {
-			return err
+	var invalid validation.Errors
+	for i, req := range requests {
+		path := fmt.Sprintf("entries[%d].", i)
+		if requireEntry(&invalid, path, req) {
+			continue
 		}
 		sfID, err := uuid.Parse(req.ShopfloorID)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		shiftID, err := uuid.Parse(req.ShiftID)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		
 		var workcenterID uuid.NullUUID
 		if req.WorkcenterID != "" {
 			id, err := uuid.Parse(req.WorkcenterID)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			workcenterID = uuid.NullUUID{UUID: id, Valid: true}
 		}
@@ -287,7 +406,7 @@ func (s *service) Sync(ctx context.Context, shopfloorID string, date string, req
 		if req.JobID != "" {
 			id, err := uuid.Parse(req.JobID)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			jobID = uuid.NullUUID{UUID: id, Valid: true}
 		}
@@ -296,7 +415,7 @@ func (s *service) Sync(ctx context.Context, shopfloorID string, date string, req
 		if req.OperatorID != "" {
 			id, err := uuid.Parse(req.OperatorID)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			operatorID = uuid.NullUUID{UUID: id, Valid: true}
 		}
@@ -309,7 +428,8 @@ func (s *service) Sync(ctx context.Context, shopfloorID string, date string, req
 			}
 		}
 
-		entries = append(entries, ScheduleEntry{
+		// Entries always belong to the tenant of the synced shop floor
+		entry := ScheduleEntry{
 			ID:           entryID,
 			CustomerID:   customerID,
 			ShopfloorID:  sfID,
@@ -324,8 +444,229 @@ func (s *service) Sync(ctx context.Context, shopfloorID string, date string, req
 			IsCompleted:  req.IsCompleted,
 			CreatedAt:    time.Now().Format(time.RFC3339),
 			UpdatedAt:    time.Now().Format(time.RFC3339),
-		})
+		}
+		if err := s.checkReferences(ctx, path, entry, &invalid); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
+
+	if entries == nil {
+		entries = []ScheduleEntry{}
+	}
+
+	// The entries kept by the sync are updated in place and keep their creation time
+	previous, err := s.repo.FindByShopfloorAndDate(ctx, parsedShopfloorID, date)
+	if err != nil {
+		return nil, err
+	}
+	created := make(map[uuid.UUID]string, len(previous))
+	for _, entry := range previous {
+		created[entry.ID] = entry.CreatedAt
+	}
+	for i := range entries {
+		if createdAt, kept := created[entries[i].ID]; kept {
+			entries[i].CreatedAt = createdAt
+		}
+	}
+
+	published := outbox.NewEvent(customerID, outbox.EventSchedulePublished, SchedulePublishedPayload{
+		ShopfloorID: parsedShopfloorID,
+		Date:        parsedDate.Format("2006-01-02"),
+		Entries:     entries,
+	})
+	if err := s.repo.Sync(ctx, parsedShopfloorID, date, entries, published); err != nil {
+		return nil, err
+	}
+	s.recordSync(ctx, previous, entries)
+	return s.Warnings(ctx, entries)
+}
+
+// requireEntry records the references an entry can't be planned without,
+// reporting whether any is missing. path locates the entry in the request.
+func requireEntry(invalid *validation.Errors, path string, request ScheduleEntryRequest) bool {
+	missing := false
+	if request.ShopfloorID == "" {
+		invalid.Add(path+"shopfloor_id", "required", path+"shopfloor_id is required")
+		missing = true
+	}
+	if request.ShiftID == "" {
+		invalid.Add(path+"shift_id", "required", path+"shift_id is required")
+		missing = true
+	}
+	return missing
+}
+
+// reference is a record a schedule entry points to
+type reference struct {
+	field string
+	code  string
+	name  string
+	id    uuid.NullUUID
+	find  func(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
+}
+
+// checkReferences records the records of an entry that are missing or belong
+// to another customer than the entry, and a workcenter outside the shop floor
+// of the entry. Shop floors out of the reach of the user look missing.
+func (s *service) checkReferences(ctx context.Context, path string, entry ScheduleEntry, invalid *validation.Errors) error {
+	references := []reference{
+		{"shopfloor_id", "shopfloor", "shop floor", uuid.NullUUID{UUID: entry.ShopfloorID, Valid: true}, s.repo.FindShopfloorCustomerID},
+		{"shift_id", "shift", "shift", uuid.NullUUID{UUID: entry.ShiftID, Valid: true}, s.repo.FindShiftCustomerID},
+		{"workcenter_id", "workcenter", "workcenter", entry.WorkcenterID, s.repo.FindWorkcenterCustomerID},
+		{"job_id", "job", "job", entry.JobID, s.repo.FindJobCustomerID},
+		{"operator_id", "operator", "operator", entry.OperatorID, s.repo.FindOperatorCustomerID},
+	}
+	valid := map[string]bool{}
+	for _, ref := range references {
+		if !ref.id.Valid {
+			continue
+		}
+		customerID, err := ref.find(ctx, ref.id.UUID)
+		if err == nil && ref.field == "shopfloor_id" && !shopfloors.Permits(ctx, ref.id.UUID) {
+			err = sql.ErrNoRows
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			invalid.Add(path+ref.field, "unknown_"+ref.code, ref.name+" not found")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if customerID != entry.CustomerID {
+			invalid.Add(path+ref.field, "foreign_"+ref.code, ref.name+" belongs to another customer")
+			continue
+		}
+		valid[ref.field] = true
+	}
+
+	if valid["shopfloor_id"] && valid["workcenter_id"] {
+		ok, err := s.repo.WorkcenterInShopfloor(ctx, entry.WorkcenterID.UUID, entry.ShopfloorID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			invalid.Add(path+"workcenter_id", "workcenter_not_in_shopfloor", "workcenter is not on this shop floor")
+		}
+	}
+	return nil
+}
+
+// recordSync writes to the audit log the entries a sync created, changed and removed
+func (s *service) recordSync(ctx context.Context, previous []ScheduleEntry, entries []ScheduleEntry) {
+	existing := make(map[uuid.UUID]ScheduleEntry, len(previous))
+	for _, entry := range previous {
+		existing[entry.ID] = entry
+	}
+	for _, entry := range entries {
+		before, found := existing[entry.ID]
+		if !found {
+			s.audit.Created(ctx, audit.EntityScheduleEntry, entry.ID, entry.CustomerID, entry)
+			continue
+		}
+		delete(existing, entry.ID)
+		// The sync touches every entry it keeps
+		entry.UpdatedAt = before.UpdatedAt
+		s.audit.Updated(ctx, audit.EntityScheduleEntry, entry.ID, entry.CustomerID, before, entry)
+	}
+	for _, entry := range previous {
+		if _, removed := existing[entry.ID]; removed {
+			s.audit.Deleted(ctx, audit.EntityScheduleEntry, entry.ID, entry.CustomerID, entry)
+		}
+	}
+}
+
+// Validate checks the saved planning of a shopfloor and day
+func (s *service) Validate(ctx context.Context, shopfloorID string, date string) ([]ScheduleWarning, error) {
+	entries, err := s.GetPlanning(ctx, shopfloorID, date)
+	if err != nil {
+		return nil, err
 	}
+	return s.Warnings(ctx, entries)
+}
+
+// Warnings returns a warning for every entry whose workcenter is down while
+// the entry is planned. Entries without explicit times span their whole shift.
+func (s *service) Warnings(ctx context.Context, entries []ScheduleEntry) ([]ScheduleWarning, error) {
+	warnings := []ScheduleWarning{}
 
-	return s.repo.Sync(ctx, parsedShopfloorID, date, entries)
+	var shiftIDs, workcenterIDs []string
+	for _, entry := range entries {
+		if !entry.WorkcenterID.Valid {
+			continue
+		}
+		shiftIDs = append(shiftIDs, entry.ShiftID.String())
+		workcenterIDs = append(workcenterIDs, entry.WorkcenterID.UUID.String())
+	}
+	if len(workcenterIDs) == 0 {
+		return warnings, nil
+	}
+	shiftsByID, err := s.repo.FindShiftTimes(ctx, shiftIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	type window struct{ start, end time.Time }
+	windows := map[uuid.UUID]window{}
+	var from, to time.Time
+	for _, entry := range entries {
+		if !entry.WorkcenterID.Valid {
+			continue
+		}
+		var w window
+		if entry.StartTime != nil && entry.EndTime != nil {
+			w = window{*entry.StartTime, *entry.EndTime}
+		} else if shift, ok := shiftsByID[entry.ShiftID]; ok {
+			w.start, w.end = shifts.Window(entry.Date, shift.StartTime, shift.EndTime)
+		} else {
+			continue
+		}
+		windows[entry.ID] = w
+		if from.IsZero() || w.start.Before(from) {
+			from = w.start
+		}
+		if w.end.After(to) {
+			to = w.end
+		}
+	}
+	if len(windows) == 0 {
+		return warnings, nil
+	}
+
+	downtimes, err := s.repo.FindDowntimes(ctx, workcenterIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		w, ok := windows[entry.ID]
+		if !ok {
+			continue
+		}
+		for _, downtime := range downtimes {
+			if downtime.WorkcenterID != entry.WorkcenterID.UUID {
+				continue
+			}
+			if !downtime.StartTime.Before(w.end) || (downtime.EndTime != nil && !downtime.EndTime.After(w.start)) {
+				continue
+			}
+			reason := downtime.Reason
+			if reason == "" {
+				reason = downtime.Kind + " downtime"
+			}
+			warnings = append(warnings, ScheduleWarning{
+				EntryID:      entry.ID,
+				WorkcenterID: downtime.WorkcenterID,
+				DowntimeID:   downtime.ID,
+				Kind:         downtime.Kind,
+				Reason:       downtime.Reason,
+				StartTime:    downtime.StartTime,
+				EndTime:      downtime.EndTime,
+				Message:      fmt.Sprintf("workcenter is down from %s (%s)", downtime.StartTime.Format("2006-01-02 15:04"), reason),
+			})
+		}
+	}
+	return warnings, nil
 }
@@ -0,0 +1,15 @@
+package shifts
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/shifts", Tag: "shifts", Summary: "Create a shift", Request: ShiftRequest{}, Response: Shift{}},
+	{Method: "GET", Path: "/api/shifts", Tag: "shifts", Summary: "List shifts", Response: []Shift{}, List: &listSpec},
+	{Method: "GET", Path: "/api/shifts/trash", Tag: "shifts", Summary: "List deleted shifts", Response: []Shift{}, List: &trashSpec, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "GET", Path: "/api/shifts/:id", Tag: "shifts", Summary: "Get a shift", Response: Shift{}},
+	{Method: "GET", Path: "/api/shifts/shopfloor/:shopfloorID", Tag: "shifts", Summary: "List the shifts of a shop floor", Response: []Shift{}, List: &listSpec},
+	{Method: "PUT", Path: "/api/shifts/:id", Tag: "shifts", Summary: "Update a shift", Request: ShiftRequest{}, Response: Shift{}},
+	{Method: "DELETE", Path: "/api/shifts/:id", Tag: "shifts", Summary: "Move a shift and its schedule entries to the trash", Query: []openapi.Param{openapi.Confirm}},
+	{Method: "POST", Path: "/api/shifts/:id/restore", Tag: "shifts", Summary: "Restore a deleted shift with the schedule entries deleted along with it", Response: Shift{}},
+}
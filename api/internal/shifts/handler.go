@@ -1,6 +1,10 @@
 package shifts
 
 import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
+	"api/internal/trash"
 	"api/middleware"
 	"net/http"
 
@@ -19,7 +23,7 @@ func (h *Handler) Create(c *gin.Context){
 	ctx := c.Request.Context()
 	var request ShiftRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	if customerID := middleware.GetCustomerID(c); customerID != "" {
@@ -27,7 +31,7 @@ func (h *Handler) Create(c *gin.Context){
 	}
 	shift, err := h.service.Create(ctx, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"data": shift})
@@ -35,21 +39,17 @@ func (h *Handler) Create(c *gin.Context){
 
 func (h *Handler) FindAll(c *gin.Context) {
 	ctx := c.Request.Context()
-	customerID := middleware.GetCustomerID(c)
-	if customerID == "" {
-		customerID = c.Query("customer_id")
-		if customerID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "customer_id required"})
-			return
-		}
+	params, err := listing.Parse(c, listSpec)
+	if err != nil {
+		c.Error(err)
+		return
 	}
-	
-	shifts, err := h.service.FindByCustomerID(ctx, customerID)
+	shifts, total, err := h.service.List(ctx, params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": shifts})
+	c.JSON(http.StatusOK, gin.H{"data": shifts, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindByID(c *gin.Context) {
@@ -57,7 +57,11 @@ func (h *Handler) FindByID(c *gin.Context) {
 	id := c.Param("id")
 	shift, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shift_not_found", "Shift not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"data": shift})
@@ -66,12 +70,17 @@ func (h *Handler) FindByID(c *gin.Context) {
 func (h *Handler) FindByShopfloorID(c *gin.Context){
 	ctx := c.Request.Context()
 	shopfloorID := c.Param("shopfloorID")
-	shifts, err := h.service.FindByShopfloorID(ctx, shopfloorID)
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": shifts})
+	shifts, total, err := h.service.FindByShopfloorID(ctx, shopfloorID, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": shifts, "meta": params.Page(total)})
 }
 
 func (h *Handler) Update(c *gin.Context){
@@ -79,7 +88,7 @@ func (h *Handler) Update(c *gin.Context){
 	id := c.Param("id")
 	var request ShiftRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	if customerID := middleware.GetCustomerID(c); customerID != "" {
@@ -87,7 +96,11 @@ func (h *Handler) Update(c *gin.Context){
 	}
 	shift, err := h.service.Update(ctx, id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shift_not_found", "Shift not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"data": shift})
@@ -96,10 +109,43 @@ func (h *Handler) Update(c *gin.Context){
 func (h *Handler) Delete(c *gin.Context){
 	ctx := c.Request.Context()
 	id := c.Param("id")
-	if err := h.service.Delete(ctx, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.Delete(ctx, id, trash.Confirmed(c.Query("confirm"))); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shift_not_found", "Shift not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Shift deleted successfully"})
 }
 
+
+func (h *Handler) Trash(c *gin.Context) {
+	ctx := c.Request.Context()
+	params, err := listing.Parse(c, trashSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.Trash(ctx, c.Query("customer_id"), params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted shifts found successfully", "data": response, "meta": params.Page(total)})
+}
+
+func (h *Handler) Restore(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.Restore(ctx, c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shift_not_found", "Shift not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shift restored successfully", "data": response})
+}
@@ -17,14 +17,28 @@ type Shift struct {
 	IsActive    bool      `json:"is_active"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 }
 
 type ShiftRequest struct {
-	CustomerID  string `json:"customer_id"`
-	ShopfloorID string `json:"shopfloor_id"`
-	Name        string `json:"name"`
-	Color       string `json:"color"`
-	StartTime   string `json:"start_time"`
-	EndTime     string `json:"end_time"`
+	CustomerID  string `json:"customer_id" binding:"omitempty,uuid"`
+	ShopfloorID string `json:"shopfloor_id" binding:"omitempty,uuid"`
+	Name        string `json:"name" binding:"required,max=100"`
+	Color       string `json:"color" binding:"omitempty,hexcolor"`
+	StartTime   string `json:"start_time" binding:"required,datetime=15:04"`
+	EndTime     string `json:"end_time" binding:"required,datetime=15:04"`
 	IsActive    bool   `json:"is_active"`
-}
\ No newline at end of file
+}
+
+// Window returns the interval a shift covers on the given day. Shifts only
+// keep the time of day; when the end is not after the start the shift ends
+// the next day.
+func Window(day, startTime, endTime time.Time) (time.Time, time.Time) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+	start := time.Date(day.Year(), day.Month(), day.Day(), startTime.Hour(), startTime.Minute(), 0, 0, time.UTC)
+	end := time.Date(day.Year(), day.Month(), day.Day(), endTime.Hour(), endTime.Minute(), 0, 0, time.UTC)
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end
+}
@@ -1,19 +1,51 @@
 package shifts
 
 import (
+	"api/internal/listing"
+	"api/internal/shopfloors"
+	"api/internal/trash"
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// listSpec is what the shift list can be sorted, filtered and searched on
+var listSpec = listing.Spec{
+	Sort: map[string]string{
+		"name":       "name",
+		"start_time": "start_time",
+		"end_time":   "end_time",
+		"is_active":  "is_active",
+		"created_at": "created_at",
+	},
+	Filter: map[string]string{
+		"customer_id":  "customer_id",
+		"shopfloor_id": "shopfloor_id",
+		"is_active":    "is_active",
+	},
+	Search:  []string{"name"},
+	Default: []listing.Order{{Field: "start_time"}},
+}
+
+// trashSpec is what the trash can be sorted and filtered on
+var trashSpec = trash.Spec(listSpec)
+
 type Repository interface {
 	Create(ctx context.Context,shift Shift) (Shift, error)
 	FindByID(ctx context.Context,shiftID uuid.UUID) (Shift, error)
-	FindByShopfloorID(ctx context.Context,shopfloorID uuid.UUID) ([]Shift, error)
+	FindByShopfloorID(ctx context.Context,shopfloorID uuid.UUID, customerID *uuid.UUID) ([]Shift, error)
 	FindByCustomerID(ctx context.Context,customerID uuid.UUID) ([]Shift, error)
+	List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Shift, int, error)
+	ListByShopfloorID(ctx context.Context, shopfloorID uuid.UUID, customerID *uuid.UUID, params listing.Params) ([]Shift, int, error)
 	Update(ctx context.Context,shift Shift) (Shift, error)
-	Delete(ctx context.Context,shiftID uuid.UUID) error
+	Delete(ctx context.Context, shiftID uuid.UUID, confirm func(trash.Preview) error) error
+	FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Shift, int, error)
+	FindDeletedByID(ctx context.Context, shiftID uuid.UUID) (Shift, error)
+	Restore(ctx context.Context, shiftID uuid.UUID) error
+	FindShopFloorCustomerID(ctx context.Context, shopFloorID uuid.UUID) (uuid.UUID, error)
 }
 
 type repository struct {
@@ -34,7 +66,7 @@ func (r *repository) Create(ctx context.Context,shift Shift) (Shift, error) {
 }
 
 func (r *repository) FindByID(ctx context.Context,shiftID uuid.UUID) (Shift, error) {
-	query := "SELECT id, customer_id, shopfloor_id, name, color, start_time, end_time, is_active, created_at, updated_at FROM shifts WHERE id = $1"
+	query := "SELECT id, customer_id, shopfloor_id, name, color, start_time, end_time, is_active, created_at, updated_at FROM shifts WHERE id = $1 AND deleted_at IS NULL"
 	row := r.db.QueryRowContext(ctx, query, shiftID)
 	var shift Shift
 	err := row.Scan(&shift.ID, &shift.CustomerID, &shift.ShopfloorID, &shift.Name, &shift.Color, &shift.StartTime, &shift.EndTime, &shift.IsActive, &shift.CreatedAt, &shift.UpdatedAt)
@@ -44,9 +76,16 @@ func (r *repository) FindByID(ctx context.Context,shiftID uuid.UUID) (Shift, err
 	return shift, nil
 }
 
-func (r *repository) FindByShopfloorID(ctx context.Context,shopfloorID uuid.UUID) ([]Shift, error) {
-	query := "SELECT id, customer_id, shopfloor_id, name, color, start_time, end_time, is_active, created_at, updated_at FROM shifts WHERE shopfloor_id = $1"
-	rows, err := r.db.QueryContext(ctx, query, shopfloorID)
+// FindByShopfloorID returns the shifts of a shop floor, within the tenant
+// when customerID is set
+func (r *repository) FindByShopfloorID(ctx context.Context,shopfloorID uuid.UUID, customerID *uuid.UUID) ([]Shift, error) {
+	query := "SELECT id, customer_id, shopfloor_id, name, color, start_time, end_time, is_active, created_at, updated_at FROM shifts WHERE shopfloor_id = $1 AND deleted_at IS NULL"
+	args := []interface{}{shopfloorID}
+	if customerID != nil {
+		query += " AND customer_id = $2"
+		args = append(args, *customerID)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +103,7 @@ func (r *repository) FindByShopfloorID(ctx context.Context,shopfloorID uuid.UUID
 }
 
 func (r *repository) FindByCustomerID(ctx context.Context,customerID uuid.UUID) ([]Shift, error) {
-	query := "SELECT id, customer_id, shopfloor_id, name, color, start_time, end_time, is_active, created_at, updated_at FROM shifts WHERE customer_id = $1"
+	query := "SELECT id, customer_id, shopfloor_id, name, color, start_time, end_time, is_active, created_at, updated_at FROM shifts WHERE customer_id = $1 AND deleted_at IS NULL"
 	rows, err := r.db.QueryContext(ctx, query, customerID)
 	if err != nil {
 		return nil, err
@@ -82,6 +121,49 @@ func (r *repository) FindByCustomerID(ctx context.Context,customerID uuid.UUID)
 	return shifts, nil
 }
 
+// List returns a page of the shifts of customerID, or of every customer when
+// nil. Shifts without a shop floor apply to the whole tenant, so users
+// restricted to some shop floors still see them.
+func (r *repository) List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Shift, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return r.findPage(ctx, q)
+}
+
+// ListByShopfloorID returns a page of the shifts of a shop floor, within the
+// tenant when customerID is set
+func (r *repository) ListByShopfloorID(ctx context.Context, shopfloorID uuid.UUID, customerID *uuid.UUID, params listing.Params) ([]Shift, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	q.Where("shopfloor_id = ?", shopfloorID)
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return r.findPage(ctx, q)
+}
+
+// findPage runs a list query on the shifts the user can see
+func (r *repository) findPage(ctx context.Context, q *listing.Query) ([]Shift, int, error) {
+	if ids, ok := shopfloors.Allowed(ctx); ok {
+		q.Where("(shopfloor_id IS NULL OR shopfloor_id = ANY(?::uuid[]))", pq.Array(ids))
+	}
+	return listing.Find(ctx, r.db, q, "SELECT id, customer_id, shopfloor_id, name, color, start_time, end_time, is_active, created_at, updated_at FROM shifts", func(rows *sql.Rows) (Shift, error) {
+		var shift Shift
+		err := rows.Scan(&shift.ID, &shift.CustomerID, &shift.ShopfloorID, &shift.Name, &shift.Color, &shift.StartTime, &shift.EndTime, &shift.IsActive, &shift.CreatedAt, &shift.UpdatedAt)
+		return shift, err
+	})
+}
+
+// FindShopFloorCustomerID returns the tenant of a shop floor
+func (r *repository) FindShopFloorCustomerID(ctx context.Context, shopFloorID uuid.UUID) (uuid.UUID, error) {
+	var customerID uuid.UUID
+	err := r.db.QueryRowContext(ctx, `SELECT customer_id FROM shopfloors WHERE id = $1 AND deleted_at IS NULL`, shopFloorID).Scan(&customerID)
+	return customerID, err
+}
+
 func (r *repository) Update(ctx context.Context,shift Shift) (Shift, error) {
 	query := "UPDATE shifts SET customer_id = $2, shopfloor_id = $3, name = $4, color = $5, start_time = $6, end_time = $7, is_active = $8, updated_at = $9 WHERE id = $10 RETURNING *"
 	_, err := r.db.ExecContext(ctx, query, shift.CustomerID, shift.ShopfloorID, shift.Name, shift.Color, shift.StartTime, shift.EndTime, shift.IsActive, shift.UpdatedAt, shift.ID)
@@ -91,11 +173,96 @@ func (r *repository) Update(ctx context.Context,shift Shift) (Shift, error) {
 	return shift, nil
 }
 
-func (r *repository) Delete(ctx context.Context,shiftID uuid.UUID) error {
-	query := "DELETE FROM shifts WHERE id = $1"
-	_, err := r.db.ExecContext(ctx, query, shiftID)
+// deleteSteps move the schedule entries of a shift to the trash along with it
+var deleteSteps = []trash.Step{
+	{Kind: "schedule_entries", Query: `UPDATE schedule_entries SET deleted_at = $2 WHERE shift_id = $1 AND deleted_at IS NULL`},
+}
+
+// restoreSteps take the schedule entries deleted along with a shift out of the trash
+var restoreSteps = []trash.Step{
+	{Kind: "schedule_entries", Query: `UPDATE schedule_entries SET deleted_at = NULL WHERE shift_id = $1 AND deleted_at = $2
+	AND ` + trash.ScheduleEntryParentsLive},
+}
+
+// Delete moves the shift and its schedule entries to the trash, marked with
+// the same time so they are restored together. confirm gets the preview
+// before anything is committed and cancels the deletion when it fails.
+func (r *repository) Delete(ctx context.Context, shiftID uuid.UUID, confirm func(trash.Preview) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	query := "UPDATE shifts SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL RETURNING deleted_at"
+	if err := tx.QueryRowContext(ctx, query, shiftID).Scan(&deletedAt); err != nil {
+		return err
+	}
+	deleted, err := trash.Cascade(ctx, tx, deleteSteps, shiftID, deletedAt)
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := confirm(trash.Preview{Deleted: deleted}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindDeleted returns a page of the shifts in the trash of the tenant, or
+// of every tenant when customerID is nil
+func (r *repository) FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Shift, int, error) {
+	q := listing.NewQuery(trashSpec, params)
+	q.Where("deleted_at IS NOT NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return listing.Find(ctx, r.db, q, `SELECT id, customer_id, shopfloor_id, name, color, start_time, end_time, is_active, created_at, updated_at, deleted_at FROM shifts`, func(rows *sql.Rows) (Shift, error) {
+		var shift Shift
+		err := rows.Scan(&shift.ID, &shift.CustomerID, &shift.ShopfloorID, &shift.Name, &shift.Color, &shift.StartTime, &shift.EndTime, &shift.IsActive, &shift.CreatedAt, &shift.UpdatedAt, &shift.DeletedAt)
+		return shift, err
+	})
+}
+
+func (r *repository) FindDeletedByID(ctx context.Context, shiftID uuid.UUID) (Shift, error) {
+	query := "SELECT id, customer_id, shopfloor_id, name, color, start_time, end_time, is_active, created_at, updated_at, deleted_at FROM shifts WHERE id = $1 AND deleted_at IS NOT NULL"
+	var shift Shift
+	err := r.db.QueryRowContext(ctx, query, shiftID).Scan(&shift.ID, &shift.CustomerID, &shift.ShopfloorID, &shift.Name, &shift.Color, &shift.StartTime, &shift.EndTime, &shift.IsActive, &shift.CreatedAt, &shift.UpdatedAt, &shift.DeletedAt)
+	if err != nil {
+		return Shift{}, err
+	}
+	return shift, nil
+}
+
+// Restore takes the shift out of the trash together with the schedule
+// entries deleted with it, unless its shop floor is still in the trash
+func (r *repository) Restore(ctx context.Context, shiftID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	query := `SELECT deleted_at FROM shifts WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, shiftID).Scan(&deletedAt); err != nil {
+		return err
+	}
+	query = `UPDATE shifts SET deleted_at = NULL WHERE id = $1
+	AND NOT EXISTS (SELECT 1 FROM shopfloors WHERE id = shifts.shopfloor_id AND deleted_at IS NOT NULL)`
+	result, err := tx.ExecContext(ctx, query, shiftID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return trash.ErrParentDeleted
+	}
+	if _, err := trash.Cascade(ctx, tx, restoreSteps, shiftID, deletedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -5,8 +5,10 @@ import "github.com/gin-gonic/gin"
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/shifts", handler.Create)
 	router.GET("/shifts", handler.FindAll)
+	router.GET("/shifts/trash", handler.Trash)
 	router.GET("/shifts/:id", handler.FindByID)
 	router.GET("/shifts/shopfloor/:shopfloorID", handler.FindByShopfloorID)
 	router.PUT("/shifts/:id", handler.Update)
 	router.DELETE("/shifts/:id", handler.Delete)
+	router.POST("/shifts/:id/restore", handler.Restore)
 }
\ No newline at end of file
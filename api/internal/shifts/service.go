@@ -1,7 +1,14 @@
 package shifts
 
 import (
+	"api/internal/apperr"
+	"api/internal/audit"
+	"api/internal/listing"
+	"api/internal/shopfloors"
+	"api/internal/tenant"
+	"api/internal/trash"
 	"context"
+	"database/sql"
 	"errors"
 	"time"
 
@@ -11,18 +18,21 @@ import (
 type Service interface {
 	Create(ctx context.Context,request ShiftRequest) (Shift, error)
 	FindByID(ctx context.Context, id string) (Shift, error)
-	FindByShopfloorID(ctx context.Context,shopfloorID string) ([]Shift, error)
-	FindByCustomerID(ctx context.Context,customerID string) ([]Shift, error)
+	FindByShopfloorID(ctx context.Context,shopfloorID string, params listing.Params) ([]Shift, int, error)
+	List(ctx context.Context, params listing.Params) ([]Shift, int, error)
 	Update(ctx context.Context,id string, request ShiftRequest) (Shift, error)
-	Delete(ctx context.Context,shiftID string) error
+	Delete(ctx context.Context,shiftID string, confirmed bool) error
+	Trash(ctx context.Context, customerID string, params listing.Params) ([]Shift, int, error)
+	Restore(ctx context.Context, id string) (Shift, error)
 }
 
 type service struct {
-	repo Repository
+	repo  Repository
+	audit audit.Recorder
 }
 
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+func NewService(repo Repository, recorder audit.Recorder) Service {
+	return &service{repo: repo, audit: recorder}
 }
 
 func (s *service) FindByID(ctx context.Context, id string) (Shift, error) {
@@ -30,11 +40,43 @@ func (s *service) FindByID(ctx context.Context, id string) (Shift, error) {
 	if err != nil {
 		return Shift{}, err
 	}
-	return s.repo.FindByID(ctx, parsedID)
+	shift, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return Shift{}, err
+	}
+	if err := tenant.Check(ctx, shift.CustomerID); err != nil {
+		return Shift{}, err
+	}
+	if err := checkScope(ctx, shift); err != nil {
+		return Shift{}, err
+	}
+	return shift, nil
+}
+
+// ErrTenantWide is returned when a user restricted to some shop floors
+// changes a shift that applies to the whole tenant
+var ErrTenantWide = apperr.Forbidden("shift_tenant_wide", "shift applies to every shop floor of the tenant")
+
+// checkScope hides the shifts of shop floors out of the reach of the user.
+// Shifts without a shop floor apply to the whole tenant and stay visible.
+func checkScope(ctx context.Context, shift Shift) error {
+	if !shift.ShopfloorID.Valid {
+		return nil
+	}
+	return shopfloors.Check(ctx, shift.ShopfloorID.UUID)
+}
+
+// checkWritable refuses changes to tenant-wide shifts by users restricted to
+// some shop floors, since they would apply to the shop floors they can't see
+func checkWritable(ctx context.Context, shopfloorID uuid.NullUUID) error {
+	if _, restricted := shopfloors.Allowed(ctx); restricted && !shopfloorID.Valid {
+		return ErrTenantWide
+	}
+	return nil
 }
 
 func (s *service) Create(ctx context.Context,request ShiftRequest) (Shift, error) {
-	parsedCustomerID, err := uuid.Parse(request.CustomerID)
+	parsedCustomerID, err := tenant.Customer(ctx, request.CustomerID)
 	if err != nil {
 		return Shift{}, err
 	}
@@ -45,9 +87,15 @@ func (s *service) Create(ctx context.Context,request ShiftRequest) (Shift, error
 			return Shift{}, err
 		}
 		parsedShopfloorID = uuid.NullUUID{UUID: id, Valid: true}
+		if err := s.checkShopFloor(ctx, id, parsedCustomerID); err != nil {
+			return Shift{}, err
+		}
 	} else {
 		parsedShopfloorID = uuid.NullUUID{Valid: false}
 	}
+	if err := checkWritable(ctx, parsedShopfloorID); err != nil {
+		return Shift{}, err
+	}
 
 	parsedStartTime, err := time.Parse("15:04", request.StartTime)
 	if err != nil {
@@ -61,7 +109,7 @@ func (s *service) Create(ctx context.Context,request ShiftRequest) (Shift, error
 	// Validate Overlap. Scope: If Shopfloor defined, use Shopfloor. Else Use Tenant.
 	var existingShifts []Shift
 	if parsedShopfloorID.Valid {
-		existingShifts, err = s.repo.FindByShopfloorID(ctx, parsedShopfloorID.UUID)
+		existingShifts, err = s.repo.FindByShopfloorID(ctx, parsedShopfloorID.UUID, &parsedCustomerID)
 	} else {
 		existingShifts, err = s.repo.FindByCustomerID(ctx, parsedCustomerID)
 	}
@@ -77,10 +125,10 @@ func (s *service) Create(ctx context.Context,request ShiftRequest) (Shift, error
 		return Shift{}, err
 	}
 	if checkOverlap(parsedStartTime, parsedEndTime, filteredShifts, uuid.Nil) {
-		return Shift{}, errors.New("shift overlaps with an existing shift")
+		return Shift{}, apperr.Conflict("shift_overlap", "shift overlaps with an existing shift")
 	}
 
-	return s.repo.Create(ctx,Shift{
+	created, err := s.repo.Create(ctx,Shift{
 		ID: uuid.New(),
 		CustomerID: parsedCustomerID,
 		ShopfloorID: parsedShopfloorID,
@@ -92,30 +140,61 @@ func (s *service) Create(ctx context.Context,request ShiftRequest) (Shift, error
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	})
+	if err != nil {
+		return Shift{}, err
+	}
+	s.audit.Created(ctx, audit.EntityShift, created.ID, created.CustomerID, created)
+	return created, nil
+}
+
+// checkShopFloor checks that a shop floor exists and belongs to customerID.
+// Shop floors out of the reach of the user look missing.
+func (s *service) checkShopFloor(ctx context.Context, shopFloorID, customerID uuid.UUID) error {
+	owner, err := s.repo.FindShopFloorCustomerID(ctx, shopFloorID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !shopfloors.Permits(ctx, shopFloorID)) {
+		return apperr.Field("shopfloor_id", "unknown_shopfloor", "shop floor not found")
+	}
+	if err != nil {
+		return err
+	}
+	if owner != customerID {
+		return apperr.Field("shopfloor_id", "foreign_shopfloor", "shop floor belongs to another customer")
+	}
+	return nil
 }
 
-func (s *service) FindByShopfloorID(ctx context.Context, shopfloorID string) ([]Shift, error) {
+// FindByShopfloorID returns a page of the shifts of a shop floor
+func (s *service) FindByShopfloorID(ctx context.Context, shopfloorID string, params listing.Params) ([]Shift, int, error) {
 	parsedShopfloorID, err := uuid.Parse(shopfloorID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, 0, err
 	}
-	return s.repo.FindByShopfloorID(ctx, parsedShopfloorID)
+	return s.repo.ListByShopfloorID(ctx, parsedShopfloorID, scope, params)
 }
 
-func (s *service) FindByCustomerID(ctx context.Context, customerID string) ([]Shift, error) {
-	parsedCustomerID, err := uuid.Parse(customerID)
+// List returns a page of the shifts of the tenant, every tenant for admins
+func (s *service) List(ctx context.Context, params listing.Params) ([]Shift, int, error) {
+	scope, err := tenant.Scope(ctx, "")
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return s.repo.FindByCustomerID(ctx, parsedCustomerID)
+	return s.repo.List(ctx, scope, params)
 }
 
 func (s *service) Update(ctx context.Context,id string, request ShiftRequest) (Shift, error) {
-	parsedID, err := uuid.Parse(id)
+	shift, err := s.FindByID(ctx, id)
 	if err != nil {
 		return Shift{}, err
 	}
-	parsedCustomerID, err := uuid.Parse(request.CustomerID)
+	if err := checkWritable(ctx, shift.ShopfloorID); err != nil {
+		return Shift{}, err
+	}
+	parsedID := shift.ID
+	parsedCustomerID, err := tenant.Customer(ctx, request.CustomerID)
 	if err != nil {
 		return Shift{}, err
 	}
@@ -127,9 +206,15 @@ func (s *service) Update(ctx context.Context,id string, request ShiftRequest) (S
 			return Shift{}, err
 		}
 		parsedShopfloorID = uuid.NullUUID{UUID: sid, Valid: true}
+		if err := s.checkShopFloor(ctx, sid, parsedCustomerID); err != nil {
+			return Shift{}, err
+		}
 	} else {
 		parsedShopfloorID = uuid.NullUUID{Valid: false}
 	}
+	if err := checkWritable(ctx, parsedShopfloorID); err != nil {
+		return Shift{}, err
+	}
 
 	parsedStartTime, err := time.Parse("15:04", request.StartTime)
 	if err != nil {
@@ -139,15 +224,10 @@ func (s *service) Update(ctx context.Context,id string, request ShiftRequest) (S
 	if err != nil {
 		return Shift{}, err
 	}
-	shift, err := s.repo.FindByID(ctx, parsedID)
-	if err != nil {
-		return Shift{}, err
-	}
-	
 	// Validate Overlap
 	var existingShifts []Shift
 	if parsedShopfloorID.Valid {
-		existingShifts, err = s.repo.FindByShopfloorID(ctx, parsedShopfloorID.UUID)
+		existingShifts, err = s.repo.FindByShopfloorID(ctx, parsedShopfloorID.UUID, &parsedCustomerID)
 	} else {
 		existingShifts, err = s.repo.FindByCustomerID(ctx, parsedCustomerID)
 	}
@@ -163,9 +243,10 @@ func (s *service) Update(ctx context.Context,id string, request ShiftRequest) (S
 		return Shift{}, err
 	}
 	if checkOverlap(parsedStartTime, parsedEndTime, filteredShifts, parsedID) {
-		return Shift{}, errors.New("shift overlaps with an existing shift")
+		return Shift{}, apperr.Conflict("shift_overlap", "shift overlaps with an existing shift")
 	}
 
+	before := shift
 	shift.CustomerID = parsedCustomerID
 	shift.ShopfloorID = parsedShopfloorID
 	shift.Name = request.Name
@@ -174,15 +255,89 @@ func (s *service) Update(ctx context.Context,id string, request ShiftRequest) (S
 	shift.EndTime = parsedEndTime
 	shift.IsActive = request.IsActive
 	shift.UpdatedAt = time.Now()
-	return s.repo.Update(ctx,shift)
+	updated, err := s.repo.Update(ctx,shift)
+	if err != nil {
+		return Shift{}, err
+	}
+	s.audit.Updated(ctx, audit.EntityShift, updated.ID, updated.CustomerID, before, updated)
+	return updated, nil
 }
 
-func (s *service) Delete(ctx context.Context,shiftID string) error {
-	parsedShiftID, err := uuid.Parse(shiftID)
+// Delete moves the shift and its schedule entries to the trash. Shifts that
+// are planned are only deleted once confirmed.
+func (s *service) Delete(ctx context.Context,shiftID string, confirmed bool) error {
+	shift, err := s.FindByID(ctx, shiftID)
+	if err != nil {
+		return err
+	}
+	if err := checkWritable(ctx, shift.ShopfloorID); err != nil {
+		return err
+	}
+	err = s.repo.Delete(ctx, shift.ID, func(preview trash.Preview) error {
+		return trash.Confirm(preview, confirmed)
+	})
 	if err != nil {
 		return err
 	}
-	return s.repo.Delete(ctx,parsedShiftID)
+	s.audit.Deleted(ctx, audit.EntityShift, shift.ID, shift.CustomerID, shift)
+	return nil
+}
+
+// Trash returns the deleted shifts of the tenant
+func (s *service) Trash(ctx context.Context, customerID string, params listing.Params) ([]Shift, int, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repo.FindDeleted(ctx, scope, params)
+}
+
+// Restore takes the shift out of the trash as long as it doesn't overlap
+// with the shifts created since it was deleted
+func (s *service) Restore(ctx context.Context, id string) (Shift, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Shift{}, err
+	}
+	shift, err := s.repo.FindDeletedByID(ctx, parsedID)
+	if err != nil {
+		return Shift{}, err
+	}
+	if err := tenant.Check(ctx, shift.CustomerID); err != nil {
+		return Shift{}, err
+	}
+	if err := checkScope(ctx, shift); err != nil {
+		return Shift{}, err
+	}
+	if err := checkWritable(ctx, shift.ShopfloorID); err != nil {
+		return Shift{}, err
+	}
+	if shift.IsActive {
+		var existingShifts []Shift
+		if shift.ShopfloorID.Valid {
+			existingShifts, err = s.repo.FindByShopfloorID(ctx, shift.ShopfloorID.UUID, &shift.CustomerID)
+		} else {
+			existingShifts, err = s.repo.FindByCustomerID(ctx, shift.CustomerID)
+		}
+		if err != nil {
+			return Shift{}, err
+		}
+		filteredShifts := []Shift{}
+		for _, existing := range existingShifts {
+			if existing.ShopfloorID == shift.ShopfloorID {
+				filteredShifts = append(filteredShifts, existing)
+			}
+		}
+		if checkOverlap(shift.StartTime, shift.EndTime, filteredShifts, shift.ID) {
+			return Shift{}, apperr.Conflict("shift_overlap", "shift overlaps with an existing shift")
+		}
+	}
+	if err := s.repo.Restore(ctx, shift.ID); err != nil {
+		return Shift{}, err
+	}
+	shift.DeletedAt = nil
+	s.audit.Restored(ctx, audit.EntityShift, shift.ID, shift.CustomerID, shift)
+	return shift, nil
 }
 
 func checkOverlap(start, end time.Time, existing []Shift, excludeID uuid.UUID) bool {
@@ -0,0 +1,17 @@
+package shopfloors
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/shopfloors", Tag: "shopfloors", Summary: "Create a shop floor", Request: ShopfloorRequest{}, Response: Shopfloor{}},
+	{Method: "GET", Path: "/api/shopfloors", Tag: "shopfloors", Summary: "List shop floors", Response: []Shopfloor{}, List: &listSpec},
+	{Method: "GET", Path: "/api/shopfloors/tree", Tag: "shopfloors", Summary: "Shop floors as a tree of sites, areas and lines", Response: []*Node{}, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "GET", Path: "/api/shopfloors/trash", Tag: "shopfloors", Summary: "List deleted shop floors", Response: []Shopfloor{}, List: &trashSpec, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "GET", Path: "/api/shopfloors/:id", Tag: "shopfloors", Summary: "Get a shop floor", Response: Shopfloor{}},
+	{Method: "GET", Path: "/api/shopfloors/:id/subtree", Tag: "shopfloors", Summary: "A shop floor and every shop floor below it", Response: []Shopfloor{}},
+	{Method: "GET", Path: "/api/shopfloors/customer/:customer_id", Tag: "shopfloors", Summary: "List the shop floors of a customer", Response: []Shopfloor{}, List: &listSpec},
+	{Method: "PUT", Path: "/api/shopfloors/:id", Tag: "shopfloors", Summary: "Update a shop floor", Request: ShopfloorRequest{}, Response: Shopfloor{}},
+	{Method: "DELETE", Path: "/api/shopfloors/:id", Tag: "shopfloors", Summary: "Move a shop floor and every record on it to the trash", Query: []openapi.Param{openapi.Confirm}},
+	{Method: "POST", Path: "/api/shopfloors/:id/restore", Tag: "shopfloors", Summary: "Restore a deleted shop floor with the records deleted along with it", Response: Shopfloor{}},
+}
@@ -1,6 +1,10 @@
 package shopfloors
 
 import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
+	"api/internal/trash"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -18,13 +22,13 @@ func (h *Handler) Create(c *gin.Context) {
 	ctx := c.Request.Context()
 	var request ShopfloorRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 
 	response, err := h.service.Create(ctx, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Shopfloor created successfully", "data": response})
@@ -33,16 +37,17 @@ func (h *Handler) Create(c *gin.Context) {
 func (h *Handler) FindAll(c *gin.Context) {
 	ctx := c.Request.Context()
 	
-	var response []Shopfloor
-	var err error
-
-	response, err = h.service.FindAll(ctx)	
-
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Shopfloors found successfully", "data": response})
+	response, total, err := h.service.List(ctx, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shopfloors found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindByID(c *gin.Context) {
@@ -50,7 +55,11 @@ func (h *Handler) FindByID(c *gin.Context) {
 	id := c.Param("id")
 	response, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shop_floor_not_found", "Shop floor not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Shopfloor found successfully", "data": response})
@@ -58,13 +67,18 @@ func (h *Handler) FindByID(c *gin.Context) {
 
 func (h *Handler) FindByCustomerID(c *gin.Context) {
 	ctx := c.Request.Context()
-	id := c.Param("id")
-	response, err := h.service.FindByCustomerID(ctx, id)
+	id := c.Param("customer_id")
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Shopfloors found successfully", "data": response})
+	response, total, err := h.service.FindByCustomerID(ctx, id, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shopfloors found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) Update(c *gin.Context) {
@@ -72,12 +86,16 @@ func (h *Handler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var request ShopfloorRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Update(ctx, id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shop_floor_not_found", "Shop floor not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Shopfloor updated successfully", "data": response})
@@ -86,9 +104,68 @@ func (h *Handler) Update(c *gin.Context) {
 func (h *Handler) Delete(c *gin.Context) {
 	ctx := c.Request.Context()
 	id := c.Param("id")
-	if err := h.service.Delete(ctx, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.Delete(ctx, id, trash.Confirmed(c.Query("confirm"))); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shop_floor_not_found", "Shop floor not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Shopfloor deleted successfully"})
+}
+
+func (h *Handler) Trash(c *gin.Context) {
+	ctx := c.Request.Context()
+	params, err := listing.Parse(c, trashSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.Trash(ctx, c.Query("customer_id"), params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted shop floors found successfully", "data": response, "meta": params.Page(total)})
+}
+
+func (h *Handler) Restore(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.Restore(ctx, c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shop_floor_not_found", "Shop floor not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shop floor restored successfully", "data": response})
+}
+
+// Tree returns the shop floors as a site / area / line tree
+func (h *Handler) Tree(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.Tree(ctx, c.Query("customer_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shopfloor tree found successfully", "data": response})
+}
+
+func (h *Handler) FindSubtree(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	response, err := h.service.FindSubtree(ctx, id)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("shop_floor_not_found", "Shop floor not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shopfloors found successfully", "data": response})
 }
\ No newline at end of file
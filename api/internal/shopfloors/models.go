@@ -1,20 +1,78 @@
 package shopfloors
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Levels of the location tree, from top to bottom
+const (
+	KindSite = "site"
+	KindArea = "area"
+	KindLine = "line"
+)
+
+// level orders the kinds; a node can only hang from a node of a lower level
+var level = map[string]int{KindSite: 0, KindArea: 1, KindLine: 2}
+
 type Shopfloor struct {
 	ID         uuid.UUID       `json:"id"`		
 	CustomerID uuid.UUID       `json:"customer_id"`
+	ParentID   uuid.NullUUID   `json:"parent_id"`
+	Kind       string          `json:"kind"`
 	Name       string    `json:"name"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
 }
 
 type ShopfloorRequest struct {
-	CustomerID string    `json:"customer_id"`
-	Name       string `json:"name"`
+	CustomerID string    `json:"customer_id" binding:"omitempty,uuid"`
+	ParentID   string    `json:"parent_id" binding:"omitempty,uuid"`
+	Kind       string    `json:"kind"`
+	Name       string `json:"name" binding:"required,max=200"`
+}
+
+// Node is a shopfloor with its children, as returned by the tree endpoint
+type Node struct {
+	Shopfloor
+	Children []*Node `json:"children"`
+}
+
+// Tree maps every shopfloor to its parent
+type Tree map[uuid.UUID]uuid.NullUUID
+
+// Within reports whether node is ancestor itself or lies below it
+func (t Tree) Within(node, ancestor uuid.UUID) bool {
+	// The walk is bounded so corrupt data cannot loop forever
+	for i := 0; i <= len(t); i++ {
+		if node == ancestor {
+			return true
+		}
+		parent, ok := t[node]
+		if !ok || !parent.Valid {
+			return false
+		}
+		node = parent.UUID
+	}
+	return false
+}
+
+// SubtreeCondition returns an SQL condition matching rows whose column
+// references the shopfloor given as parameter $arg or any shopfloor below it
+func SubtreeCondition(column string, arg int) string {
+	return subtreeCondition(column, fmt.Sprintf("$%d", arg))
+}
+
+func subtreeCondition(column, placeholder string) string {
+	return fmt.Sprintf(`%s IN (
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM shopfloors WHERE id = %s
+			UNION ALL
+			SELECT child.id FROM shopfloors child JOIN subtree ON child.parent_id = subtree.id
+		)
+		SELECT id FROM subtree
+	)`, column, placeholder)
 }
@@ -1,20 +1,50 @@
 package shopfloors
 
 import (
+	"api/internal/listing"
+	"api/internal/trash"
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// listSpec is what the shopfloor list can be sorted, filtered and searched on
+var listSpec = listing.Spec{
+	Sort: map[string]string{
+		"name":       "name",
+		"kind":       "kind",
+		"created_at": "created_at",
+	},
+	Filter: map[string]string{
+		"customer_id": "customer_id",
+		"parent_id":   "parent_id",
+		"kind":        "kind",
+	},
+	Search:  []string{"name"},
+	Default: []listing.Order{{Field: "name"}},
+}
+
+// trashSpec is what the trash can be sorted and filtered on
+var trashSpec = trash.Spec(listSpec)
+
 type Repository interface {
 	Create(ctx context.Context, shopfloor Shopfloor) (Shopfloor, error)
 	FindAll(ctx context.Context) ([]Shopfloor, error)
+	List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Shopfloor, int, error)
 	FindByID(ctx context.Context, id uuid.UUID) (Shopfloor, error)
 	FindByCustomerID(ctx context.Context, id uuid.UUID) ([]Shopfloor, error)
 	CountByCustomerID(ctx context.Context, id uuid.UUID) (int, error)
+	CountByKind(ctx context.Context, customerID uuid.UUID) (trash.Dependents, error)
+	CountChildren(ctx context.Context, id uuid.UUID) (int, error)
+	CountUsers(ctx context.Context, id uuid.UUID) (int, error)
+	FindSubtree(ctx context.Context, id uuid.UUID) ([]Shopfloor, error)
 	Update(ctx context.Context, shopfloor Shopfloor) (Shopfloor, error)
-	Delete(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID, confirm func(trash.Preview) error) error
+	FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Shopfloor, int, error)
+	FindDeletedByID(ctx context.Context, id uuid.UUID) (Shopfloor, error)
+	Restore(ctx context.Context, id uuid.UUID, check func(restored trash.Dependents) error) error
 }
 
 type repository struct {
@@ -26,9 +56,9 @@ func NewRepository(db *sql.DB) Repository {
 }
 
 func (r *repository) Create(ctx context.Context, shopfloor Shopfloor) (Shopfloor, error) {
-	query := `INSERT INTO shopfloors (id, customer_id, name, created_at, updated_at) 
-	VALUES ($1, $2, $3, $4, $5)`
-	_, err := r.db.ExecContext(ctx, query, shopfloor.ID, shopfloor.CustomerID, shopfloor.Name, shopfloor.CreatedAt, shopfloor.UpdatedAt)
+	query := `INSERT INTO shopfloors (id, customer_id, parent_id, kind, name, created_at, updated_at) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.ExecContext(ctx, query, shopfloor.ID, shopfloor.CustomerID, shopfloor.ParentID, shopfloor.Kind, shopfloor.Name, shopfloor.CreatedAt, shopfloor.UpdatedAt)
 	if err != nil {
 		return Shopfloor{}, err
 	}
@@ -36,7 +66,7 @@ func (r *repository) Create(ctx context.Context, shopfloor Shopfloor) (Shopfloor
 }
 
 func (r *repository) FindAll(ctx context.Context) ([]Shopfloor, error) {
-	query := `SELECT id, customer_id, name, created_at, updated_at FROM shopfloors`
+	query := `SELECT id, customer_id, parent_id, kind, name, created_at, updated_at FROM shopfloors WHERE deleted_at IS NULL`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -45,7 +75,7 @@ func (r *repository) FindAll(ctx context.Context) ([]Shopfloor, error) {
 	shopfloors := []Shopfloor{}
 	for rows.Next() {
 		var shopfloor Shopfloor
-		if err := rows.Scan(&shopfloor.ID, &shopfloor.CustomerID, &shopfloor.Name, &shopfloor.CreatedAt, &shopfloor.UpdatedAt); err != nil {
+		if err := rows.Scan(&shopfloor.ID, &shopfloor.CustomerID, &shopfloor.ParentID, &shopfloor.Kind, &shopfloor.Name, &shopfloor.CreatedAt, &shopfloor.UpdatedAt); err != nil {
 			return nil, err
 		}
 		shopfloors = append(shopfloors, shopfloor)
@@ -53,18 +83,32 @@ func (r *repository) FindAll(ctx context.Context) ([]Shopfloor, error) {
 	return shopfloors, nil
 }
 
+// List returns a page of the shopfloors of customerID, or of every customer when nil
+func (r *repository) List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Shopfloor, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return listing.Find(ctx, r.db, q, `SELECT id, customer_id, parent_id, kind, name, created_at, updated_at FROM shopfloors`, func(rows *sql.Rows) (Shopfloor, error) {
+		var shopfloor Shopfloor
+		err := rows.Scan(&shopfloor.ID, &shopfloor.CustomerID, &shopfloor.ParentID, &shopfloor.Kind, &shopfloor.Name, &shopfloor.CreatedAt, &shopfloor.UpdatedAt)
+		return shopfloor, err
+	})
+}
+
 func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (Shopfloor, error) {
-	query := `SELECT id, customer_id, name, created_at, updated_at FROM shopfloors WHERE id = $1`
+	query := `SELECT id, customer_id, parent_id, kind, name, created_at, updated_at FROM shopfloors WHERE id = $1 AND deleted_at IS NULL`
 	row := r.db.QueryRowContext(ctx, query, id)
 	var shopfloor Shopfloor
-	if err := row.Scan(&shopfloor.ID, &shopfloor.CustomerID, &shopfloor.Name, &shopfloor.CreatedAt, &shopfloor.UpdatedAt); err != nil {
+	if err := row.Scan(&shopfloor.ID, &shopfloor.CustomerID, &shopfloor.ParentID, &shopfloor.Kind, &shopfloor.Name, &shopfloor.CreatedAt, &shopfloor.UpdatedAt); err != nil {
 		return Shopfloor{}, err
 	}
 	return shopfloor, nil
 }
 
 func(r *repository) FindByCustomerID(ctx context.Context, id uuid.UUID)([]Shopfloor, error){
-	query := `SELECT id, customer_id, name, created_at, updated_at FROM shopfloors WHERE customer_id = $1`
+	query := `SELECT id, customer_id, parent_id, kind, name, created_at, updated_at FROM shopfloors WHERE customer_id = $1 AND deleted_at IS NULL`
 	rows, err := r.db.QueryContext(ctx, query, id)
 	if err != nil {
 		return nil, err
@@ -73,7 +117,7 @@ func(r *repository) FindByCustomerID(ctx context.Context, id uuid.UUID)([]Shopfl
 	shopfloors := []Shopfloor{}
 	for rows.Next() {
 		var shopfloor Shopfloor
-		if err := rows.Scan(&shopfloor.ID, &shopfloor.CustomerID, &shopfloor.Name, &shopfloor.CreatedAt, &shopfloor.UpdatedAt); err != nil {
+		if err := rows.Scan(&shopfloor.ID, &shopfloor.CustomerID, &shopfloor.ParentID, &shopfloor.Kind, &shopfloor.Name, &shopfloor.CreatedAt, &shopfloor.UpdatedAt); err != nil {
 			return nil, err
 		}
 		shopfloors = append(shopfloors, shopfloor)
@@ -82,7 +126,7 @@ func(r *repository) FindByCustomerID(ctx context.Context, id uuid.UUID)([]Shopfl
 }
 
 func (r *repository) CountByCustomerID(ctx context.Context, id uuid.UUID) (int, error) {
-	query := `SELECT COUNT(*) FROM shopfloors WHERE customer_id = $1`
+	query := `SELECT COUNT(*) FROM shopfloors WHERE customer_id = $1 AND deleted_at IS NULL`
 	var count int
 	err := r.db.QueryRowContext(ctx, query, id).Scan(&count)
 	if err != nil {
@@ -91,20 +135,208 @@ func (r *repository) CountByCustomerID(ctx context.Context, id uuid.UUID) (int,
 	return count, nil
 }
 
+// CountByKind counts the workcenters, operators and jobs of the tenant, for
+// the quotas of the records restored along with a shop floor
+func (r *repository) CountByKind(ctx context.Context, customerID uuid.UUID) (trash.Dependents, error) {
+	query := `SELECT
+		(SELECT COUNT(*) FROM workcenters WHERE customer_id = $1 AND deleted_at IS NULL),
+		(SELECT COUNT(*) FROM operators WHERE customer_id = $1 AND deleted_at IS NULL),
+		(SELECT COUNT(*) FROM jobs WHERE customer_id = $1 AND deleted_at IS NULL)`
+	var workcenters, operators, jobs int
+	if err := r.db.QueryRowContext(ctx, query, customerID).Scan(&workcenters, &operators, &jobs); err != nil {
+		return nil, err
+	}
+	return trash.Dependents{"workcenters": workcenters, "operators": operators, "jobs": jobs}, nil
+}
+
 func (r *repository) Update(ctx context.Context, shopfloor Shopfloor) (Shopfloor, error) {
-	query := `UPDATE shopfloors SET customer_id = $2, name = $3, updated_at = $4 WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, shopfloor.ID, shopfloor.CustomerID, shopfloor.Name, shopfloor.UpdatedAt)
+	query := `UPDATE shopfloors SET customer_id = $2, parent_id = $3, kind = $4, name = $5, updated_at = $6 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, shopfloor.ID, shopfloor.CustomerID, shopfloor.ParentID, shopfloor.Kind, shopfloor.Name, shopfloor.UpdatedAt)
 	if err != nil {
 		return Shopfloor{}, err
 	}
 	return shopfloor, nil
 }
 
-func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM shopfloors WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+// The records that depend on a shop floor: the workcenters, operators and
+// shifts on it, the jobs on it or on its workcenters, and the schedule and
+// time entries of any of them
+const (
+	shopfloorWorkcenters = `SELECT id FROM workcenters WHERE shop_floor_id = $1`
+	shopfloorOperators   = `SELECT id FROM operators WHERE shop_floor_id = $1`
+	shopfloorShifts      = `SELECT id FROM shifts WHERE shopfloor_id = $1`
+	shopfloorJobs        = `SELECT id FROM jobs WHERE shop_floor_id = $1 OR workcenter_id IN (` + shopfloorWorkcenters + `)`
+
+	shopfloorScheduleEntries = `(shopfloor_id = $1 OR workcenter_id IN (` + shopfloorWorkcenters + `)
+	OR job_id IN (` + shopfloorJobs + `) OR operator_id IN (` + shopfloorOperators + `)
+	OR shift_id IN (` + shopfloorShifts + `))`
+	shopfloorTimeEntries = `(operator_id IN (` + shopfloorOperators + `) OR workcenter_id IN (` + shopfloorWorkcenters + `))`
+)
+
+// deleteSteps move the records that depend on a shop floor to the trash along with it
+var deleteSteps = []trash.Step{
+	{Kind: "workcenters", Query: `UPDATE workcenters SET deleted_at = $2 WHERE shop_floor_id = $1 AND deleted_at IS NULL`},
+	{Kind: "operators", Query: `UPDATE operators SET deleted_at = $2 WHERE shop_floor_id = $1 AND deleted_at IS NULL`},
+	{Kind: "shifts", Query: `UPDATE shifts SET deleted_at = $2 WHERE shopfloor_id = $1 AND deleted_at IS NULL`},
+	{Kind: "jobs", Query: `UPDATE jobs SET deleted_at = $2 WHERE deleted_at IS NULL
+	AND (shop_floor_id = $1 OR workcenter_id IN (` + shopfloorWorkcenters + `))`},
+	{Kind: "schedule_entries", Query: `UPDATE schedule_entries SET deleted_at = $2 WHERE deleted_at IS NULL AND ` + shopfloorScheduleEntries},
+	{Kind: "time_entries", Query: `UPDATE time_entries SET deleted_at = $2 WHERE deleted_at IS NULL AND ` + shopfloorTimeEntries},
+}
+
+// restoreSteps take the records deleted along with a shop floor out of the trash
+var restoreSteps = []trash.Step{
+	{Kind: "workcenters", Query: `UPDATE workcenters SET deleted_at = NULL WHERE shop_floor_id = $1 AND deleted_at = $2`},
+	{Kind: "operators", Query: `UPDATE operators SET deleted_at = NULL WHERE shop_floor_id = $1 AND deleted_at = $2`},
+	{Kind: "shifts", Query: `UPDATE shifts SET deleted_at = NULL WHERE shopfloor_id = $1 AND deleted_at = $2`},
+	{Kind: "jobs", Query: `UPDATE jobs SET deleted_at = NULL WHERE deleted_at = $2
+	AND (shop_floor_id = $1 OR workcenter_id IN (` + shopfloorWorkcenters + `))
+	AND ` + trash.JobParentsLive},
+	{Kind: "schedule_entries", Query: `UPDATE schedule_entries SET deleted_at = NULL WHERE deleted_at = $2 AND ` + shopfloorScheduleEntries + `
+	AND ` + trash.ScheduleEntryParentsLive},
+	{Kind: "time_entries", Query: `UPDATE time_entries SET deleted_at = NULL WHERE deleted_at = $2 AND ` + shopfloorTimeEntries + `
+	AND ` + trash.TimeEntryParentsLive},
+}
+
+// Delete moves the shop floor and the records that depend on it to the
+// trash, marked with the same time so they are restored together. The
+// downtimes and production counts of its workcenters and jobs are kept.
+// confirm gets the preview before anything is committed and cancels the
+// deletion when it fails.
+func (r *repository) Delete(ctx context.Context, id uuid.UUID, confirm func(trash.Preview) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	var preview trash.Preview
+	query := `SELECT
+		(SELECT COUNT(*) FROM downtimes WHERE workcenter_id IN (SELECT id FROM workcenters WHERE shop_floor_id = $1 AND deleted_at IS NULL)),
+		(SELECT COUNT(*) FROM production_counts WHERE workcenter_id IN (SELECT id FROM workcenters WHERE shop_floor_id = $1 AND deleted_at IS NULL)
+			OR job_id IN (SELECT id FROM jobs WHERE shop_floor_id = $1 AND deleted_at IS NULL))`
+	var downtimes, productionCounts int
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&downtimes, &productionCounts); err != nil {
+		return err
+	}
+	preview.Kept = trash.Dependents{"downtimes": downtimes, "production_counts": productionCounts}
+
+	var deletedAt time.Time
+	query = `UPDATE shopfloors SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL RETURNING deleted_at`
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&deletedAt); err != nil {
+		return err
+	}
+	if preview.Deleted, err = trash.Cascade(ctx, tx, deleteSteps, id, deletedAt); err != nil {
+		return err
+	}
+	if err := confirm(preview); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindDeleted returns a page of the shop floors in the trash of the tenant,
+// or of every tenant when customerID is nil
+func (r *repository) FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Shopfloor, int, error) {
+	q := listing.NewQuery(trashSpec, params)
+	q.Where("deleted_at IS NOT NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return listing.Find(ctx, r.db, q, `SELECT id, customer_id, parent_id, kind, name, created_at, updated_at, deleted_at FROM shopfloors`, func(rows *sql.Rows) (Shopfloor, error) {
+		var shopfloor Shopfloor
+		err := rows.Scan(&shopfloor.ID, &shopfloor.CustomerID, &shopfloor.ParentID, &shopfloor.Kind, &shopfloor.Name, &shopfloor.CreatedAt, &shopfloor.UpdatedAt, &shopfloor.DeletedAt)
+		return shopfloor, err
+	})
+}
+
+func (r *repository) FindDeletedByID(ctx context.Context, id uuid.UUID) (Shopfloor, error) {
+	query := `SELECT id, customer_id, parent_id, kind, name, created_at, updated_at, deleted_at
+	FROM shopfloors WHERE id = $1 AND deleted_at IS NOT NULL`
+	var shopfloor Shopfloor
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&shopfloor.ID, &shopfloor.CustomerID, &shopfloor.ParentID, &shopfloor.Kind, &shopfloor.Name, &shopfloor.CreatedAt, &shopfloor.UpdatedAt, &shopfloor.DeletedAt); err != nil {
+		return Shopfloor{}, err
+	}
+	return shopfloor, nil
+}
+
+// Restore takes the shop floor out of the trash together with the records
+// deleted with it, unless its parent is still in the trash. check gets the
+// counts of the records restored before anything is committed and cancels
+// the restore when it fails.
+func (r *repository) Restore(ctx context.Context, id uuid.UUID, check func(restored trash.Dependents) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	query := `SELECT deleted_at FROM shopfloors WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&deletedAt); err != nil {
+		return err
+	}
+	query = `UPDATE shopfloors SET deleted_at = NULL WHERE id = $1
+	AND NOT EXISTS (SELECT 1 FROM shopfloors parent WHERE parent.id = shopfloors.parent_id AND parent.deleted_at IS NOT NULL)`
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return trash.ErrParentDeleted
+	}
+	restored, err := trash.Cascade(ctx, tx, restoreSteps, id, deletedAt)
+	if err != nil {
+		return err
+	}
+	if err := check(restored); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) CountChildren(ctx context.Context, id uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM shopfloors WHERE parent_id = $1 AND deleted_at IS NULL`
+	var count int
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountUsers returns how many users are restricted to the shopfloor. Users
+// in the trash don't count: they can't be changed until restored.
+func (r *repository) CountUsers(ctx context.Context, id uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM user_shopfloors us JOIN users u ON u.id = us.user_id
+	WHERE us.shopfloor_id = $1 AND u.deleted_at IS NULL`
+	var count int
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindSubtree returns the shopfloor id and every shopfloor below it
+func (r *repository) FindSubtree(ctx context.Context, id uuid.UUID) ([]Shopfloor, error) {
+	query := `SELECT id, customer_id, parent_id, kind, name, created_at, updated_at FROM shopfloors WHERE deleted_at IS NULL AND ` + SubtreeCondition("id", 1) + ` ORDER BY name`
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shopfloors := []Shopfloor{}
+	for rows.Next() {
+		var shopfloor Shopfloor
+		if err := rows.Scan(&shopfloor.ID, &shopfloor.CustomerID, &shopfloor.ParentID, &shopfloor.Kind, &shopfloor.Name, &shopfloor.CreatedAt, &shopfloor.UpdatedAt); err != nil {
+			return nil, err
+		}
+		shopfloors = append(shopfloors, shopfloor)
+	}
+	return shopfloors, nil
 }
@@ -5,8 +5,12 @@ import "github.com/gin-gonic/gin"
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/shopfloors", handler.Create)
 	router.GET("/shopfloors", handler.FindAll)
+	router.GET("/shopfloors/tree", handler.Tree)
+	router.GET("/shopfloors/trash", handler.Trash)
 	router.GET("/shopfloors/:id", handler.FindByID)
+	router.GET("/shopfloors/:id/subtree", handler.FindSubtree)
 	router.GET("/shopfloors/customer/:customer_id", handler.FindByCustomerID)
 	router.PUT("/shopfloors/:id", handler.Update)
 	router.DELETE("/shopfloors/:id", handler.Delete)
+	router.POST("/shopfloors/:id/restore", handler.Restore)
 }
\ No newline at end of file
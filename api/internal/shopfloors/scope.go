@@ -0,0 +1,65 @@
+package shopfloors
+
+import (
+	"api/internal/listing"
+	"api/internal/tenant"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Allowed returns the shopfloors the current user is restricted to, including
+// the areas and lines below the assigned ones. ok is false when the user can
+// see every shopfloor of the tenant.
+func Allowed(ctx context.Context) (ids []uuid.UUID, ok bool) {
+	ids, ok = ctx.Value("shopfloor_ids").([]uuid.UUID)
+	return ids, ok
+}
+
+// Permits reports whether the current user can access shopfloorID
+func Permits(ctx context.Context, shopfloorID uuid.UUID) bool {
+	ids, ok := Allowed(ctx)
+	if !ok {
+		return true
+	}
+	return slices.Contains(ids, shopfloorID)
+}
+
+// Check returns tenant.ErrNotFound when the current user can't access
+// shopfloorID, so the shopfloors out of their reach look missing
+func Check(ctx context.Context, shopfloorID uuid.UUID) error {
+	if !Permits(ctx, shopfloorID) {
+		return tenant.ErrNotFound
+	}
+	return nil
+}
+
+// ScopeCondition returns an SQL condition, starting with AND, that keeps the
+// rows whose column references one of the allowed shopfloors, with the value
+// for parameter $arg. Both are empty when the user is not restricted.
+func ScopeCondition(ctx context.Context, column string, arg int) (string, []interface{}) {
+	ids, ok := Allowed(ctx)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf(" AND %s = ANY($%d::uuid[])", column, arg), []interface{}{pq.Array(ids)}
+}
+
+// Restrict keeps the rows of a list whose column references one of the
+// allowed shopfloors. Lists of unrestricted users are left as they are.
+func Restrict(ctx context.Context, q *listing.Query, column string) {
+	ids, ok := Allowed(ctx)
+	if !ok {
+		return
+	}
+	q.Where(column+" = ANY(?::uuid[])", pq.Array(ids))
+}
+
+// InSubtree keeps the rows of a list whose column references shopfloorID or
+// any shopfloor below it
+func InSubtree(q *listing.Query, column string, shopfloorID uuid.UUID) {
+	q.Where(subtreeCondition(column, "?"), shopfloorID)
+}
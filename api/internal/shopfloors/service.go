@@ -1,9 +1,16 @@
 package shopfloors
 
 import (
+	"api/internal/apperr"
+	"api/internal/audit"
 	"api/internal/customers"
+	"api/internal/listing"
+	"api/internal/tenant"
+	"api/internal/trash"
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,20 +18,25 @@ import (
 
 type Service interface {
 	Create(ctx context.Context, request ShopfloorRequest) (Shopfloor, error)
-	FindAll(ctx context.Context) ([]Shopfloor, error)
+	List(ctx context.Context, params listing.Params) ([]Shopfloor, int, error)
 	FindByID(ctx context.Context, id string) (Shopfloor, error)
-	FindByCustomerID(ctx context.Context, customerID string) ([]Shopfloor, error)
+	FindByCustomerID(ctx context.Context, customerID string, params listing.Params) ([]Shopfloor, int, error)
 	Update(ctx context.Context, id string, request ShopfloorRequest) (Shopfloor, error)
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string, confirmed bool) error
+	Trash(ctx context.Context, customerID string, params listing.Params) ([]Shopfloor, int, error)
+	Restore(ctx context.Context, id string) (Shopfloor, error)
+	Tree(ctx context.Context, customerID string) ([]*Node, error)
+	FindSubtree(ctx context.Context, id string) ([]Shopfloor, error)
 }
 
 type service struct {
 	repository Repository
 	customerService customers.Service		
+	audit audit.Recorder
 }
 
-func NewService(repository Repository, customerService customers.Service) Service {
-	return &service{repository: repository, customerService: customerService}
+func NewService(repository Repository, customerService customers.Service, recorder audit.Recorder) Service {
+	return &service{repository: repository, customerService: customerService, audit: recorder}
 }
 
 func (s *service) Create(ctx context.Context, request ShopfloorRequest) (Shopfloor, error) {
@@ -54,7 +66,12 @@ func (s *service) Create(ctx context.Context, request ShopfloorRequest) (Shopflo
 		customerID = customerIDFromCtx
 	}
 	
-	// Check limits
+	kind, parentID, err := s.placement(ctx, customerID, request)
+	if err != nil {
+		return Shopfloor{}, err
+	}
+
+	// Check limits. Every node of the tree counts as a shop floor.
 	customer, err := s.customerService.FindByID(ctx, customerID.String())
 	if err != nil {
 		return Shopfloor{}, err
@@ -66,36 +83,34 @@ func (s *service) Create(ctx context.Context, request ShopfloorRequest) (Shopflo
 	}
 
 	if count >= customer.MaxShopFloors {
-		return Shopfloor{}, errors.New("max shop floors limit reached for this tenant")
+		return Shopfloor{}, apperr.QuotaExceeded("max_shop_floors", "max shop floors limit reached for this tenant")
 	}
 
 	shopfloor := Shopfloor{
 		ID:         uuid.New(),		
 		CustomerID: customerID,
+		ParentID:   parentID,
+		Kind:       kind,
 		Name:       request.Name,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
 
-	return s.repository.Create(ctx, shopfloor)
+	created, err := s.repository.Create(ctx, shopfloor)
+	if err != nil {
+		return Shopfloor{}, err
+	}
+	s.audit.Created(ctx, audit.EntityShopfloor, created.ID, created.CustomerID, created)
+	return created, nil
 }
 
-func (s *service) FindAll(ctx context.Context) ([]Shopfloor, error) {
-	isAdminVal := ctx.Value("is_admin")
-	isAdmin, ok := isAdminVal.(bool)
-	if !ok {
-		return nil, errors.New("invalid or missing is_admin in context")
-	}
-	if isAdmin{
-		return s.repository.FindAll(ctx)
+// List returns a page of the shopfloors of the tenant, every tenant for admins
+func (s *service) List(ctx context.Context, params listing.Params) ([]Shopfloor, int, error) {
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, 0, err
 	}
-	customerIDVal := ctx.Value("customer_id")
-		customerIDFromCtx, ok := customerIDVal.(uuid.UUID)
-		if !ok {
-			return nil, errors.New("invalid or missing customer_id in context")
-		}
-		customerID := customerIDFromCtx
-	return s.repository.FindByCustomerID(ctx, customerID)
+	return s.repository.List(ctx, scope, params)
 }
 
 func (s *service) FindByID(ctx context.Context, id string) (Shopfloor, error) {
@@ -103,11 +118,30 @@ func (s *service) FindByID(ctx context.Context, id string) (Shopfloor, error) {
 	if err != nil {
 		return Shopfloor{}, err
 	}
-	return s.repository.FindByID(ctx, parsedId)
+	shopfloor, err := s.repository.FindByID(ctx, parsedId)
+	if err != nil {
+		return Shopfloor{}, err
+	}
+	if err := tenant.Check(ctx, shopfloor.CustomerID); err != nil {
+		return Shopfloor{}, err
+	}
+	if err := Check(ctx, shopfloor.ID); err != nil {
+		return Shopfloor{}, err
+	}
+	return shopfloor, nil
 }
 
-func (s *service) FindByCustomerID(ctx context.Context, id string) ([]Shopfloor, error) {
-	parsedId, err := uuid.Parse(id)
+func (s *service) FindByCustomerID(ctx context.Context, id string, params listing.Params) ([]Shopfloor, int, error) {
+	parsedId, err := tenant.Customer(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repository.List(ctx, &parsedId, params)
+}
+
+// findByCustomerID returns every shop floor of the tenant, unpaged, for the tree
+func (s *service) findByCustomerID(ctx context.Context, id string) ([]Shopfloor, error) {
+	parsedId, err := tenant.Customer(ctx, id)
 	if err != nil {
 		return []Shopfloor{}, err
 	}
@@ -115,23 +149,216 @@ func (s *service) FindByCustomerID(ctx context.Context, id string) ([]Shopfloor,
 }
 
 func (s *service) Update(ctx context.Context, id string, request ShopfloorRequest) (Shopfloor, error) {
-	parsedId, err := uuid.Parse(id)
+	shopfloor, err := s.FindByID(ctx, id)
 	if err != nil {
 		return Shopfloor{}, err
 	}
-	shopfloor, err := s.repository.FindByID(ctx, parsedId)
+	kind, parentID, err := s.placement(ctx, shopfloor.CustomerID, request)
+	if err != nil {
+		return Shopfloor{}, err
+	}
+	// The new parent can't be the node itself or one of its descendants,
+	// and the children must still fit below the new kind
+	subtree, err := s.repository.FindSubtree(ctx, shopfloor.ID)
 	if err != nil {
 		return Shopfloor{}, err
 	}
+	for _, node := range subtree {
+		if parentID.Valid && node.ID == parentID.UUID {
+			return Shopfloor{}, apperr.Field("parent_id", "invalid_parent", "a shop floor can't be moved below itself")
+		}
+		if node.ParentID.Valid && node.ParentID.UUID == shopfloor.ID && level[node.Kind] <= level[kind] {
+			return Shopfloor{}, apperr.Field("parent_id", "invalid_parent", fmt.Sprintf("a %s can't contain a %s", kind, node.Kind))
+		}
+	}
+	before := shopfloor
+	shopfloor.ParentID = parentID
+	shopfloor.Kind = kind
 	shopfloor.Name = request.Name
-	return s.repository.Update(ctx, shopfloor)
+	shopfloor.UpdatedAt = time.Now()
+	updated, err := s.repository.Update(ctx, shopfloor)
+	if err != nil {
+		return Shopfloor{}, err
+	}
+	s.audit.Updated(ctx, audit.EntityShopfloor, updated.ID, updated.CustomerID, before, updated)
+	return updated, nil
 }
 
-func (s *service) Delete(ctx context.Context, id string) error {
-	parsedId, err := uuid.Parse(id)
+// Delete moves the shop floor and the records on it to the trash. Shop floors with
+// workcenters, operators, shifts, jobs or planning are only deleted once
+// confirmed; the ones with children or users are never deleted.
+func (s *service) Delete(ctx context.Context, id string, confirmed bool) error {
+	shopfloor, err := s.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	parsedId := shopfloor.ID
+	children, err := s.repository.CountChildren(ctx, parsedId)
 	if err != nil {
 		return err
 	}
-	return s.repository.Delete(ctx, parsedId)
+	if children > 0 {
+		return apperr.Conflict("has_children", "shop floor has children, delete or move them first")
+	}
+	// Removing the assignment would widen what those users can see
+	users, err := s.repository.CountUsers(ctx, parsedId)
+	if err != nil {
+		return err
+	}
+	if users > 0 {
+		return apperr.Conflict("assigned_to_users", "shop floor is assigned to users, change their shop floors first")
+	}
+	err = s.repository.Delete(ctx, parsedId, func(preview trash.Preview) error {
+		return trash.Confirm(preview, confirmed)
+	})
+	if err != nil {
+		return err
+	}
+	s.audit.Deleted(ctx, audit.EntityShopfloor, shopfloor.ID, shopfloor.CustomerID, shopfloor)
+	return nil
+}
+
+// Trash returns the deleted shop floors of the tenant
+func (s *service) Trash(ctx context.Context, customerID string, params listing.Params) ([]Shopfloor, int, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repository.FindDeleted(ctx, scope, params)
+}
+
+// Restore takes the shop floor out of the trash with the records deleted
+// along with it, as long as they fit in the quotas of the tenant
+func (s *service) Restore(ctx context.Context, id string) (Shopfloor, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Shopfloor{}, err
+	}
+	shopfloor, err := s.repository.FindDeletedByID(ctx, parsedID)
+	if err != nil {
+		return Shopfloor{}, err
+	}
+	if err := tenant.Check(ctx, shopfloor.CustomerID); err != nil {
+		return Shopfloor{}, err
+	}
+	if err := Check(ctx, shopfloor.ID); err != nil {
+		return Shopfloor{}, err
+	}
+	customer, err := s.customerService.FindByID(ctx, shopfloor.CustomerID.String())
+	if err != nil {
+		return Shopfloor{}, err
+	}
+	count, err := s.repository.CountByCustomerID(ctx, shopfloor.CustomerID)
+	if err != nil {
+		return Shopfloor{}, err
+	}
+	if count >= customer.MaxShopFloors {
+		return Shopfloor{}, apperr.QuotaExceeded("max_shop_floors", "max shop floors limit reached for this tenant")
+	}
+	counts, err := s.repository.CountByKind(ctx, shopfloor.CustomerID)
+	if err != nil {
+		return Shopfloor{}, err
+	}
+	err = s.repository.Restore(ctx, shopfloor.ID, func(restored trash.Dependents) error {
+		switch {
+		case counts["workcenters"]+restored["workcenters"] > customer.MaxWorkcenters:
+			return apperr.QuotaExceeded("max_workcenters", "max workcenters limit reached for this tenant")
+		case counts["operators"]+restored["operators"] > customer.MaxOperators:
+			return apperr.QuotaExceeded("max_operators", "max operators limit reached for this customer")
+		case counts["jobs"]+restored["jobs"] > customer.MaxJobs:
+			return apperr.QuotaExceeded("max_jobs", "max jobs limit reached for this customer")
+		}
+		return nil
+	})
+	if err != nil {
+		return Shopfloor{}, err
+	}
+	shopfloor.DeletedAt = nil
+	s.audit.Restored(ctx, audit.EntityShopfloor, shopfloor.ID, shopfloor.CustomerID, shopfloor)
+	return shopfloor, nil
+}
+
+// placement validates the kind and parent of a request. Sites are top level,
+// areas hang from a site and lines from a site or an area.
+func (s *service) placement(ctx context.Context, customerID uuid.UUID, request ShopfloorRequest) (string, uuid.NullUUID, error) {
+	kind := strings.ToLower(strings.TrimSpace(request.Kind))
+	if kind == "" {
+		kind = KindSite
+	}
+	if _, ok := level[kind]; !ok {
+		return "", uuid.NullUUID{}, apperr.Field("kind", "invalid_kind", "kind must be site, area or line")
+	}
+	if request.ParentID == "" {
+		if kind != KindSite {
+			return "", uuid.NullUUID{}, apperr.Field("parent_id", "invalid_parent", fmt.Sprintf("a %s needs a parent", kind))
+		}
+		return kind, uuid.NullUUID{}, nil
+	}
+	if kind == KindSite {
+		return "", uuid.NullUUID{}, apperr.Field("parent_id", "invalid_parent", "a site can't have a parent")
+	}
+	parsedParentID, err := uuid.Parse(request.ParentID)
+	if err != nil {
+		return "", uuid.NullUUID{}, err
+	}
+	parent, err := s.repository.FindByID(ctx, parsedParentID)
+	if err != nil {
+		return "", uuid.NullUUID{}, err
+	}
+	if err := Check(ctx, parent.ID); err != nil {
+		return "", uuid.NullUUID{}, err
+	}
+	if parent.CustomerID != customerID {
+		return "", uuid.NullUUID{}, apperr.Field("parent_id", "invalid_parent", "parent shop floor belongs to another customer")
+	}
+	if level[parent.Kind] >= level[kind] {
+		return "", uuid.NullUUID{}, apperr.Field("parent_id", "invalid_parent", fmt.Sprintf("a %s can't contain a %s", parent.Kind, kind))
+	}
+	return kind, uuid.NullUUID{UUID: parent.ID, Valid: true}, nil
+}
+
+// Tree returns the shop floors of the tenant nested under their parents
+func (s *service) Tree(ctx context.Context, customerID string) ([]*Node, error) {
+	var list []Shopfloor
+	var err error
+	isAdminVal := ctx.Value("is_admin")
+	isAdmin, ok := isAdminVal.(bool)
+	if !ok {
+		return nil, errors.New("invalid or missing is_admin in context")
+	}
+	if isAdmin && customerID == "" {
+		list, err = s.repository.FindAll(ctx)
+	} else if isAdmin {
+		list, err = s.findByCustomerID(ctx, customerID)
+	} else {
+		list, err = s.findByCustomerID(ctx, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uuid.UUID]*Node, len(list))
+	for _, shopfloor := range list {
+		nodes[shopfloor.ID] = &Node{Shopfloor: shopfloor, Children: []*Node{}}
+	}
+	roots := []*Node{}
+	for _, shopfloor := range list {
+		node := nodes[shopfloor.ID]
+		if parent, ok := nodes[shopfloor.ParentID.UUID]; shopfloor.ParentID.Valid && ok {
+			parent.Children = append(parent.Children, node)
+			continue
+		}
+		roots = append(roots, node)
+	}
+	return roots, nil
+}
+
+// FindSubtree returns the shop floor and everything beneath it
+func (s *service) FindSubtree(ctx context.Context, id string) ([]Shopfloor, error) {
+	shopfloor, err := s.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.FindSubtree(ctx, shopfloor.ID)
 }
 
@@ -0,0 +1,24 @@
+package sso
+
+import (
+	"api/internal/openapi"
+	"net/http"
+)
+
+// Docs documents the routes of RegisterRoutes and RegisterConfigRoutes
+var Docs = []openapi.Route{
+	{Method: "GET", Path: "/auth/sso/login", Tag: "sso", Summary: "Redirect to the identity provider of the customer", Public: true, Status: http.StatusFound, Query: []openapi.Param{
+		{Name: "customer_id"},
+		{Name: "email", Description: "Finds the customer by the domain when customer_id is missing"},
+	}},
+	{Method: "GET", Path: "/auth/sso/callback", Tag: "sso", Summary: "Return from the identity provider; redirects to the web app with a one-time code", Public: true, Status: http.StatusFound, Query: []openapi.Param{
+		{Name: "code"},
+		{Name: "state"},
+		{Name: "error"},
+		{Name: "error_description"},
+	}},
+
+	{Method: "GET", Path: "/api/customers/:id/sso", Tag: "sso", Summary: "Single sign-on configuration of a customer, for admins", Response: Config{}},
+	{Method: "PUT", Path: "/api/customers/:id/sso", Tag: "sso", Summary: "Configure single sign-on for a customer, for admins", Request: ConfigRequest{}, Response: Config{}},
+	{Method: "DELETE", Path: "/api/customers/:id/sso", Tag: "sso", Summary: "Turn off single sign-on for a customer, for admins"},
+}
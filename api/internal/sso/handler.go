@@ -0,0 +1,131 @@
+package sso
+
+import (
+	"api/internal/apperr"
+	"api/internal/tenant"
+	"api/internal/users"
+	"api/middleware"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+	appURL  string
+}
+
+func NewHandler(service Service, appURL string) Handler {
+	return Handler{service: service, appURL: appURL}
+}
+
+// Login sends the browser to the identity provider of the customer, given
+// by customer_id or by the domain of email
+func (h *Handler) Login(c *gin.Context) {
+	redirect, err := h.service.Start(c.Request.Context(), c.Query("customer_id"), c.Query("email"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// Callback is where the identity provider sends the browser back to. It
+// hands the login over to the web app with a one-time code.
+func (h *Handler) Callback(c *gin.Context) {
+	if providerError := c.Query("error"); providerError != "" {
+		slog.Warn("Single sign-on refused by the identity provider", slog.String("error", providerError),
+			slog.String("description", c.Query("error_description")))
+		h.redirect(c, "/login", "sso_error", "denied")
+		return
+	}
+	code, err := h.service.Callback(c.Request.Context(), c.Query("state"), c.Query("code"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	h.redirect(c, "/sso/callback", "code", code)
+}
+
+// fail sends the browser back to the login page of the web app with the reason
+func (h *Handler) fail(c *gin.Context, err error) {
+	reason := "failed"
+	switch err {
+	case ErrNotConfigured:
+		reason = "not_configured"
+	case ErrInvalidState:
+		reason = "expired"
+	case ErrNoEmail, ErrEmailUnverified, ErrForeignUser:
+		reason = "rejected"
+	case users.ErrMaxUsers:
+		reason = "max_users"
+	}
+	slog.Warn("Single sign-on failed", slog.String("reason", reason), slog.Any("error", err))
+	h.redirect(c, "/login", "sso_error", reason)
+}
+
+func (h *Handler) redirect(c *gin.Context, path string, key string, value string) {
+	c.Redirect(http.StatusFound, h.appURL+path+"?"+url.Values{key: {value}}.Encode())
+}
+
+// The configuration is managed by system admins
+
+func (h *Handler) FindConfig(c *gin.Context) {
+	if !middleware.IsAdmin(c) {
+		c.Error(apperr.Forbidden("forbidden", "Forbidden"))
+		return
+	}
+	response, err := h.service.FindConfig(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.Error(apperr.NotFound("single_sign_on_not_found", "Single sign-on not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Single sign-on found successfully", "data": response})
+}
+
+func (h *Handler) SaveConfig(c *gin.Context) {
+	if !middleware.IsAdmin(c) {
+		c.Error(apperr.Forbidden("forbidden", "Forbidden"))
+		return
+	}
+	var request ConfigRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.SaveConfig(c.Request.Context(), c.Param("id"), request)
+	if err != nil {
+		switch {
+		case tenant.IsNotFound(err):
+			c.Error(apperr.NotFound("customer_not_found", "Customer not found"))
+		default:
+			c.Error(err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Single sign-on saved successfully", "data": response})
+}
+
+func (h *Handler) DeleteConfig(c *gin.Context) {
+	if !middleware.IsAdmin(c) {
+		c.Error(apperr.Forbidden("forbidden", "Forbidden"))
+		return
+	}
+	if err := h.service.DeleteConfig(c.Request.Context(), c.Param("id")); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("customer_not_found", "Customer not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Single sign-on deleted successfully"})
+}
@@ -0,0 +1,67 @@
+package sso
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How long a user has to log in at the identity provider
+const stateTTL = 10 * time.Minute
+
+// Config is the OpenID Connect single sign-on of a customer
+type Config struct {
+	CustomerID uuid.UUID `json:"customer_id"`
+	Issuer     string    `json:"issuer"`
+	ClientID   string    `json:"client_id"`
+	// ClientSecret is never returned
+	ClientSecret string     `json:"-"`
+	Scopes       []string   `json:"scopes"`
+	Domains      []string   `json:"domains"`
+	RoleClaim    string     `json:"role_claim"`
+	RoleMapping  []RoleRule `json:"role_mapping"`
+	DefaultRole  string     `json:"default_role"`
+	Enabled      bool       `json:"enabled"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// RoleRule gives Role to users whose role claim contains Value
+type RoleRule struct {
+	Value string `json:"value"`
+	Role  string `json:"role"`
+}
+
+type ConfigRequest struct {
+	Issuer   string `json:"issuer" binding:"required,url"`
+	ClientID string `json:"client_id" binding:"required"`
+	// An empty secret keeps the current one
+	ClientSecret string     `json:"client_secret"`
+	Scopes       []string   `json:"scopes"`
+	Domains      []string   `json:"domains"`
+	RoleClaim    string     `json:"role_claim"`
+	RoleMapping  []RoleRule `json:"role_mapping"`
+	DefaultRole  string     `json:"default_role"`
+	Enabled      bool       `json:"enabled"`
+}
+
+// state is a pending authorization request. Only the hash of the state
+// sent to the identity provider is stored.
+type state struct {
+	Hash         string
+	CustomerID   uuid.UUID
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// Identity is the user an identity provider vouches for in an ID token
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified *bool
+	Name          string
+	// Roles are the values of the role claim
+	Roles []string
+}
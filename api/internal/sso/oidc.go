@@ -0,0 +1,272 @@
+package sso
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// How long discovery documents and signing keys are cached
+const discoveryTTL = time.Hour
+
+// provider is the part of the discovery document of an issuer we use
+type provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwk is a signing key of the issuer. Only RSA keys are supported, the
+// ones every provider offers.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type cachedProvider struct {
+	provider provider
+	keys     map[string]*rsa.PublicKey
+	expires  time.Time
+}
+
+// discovery fetches and caches the configuration and keys of issuers
+type discovery struct {
+	client *http.Client
+
+	mu        sync.Mutex
+	providers map[string]cachedProvider
+}
+
+func newDiscovery() *discovery {
+	return &discovery{
+		client:    &http.Client{Timeout: 10 * time.Second},
+		providers: map[string]cachedProvider{},
+	}
+}
+
+// provider returns the discovery document of the issuer
+func (d *discovery) provider(ctx context.Context, issuer string) (provider, error) {
+	d.mu.Lock()
+	cached, ok := d.providers[issuer]
+	d.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.provider, nil
+	}
+
+	var p provider
+	if err := d.get(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &p); err != nil {
+		return provider{}, err
+	}
+	// The issuer must match exactly, so a document can't speak for another one
+	if p.Issuer != issuer {
+		return provider{}, fmt.Errorf("discovery document is for issuer %q, not %q", p.Issuer, issuer)
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return provider{}, errors.New("incomplete discovery document")
+	}
+	d.mu.Lock()
+	d.providers[issuer] = cachedProvider{provider: p, expires: time.Now().Add(discoveryTTL)}
+	d.mu.Unlock()
+	return p, nil
+}
+
+// key returns the signing key kid of the issuer. The keys are fetched again
+// when kid is unknown, as providers rotate them.
+func (d *discovery) key(ctx context.Context, p provider, kid string) (*rsa.PublicKey, error) {
+	d.mu.Lock()
+	cached := d.providers[p.Issuer]
+	d.mu.Unlock()
+	if key := pickKey(cached.keys, kid); key != nil {
+		return key, nil
+	}
+
+	var set jwks
+	if err := d.get(ctx, p.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		key, err := k.rsa()
+		if err != nil {
+			return nil, err
+		}
+		keys[k.Kid] = key
+	}
+	d.mu.Lock()
+	cached.provider = p
+	cached.keys = keys
+	if cached.expires.IsZero() {
+		cached.expires = time.Now().Add(discoveryTTL)
+	}
+	d.providers[p.Issuer] = cached
+	d.mu.Unlock()
+
+	if key := pickKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// pickKey returns the key kid, or the only key when the token names none
+func pickKey(keys map[string]*rsa.PublicKey, kid string) *rsa.PublicKey {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return keys[kid]
+}
+
+func (k jwk) rsa() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func (d *discovery) get(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %d", url, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(target)
+}
+
+// oauth returns the OAuth 2.0 client of the customer at the provider
+func oauth(cfg Config, p provider, callbackURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint:     oauth2.Endpoint{AuthURL: p.AuthorizationEndpoint, TokenURL: p.TokenEndpoint},
+		RedirectURL:  callbackURL,
+		Scopes:       cfg.Scopes,
+	}
+}
+
+// exchange trades the authorization code for the ID token
+func (d *discovery) exchange(ctx context.Context, client *oauth2.Config, code string, verifier string) (string, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, d.client)
+	token, err := client.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return "", err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return "", errors.New("the token response has no ID token")
+	}
+	return rawIDToken, nil
+}
+
+// verify checks the signature, issuer, audience, lifetime and nonce of the
+// ID token and returns the identity in it
+func (d *discovery) verify(ctx context.Context, cfg Config, p provider, rawIDToken string, nonce string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return d.key(ctx, p, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return Identity{}, err
+	}
+	if claimString(claims, "nonce") != nonce {
+		return Identity{}, errors.New("the ID token nonce doesn't match")
+	}
+
+	identity := Identity{
+		Issuer:  p.Issuer,
+		Subject: claimString(claims, "sub"),
+		Email:   strings.ToLower(claimString(claims, "email")),
+		Name:    claimString(claims, "preferred_username"),
+		Roles:   claimStrings(claims, cfg.RoleClaim),
+	}
+	if identity.Subject == "" {
+		return Identity{}, errors.New("the ID token has no subject")
+	}
+	if identity.Name == "" {
+		identity.Name = claimString(claims, "name")
+	}
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = &verified
+	case string:
+		value := verified == "true"
+		identity.EmailVerified = &value
+	}
+	return identity, nil
+}
+
+func claimString(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// claimStrings returns the values of a string or list claim. Dots reach
+// into nested claims, e.g. "realm_access.roles".
+func claimStrings(claims jwt.MapClaims, path string) []string {
+	if path == "" {
+		return nil
+	}
+	var value interface{} = map[string]interface{}(claims)
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
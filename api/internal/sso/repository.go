@@ -0,0 +1,132 @@
+package sso
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Repository interface {
+	FindConfig(ctx context.Context, customerID uuid.UUID) (Config, error)
+	FindConfigByDomain(ctx context.Context, domain string) (Config, error)
+	SaveConfig(ctx context.Context, cfg Config) (Config, error)
+	DeleteConfig(ctx context.Context, customerID uuid.UUID) error
+	DomainTaken(ctx context.Context, customerID uuid.UUID, domains []string) (bool, error)
+	CreateState(ctx context.Context, st state) error
+	ConsumeState(ctx context.Context, hash string) (state, error)
+	FindIdentity(ctx context.Context, issuer string, subject string) (uuid.UUID, error)
+	LinkIdentity(ctx context.Context, userID uuid.UUID, issuer string, subject string) error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+const selectConfigs = `SELECT customer_id, issuer, client_id, client_secret, scopes, domains, role_claim, role_mapping,
+	default_role, enabled, created_at, updated_at
+	FROM sso_configs`
+
+func scanConfig(row *sql.Row) (Config, error) {
+	var cfg Config
+	var mapping []byte
+	err := row.Scan(&cfg.CustomerID, &cfg.Issuer, &cfg.ClientID, &cfg.ClientSecret, pq.Array(&cfg.Scopes), pq.Array(&cfg.Domains),
+		&cfg.RoleClaim, &mapping, &cfg.DefaultRole, &cfg.Enabled, &cfg.CreatedAt, &cfg.UpdatedAt)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := json.Unmarshal(mapping, &cfg.RoleMapping); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func (r *repository) FindConfig(ctx context.Context, customerID uuid.UUID) (Config, error) {
+	return scanConfig(r.db.QueryRowContext(ctx, selectConfigs+` WHERE customer_id = $1`, customerID))
+}
+
+func (r *repository) FindConfigByDomain(ctx context.Context, domain string) (Config, error) {
+	return scanConfig(r.db.QueryRowContext(ctx, selectConfigs+` WHERE $1 = ANY(domains) AND enabled`, domain))
+}
+
+func (r *repository) SaveConfig(ctx context.Context, cfg Config) (Config, error) {
+	mapping, err := json.Marshal(cfg.RoleMapping)
+	if err != nil {
+		return Config{}, err
+	}
+	query := `INSERT INTO sso_configs (customer_id, issuer, client_id, client_secret, scopes, domains, role_claim, role_mapping,
+		default_role, enabled, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	ON CONFLICT (customer_id) DO UPDATE SET issuer = EXCLUDED.issuer, client_id = EXCLUDED.client_id,
+		client_secret = EXCLUDED.client_secret, scopes = EXCLUDED.scopes, domains = EXCLUDED.domains,
+		role_claim = EXCLUDED.role_claim, role_mapping = EXCLUDED.role_mapping, default_role = EXCLUDED.default_role,
+		enabled = EXCLUDED.enabled, updated_at = EXCLUDED.updated_at`
+	_, err = r.db.ExecContext(ctx, query, cfg.CustomerID, cfg.Issuer, cfg.ClientID, cfg.ClientSecret, pq.Array(cfg.Scopes),
+		pq.Array(cfg.Domains), cfg.RoleClaim, mapping, cfg.DefaultRole, cfg.Enabled, cfg.CreatedAt, cfg.UpdatedAt)
+	if err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func (r *repository) DeleteConfig(ctx context.Context, customerID uuid.UUID) error {
+	query := `DELETE FROM sso_configs WHERE customer_id = $1`
+	_, err := r.db.ExecContext(ctx, query, customerID)
+	return err
+}
+
+// DomainTaken reports whether another customer already claims one of the domains
+func (r *repository) DomainTaken(ctx context.Context, customerID uuid.UUID, domains []string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM sso_configs WHERE customer_id <> $1 AND domains && $2::text[])`
+	var taken bool
+	if err := r.db.QueryRowContext(ctx, query, customerID, pq.Array(domains)).Scan(&taken); err != nil {
+		return false, err
+	}
+	return taken, nil
+}
+
+// CreateState stores a pending login and drops the expired ones
+func (r *repository) CreateState(ctx context.Context, st state) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM sso_states WHERE expires_at < $1`, time.Now()); err != nil {
+		return err
+	}
+	query := `INSERT INTO sso_states (state_hash, customer_id, nonce, code_verifier, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.ExecContext(ctx, query, st.Hash, st.CustomerID, st.Nonce, st.CodeVerifier, st.ExpiresAt)
+	return err
+}
+
+// ConsumeState removes a pending login and returns it. It returns
+// sql.ErrNoRows when the state is unknown, used or expired.
+func (r *repository) ConsumeState(ctx context.Context, hash string) (state, error) {
+	query := `DELETE FROM sso_states WHERE state_hash = $1 AND expires_at > $2
+		RETURNING state_hash, customer_id, nonce, code_verifier, expires_at`
+	var st state
+	err := r.db.QueryRowContext(ctx, query, hash, time.Now()).Scan(&st.Hash, &st.CustomerID, &st.Nonce, &st.CodeVerifier, &st.ExpiresAt)
+	if err != nil {
+		return state{}, err
+	}
+	return st, nil
+}
+
+func (r *repository) FindIdentity(ctx context.Context, issuer string, subject string) (uuid.UUID, error) {
+	query := `SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2`
+	var userID uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, issuer, subject).Scan(&userID); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+func (r *repository) LinkIdentity(ctx context.Context, userID uuid.UUID, issuer string, subject string) error {
+	query := `INSERT INTO user_identities (id, user_id, issuer, subject) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, query, uuid.New(), userID, issuer, subject)
+	return err
+}
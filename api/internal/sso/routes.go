@@ -0,0 +1,17 @@
+package sso
+
+import "github.com/gin-gonic/gin"
+
+// RegisterRoutes registers the login flow, which has no logged in user
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/sso/login", handler.Login)
+	router.GET("/sso/callback", handler.Callback)
+}
+
+// RegisterConfigRoutes registers the routes system admins configure the
+// single sign-on of a customer with
+func RegisterConfigRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/customers/:id/sso", handler.FindConfig)
+	router.PUT("/customers/:id/sso", handler.SaveConfig)
+	router.DELETE("/customers/:id/sso", handler.DeleteConfig)
+}
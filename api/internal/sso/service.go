@@ -0,0 +1,329 @@
+package sso
+
+import (
+	"api/internal/apperr"
+	"api/config"
+	"api/internal/auth"
+	"api/internal/customers"
+	"api/internal/roles"
+	"api/internal/users"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrNotConfigured   = apperr.NotFound("sso_not_configured", "single sign-on is not configured")
+	ErrInvalidState    = apperr.Validation("invalid_sso_state", "invalid or expired single sign-on request")
+	ErrDomainTaken     = apperr.Conflict("domain_taken", "a domain is already used by another customer")
+	ErrNoEmail         = apperr.Forbidden("sso_no_email", "the identity provider didn't share an email")
+	ErrEmailUnverified = apperr.Forbidden("sso_email_unverified", "the identity provider hasn't verified the email")
+	ErrForeignUser     = apperr.Forbidden("sso_foreign_user", "the user belongs to another customer")
+)
+
+type Service interface {
+	FindConfig(ctx context.Context, customerID string) (Config, error)
+	SaveConfig(ctx context.Context, customerID string, request ConfigRequest) (Config, error)
+	DeleteConfig(ctx context.Context, customerID string) error
+	Start(ctx context.Context, customerID string, email string) (string, error)
+	Callback(ctx context.Context, stateValue string, code string) (string, error)
+}
+
+type service struct {
+	repo            Repository
+	customerService customers.Service
+	userService     users.Service
+	roleService     roles.Service
+	authService     auth.AuthService
+	discovery       *discovery
+	callbackURL     string
+}
+
+func NewService(repo Repository, customerService customers.Service, userService users.Service, roleService roles.Service, authService auth.AuthService, cfg config.Config) Service {
+	return &service{
+		repo:            repo,
+		customerService: customerService,
+		userService:     userService,
+		roleService:     roleService,
+		authService:     authService,
+		discovery:       newDiscovery(),
+		callbackURL:     cfg.SSO.CallbackURL,
+	}
+}
+
+// customer returns the customer the current user asked for, or ErrNotFound
+func (s *service) customer(ctx context.Context, customerID string) (customers.Customer, error) {
+	return s.customerService.FindByID(ctx, customerID)
+}
+
+func (s *service) FindConfig(ctx context.Context, customerID string) (Config, error) {
+	customer, err := s.customer(ctx, customerID)
+	if err != nil {
+		return Config{}, err
+	}
+	return s.repo.FindConfig(ctx, customer.ID)
+}
+
+func (s *service) SaveConfig(ctx context.Context, customerID string, request ConfigRequest) (Config, error) {
+	customer, err := s.customer(ctx, customerID)
+	if err != nil {
+		return Config{}, err
+	}
+	current, err := s.repo.FindConfig(ctx, customer.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Config{}, err
+	}
+
+	now := time.Now()
+	cfg := Config{
+		CustomerID:   customer.ID,
+		Issuer:       strings.TrimSpace(request.Issuer),
+		ClientID:     strings.TrimSpace(request.ClientID),
+		ClientSecret: request.ClientSecret,
+		Scopes:       request.Scopes,
+		RoleClaim:    strings.TrimSpace(request.RoleClaim),
+		RoleMapping:  request.RoleMapping,
+		DefaultRole:  request.DefaultRole,
+		Enabled:      request.Enabled,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if !current.CreatedAt.IsZero() {
+		cfg.CreatedAt = current.CreatedAt
+	}
+	if cfg.ClientSecret == "" {
+		cfg.ClientSecret = current.ClientSecret
+	}
+	if cfg.ClientSecret == "" {
+		return Config{}, apperr.Field("client_secret", "client_secret_required", "client_secret is required")
+	}
+	if !slices.Contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid", "email", "profile"}, cfg.Scopes...)
+	}
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = "groups"
+	}
+	if cfg.RoleMapping == nil {
+		cfg.RoleMapping = []RoleRule{}
+	}
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = roles.RoleReadOnly
+	}
+	for _, code := range append([]string{cfg.DefaultRole}, ruleRoles(cfg.RoleMapping)...) {
+		if _, err := s.roleService.Resolve(ctx, customer.ID, code); err != nil {
+			return Config{}, err
+		}
+	}
+
+	cfg.Domains = []string{}
+	for _, domain := range request.Domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" && !slices.Contains(cfg.Domains, domain) {
+			cfg.Domains = append(cfg.Domains, domain)
+		}
+	}
+	taken, err := s.repo.DomainTaken(ctx, customer.ID, cfg.Domains)
+	if err != nil {
+		return Config{}, err
+	}
+	if taken {
+		return Config{}, ErrDomainTaken
+	}
+	return s.repo.SaveConfig(ctx, cfg)
+}
+
+func ruleRoles(rules []RoleRule) []string {
+	codes := make([]string, len(rules))
+	for i, rule := range rules {
+		codes[i] = rule.Role
+	}
+	return codes
+}
+
+func (s *service) DeleteConfig(ctx context.Context, customerID string) error {
+	customer, err := s.customer(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteConfig(ctx, customer.ID)
+}
+
+// Start begins a login at the identity provider of the customer, given by
+// ID or by the domain of the user's email, and returns where to send the
+// user. The request uses a nonce and PKCE.
+func (s *service) Start(ctx context.Context, customerID string, email string) (string, error) {
+	cfg, err := s.enabledConfig(ctx, customerID, email)
+	if err != nil {
+		return "", err
+	}
+	p, err := s.discovery.provider(ctx, cfg.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	stateValue, err := randomValue()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomValue()
+	if err != nil {
+		return "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+	err = s.repo.CreateState(ctx, state{
+		Hash:         hashValue(stateValue),
+		CustomerID:   cfg.CustomerID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(stateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	client := oauth(cfg, p, s.callbackURL)
+	return client.AuthCodeURL(stateValue, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce)), nil
+}
+
+func (s *service) enabledConfig(ctx context.Context, customerID string, email string) (Config, error) {
+	var cfg Config
+	var err error
+	if customerID != "" {
+		parsedID, parseErr := uuid.Parse(customerID)
+		if parseErr != nil {
+			return Config{}, ErrNotConfigured
+		}
+		cfg, err = s.repo.FindConfig(ctx, parsedID)
+	} else {
+		_, domain, _ := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+		cfg, err = s.repo.FindConfigByDomain(ctx, domain)
+	}
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !cfg.Enabled) {
+		return Config{}, ErrNotConfigured
+	}
+	return cfg, err
+}
+
+// Callback completes the login at the identity provider: it exchanges the
+// code, verifies the ID token, finds or provisions the user and returns a
+// one-time code the web app exchanges for our tokens
+func (s *service) Callback(ctx context.Context, stateValue string, code string) (string, error) {
+	st, err := s.repo.ConsumeState(ctx, hashValue(stateValue))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidState
+	}
+	if err != nil {
+		return "", err
+	}
+	cfg, err := s.repo.FindConfig(ctx, st.CustomerID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !cfg.Enabled) {
+		return "", ErrNotConfigured
+	}
+	if err != nil {
+		return "", err
+	}
+	p, err := s.discovery.provider(ctx, cfg.Issuer)
+	if err != nil {
+		return "", err
+	}
+	rawIDToken, err := s.discovery.exchange(ctx, oauth(cfg, p, s.callbackURL), code, st.CodeVerifier)
+	if err != nil {
+		return "", err
+	}
+	identity, err := s.discovery.verify(ctx, cfg, p, rawIDToken, st.Nonce)
+	if err != nil {
+		return "", err
+	}
+	user, err := s.resolveUser(ctx, cfg, identity)
+	if err != nil {
+		return "", err
+	}
+	return s.authService.IssueLoginCode(ctx, user.ID)
+}
+
+// resolveUser returns the user of the identity with the role it maps to.
+// Unknown identities are linked to the user of the tenant with the same
+// email, or to a new user while the tenant has room for one.
+func (s *service) resolveUser(ctx context.Context, cfg Config, identity Identity) (users.User, error) {
+	role := roleFor(cfg, identity.Roles)
+
+	userID, err := s.repo.FindIdentity(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		user, err := s.userService.Lookup(ctx, userID)
+		if err != nil {
+			return users.User{}, err
+		}
+		if user.CustomerID != cfg.CustomerID {
+			return users.User{}, ErrForeignUser
+		}
+		return s.userService.SyncRole(ctx, user, role)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return users.User{}, err
+	}
+
+	if identity.Email == "" {
+		return users.User{}, ErrNoEmail
+	}
+	if identity.EmailVerified != nil && !*identity.EmailVerified {
+		return users.User{}, ErrEmailUnverified
+	}
+	user, err := s.userService.FindByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		if user.CustomerID != cfg.CustomerID {
+			return users.User{}, ErrForeignUser
+		}
+		user, err = s.userService.SyncRole(ctx, user, role)
+	case errors.Is(err, sql.ErrNoRows):
+		username := identity.Name
+		if username == "" {
+			username, _, _ = strings.Cut(identity.Email, "@")
+		}
+		user, err = s.userService.Provision(ctx, cfg.CustomerID, users.UserRequest{
+			Username: username,
+			Email:    identity.Email,
+			Role:     role,
+		})
+	}
+	if err != nil {
+		return users.User{}, err
+	}
+	if err := s.repo.LinkIdentity(ctx, user.ID, identity.Issuer, identity.Subject); err != nil {
+		return users.User{}, err
+	}
+	return user, nil
+}
+
+// roleFor returns the role of the first rule matching the claim values, or
+// the default role
+func roleFor(cfg Config, values []string) string {
+	for _, rule := range cfg.RoleMapping {
+		if slices.Contains(values, rule.Value) {
+			return rule.Role
+		}
+	}
+	return cfg.DefaultRole
+}
+
+func randomValue() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
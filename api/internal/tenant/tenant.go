@@ -0,0 +1,85 @@
+// Package tenant scopes data access to the customer of the current user.
+// ContextMiddleware puts "is_admin" and "customer_id" in the request context;
+// services use these helpers on every path that takes an ID so a tenant can
+// never read or change the data of another one.
+package tenant
+
+import (
+	"api/internal/apperr"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned for records of another tenant. It is sql.ErrNoRows
+// so foreign records can't be told apart from missing ones.
+var ErrNotFound = sql.ErrNoRows
+
+// Scope returns the tenant the current user can see. Admins get nil (every
+// tenant) unless they ask for a specific one; other users always get their
+// own tenant, whatever they ask for.
+func Scope(ctx context.Context, requested string) (*uuid.UUID, error) {
+	isAdminVal := ctx.Value("is_admin")
+	isAdmin, ok := isAdminVal.(bool)
+	if !ok {
+		return nil, errors.New("invalid or missing is_admin in context")
+	}
+	if isAdmin {
+		if requested == "" {
+			return nil, nil
+		}
+		parsedID, err := uuid.Parse(requested)
+		if err != nil {
+			return nil, err
+		}
+		return &parsedID, nil
+	}
+	customerIDVal := ctx.Value("customer_id")
+	customerIDFromCtx, ok := customerIDVal.(uuid.UUID)
+	if !ok {
+		return nil, errors.New("invalid or missing customer_id in context")
+	}
+	return &customerIDFromCtx, nil
+}
+
+// Customer returns the tenant to work on: the requested one for admins and
+// the user's own tenant for everyone else
+func Customer(ctx context.Context, requested string) (uuid.UUID, error) {
+	scope, err := Scope(ctx, requested)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if scope == nil {
+		return uuid.Nil, apperr.Field("customer_id", "customer_id_required", "customer_id is required")
+	}
+	return *scope, nil
+}
+
+// Owns reports whether the current user can access data of customerID
+func Owns(ctx context.Context, customerID uuid.UUID) (bool, error) {
+	scope, err := Scope(ctx, "")
+	if err != nil {
+		return false, err
+	}
+	return scope == nil || *scope == customerID, nil
+}
+
+// Check returns ErrNotFound when the current user can't access data of customerID
+func Check(ctx context.Context, customerID uuid.UUID) error {
+	ok, err := Owns(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IsNotFound reports whether err means the record is missing or belongs to
+// another tenant
+func IsNotFound(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
@@ -0,0 +1,22 @@
+package timeentries
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/time-entries", Tag: "time-entries", Summary: "Create a time entry", Request: TimeEntryRequest{}, Response: TimeEntry{}},
+	{Method: "GET", Path: "/api/time-entries", Tag: "time-entries", Summary: "List time entries", Response: []TimeEntry{}, List: &listSpec, Query: []openapi.Param{
+		openapi.CustomerID,
+		{Name: "operator_id"},
+		{Name: "from", Description: "YYYY-MM-DD or RFC 3339"},
+		{Name: "to", Description: "YYYY-MM-DD or RFC 3339"},
+	}},
+	{Method: "GET", Path: "/api/time-entries/trash", Tag: "time-entries", Summary: "List deleted time entries", Response: []TimeEntry{}, List: &trashSpec, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "GET", Path: "/api/time-entries/:id", Tag: "time-entries", Summary: "Get a time entry", Response: TimeEntry{}},
+	{Method: "GET", Path: "/api/time-entries/customer/:customer_id", Tag: "time-entries", Summary: "List the time entries of a customer", Response: []TimeEntry{}, List: &listSpec},
+	{Method: "GET", Path: "/api/time-entries/operator/:operator_id", Tag: "time-entries", Summary: "List the time entries of an operator", Response: []TimeEntry{}, List: &listSpec},
+	{Method: "GET", Path: "/api/time-entries/current/:operator_id", Tag: "time-entries", Summary: "The open time entry of an operator", Response: TimeEntry{}},
+	{Method: "PUT", Path: "/api/time-entries/:id", Tag: "time-entries", Summary: "Update a time entry", Request: TimeEntryRequest{}, Response: TimeEntry{}},
+	{Method: "DELETE", Path: "/api/time-entries/:id", Tag: "time-entries", Summary: "Move a time entry to the trash"},
+	{Method: "POST", Path: "/api/time-entries/:id/restore", Tag: "time-entries", Summary: "Restore a deleted time entry", Response: TimeEntry{}},
+}
@@ -1,6 +1,9 @@
 package timeentries
 
 import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
 	"net/http"
 	"time"
 
@@ -20,13 +23,13 @@ func (h *Handler) Create(c *gin.Context) {
 	ctx := c.Request.Context()
 	var request TimeEntryRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 
 	response, err := h.service.Create(ctx, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Time entry created successfully", "data": response})
@@ -37,7 +40,11 @@ func (h *Handler) FindByID(c *gin.Context) {
 	id := c.Param("id")
 	response, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("time_entry_not_found", "Time entry not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Time entry found successfully", "data": response})
@@ -46,12 +53,17 @@ func (h *Handler) FindByID(c *gin.Context) {
 func (h *Handler) FindByCustomerID(c *gin.Context) {
 	ctx := c.Request.Context()
 	customerID := c.Param("customer_id")
-	response, err := h.service.FindByCustomerID(ctx, customerID)
+	params, err := listing.Parse(c, listSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.FindByCustomerID(ctx, customerID, params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Time entries found successfully", "data": response})
+	c.JSON(http.StatusOK, gin.H{"message": "Time entries found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindAll(c *gin.Context) {
@@ -92,23 +104,37 @@ func (h *Handler) FindAll(c *gin.Context) {
         }
 	}
 
-	response, err := h.service.Search(ctx, filter)
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Time entries found successfully", "data": response})
+	response, total, err := h.service.Search(ctx, filter, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Time entries found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindByOperatorID(c *gin.Context) {
 	ctx := c.Request.Context()
 	operatorID := c.Param("operator_id")
-	response, err := h.service.FindByOperatorID(ctx, operatorID)
+	params, err := listing.Parse(c, listSpec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Time entries found successfully", "data": response})
+	response, total, err := h.service.FindByOperatorID(ctx, operatorID, params)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("operator_not_found", "Operator not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Time entries found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindCurrent(c *gin.Context) {
@@ -116,7 +142,11 @@ func (h *Handler) FindCurrent(c *gin.Context) {
 	operatorID := c.Param("operator_id")
 	response, err := h.service.FindCurrent(ctx, operatorID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("time_entry_not_found", "Time entry not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Time entry found successfully", "data": response})
@@ -127,12 +157,16 @@ func (h *Handler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var request TimeEntryRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Update(ctx, id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("time_entry_not_found", "Time entry not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Time entry updated successfully", "data": response})
@@ -142,8 +176,41 @@ func (h *Handler) Delete(c *gin.Context) {
 	ctx := c.Request.Context()
 	id := c.Param("id")
 	if err := h.service.Delete(ctx, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("time_entry_not_found", "Time entry not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Time entry deleted successfully"})
 }
+
+func (h *Handler) Trash(c *gin.Context) {
+	ctx := c.Request.Context()
+	params, err := listing.Parse(c, trashSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.Trash(ctx, c.Query("customer_id"), params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted time entries found successfully", "data": response, "meta": params.Page(total)})
+}
+
+func (h *Handler) Restore(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.Restore(ctx, c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("time_entry_not_found", "Time entry not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Time entry restored successfully", "data": response})
+}
@@ -14,11 +14,12 @@ type TimeEntry struct {
     CheckOut     *time.Time `json:"check_out,omitempty"`
     CreatedAt    time.Time  `json:"created_at"`
     UpdatedAt    time.Time  `json:"updated_at"`
+    DeletedAt    *time.Time `json:"deleted_at,omitempty"`
 }
 
 type TimeEntryRequest struct {
-    OperatorID   string     `json:"operator_id"`
+    OperatorID   string     `json:"operator_id" binding:"omitempty,uuid"`
     WorkcenterID *string    `json:"workcenter_id,omitempty"`
-    CheckIn      time.Time  `json:"check_in"`
-    CheckOut     *time.Time `json:"check_out,omitempty"`
+    CheckIn      time.Time  `json:"check_in" binding:"required"`
+    CheckOut     *time.Time `json:"check_out,omitempty" binding:"omitempty,gtfield=CheckIn"`
 }
@@ -1,12 +1,16 @@
 package timeentries
 
 import (
+	"api/internal/listing"
+	"api/internal/outbox"
+	"api/internal/shopfloors"
+	"api/internal/trash"
 	"context"
 	"database/sql"
-	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type TimeEntryFilter struct {
@@ -16,16 +20,38 @@ type TimeEntryFilter struct {
 	EndDate    *time.Time
 }
 
+// listSpec is what the time entry list can be sorted and filtered on,
+// besides the filters of TimeEntryFilter
+var listSpec = listing.Spec{
+	Sort: map[string]string{
+		"check_in":   "check_in",
+		"check_out":  "check_out",
+		"created_at": "created_at",
+	},
+	Filter: map[string]string{
+		"workcenter_id": "workcenter_id",
+	},
+	Default: []listing.Order{{Field: "check_in", Desc: true}},
+}
+
+// trashSpec is what the trash can be sorted and filtered on
+var trashSpec = trash.Spec(listSpec)
+
 type Repository interface {
-	Create(ctx context.Context, entry TimeEntry) (TimeEntry, error)
+	Create(ctx context.Context, entry TimeEntry, events ...outbox.Event) (TimeEntry, error)
 	FindByID(ctx context.Context, id uuid.UUID) (TimeEntry, error)
-	FindByCustomerID(ctx context.Context, customerID uuid.UUID)([]TimeEntry, error)
-	FindByOperatorID(ctx context.Context, operatorID uuid.UUID)([]TimeEntry, error)
+	FindByCustomerID(ctx context.Context, customerID uuid.UUID, params listing.Params) ([]TimeEntry, int, error)
+	FindByOperatorID(ctx context.Context, operatorID uuid.UUID, params listing.Params) ([]TimeEntry, int, error)
 	FindCurrent(ctx context.Context, operatorID uuid.UUID)(TimeEntry, error)
-	FindAll(ctx context.Context) ([]TimeEntry, error)
-	Search(ctx context.Context, filter TimeEntryFilter) ([]TimeEntry, error)
-	Update(ctx context.Context, entry TimeEntry) (TimeEntry, error)
+	Search(ctx context.Context, filter TimeEntryFilter, params listing.Params) ([]TimeEntry, int, error)
+	FindOperatorCustomerID(ctx context.Context, operatorID uuid.UUID) (uuid.UUID, error)
+	FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error)
+	Update(ctx context.Context, entry TimeEntry, events ...outbox.Event) (TimeEntry, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]TimeEntry, int, error)
+	FindDeletedByID(ctx context.Context, id uuid.UUID) (TimeEntry, error)
+	FindOwnerCustomerID(ctx context.Context, operatorID uuid.UUID) (uuid.UUID, error)
+	Restore(ctx context.Context, id uuid.UUID) error
 }
 
 type repository struct {
@@ -36,25 +62,38 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
-func (r *repository) Create(ctx context.Context, entry TimeEntry) (TimeEntry, error) {
+// Create inserts the entry and writes the given outbox events in the same transaction
+func (r *repository) Create(ctx context.Context, entry TimeEntry, events ...outbox.Event) (TimeEntry, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	defer tx.Rollback()
+
 	query := `INSERT INTO time_entries (
 		id, operator_id, workcenter_id, check_in, check_out, created_at, updated_at
 	) VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = tx.ExecContext(ctx, query,
 		entry.ID, entry.OperatorID, entry.WorkcenterID,
 		entry.CheckIn, entry.CheckOut, entry.CreatedAt, entry.UpdatedAt,
 	)
 	if err != nil {
 		return TimeEntry{}, err
 	}
+	if err := outbox.Write(ctx, tx, events...); err != nil {
+		return TimeEntry{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return TimeEntry{}, err
+	}
 	return entry, nil
 }
 
 func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (TimeEntry, error) {
 	query := `SELECT 
 		id, operator_id, workcenter_id, check_in, check_out, created_at, updated_at
-	FROM time_entries WHERE id = $1`
+	FROM time_entries WHERE id = $1 AND deleted_at IS NULL`
 
 	row := r.db.QueryRowContext(ctx, query, id)
 	var entry TimeEntry
@@ -68,141 +107,54 @@ func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (TimeEntry, err
 	return entry, nil
 }
 
-func (r *repository) FindAll(ctx context.Context) ([]TimeEntry, error) {
-	query := `SELECT 
-		id, operator_id, workcenter_id, check_in, check_out, created_at, updated_at
-	FROM time_entries`
-
-	rows, err := r.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var entries []TimeEntry
-	for rows.Next() {
-		var entry TimeEntry
-		err := rows.Scan(
-			&entry.ID, &entry.OperatorID, &entry.WorkcenterID,
-			&entry.CheckIn, &entry.CheckOut, &entry.CreatedAt, &entry.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		entries = append(entries, entry)
-	}
-	return entries, nil
-}
-
-func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]TimeEntry, error) {
-	query := `SELECT 
-		id, operator_id, workcenter_id, check_in, check_out, created_at, updated_at
-	FROM time_entries WHERE customer_id = $1`
-
-	rows, err := r.db.QueryContext(ctx, query, customerID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var entries []TimeEntry
-	for rows.Next() {
-		var entry TimeEntry
-		err := rows.Scan(
-			&entry.ID, &entry.OperatorID, &entry.WorkcenterID,
-			&entry.CheckIn, &entry.CheckOut, &entry.CreatedAt, &entry.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		entries = append(entries, entry)
-	}
-	return entries, nil
+// FindByCustomerID returns a page of the entries of the operators of the customer
+func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID, params listing.Params) ([]TimeEntry, int, error) {
+	return r.Search(ctx, TimeEntryFilter{CustomerID: &customerID}, params)
 }
 
-func (r *repository) Search(ctx context.Context, filter TimeEntryFilter) ([]TimeEntry, error) {
-	query := `SELECT 
-		id, operator_id, workcenter_id, check_in, check_out, created_at, updated_at
-	FROM time_entries WHERE 1=1`
-
-	var args []interface{}
-	argId := 1
-
+// Search returns a page of the entries matching the filter
+func (r *repository) Search(ctx context.Context, filter TimeEntryFilter, params listing.Params) ([]TimeEntry, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	// Time entries belong to the tenant of their operator
 	if filter.CustomerID != nil {
-		query += fmt.Sprintf(" AND customer_id = $%d", argId)
-		args = append(args, *filter.CustomerID)
-		argId++
+		q.Where("operator_id IN (SELECT id FROM operators WHERE customer_id = ?)", *filter.CustomerID)
 	}
 	if filter.OperatorID != nil {
-		query += fmt.Sprintf(" AND operator_id = $%d", argId)
-		args = append(args, *filter.OperatorID)
-		argId++
+		q.Where("operator_id = ?", *filter.OperatorID)
 	}
 	// check_in range
 	if filter.StartDate != nil {
-		query += fmt.Sprintf(" AND check_in >= $%d", argId)
-		args = append(args, *filter.StartDate)
-		argId++
+		q.Where("check_in >= ?", *filter.StartDate)
 	}
 	if filter.EndDate != nil {
-		query += fmt.Sprintf(" AND check_in <= $%d", argId)
-		args = append(args, *filter.EndDate)
-		argId++
+		q.Where("check_in <= ?", *filter.EndDate)
 	}
-
-	query += " ORDER BY check_in DESC"
-
-	rows, err := r.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
+	if ids, ok := shopfloors.Allowed(ctx); ok {
+		q.Where("operator_id IN (SELECT id FROM operators WHERE shop_floor_id = ANY(?::uuid[]))", pq.Array(ids))
 	}
-	defer rows.Close()
 
-	var entries []TimeEntry
-	for rows.Next() {
+	return listing.Find(ctx, r.db, q, `SELECT 
+		id, operator_id, workcenter_id, check_in, check_out, created_at, updated_at
+	FROM time_entries`, func(rows *sql.Rows) (TimeEntry, error) {
 		var entry TimeEntry
 		err := rows.Scan(
 			&entry.ID, &entry.OperatorID, &entry.WorkcenterID,
 			&entry.CheckIn, &entry.CheckOut, &entry.CreatedAt, &entry.UpdatedAt,
 		)
-		if err != nil {
-			return nil, err
-		}
-		entries = append(entries, entry)
-	}
-	return entries, nil
+		return entry, err
+	})
 }
 
-func (r *repository) FindByOperatorID(ctx context.Context, operatorID uuid.UUID) ([]TimeEntry, error) {
-	query := `SELECT 
-		id, operator_id, workcenter_id, check_in, check_out, created_at, updated_at
-	FROM time_entries WHERE operator_id = $1`
-
-	rows, err := r.db.QueryContext(ctx, query, operatorID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var entries []TimeEntry
-	for rows.Next() {
-		var entry TimeEntry
-		err := rows.Scan(
-			&entry.ID, &entry.OperatorID, &entry.WorkcenterID,
-			&entry.CheckIn, &entry.CheckOut, &entry.CreatedAt, &entry.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		entries = append(entries, entry)
-	}
-	return entries, nil
+// FindByOperatorID returns a page of the entries of the operator
+func (r *repository) FindByOperatorID(ctx context.Context, operatorID uuid.UUID, params listing.Params) ([]TimeEntry, int, error) {
+	return r.Search(ctx, TimeEntryFilter{OperatorID: &operatorID}, params)
 }
 
 func (r *repository) FindCurrent(ctx context.Context, operatorID uuid.UUID) (TimeEntry, error) {
 	query := `SELECT 
 		id, operator_id, workcenter_id, check_in, check_out, created_at, updated_at
-	FROM time_entries WHERE operator_id = $1 AND check_out IS NULL`
+	FROM time_entries WHERE operator_id = $1 AND check_out IS NULL AND deleted_at IS NULL`
 
 	row := r.db.QueryRowContext(ctx, query, operatorID)
 	var entry TimeEntry
@@ -216,23 +168,120 @@ func (r *repository) FindCurrent(ctx context.Context, operatorID uuid.UUID) (Tim
 	return entry, nil
 }
 
-func (r *repository) Update(ctx context.Context, entry TimeEntry) (TimeEntry, error) {
+func (r *repository) FindOperatorCustomerID(ctx context.Context, operatorID uuid.UUID) (uuid.UUID, error) {
+	query := `SELECT customer_id FROM operators WHERE id = $1 AND deleted_at IS NULL`
+	var customerID uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, operatorID).Scan(&customerID); err != nil {
+		return uuid.Nil, err
+	}
+	return customerID, nil
+}
+
+func (r *repository) FindWorkcenterCustomerID(ctx context.Context, workcenterID uuid.UUID) (uuid.UUID, error) {
+	query := `SELECT customer_id FROM workcenters WHERE id = $1 AND deleted_at IS NULL`
+	var customerID uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, workcenterID).Scan(&customerID); err != nil {
+		return uuid.Nil, err
+	}
+	return customerID, nil
+}
+
+// Update saves the entry and writes the given outbox events in the same transaction
+func (r *repository) Update(ctx context.Context, entry TimeEntry, events ...outbox.Event) (TimeEntry, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	defer tx.Rollback()
+
 	query := `UPDATE time_entries SET 
 		operator_id = $2, workcenter_id = $3, check_in = $4, check_out = $5, updated_at = $6
 	WHERE id = $1`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = tx.ExecContext(ctx, query,
 		entry.ID, entry.OperatorID, entry.WorkcenterID,
 		entry.CheckIn, entry.CheckOut, entry.UpdatedAt,
 	)
 	if err != nil {
 		return TimeEntry{}, err
 	}
+	if err := outbox.Write(ctx, tx, events...); err != nil {
+		return TimeEntry{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return TimeEntry{}, err
+	}
 	return entry, nil
 }
 
+// Delete moves the entry to the trash
 func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM time_entries WHERE id = $1`
+	query := `UPDATE time_entries SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
 	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
+
+// FindDeleted returns a page of the entries in the trash of the operators
+// of the tenant, or of every tenant when customerID is nil
+func (r *repository) FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]TimeEntry, int, error) {
+	q := listing.NewQuery(trashSpec, params)
+	q.Where("deleted_at IS NOT NULL")
+	if customerID != nil {
+		q.Where("operator_id IN (SELECT id FROM operators WHERE customer_id = ?)", *customerID)
+	}
+	return listing.Find(ctx, r.db, q, `SELECT 
+		id, operator_id, workcenter_id, check_in, check_out, created_at, updated_at, deleted_at
+	FROM time_entries`, func(rows *sql.Rows) (TimeEntry, error) {
+		var entry TimeEntry
+		err := rows.Scan(
+			&entry.ID, &entry.OperatorID, &entry.WorkcenterID,
+			&entry.CheckIn, &entry.CheckOut, &entry.CreatedAt, &entry.UpdatedAt, &entry.DeletedAt,
+		)
+		return entry, err
+	})
+}
+
+func (r *repository) FindDeletedByID(ctx context.Context, id uuid.UUID) (TimeEntry, error) {
+	query := `SELECT 
+		id, operator_id, workcenter_id, check_in, check_out, created_at, updated_at, deleted_at
+	FROM time_entries WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	var entry TimeEntry
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&entry.ID, &entry.OperatorID, &entry.WorkcenterID,
+		&entry.CheckIn, &entry.CheckOut, &entry.CreatedAt, &entry.UpdatedAt, &entry.DeletedAt,
+	)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	return entry, nil
+}
+
+// FindOwnerCustomerID returns the tenant of an operator, in the trash or not
+func (r *repository) FindOwnerCustomerID(ctx context.Context, operatorID uuid.UUID) (uuid.UUID, error) {
+	query := `SELECT customer_id FROM operators WHERE id = $1`
+	var customerID uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, operatorID).Scan(&customerID); err != nil {
+		return uuid.Nil, err
+	}
+	return customerID, nil
+}
+
+// Restore takes the entry out of the trash unless its operator or workcenter
+// is still in it
+func (r *repository) Restore(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE time_entries SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL
+	AND ` + trash.TimeEntryParentsLive
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return trash.ErrParentDeleted
+	}
+	return nil
+}
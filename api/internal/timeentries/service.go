@@ -1,7 +1,13 @@
 package timeentries
 
 import (
+	"api/internal/apperr"
+	"api/internal/audit"
+	"api/internal/listing"
+	"api/internal/outbox"
+	"api/internal/tenant"
 	"context"
+	"database/sql"
 	"errors"
 	"time"
 
@@ -11,24 +17,29 @@ import (
 type Service interface {
 	Create(ctx context.Context, request TimeEntryRequest) (TimeEntry, error)
 	FindByID(ctx context.Context, id string) (TimeEntry, error)
-	FindAll(ctx context.Context) ([]TimeEntry, error)
-	FindByCustomerID(ctx context.Context, customerID string) ([]TimeEntry, error)
-	FindByOperatorID(ctx context.Context, operatorID string) ([]TimeEntry, error)
+	FindByCustomerID(ctx context.Context, customerID string, params listing.Params) ([]TimeEntry, int, error)
+	FindByOperatorID(ctx context.Context, operatorID string, params listing.Params) ([]TimeEntry, int, error)
 	FindCurrent(ctx context.Context, operatorID string) (TimeEntry, error)
-	Search(ctx context.Context, filter TimeEntryFilter) ([]TimeEntry, error)
+	Search(ctx context.Context, filter TimeEntryFilter, params listing.Params) ([]TimeEntry, int, error)
 	Update(ctx context.Context, id string, request TimeEntryRequest) (TimeEntry, error)
 	Delete(ctx context.Context, id string) error
+	Trash(ctx context.Context, customerID string, params listing.Params) ([]TimeEntry, int, error)
+	Restore(ctx context.Context, id string) (TimeEntry, error)
 }
 
 type service struct {
-	repo Repository
+	repo  Repository
+	audit audit.Recorder
 }
 
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+func NewService(repo Repository, recorder audit.Recorder) Service {
+	return &service{repo: repo, audit: recorder}
 }
 
 func (s *service) Create(ctx context.Context, request TimeEntryRequest) (TimeEntry, error) {
+	if request.OperatorID == "" {
+		return TimeEntry{}, apperr.Field("operator_id", "required", "operator_id is required")
+	}
 	operatorID, err := uuid.Parse(request.OperatorID)
 	if err != nil {
 		return TimeEntry{}, err
@@ -36,7 +47,7 @@ func (s *service) Create(ctx context.Context, request TimeEntryRequest) (TimeEnt
 	
 	var workcenterID *uuid.UUID
 	if request.WorkcenterID != nil && *request.WorkcenterID != "" {
-		id, err := uuid.Parse(*request.WorkcenterID)
+		id, err := parseWorkcenterID(*request.WorkcenterID)
 		if err != nil {
 			return TimeEntry{}, err
 		}
@@ -53,53 +64,107 @@ func (s *service) Create(ctx context.Context, request TimeEntryRequest) (TimeEnt
 		UpdatedAt:    time.Now(),
 	}
 
-	_, err = s.repo.Create(ctx, entry)
+	customerID, err := s.ownedOperator(ctx, operatorID)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	if err := s.checkWorkcenter(ctx, entry.WorkcenterID, customerID); err != nil {
+		return TimeEntry{}, err
+	}
+	events := []outbox.Event{outbox.NewEvent(customerID, outbox.EventTimeEntryOpened, entry)}
+	if entry.CheckOut != nil {
+		events = append(events, outbox.NewEvent(customerID, outbox.EventTimeEntryClosed, entry))
+	}
+
+	_, err = s.repo.Create(ctx, entry, events...)
 	if err != nil {
 		return TimeEntry{}, err
 	}
+	s.audit.Created(ctx, audit.EntityTimeEntry, entry.ID, customerID, entry)
 	return entry, nil
 }
 
 func (s *service) FindByID(ctx context.Context, id string) (TimeEntry, error) {
+	entry, _, err := s.findOwned(ctx, id)
+	return entry, err
+}
+
+// findOwned returns the entry and its tenant if the current user can access it
+func (s *service) findOwned(ctx context.Context, id string) (TimeEntry, uuid.UUID, error) {
 	parsedID, err := uuid.Parse(id)
 	if err != nil {
-		return TimeEntry{}, err
+		return TimeEntry{}, uuid.Nil, err
 	}
-	return s.repo.FindByID(ctx, parsedID)
+	entry, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return TimeEntry{}, uuid.Nil, err
+	}
+	customerID, err := s.ownedOperator(ctx, entry.OperatorID)
+	if err != nil {
+		return TimeEntry{}, uuid.Nil, err
+	}
+	return entry, customerID, nil
 }
 
-func (s *service) FindAll(ctx context.Context) ([]TimeEntry, error) {
-	isAdminVal := ctx.Value("is_admin")
-	isAdmin, ok := isAdminVal.(bool)
-	if !ok {
-		return nil, errors.New("invalid or missing is_admin in context")
+// ownedOperator returns the tenant of an operator the current user can
+// access. Time entries belong to the tenant of their operator.
+func (s *service) ownedOperator(ctx context.Context, operatorID uuid.UUID) (uuid.UUID, error) {
+	customerID, err := s.repo.FindOperatorCustomerID(ctx, operatorID)
+	if err != nil {
+		return uuid.Nil, err
 	}
-	if isAdmin{
-		return s.repo.FindAll(ctx)
+	if err := tenant.Check(ctx, customerID); err != nil {
+		return uuid.Nil, err
 	}
-	customerIDVal := ctx.Value("customer_id")
-		customerIDFromCtx, ok := customerIDVal.(uuid.UUID)
-		if !ok {
-			return nil, errors.New("invalid or missing customer_id in context")
-		}
-		customerID := customerIDFromCtx
-	return s.repo.FindByCustomerID(ctx, customerID)
+	return customerID, nil
+}
+
+// parseWorkcenterID parses the workcenter of a request. It isn't a uuid
+// binding rule because an empty workcenter_id clears the workcenter.
+func parseWorkcenterID(value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, apperr.Field("workcenter_id", "invalid_format", "workcenter_id must be a UUID")
+	}
+	return id, nil
+}
+
+// checkWorkcenter checks that the workcenter of an entry belongs to the
+// tenant of its operator
+func (s *service) checkWorkcenter(ctx context.Context, workcenterID *uuid.UUID, customerID uuid.UUID) error {
+	if workcenterID == nil {
+		return nil
+	}
+	owner, err := s.repo.FindWorkcenterCustomerID(ctx, *workcenterID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperr.Field("workcenter_id", "unknown_workcenter", "workcenter not found")
+	}
+	if err != nil {
+		return err
+	}
+	if owner != customerID {
+		return apperr.Field("workcenter_id", "foreign_workcenter", "workcenter belongs to another customer")
+	}
+	return nil
 }
 
-func(s *service) FindByCustomerID(ctx context.Context, customerID string) ([]TimeEntry, error) {
-	parsedCustomerID, err := uuid.Parse(customerID)
+func(s *service) FindByCustomerID(ctx context.Context, customerID string, params listing.Params) ([]TimeEntry, int, error) {
+	parsedCustomerID, err := tenant.Customer(ctx, customerID)
 	if err != nil {
-		return []TimeEntry{}, err
+		return nil, 0, err
 	}
-	return s.repo.FindByCustomerID(ctx, parsedCustomerID)
+	return s.repo.FindByCustomerID(ctx, parsedCustomerID, params)
 }
 
-func(s *service) FindByOperatorID(ctx context.Context, operatorID string) ([]TimeEntry, error) {
+func(s *service) FindByOperatorID(ctx context.Context, operatorID string, params listing.Params) ([]TimeEntry, int, error) {
 	parsedOperatorID, err := uuid.Parse(operatorID)
 	if err != nil {
-		return []TimeEntry{}, err
+		return nil, 0, err
 	}
-	return s.repo.FindByOperatorID(ctx, parsedOperatorID)
+	if _, err := s.ownedOperator(ctx, parsedOperatorID); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.FindByOperatorID(ctx, parsedOperatorID, params)
 }
 
 func(s *service) FindCurrent(ctx context.Context, operatorID string) (TimeEntry, error) {
@@ -107,70 +172,127 @@ func(s *service) FindCurrent(ctx context.Context, operatorID string) (TimeEntry,
 	if err != nil {
 		return TimeEntry{}, err
 	}
+	if _, err := s.ownedOperator(ctx, parsedOperatorID); err != nil {
+		return TimeEntry{}, err
+	}
 	return s.repo.FindCurrent(ctx, parsedOperatorID)
 }
 
-func (s *service) Search(ctx context.Context, filter TimeEntryFilter) ([]TimeEntry, error) {
+func (s *service) Search(ctx context.Context, filter TimeEntryFilter, params listing.Params) ([]TimeEntry, int, error) {
 	isAdminVal := ctx.Value("is_admin")
 	isAdmin, ok := isAdminVal.(bool)
 	if !ok {
-		return nil, errors.New("invalid or missing is_admin in context")
+		return nil, 0, errors.New("invalid or missing is_admin in context")
 	}
 
 	if !isAdmin {
 		customerIDVal := ctx.Value("customer_id")
 		customerIDFromCtx, ok := customerIDVal.(uuid.UUID)
 		if !ok {
-			return nil, errors.New("invalid or missing customer_id in context")
+			return nil, 0, errors.New("invalid or missing customer_id in context")
 		}
 		filter.CustomerID = &customerIDFromCtx
 	}
-	return s.repo.Search(ctx, filter)
+	return s.repo.Search(ctx, filter, params)
 }
 
 func (s *service) Update(ctx context.Context, id string, request TimeEntryRequest) (TimeEntry, error) {
-	parsedID, err := uuid.Parse(id)
-	if err != nil {
-		return TimeEntry{}, err
-	}
-
-	entry, err := s.repo.FindByID(ctx, parsedID)
+	entry, customerID, err := s.findOwned(ctx, id)
 	if err != nil {
 		return TimeEntry{}, err
 	}
+	before := entry
+	wasOpen := entry.CheckOut == nil
 
 	// Update fields
 	if request.OperatorID != "" {
 		if operatorID, err := uuid.Parse(request.OperatorID); err == nil {
+			operatorCustomerID, err := s.ownedOperator(ctx, operatorID)
+			if err != nil {
+				return TimeEntry{}, err
+			}
 			entry.OperatorID = operatorID
+			customerID = operatorCustomerID
 		}
 	}
 	
 	if request.WorkcenterID != nil {
 		if *request.WorkcenterID != "" {
-			if workcenterID, err := uuid.Parse(*request.WorkcenterID); err == nil {
-				entry.WorkcenterID = &workcenterID
+			workcenterID, err := parseWorkcenterID(*request.WorkcenterID)
+			if err != nil {
+				return TimeEntry{}, err
 			}
+			entry.WorkcenterID = &workcenterID
 		} else {
 			entry.WorkcenterID = nil
 		}
 	}
 
+	if err := s.checkWorkcenter(ctx, entry.WorkcenterID, customerID); err != nil {
+		return TimeEntry{}, err
+	}
+
 	entry.CheckIn = request.CheckIn
 	entry.CheckOut = request.CheckOut
 	entry.UpdatedAt = time.Now()
 
-	_, err = s.repo.Update(ctx, entry)
+	var events []outbox.Event
+	if wasOpen && entry.CheckOut != nil {
+		events = append(events, outbox.NewEvent(customerID, outbox.EventTimeEntryClosed, entry))
+	}
+
+	_, err = s.repo.Update(ctx, entry, events...)
 	if err != nil {
 		return TimeEntry{}, err
 	}
+	s.audit.Updated(ctx, audit.EntityTimeEntry, entry.ID, customerID, before, entry)
 	return entry, nil
 }
 
+// Delete moves the entry to the trash
 func (s *service) Delete(ctx context.Context, id string) error {
-	parsedID, err := uuid.Parse(id)
+	entry, customerID, err := s.findOwned(ctx, id)
 	if err != nil {
 		return err
 	}
-	return s.repo.Delete(ctx, parsedID)
+	if err := s.repo.Delete(ctx, entry.ID); err != nil {
+		return err
+	}
+	s.audit.Deleted(ctx, audit.EntityTimeEntry, entry.ID, customerID, entry)
+	return nil
+}
+
+// Trash returns the deleted entries of the operators of the tenant
+func (s *service) Trash(ctx context.Context, customerID string, params listing.Params) ([]TimeEntry, int, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repo.FindDeleted(ctx, scope, params)
+}
+
+// Restore takes the entry out of the trash. The tenant is the one of its
+// operator, which may be in the trash too.
+func (s *service) Restore(ctx context.Context, id string) (TimeEntry, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	entry, err := s.repo.FindDeletedByID(ctx, parsedID)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	customerID, err := s.repo.FindOwnerCustomerID(ctx, entry.OperatorID)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	if err := tenant.Check(ctx, customerID); err != nil {
+		return TimeEntry{}, err
+	}
+	if err := s.repo.Restore(ctx, entry.ID); err != nil {
+		return TimeEntry{}, err
+	}
+	entry.DeletedAt = nil
+	s.audit.Restored(ctx, audit.EntityTimeEntry, entry.ID, customerID, entry)
+	return entry, nil
 }
@@ -0,0 +1,127 @@
+// Package trash holds what soft deleted records have in common: the preview
+// of the records that depend on the one being deleted, the confirmation
+// needed to delete it anyway, and the cascade that moves those records to and
+// out of the trash along with it.
+package trash
+
+import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrParentDeleted is returned when restoring a record whose parent is still in the trash
+var ErrParentDeleted = apperr.Conflict("parent_deleted", "the record belongs to a deleted record, restore that one first")
+
+// Dependents counts the records that depend on another one, by kind
+type Dependents map[string]int
+
+// Total is the number of dependent records of every kind
+func (d Dependents) Total() int {
+	total := 0
+	for _, count := range d {
+		total += count
+	}
+	return total
+}
+
+// Preview describes what deleting a record does to the records that depend
+// on it
+type Preview struct {
+	// Deleted counts the records moved to the trash along with it
+	Deleted Dependents
+	// Kept counts the history that has no trash, such as downtimes and
+	// production counts. It stays as it is and shows again once the record
+	// is restored.
+	Kept Dependents
+}
+
+// Total is the number of records the deletion affects
+func (p Preview) Total() int {
+	return p.Deleted.Total() + p.Kept.Total()
+}
+
+// ErrUnconfirmed is returned when deleting a record with dependents without
+// confirming it. The response lists the dependents moved to the trash and
+// the history kept.
+var ErrUnconfirmed = apperr.Conflict("unconfirmed_delete", "the record has dependents, confirm to delete it")
+
+// Confirm returns ErrUnconfirmed with the preview when the deletion affects
+// other records and wasn't confirmed
+func Confirm(preview Preview, confirmed bool) error {
+	if confirmed || preview.Total() == 0 {
+		return nil
+	}
+	kept := preview.Kept
+	if kept == nil {
+		kept = Dependents{}
+	}
+	return ErrUnconfirmed.With("dependents", preview.Deleted).With("kept", kept)
+}
+
+// Confirmed tells whether the confirm query param of a request, ?confirm=true,
+// confirms the deletion
+func Confirmed(confirm string) bool {
+	confirmed, _ := strconv.ParseBool(confirm)
+	return confirmed
+}
+
+// Spec returns the spec of the trash of a list: its sorts, filters and
+// search, deleted_at to sort on too, and the most recently deleted first
+func Spec(list listing.Spec) listing.Spec {
+	sort := map[string]string{"deleted_at": "deleted_at"}
+	for field, expression := range list.Sort {
+		sort[field] = expression
+	}
+	list.Sort = sort
+	list.Default = []listing.Order{{Field: "deleted_at", Desc: true}}
+	return list
+}
+
+// Step moves the records of a kind that depend on another record to or out
+// of the trash. Its query gets the ID of that record as $1 and the time it
+// was deleted at as $2.
+type Step struct {
+	Kind  string
+	Query string
+}
+
+// Cascade runs the steps in order within tx and counts the records they
+// moved, by kind. The records deleted along with another one share its
+// deleted_at, which is how restoring it finds them again.
+func Cascade(ctx context.Context, tx *sql.Tx, steps []Step, id uuid.UUID, deletedAt time.Time) (Dependents, error) {
+	moved := Dependents{}
+	for _, step := range steps {
+		result, err := tx.ExecContext(ctx, step.Query, id, deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		moved[step.Kind] += int(affected)
+	}
+	return moved, nil
+}
+
+// The conditions below keep the records whose parents are all out of the
+// trash, so a restore never brings back a record pointing to a deleted one.
+const (
+	JobParentsLive = `NOT EXISTS (SELECT 1 FROM shopfloors WHERE id = jobs.shop_floor_id AND deleted_at IS NOT NULL)
+	AND NOT EXISTS (SELECT 1 FROM workcenters WHERE id = jobs.workcenter_id AND deleted_at IS NOT NULL)`
+
+	ScheduleEntryParentsLive = `NOT EXISTS (SELECT 1 FROM shopfloors WHERE id = schedule_entries.shopfloor_id AND deleted_at IS NOT NULL)
+	AND NOT EXISTS (SELECT 1 FROM shifts WHERE id = schedule_entries.shift_id AND deleted_at IS NOT NULL)
+	AND NOT EXISTS (SELECT 1 FROM workcenters WHERE id = schedule_entries.workcenter_id AND deleted_at IS NOT NULL)
+	AND NOT EXISTS (SELECT 1 FROM jobs WHERE id = schedule_entries.job_id AND deleted_at IS NOT NULL)
+	AND NOT EXISTS (SELECT 1 FROM operators WHERE id = schedule_entries.operator_id AND deleted_at IS NOT NULL)`
+
+	TimeEntryParentsLive = `NOT EXISTS (SELECT 1 FROM operators WHERE id = time_entries.operator_id AND deleted_at IS NOT NULL)
+	AND NOT EXISTS (SELECT 1 FROM workcenters WHERE id = time_entries.workcenter_id AND deleted_at IS NOT NULL)`
+)
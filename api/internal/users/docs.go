@@ -0,0 +1,23 @@
+package users
+
+import (
+	"api/internal/openapi"
+
+	"github.com/google/uuid"
+)
+
+// Docs documents the routes of RegisterRoutes and RegisterAdminRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/users", Tag: "users", Summary: "Create a user", Request: UserRequest{}, Response: User{}, Status: 201},
+	{Method: "GET", Path: "/api/users", Tag: "users", Summary: "List users", Response: []User{}, List: &listSpec},
+	{Method: "GET", Path: "/api/users/trash", Tag: "users", Summary: "List deleted users", Response: []User{}, List: &trashSpec, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "GET", Path: "/api/users/:id", Tag: "users", Summary: "Get a user", Response: User{}},
+	{Method: "GET", Path: "/api/users/customer/:customer_id", Tag: "users", Summary: "List the users of a customer", Response: []User{}, List: &listSpec},
+	{Method: "PUT", Path: "/api/users/:id", Tag: "users", Summary: "Update a user", Request: UserRequest{}, Response: User{}},
+	{Method: "DELETE", Path: "/api/users/:id", Tag: "users", Summary: "Move a user to the trash and end their sessions"},
+	{Method: "POST", Path: "/api/users/:id/restore", Tag: "users", Summary: "Restore a deleted user", Response: User{}},
+	{Method: "GET", Path: "/api/users/:id/shopfloors", Tag: "users", Summary: "Shop floors the user is restricted to, none when unrestricted", Response: []uuid.UUID{}},
+	{Method: "PUT", Path: "/api/users/:id/shopfloors", Tag: "users", Summary: "Restrict a user to shop floors", Request: ShopfloorsRequest{}, Response: []uuid.UUID{}},
+
+	{Method: "POST", Path: "/auth/users/admin", Tag: "users", Summary: "Create the system admin, with the password in ADMIN_PASSWORD", Public: true, Status: 201},
+}
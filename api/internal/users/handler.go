@@ -1,6 +1,9 @@
 package users
 
 import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -18,13 +21,13 @@ func (h *Handler) Create(c *gin.Context) {
 	ctx := c.Request.Context()
 	var request UserRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 
 	response, err := h.service.Create(ctx, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully", "data": response})
@@ -34,7 +37,7 @@ func (h *Handler) CreateAdmin(c *gin.Context) {
 	ctx := c.Request.Context()
 	err := h.service.CreateAdmin(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"message": "Admin created successfully"})
@@ -43,18 +46,17 @@ func (h *Handler) CreateAdmin(c *gin.Context) {
 func (h *Handler) FindAll(c *gin.Context) {
 	ctx := c.Request.Context()
 	
-	var response []User
-	var err error
-
-	
-		response, err = h.service.FindAll(ctx)
-	
-
+	params, err := listing.Parse(c, listSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.List(ctx, params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Users found successfully", "data": response})
+	c.JSON(http.StatusOK, gin.H{"message": "Users found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindByID(c *gin.Context) {
@@ -62,11 +64,11 @@ func (h *Handler) FindByID(c *gin.Context) {
 	id := c.Param("id")
 	response, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("user_not_found", "User not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "User found successfully", "data": response})
@@ -75,16 +77,21 @@ func (h *Handler) FindByID(c *gin.Context) {
 func (h *Handler) FindByCustomerID(c *gin.Context) {
 	ctx := c.Request.Context()
 	customerID := c.Param("customer_id")
-	response, err := h.service.FindByCustomerID(ctx, customerID)
-	if err != nil && err.Error() != "sql: no rows in result set" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	params, err := listing.Parse(c, listSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.FindByCustomerID(ctx, customerID, params)
+	if err != nil && !tenant.IsNotFound(err) {
+		c.Error(err)
 		return
 	}
 	// If empty list, we return empty list, not error.
 	if response == nil {
 		response = []User{}
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Users found successfully", "data": response})
+	c.JSON(http.StatusOK, gin.H{"message": "Users found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) Update(c *gin.Context) {
@@ -92,12 +99,16 @@ func (h *Handler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var request UserRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Update(ctx, id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("user_not_found", "User not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "data": response})
@@ -108,8 +119,76 @@ func (h *Handler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	err := h.service.Delete(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("user_not_found", "User not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
+
+func (h *Handler) Trash(c *gin.Context) {
+	ctx := c.Request.Context()
+	params, err := listing.Parse(c, trashSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.Trash(ctx, c.Query("customer_id"), params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted users found successfully", "data": response, "meta": params.Page(total)})
+}
+
+func (h *Handler) Restore(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.Restore(ctx, c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("user_not_found", "User not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User restored successfully", "data": response})
+}
+
+func (h *Handler) FindShopfloors(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	response, err := h.service.FindShopfloors(ctx, id)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("user_not_found", "User not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User shop floors found successfully", "data": response})
+}
+
+func (h *Handler) SetShopfloors(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	var request ShopfloorsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.SetShopfloors(ctx, id, request)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("user_not_found", "User not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User shop floors updated successfully", "data": response})
+}
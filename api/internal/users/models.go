@@ -13,15 +13,25 @@ type User struct {
 	Email      string    `json:"email"`
 	Password   string    `json:"password"`	
 	IsAdmin    bool      `json:"is_admin"`
+	Role       string    `json:"role"`
 	IsActive   bool      `json:"is_active"`
+	// EmailVerifiedAt is nil until the user confirms their email address
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
 }
 
 type UserRequest struct {
-	CustomerID string `json:"customer_id"`		
-	Username   string `json:"username"`
-	Email      string `json:"email"`
-	Password   string `json:"password"`	
+	CustomerID string `json:"customer_id" binding:"omitempty,uuid"`		
+	Username   string `json:"username" binding:"required,max=100"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"omitempty,min=8"`	
+	Role       string `json:"role"`
 	IsActive   bool   `json:"is_active"`
-}
\ No newline at end of file
+}
+// ShopfloorsRequest restricts a user to the given shopfloors and everything
+// below them. An empty list lifts the restriction.
+type ShopfloorsRequest struct {
+	ShopfloorIDs []string `json:"shopfloor_ids" binding:"dive,uuid"`
+}
@@ -1,21 +1,53 @@
 package users
 
 import (
+	"api/internal/listing"
+	"api/internal/trash"
 	"context"
 	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// listSpec is what the user list can be sorted, filtered and searched on
+var listSpec = listing.Spec{
+	Sort: map[string]string{
+		"username":   "username",
+		"email":      "email",
+		"role":       "role",
+		"is_active":  "is_active",
+		"created_at": "created_at",
+	},
+	Filter: map[string]string{
+		"customer_id": "customer_id",
+		"role":        "role",
+		"is_active":   "is_active",
+		"is_admin":    "is_admin",
+	},
+	Search:  []string{"username", "email"},
+	Default: []listing.Order{{Field: "username"}},
+}
+
+// trashSpec is what the trash can be sorted and filtered on
+var trashSpec = trash.Spec(listSpec)
+
 type Repository interface {
 	Create(ctx context.Context, user User) (User, error)
-	FindAll(ctx context.Context) ([]User, error)
+	List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]User, int, error)
 	FindByID(ctx context.Context, id uuid.UUID) (User, error)
-	FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]User, error)
 	CountByCustomerID(ctx context.Context, customerID uuid.UUID) (int, error)
 	FindByEmail(ctx context.Context, email string) (User, error)
 	Update(ctx context.Context, user User) (User, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]User, int, error)
+	FindDeletedByID(ctx context.Context, id uuid.UUID) (User, error)
+	Restore(ctx context.Context, id uuid.UUID, role string) error
+	FindShopfloors(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	FindAllowedShopfloors(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	CountTenantShopfloors(ctx context.Context, customerID uuid.UUID, shopfloorIDs []uuid.UUID) (int, error)
+	ReplaceShopfloors(ctx context.Context, userID uuid.UUID, shopfloorIDs []uuid.UUID) error
+	RevokeSessions(ctx context.Context, userID uuid.UUID) error
 }
 
 type repository struct {
@@ -28,67 +60,44 @@ func NewRepository(db *sql.DB) Repository {
 
 func (r *repository) Create(ctx context.Context, user User) (User, error) {
 	query := `INSERT INTO users (id, username, email, password, customer_id, 
-						is_admin, is_active, created_at, updated_at) 
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+						is_admin, role, is_active, email_verified_at, created_at, updated_at) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
 	_, err := r.db.ExecContext(ctx, query, user.ID, user.Username, user.Email, user.Password, user.CustomerID, 
-		user.IsAdmin, user.IsActive, user.CreatedAt, user.UpdatedAt)
+		user.IsAdmin, user.Role, user.IsActive, user.EmailVerifiedAt, user.CreatedAt, user.UpdatedAt)
 	if err != nil {
 		return User{}, err
 	}
 	return user, nil
 }
 
-func (r *repository) FindAll(ctx context.Context) ([]User, error) {
-	query := `SELECT id, username, email, password, customer_id, is_admin, is_active, created_at, updated_at
-				FROM users`
-	rows, err := r.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
+// List returns a page of the users of customerID, or of every customer when nil
+func (r *repository) List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]User, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
 	}
-	defer rows.Close()
-	var users []User
-	for rows.Next() {
+	return listing.Find(ctx, r.db, q, `SELECT id, username, email, password, customer_id, is_admin, role, is_active, email_verified_at, created_at, updated_at
+				FROM users`, func(rows *sql.Rows) (User, error) {
 		var user User
-		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CustomerID, &user.IsAdmin, &user.IsActive, &user.CreatedAt, &user.UpdatedAt); err != nil {
-			return nil, err
-		}
-		users = append(users, user)
-	}
-	return users, nil
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CustomerID, &user.IsAdmin, &user.Role, &user.IsActive, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
+		return user, err
+	})
 }
 
 func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (User, error) {
-	query := `SELECT id, username, email, password, customer_id, is_admin, is_active, created_at, updated_at 
-				FROM users WHERE id = $1`
+	query := `SELECT id, username, email, password, customer_id, is_admin, role, is_active, email_verified_at, created_at, updated_at 
+				FROM users WHERE id = $1 AND deleted_at IS NULL`
 	row := r.db.QueryRowContext(ctx, query, id)
 	var user User
-	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CustomerID, &user.IsAdmin, &user.IsActive, &user.CreatedAt, &user.UpdatedAt); err != nil {
+	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CustomerID, &user.IsAdmin, &user.Role, &user.IsActive, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt); err != nil {
 		return User{}, err
 	}
 	return user, nil
 }
 
-func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]User, error) {
-	query := `SELECT id, username, email, password, customer_id, is_admin, is_active, created_at, updated_at
-				FROM users WHERE customer_id = $1`
-	rows, err := r.db.QueryContext(ctx, query, customerID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var users []User
-	for rows.Next() {
-		var user User
-		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CustomerID, &user.IsAdmin, &user.IsActive, &user.CreatedAt, &user.UpdatedAt); err != nil {
-			return nil, err
-		}
-		users = append(users, user)
-	}
-	return users, nil
-}
-
 func (r *repository) CountByCustomerID(ctx context.Context, customerID uuid.UUID) (int, error) {
-	query := `SELECT COUNT(*) FROM users WHERE customer_id = $1`
+	query := `SELECT COUNT(*) FROM users WHERE customer_id = $1 AND deleted_at IS NULL`
 	var count int
 	err := r.db.QueryRowContext(ctx, query, customerID).Scan(&count)
 	if err != nil {
@@ -98,30 +107,154 @@ func (r *repository) CountByCustomerID(ctx context.Context, customerID uuid.UUID
 }
 
 func (r *repository) FindByEmail(ctx context.Context, email string) (User, error) {
-	query := `SELECT id, username, email, password, customer_id, is_admin, is_active, created_at, updated_at
-				FROM users WHERE email = $1`
+	query := `SELECT id, username, email, password, customer_id, is_admin, role, is_active, email_verified_at, created_at, updated_at
+				FROM users WHERE email = $1 AND deleted_at IS NULL`
 	row := r.db.QueryRowContext(ctx, query, email)
 	var user User
-	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CustomerID, &user.IsAdmin, &user.IsActive, &user.CreatedAt, &user.UpdatedAt); err != nil {
+	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CustomerID, &user.IsAdmin, &user.Role, &user.IsActive, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt); err != nil {
 		return User{}, err
 	}
 	return user, nil
 }
 
 func (r *repository) Update(ctx context.Context, user User) (User, error) {
-	query := `UPDATE users SET username = $2, email = $3, password = $4, customer_id = $5, is_admin = $6, is_active = $7, updated_at = $8 WHERE id = $1 RETURNING id`
-	_, err := r.db.ExecContext(ctx, query, user.ID, user.Username, user.Email, user.Password, user.CustomerID, user.IsAdmin, user.IsActive, user.UpdatedAt)
+	query := `UPDATE users SET username = $2, email = $3, password = $4, customer_id = $5, is_admin = $6, role = $7, is_active = $8, updated_at = $9 WHERE id = $1 RETURNING id`
+	_, err := r.db.ExecContext(ctx, query, user.ID, user.Username, user.Email, user.Password, user.CustomerID, user.IsAdmin, user.Role, user.IsActive, user.UpdatedAt)
 	if err != nil {
 		return User{}, err
 	}
 	return user, nil
 }
 
+// Delete moves the user to the trash and ends their sessions
 func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindDeleted returns a page of the users in the trash of the tenant, or of
+// every tenant when customerID is nil
+func (r *repository) FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]User, int, error) {
+	q := listing.NewQuery(trashSpec, params)
+	q.Where("deleted_at IS NOT NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return listing.Find(ctx, r.db, q, `SELECT id, username, email, password, customer_id, is_admin, role, is_active, email_verified_at, created_at, updated_at, deleted_at FROM users`, func(rows *sql.Rows) (User, error) {
+		var user User
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CustomerID, &user.IsAdmin, &user.Role, &user.IsActive, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt)
+		return user, err
+	})
+}
+
+func (r *repository) FindDeletedByID(ctx context.Context, id uuid.UUID) (User, error) {
+	query := `SELECT id, username, email, password, customer_id, is_admin, role, is_active, email_verified_at, created_at, updated_at, deleted_at
+				FROM users WHERE id = $1 AND deleted_at IS NOT NULL`
+	var user User
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CustomerID, &user.IsAdmin, &user.Role, &user.IsActive, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt)
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// Restore takes the user out of the trash with the given role. It fails with
+// a unique violation when another user took their email in the meantime.
+func (r *repository) Restore(ctx context.Context, id uuid.UUID, role string) error {
+	query := `UPDATE users SET deleted_at = NULL, role = $2 WHERE id = $1 AND deleted_at IS NOT NULL`
+	_, err := r.db.ExecContext(ctx, query, id, role)
+	return err
+}
+
+// FindShopfloors returns the shopfloors assigned to the user
+func (r *repository) FindShopfloors(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	query := `SELECT shopfloor_id FROM user_shopfloors WHERE user_id = $1 ORDER BY created_at`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// FindAllowedShopfloors returns the shopfloors assigned to the user and every
+// shopfloor below them
+func (r *repository) FindAllowedShopfloors(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	query := `WITH RECURSIVE allowed AS (
+		SELECT shopfloor_id AS id FROM user_shopfloors WHERE user_id = $1
+		UNION
+		SELECT child.id FROM shopfloors child JOIN allowed ON child.parent_id = allowed.id
+	)
+	SELECT id FROM allowed`
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// CountTenantShopfloors returns how many of the shopfloors belong to the tenant
+func (r *repository) CountTenantShopfloors(ctx context.Context, customerID uuid.UUID, shopfloorIDs []uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM shopfloors WHERE customer_id = $1 AND id = ANY($2::uuid[]) AND deleted_at IS NULL`
+	var count int
+	err := r.db.QueryRowContext(ctx, query, customerID, pq.Array(shopfloorIDs)).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ReplaceShopfloors sets the shopfloors assigned to the user
+func (r *repository) ReplaceShopfloors(ctx context.Context, userID uuid.UUID, shopfloorIDs []uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_shopfloors WHERE user_id = $1`, userID); err != nil {
 		return err
 	}
-	return nil
+	for _, shopfloorID := range shopfloorIDs {
+		query := `INSERT INTO user_shopfloors (user_id, shopfloor_id) VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, query, userID, shopfloorID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RevokeSessions ends every login session of the user
+func (r *repository) RevokeSessions(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
 }
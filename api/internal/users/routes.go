@@ -5,10 +5,14 @@ import "github.com/gin-gonic/gin"
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/users", handler.Create)
 	router.GET("/users", handler.FindAll)
+	router.GET("/users/trash", handler.Trash)
 	router.GET("/users/:id", handler.FindByID)
 	router.GET("/users/customer/:customer_id", handler.FindByCustomerID)
 	router.PUT("/users/:id", handler.Update)
 	router.DELETE("/users/:id", handler.Delete)
+	router.POST("/users/:id/restore", handler.Restore)
+	router.GET("/users/:id/shopfloors", handler.FindShopfloors)
+	router.PUT("/users/:id/shopfloors", handler.SetShopfloors)
 }
 
 func RegisterAdminRoutes(router *gin.RouterGroup, handler *Handler) {
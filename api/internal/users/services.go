@@ -1,10 +1,15 @@
 package users
 
 import (
+	"api/internal/apperr"
+	"api/internal/audit"
 	"api/internal/customers"
+	"api/internal/listing"
+	"api/internal/roles"
+	"api/internal/tenant"
 	"context"
+	"database/sql"
 	"errors"
-	"log/slog"
 	"os"
 	"time"
 
@@ -12,24 +17,63 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrMaxUsers is returned when the tenant has as many users as its plan allows
+var ErrMaxUsers = apperr.QuotaExceeded("max_users", "max users limit reached for this tenant")
+
+// ErrImpersonatedPassword is returned when an admin impersonating a user
+// tries to change a password
+var ErrImpersonatedPassword = apperr.Forbidden("impersonation_not_allowed", "passwords can't be changed while impersonating a user")
+
+// ErrEmailTaken is returned when restoring a user whose email another user
+// took while they were in the trash
+var ErrEmailTaken = apperr.Conflict("email_taken", "another user has the email of this user")
+
 type Service interface {
 	Create(ctx context.Context, request UserRequest) (User, error)
+	Register(ctx context.Context, request UserRequest) (User, error)
+	Invite(ctx context.Context, request UserRequest) (User, error)
+	Provision(ctx context.Context, customerID uuid.UUID, request UserRequest) (User, error)
+	SyncRole(ctx context.Context, user User, role string) (User, error)
 	CreateAdmin(ctx context.Context)error
-	FindAll(ctx context.Context) ([]User, error)	
+	List(ctx context.Context, params listing.Params) ([]User, int, error)
 	FindByID(ctx context.Context, id string) (User, error)
-	FindByCustomerID(ctx context.Context, customerID string) ([]User, error)
+	FindByCustomerID(ctx context.Context, customerID string, params listing.Params) ([]User, int, error)
 	FindByEmail(ctx context.Context, email string) (User, error)
+	Lookup(ctx context.Context, id uuid.UUID) (User, error)
 	Update(ctx context.Context, id string, request UserRequest) (User, error)
 	Delete(ctx context.Context, id string) error
+	Trash(ctx context.Context, customerID string, params listing.Params) ([]User, int, error)
+	Restore(ctx context.Context, id string) (User, error)
+	FindShopfloors(ctx context.Context, id string) ([]uuid.UUID, error)
+	SetShopfloors(ctx context.Context, id string, request ShopfloorsRequest) ([]uuid.UUID, error)
+	AllowedShopfloors(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, bool, error)
 }
 
 type service struct {
 	repo Repository
 	customerService customers.Service
+	roleService roles.Service
+	audit audit.Recorder
 }
 
-func NewService(repo Repository, customerService customers.Service) Service {
-	return &service{repo: repo, customerService: customerService}
+func NewService(repo Repository, customerService customers.Service, roleService roles.Service, recorder audit.Recorder) Service {
+	return &service{repo: repo, customerService: customerService, roleService: roleService, audit: recorder}
+}
+
+// resolveRole validates the requested role for the tenant. Users without
+// an explicit role get read-only access.
+func (s *service) resolveRole(ctx context.Context, customerID uuid.UUID, code string) (string, error) {
+	if code == "" {
+		return roles.RoleReadOnly, nil
+	}
+	role, err := s.roleService.Resolve(ctx, customerID, code)
+	if err != nil {
+		return "", err
+	}
+	if err := s.roleService.CheckGrantable(ctx, role.Permissions); err != nil {
+		return "", err
+	}
+	return role.Code, nil
 }
 
 func (s *service) Create(ctx context.Context, request UserRequest) (User, error) {
@@ -59,28 +103,124 @@ func (s *service) Create(ctx context.Context, request UserRequest) (User, error)
 		customerID = customerIDFromCtx
 	}
 	
+	user, err := s.newUser(ctx, customerID, request)
+	if err != nil {
+		return User{}, err
+	}
+	// Users created by an admin of the tenant don't need to verify their email
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	return s.create(ctx, user)
+}
+
+// Register creates a user of the customer in the request for the public sign
+// up. The email address is unverified until the user follows the link sent
+// by email.
+func (s *service) Register(ctx context.Context, request UserRequest) (User, error) {
+	customerID, err := uuid.Parse(request.CustomerID)
+	if err != nil {
+		return User{}, err
+	}
+	user, err := s.newUser(ctx, customerID, request)
+	if err != nil {
+		return User{}, err
+	}
+	return s.create(ctx, user)
+}
+
+// Invite creates an inactive user with an unusable password. The user is
+// activated when the invitation is accepted and a password is chosen.
+func (s *service) Invite(ctx context.Context, request UserRequest) (User, error) {
+	customerID, err := tenant.Customer(ctx, request.CustomerID)
+	if err != nil {
+		return User{}, err
+	}
+	request.Password = uuid.NewString() + uuid.NewString()
+	user, err := s.newUser(ctx, customerID, request)
+	if err != nil {
+		return User{}, err
+	}
+	user.IsActive = false
+	return s.create(ctx, user)
+}
+
+// Provision creates a user of the customer on their first single sign-on.
+// The identity provider vouches for the email and the password is unusable,
+// so the user can only log in through it.
+func (s *service) Provision(ctx context.Context, customerID uuid.UUID, request UserRequest) (User, error) {
+	request.Password = uuid.NewString() + uuid.NewString()
+	user, err := s.newUser(ctx, customerID, request)
+	if err != nil {
+		return User{}, err
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	return s.create(ctx, user)
+}
+
+// SyncRole gives the user the role their identity provider maps them to
+func (s *service) SyncRole(ctx context.Context, user User, role string) (User, error) {
+	code, err := s.resolveRole(ctx, user.CustomerID, role)
+	if err != nil {
+		return User{}, err
+	}
+	if code == user.Role {
+		return user, nil
+	}
+	before := user
+	user.Role = code
+	user.UpdatedAt = time.Now()
+	updated, err := s.repo.Update(ctx, user)
+	if err != nil {
+		return User{}, err
+	}
+	s.audit.Updated(ctx, audit.EntityUser, updated.ID, updated.CustomerID, before, updated)
+	return updated, nil
+}
+
+// create stores a new user and records it in the audit log
+func (s *service) create(ctx context.Context, user User) (User, error) {
+	created, err := s.repo.Create(ctx, user)
+	if err != nil {
+		return User{}, err
+	}
+	s.audit.Created(ctx, audit.EntityUser, created.ID, created.CustomerID, created)
+	return created, nil
+}
+
+// newUser checks the limits of the customer and builds an active user with
+// the hashed password of the request
+func (s *service) newUser(ctx context.Context, customerID uuid.UUID, request UserRequest) (User, error) {
+	// Updates keep the password when it's empty, new users need one
+	if request.Password == "" {
+		return User{}, apperr.Field("password", "required", "password is required")
+	}
 	// Check limits
-	customer, err := s.customerService.FindByID(ctx, customerID.String())
+	customer, err := s.customerService.Lookup(ctx, customerID)
 	if err != nil {
 		return User{}, err
 	}
-	slog.Info("Customer found", "customer", customer)
 	count, err := s.repo.CountByCustomerID(ctx, customerID)
 	if err != nil {
 		return User{}, err
 	}
 
 	if count >= customer.MaxUsers {
-		return User{}, errors.New("max users limit reached for this tenant")
+		return User{}, ErrMaxUsers
 	}
 	
+	role, err := s.resolveRole(ctx, customerID, request.Role)
+	if err != nil {
+		return User{}, err
+	}
+
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return User{}, err
 	}
 	
-	user := User{
+	return User{
 		ID:         uuid.New(),
 		Username:   request.Username,
 		Email:      request.Email,
@@ -88,10 +228,10 @@ func (s *service) Create(ctx context.Context, request UserRequest) (User, error)
 		CustomerID: customerID,
 		IsActive:   true,
 		IsAdmin:    false,
+		Role:       role,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
-	}
-	return s.repo.Create(ctx, user)
+	}, nil
 }
 func (s *service) CreateAdmin(ctx context.Context) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(os.Getenv("ADMIN_PASSWORD")), bcrypt.DefaultCost)
@@ -115,6 +255,7 @@ func (s *service) CreateAdmin(ctx context.Context) error {
 	}
 
 
+	now := time.Now()
 	user := User{
 		ID:         uuid.New(),
 		Username:   "admin",
@@ -123,64 +264,62 @@ func (s *service) CreateAdmin(ctx context.Context) error {
 		CustomerID: customer.ID,
 		IsActive:   true,
 		IsAdmin:    true,
+		Role:       roles.RoleOwner,
+		EmailVerifiedAt: &now,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
-	_, err = s.repo.Create(ctx, user)
+	_, err = s.create(ctx, user)
 	return err
 }
 
-func (s *service) FindAll(ctx context.Context) ([]User, error) {
-	isAdminVal := ctx.Value("is_admin")
-	isAdmin, ok := isAdminVal.(bool)
-	if !ok {
-		return nil, errors.New("invalid or missing is_admin in context")
-	}
-	if isAdmin{
-		return s.repo.FindAll(ctx)
+// List returns a page of the users of the tenant, every tenant for admins
+func (s *service) List(ctx context.Context, params listing.Params) ([]User, int, error) {
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, 0, err
 	}
-	customerIDVal := ctx.Value("customer_id")
-		customerIDFromCtx, ok := customerIDVal.(uuid.UUID)
-		if !ok {
-			return nil, errors.New("invalid or missing customer_id in context")
-		}
-		customerID := customerIDFromCtx
-	return s.repo.FindByCustomerID(ctx, customerID)
+	return s.repo.List(ctx, scope, params)
 }
 
 func (s *service) FindByID(ctx context.Context, id string) (User, error) {
-	parsedId, err := uuid.Parse(id)
-	if err != nil {
-		return User{}, err
-	}
-	return s.repo.FindByID(ctx, parsedId)
+	return s.findOwned(ctx, id)
 }
 
-func (s *service) FindByCustomerID(ctx context.Context, customerID string) ([]User, error) {
-	parsedCustomerID, err := uuid.Parse(customerID)
+func (s *service) FindByCustomerID(ctx context.Context, customerID string, params listing.Params) ([]User, int, error) {
+	parsedCustomerID, err := tenant.Customer(ctx, customerID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return s.repo.FindByCustomerID(ctx, parsedCustomerID)
+	return s.repo.List(ctx, &parsedCustomerID, params)
 }
 
 func (s *service) FindByEmail(ctx context.Context, email string) (User, error) {
 	return s.repo.FindByEmail(ctx, email)
 }
 
+// Lookup returns the user without checking the tenant of the current user.
+// It is meant for flows without a logged in user, such as refreshing a token.
+func (s *service) Lookup(ctx context.Context, id uuid.UUID) (User, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
 func (s *service) Update(ctx context.Context, id string, request UserRequest) (User, error) {
-	parsedId, err := uuid.Parse(id)
+	user, err := s.findOwned(ctx, id)
 	if err != nil {
 		return User{}, err
 	}
-	customerID, err := uuid.Parse(request.CustomerID)
+	customerID, err := tenant.Customer(ctx, request.CustomerID)
 	if err != nil {
 		return User{}, err
 	}
-	user, err := s.repo.FindByID(ctx, parsedId)
-	if err != nil {
-		return User{}, err
+	// Admins acting as a user can't take over the account
+	if impersonatedBy, _ := ctx.Value("impersonated_by").(string); impersonatedBy != "" && request.Password != "" {
+		return User{}, ErrImpersonatedPassword
 	}
+	// Deactivating a user or changing their password ends their sessions
+	revoke := request.Password != "" || (user.IsActive && !request.IsActive)
+	before := user
 	
 	user.Username = request.Username
 	user.Email = request.Email
@@ -195,16 +334,176 @@ func (s *service) Update(ctx context.Context, id string, request UserRequest) (U
 	}
 	
 	user.CustomerID = customerID
+	// The role is kept when the request doesn't set one
+	if request.Role != "" {
+		role, err := s.resolveRole(ctx, customerID, request.Role)
+		if err != nil {
+			return User{}, err
+		}
+		user.Role = role
+	}
 	user.IsActive = request.IsActive
 	user.IsAdmin = false
 	user.UpdatedAt = time.Now()
-	return s.repo.Update(ctx, user)
+	user, err = s.repo.Update(ctx, user)
+	if err != nil {
+		return User{}, err
+	}
+	s.audit.Updated(ctx, audit.EntityUser, user.ID, user.CustomerID, before, user)
+	if revoke {
+		if err := s.repo.RevokeSessions(ctx, user.ID); err != nil {
+			return User{}, err
+		}
+	}
+	return user, nil
 }
 
+// Delete moves the user to the trash and ends their sessions
 func (s *service) Delete(ctx context.Context, id string) error {
-	parsedId, err := uuid.Parse(id)
+	user, err := s.findOwned(ctx, id)
 	if err != nil {
 		return err
 	}
-	return s.repo.Delete(ctx, parsedId)
+	if err := s.repo.Delete(ctx, user.ID); err != nil {
+		return err
+	}
+	s.audit.Deleted(ctx, audit.EntityUser, user.ID, user.CustomerID, user)
+	return nil
+}
+
+// Trash returns the deleted users of the tenant
+func (s *service) Trash(ctx context.Context, customerID string, params listing.Params) ([]User, int, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repo.FindDeleted(ctx, scope, params)
+}
+
+// Restore takes the user out of the trash if the tenant has room for them
+// and nobody took their email. A user whose role was deleted meanwhile comes
+// back read-only.
+func (s *service) Restore(ctx context.Context, id string) (User, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return User{}, err
+	}
+	user, err := s.repo.FindDeletedByID(ctx, parsedID)
+	if err != nil {
+		return User{}, err
+	}
+	if err := tenant.Check(ctx, user.CustomerID); err != nil {
+		return User{}, err
+	}
+	customer, err := s.customerService.Lookup(ctx, user.CustomerID)
+	if err != nil {
+		return User{}, err
+	}
+	count, err := s.repo.CountByCustomerID(ctx, user.CustomerID)
+	if err != nil {
+		return User{}, err
+	}
+	if count >= customer.MaxUsers {
+		return User{}, ErrMaxUsers
+	}
+	_, err = s.repo.FindByEmail(ctx, user.Email)
+	if err == nil {
+		return User{}, ErrEmailTaken
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return User{}, err
+	}
+	// Custom roles can be deleted while their users are in the trash
+	if _, err := s.roleService.Resolve(ctx, user.CustomerID, user.Role); errors.Is(err, roles.ErrUnknownRole) {
+		user.Role = roles.RoleReadOnly
+	} else if err != nil {
+		return User{}, err
+	}
+	if err := s.repo.Restore(ctx, user.ID, user.Role); err != nil {
+		return User{}, err
+	}
+	user.DeletedAt = nil
+	s.audit.Restored(ctx, audit.EntityUser, user.ID, user.CustomerID, user)
+	return user, nil
+}
+
+// findOwned returns the user if the current user can manage it
+func (s *service) findOwned(ctx context.Context, id string) (User, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return User{}, err
+	}
+	user, err := s.repo.FindByID(ctx, parsedId)
+	if err != nil {
+		return User{}, err
+	}
+	if err := tenant.Check(ctx, user.CustomerID); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (s *service) FindShopfloors(ctx context.Context, id string) ([]uuid.UUID, error) {
+	user, err := s.findOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindShopfloors(ctx, user.ID)
+}
+
+// SetShopfloors replaces the shopfloors the user is restricted to. They must
+// belong to the tenant of the user.
+func (s *service) SetShopfloors(ctx context.Context, id string, request ShopfloorsRequest) ([]uuid.UUID, error) {
+	user, err := s.findOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsAdmin {
+		return nil, apperr.Field("shopfloor_ids", "admin_shopfloors", "system admins can't be restricted to shop floors")
+	}
+	seen := map[uuid.UUID]bool{}
+	shopfloorIDs := []uuid.UUID{}
+	for _, raw := range request.ShopfloorIDs {
+		shopfloorID, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[shopfloorID] {
+			seen[shopfloorID] = true
+			shopfloorIDs = append(shopfloorIDs, shopfloorID)
+		}
+	}
+	if len(shopfloorIDs) > 0 {
+		count, err := s.repo.CountTenantShopfloors(ctx, user.CustomerID, shopfloorIDs)
+		if err != nil {
+			return nil, err
+		}
+		if count != len(shopfloorIDs) {
+			return nil, apperr.NotFound("shopfloor_not_found", "shop floor not found")
+		}
+	}
+	previous, err := s.repo.FindShopfloors(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceShopfloors(ctx, user.ID, shopfloorIDs); err != nil {
+		return nil, err
+	}
+	s.audit.Updated(ctx, audit.EntityUser, user.ID, user.CustomerID,
+		map[string]interface{}{"shopfloor_ids": previous}, map[string]interface{}{"shopfloor_ids": shopfloorIDs})
+	return shopfloorIDs, nil
+}
+
+// AllowedShopfloors returns the shopfloors the user can work with, including
+// the ones below the assigned shopfloors. restricted is false when the user
+// has no assignments and sees the whole tenant.
+func (s *service) AllowedShopfloors(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, bool, error) {
+	allowed, err := s.repo.FindAllowedShopfloors(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(allowed) == 0 {
+		return nil, false, nil
+	}
+	return allowed, true, nil
 }
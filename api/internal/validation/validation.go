@@ -0,0 +1,55 @@
+// Package validation sets up how request bodies are checked. Every request
+// struct declares the rules of its fields in binding tags, which gin checks
+// while binding; handlers pass the error to apperr.Invalid, which lists the
+// failed rules per field. Rules that need more than one field or a lookup,
+// such as a workcenter belonging to the shop floor of the request, are
+// checked by the services, which report them in the same format through
+// Errors.
+package validation
+
+import (
+	"api/internal/apperr"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Register names the fields of binding errors after their json tag, the
+// name clients send
+func Register() error {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected validator engine")
+	}
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return nil
+}
+
+// Errors collects the field errors of a request, so a client learns every
+// wrong field at once
+type Errors []apperr.FieldError
+
+// Add records what is wrong with a field
+func (e *Errors) Add(field, code, message string) {
+	*e = append(*e, apperr.FieldError{Field: field, Code: code, Message: message})
+}
+
+// Err is the validation error of the fields added, nil when there are none
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return apperr.Fields(e...)
+}
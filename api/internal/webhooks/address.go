@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"api/internal/apperr"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errForbiddenAddress is returned when dialing an address webhooks can't reach
+var errForbiddenAddress = errors.New("webhook address is private or reserved")
+
+// reservedPrefixes are the special purpose ranges not covered by the netip
+// predicates: shared, benchmarking, documentation, translation and future use
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// publicAddress reports whether webhooks may be sent to addr: private,
+// loopback, link-local, multicast and reserved addresses are refused so an
+// endpoint can't reach the internal network of the API
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHost resolves the host of an endpoint URL and rejects it unless all
+// its addresses are public
+func checkHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return apperr.Field("url", "unresolvable_host", "url host does not resolve")
+	}
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return apperr.Field("url", "forbidden_address", "url must not resolve to a private or reserved address")
+		}
+	}
+	return nil
+}
+
+// newClient returns the HTTP client of the dispatcher. Its dialer checks the
+// address actually connected to, so a host that resolves to another address
+// after registration still can't reach the internal network.
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !publicAddress(addrPort.Addr()) {
+				return errForbiddenAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on our behalf, out of reach of the dialer check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
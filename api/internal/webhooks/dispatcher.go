@@ -0,0 +1,169 @@
+package webhooks
+
+import (
+	"api/config"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	dispatchBatchSize = 100
+	maxBackoff        = 6 * time.Hour
+	baseBackoff       = 30 * time.Second
+)
+
+// Dispatcher moves events from the outbox to webhook deliveries and sends them,
+// retrying failed deliveries with exponential backoff.
+type Dispatcher struct {
+	repo         Repository
+	client       *http.Client
+	pollInterval time.Duration
+	maxAttempts  int
+}
+
+func NewDispatcher(repo Repository, cfg config.Config) *Dispatcher {
+	return &Dispatcher{
+		repo:         repo,
+		client:       newClient(cfg.Webhooks.Timeout),
+		pollInterval: cfg.Webhooks.PollInterval,
+		maxAttempts:  cfg.Webhooks.MaxAttempts,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	slog.Info("Webhook dispatcher started", slog.Duration("poll_interval", d.pollInterval))
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for {
+		d.tick(ctx)
+		select {
+		case <-ctx.Done():
+			slog.Info("Webhook dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) tick(ctx context.Context) {
+	if _, err := d.repo.DispatchEvents(ctx, dispatchBatchSize); err != nil {
+		slog.Error("Failed to dispatch outbox events", slog.Any("error", err))
+	}
+	// Claimed deliveries are not retried by another worker until the HTTP timeout has passed
+	deliveries, err := d.repo.ClaimDeliveries(ctx, dispatchBatchSize, d.client.Timeout+time.Minute)
+	if err != nil {
+		slog.Error("Failed to claim webhook deliveries", slog.Any("error", err))
+		return
+	}
+	for _, delivery := range deliveries {
+		d.deliver(ctx, delivery)
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, pending pendingDelivery) {
+	delivery := pending.Delivery
+	delivery.Attempts++
+	now := time.Now()
+	delivery.UpdatedAt = now
+
+	statusCode, err := d.send(ctx, pending)
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+	if err == nil {
+		delivery.Status = DeliveryStatusDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= d.maxAttempts {
+			delivery.Status = DeliveryStatusFailed
+			delivery.NextAttemptAt = nil
+		} else {
+			delivery.Status = DeliveryStatusRetrying
+			next := now.Add(backoff(delivery.Attempts))
+			delivery.NextAttemptAt = &next
+		}
+		slog.Warn("Webhook delivery failed",
+			slog.String("delivery_id", delivery.ID.String()),
+			slog.String("event_type", delivery.EventType),
+			slog.Int("attempt", delivery.Attempts),
+			slog.Any("error", err),
+		)
+	}
+
+	if err := d.repo.SaveAttempt(ctx, delivery); err != nil {
+		slog.Error("Failed to save webhook delivery attempt", slog.String("delivery_id", delivery.ID.String()), slog.Any("error", err))
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, pending pendingDelivery) (int, error) {
+	body, err := json.Marshal(envelope{
+		ID:         pending.EventID,
+		Type:       pending.EventType,
+		CustomerID: pending.CustomerID,
+		CreatedAt:  pending.EventCreatedAt,
+		Data:       pending.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pending.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Turniq-Webhooks/1.0")
+	req.Header.Set("X-Turniq-Event", pending.EventType)
+	req.Header.Set("X-Turniq-Delivery", pending.ID.String())
+	req.Header.Set("X-Turniq-Timestamp", timestamp)
+	req.Header.Set("X-Turniq-Signature", "sha256="+Sign(pending.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign computes the hex HMAC-SHA256 of "<timestamp>.<body>" with the endpoint secret.
+// Receivers should recompute it and reject stale timestamps to prevent replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns the wait before the next attempt: 30s, 1m, 2m, 4m... capped at 6h
+func backoff(attempt int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return wait
+}
@@ -0,0 +1,17 @@
+package webhooks
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "GET", Path: "/api/webhooks/events", Tag: "webhooks", Summary: "Events a webhook can subscribe to", Response: []string{}},
+	{Method: "POST", Path: "/api/webhooks", Tag: "webhooks", Summary: "Create a webhook", Request: EndpointRequest{}, Response: Endpoint{}, Status: 201},
+	{Method: "GET", Path: "/api/webhooks", Tag: "webhooks", Summary: "List webhooks", Response: []Endpoint{}},
+	{Method: "GET", Path: "/api/webhooks/:id", Tag: "webhooks", Summary: "Get a webhook", Response: Endpoint{}},
+	{Method: "PUT", Path: "/api/webhooks/:id", Tag: "webhooks", Summary: "Update a webhook", Request: EndpointRequest{}, Response: Endpoint{}},
+	{Method: "DELETE", Path: "/api/webhooks/:id", Tag: "webhooks", Summary: "Delete a webhook"},
+	{Method: "GET", Path: "/api/webhooks/:id/deliveries", Tag: "webhooks", Summary: "Latest deliveries of a webhook", Response: []Delivery{}, Query: []openapi.Param{
+		{Name: "limit", Type: "integer"},
+	}},
+	{Method: "POST", Path: "/api/webhooks/deliveries/:deliveryID/retry", Tag: "webhooks", Summary: "Send a failed delivery again"},
+}
@@ -0,0 +1,127 @@
+package webhooks
+
+import (
+	"api/internal/apperr"
+	"api/internal/tenant"
+	"api/internal/outbox"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request EndpointRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.Create(ctx, request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Webhook created successfully", "data": response})
+}
+
+func (h *Handler) FindAll(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.FindAll(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhooks found successfully", "data": response})
+}
+
+func (h *Handler) FindByID(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	response, err := h.service.FindByID(ctx, id)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("webhook_endpoint_not_found", "Webhook endpoint not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook found successfully", "data": response})
+}
+
+func (h *Handler) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	var request EndpointRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.Update(ctx, id, request)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("webhook_endpoint_not_found", "Webhook endpoint not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook updated successfully", "data": response})
+}
+
+func (h *Handler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	if err := h.service.Delete(ctx, id); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("webhook_endpoint_not_found", "Webhook endpoint not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+func (h *Handler) FindDeliveries(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	response, err := h.service.FindDeliveries(ctx, id, limit)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("webhook_endpoint_not_found", "Webhook endpoint not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deliveries found successfully", "data": response})
+}
+
+func (h *Handler) RetryDelivery(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("deliveryID")
+	if err := h.service.RetryDelivery(ctx, id); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("webhook_delivery_not_found", "Webhook delivery not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Delivery scheduled for retry"})
+}
+
+func (h *Handler) EventTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": outbox.EventTypes})
+}
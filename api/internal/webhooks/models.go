@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusRetrying  = "retrying"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+)
+
+type Endpoint struct {
+	ID         uuid.UUID `json:"id"`
+	CustomerID uuid.UUID `json:"customer_id"`
+	URL        string    `json:"url"`
+	// Secret is only returned when the endpoint is created
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type EndpointRequest struct {
+	CustomerID string   `json:"customer_id" binding:"omitempty,uuid"`
+	URL        string   `json:"url" binding:"required,url"`
+	Secret     string   `json:"secret"`
+	Events     []string `json:"events"`
+	IsActive   bool     `json:"is_active"`
+}
+
+type Delivery struct {
+	ID             uuid.UUID  `json:"id"`
+	CustomerID     uuid.UUID  `json:"customer_id"`
+	EndpointID     uuid.UUID  `json:"endpoint_id"`
+	EventID        uuid.UUID  `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode *int       `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// pendingDelivery is a delivery claimed by the dispatcher, with everything needed to send it
+type pendingDelivery struct {
+	Delivery
+	URL            string
+	Secret         string
+	EventCreatedAt time.Time
+	Payload        json.RawMessage
+}
+
+// envelope is the JSON body posted to webhook endpoints
+type envelope struct {
+	ID         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	CustomerID uuid.UUID       `json:"customer_id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Data       json.RawMessage `json:"data"`
+}
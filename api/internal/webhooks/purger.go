@@ -0,0 +1,43 @@
+package webhooks
+
+import (
+	"api/config"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// How often the purger looks for expired outbox events
+const purgeInterval = time.Hour
+
+// Purger deletes the outbox events, and their deliveries, older than the
+// retention period
+type Purger struct {
+	repo      Repository
+	retention time.Duration
+}
+
+func NewPurger(repo Repository, cfg config.Config) *Purger {
+	return &Purger{repo: repo, retention: cfg.Webhooks.Retention}
+}
+
+// Run purges the outbox every purgeInterval until ctx is cancelled
+func (p *Purger) Run(ctx context.Context) {
+	slog.Info("Outbox purger started", slog.Duration("retention", p.retention))
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		purged, err := p.repo.PurgeEvents(ctx, time.Now().Add(-p.retention))
+		if err != nil {
+			slog.Error("Failed to purge the outbox", slog.Any("error", err))
+		} else if purged > 0 {
+			slog.Info("Outbox purged", slog.Int64("events", purged))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Outbox purger stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Repository interface {
+	Create(ctx context.Context, endpoint Endpoint) (Endpoint, error)
+	FindAll(ctx context.Context) ([]Endpoint, error)
+	FindByID(ctx context.Context, id uuid.UUID) (Endpoint, error)
+	FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]Endpoint, error)
+	Update(ctx context.Context, endpoint Endpoint) (Endpoint, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	FindDeliveriesByEndpointID(ctx context.Context, endpointID uuid.UUID, limit int) ([]Delivery, error)
+	FindDeliveryByID(ctx context.Context, id uuid.UUID) (Delivery, error)
+	RetryDelivery(ctx context.Context, id uuid.UUID) error
+	DispatchEvents(ctx context.Context, limit int) (int, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]pendingDelivery, error)
+	SaveAttempt(ctx context.Context, delivery Delivery) error
+	PurgeEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(ctx context.Context, endpoint Endpoint) (Endpoint, error) {
+	query := `INSERT INTO webhook_endpoints (id, customer_id, url, secret, events, is_active, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := r.db.ExecContext(ctx, query, endpoint.ID, endpoint.CustomerID, endpoint.URL, endpoint.Secret,
+		pq.Array(endpoint.Events), endpoint.IsActive, endpoint.CreatedAt, endpoint.UpdatedAt)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	return endpoint, nil
+}
+
+func (r *repository) FindAll(ctx context.Context) ([]Endpoint, error) {
+	query := `SELECT id, customer_id, url, secret, events, is_active, created_at, updated_at
+	FROM webhook_endpoints ORDER BY created_at ASC`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	endpoints := []Endpoint{}
+	for rows.Next() {
+		var endpoint Endpoint
+		if err := rows.Scan(&endpoint.ID, &endpoint.CustomerID, &endpoint.URL, &endpoint.Secret, pq.Array(&endpoint.Events), &endpoint.IsActive, &endpoint.CreatedAt, &endpoint.UpdatedAt); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (Endpoint, error) {
+	query := `SELECT id, customer_id, url, secret, events, is_active, created_at, updated_at
+	FROM webhook_endpoints WHERE id = $1`
+	row := r.db.QueryRowContext(ctx, query, id)
+	var endpoint Endpoint
+	if err := row.Scan(&endpoint.ID, &endpoint.CustomerID, &endpoint.URL, &endpoint.Secret, pq.Array(&endpoint.Events), &endpoint.IsActive, &endpoint.CreatedAt, &endpoint.UpdatedAt); err != nil {
+		return Endpoint{}, err
+	}
+	return endpoint, nil
+}
+
+func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]Endpoint, error) {
+	query := `SELECT id, customer_id, url, secret, events, is_active, created_at, updated_at
+	FROM webhook_endpoints WHERE customer_id = $1 ORDER BY created_at ASC`
+	rows, err := r.db.QueryContext(ctx, query, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	endpoints := []Endpoint{}
+	for rows.Next() {
+		var endpoint Endpoint
+		if err := rows.Scan(&endpoint.ID, &endpoint.CustomerID, &endpoint.URL, &endpoint.Secret, pq.Array(&endpoint.Events), &endpoint.IsActive, &endpoint.CreatedAt, &endpoint.UpdatedAt); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+func (r *repository) Update(ctx context.Context, endpoint Endpoint) (Endpoint, error) {
+	query := `UPDATE webhook_endpoints SET url = $2, secret = $3, events = $4, is_active = $5, updated_at = $6 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, endpoint.ID, endpoint.URL, endpoint.Secret, pq.Array(endpoint.Events), endpoint.IsActive, endpoint.UpdatedAt)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	return endpoint, nil
+}
+
+func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM webhook_endpoints WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *repository) FindDeliveriesByEndpointID(ctx context.Context, endpointID uuid.UUID, limit int) ([]Delivery, error) {
+	query := `SELECT id, customer_id, endpoint_id, event_id, event_type, status, attempts, last_status_code, last_error,
+		next_attempt_at, delivered_at, created_at, updated_at
+	FROM webhook_deliveries WHERE endpoint_id = $1
+	ORDER BY created_at DESC LIMIT $2`
+	rows, err := r.db.QueryContext(ctx, query, endpointID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		if err := rows.Scan(&d.ID, &d.CustomerID, &d.EndpointID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError,
+			&d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func (r *repository) FindDeliveryByID(ctx context.Context, id uuid.UUID) (Delivery, error) {
+	query := `SELECT id, customer_id, endpoint_id, event_id, event_type, status, attempts, last_status_code, last_error,
+		next_attempt_at, delivered_at, created_at, updated_at
+	FROM webhook_deliveries WHERE id = $1`
+	var d Delivery
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&d.ID, &d.CustomerID, &d.EndpointID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError,
+		&d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return Delivery{}, err
+	}
+	return d, nil
+}
+
+// RetryDelivery schedules a delivery to be sent again on the next dispatcher run,
+// with a fresh budget of attempts
+func (r *repository) RetryDelivery(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE webhook_deliveries SET status = $2, attempts = 0, next_attempt_at = NOW(), updated_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, DeliveryStatusRetrying)
+	return err
+}
+
+// DispatchEvents fans out undispatched outbox events into one delivery per subscribed endpoint.
+// Rows are locked with SKIP LOCKED so several API instances can run the dispatcher at once.
+func (r *repository) DispatchEvents(ctx context.Context, limit int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, customer_id, event_type FROM outbox_events
+	WHERE dispatched_at IS NULL ORDER BY created_at ASC LIMIT $1 FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, err
+	}
+	type pendingEvent struct {
+		ID         uuid.UUID
+		CustomerID uuid.UUID
+		Type       string
+	}
+	var events []pendingEvent
+	for rows.Next() {
+		var e pendingEvent
+		if err := rows.Scan(&e.ID, &e.CustomerID, &e.Type); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// An endpoint without events subscribes to all of them
+	fanOut := `INSERT INTO webhook_deliveries (id, customer_id, endpoint_id, event_id, event_type, status, next_attempt_at, created_at, updated_at)
+	SELECT uuid_generate_v4(), e.customer_id, e.id, $1, $3, $4, NOW(), NOW(), NOW()
+	FROM webhook_endpoints e
+	WHERE e.customer_id = $2 AND e.is_active AND ($3 = ANY(e.events) OR cardinality(e.events) = 0)`
+	for _, e := range events {
+		if _, err := tx.ExecContext(ctx, fanOut, e.ID, e.CustomerID, e.Type, DeliveryStatusPending); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE outbox_events SET dispatched_at = NOW() WHERE id = $1`, e.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(events), tx.Commit()
+}
+
+// ClaimDeliveries returns the deliveries of active endpoints due for an attempt and pushes
+// their next attempt forward by lease, so that a crashed worker does not block them forever.
+// Deliveries of a disabled endpoint wait until it is enabled again.
+func (r *repository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]pendingDelivery, error) {
+	query := `WITH due AS (
+		SELECT d.id FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE d.status IN ($1, $2) AND d.next_attempt_at <= NOW() AND e.is_active
+		ORDER BY d.next_attempt_at ASC
+		LIMIT $3
+		FOR UPDATE OF d SKIP LOCKED
+	)
+	UPDATE webhook_deliveries d SET next_attempt_at = NOW() + ($4 * INTERVAL '1 second')
+	FROM due, webhook_endpoints e, outbox_events o
+	WHERE d.id = due.id AND e.id = d.endpoint_id AND o.id = d.event_id
+	RETURNING d.id, d.customer_id, d.endpoint_id, d.event_id, d.event_type, d.status, d.attempts, d.created_at,
+		e.url, e.secret, o.created_at, o.payload`
+	rows, err := r.db.QueryContext(ctx, query, DeliveryStatusPending, DeliveryStatusRetrying, limit, int(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var deliveries []pendingDelivery
+	for rows.Next() {
+		var d pendingDelivery
+		if err := rows.Scan(&d.ID, &d.CustomerID, &d.EndpointID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.CreatedAt,
+			&d.URL, &d.Secret, &d.EventCreatedAt, &d.Payload); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *repository) SaveAttempt(ctx context.Context, delivery Delivery) error {
+	query := `UPDATE webhook_deliveries SET status = $2, attempts = $3, last_status_code = $4, last_error = $5,
+		next_attempt_at = $6, delivered_at = $7, updated_at = $8 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, delivery.ID, delivery.Status, delivery.Attempts, delivery.LastStatusCode, delivery.LastError,
+		delivery.NextAttemptAt, delivery.DeliveredAt, delivery.UpdatedAt)
+	return err
+}
+
+// PurgeEvents deletes the outbox events created before before, with their
+// deliveries, and returns how many. Events with a delivery still to be sent
+// are kept.
+func (r *repository) PurgeEvents(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM outbox_events o WHERE o.created_at < $1
+	AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.event_id = o.id AND d.status IN ($2, $3))`
+	result, err := r.db.ExecContext(ctx, query, before, DeliveryStatusPending, DeliveryStatusRetrying)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,14 @@
+package webhooks
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/webhooks/events", handler.EventTypes)
+	router.POST("/webhooks", handler.Create)
+	router.GET("/webhooks", handler.FindAll)
+	router.GET("/webhooks/:id", handler.FindByID)
+	router.PUT("/webhooks/:id", handler.Update)
+	router.DELETE("/webhooks/:id", handler.Delete)
+	router.GET("/webhooks/:id/deliveries", handler.FindDeliveries)
+	router.POST("/webhooks/deliveries/:deliveryID/retry", handler.RetryDelivery)
+}
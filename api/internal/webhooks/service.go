@@ -0,0 +1,203 @@
+package webhooks
+
+import (
+	"api/internal/apperr"
+	"api/internal/outbox"
+	"api/internal/tenant"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultDeliveryLogLimit = 100
+
+type Service interface {
+	Create(ctx context.Context, request EndpointRequest) (Endpoint, error)
+	FindAll(ctx context.Context) ([]Endpoint, error)
+	FindByID(ctx context.Context, id string) (Endpoint, error)
+	Update(ctx context.Context, id string, request EndpointRequest) (Endpoint, error)
+	Delete(ctx context.Context, id string) error
+	FindDeliveries(ctx context.Context, endpointID string, limit int) ([]Delivery, error)
+	RetryDelivery(ctx context.Context, deliveryID string) error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) Create(ctx context.Context, request EndpointRequest) (Endpoint, error) {
+	customerID, err := tenant.Customer(ctx, request.CustomerID)
+	if err != nil {
+		return Endpoint{}, err
+	}
+
+	if err := validateEndpoint(ctx, request); err != nil {
+		return Endpoint{}, err
+	}
+	secret := request.Secret
+	if secret == "" {
+		generated, err := generateSecret()
+		if err != nil {
+			return Endpoint{}, err
+		}
+		secret = generated
+	}
+
+	endpoint := Endpoint{
+		ID:         uuid.New(),
+		CustomerID: customerID,
+		URL:        request.URL,
+		Secret:     secret,
+		Events:     normalizeEvents(request.Events),
+		IsActive:   request.IsActive,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	return s.repo.Create(ctx, endpoint)
+}
+
+func (s *service) FindAll(ctx context.Context) ([]Endpoint, error) {
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var endpoints []Endpoint
+	if scope == nil {
+		endpoints, err = s.repo.FindAll(ctx)
+	} else {
+		endpoints, err = s.repo.FindByCustomerID(ctx, *scope)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range endpoints {
+		endpoints[i].Secret = ""
+	}
+	return endpoints, nil
+}
+
+func (s *service) FindByID(ctx context.Context, id string) (Endpoint, error) {
+	endpoint, err := s.findOwned(ctx, id)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	endpoint.Secret = ""
+	return endpoint, nil
+}
+
+func (s *service) Update(ctx context.Context, id string, request EndpointRequest) (Endpoint, error) {
+	endpoint, err := s.findOwned(ctx, id)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	if err := validateEndpoint(ctx, request); err != nil {
+		return Endpoint{}, err
+	}
+	endpoint.URL = request.URL
+	// Secrets are rotated by sending a new one, an empty value keeps the current secret
+	if request.Secret != "" {
+		endpoint.Secret = request.Secret
+	}
+	endpoint.Events = normalizeEvents(request.Events)
+	endpoint.IsActive = request.IsActive
+	endpoint.UpdatedAt = time.Now()
+	updated, err := s.repo.Update(ctx, endpoint)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	updated.Secret = ""
+	return updated, nil
+}
+
+func (s *service) Delete(ctx context.Context, id string) error {
+	endpoint, err := s.findOwned(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, endpoint.ID)
+}
+
+func (s *service) FindDeliveries(ctx context.Context, endpointID string, limit int) ([]Delivery, error) {
+	endpoint, err := s.findOwned(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 1000 {
+		limit = defaultDeliveryLogLimit
+	}
+	return s.repo.FindDeliveriesByEndpointID(ctx, endpoint.ID, limit)
+}
+
+func (s *service) RetryDelivery(ctx context.Context, deliveryID string) error {
+	parsedID, err := uuid.Parse(deliveryID)
+	if err != nil {
+		return err
+	}
+	delivery, err := s.repo.FindDeliveryByID(ctx, parsedID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.findOwned(ctx, delivery.EndpointID.String()); err != nil {
+		return err
+	}
+	return s.repo.RetryDelivery(ctx, delivery.ID)
+}
+
+// findOwned loads an endpoint and checks it belongs to the caller's customer
+func (s *service) findOwned(ctx context.Context, id string) (Endpoint, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	endpoint, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	if err := tenant.Check(ctx, endpoint.CustomerID); err != nil {
+		return Endpoint{}, err
+	}
+	return endpoint, nil
+}
+
+func validateEndpoint(ctx context.Context, request EndpointRequest) error {
+	parsed, err := url.Parse(request.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return apperr.Field("url", "invalid_url", "url must be an absolute http or https URL")
+	}
+	if err := checkHost(ctx, parsed.Hostname()); err != nil {
+		return err
+	}
+	for _, event := range request.Events {
+		if !slices.Contains(outbox.EventTypes, event) {
+			return apperr.Field("events", "unknown_event", fmt.Sprintf("unknown event type %q", event))
+		}
+	}
+	return nil
+}
+
+func normalizeEvents(events []string) []string {
+	if events == nil {
+		return []string{}
+	}
+	normalized := slices.Clone(events)
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,28 @@
+package workcenters
+
+import "api/internal/openapi"
+
+// Docs documents the routes of RegisterRoutes
+var Docs = []openapi.Route{
+	{Method: "POST", Path: "/api/workcenters", Tag: "workcenters", Summary: "Create a workcenter", Request: WorkcenterRequest{}, Response: Workcenter{}},
+	{Method: "GET", Path: "/api/workcenters", Tag: "workcenters", Summary: "List workcenters", Response: []Workcenter{}, List: &listSpec},
+	{Method: "GET", Path: "/api/workcenters/utilization", Tag: "workcenters", Summary: "Planned against available hours per workcenter and day", Response: UtilizationReport{}, Query: []openapi.Param{
+		openapi.CustomerID,
+		{Name: "shop_floor_id"},
+		{Name: "from", Description: "YYYY-MM-DD"},
+		{Name: "to", Description: "YYYY-MM-DD"},
+	}},
+	{Method: "GET", Path: "/api/workcenters/trash", Tag: "workcenters", Summary: "List deleted workcenters", Response: []Workcenter{}, List: &trashSpec, Query: []openapi.Param{openapi.CustomerID}},
+	{Method: "GET", Path: "/api/workcenters/:id", Tag: "workcenters", Summary: "Get a workcenter", Response: Workcenter{}},
+	{Method: "GET", Path: "/api/workcenters/customer/:customerID", Tag: "workcenters", Summary: "List the workcenters of a customer", Response: []Workcenter{}, List: &listSpec},
+	{Method: "GET", Path: "/api/workcenters/shopfloor/:shopFloorID", Tag: "workcenters", Summary: "List the workcenters of a shop floor", Response: []Workcenter{}, List: &listSpec},
+	{Method: "PUT", Path: "/api/workcenters/:id", Tag: "workcenters", Summary: "Update a workcenter", Request: WorkcenterRequest{}, Response: Workcenter{}},
+	{Method: "DELETE", Path: "/api/workcenters/:id", Tag: "workcenters", Summary: "Move a workcenter with its jobs, schedule and time entries to the trash", Query: []openapi.Param{openapi.Confirm}},
+	{Method: "POST", Path: "/api/workcenters/:id/restore", Tag: "workcenters", Summary: "Restore a deleted workcenter with the records deleted along with it", Response: Workcenter{}},
+	{Method: "GET", Path: "/api/workcenters/:id/calendar", Tag: "workcenters", Summary: "Calendar exceptions of a workcenter", Response: []CalendarDay{}, Query: []openapi.Param{
+		{Name: "from", Description: "YYYY-MM-DD"},
+		{Name: "to", Description: "YYYY-MM-DD"},
+	}},
+	{Method: "PUT", Path: "/api/workcenters/:id/calendar", Tag: "workcenters", Summary: "Set the hours of a workcenter on a day", Request: CalendarDayRequest{}, Response: CalendarDay{}},
+	{Method: "DELETE", Path: "/api/workcenters/:id/calendar/:date", Tag: "workcenters", Summary: "Remove a calendar exception"},
+}
@@ -1,6 +1,10 @@
 package workcenters
 
 import (
+	"api/internal/apperr"
+	"api/internal/listing"
+	"api/internal/tenant"
+	"api/internal/trash"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -18,13 +22,13 @@ func (h *Handler) Create(c *gin.Context) {
 	ctx := c.Request.Context()
 	var request WorkcenterRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 
 	response, err := h.service.Create(ctx, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Workcenter created successfully", "data": response})
@@ -33,16 +37,32 @@ func (h *Handler) Create(c *gin.Context) {
 func (h *Handler) FindAll(c *gin.Context) {
 	ctx := c.Request.Context()
 	
-	var response []Workcenter
-	var err error
-
-	response, err = h.service.FindAll(ctx)
+	params, err := listing.Parse(c, listSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.List(ctx, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Workcenters found successfully", "data": response, "meta": params.Page(total)})
+}
 
+func (h *Handler) FindByShopFloorID(c *gin.Context) {
+	ctx := c.Request.Context()
+	params, err := listing.Parse(c, listSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.FindByShopFloorID(ctx, c.Param("shopFloorID"), params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Workcenters found successfully", "data": response})
+	c.JSON(http.StatusOK, gin.H{"message": "Workcenters found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) FindByID(c *gin.Context) {
@@ -50,7 +70,11 @@ func (h *Handler) FindByID(c *gin.Context) {
 	id := c.Param("id")
 	response, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("workcenter_not_found", "Workcenter not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Workcenter found successfully", "data": response})
@@ -59,12 +83,17 @@ func (h *Handler) FindByID(c *gin.Context) {
 func (h *Handler) FindByCustomerID(c *gin.Context) {
 	ctx := c.Request.Context()
 	customerID := c.Param("customerID")
-	response, err := h.service.FindByCustomerID(ctx, customerID)
+	params, err := listing.Parse(c, listSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.FindByCustomerID(ctx, customerID, params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Workcenters found successfully", "data": response})
+	c.JSON(http.StatusOK, gin.H{"message": "Workcenters found successfully", "data": response, "meta": params.Page(total)})
 }
 
 func (h *Handler) Update(c *gin.Context) {
@@ -72,12 +101,16 @@ func (h *Handler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var request WorkcenterRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.Invalid(err))
 		return
 	}
 	response, err := h.service.Update(ctx, id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("workcenter_not_found", "Workcenter not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Workcenter updated successfully", "data": response})
@@ -86,9 +119,108 @@ func (h *Handler) Update(c *gin.Context) {
 func (h *Handler) Delete(c *gin.Context) {
 	ctx := c.Request.Context()
 	id := c.Param("id")
-	if err := h.service.Delete(ctx, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.Delete(ctx, id, trash.Confirmed(c.Query("confirm"))); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("workcenter_not_found", "Workcenter not found"))
+			return
+		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Workcenter deleted successfully"})
 }
+
+func (h *Handler) Trash(c *gin.Context) {
+	ctx := c.Request.Context()
+	params, err := listing.Parse(c, trashSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response, total, err := h.service.Trash(ctx, c.Query("customer_id"), params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted workcenters found successfully", "data": response, "meta": params.Page(total)})
+}
+
+func (h *Handler) Restore(c *gin.Context) {
+	ctx := c.Request.Context()
+	response, err := h.service.Restore(ctx, c.Param("id"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("workcenter_not_found", "Workcenter not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Workcenter restored successfully", "data": response})
+}
+
+func (h *Handler) FindCalendar(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	response, err := h.service.FindCalendar(ctx, id, c.Query("from"), c.Query("to"))
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("workcenter_not_found", "Workcenter not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar found successfully", "data": response})
+}
+
+func (h *Handler) SetCalendarDay(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	var request CalendarDayRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperr.Invalid(err))
+		return
+	}
+	response, err := h.service.SetCalendarDay(ctx, id, request)
+	if err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("workcenter_not_found", "Workcenter not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar day saved successfully", "data": response})
+}
+
+func (h *Handler) DeleteCalendarDay(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	if err := h.service.DeleteCalendarDay(ctx, id, c.Param("date")); err != nil {
+		if tenant.IsNotFound(err) {
+			c.Error(apperr.NotFound("workcenter_not_found", "Workcenter not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar day deleted successfully"})
+}
+
+// Utilization returns the load per day, shift and workcenter between from and to (YYYY-MM-DD)
+func (h *Handler) Utilization(c *gin.Context) {
+	ctx := c.Request.Context()
+	filter := UtilizationFilter{
+		CustomerID:  c.Query("customer_id"),
+		ShopFloorID: c.Query("shop_floor_id"),
+		From:        c.Query("from"),
+		To:          c.Query("to"),
+	}
+	response, err := h.service.Utilization(ctx, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Utilization report generated successfully", "data": response})
+}
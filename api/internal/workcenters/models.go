@@ -12,13 +12,87 @@ type Workcenter struct {
 	ShopFloorID uuid.NullUUID `json:"shop_floor_id"`
 	Name      string    `json:"name"`
 	IsActive  bool      `json:"is_active"`
+	HoursPerShift float64 `json:"hours_per_shift"`
+	ParallelSlots int `json:"parallel_slots"`
+	Efficiency float64 `json:"efficiency"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type WorkcenterRequest struct {
-	Name string `json:"name"`	
-	CustomerID string `json:"customer_id"`
-	ShopFloorID string `json:"shop_floor_id"`
+	Name string `json:"name" binding:"required,max=200"`	
+	CustomerID string `json:"customer_id" binding:"omitempty,uuid"`
+	ShopFloorID string `json:"shop_floor_id" binding:"omitempty,uuid"`
 	IsActive bool `json:"is_active"`
+	// Capacity fields are optional; when omitted the current (or default) value is kept
+	HoursPerShift *float64 `json:"hours_per_shift" binding:"omitempty,gte=0,lte=24"`
+	ParallelSlots *int `json:"parallel_slots" binding:"omitempty,gte=1"`
+	Efficiency *float64 `json:"efficiency" binding:"omitempty,gt=0,lte=2"`
+}
+
+// CalendarDay overrides the available hours per shift of a workcenter on a given date
+type CalendarDay struct {
+	WorkcenterID  uuid.UUID `json:"workcenter_id"`
+	Date          string    `json:"date"`
+	HoursPerShift float64   `json:"hours_per_shift"`
+	Note          string    `json:"note"`
+}
+
+type CalendarDayRequest struct {
+	Date          string  `json:"date" binding:"required,datetime=2006-01-02"`
+	HoursPerShift float64 `json:"hours_per_shift" binding:"gte=0,lte=24"`
+	Note          string  `json:"note"`
+}
+
+type UtilizationFilter struct {
+	CustomerID  string
+	ShopFloorID string
+	From        string
+	To          string
+}
+
+// UtilizationCell is the load of one workcenter in one shift of one day
+type UtilizationCell struct {
+	Date             string    `json:"date"`
+	ShiftID          uuid.UUID `json:"shift_id"`
+	ShiftName        string    `json:"shift_name"`
+	WorkcenterID     uuid.UUID `json:"workcenter_id"`
+	WorkcenterName   string    `json:"workcenter_name"`
+	ScheduledMinutes int       `json:"scheduled_minutes"`
+	DowntimeMinutes  int       `json:"downtime_minutes"`
+	AvailableMinutes int       `json:"available_minutes"`
+	Utilization      float64   `json:"utilization"`
+	Overloaded       bool      `json:"overloaded"`
+}
+
+type UtilizationReport struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Cells      []UtilizationCell `json:"cells"`
+	Overloaded int               `json:"overloaded"`
+}
+
+// shiftRef is the part of a shift the utilization report needs
+type shiftRef struct {
+	ID          uuid.UUID
+	ShopFloorID uuid.NullUUID
+	Name        string
+	StartTime   time.Time
+	EndTime     time.Time
+}
+
+// downtimeWindow is an interval in which a workcenter is down
+type downtimeWindow struct {
+	WorkcenterID uuid.UUID
+	Start        time.Time
+	End          time.Time
+}
+
+// scheduledLoad is the scheduled minutes of a workcenter in a shift of a day
+type scheduledLoad struct {
+	Date         string
+	ShiftID      uuid.UUID
+	WorkcenterID uuid.UUID
+	Minutes      int
 }
\ No newline at end of file
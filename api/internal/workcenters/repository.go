@@ -1,20 +1,62 @@
 package workcenters
 
 import (
+	"api/internal/listing"
+	"api/internal/shopfloors"
+	"api/internal/trash"
 	"context"
 	"database/sql"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// listSpec is what the workcenter list can be sorted, filtered and searched on
+var listSpec = listing.Spec{
+	Sort: map[string]string{
+		"name":            "name",
+		"is_active":       "is_active",
+		"hours_per_shift": "hours_per_shift",
+		"parallel_slots":  "parallel_slots",
+		"created_at":      "created_at",
+	},
+	Filter: map[string]string{
+		"customer_id":   "customer_id",
+		"shop_floor_id": "shop_floor_id",
+		"shopfloor_id":  "shop_floor_id",
+		"is_active":     "is_active",
+	},
+	Search:  []string{"name"},
+	Default: []listing.Order{{Field: "name"}},
+}
+
+// trashSpec is what the trash can be sorted and filtered on
+var trashSpec = trash.Spec(listSpec)
+
 type Repository interface {
 	Create(ctx context.Context, workcenter Workcenter)(Workcenter, error)
 	FindAll(ctx context.Context) ([]Workcenter, error)
+	List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Workcenter, int, error)
 	FindByID(ctx context.Context, id uuid.UUID) (Workcenter, error)
 	FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]Workcenter, error)
+	FindByShopFloorID(ctx context.Context, shopFloorID uuid.UUID, customerID *uuid.UUID, params listing.Params) ([]Workcenter, int, error)
+	FindShopfloorTree(ctx context.Context, customerID *uuid.UUID) (shopfloors.Tree, error)
 	CountByCustomerID(ctx context.Context, customerID uuid.UUID) (int, error)
+	CountJobsByCustomerID(ctx context.Context, customerID uuid.UUID) (int, error)
 	Update(ctx context.Context, workcenter Workcenter) (Workcenter, error)
-	Delete(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID, confirm func(trash.Preview) error) error
+	FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Workcenter, int, error)
+	FindDeletedByID(ctx context.Context, id uuid.UUID) (Workcenter, error)
+	Restore(ctx context.Context, id uuid.UUID, check func(restored trash.Dependents) error) error
+	FindCalendar(ctx context.Context, workcenterID uuid.UUID, from, to time.Time) ([]CalendarDay, error)
+	FindCalendarByCustomerID(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]CalendarDay, error)
+	UpsertCalendarDay(ctx context.Context, day CalendarDay) (CalendarDay, error)
+	DeleteCalendarDay(ctx context.Context, workcenterID uuid.UUID, date time.Time) error
+	FindActiveShifts(ctx context.Context, customerID *uuid.UUID) ([]shiftRef, error)
+	FindScheduledLoad(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]scheduledLoad, error)
+	FindDowntimes(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]downtimeWindow, error)
+	FindShopFloorCustomerID(ctx context.Context, shopFloorID uuid.UUID) (uuid.UUID, error)
 }
 
 type repository struct {
@@ -26,8 +68,8 @@ func NewRepository(db *sql.DB) Repository {
 }
 
 func (r *repository) Create(ctx context.Context, workcenter Workcenter)(Workcenter, error) {
-	query := `INSERT INTO workcenters (id, customer_id, shop_floor_id, name, is_active, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
-	_, err := r.db.ExecContext(ctx, query, workcenter.ID, workcenter.CustomerID, workcenter.ShopFloorID, workcenter.Name, workcenter.IsActive, workcenter.CreatedAt, workcenter.UpdatedAt)
+	query := `INSERT INTO workcenters (id, customer_id, shop_floor_id, name, is_active, hours_per_shift, parallel_slots, efficiency, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+	_, err := r.db.ExecContext(ctx, query, workcenter.ID, workcenter.CustomerID, workcenter.ShopFloorID, workcenter.Name, workcenter.IsActive, workcenter.HoursPerShift, workcenter.ParallelSlots, workcenter.Efficiency, workcenter.CreatedAt, workcenter.UpdatedAt)
 	if err != nil {
 		return Workcenter{}, err
 	}
@@ -35,7 +77,7 @@ func (r *repository) Create(ctx context.Context, workcenter Workcenter)(Workcent
 }
 
 func (r *repository) FindAll(ctx context.Context) ([]Workcenter, error) {
-	query := `SELECT id, customer_id, shop_floor_id, name, is_active, created_at, updated_at FROM workcenters`
+	query := `SELECT id, customer_id, shop_floor_id, name, is_active, hours_per_shift, parallel_slots, efficiency, created_at, updated_at FROM workcenters WHERE deleted_at IS NULL`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -44,7 +86,7 @@ func (r *repository) FindAll(ctx context.Context) ([]Workcenter, error) {
 	var workcenters []Workcenter
 	for rows.Next() {
 		var workcenter Workcenter
-		if err := rows.Scan(&workcenter.ID, &workcenter.CustomerID, &workcenter.ShopFloorID, &workcenter.Name, &workcenter.IsActive, &workcenter.CreatedAt, &workcenter.UpdatedAt); err != nil {
+		if err := rows.Scan(&workcenter.ID, &workcenter.CustomerID, &workcenter.ShopFloorID, &workcenter.Name, &workcenter.IsActive, &workcenter.HoursPerShift, &workcenter.ParallelSlots, &workcenter.Efficiency, &workcenter.CreatedAt, &workcenter.UpdatedAt); err != nil {
 			return nil, err
 		}
 		workcenters = append(workcenters, workcenter)
@@ -52,19 +94,45 @@ func (r *repository) FindAll(ctx context.Context) ([]Workcenter, error) {
 	return workcenters, nil
 }
 
+// List returns a page of the workcenters of customerID, or of every customer when nil
+func (r *repository) List(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Workcenter, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return r.findPage(ctx, q)
+}
+
+// findPage runs a list query on the workcenters the user can see
+func (r *repository) findPage(ctx context.Context, q *listing.Query) ([]Workcenter, int, error) {
+	// Users restricted to some shop floors only see their workcenters
+	shopfloors.Restrict(ctx, q, "shop_floor_id")
+	return listing.Find(ctx, r.db, q, `SELECT id, customer_id, shop_floor_id, name, is_active, hours_per_shift, parallel_slots, efficiency, created_at, updated_at FROM workcenters`, func(rows *sql.Rows) (Workcenter, error) {
+		var workcenter Workcenter
+		err := rows.Scan(&workcenter.ID, &workcenter.CustomerID, &workcenter.ShopFloorID, &workcenter.Name, &workcenter.IsActive, &workcenter.HoursPerShift, &workcenter.ParallelSlots, &workcenter.Efficiency, &workcenter.CreatedAt, &workcenter.UpdatedAt)
+		return workcenter, err
+	})
+}
+
 func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (Workcenter, error) {
-	query := `SELECT id, customer_id, shop_floor_id, name, is_active, created_at, updated_at FROM workcenters WHERE id = $1`
+	query := `SELECT id, customer_id, shop_floor_id, name, is_active, hours_per_shift, parallel_slots, efficiency, created_at, updated_at FROM workcenters WHERE id = $1 AND deleted_at IS NULL`
 	row := r.db.QueryRowContext(ctx, query, id)
 	var workcenter Workcenter
-	if err := row.Scan(&workcenter.ID, &workcenter.CustomerID, &workcenter.ShopFloorID, &workcenter.Name, &workcenter.IsActive, &workcenter.CreatedAt, &workcenter.UpdatedAt); err != nil {
+	if err := row.Scan(&workcenter.ID, &workcenter.CustomerID, &workcenter.ShopFloorID, &workcenter.Name, &workcenter.IsActive, &workcenter.HoursPerShift, &workcenter.ParallelSlots, &workcenter.Efficiency, &workcenter.CreatedAt, &workcenter.UpdatedAt); err != nil {
 		return Workcenter{}, err
 	}
 	return workcenter, nil
 }
 
 func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]Workcenter, error) {
-	query := `SELECT id, customer_id, shop_floor_id, name, is_active, created_at, updated_at FROM workcenters WHERE customer_id = $1`
-	rows, err := r.db.QueryContext(ctx, query, customerID)
+	query := `SELECT id, customer_id, shop_floor_id, name, is_active, hours_per_shift, parallel_slots, efficiency, created_at, updated_at FROM workcenters WHERE customer_id = $1 AND deleted_at IS NULL`
+	args := []interface{}{customerID}
+	// Users restricted to some shop floors only see their workcenters
+	condition, scopeArgs := shopfloors.ScopeCondition(ctx, "shop_floor_id", 2)
+	query += condition
+	args = append(args, scopeArgs...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +140,7 @@ func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID)
 	var workcenters []Workcenter
 	for rows.Next() {
 		var workcenter Workcenter
-		if err := rows.Scan(&workcenter.ID, &workcenter.CustomerID, &workcenter.ShopFloorID, &workcenter.Name, &workcenter.IsActive, &workcenter.CreatedAt, &workcenter.UpdatedAt); err != nil {
+		if err := rows.Scan(&workcenter.ID, &workcenter.CustomerID, &workcenter.ShopFloorID, &workcenter.Name, &workcenter.IsActive, &workcenter.HoursPerShift, &workcenter.ParallelSlots, &workcenter.Efficiency, &workcenter.CreatedAt, &workcenter.UpdatedAt); err != nil {
 			return nil, err
 		}
 		workcenters = append(workcenters, workcenter)
@@ -80,8 +148,54 @@ func (r *repository) FindByCustomerID(ctx context.Context, customerID uuid.UUID)
 	return workcenters, nil
 }
 
+// FindByShopFloorID returns a page of the workcenters of the shop floor and of every shop floor below it
+func (r *repository) FindByShopFloorID(ctx context.Context, shopFloorID uuid.UUID, customerID *uuid.UUID, params listing.Params) ([]Workcenter, int, error) {
+	q := listing.NewQuery(listSpec, params)
+	q.Where("deleted_at IS NULL")
+	shopfloors.InSubtree(q, "shop_floor_id", shopFloorID)
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return r.findPage(ctx, q)
+}
+
+// FindShopfloorTree returns the parent of every shop floor of the tenant
+func (r *repository) FindShopfloorTree(ctx context.Context, customerID *uuid.UUID) (shopfloors.Tree, error) {
+	query := `SELECT id, parent_id FROM shopfloors WHERE deleted_at IS NULL AND ($1::uuid IS NULL OR customer_id = $1)`
+	var tenant uuid.NullUUID
+	if customerID != nil {
+		tenant = uuid.NullUUID{UUID: *customerID, Valid: true}
+	}
+	rows, err := r.db.QueryContext(ctx, query, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tree := shopfloors.Tree{}
+	for rows.Next() {
+		var id uuid.UUID
+		var parentID uuid.NullUUID
+		if err := rows.Scan(&id, &parentID); err != nil {
+			return nil, err
+		}
+		tree[id] = parentID
+	}
+	return tree, nil
+}
+
+// CountJobsByCustomerID counts the jobs of the tenant, for the quota of the
+// jobs restored along with a workcenter
+func (r *repository) CountJobsByCustomerID(ctx context.Context, customerID uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM jobs WHERE customer_id = $1 AND deleted_at IS NULL`
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, customerID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func (r *repository) CountByCustomerID(ctx context.Context, customerID uuid.UUID) (int, error) {
-	query := `SELECT COUNT(*) FROM workcenters WHERE customer_id = $1`
+	query := `SELECT COUNT(*) FROM workcenters WHERE customer_id = $1 AND deleted_at IS NULL`
 	var count int
 	err := r.db.QueryRowContext(ctx, query, customerID).Scan(&count)
 	if err != nil {
@@ -90,20 +204,286 @@ func (r *repository) CountByCustomerID(ctx context.Context, customerID uuid.UUID
 	return count, nil
 }
 
+// FindShopFloorCustomerID returns the tenant of a shop floor
+func (r *repository) FindShopFloorCustomerID(ctx context.Context, shopFloorID uuid.UUID) (uuid.UUID, error) {
+	var customerID uuid.UUID
+	err := r.db.QueryRowContext(ctx, `SELECT customer_id FROM shopfloors WHERE id = $1 AND deleted_at IS NULL`, shopFloorID).Scan(&customerID)
+	return customerID, err
+}
+
 func (r *repository) Update(ctx context.Context, workcenter Workcenter) (Workcenter, error) {
-	query := `UPDATE workcenters SET customer_id = $2, shop_floor_id = $3, name = $4, is_active = $5, created_at = $6, updated_at = $7 WHERE id = $1 RETURNING id`
-	_, err := r.db.ExecContext(ctx, query, workcenter.ID, workcenter.CustomerID, workcenter.ShopFloorID, workcenter.Name, workcenter.IsActive, workcenter.CreatedAt, workcenter.UpdatedAt)
+	query := `UPDATE workcenters SET customer_id = $2, shop_floor_id = $3, name = $4, is_active = $5, hours_per_shift = $6, parallel_slots = $7, efficiency = $8, created_at = $9, updated_at = $10 WHERE id = $1 RETURNING id`
+	_, err := r.db.ExecContext(ctx, query, workcenter.ID, workcenter.CustomerID, workcenter.ShopFloorID, workcenter.Name, workcenter.IsActive, workcenter.HoursPerShift, workcenter.ParallelSlots, workcenter.Efficiency, workcenter.CreatedAt, workcenter.UpdatedAt)
 	if err != nil {
 		return Workcenter{}, err
 	}
 	return workcenter, nil
 }
 
-func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM workcenters WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+// deleteSteps move the records that depend on a workcenter to the trash
+// along with it: its jobs, the schedule entries of the workcenter or of those
+// jobs, and its time entries
+var deleteSteps = []trash.Step{
+	{Kind: "jobs", Query: `UPDATE jobs SET deleted_at = $2 WHERE workcenter_id = $1 AND deleted_at IS NULL`},
+	{Kind: "schedule_entries", Query: `UPDATE schedule_entries SET deleted_at = $2 WHERE deleted_at IS NULL
+	AND (workcenter_id = $1 OR job_id IN (SELECT id FROM jobs WHERE workcenter_id = $1 AND deleted_at = $2))`},
+	{Kind: "time_entries", Query: `UPDATE time_entries SET deleted_at = $2 WHERE workcenter_id = $1 AND deleted_at IS NULL`},
+}
+
+// restoreSteps take the records deleted along with a workcenter out of the trash
+var restoreSteps = []trash.Step{
+	{Kind: "jobs", Query: `UPDATE jobs SET deleted_at = NULL WHERE workcenter_id = $1 AND deleted_at = $2
+	AND ` + trash.JobParentsLive},
+	{Kind: "schedule_entries", Query: `UPDATE schedule_entries SET deleted_at = NULL WHERE deleted_at = $2
+	AND (workcenter_id = $1 OR job_id IN (SELECT id FROM jobs WHERE workcenter_id = $1))
+	AND ` + trash.ScheduleEntryParentsLive},
+	{Kind: "time_entries", Query: `UPDATE time_entries SET deleted_at = NULL WHERE workcenter_id = $1 AND deleted_at = $2
+	AND ` + trash.TimeEntryParentsLive},
+}
+
+// Delete moves the workcenter and the records that depend on it to the
+// trash, marked with the same time so they are restored together. Its
+// downtimes and production counts are kept. confirm gets the preview before
+// anything is committed and cancels the deletion when it fails.
+func (r *repository) Delete(ctx context.Context, id uuid.UUID, confirm func(trash.Preview) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	var preview trash.Preview
+	query := `SELECT
+		(SELECT COUNT(*) FROM downtimes WHERE workcenter_id = $1),
+		(SELECT COUNT(*) FROM production_counts WHERE workcenter_id = $1)`
+	var downtimes, productionCounts int
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&downtimes, &productionCounts); err != nil {
+		return err
+	}
+	preview.Kept = trash.Dependents{"downtimes": downtimes, "production_counts": productionCounts}
+
+	var deletedAt time.Time
+	query = `UPDATE workcenters SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL RETURNING deleted_at`
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&deletedAt); err != nil {
+		return err
+	}
+	if preview.Deleted, err = trash.Cascade(ctx, tx, deleteSteps, id, deletedAt); err != nil {
+		return err
+	}
+	if err := confirm(preview); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindDeleted returns a page of the workcenters in the trash of the tenant,
+// or of every tenant when customerID is nil
+func (r *repository) FindDeleted(ctx context.Context, customerID *uuid.UUID, params listing.Params) ([]Workcenter, int, error) {
+	q := listing.NewQuery(trashSpec, params)
+	q.Where("deleted_at IS NOT NULL")
+	if customerID != nil {
+		q.Where("customer_id = ?", *customerID)
+	}
+	return listing.Find(ctx, r.db, q, `SELECT id, customer_id, shop_floor_id, name, is_active, hours_per_shift, parallel_slots, efficiency, created_at, updated_at, deleted_at FROM workcenters`, func(rows *sql.Rows) (Workcenter, error) {
+		var workcenter Workcenter
+		err := rows.Scan(&workcenter.ID, &workcenter.CustomerID, &workcenter.ShopFloorID, &workcenter.Name, &workcenter.IsActive, &workcenter.HoursPerShift, &workcenter.ParallelSlots, &workcenter.Efficiency, &workcenter.CreatedAt, &workcenter.UpdatedAt, &workcenter.DeletedAt)
+		return workcenter, err
+	})
+}
+
+func (r *repository) FindDeletedByID(ctx context.Context, id uuid.UUID) (Workcenter, error) {
+	query := `SELECT id, customer_id, shop_floor_id, name, is_active, hours_per_shift, parallel_slots, efficiency, created_at, updated_at, deleted_at
+	FROM workcenters WHERE id = $1 AND deleted_at IS NOT NULL`
+	var workcenter Workcenter
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&workcenter.ID, &workcenter.CustomerID, &workcenter.ShopFloorID, &workcenter.Name, &workcenter.IsActive, &workcenter.HoursPerShift, &workcenter.ParallelSlots, &workcenter.Efficiency, &workcenter.CreatedAt, &workcenter.UpdatedAt, &workcenter.DeletedAt)
+	if err != nil {
+		return Workcenter{}, err
+	}
+	return workcenter, nil
+}
+
+// Restore takes the workcenter out of the trash together with the records
+// deleted with it, unless its shop floor is still in the trash. check gets
+// the counts of the records restored before anything is committed and
+// cancels the restore when it fails.
+func (r *repository) Restore(ctx context.Context, id uuid.UUID, check func(restored trash.Dependents) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	query := `SELECT deleted_at FROM workcenters WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&deletedAt); err != nil {
+		return err
+	}
+	query = `UPDATE workcenters SET deleted_at = NULL WHERE id = $1
+	AND NOT EXISTS (SELECT 1 FROM shopfloors WHERE id = workcenters.shop_floor_id AND deleted_at IS NOT NULL)`
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return trash.ErrParentDeleted
+	}
+	restored, err := trash.Cascade(ctx, tx, restoreSteps, id, deletedAt)
+	if err != nil {
+		return err
+	}
+	if err := check(restored); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) FindCalendar(ctx context.Context, workcenterID uuid.UUID, from, to time.Time) ([]CalendarDay, error) {
+	query := `SELECT workcenter_id, to_char(date, 'YYYY-MM-DD'), hours_per_shift, note FROM workcenter_calendar
+	WHERE workcenter_id = $1 AND date BETWEEN $2 AND $3 ORDER BY date`
+	rows, err := r.db.QueryContext(ctx, query, workcenterID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var days []CalendarDay
+	for rows.Next() {
+		var day CalendarDay
+		if err := rows.Scan(&day.WorkcenterID, &day.Date, &day.HoursPerShift, &day.Note); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func (r *repository) FindCalendarByCustomerID(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]CalendarDay, error) {
+	query := `SELECT c.workcenter_id, to_char(c.date, 'YYYY-MM-DD'), c.hours_per_shift, c.note
+	FROM workcenter_calendar c
+	JOIN workcenters w ON w.id = c.workcenter_id
+	WHERE c.date BETWEEN $1 AND $2`
+	args := []interface{}{from, to}
+	if customerID != nil {
+		query += " AND w.customer_id = $3"
+		args = append(args, *customerID)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var days []CalendarDay
+	for rows.Next() {
+		var day CalendarDay
+		if err := rows.Scan(&day.WorkcenterID, &day.Date, &day.HoursPerShift, &day.Note); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func (r *repository) UpsertCalendarDay(ctx context.Context, day CalendarDay) (CalendarDay, error) {
+	query := `INSERT INTO workcenter_calendar (workcenter_id, date, hours_per_shift, note) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (workcenter_id, date) DO UPDATE SET hours_per_shift = EXCLUDED.hours_per_shift, note = EXCLUDED.note, updated_at = NOW()`
+	_, err := r.db.ExecContext(ctx, query, day.WorkcenterID, day.Date, day.HoursPerShift, day.Note)
+	if err != nil {
+		return CalendarDay{}, err
+	}
+	return day, nil
+}
+
+func (r *repository) DeleteCalendarDay(ctx context.Context, workcenterID uuid.UUID, date time.Time) error {
+	query := `DELETE FROM workcenter_calendar WHERE workcenter_id = $1 AND date = $2`
+	_, err := r.db.ExecContext(ctx, query, workcenterID, date)
+	return err
+}
+
+func (r *repository) FindActiveShifts(ctx context.Context, customerID *uuid.UUID) ([]shiftRef, error) {
+	query := `SELECT id, shopfloor_id, name, start_time, end_time FROM shifts WHERE is_active = TRUE AND deleted_at IS NULL`
+	var args []interface{}
+	if customerID != nil {
+		query += " AND customer_id = $1"
+		args = append(args, *customerID)
+	}
+	query += " ORDER BY start_time"
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var shifts []shiftRef
+	for rows.Next() {
+		var shift shiftRef
+		if err := rows.Scan(&shift.ID, &shift.ShopFloorID, &shift.Name, &shift.StartTime, &shift.EndTime); err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, shift)
+	}
+	return shifts, nil
+}
+
+// FindScheduledLoad sums the planned minutes per day, shift and workcenter.
+// Entries with explicit start and end times count their real span, the rest
+// count the estimated duration of their job.
+func (r *repository) FindScheduledLoad(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]scheduledLoad, error) {
+	query := `SELECT to_char(se.date, 'YYYY-MM-DD') AS day, se.shift_id, se.workcenter_id,
+		COALESCE(SUM(CASE
+			WHEN se.start_time IS NOT NULL AND se.end_time IS NOT NULL AND se.end_time > se.start_time
+				THEN EXTRACT(EPOCH FROM (se.end_time - se.start_time)) / 60
+			ELSE COALESCE(j.estimated_duration, 0)
+		END), 0)::INT AS minutes
+	FROM schedule_entries se
+	LEFT JOIN jobs j ON j.id = se.job_id
+	WHERE se.date >= $1 AND se.date < $2 AND se.deleted_at IS NULL`
+	args := []interface{}{from, to.AddDate(0, 0, 1)}
+	if customerID != nil {
+		query += fmt.Sprintf(" AND se.customer_id = $%d", len(args)+1)
+		args = append(args, *customerID)
+	}
+	query += " GROUP BY day, se.shift_id, se.workcenter_id"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var loads []scheduledLoad
+	for rows.Next() {
+		var load scheduledLoad
+		if err := rows.Scan(&load.Date, &load.ShiftID, &load.WorkcenterID, &load.Minutes); err != nil {
+			return nil, err
+		}
+		loads = append(loads, load)
+	}
+	return loads, nil
+}
+
+// FindDowntimes returns the downtime intervals overlapping [from, to].
+// Downtimes that are still open end at to.
+func (r *repository) FindDowntimes(ctx context.Context, customerID *uuid.UUID, from, to time.Time) ([]downtimeWindow, error) {
+	query := `SELECT workcenter_id, start_time, COALESCE(end_time, $2) FROM downtimes
+	WHERE start_time < $2 AND (end_time IS NULL OR end_time > $1)`
+	args := []interface{}{from, to}
+	if customerID != nil {
+		query += " AND customer_id = $3"
+		args = append(args, *customerID)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var windows []downtimeWindow
+	for rows.Next() {
+		var window downtimeWindow
+		if err := rows.Scan(&window.WorkcenterID, &window.Start, &window.End); err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
 }
@@ -5,8 +5,15 @@ import "github.com/gin-gonic/gin"
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/workcenters", handler.Create)
 	router.GET("/workcenters", handler.FindAll)
+	router.GET("/workcenters/utilization", handler.Utilization)
+	router.GET("/workcenters/trash", handler.Trash)
 	router.GET("/workcenters/:id", handler.FindByID)
 	router.GET("/workcenters/customer/:customerID", handler.FindByCustomerID)
+	router.GET("/workcenters/shopfloor/:shopFloorID", handler.FindByShopFloorID)
 	router.PUT("/workcenters/:id", handler.Update)
 	router.DELETE("/workcenters/:id", handler.Delete)
+	router.POST("/workcenters/:id/restore", handler.Restore)
+	router.GET("/workcenters/:id/calendar", handler.FindCalendar)
+	router.PUT("/workcenters/:id/calendar", handler.SetCalendarDay)
+	router.DELETE("/workcenters/:id/calendar/:date", handler.DeleteCalendarDay)
 }
\ No newline at end of file
@@ -1,9 +1,18 @@
 package workcenters
 
 import (
+	"api/internal/apperr"
+	"api/internal/audit"
 	"api/internal/customers"
+	"api/internal/listing"
+	"api/internal/shifts"
+	"api/internal/shopfloors"
+	"api/internal/tenant"
+	"api/internal/trash"
 	"context"
+	"database/sql"
 	"errors"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,49 +20,43 @@ import (
 
 type Service interface {
 	Create(ctx context.Context, request WorkcenterRequest) (Workcenter, error)
-	FindAll(ctx context.Context) ([]Workcenter, error)
+	List(ctx context.Context, params listing.Params) ([]Workcenter, int, error)
 	FindByID(ctx context.Context, id string) (Workcenter, error)
-	FindByCustomerID(ctx context.Context, customerID string) ([]Workcenter, error)
+	FindByCustomerID(ctx context.Context, customerID string, params listing.Params) ([]Workcenter, int, error)
+	FindByShopFloorID(ctx context.Context, shopFloorID string, params listing.Params) ([]Workcenter, int, error)
 	Update(ctx context.Context, id string, request WorkcenterRequest) (Workcenter, error)
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string, confirmed bool) error
+	Trash(ctx context.Context, customerID string, params listing.Params) ([]Workcenter, int, error)
+	Restore(ctx context.Context, id string) (Workcenter, error)
+	FindCalendar(ctx context.Context, id string, from string, to string) ([]CalendarDay, error)
+	SetCalendarDay(ctx context.Context, id string, request CalendarDayRequest) (CalendarDay, error)
+	DeleteCalendarDay(ctx context.Context, id string, date string) error
+	Utilization(ctx context.Context, filter UtilizationFilter) (UtilizationReport, error)
 }
 
+const (
+	DefaultHoursPerShift = 8.0
+	// MaxUtilizationDays caps the range of the utilization report
+	MaxUtilizationDays = 93
+	dateLayout         = "2006-01-02"
+)
+
 type service struct {
 	repo Repository
 	customerService customers.Service
+	audit audit.Recorder
 }
 
-func NewService(repo Repository, customerService customers.Service) Service {
-	return &service{repo: repo, customerService: customerService}
+func NewService(repo Repository, customerService customers.Service, recorder audit.Recorder) Service {
+	return &service{repo: repo, customerService: customerService, audit: recorder}
 }
 
 func (s *service) Create(ctx context.Context, request WorkcenterRequest) (Workcenter, error) {
-	var customerID uuid.UUID
-	
-	// Recuperar i validar is_admin
-	isAdminVal := ctx.Value("is_admin")
-	isAdmin, ok := isAdminVal.(bool)
-	if !ok {
-		return Workcenter{}, errors.New("invalid or missing is_admin in context")
-	}
-	
-	if isAdmin {
-		// Si és admin, usar el customerID del request
-		parsedCustomerID, err := uuid.Parse(request.CustomerID)
-		if err != nil {
-			return Workcenter{}, err
-		}
-		customerID = parsedCustomerID
-	} else {
-		// Si no és admin, usar el customerID del context
-		customerIDVal := ctx.Value("customer_id")
-		customerIDFromCtx, ok := customerIDVal.(uuid.UUID)
-		if !ok {
-			return Workcenter{}, errors.New("invalid or missing customer_id in context")
-		}
-		customerID = customerIDFromCtx
+	customerID, err := tenant.Customer(ctx, request.CustomerID)
+	if err != nil {
+		return Workcenter{}, err
 	}
-	
+
 	// Check limits
 	customer, err := s.customerService.FindByID(ctx, customerID.String())
 	if err != nil {
@@ -66,7 +69,7 @@ func (s *service) Create(ctx context.Context, request WorkcenterRequest) (Workce
 	}
 
 	if count >= customer.MaxWorkcenters {
-		return Workcenter{}, errors.New("max workcenters limit reached for this tenant")
+		return Workcenter{}, apperr.QuotaExceeded("max_workcenters", "max workcenters limit reached for this tenant")
 	}
 
 	var shopFloorID uuid.NullUUID
@@ -75,6 +78,9 @@ func (s *service) Create(ctx context.Context, request WorkcenterRequest) (Workce
 			shopFloorID = uuid.NullUUID{UUID: id, Valid: true}
 		}
 	}
+	if err := s.checkShopFloor(ctx, shopFloorID, customerID); err != nil {
+		return Workcenter{}, err
+	}
 
 	workcenter := Workcenter{
 		ID:        uuid.New(),		
@@ -82,55 +88,87 @@ func (s *service) Create(ctx context.Context, request WorkcenterRequest) (Workce
 		ShopFloorID: shopFloorID,
 		Name:      request.Name,
 		IsActive:  request.IsActive,
+		HoursPerShift: DefaultHoursPerShift,
+		ParallelSlots: 1,
+		Efficiency: 1,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
-	return s.repo.Create(ctx, workcenter)
+	if err := applyCapacity(&workcenter, request); err != nil {
+		return Workcenter{}, err
+	}
+	created, err := s.repo.Create(ctx, workcenter)
+	if err != nil {
+		return Workcenter{}, err
+	}
+	s.audit.Created(ctx, audit.EntityWorkcenter, created.ID, created.CustomerID, created)
+	return created, nil
 }
 
-func (s *service) FindAll(ctx context.Context) ([]Workcenter, error) {
-	isAdminVal := ctx.Value("is_admin")
-	isAdmin, ok := isAdminVal.(bool)
-	if !ok {
-		return nil, errors.New("invalid or missing is_admin in context")
-	}
-	if isAdmin{
-		return s.repo.FindAll(ctx)
+// List returns a page of the workcenters of the tenant, every tenant for admins
+func (s *service) List(ctx context.Context, params listing.Params) ([]Workcenter, int, error) {
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, 0, err
 	}
-	customerIDVal := ctx.Value("customer_id")
-		customerIDFromCtx, ok := customerIDVal.(uuid.UUID)
-		if !ok {
-			return nil, errors.New("invalid or missing customer_id in context")
-		}
-		customerID := customerIDFromCtx
-	return s.repo.FindByCustomerID(ctx, customerID)
+	return s.repo.List(ctx, scope, params)
 }
 
-func (s *service) FindByID(ctx context.Context, id string) (Workcenter, error) {
-	parsedId, err := uuid.Parse(id)
+// FindByShopFloorID returns the workcenters of a shop floor, including the
+// ones attached to areas and lines below it
+func (s *service) FindByShopFloorID(ctx context.Context, shopFloorID string, params listing.Params) ([]Workcenter, int, error) {
+	parsedID, err := uuid.Parse(shopFloorID)
 	if err != nil {
-		return Workcenter{}, err
+		return nil, 0, err
 	}
-	return s.repo.FindByID(ctx, parsedId)
+	scope, err := tenant.Scope(ctx, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repo.FindByShopFloorID(ctx, parsedID, scope, params)
 }
 
-func (s *service) FindByCustomerID(ctx context.Context, customerID string) ([]Workcenter, error) {
-	parsedCustomerID, err := uuid.Parse(customerID)
+func (s *service) FindByID(ctx context.Context, id string) (Workcenter, error) {
+	return s.findOwned(ctx, id)
+}
+
+func (s *service) FindByCustomerID(ctx context.Context, customerID string, params listing.Params) ([]Workcenter, int, error) {
+	parsedCustomerID, err := tenant.Customer(ctx, customerID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}	
-	return s.repo.FindByCustomerID(ctx, parsedCustomerID)
+	return s.repo.List(ctx, &parsedCustomerID, params)
 }
 
-func (s *service) Update(ctx context.Context, id string, request WorkcenterRequest) (Workcenter, error) {
-	parsedId, err := uuid.Parse(id)
+// checkShopFloor checks that a shop floor exists and belongs to customerID.
+// Shop floors out of the reach of the user look missing, and users restricted
+// to some shop floors can't leave a workcenter unassigned.
+func (s *service) checkShopFloor(ctx context.Context, shopFloorID uuid.NullUUID, customerID uuid.UUID) error {
+	if !shopFloorID.Valid {
+		if _, restricted := shopfloors.Allowed(ctx); restricted {
+			return apperr.Field("shop_floor_id", "shopfloor_required", "shop floor is required")
+		}
+		return nil
+	}
+	owner, err := s.repo.FindShopFloorCustomerID(ctx, shopFloorID.UUID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !shopfloors.Permits(ctx, shopFloorID.UUID)) {
+		return apperr.Field("shop_floor_id", "unknown_shopfloor", "shop floor not found")
+	}
 	if err != nil {
-		return Workcenter{}, err
+		return err
 	}
-	workcenter, err := s.repo.FindByID(ctx, parsedId)
+	if owner != customerID {
+		return apperr.Field("shop_floor_id", "foreign_shopfloor", "shop floor belongs to another customer")
+	}
+	return nil
+}
+
+func (s *service) Update(ctx context.Context, id string, request WorkcenterRequest) (Workcenter, error) {
+	workcenter, err := s.findOwned(ctx, id)
 	if err != nil {
 		return Workcenter{}, err
 	}
+	before := workcenter
 	workcenter.Name = request.Name
 	
 	if request.ShopFloorID != "" {
@@ -141,16 +179,381 @@ func (s *service) Update(ctx context.Context, id string, request WorkcenterReque
 		// If empty, set to NULL (allowing unassignment)
 		workcenter.ShopFloorID = uuid.NullUUID{Valid: false}
 	}
+	if err := s.checkShopFloor(ctx, workcenter.ShopFloorID, workcenter.CustomerID); err != nil {
+		return Workcenter{}, err
+	}
 
 	workcenter.IsActive = request.IsActive
+	if err := applyCapacity(&workcenter, request); err != nil {
+		return Workcenter{}, err
+	}
 	workcenter.UpdatedAt = time.Now()
-	return s.repo.Update(ctx, workcenter)
+	updated, err := s.repo.Update(ctx, workcenter)
+	if err != nil {
+		return Workcenter{}, err
+	}
+	s.audit.Updated(ctx, audit.EntityWorkcenter, updated.ID, updated.CustomerID, before, updated)
+	return updated, nil
 }
 
-func (s *service) Delete(ctx context.Context, id string) error {
+// Delete moves the workcenter with its jobs, schedule and time entries to the
+// trash. Workcenters with jobs, planning or production history are only
+// deleted once confirmed.
+func (s *service) Delete(ctx context.Context, id string, confirmed bool) error {
+	workcenter, err := s.findOwned(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = s.repo.Delete(ctx, workcenter.ID, func(preview trash.Preview) error {
+		return trash.Confirm(preview, confirmed)
+	})
+	if err != nil {
+		return err
+	}
+	s.audit.Deleted(ctx, audit.EntityWorkcenter, workcenter.ID, workcenter.CustomerID, workcenter)
+	return nil
+}
+
+// Trash returns the deleted workcenters of the tenant
+func (s *service) Trash(ctx context.Context, customerID string, params listing.Params) ([]Workcenter, int, error) {
+	scope, err := tenant.Scope(ctx, customerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repo.FindDeleted(ctx, scope, params)
+}
+
+// Restore takes the workcenter out of the trash with the records deleted
+// along with it, as long as the jobs fit in the quota of the tenant
+func (s *service) Restore(ctx context.Context, id string) (Workcenter, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Workcenter{}, err
+	}
+	workcenter, err := s.repo.FindDeletedByID(ctx, parsedID)
+	if err != nil {
+		return Workcenter{}, err
+	}
+	if err := tenant.Check(ctx, workcenter.CustomerID); err != nil {
+		return Workcenter{}, err
+	}
+	if err := shopfloors.Check(ctx, workcenter.ShopFloorID.UUID); err != nil {
+		return Workcenter{}, err
+	}
+	customer, err := s.customerService.FindByID(ctx, workcenter.CustomerID.String())
+	if err != nil {
+		return Workcenter{}, err
+	}
+	count, err := s.repo.CountByCustomerID(ctx, workcenter.CustomerID)
+	if err != nil {
+		return Workcenter{}, err
+	}
+	if count >= customer.MaxWorkcenters {
+		return Workcenter{}, apperr.QuotaExceeded("max_workcenters", "max workcenters limit reached for this tenant")
+	}
+	jobs, err := s.repo.CountJobsByCustomerID(ctx, workcenter.CustomerID)
+	if err != nil {
+		return Workcenter{}, err
+	}
+	err = s.repo.Restore(ctx, workcenter.ID, func(restored trash.Dependents) error {
+		if jobs+restored["jobs"] > customer.MaxJobs {
+			return apperr.QuotaExceeded("max_jobs", "max jobs limit reached for this customer")
+		}
+		return nil
+	})
+	if err != nil {
+		return Workcenter{}, err
+	}
+	workcenter.DeletedAt = nil
+	s.audit.Restored(ctx, audit.EntityWorkcenter, workcenter.ID, workcenter.CustomerID, workcenter)
+	return workcenter, nil
+}
+
+// applyCapacity copies the capacity fields present in the request and validates the result
+func applyCapacity(workcenter *Workcenter, request WorkcenterRequest) error {
+	if request.HoursPerShift != nil {
+		workcenter.HoursPerShift = *request.HoursPerShift
+	}
+	if request.ParallelSlots != nil {
+		workcenter.ParallelSlots = *request.ParallelSlots
+	}
+	if request.Efficiency != nil {
+		workcenter.Efficiency = *request.Efficiency
+	}
+	if workcenter.HoursPerShift < 0 || workcenter.HoursPerShift > 24 {
+		return apperr.Field("hours_per_shift", "invalid_hours_per_shift", "hours_per_shift must be between 0 and 24")
+	}
+	if workcenter.ParallelSlots < 1 {
+		return apperr.Field("parallel_slots", "invalid_parallel_slots", "parallel_slots must be at least 1")
+	}
+	if workcenter.Efficiency <= 0 || workcenter.Efficiency > 2 {
+		return apperr.Field("efficiency", "invalid_efficiency", "efficiency must be greater than 0 and at most 2")
+	}
+	return nil
+}
+
+// availableMinutes is the effective capacity of a workcenter in one shift,
+// once the minutes the workcenter is down have been taken out
+func availableMinutes(hoursPerShift float64, downtimeMinutes int, workcenter Workcenter) int {
+	minutes := math.Max(hoursPerShift*60-float64(downtimeMinutes), 0)
+	return int(math.Round(minutes * float64(workcenter.ParallelSlots) * workcenter.Efficiency))
+}
+
+// overlapMinutes returns how many minutes of [start, end) are covered by the windows
+func overlapMinutes(start, end time.Time, windows []downtimeWindow) int {
+	var total time.Duration
+	for _, window := range windows {
+		s, e := window.Start, window.End
+		if s.Before(start) {
+			s = start
+		}
+		if e.After(end) {
+			e = end
+		}
+		if e.After(s) {
+			total += e.Sub(s)
+		}
+	}
+	return int(total.Minutes())
+}
+
+// findOwned returns the workcenter if the current user is allowed to manage it.
+// Users restricted to some shop floors don't see the unassigned workcenters.
+func (s *service) findOwned(ctx context.Context, id string) (Workcenter, error) {
 	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return Workcenter{}, err
+	}
+	workcenter, err := s.repo.FindByID(ctx, parsedId)
+	if err != nil {
+		return Workcenter{}, err
+	}
+	if err := tenant.Check(ctx, workcenter.CustomerID); err != nil {
+		return Workcenter{}, err
+	}
+	if err := shopfloors.Check(ctx, workcenter.ShopFloorID.UUID); err != nil {
+		return Workcenter{}, err
+	}
+	return workcenter, nil
+}
+
+// parseRange parses a from/to pair of dates, defaulting to the coming week
+func parseRange(from, to string) (time.Time, time.Time, error) {
+	start := time.Now().Truncate(24 * time.Hour)
+	if from != "" {
+		parsed, err := time.Parse(dateLayout, from)
+		if err != nil {
+			return time.Time{}, time.Time{}, apperr.Validation("invalid_from", "from must be a date (YYYY-MM-DD)")
+		}
+		start = parsed
+	}
+	end := start.AddDate(0, 0, 6)
+	if to != "" {
+		parsed, err := time.Parse(dateLayout, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, apperr.Validation("invalid_to", "to must be a date (YYYY-MM-DD)")
+		}
+		end = parsed
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, apperr.Validation("invalid_range", "to must not be before from")
+	}
+	if end.Sub(start) > MaxUtilizationDays*24*time.Hour {
+		return time.Time{}, time.Time{}, apperr.Validation("range_too_large", "date range is too large")
+	}
+	return start, end, nil
+}
+
+func (s *service) FindCalendar(ctx context.Context, id string, from string, to string) ([]CalendarDay, error) {
+	workcenter, err := s.findOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := parseRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindCalendar(ctx, workcenter.ID, start, end)
+}
+
+func (s *service) SetCalendarDay(ctx context.Context, id string, request CalendarDayRequest) (CalendarDay, error) {
+	workcenter, err := s.findOwned(ctx, id)
+	if err != nil {
+		return CalendarDay{}, err
+	}
+	date, err := time.Parse(dateLayout, request.Date)
+	if err != nil {
+		return CalendarDay{}, apperr.Field("date", "invalid_date", "date must be a date (YYYY-MM-DD)")
+	}
+	if request.HoursPerShift < 0 || request.HoursPerShift > 24 {
+		return CalendarDay{}, apperr.Field("hours_per_shift", "invalid_hours_per_shift", "hours_per_shift must be between 0 and 24")
+	}
+	return s.repo.UpsertCalendarDay(ctx, CalendarDay{
+		WorkcenterID:  workcenter.ID,
+		Date:          date.Format(dateLayout),
+		HoursPerShift: request.HoursPerShift,
+		Note:          request.Note,
+	})
+}
+
+func (s *service) DeleteCalendarDay(ctx context.Context, id string, date string) error {
+	workcenter, err := s.findOwned(ctx, id)
 	if err != nil {
 		return err
 	}
-	return s.repo.Delete(ctx, parsedId)
+	parsedDate, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return apperr.Validation("invalid_date", "date must be a date (YYYY-MM-DD)")
+	}
+	return s.repo.DeleteCalendarDay(ctx, workcenter.ID, parsedDate)
+}
+
+// Utilization compares the scheduled minutes against the available capacity
+// for every day, shift and workcenter in the range. A cell is overloaded when
+// more minutes are scheduled than the workcenter can deliver.
+func (s *service) Utilization(ctx context.Context, filter UtilizationFilter) (UtilizationReport, error) {
+	scope, err := tenant.Scope(ctx, filter.CustomerID)
+	if err != nil {
+		return UtilizationReport{}, err
+	}
+
+	var shopFloorID uuid.NullUUID
+	if filter.ShopFloorID != "" {
+		parsedID, err := uuid.Parse(filter.ShopFloorID)
+		if err != nil {
+			return UtilizationReport{}, err
+		}
+		shopFloorID = uuid.NullUUID{UUID: parsedID, Valid: true}
+	}
+
+	from, to, err := parseRange(filter.From, filter.To)
+	if err != nil {
+		return UtilizationReport{}, err
+	}
+
+	var workcenters []Workcenter
+	if scope == nil {
+		workcenters, err = s.repo.FindAll(ctx)
+	} else {
+		workcenters, err = s.repo.FindByCustomerID(ctx, *scope)
+	}
+	if err != nil {
+		return UtilizationReport{}, err
+	}
+	activeShifts, err := s.repo.FindActiveShifts(ctx, scope)
+	if err != nil {
+		return UtilizationReport{}, err
+	}
+	tree, err := s.repo.FindShopfloorTree(ctx, scope)
+	if err != nil {
+		return UtilizationReport{}, err
+	}
+	loads, err := s.repo.FindScheduledLoad(ctx, scope, from, to)
+	if err != nil {
+		return UtilizationReport{}, err
+	}
+	calendar, err := s.repo.FindCalendarByCustomerID(ctx, scope, from, to)
+	if err != nil {
+		return UtilizationReport{}, err
+	}
+	// Night shifts of the last day end on the next one
+	downtimes, err := s.repo.FindDowntimes(ctx, scope, from, to.AddDate(0, 0, 2))
+	if err != nil {
+		return UtilizationReport{}, err
+	}
+	downtimesByWorkcenter := map[uuid.UUID][]downtimeWindow{}
+	for _, window := range downtimes {
+		downtimesByWorkcenter[window.WorkcenterID] = append(downtimesByWorkcenter[window.WorkcenterID], window)
+	}
+
+	type cellKey struct {
+		date         string
+		shiftID      uuid.UUID
+		workcenterID uuid.UUID
+	}
+	type dayKey struct {
+		date         string
+		workcenterID uuid.UUID
+	}
+	loadByCell := map[cellKey]int{}
+	for _, load := range loads {
+		loadByCell[cellKey{load.Date, load.ShiftID, load.WorkcenterID}] += load.Minutes
+	}
+	hoursByDay := map[dayKey]float64{}
+	for _, day := range calendar {
+		hoursByDay[dayKey{day.Date, day.WorkcenterID}] = day.HoursPerShift
+	}
+	shiftsByID := map[uuid.UUID]shiftRef{}
+	for _, shift := range activeShifts {
+		shiftsByID[shift.ID] = shift
+	}
+
+	report := UtilizationReport{From: from.Format(dateLayout), To: to.Format(dateLayout), Cells: []UtilizationCell{}}
+	addCell := func(date string, shiftID uuid.UUID, workcenter Workcenter, scheduled int) {
+		hours, ok := hoursByDay[dayKey{date, workcenter.ID}]
+		if !ok {
+			hours = workcenter.HoursPerShift
+		}
+		cell := UtilizationCell{
+			Date:             date,
+			ShiftID:          shiftID,
+			WorkcenterID:     workcenter.ID,
+			WorkcenterName:   workcenter.Name,
+			ScheduledMinutes: scheduled,
+		}
+		if shift, ok := shiftsByID[shiftID]; ok {
+			cell.ShiftName = shift.Name
+			day, _ := time.Parse(dateLayout, date)
+			start, end := shifts.Window(day, shift.StartTime, shift.EndTime)
+			cell.DowntimeMinutes = overlapMinutes(start, end, downtimesByWorkcenter[workcenter.ID])
+		}
+		cell.AvailableMinutes = availableMinutes(hours, cell.DowntimeMinutes, workcenter)
+		if cell.AvailableMinutes > 0 {
+			cell.Utilization = math.Round(float64(scheduled)/float64(cell.AvailableMinutes)*1000) / 1000
+		}
+		cell.Overloaded = scheduled > cell.AvailableMinutes
+		if cell.Overloaded {
+			report.Overloaded++
+		}
+		report.Cells = append(report.Cells, cell)
+	}
+
+	reported := map[cellKey]bool{}
+	inScope := map[uuid.UUID]Workcenter{}
+	for _, workcenter := range workcenters {
+		// A shop floor includes the areas and lines below it
+		if shopFloorID.Valid && (!workcenter.ShopFloorID.Valid || !tree.Within(workcenter.ShopFloorID.UUID, shopFloorID.UUID)) {
+			continue
+		}
+		inScope[workcenter.ID] = workcenter
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dateLayout)
+		for _, workcenter := range workcenters {
+			if _, ok := inScope[workcenter.ID]; !ok || !workcenter.IsActive {
+				continue
+			}
+			for _, shift := range activeShifts {
+				// Shifts without shopfloor apply to every workcenter of the tenant,
+				// the others to the workcenters of their shop floor and below
+				if shift.ShopFloorID.Valid && (!workcenter.ShopFloorID.Valid || !tree.Within(workcenter.ShopFloorID.UUID, shift.ShopFloorID.UUID)) {
+					continue
+				}
+				key := cellKey{date, shift.ID, workcenter.ID}
+				reported[key] = true
+				addCell(date, shift.ID, workcenter, loadByCell[key])
+			}
+		}
+	}
+	// Work planned on inactive shifts or workcenters must not disappear from the report
+	for _, load := range loads {
+		key := cellKey{load.Date, load.ShiftID, load.WorkcenterID}
+		workcenter, ok := inScope[load.WorkcenterID]
+		if reported[key] || !ok {
+			continue
+		}
+		reported[key] = true
+		addCell(load.Date, load.ShiftID, workcenter, loadByCell[key])
+	}
+	return report, nil
 }
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"api/internal/apperr"
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader carries the API key of machine-to-machine requests
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator returns the identity of an API key, or nil when the
+// key is unknown or expired
+type APIKeyAuthenticator interface {
+	AuthenticateKey(ctx context.Context, key string) (*AuthUser, error)
+}
+
+// APIKeyMiddleware authenticates requests that carry an API key and hands
+// the rest to next, the JWT middleware. The identity of the key is stored
+// like the claims of a token, so the middlewares after it work unchanged.
+func APIKeyMiddleware(authenticator APIKeyAuthenticator, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(APIKeyHeader)
+		if key == "" {
+			next(c)
+			return
+		}
+		user, err := authenticator.AuthenticateKey(c.Request.Context(), key)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if user == nil {
+			abortWithError(c, apperr.Unauthorized("invalid_api_key", "invalid or expired API key"))
+			return
+		}
+		c.Set("id", user)
+		c.Next()
+	}
+}
+
+// RequireUser rejects API keys on routes that act on the logged in user
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user := GetUser(c); user != nil && user.APIKeyID != "" {
+			abortWithError(c, apperr.Forbidden("api_key_not_allowed", "not available with an API key"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditRecorder writes a request to the audit log
+type AuditRecorder interface {
+	RecordRequest(ctx context.Context, user *AuthUser, method string, path string, status int, ip string) error
+}
+
+// ImpersonationAudit writes every change made while an admin impersonates
+// a user to the audit log. Reads aren't recorded.
+func ImpersonationAudit(recorder AuditRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		user := GetUser(c)
+		if user == nil || user.ImpersonatedBy == "" {
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		err := recorder.RecordRequest(c.Request.Context(), user, c.Request.Method, c.Request.URL.Path, c.Writer.Status(), c.ClientIP())
+		if err != nil {
+			slog.Error("Unable to record impersonated request", slog.String("path", c.Request.URL.Path), slog.Any("error", err))
+		}
+	}
+}
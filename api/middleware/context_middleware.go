@@ -31,6 +31,16 @@ func ContextMiddleware() gin.HandlerFunc {
 			}
 		}
 
+		// Inject who makes the request, for the audit log
+		ctx = context.WithValue(ctx, "user_id", user.ID)
+		if user.ImpersonatedBy != "" {
+			ctx = context.WithValue(ctx, "impersonated_by", user.ImpersonatedBy)
+		}
+		if user.APIKeyID != "" {
+			ctx = context.WithValue(ctx, "api_key_id", user.APIKeyID)
+			ctx = context.WithValue(ctx, "scopes", user.Scopes)
+		}
+
 		// Update request with the new context
 		c.Request = c.Request.WithContext(ctx)
 
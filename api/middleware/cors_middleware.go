@@ -15,8 +15,8 @@ func SetupCORS() gin.HandlerFunc {
 	"https://turniq.zenith.ovh",
 }
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
-	corsConfig.ExposeHeaders = []string{"Content-Length"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "X-Request-ID", IdempotencyKeyHeader}
+	corsConfig.ExposeHeaders = []string{"Content-Length", "X-Request-ID", "Idempotent-Replayed"}
 	corsConfig.AllowCredentials = true
 	corsConfig.MaxAge = 12 * time.Hour
 	
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"api/internal/apperr"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of error responses (RFC 9457)
+const ProblemContentType = "application/problem+json"
+
+// ErrorMiddleware answers the last error handlers and middlewares add with
+// c.Error, unless they already wrote a response. Typed errors become problem
+// responses with their status and code; any other error is logged and
+// answers 500 without its message. "error" repeats "detail" for the clients
+// that read it.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		problem := apperr.From(err)
+		status := problem.Status()
+		if problem.Kind == apperr.KindInternal {
+			slog.ErrorContext(c.Request.Context(), "Request failed",
+				slog.String("request_id", GetRequestID(c)),
+				slog.String("method", c.Request.Method),
+				slog.String("path", c.Request.URL.Path),
+				slog.Any("error", err))
+		}
+		body := gin.H{}
+		for key, value := range problem.Details {
+			body[key] = value
+		}
+		body["type"] = "about:blank"
+		body["title"] = http.StatusText(status)
+		body["status"] = status
+		body["detail"] = problem.Message
+		body["code"] = problem.Code
+		body["error"] = problem.Message
+		body["request_id"] = GetRequestID(c)
+		c.Header("Content-Type", ProblemContentType)
+		c.JSON(status, body)
+	}
+}
+
+// abortWithError stops the chain and leaves the response to ErrorMiddleware
+func abortWithError(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
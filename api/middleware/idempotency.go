@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"api/internal/apperr"
+	"api/internal/idempotency"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// IdempotencyKeyHeader is the header clients send to make a POST safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKey is the longest key accepted
+const maxIdempotencyKey = 255
+
+// IdempotencyStore keeps the responses of the requests sent with a key
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, scope idempotency.Scope, key string, fingerprint string, lockedUntil, expiresAt time.Time) (idempotency.Record, bool, error)
+	Complete(ctx context.Context, scope idempotency.Scope, key string, record idempotency.Record) error
+	Release(ctx context.Context, scope idempotency.Scope, key string, reservedAt time.Time) error
+}
+
+// responseRecorder keeps a copy of the body written to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes POST requests with an Idempotency-Key header safe to
+// retry. The first successful response for a key of the caller, the user or
+// the API key, is stored for ttl and answered again, with an
+// Idempotent-Replayed header, to every retry. A key reused with another
+// method, path or body is rejected, and so is a retry that arrives while the
+// first request is still running, for up to lease. Failed requests aren't
+// stored: their key is released so it can be retried. Admins without a
+// tenant have nowhere to keep keys and are served without them.
+func Idempotency(store IdempotencyStore, ttl, lease time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		user := GetUser(c)
+		if c.Request.Method != http.MethodPost || key == "" || user == nil {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			abortWithError(c, apperr.Validation("invalid_idempotency_key", "Idempotency-Key must be at most 255 characters"))
+			return
+		}
+		scope, ok := idempotencyScope(user)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		io.WriteString(hash, c.Request.Method+" "+c.Request.URL.RequestURI()+"\n")
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		ctx := c.Request.Context()
+		now := time.Now()
+		record, reserved, err := store.Reserve(ctx, scope, key, fingerprint, now.Add(lease), now.Add(ttl))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if !reserved {
+			switch {
+			case record.Fingerprint != fingerprint:
+				abortWithError(c, apperr.Validation("idempotency_key_reused", "Idempotency-Key was already used for another request"))
+			case record.Status == 0:
+				abortWithError(c, apperr.Conflict("request_in_progress", "A request with this Idempotency-Key is still running"))
+			default:
+				if record.Location != "" {
+					c.Header("Location", record.Location)
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(record.Status, record.ContentType, record.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// The client may be gone by now, the outcome is stored anyway
+		ctx = context.WithoutCancel(ctx)
+		status := c.Writer.Status()
+		if len(c.Errors) > 0 || status >= http.StatusBadRequest {
+			err = store.Release(ctx, scope, key, record.ReservedAt)
+		} else {
+			err = store.Complete(ctx, scope, key, idempotency.Record{
+				Fingerprint: fingerprint,
+				Status:      status,
+				ContentType: c.Writer.Header().Get("Content-Type"),
+				Location:    c.Writer.Header().Get("Location"),
+				Body:        recorder.body.Bytes(),
+				ReservedAt:  record.ReservedAt,
+			})
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to save idempotency key",
+				slog.String("request_id", GetRequestID(c)),
+				slog.String("path", c.Request.URL.Path),
+				slog.Any("error", err))
+		}
+	}
+}
+
+// idempotencyScope returns whose keys the request uses: the API key or the
+// user within their tenant. Callers without a tenant have none.
+func idempotencyScope(user *AuthUser) (idempotency.Scope, bool) {
+	customerID, err := uuid.Parse(user.CustomerID)
+	if err != nil || customerID == uuid.Nil {
+		return idempotency.Scope{}, false
+	}
+	caller := user.ID
+	if user.APIKeyID != "" {
+		caller = user.APIKeyID
+	}
+	callerID, err := uuid.Parse(caller)
+	if err != nil {
+		return idempotency.Scope{}, false
+	}
+	return idempotency.Scope{CustomerID: customerID, CallerID: callerID}, true
+}
@@ -3,6 +3,8 @@ package middleware
 
 import (
 	"api/config"
+	"api/internal/apperr"
+	"net/http"
 	"time"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
@@ -16,6 +18,14 @@ type AuthUser struct {
 	Email      string
 	CustomerID string
 	IsAdmin    bool
+	// SessionID is the login session the token was issued for
+	SessionID string
+	// APIKeyID and Scopes are set when the request was authenticated with
+	// an API key instead of a user's token
+	APIKeyID string
+	Scopes   []string
+	// ImpersonatedBy is the admin acting as the user, if any
+	ImpersonatedBy string
 }
 
 func SetupJWT(cfg config.Config) (*jwt.GinJWTMiddleware, error) {
@@ -27,13 +37,18 @@ func SetupJWT(cfg config.Config) (*jwt.GinJWTMiddleware, error) {
 		IdentityKey: "id",
 		PayloadFunc: func(data interface{}) jwt.MapClaims {
 			if v, ok := data.(*AuthUser); ok {
-				return jwt.MapClaims{
+				claims := jwt.MapClaims{
 					"id":          v.ID,
 					"username":    v.Username,
 					"email":       v.Email,
 					"customer_id": v.CustomerID,
 					"is_admin":    v.IsAdmin,
+					"sid":         v.SessionID,
 				}
+				if v.ImpersonatedBy != "" {
+					claims["impersonated_by"] = v.ImpersonatedBy
+				}
+				return claims
 			}
 			return jwt.MapClaims{}
 		},
@@ -45,7 +60,17 @@ func SetupJWT(cfg config.Config) (*jwt.GinJWTMiddleware, error) {
 				Email:      getStringClaim(claims, "email"),
 				CustomerID: getStringClaim(claims, "customer_id"),
 				IsAdmin:    getBoolClaim(claims, "is_admin"),
+				SessionID:  getStringClaim(claims, "sid"),
+				ImpersonatedBy: getStringClaim(claims, "impersonated_by"),
+			}
+		},
+		// Impersonation tokens live as long as the impersonation, since
+		// they can't be refreshed
+		TimeoutFunc: func(data interface{}) time.Duration {
+			if claims, ok := data.(jwt.MapClaims); ok && getStringClaim(claims, "impersonated_by") != "" {
+				return cfg.Auth.ImpersonationTTL
 			}
+			return cfg.Auth.TTL
 		},
 		Authenticator: func(c *gin.Context) (interface{}, error) {
 			return nil, jwt.ErrFailedAuthentication
@@ -57,10 +82,12 @@ func SetupJWT(cfg config.Config) (*jwt.GinJWTMiddleware, error) {
 			return false
 		},
 		Unauthorized: func(c *gin.Context, code int, message string) {
-			c.JSON(code, gin.H{
-				"code":    code,
-				"message": message,
-			})
+			// The middleware has aborted already, ErrorMiddleware answers
+			if code == http.StatusForbidden {
+				c.Error(apperr.Forbidden("forbidden", message))
+				return
+			}
+			c.Error(apperr.Unauthorized("unauthorized", message))
 		},
 		TokenLookup:   "header: Authorization, query: token, cookie: jwt",
 		TokenHeadName: "Bearer",
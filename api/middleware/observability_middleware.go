@@ -87,6 +87,7 @@ func ObservabilityMiddleware() gin.HandlerFunc {
 				slog.Int("status", status),
 				slog.Float64("duration_seconds", duration),
 				slog.String("client_ip", c.ClientIP()),
+				slog.String("request_id", GetRequestID(c)),
 			}
 
 			if len(c.Errors) > 0 {
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"api/internal/apperr"
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PermissionResolver tells whether a user holds a permission
+type PermissionResolver interface {
+	HasPermission(ctx context.Context, userID uuid.UUID, permission string) (bool, error)
+}
+
+// RequirePermission guards a route group with the permissions of resource:
+// GET and HEAD requests need "<resource>:read", the rest "<resource>:write".
+// System admins are always allowed; API keys need the permission in their scopes.
+func RequirePermission(resolver PermissionResolver, resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := GetUser(c)
+		if user == nil {
+			abortWithError(c, apperr.Unauthorized("unauthorized", "unauthorized"))
+			return
+		}
+		if user.IsAdmin {
+			c.Next()
+			return
+		}
+
+		action := "write"
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			action = "read"
+		}
+		permission := resource + ":" + action
+
+		if user.APIKeyID != "" {
+			if !slices.Contains(user.Scopes, permission) {
+				abortWithError(c, apperr.Forbidden("missing_scope", "missing scope "+permission))
+				return
+			}
+			c.Next()
+			return
+		}
+
+		userID, err := uuid.Parse(user.ID)
+		if err != nil {
+			abortWithError(c, apperr.Unauthorized("unauthorized", "unauthorized"))
+			return
+		}
+		allowed, err := resolver.HasPermission(c.Request.Context(), userID, permission)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if !allowed {
+			abortWithError(c, apperr.Forbidden("missing_permission", "missing permission "+permission))
+			return
+		}
+		c.Next()
+	}
+}
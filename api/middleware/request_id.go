@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader identifies a request in the logs and the audit log
+const RequestIDHeader = "X-Request-ID"
+
+// Request IDs from clients are kept when they are reasonable
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestIDMiddleware gives every request an ID, the client's or a new one,
+// returns it in the response and puts "request_id" in the request context
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), "request_id", requestID))
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID of the request
+func GetRequestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"api/internal/apperr"
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SessionValidator tells whether the session of an access token is still active
+type SessionValidator interface {
+	ActiveSession(ctx context.Context, sessionID uuid.UUID) (bool, error)
+}
+
+// SessionMiddleware rejects access tokens whose session was revoked or
+// expired, or whose user was deactivated, so logouts take effect before
+// the token expires
+func SessionMiddleware(validator SessionValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := GetUser(c)
+		if user == nil {
+			abortWithError(c, apperr.Unauthorized("unauthorized", "unauthorized"))
+			return
+		}
+		// API keys have no session
+		if user.APIKeyID != "" {
+			c.Next()
+			return
+		}
+		sessionID, err := uuid.Parse(user.SessionID)
+		if err != nil {
+			abortWithError(c, apperr.Unauthorized("unauthorized", "unauthorized"))
+			return
+		}
+		active, err := validator.ActiveSession(c.Request.Context(), sessionID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if !active {
+			abortWithError(c, apperr.Unauthorized("session_expired", "session expired"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RejectImpersonation guards the routes that secure an account, such as its
+// second factor, from admins acting as its user. They can only end the
+// impersonation session.
+func RejectImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user := GetUser(c); user != nil && user.ImpersonatedBy != "" {
+			abortWithError(c, apperr.Forbidden("impersonation_not_allowed", "not available while impersonating a user"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"api/internal/apperr"
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ShopfloorResolver returns the shopfloors a user is restricted to
+type ShopfloorResolver interface {
+	AllowedShopfloors(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, bool, error)
+}
+
+// ShopfloorScopeMiddleware injects "shopfloor_ids" into the request context
+// for users restricted to some shopfloors. It is resolved on every request so
+// assignment changes apply without logging in again. Unrestricted users,
+// system admins and API keys get no value.
+func ShopfloorScopeMiddleware(resolver ShopfloorResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := GetUser(c)
+		if user == nil || user.IsAdmin || user.APIKeyID != "" {
+			c.Next()
+			return
+		}
+		userID, err := uuid.Parse(user.ID)
+		if err != nil {
+			abortWithError(c, apperr.Unauthorized("unauthorized", "unauthorized"))
+			return
+		}
+		allowed, restricted, err := resolver.AllowedShopfloors(c.Request.Context(), userID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if restricted {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), "shopfloor_ids", allowed))
+		}
+		c.Next()
+	}
+}
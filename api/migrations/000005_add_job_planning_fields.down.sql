@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_schedule_entries_job_id;
+DROP INDEX IF EXISTS idx_jobs_customer_due_date;
+
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS customer_order_ref,
+    DROP COLUMN IF EXISTS priority,
+    DROP COLUMN IF EXISTS due_date;
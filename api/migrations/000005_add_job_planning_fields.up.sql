@@ -0,0 +1,7 @@
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS due_date TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS priority INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS customer_order_ref TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_jobs_customer_due_date ON jobs (customer_id, due_date);
+CREATE INDEX IF NOT EXISTS idx_schedule_entries_job_id ON schedule_entries (job_id);
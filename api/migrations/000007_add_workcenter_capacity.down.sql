@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_schedule_entries_workcenter_date;
+DROP TABLE IF EXISTS workcenter_calendar;
+
+ALTER TABLE workcenters
+    DROP COLUMN IF EXISTS efficiency,
+    DROP COLUMN IF EXISTS parallel_slots,
+    DROP COLUMN IF EXISTS hours_per_shift;
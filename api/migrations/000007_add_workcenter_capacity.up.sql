@@ -0,0 +1,17 @@
+ALTER TABLE workcenters
+    ADD COLUMN IF NOT EXISTS hours_per_shift NUMERIC(5, 2) NOT NULL DEFAULT 8,
+    ADD COLUMN IF NOT EXISTS parallel_slots INT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS efficiency NUMERIC(4, 3) NOT NULL DEFAULT 1;
+
+-- Per-day overrides of the available hours per shift (holidays, reduced days...)
+CREATE TABLE IF NOT EXISTS workcenter_calendar (
+    workcenter_id UUID NOT NULL REFERENCES workcenters(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    hours_per_shift NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (workcenter_id, date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_entries_workcenter_date ON schedule_entries (workcenter_id, date);
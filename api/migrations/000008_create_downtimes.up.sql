@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS downtime_reasons (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL DEFAULT 'unplanned', -- planned | unplanned
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (customer_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS downtimes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    workcenter_id UUID NOT NULL REFERENCES workcenters(id) ON DELETE CASCADE,
+    reason_id UUID REFERENCES downtime_reasons(id) ON DELETE SET NULL,
+    kind TEXT NOT NULL DEFAULT 'unplanned', -- planned | unplanned
+    start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    end_time TIMESTAMP WITH TIME ZONE, -- NULL while the workcenter is still down
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK (end_time IS NULL OR end_time > start_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_downtimes_workcenter_start ON downtimes (workcenter_id, start_time);
+CREATE INDEX IF NOT EXISTS idx_downtimes_customer_start ON downtimes (customer_id, start_time);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_time_entries_workcenter_check_in;
+DROP TABLE IF EXISTS production_counts;
+
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS ideal_cycle_seconds;
@@ -0,0 +1,18 @@
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS ideal_cycle_seconds NUMERIC(10, 2) NOT NULL DEFAULT 0;
+
+-- Pieces produced on a workcenter, reported by operators or machines
+CREATE TABLE IF NOT EXISTS production_counts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    workcenter_id UUID NOT NULL REFERENCES workcenters(id) ON DELETE CASCADE,
+    job_id UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    good_qty INT NOT NULL DEFAULT 0 CHECK (good_qty >= 0),
+    scrap_qty INT NOT NULL DEFAULT 0 CHECK (scrap_qty >= 0),
+    source TEXT NOT NULL DEFAULT 'manual',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_production_counts_workcenter_recorded ON production_counts (workcenter_id, recorded_at);
+CREATE INDEX IF NOT EXISTS idx_time_entries_workcenter_check_in ON time_entries (workcenter_id, check_in);
@@ -0,0 +1,5 @@
+ALTER TABLE downtimes
+    DROP COLUMN IF EXISTS source;
+
+DROP TABLE IF EXISTS machine_events;
+DROP TABLE IF EXISTS machine_signals;
@@ -0,0 +1,29 @@
+-- MQTT topics published by machines, mapped to the workcenter they belong to
+CREATE TABLE IF NOT EXISTS machine_signals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    workcenter_id UUID NOT NULL REFERENCES workcenters(id) ON DELETE CASCADE,
+    topic TEXT NOT NULL UNIQUE,
+    signal TEXT NOT NULL, -- state | count | scrap
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Raw state changes and counts received from machines
+CREATE TABLE IF NOT EXISTS machine_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    workcenter_id UUID NOT NULL REFERENCES workcenters(id) ON DELETE CASCADE,
+    signal_id UUID REFERENCES machine_signals(id) ON DELETE SET NULL,
+    signal TEXT NOT NULL,
+    state TEXT NOT NULL DEFAULT '',
+    quantity INT NOT NULL DEFAULT 0,
+    payload TEXT NOT NULL DEFAULT '',
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_machine_events_workcenter_received ON machine_events (workcenter_id, received_at);
+
+ALTER TABLE downtimes
+    ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'manual';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_shopfloors_parent;
+
+ALTER TABLE shopfloors
+    DROP CONSTRAINT IF EXISTS chk_shopfloors_parent;
+
+ALTER TABLE shopfloors
+    DROP COLUMN IF EXISTS kind,
+    DROP COLUMN IF EXISTS parent_id;
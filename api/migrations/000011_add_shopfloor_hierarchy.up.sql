@@ -0,0 +1,10 @@
+-- Shopfloors become a location tree: sites contain areas, areas contain
+-- lines. Existing shopfloors are top level sites.
+ALTER TABLE shopfloors
+    ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES shopfloors(id) ON DELETE RESTRICT,
+    ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'site';
+
+ALTER TABLE shopfloors
+    ADD CONSTRAINT chk_shopfloors_parent CHECK (parent_id IS NULL OR parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS idx_shopfloors_parent ON shopfloors (parent_id);
@@ -0,0 +1,10 @@
+-- Shopfloors a user is restricted to. A user without rows sees every
+-- shopfloor of the tenant; an assignment also covers the nodes below it.
+CREATE TABLE IF NOT EXISTS user_shopfloors (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shopfloor_id UUID NOT NULL REFERENCES shopfloors(id) ON DELETE RESTRICT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, shopfloor_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_shopfloors_shopfloor ON user_shopfloors (shopfloor_id);
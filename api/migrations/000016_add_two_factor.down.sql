@@ -0,0 +1,7 @@
+ALTER TABLE user_tokens DROP COLUMN IF EXISTS attempts;
+DROP TABLE IF EXISTS user_recovery_codes;
+ALTER TABLE customers DROP COLUMN IF EXISTS require_two_factor;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS totp_secret,
+    DROP COLUMN IF EXISTS totp_enabled_at,
+    DROP COLUMN IF EXISTS totp_last_step;
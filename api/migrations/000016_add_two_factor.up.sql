@@ -0,0 +1,25 @@
+-- TOTP two-factor authentication. The secret is set on enrollment and only
+-- used once totp_enabled_at is set; totp_last_step stops code replays.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS totp_secret TEXT,
+    ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+
+-- System admins can require two-factor authentication for every user of a tenant
+ALTER TABLE customers
+    ADD COLUMN IF NOT EXISTS require_two_factor BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Single-use recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user ON user_recovery_codes (user_id);
+
+-- Failed codes entered with an interim login token
+ALTER TABLE user_tokens
+    ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
@@ -0,0 +1,21 @@
+-- Consecutive failed logins of each account and how long it stays locked
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS failed_logins INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+
+-- Audit trail of every login, successful or not. Attempts with an unknown
+-- email have no user or customer. The failures of an IP throttle it.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    customer_id UUID REFERENCES customers(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    ip TEXT,
+    user_agent TEXT,
+    success BOOLEAN NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_customer ON login_attempts (customer_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts (ip, created_at);
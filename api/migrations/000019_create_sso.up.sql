@@ -0,0 +1,38 @@
+-- OpenID Connect single sign-on of a customer. role_mapping is a list of
+-- {"value", "role"} rules checked in order against the role_claim of the ID
+-- token; users matching none get default_role. Users whose email domain is
+-- in domains can start the login from their email.
+CREATE TABLE IF NOT EXISTS sso_configs (
+    customer_id UUID PRIMARY KEY REFERENCES customers(id) ON DELETE CASCADE,
+    issuer TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    client_secret TEXT NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{openid,email,profile}',
+    domains TEXT[] NOT NULL DEFAULT '{}',
+    role_claim TEXT NOT NULL DEFAULT 'groups',
+    role_mapping JSONB NOT NULL DEFAULT '[]',
+    default_role TEXT NOT NULL DEFAULT 'read_only',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Pending authorization requests, by the SHA-256 hash of their state
+CREATE TABLE IF NOT EXISTS sso_states (
+    state_hash TEXT PRIMARY KEY,
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Identities of the identity providers linked to users
+CREATE TABLE IF NOT EXISTS user_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (issuer, subject)
+);
@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_audit_logs_request;
+DROP INDEX IF EXISTS idx_audit_logs_created;
+DROP INDEX IF EXISTS idx_audit_logs_entity;
+DELETE FROM audit_logs WHERE action <> 'request';
+ALTER TABLE audit_logs
+    DROP COLUMN IF EXISTS after,
+    DROP COLUMN IF EXISTS before,
+    DROP COLUMN IF EXISTS entity_id,
+    DROP COLUMN IF EXISTS entity,
+    DROP COLUMN IF EXISTS action,
+    DROP COLUMN IF EXISTS request_id,
+    DROP COLUMN IF EXISTS api_key_id,
+    ALTER COLUMN method SET NOT NULL,
+    ALTER COLUMN path SET NOT NULL,
+    ALTER COLUMN status SET NOT NULL;
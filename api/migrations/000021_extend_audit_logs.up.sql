@@ -0,0 +1,22 @@
+-- The audit log records every change of the tenant data: who made it, on
+-- which record, the fields before and after, and the request it came from.
+-- It outlives the records, users and customers it mentions, so it has no
+-- foreign keys; old entries are purged after the retention period.
+ALTER TABLE audit_logs
+    DROP CONSTRAINT IF EXISTS audit_logs_customer_id_fkey,
+    DROP CONSTRAINT IF EXISTS audit_logs_actor_id_fkey,
+    DROP CONSTRAINT IF EXISTS audit_logs_impersonated_by_fkey,
+    ALTER COLUMN method DROP NOT NULL,
+    ALTER COLUMN path DROP NOT NULL,
+    ALTER COLUMN status DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS api_key_id UUID,
+    ADD COLUMN IF NOT EXISTS request_id TEXT,
+    ADD COLUMN IF NOT EXISTS action TEXT NOT NULL DEFAULT 'request',
+    ADD COLUMN IF NOT EXISTS entity TEXT,
+    ADD COLUMN IF NOT EXISTS entity_id UUID,
+    ADD COLUMN IF NOT EXISTS before JSONB,
+    ADD COLUMN IF NOT EXISTS after JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs (entity, entity_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created ON audit_logs (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_request ON audit_logs (request_id);
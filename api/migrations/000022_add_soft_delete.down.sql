@@ -0,0 +1,55 @@
+ALTER TABLE schedule_entries
+    DROP CONSTRAINT IF EXISTS schedule_entries_shopfloor_id_fkey,
+    DROP CONSTRAINT IF EXISTS schedule_entries_shift_id_fkey,
+    DROP CONSTRAINT IF EXISTS schedule_entries_workcenter_id_fkey,
+    DROP CONSTRAINT IF EXISTS schedule_entries_job_id_fkey,
+    DROP CONSTRAINT IF EXISTS schedule_entries_operator_id_fkey,
+    ADD CONSTRAINT schedule_entries_shopfloor_id_fkey FOREIGN KEY (shopfloor_id) REFERENCES shopfloors(id) ON DELETE CASCADE,
+    ADD CONSTRAINT schedule_entries_shift_id_fkey FOREIGN KEY (shift_id) REFERENCES shifts(id) ON DELETE CASCADE,
+    ADD CONSTRAINT schedule_entries_workcenter_id_fkey FOREIGN KEY (workcenter_id) REFERENCES workcenters(id) ON DELETE CASCADE,
+    ADD CONSTRAINT schedule_entries_job_id_fkey FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE SET NULL,
+    ADD CONSTRAINT schedule_entries_operator_id_fkey FOREIGN KEY (operator_id) REFERENCES operators(id) ON DELETE SET NULL;
+
+ALTER TABLE jobs
+    DROP CONSTRAINT IF EXISTS jobs_shop_floor_id_fkey,
+    DROP CONSTRAINT IF EXISTS jobs_workcenter_id_fkey,
+    ADD CONSTRAINT jobs_shop_floor_id_fkey FOREIGN KEY (shop_floor_id) REFERENCES shopfloors(id) ON DELETE CASCADE,
+    ADD CONSTRAINT jobs_workcenter_id_fkey FOREIGN KEY (workcenter_id) REFERENCES workcenters(id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS idx_shifts_deleted;
+DROP INDEX IF EXISTS idx_jobs_deleted;
+DROP INDEX IF EXISTS idx_operators_deleted;
+DROP INDEX IF EXISTS idx_workcenters_deleted;
+DROP INDEX IF EXISTS idx_shopfloors_deleted;
+
+-- Deleted records are removed for good when going back: without deleted_at
+-- they can't be told apart from live ones. Restore what must be kept first.
+DO $$
+DECLARE
+    trashed BIGINT;
+BEGIN
+    SELECT (SELECT COUNT(*) FROM shopfloors WHERE deleted_at IS NOT NULL)
+        + (SELECT COUNT(*) FROM workcenters WHERE deleted_at IS NOT NULL)
+        + (SELECT COUNT(*) FROM operators WHERE deleted_at IS NOT NULL)
+        + (SELECT COUNT(*) FROM jobs WHERE deleted_at IS NOT NULL)
+        + (SELECT COUNT(*) FROM shifts WHERE deleted_at IS NOT NULL)
+    INTO trashed;
+    IF trashed > 0 THEN
+        RAISE WARNING 'Removing % records in the trash for good, with the schedule entries that point to them', trashed;
+    END IF;
+END $$;
+
+DELETE FROM schedule_entries WHERE shopfloor_id IN (SELECT id FROM shopfloors WHERE deleted_at IS NOT NULL)
+    OR workcenter_id IN (SELECT id FROM workcenters WHERE deleted_at IS NOT NULL)
+    OR shift_id IN (SELECT id FROM shifts WHERE deleted_at IS NOT NULL);
+DELETE FROM jobs WHERE deleted_at IS NOT NULL;
+DELETE FROM shifts WHERE deleted_at IS NOT NULL;
+DELETE FROM operators WHERE deleted_at IS NOT NULL;
+DELETE FROM workcenters WHERE deleted_at IS NOT NULL;
+DELETE FROM shopfloors WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE shifts DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE jobs DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE operators DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE workcenters DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE shopfloors DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,34 @@
+-- Shop floors, workcenters, operators, jobs and shifts are soft deleted:
+-- deleted_at hides them until they are restored from the trash.
+ALTER TABLE shopfloors ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE workcenters ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE operators ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE shifts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_shopfloors_deleted ON shopfloors (customer_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_workcenters_deleted ON workcenters (customer_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_operators_deleted ON operators (customer_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_jobs_deleted ON jobs (customer_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_shifts_deleted ON shifts (customer_id, deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Planning no longer disappears with the records it points to: removing a
+-- row that jobs or schedule entries still reference fails. They are still
+-- removed together with their customer.
+ALTER TABLE jobs
+    DROP CONSTRAINT IF EXISTS jobs_shop_floor_id_fkey,
+    DROP CONSTRAINT IF EXISTS jobs_workcenter_id_fkey,
+    ADD CONSTRAINT jobs_shop_floor_id_fkey FOREIGN KEY (shop_floor_id) REFERENCES shopfloors(id),
+    ADD CONSTRAINT jobs_workcenter_id_fkey FOREIGN KEY (workcenter_id) REFERENCES workcenters(id);
+
+ALTER TABLE schedule_entries
+    DROP CONSTRAINT IF EXISTS schedule_entries_shopfloor_id_fkey,
+    DROP CONSTRAINT IF EXISTS schedule_entries_shift_id_fkey,
+    DROP CONSTRAINT IF EXISTS schedule_entries_workcenter_id_fkey,
+    DROP CONSTRAINT IF EXISTS schedule_entries_job_id_fkey,
+    DROP CONSTRAINT IF EXISTS schedule_entries_operator_id_fkey,
+    ADD CONSTRAINT schedule_entries_shopfloor_id_fkey FOREIGN KEY (shopfloor_id) REFERENCES shopfloors(id),
+    ADD CONSTRAINT schedule_entries_shift_id_fkey FOREIGN KEY (shift_id) REFERENCES shifts(id),
+    ADD CONSTRAINT schedule_entries_workcenter_id_fkey FOREIGN KEY (workcenter_id) REFERENCES workcenters(id),
+    ADD CONSTRAINT schedule_entries_job_id_fkey FOREIGN KEY (job_id) REFERENCES jobs(id),
+    ADD CONSTRAINT schedule_entries_operator_id_fkey FOREIGN KEY (operator_id) REFERENCES operators(id);
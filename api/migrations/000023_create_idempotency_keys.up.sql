@@ -0,0 +1,18 @@
+-- Responses to POST requests sent with an Idempotency-Key header, replayed
+-- when a client retries with the same key. The fingerprint is the SHA-256 of
+-- the method, path and body, so a key reused for another request is told
+-- apart. status is NULL while the first request is still running.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    status INT,
+    content_type TEXT,
+    location TEXT,
+    body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (customer_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys (expires_at);
@@ -0,0 +1,13 @@
+-- Job codes are unique within a customer, so an import can never create a
+-- second job for a code that already exists. Trashed jobs keep their code
+-- until they are restored. Existing duplicates keep the oldest job's code;
+-- the others get the id of their job appended.
+UPDATE jobs SET job_code = job_code || '-' || id
+WHERE deleted_at IS NULL AND id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (PARTITION BY customer_id, job_code ORDER BY created_at, id) AS position
+        FROM jobs WHERE deleted_at IS NULL
+    ) numbered WHERE position > 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_customer_code ON jobs (customer_id, job_code) WHERE deleted_at IS NULL;
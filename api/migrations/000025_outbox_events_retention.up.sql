@@ -0,0 +1,2 @@
+-- The outbox purger deletes events by age
+CREATE INDEX IF NOT EXISTS idx_outbox_events_created_at ON outbox_events (created_at);
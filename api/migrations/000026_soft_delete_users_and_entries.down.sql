@@ -0,0 +1,29 @@
+DROP INDEX IF EXISTS idx_time_entries_deleted;
+DROP INDEX IF EXISTS idx_schedule_entries_deleted;
+DROP INDEX IF EXISTS idx_users_deleted;
+
+-- Deleted records are removed for good when going back: without deleted_at
+-- they can't be told apart from live ones. Restore what must be kept first.
+DO $$
+DECLARE
+    trashed BIGINT;
+BEGIN
+    SELECT (SELECT COUNT(*) FROM users WHERE deleted_at IS NOT NULL)
+        + (SELECT COUNT(*) FROM schedule_entries WHERE deleted_at IS NOT NULL)
+        + (SELECT COUNT(*) FROM time_entries WHERE deleted_at IS NOT NULL)
+    INTO trashed;
+    IF trashed > 0 THEN
+        RAISE WARNING 'Removing % users, schedule entries and time entries in the trash for good', trashed;
+    END IF;
+END $$;
+
+DELETE FROM time_entries WHERE deleted_at IS NOT NULL;
+DELETE FROM schedule_entries WHERE deleted_at IS NOT NULL;
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_users_email;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+ALTER TABLE time_entries DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE schedule_entries DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,14 @@
+-- Users, schedule entries and time entries are soft deleted too, so nothing
+-- the foreign keys of 000022 protect is removed for good from the API.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE schedule_entries ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE time_entries ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted ON users (customer_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_schedule_entries_deleted ON schedule_entries (customer_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_time_entries_deleted ON time_entries (operator_id, deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- A deleted user doesn't keep their email from being used again. Restoring
+-- them fails while another user has it.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email) WHERE deleted_at IS NULL;
@@ -0,0 +1,8 @@
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS locked_until;
+
+-- Keys of several callers of a tenant can't share the old primary key. They
+-- only replay responses to retries, so they are dropped.
+DELETE FROM idempotency_keys;
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS caller_id;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (customer_id, key);
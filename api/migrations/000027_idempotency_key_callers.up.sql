@@ -0,0 +1,11 @@
+-- Keys are scoped to the caller within the tenant, the user or the API key
+-- that sent the request, so two callers of a tenant never share a key.
+-- Keys reserved before have no caller and are left to expire.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS caller_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000';
+ALTER TABLE idempotency_keys ALTER COLUMN caller_id DROP DEFAULT;
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (customer_id, caller_id, key);
+
+-- A request that never answers, because the server crashed while it ran,
+-- holds its key until locked_until. The key can be reserved again after it.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
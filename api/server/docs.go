@@ -0,0 +1,65 @@
+package server
+
+import (
+	"api/internal/apikeys"
+	"api/internal/audit"
+	"api/internal/auth"
+	"api/internal/customers"
+	"api/internal/downtimes"
+	"api/internal/jobs"
+	"api/internal/machines"
+	"api/internal/oee"
+	"api/internal/openapi"
+	"api/internal/operators"
+	"api/internal/payments"
+	"api/internal/roles"
+	"api/internal/scheduleentries"
+	"api/internal/shifts"
+	"api/internal/shopfloors"
+	"api/internal/sso"
+	"api/internal/timeentries"
+	"api/internal/users"
+	"api/internal/webhooks"
+	"api/internal/workcenters"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Docs documents the routes Setup registers itself
+var Docs = []openapi.Route{
+	{Method: "GET", Path: "/health", Tag: "server", Summary: "Liveness check", Public: true},
+	{Method: "GET", Path: "/metrics", Tag: "server", Summary: "Prometheus metrics", Public: true, ContentType: "text/plain"},
+}
+
+// Document is the OpenAPI description of every route of the server. A route
+// registered without an entry here fails TestDocumentCoversRoutes.
+func Document() *openapi.Document {
+	return openapi.New(openapi.Info{Title: "Shop floor planning API", Version: "1.0.0"},
+		Docs,
+		openapi.Docs,
+		auth.Docs,
+		users.Docs,
+		roles.Docs,
+		customers.Docs,
+		sso.Docs,
+		operators.Docs,
+		jobs.Docs,
+		payments.Docs,
+		shopfloors.Docs,
+		workcenters.Docs,
+		shifts.Docs,
+		scheduleentries.Docs,
+		timeentries.Docs,
+		webhooks.Docs,
+		apikeys.Docs,
+		downtimes.Docs,
+		oee.Docs,
+		machines.Docs,
+		audit.Docs,
+	)
+}
+
+// Routes returns the routes registered by Setup
+func (s *Server) Routes() gin.RoutesInfo {
+	return s.router.Routes()
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"api/config"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestDocumentCoversRoutes sets the server up without a database and
+// compares its routes with the OpenAPI document; fix the Docs of the module
+// next to its routes.go when it fails
+func TestDocumentCoversRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Setup only needs what it validates; nothing reaches the database
+	cfg := config.Config{}
+	cfg.Auth.Secret = "docs-test"
+	cfg.Auth.TTL = time.Hour
+	cfg.Email.From = "docs-test@example.com"
+	s := NewServer(cfg, nil)
+	if err := s.Setup(); err != nil {
+		t.Fatal("setup:", err)
+	}
+
+	doc := Document()
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatal("document:", err)
+	}
+	for _, route := range doc.Missing(s.Routes()) {
+		t.Error("missing:", route)
+	}
+	for _, route := range doc.Stale(s.Routes()) {
+		t.Error("stale:", route)
+	}
+}
@@ -2,16 +2,28 @@ package server
 
 import (
 	"api/config"
+	"api/internal/apikeys"
+	"api/internal/audit"
 	"api/internal/auth"
 	"api/internal/customers"
+	"api/internal/downtimes"
+	"api/internal/email"
+	"api/internal/idempotency"
 	"api/internal/jobs"
+	"api/internal/machines"
+	"api/internal/oee"
+	"api/internal/openapi"
 	"api/internal/operators"
 	"api/internal/payments"
+	"api/internal/roles"
 	"api/internal/scheduleentries"
 	"api/internal/shifts"
 	"api/internal/shopfloors"
+	"api/internal/sso"
 	"api/internal/timeentries"
 	"api/internal/users"
+	"api/internal/validation"
+	"api/internal/webhooks"
 	"api/internal/workcenters"
 	"api/middleware"
 	"database/sql"
@@ -37,7 +49,13 @@ func NewServer(config config.Config, db *sql.DB) *Server {
 
 func(s *Server)Setup()error{
 	s.router.Use(middleware.SetupCORS())
+	s.router.Use(middleware.RequestIDMiddleware())
 	s.router.Use(middleware.ObservabilityMiddleware())
+	s.router.Use(middleware.ErrorMiddleware())
+
+	if err := validation.Register(); err != nil {
+		return err
+	}
 	
 	authMiddleware, err := middleware.SetupJWT(s.config)
 	if err != nil{
@@ -56,19 +74,42 @@ func(s *Server)Setup()error{
 	shiftRepo := shifts.NewRepository(s.db)
 	scheduleEntryRepo := scheduleentries.NewRepository(s.db)
 	timeEntryRepo := timeentries.NewRepository(s.db)
+	webhookRepo := webhooks.NewRepository(s.db)
+	apiKeyRepo := apikeys.NewRepository(s.db)
+	ssoRepo := sso.NewRepository(s.db)
+	downtimeRepo := downtimes.NewRepository(s.db)
+	oeeRepo := oee.NewRepository(s.db)
+	machineRepo := machines.NewRepository(s.db)
+	roleRepo := roles.NewRepository(s.db)
+	authRepo := auth.NewRepository(s.db)
+	auditRepo := audit.NewRepository(s.db)
+	idempotencyRepo := idempotency.NewRepository(s.db)
+
+	emailSender, err := email.NewSender(s.config)
+	if err != nil {
+		return err
+	}
 
 	//Services
-	customerService := customers.NewService(customerRepo)
-	userService := users.NewService(userRepo, customerService)
-	authService := auth.NewAuthService(userService,customerService, authMiddleware)
-	operatorService := operators.NewService(operatorRepo, customerService)
-	jobService := jobs.NewService(jobRepo, customerService)
-	paymentService := payments.NewService(paymentRepo)
-	shopfloorService := shopfloors.NewService(shopfloorRepo, customerService)
-	workcenterService := workcenters.NewService(workcenterRepo, customerService)
-	shiftService := shifts.NewService(shiftRepo)
-	scheduleEntryService := scheduleentries.NewService(scheduleEntryRepo)
-	timeEntryService := timeentries.NewService(timeEntryRepo)
+	auditService := audit.NewService(auditRepo)
+	customerService := customers.NewService(customerRepo, auditService)
+	roleService := roles.NewService(roleRepo)
+	userService := users.NewService(userRepo, customerService, roleService, auditService)
+	authService := auth.NewAuthService(userService,customerService, authMiddleware, authRepo, emailSender, s.config)
+	operatorService := operators.NewService(operatorRepo, customerService, auditService)
+	jobService := jobs.NewService(jobRepo, customerService, auditService)
+	paymentService := payments.NewService(paymentRepo, auditService)
+	shopfloorService := shopfloors.NewService(shopfloorRepo, customerService, auditService)
+	workcenterService := workcenters.NewService(workcenterRepo, customerService, auditService)
+	shiftService := shifts.NewService(shiftRepo, auditService)
+	scheduleEntryService := scheduleentries.NewService(scheduleEntryRepo, auditService)
+	timeEntryService := timeentries.NewService(timeEntryRepo, auditService)
+	webhookService := webhooks.NewService(webhookRepo)
+	apiKeyService := apikeys.NewService(apiKeyRepo, roleService)
+	ssoService := sso.NewService(ssoRepo, customerService, userService, roleService, authService, s.config)
+	downtimeService := downtimes.NewService(downtimeRepo)
+	oeeService := oee.NewService(oeeRepo)
+	machineService := machines.NewService(machineRepo)
 	//Handlers
 	userHandler := users.NewHandler(userService)
 	customerHandler := customers.NewHandler(customerService)
@@ -81,6 +122,14 @@ func(s *Server)Setup()error{
 	shiftHandler := shifts.NewHandler(shiftService)
 	scheduleEntryHandler := scheduleentries.NewHandler(scheduleEntryService)
 	timeEntryHandler := timeentries.NewHandler(timeEntryService)
+	webhookHandler := webhooks.NewHandler(webhookService)
+	apiKeyHandler := apikeys.NewHandler(apiKeyService)
+	ssoHandler := sso.NewHandler(ssoService, s.config.App.URL)
+	downtimeHandler := downtimes.NewHandler(downtimeService)
+	oeeHandler := oee.NewHandler(oeeService)
+	machineHandler := machines.NewHandler(machineService)
+	roleHandler := roles.NewHandler(roleService)
+	auditHandler := audit.NewHandler(auditService)
 	s.router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "OK"})
 	})
@@ -88,29 +137,62 @@ func(s *Server)Setup()error{
 	// Prometheus metrics endpoint
 	s.router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// OpenAPI description and docs UI
+	if err := openapi.Register(s.router, Document()); err != nil {
+		return err
+	}
+
 	// Public routes
 	public := s.router.Group("/auth")
 	auth.RegisterRoutes(public, authHandler, authMiddleware)
 	users.RegisterAdminRoutes(public, &userHandler)
+	sso.RegisterRoutes(public, &ssoHandler)
 
 	//protected routes
 	protected := s.router.Group("/api")
-	protected.Use(authMiddleware.MiddlewareFunc())
+	// Integrations authenticate with an API key instead of a user's token
+	protected.Use(middleware.APIKeyMiddleware(apiKeyService, authMiddleware.MiddlewareFunc()))
+	protected.Use(middleware.SessionMiddleware(authService))
 	protected.Use(middleware.ContextMiddleware()) // Inject context values
-	users.RegisterRoutes(protected, &userHandler)	
-	customers.RegisterRoutes(protected, &customerHandler)
-	operators.RegisterRoutes(protected, &operatorHandler)
-	jobs.RegisterRoutes(protected, &jobHandler)
-	payments.RegisterRoutes(protected, &paymentHandler)
-	shopfloors.RegisterRoutes(protected, &shopfloorHandler)
-	workcenters.RegisterRoutes(protected, &workcenterHandler)
-	shifts.RegisterRoutes(protected, &shiftHandler)
-	scheduleentries.RegisterRoutes(protected, &scheduleEntryHandler)
-	timeentries.RegisterRoutes(protected, &timeEntryHandler)
+	protected.Use(middleware.ShopfloorScopeMiddleware(userService))
+	// Changes made by admins impersonating a user are audited
+	protected.Use(middleware.ImpersonationAudit(auditService))
+	// Retried POSTs answer the response of the first attempt
+	protected.Use(middleware.Idempotency(idempotencyRepo, s.config.Idempotency.TTL, s.config.Idempotency.Lease))
+	auth.RegisterSessionRoutes(protected.Group("", middleware.RequireUser()), authHandler)
+	// Every route group needs the read or write permission of its resource
+	authorize := func(resource string) *gin.RouterGroup {
+		return protected.Group("", middleware.RequirePermission(roleService, resource))
+	}
+	users.RegisterRoutes(authorize(roles.ResourceUsers), &userHandler)
+	auth.RegisterUserRoutes(authorize(roles.ResourceUsers), authHandler)
+	roles.RegisterRoutes(authorize(roles.ResourceRoles), &roleHandler)
+	customers.RegisterRoutes(authorize(roles.ResourceCustomers), &customerHandler)
+	sso.RegisterConfigRoutes(authorize(roles.ResourceCustomers), &ssoHandler)
+	operators.RegisterRoutes(authorize(roles.ResourceOperators), &operatorHandler)
+	jobs.RegisterRoutes(authorize(roles.ResourceJobs), &jobHandler)
+	payments.RegisterRoutes(authorize(roles.ResourcePayments), &paymentHandler)
+	shopfloors.RegisterRoutes(authorize(roles.ResourceShopfloors), &shopfloorHandler)
+	workcenters.RegisterRoutes(authorize(roles.ResourceWorkcenters), &workcenterHandler)
+	shifts.RegisterRoutes(authorize(roles.ResourceShifts), &shiftHandler)
+	scheduleentries.RegisterRoutes(authorize(roles.ResourcePlanning), &scheduleEntryHandler)
+	timeentries.RegisterRoutes(authorize(roles.ResourceTimeEntries), &timeEntryHandler)
+	webhooks.RegisterRoutes(authorize(roles.ResourceWebhooks), &webhookHandler)
+	// Keys are managed by users only, so a key can't mint others
+	apikeys.RegisterRoutes(authorize(roles.ResourceAPIKeys).Group("", middleware.RequireUser()), &apiKeyHandler)
+	downtimes.RegisterRoutes(authorize(roles.ResourceDowntimes), &downtimeHandler)
+	oee.RegisterRoutes(authorize(roles.ResourceOEE), &oeeHandler)
+	machines.RegisterRoutes(authorize(roles.ResourceMachines), &machineHandler)
+	audit.RegisterRoutes(authorize(roles.ResourceAudit), &auditHandler)
 	return nil
 	
 }
 
+// Handler returns the router, e.g. to serve requests in process
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
 func(s *Server)Run()error{
 	return s.router.Run(":" + s.config.App.Port)
 }
\ No newline at end of file
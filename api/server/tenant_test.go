@@ -0,0 +1,938 @@
+package server
+
+import (
+	"api/config"
+	"api/internal/apikeys"
+	"api/internal/audit"
+	"api/internal/auth"
+	"api/internal/customers"
+	"api/internal/downtimes"
+	"api/internal/jobs"
+	"api/internal/machines"
+	"api/internal/oee"
+	"api/internal/operators"
+	"api/internal/payments"
+	"api/internal/roles"
+	"api/internal/scheduleentries"
+	"api/internal/shifts"
+	"api/internal/shopfloors"
+	"api/internal/sso"
+	"api/internal/timeentries"
+	"api/internal/users"
+	"api/internal/webhooks"
+	"api/internal/workcenters"
+	"api/middleware"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// tenantData holds one record of every kind for the owner tenant
+type tenantData struct {
+	customer      customers.Customer
+	shopfloor     shopfloors.Shopfloor
+	workcenter    workcenters.Workcenter
+	operator      operators.Operator
+	user          users.User
+	role          roles.Role
+	job           jobs.Job
+	payment       payments.Payment
+	shift         shifts.Shift
+	scheduleEntry scheduleentries.ScheduleEntry
+	timeEntry     timeentries.TimeEntry
+	endpoint      webhooks.Endpoint
+	delivery      webhooks.Delivery
+	apiKey        apikeys.APIKey
+	reason        downtimes.Reason
+	downtime      downtimes.Downtime
+	count         oee.ProductionCount
+	signal        machines.MachineSignal
+}
+
+func newTenantData(owner uuid.UUID) *tenantData {
+	now := time.Now()
+	d := &tenantData{}
+	d.customer = customers.Customer{ID: owner, Name: "Owner", MaxOperators: 100, MaxWorkcenters: 100, MaxShopFloors: 100, MaxUsers: 100, MaxJobs: 100}
+	d.shopfloor = shopfloors.Shopfloor{ID: uuid.New(), CustomerID: owner, Kind: shopfloors.KindSite, Name: "Plant"}
+	onShopfloor := uuid.NullUUID{UUID: d.shopfloor.ID, Valid: true}
+	d.workcenter = workcenters.Workcenter{ID: uuid.New(), CustomerID: owner, ShopFloorID: onShopfloor, Name: "Press"}
+	d.operator = operators.Operator{ID: uuid.New(), CustomerID: owner, ShopFloorID: d.shopfloor.ID, Code: "OP1", Name: "Operator"}
+	d.user = users.User{ID: uuid.New(), CustomerID: owner, Username: "owner", Email: "owner@example.com", Role: roles.RoleOwner}
+	d.role = roles.Role{ID: uuid.NullUUID{UUID: uuid.New(), Valid: true}, CustomerID: uuid.NullUUID{UUID: owner, Valid: true}, Code: "custom", Name: "Custom"}
+	d.job = jobs.Job{ID: uuid.New(), CustomerID: owner, ShopFloorID: d.shopfloor.ID, WorkcenterID: d.workcenter.ID, JobCode: "J1"}
+	d.payment = payments.Payment{ID: uuid.New(), CustomerID: owner, Amount: 10}
+	d.shift = shifts.Shift{ID: uuid.New(), CustomerID: owner, ShopfloorID: onShopfloor, Name: "Morning"}
+	d.scheduleEntry = scheduleentries.ScheduleEntry{ID: uuid.New(), CustomerID: owner, ShopfloorID: d.shopfloor.ID, ShiftID: d.shift.ID, Date: now}
+	d.timeEntry = timeentries.TimeEntry{ID: uuid.New(), OperatorID: d.operator.ID, CheckIn: now}
+	d.endpoint = webhooks.Endpoint{ID: uuid.New(), CustomerID: owner, URL: "https://hooks.example.com"}
+	d.delivery = webhooks.Delivery{ID: uuid.New(), CustomerID: owner, EndpointID: d.endpoint.ID}
+	d.apiKey = apikeys.APIKey{ID: uuid.New(), CustomerID: owner, Name: "CI"}
+	d.reason = downtimes.Reason{ID: uuid.New(), CustomerID: owner, Code: "JAM", Name: "Jam"}
+	d.downtime = downtimes.Downtime{ID: uuid.New(), CustomerID: owner, WorkcenterID: d.workcenter.ID, StartTime: now}
+	d.count = oee.ProductionCount{ID: uuid.New(), CustomerID: owner, WorkcenterID: d.workcenter.ID, RecordedAt: now}
+	d.signal = machines.MachineSignal{ID: uuid.New(), CustomerID: owner, WorkcenterID: d.workcenter.ID, Topic: "press/state"}
+	return d
+}
+
+// IDs returns the owner tenant and every record of it
+func (d *tenantData) IDs() []uuid.UUID {
+	return append(d.OnShopfloor(), d.customer.ID, d.user.ID, d.role.ID.UUID, d.payment.ID, d.timeEntry.ID, d.endpoint.ID,
+		d.delivery.ID, d.apiKey.ID, d.reason.ID, d.downtime.ID, d.count.ID, d.signal.ID)
+}
+
+// OnShopfloor returns the shop floor and the records on it
+func (d *tenantData) OnShopfloor() []uuid.UUID {
+	return []uuid.UUID{d.shopfloor.ID, d.workcenter.ID, d.operator.ID, d.job.ID, d.shift.ID, d.scheduleEntry.ID}
+}
+
+// customerOf answers the lookups of the tenant of a record
+func (d *tenantData) customerOf(id uuid.UUID) (uuid.UUID, error) {
+	for _, owned := range d.IDs() {
+		if id == owned {
+			return d.customer.ID, nil
+		}
+	}
+	return uuid.Nil, sql.ErrNoRows
+}
+
+// The repositories of the isolation test find the records of the owner
+// tenant by ID, and the tenant of a record by its ID. Everything else goes
+// to the isolation database, which holds no rows.
+
+type customerRepo struct {
+	customers.Repository
+	data *tenantData
+}
+
+// FindByID finds the owner, and any other tenant with room for more records
+// so the requests of the caller reach their repository
+func (r customerRepo) FindByID(ctx context.Context, id uuid.UUID) (customers.Customer, error) {
+	if id == r.data.customer.ID {
+		return r.data.customer, nil
+	}
+	customer := r.data.customer
+	customer.ID, customer.Name = id, "Tenant"
+	return customer, nil
+}
+
+type userRepo struct {
+	users.Repository
+	data *tenantData
+}
+
+func (r userRepo) FindByID(ctx context.Context, id uuid.UUID) (users.User, error) {
+	if id != r.data.user.ID {
+		return r.Repository.FindByID(ctx, id)
+	}
+	return r.data.user, nil
+}
+
+func (r userRepo) FindDeletedByID(ctx context.Context, id uuid.UUID) (users.User, error) {
+	if id != r.data.user.ID {
+		return users.User{}, sql.ErrNoRows
+	}
+	return r.data.user, nil
+}
+
+type roleRepo struct {
+	roles.Repository
+	data *tenantData
+}
+
+func (r roleRepo) FindByID(ctx context.Context, id uuid.UUID) (roles.Role, error) {
+	if id != r.data.role.ID.UUID {
+		return roles.Role{}, sql.ErrNoRows
+	}
+	return r.data.role, nil
+}
+
+type shopfloorRepo struct {
+	shopfloors.Repository
+	data *tenantData
+}
+
+func (r shopfloorRepo) FindByID(ctx context.Context, id uuid.UUID) (shopfloors.Shopfloor, error) {
+	if id != r.data.shopfloor.ID {
+		return shopfloors.Shopfloor{}, sql.ErrNoRows
+	}
+	return r.data.shopfloor, nil
+}
+
+func (r shopfloorRepo) FindDeletedByID(ctx context.Context, id uuid.UUID) (shopfloors.Shopfloor, error) {
+	return r.FindByID(ctx, id)
+}
+
+type workcenterRepo struct {
+	workcenters.Repository
+	data *tenantData
+}
+
+func (r workcenterRepo) FindByID(ctx context.Context, id uuid.UUID) (workcenters.Workcenter, error) {
+	if id != r.data.workcenter.ID {
+		return workcenters.Workcenter{}, sql.ErrNoRows
+	}
+	return r.data.workcenter, nil
+}
+
+func (r workcenterRepo) FindDeletedByID(ctx context.Context, id uuid.UUID) (workcenters.Workcenter, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r workcenterRepo) FindShopFloorCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+type operatorRepo struct {
+	operators.Repository
+	data *tenantData
+}
+
+func (r operatorRepo) FindByID(ctx context.Context, id uuid.UUID) (operators.Operator, error) {
+	if id != r.data.operator.ID {
+		return operators.Operator{}, sql.ErrNoRows
+	}
+	return r.data.operator, nil
+}
+
+func (r operatorRepo) FindByCode(ctx context.Context, code string, customerID *uuid.UUID) (operators.Operator, error) {
+	if code != r.data.operator.Code || (customerID != nil && *customerID != r.data.customer.ID) {
+		return operators.Operator{}, sql.ErrNoRows
+	}
+	return r.data.operator, nil
+}
+
+func (r operatorRepo) FindDeletedByID(ctx context.Context, id uuid.UUID) (operators.Operator, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r operatorRepo) FindShopFloorCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+type jobRepo struct {
+	jobs.Repository
+	data *tenantData
+}
+
+func (r jobRepo) FindByID(ctx context.Context, id uuid.UUID) (jobs.Job, error) {
+	if id != r.data.job.ID {
+		return jobs.Job{}, sql.ErrNoRows
+	}
+	return r.data.job, nil
+}
+
+func (r jobRepo) FindDeletedByID(ctx context.Context, id uuid.UUID) (jobs.Job, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r jobRepo) FindShopFloorCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+func (r jobRepo) FindWorkcenterCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+type paymentRepo struct {
+	payments.Repository
+	data *tenantData
+}
+
+func (r paymentRepo) FindById(ctx context.Context, id uuid.UUID) (payments.Payment, error) {
+	if id != r.data.payment.ID {
+		return payments.Payment{}, sql.ErrNoRows
+	}
+	return r.data.payment, nil
+}
+
+type shiftRepo struct {
+	shifts.Repository
+	data *tenantData
+}
+
+func (r shiftRepo) FindByID(ctx context.Context, id uuid.UUID) (shifts.Shift, error) {
+	if id != r.data.shift.ID {
+		return shifts.Shift{}, sql.ErrNoRows
+	}
+	return r.data.shift, nil
+}
+
+func (r shiftRepo) FindDeletedByID(ctx context.Context, id uuid.UUID) (shifts.Shift, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r shiftRepo) FindShopFloorCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+type scheduleEntryRepo struct {
+	scheduleentries.Repository
+	data *tenantData
+}
+
+func (r scheduleEntryRepo) FindByID(ctx context.Context, id uuid.UUID) (scheduleentries.ScheduleEntry, error) {
+	if id != r.data.scheduleEntry.ID {
+		return scheduleentries.ScheduleEntry{}, sql.ErrNoRows
+	}
+	return r.data.scheduleEntry, nil
+}
+
+func (r scheduleEntryRepo) FindDeletedByID(ctx context.Context, id uuid.UUID) (scheduleentries.ScheduleEntry, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r scheduleEntryRepo) FindShopfloorCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+func (r scheduleEntryRepo) FindOperatorCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+func (r scheduleEntryRepo) FindShiftCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+func (r scheduleEntryRepo) FindJobCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+func (r scheduleEntryRepo) FindWorkcenterCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+type timeEntryRepo struct {
+	timeentries.Repository
+	data *tenantData
+}
+
+func (r timeEntryRepo) FindByID(ctx context.Context, id uuid.UUID) (timeentries.TimeEntry, error) {
+	if id != r.data.timeEntry.ID {
+		return timeentries.TimeEntry{}, sql.ErrNoRows
+	}
+	return r.data.timeEntry, nil
+}
+
+func (r timeEntryRepo) FindCurrent(ctx context.Context, operatorID uuid.UUID) (timeentries.TimeEntry, error) {
+	if operatorID != r.data.timeEntry.OperatorID {
+		return timeentries.TimeEntry{}, sql.ErrNoRows
+	}
+	return r.data.timeEntry, nil
+}
+
+func (r timeEntryRepo) FindDeletedByID(ctx context.Context, id uuid.UUID) (timeentries.TimeEntry, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r timeEntryRepo) FindOperatorCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+func (r timeEntryRepo) FindOwnerCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+func (r timeEntryRepo) FindWorkcenterCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+type webhookRepo struct {
+	webhooks.Repository
+	data *tenantData
+}
+
+func (r webhookRepo) FindByID(ctx context.Context, id uuid.UUID) (webhooks.Endpoint, error) {
+	if id != r.data.endpoint.ID {
+		return webhooks.Endpoint{}, sql.ErrNoRows
+	}
+	return r.data.endpoint, nil
+}
+
+func (r webhookRepo) FindDeliveryByID(ctx context.Context, id uuid.UUID) (webhooks.Delivery, error) {
+	if id != r.data.delivery.ID {
+		return webhooks.Delivery{}, sql.ErrNoRows
+	}
+	return r.data.delivery, nil
+}
+
+type apiKeyRepo struct {
+	apikeys.Repository
+	data *tenantData
+}
+
+func (r apiKeyRepo) FindByID(ctx context.Context, id uuid.UUID) (apikeys.APIKey, error) {
+	if id != r.data.apiKey.ID {
+		return apikeys.APIKey{}, sql.ErrNoRows
+	}
+	return r.data.apiKey, nil
+}
+
+type downtimeRepo struct {
+	downtimes.Repository
+	data *tenantData
+}
+
+func (r downtimeRepo) FindReasonByID(ctx context.Context, id uuid.UUID) (downtimes.Reason, error) {
+	if id != r.data.reason.ID {
+		return downtimes.Reason{}, sql.ErrNoRows
+	}
+	return r.data.reason, nil
+}
+
+func (r downtimeRepo) FindByID(ctx context.Context, id uuid.UUID) (downtimes.Downtime, error) {
+	if id != r.data.downtime.ID {
+		return downtimes.Downtime{}, sql.ErrNoRows
+	}
+	return r.data.downtime, nil
+}
+
+func (r downtimeRepo) FindWorkcenterCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+type oeeRepo struct {
+	oee.Repository
+	data *tenantData
+}
+
+func (r oeeRepo) FindCountByID(ctx context.Context, id uuid.UUID) (oee.ProductionCount, error) {
+	if id != r.data.count.ID {
+		return oee.ProductionCount{}, sql.ErrNoRows
+	}
+	return r.data.count, nil
+}
+
+func (r oeeRepo) FindWorkcenterCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+func (r oeeRepo) FindJobCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+type machineRepo struct {
+	machines.Repository
+	data *tenantData
+}
+
+func (r machineRepo) FindByID(ctx context.Context, id uuid.UUID) (machines.MachineSignal, error) {
+	if id != r.data.signal.ID {
+		return machines.MachineSignal{}, sql.ErrNoRows
+	}
+	return r.data.signal, nil
+}
+
+func (r machineRepo) FindWorkcenterCustomerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return r.data.customerOf(id)
+}
+
+// scopeResolver restricts every user to its shop floors
+type scopeResolver []uuid.UUID
+
+func (r scopeResolver) AllowedShopfloors(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, bool, error) {
+	return r, true, nil
+}
+
+// isolationRouter serves the API as server.Setup does, for a user of
+// customerID and on top of the records of data. The user is restricted to
+// the allowed shop floors when there are some. Permissions aren't checked:
+// the test is about the records a request reaches, not about who may send it.
+func isolationRouter(t *testing.T, customerID uuid.UUID, data *tenantData, db *sql.DB, allowed ...uuid.UUID) *gin.Engine {
+	cfg := config.Config{}
+	auditService := audit.NewService(audit.NewRepository(db))
+	customerService := customers.NewService(customerRepo{customers.NewRepository(db), data}, auditService)
+	roleService := roles.NewService(roleRepo{roles.NewRepository(db), data})
+	userService := users.NewService(userRepo{users.NewRepository(db), data}, customerService, roleService, auditService)
+	authService := auth.NewAuthService(userService, customerService, nil, auth.NewRepository(db), nil, cfg)
+
+	userHandler := users.NewHandler(userService)
+	authHandler := auth.NewAuthHandler(authService, nil)
+	roleHandler := roles.NewHandler(roleService)
+	customerHandler := customers.NewHandler(customerService)
+	ssoHandler := sso.NewHandler(sso.NewService(sso.NewRepository(db), customerService, userService, roleService, authService, cfg), "")
+	operatorHandler := operators.NewHandler(operators.NewService(operatorRepo{operators.NewRepository(db), data}, customerService, auditService))
+	jobHandler := jobs.NewHandler(jobs.NewService(jobRepo{jobs.NewRepository(db), data}, customerService, auditService))
+	paymentHandler := payments.NewHandler(payments.NewService(paymentRepo{payments.NewRepository(db), data}, auditService))
+	shopfloorHandler := shopfloors.NewHandler(shopfloors.NewService(shopfloorRepo{shopfloors.NewRepository(db), data}, customerService, auditService))
+	workcenterHandler := workcenters.NewHandler(workcenters.NewService(workcenterRepo{workcenters.NewRepository(db), data}, customerService, auditService))
+	shiftHandler := shifts.NewHandler(shifts.NewService(shiftRepo{shifts.NewRepository(db), data}, auditService))
+	scheduleEntryHandler := scheduleentries.NewHandler(scheduleentries.NewService(scheduleEntryRepo{scheduleentries.NewRepository(db), data}, auditService))
+	timeEntryHandler := timeentries.NewHandler(timeentries.NewService(timeEntryRepo{timeentries.NewRepository(db), data}, auditService))
+	webhookHandler := webhooks.NewHandler(webhooks.NewService(webhookRepo{webhooks.NewRepository(db), data}))
+	apiKeyHandler := apikeys.NewHandler(apikeys.NewService(apiKeyRepo{apikeys.NewRepository(db), data}, roleService))
+	downtimeHandler := downtimes.NewHandler(downtimes.NewService(downtimeRepo{downtimes.NewRepository(db), data}))
+	oeeHandler := oee.NewHandler(oee.NewService(oeeRepo{oee.NewRepository(db), data}))
+	machineHandler := machines.NewHandler(machines.NewService(machineRepo{machines.NewRepository(db), data}))
+	auditHandler := audit.NewHandler(auditService)
+
+	router := gin.New()
+	router.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
+		t.Errorf("%s %s panicked: %v", c.Request.Method, c.Request.URL.Path, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}), middleware.ErrorMiddleware())
+	api := router.Group("/api", func(c *gin.Context) {
+		c.Set("id", &middleware.AuthUser{ID: uuid.NewString(), CustomerID: customerID.String()})
+	}, middleware.ContextMiddleware())
+	if len(allowed) > 0 {
+		api.Use(middleware.ShopfloorScopeMiddleware(scopeResolver(allowed)))
+	}
+	auth.RegisterSessionRoutes(api, authHandler)
+	users.RegisterRoutes(api, &userHandler)
+	auth.RegisterUserRoutes(api, authHandler)
+	roles.RegisterRoutes(api, &roleHandler)
+	customers.RegisterRoutes(api, &customerHandler)
+	sso.RegisterConfigRoutes(api, &ssoHandler)
+	operators.RegisterRoutes(api, &operatorHandler)
+	jobs.RegisterRoutes(api, &jobHandler)
+	payments.RegisterRoutes(api, &paymentHandler)
+	shopfloors.RegisterRoutes(api, &shopfloorHandler)
+	workcenters.RegisterRoutes(api, &workcenterHandler)
+	shifts.RegisterRoutes(api, &shiftHandler)
+	scheduleentries.RegisterRoutes(api, &scheduleEntryHandler)
+	timeentries.RegisterRoutes(api, &timeEntryHandler)
+	webhooks.RegisterRoutes(api, &webhookHandler)
+	apikeys.RegisterRoutes(api, &apiKeyHandler)
+	downtimes.RegisterRoutes(api, &downtimeHandler)
+	oee.RegisterRoutes(api, &oeeHandler)
+	machines.RegisterRoutes(api, &machineHandler)
+	audit.RegisterRoutes(api, &auditHandler)
+	return router
+}
+
+// check is what a route must answer to a user of another tenant
+type check int
+
+const (
+	// owned routes address a record: they answer 404, as if it didn't exist
+	owned check = iota
+	// onShopfloor routes are owned, and answer 404 to the users restricted
+	// to other shop floors of the tenant too
+	onShopfloor
+	// scoped routes list, create or report on the tenant of the user,
+	// whatever tenant the request names
+	scoped
+	// adminOnly routes turn every tenant user away with a 403 (or a 401)
+	adminOnly
+	// personal routes act on the account of the user and take no ID
+	personal
+	// catalogue routes return the same fixed list to every tenant
+	catalogue
+)
+
+// rule is how a route is checked, with the body of its write requests
+type rule struct {
+	check check
+	body  interface{}
+}
+
+// upload is a multipart body with a file
+type upload struct {
+	fields map[string]string
+	file   string
+}
+
+// isolationRules covers every route of the API. The requests name the
+// records of the owner tenant in their path, body and query.
+func isolationRules(d *tenantData) map[string]rule {
+	owner := d.customer.ID
+	now := time.Now()
+	userBody := gin.H{"customer_id": owner, "username": "owner", "email": "owner@example.com"}
+	roleBody := gin.H{"customer_id": owner, "code": "custom", "name": "Custom"}
+	shopfloorBody := gin.H{"customer_id": owner, "parent_id": d.shopfloor.ID, "kind": shopfloors.KindArea, "name": "Plant"}
+	workcenterBody := gin.H{"customer_id": owner, "shop_floor_id": d.shopfloor.ID, "name": "Press"}
+	operatorBody := gin.H{"customer_id": owner, "shop_floor_id": d.shopfloor.ID, "code": "OP1", "name": "Operator"}
+	jobBody := gin.H{"customer_id": owner, "shop_floor_id": d.shopfloor.ID, "workcenter_id": d.workcenter.ID, "job_code": "J1"}
+	paymentBody := gin.H{"customer_id": owner, "amount": 10}
+	shiftBody := gin.H{"customer_id": owner, "shopfloor_id": d.shopfloor.ID, "name": "Morning", "start_time": "06:00", "end_time": "14:00"}
+	entryBody := gin.H{"customer_id": owner, "shopfloor_id": d.shopfloor.ID, "shift_id": d.shift.ID, "workcenter_id": d.workcenter.ID,
+		"job_id": d.job.ID, "operator_id": d.operator.ID, "date": now.Format("2006-01-02")}
+	timeEntryBody := gin.H{"operator_id": d.operator.ID, "workcenter_id": d.workcenter.ID, "check_in": now}
+	webhookBody := gin.H{"customer_id": owner, "url": "https://93.184.216.34/hooks"}
+	reasonBody := gin.H{"customer_id": owner, "code": "JAM", "name": "Jam"}
+	downtimeBody := gin.H{"customer_id": owner, "workcenter_id": d.workcenter.ID, "reason_id": d.reason.ID, "start_time": now}
+	signalBody := gin.H{"workcenter_id": d.workcenter.ID, "topic": "press/state", "signal": "state"}
+
+	return map[string]rule{
+		"POST /api/auth/logout":             {personal, nil},
+		"POST /api/auth/logout-all":         {personal, nil},
+		"GET /api/auth/2fa":                 {personal, nil},
+		"GET /api/auth/impersonations":      {personal, nil},
+		"POST /api/auth/2fa/enroll":         {personal, nil},
+		"POST /api/auth/2fa/confirm":        {personal, nil},
+		"POST /api/auth/2fa/disable":        {personal, nil},
+		"POST /api/auth/2fa/recovery-codes": {personal, nil},
+
+		"POST /api/users":                      {scoped, userBody},
+		"GET /api/users":                       {scoped, nil},
+		"GET /api/users/trash":                 {scoped, nil},
+		"GET /api/users/customer/:customer_id": {scoped, nil},
+		"GET /api/users/logins":                {scoped, nil},
+		"POST /api/users/invite":               {scoped, userBody},
+		"GET /api/users/:id":                   {owned, nil},
+		"PUT /api/users/:id":                   {owned, userBody},
+		"DELETE /api/users/:id":                {owned, nil},
+		"POST /api/users/:id/restore":          {owned, nil},
+		"GET /api/users/:id/shopfloors":        {owned, nil},
+		"PUT /api/users/:id/shopfloors":        {owned, gin.H{"shopfloor_ids": []uuid.UUID{d.shopfloor.ID}}},
+		"POST /api/users/:id/invite":           {owned, nil},
+		"DELETE /api/users/:id/two-factor":     {owned, nil},
+		"POST /api/users/:id/unlock":           {owned, nil},
+		"POST /api/users/:id/impersonate":      {adminOnly, gin.H{"reason": "Support"}},
+
+		"GET /api/roles/permissions": {catalogue, nil},
+		"POST /api/roles":            {scoped, roleBody},
+		"GET /api/roles":             {scoped, nil},
+		"PUT /api/roles/:id":         {owned, roleBody},
+		"DELETE /api/roles/:id":      {owned, nil},
+
+		"POST /api/customers":               {adminOnly, gin.H{"name": "Owner"}},
+		"GET /api/customers":                {scoped, nil},
+		"GET /api/customers/:id":            {owned, nil},
+		"PUT /api/customers/:id":            {adminOnly, gin.H{"name": "Owner"}},
+		"DELETE /api/customers/:id":         {adminOnly, nil},
+		"PUT /api/customers/:id/two-factor": {adminOnly, gin.H{"required": true}},
+		"GET /api/customers/:id/sso":        {adminOnly, nil},
+		"PUT /api/customers/:id/sso":        {adminOnly, gin.H{"issuer": "https://id.example.com", "client_id": "planner"}},
+		"DELETE /api/customers/:id/sso":     {adminOnly, nil},
+
+		"POST /api/operators":                       {scoped, operatorBody},
+		"GET /api/operators":                        {scoped, nil},
+		"GET /api/operators/trash":                  {scoped, nil},
+		"GET /api/operators/shopfloor/:shopFloorID": {scoped, nil},
+		"GET /api/operators/code/:code":             {onShopfloor, nil},
+		"GET /api/operators/:id":                    {onShopfloor, nil},
+		"PUT /api/operators/:id":                    {onShopfloor, operatorBody},
+		"DELETE /api/operators/:id":                 {onShopfloor, nil},
+		"POST /api/operators/:id/restore":           {onShopfloor, nil},
+
+		"POST /api/jobs":                         {scoped, jobBody},
+		"GET /api/jobs":                          {scoped, nil},
+		"GET /api/jobs/trash":                    {scoped, nil},
+		"GET /api/jobs/lateness":                 {scoped, nil},
+		"POST /api/jobs/import":                  {scoped, upload{map[string]string{"customer_id": owner.String()}, "job_code,workcenter,shop_floor\nJ2,Press,Plant\n"}},
+		"GET /api/jobs/customer/:customerID":     {scoped, nil},
+		"GET /api/jobs/shopfloor/:shopfloorID":   {scoped, nil},
+		"GET /api/jobs/workcenter/:workcenterID": {scoped, nil},
+		"GET /api/jobs/:id":                      {onShopfloor, nil},
+		"PUT /api/jobs/:id":                      {onShopfloor, jobBody},
+		"DELETE /api/jobs/:id":                   {onShopfloor, nil},
+		"POST /api/jobs/:id/restore":             {onShopfloor, nil},
+
+		"POST /api/payments":                      {adminOnly, paymentBody},
+		"GET /api/payments":                       {adminOnly, nil},
+		"GET /api/payments/customer/:customer_id": {adminOnly, nil},
+		"GET /api/payments/:id":                   {adminOnly, nil},
+		"PUT /api/payments/:id":                   {adminOnly, paymentBody},
+		"DELETE /api/payments/:id":                {adminOnly, nil},
+
+		"POST /api/shopfloors":                      {scoped, shopfloorBody},
+		"GET /api/shopfloors":                       {scoped, nil},
+		"GET /api/shopfloors/trash":                 {scoped, nil},
+		"GET /api/shopfloors/tree":                  {scoped, nil},
+		"GET /api/shopfloors/customer/:customer_id": {scoped, nil},
+		"GET /api/shopfloors/:id":                   {onShopfloor, nil},
+		"PUT /api/shopfloors/:id":                   {onShopfloor, shopfloorBody},
+		"DELETE /api/shopfloors/:id":                {onShopfloor, nil},
+		"POST /api/shopfloors/:id/restore":          {onShopfloor, nil},
+		"GET /api/shopfloors/:id/subtree":           {onShopfloor, nil},
+
+		"POST /api/workcenters":                       {scoped, workcenterBody},
+		"GET /api/workcenters":                        {scoped, nil},
+		"GET /api/workcenters/trash":                  {scoped, nil},
+		"GET /api/workcenters/utilization":            {scoped, nil},
+		"GET /api/workcenters/customer/:customerID":   {scoped, nil},
+		"GET /api/workcenters/shopfloor/:shopFloorID": {scoped, nil},
+		"GET /api/workcenters/:id":                    {onShopfloor, nil},
+		"PUT /api/workcenters/:id":                    {onShopfloor, workcenterBody},
+		"DELETE /api/workcenters/:id":                 {onShopfloor, nil},
+		"POST /api/workcenters/:id/restore":           {onShopfloor, nil},
+		"GET /api/workcenters/:id/calendar":           {onShopfloor, nil},
+		"PUT /api/workcenters/:id/calendar":           {onShopfloor, gin.H{"date": now.Format("2006-01-02"), "hours_per_shift": 4}},
+		"DELETE /api/workcenters/:id/calendar/:date":  {onShopfloor, nil},
+
+		"POST /api/shifts":                       {scoped, shiftBody},
+		"GET /api/shifts":                        {scoped, nil},
+		"GET /api/shifts/trash":                  {scoped, nil},
+		"GET /api/shifts/shopfloor/:shopfloorID": {scoped, nil},
+		"GET /api/shifts/:id":                    {onShopfloor, nil},
+		"PUT /api/shifts/:id":                    {onShopfloor, shiftBody},
+		"DELETE /api/shifts/:id":                 {onShopfloor, nil},
+		"POST /api/shifts/:id/restore":           {onShopfloor, nil},
+
+		"POST /api/schedule-entries":             {scoped, entryBody},
+		"GET /api/schedule-entries":              {scoped, nil},
+		"GET /api/schedule-entries/trash":        {scoped, nil},
+		"GET /api/schedule-entries/filtered":     {scoped, nil},
+		"GET /api/schedule-entries/validate":     {scoped, nil},
+		"POST /api/schedule-entries/sync":        {scoped, gin.H{"shopfloor_id": d.shopfloor.ID, "date": now.Format("2006-01-02"), "entries": []gin.H{}}},
+		"GET /api/schedule-entries/:id":          {onShopfloor, nil},
+		"PUT /api/schedule-entries/:id":          {onShopfloor, entryBody},
+		"DELETE /api/schedule-entries/:id":       {onShopfloor, nil},
+		"POST /api/schedule-entries/:id/restore": {onShopfloor, nil},
+
+		"POST /api/time-entries":                      {scoped, timeEntryBody},
+		"GET /api/time-entries":                       {scoped, nil},
+		"GET /api/time-entries/trash":                 {scoped, nil},
+		"GET /api/time-entries/customer/:customer_id": {scoped, nil},
+		"GET /api/time-entries/operator/:operator_id": {scoped, nil},
+		"GET /api/time-entries/current/:operator_id":  {owned, nil},
+		"GET /api/time-entries/:id":                   {owned, nil},
+		"PUT /api/time-entries/:id":                   {owned, timeEntryBody},
+		"DELETE /api/time-entries/:id":                {owned, nil},
+		"POST /api/time-entries/:id/restore":          {owned, nil},
+
+		"GET /api/webhooks/events":                        {catalogue, nil},
+		"POST /api/webhooks":                              {scoped, webhookBody},
+		"GET /api/webhooks":                               {scoped, nil},
+		"GET /api/webhooks/:id":                           {owned, nil},
+		"PUT /api/webhooks/:id":                           {owned, webhookBody},
+		"DELETE /api/webhooks/:id":                        {owned, nil},
+		"GET /api/webhooks/:id/deliveries":                {owned, nil},
+		"POST /api/webhooks/deliveries/:deliveryID/retry": {owned, nil},
+
+		"POST /api/api-keys":       {scoped, gin.H{"customer_id": owner, "name": "CI", "scopes": []string{}}},
+		"GET /api/api-keys":        {scoped, nil},
+		"GET /api/api-keys/:id":    {owned, nil},
+		"DELETE /api/api-keys/:id": {owned, nil},
+
+		"POST /api/downtime-reasons":       {scoped, reasonBody},
+		"GET /api/downtime-reasons":        {scoped, nil},
+		"PUT /api/downtime-reasons/:id":    {owned, reasonBody},
+		"DELETE /api/downtime-reasons/:id": {owned, nil},
+		"GET /api/downtimes/report":        {scoped, nil},
+		"POST /api/downtimes":              {scoped, downtimeBody},
+		"GET /api/downtimes":               {scoped, nil},
+		"GET /api/downtimes/:id":           {owned, nil},
+		"PUT /api/downtimes/:id":           {owned, downtimeBody},
+		"DELETE /api/downtimes/:id":        {owned, nil},
+
+		"GET /api/oee":               {scoped, nil},
+		"POST /api/oee/counts":       {scoped, gin.H{"workcenter_id": d.workcenter.ID, "job_id": d.job.ID, "good_qty": 1}},
+		"GET /api/oee/counts":        {scoped, nil},
+		"DELETE /api/oee/counts/:id": {owned, nil},
+
+		"GET /api/machine-signals/events": {scoped, nil},
+		"POST /api/machine-signals":       {scoped, signalBody},
+		"GET /api/machine-signals":        {scoped, nil},
+		"GET /api/machine-signals/:id":    {owned, nil},
+		"PUT /api/machine-signals/:id":    {owned, signalBody},
+		"DELETE /api/machine-signals/:id": {owned, nil},
+
+		"GET /api/audit": {scoped, nil},
+	}
+}
+
+// isolationPath fills the params of a route with the records of the owner
+// tenant, and asks for the owner's records in the query of scoped lists
+func isolationPath(d *tenantData, route string, c check) string {
+	records := map[string]uuid.UUID{
+		"users": d.user.ID, "roles": d.role.ID.UUID, "customers": d.customer.ID, "operators": d.operator.ID,
+		"jobs": d.job.ID, "payments": d.payment.ID, "shopfloors": d.shopfloor.ID, "workcenters": d.workcenter.ID,
+		"shifts": d.shift.ID, "schedule-entries": d.scheduleEntry.ID, "time-entries": d.timeEntry.ID,
+		"webhooks": d.endpoint.ID, "api-keys": d.apiKey.ID, "downtime-reasons": d.reason.ID, "downtimes": d.downtime.ID,
+		"oee": d.count.ID, "machine-signals": d.signal.ID,
+	}
+	params := map[string]string{
+		"customer_id": d.customer.ID.String(), "customerID": d.customer.ID.String(),
+		"shopfloorID": d.shopfloor.ID.String(), "shopFloorID": d.shopfloor.ID.String(),
+		"workcenterID": d.workcenter.ID.String(), "operator_id": d.operator.ID.String(),
+		"deliveryID": d.delivery.ID.String(), "code": d.operator.Code, "date": time.Now().Format("2006-01-02"),
+	}
+
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+		if segment == ":id" {
+			segments[i] = records[segments[2]].String()
+		} else {
+			segments[i] = params[segment[1:]]
+		}
+	}
+	path := strings.Join(segments, "/")
+	if c == scoped {
+		path += "?customer_id=" + d.customer.ID.String()
+	}
+	return path
+}
+
+func serve(t *testing.T, router http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var payload bytes.Buffer
+	contentType := "application/json"
+	switch body := body.(type) {
+	case nil:
+	case upload:
+		form := multipart.NewWriter(&payload)
+		for name, value := range body.fields {
+			form.WriteField(name, value)
+		}
+		file, err := form.CreateFormFile("file", "jobs.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(body.file))
+		form.Close()
+		contentType = form.FormDataContentType()
+	default:
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", contentType)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+// apiRoutes returns the sorted "METHOD path" of the routes under /api
+func apiRoutes(routes gin.RoutesInfo) []string {
+	var keys []string
+	for _, route := range routes {
+		if strings.HasPrefix(route.Path, "/api/") {
+			keys = append(keys, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TestIsolationCoversRoutes makes sure the isolation tests serve every route
+// of the API and have a rule for it, so a new route can't skip them
+func TestIsolationCoversRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Config{}
+	cfg.Auth.Secret = "test-secret"
+	cfg.Auth.TTL = time.Hour
+	cfg.Email.From = "no-reply@example.com"
+	s := NewServer(cfg, nil)
+	if err := s.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	data := newTenantData(uuid.New())
+	db, _ := openIsolationDB(uuid.Nil)
+	served := map[string]bool{}
+	for _, key := range apiRoutes(isolationRouter(t, uuid.New(), data, db).Routes()) {
+		served[key] = true
+	}
+	rules := isolationRules(data)
+
+	registered := map[string]bool{}
+	for _, key := range apiRoutes(s.Routes()) {
+		registered[key] = true
+		if !served[key] {
+			t.Errorf("%s isn't served by the isolation router", key)
+		}
+		if _, ok := rules[key]; !ok {
+			t.Errorf("%s has no isolation rule", key)
+		}
+	}
+	for key := range rules {
+		if !registered[key] {
+			t.Errorf("the isolation rule of %s names no route", key)
+		}
+	}
+}
+
+// TestTenantIsolation sends every request of the API as a user of another
+// tenant, naming the records of the owner tenant. Routes on a record must
+// answer 404, as if the record didn't exist, the others must work on the
+// user's own tenant. None may reach the records of the owner in the database
+// nor return them.
+func TestTenantIsolation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	data, intruder := newTenantData(uuid.New()), uuid.New()
+	db, isolation := openIsolationDB(intruder, data.IDs()...)
+	router := isolationRouter(t, intruder, data, db)
+	rules := isolationRules(data)
+
+	for _, key := range apiRoutes(router.Routes()) {
+		rule := rules[key]
+		if rule.check == personal || rule.check == catalogue {
+			continue
+		}
+		method, route, _ := strings.Cut(key, " ")
+		path := isolationPath(data, route, rule.check)
+		res := serve(t, router, method, path, rule.body)
+		switch rule.check {
+		case owned, onShopfloor:
+			if res.Code != http.StatusNotFound {
+				t.Errorf("%s %s from another tenant answered %d, want 404: %s", method, path, res.Code, res.Body)
+			}
+		case adminOnly:
+			if res.Code != http.StatusForbidden && res.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s from a tenant user answered %d, want 403: %s", method, path, res.Code, res.Body)
+			}
+		case scoped:
+			for _, id := range data.IDs() {
+				if strings.Contains(res.Body.String(), id.String()) {
+					t.Errorf("%s %s from another tenant returned %s of the owner: %s", method, path, id, res.Body)
+				}
+			}
+		}
+		for _, statement := range isolation.Refusals() {
+			t.Errorf("%s %s from another tenant reached the records of the owner: %s", method, path, statement)
+		}
+	}
+}
+
+// TestTenantIsolationOwner makes sure the records of the isolation test are
+// found by their own tenant, so its 404s don't come from the setup
+func TestTenantIsolationOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	data := newTenantData(uuid.New())
+	db, _ := openIsolationDB(uuid.Nil)
+	router := isolationRouter(t, data.customer.ID, data, db)
+	rules := isolationRules(data)
+
+	for _, key := range apiRoutes(router.Routes()) {
+		rule := rules[key]
+		method, route, _ := strings.Cut(key, " ")
+		if method != http.MethodGet || (rule.check != owned && rule.check != onShopfloor) {
+			continue
+		}
+		path := isolationPath(data, route, rule.check)
+		if res := serve(t, router, method, path, nil); res.Code != http.StatusOK {
+			t.Errorf("GET %s from its tenant answered %d, want 200: %s", path, res.Code, res.Body)
+		}
+	}
+}
+
+// TestShopfloorIsolation sends the requests on the records of a shop floor
+// as a user of the same tenant restricted to another shop floor: they must
+// answer 404 as well
+func TestShopfloorIsolation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	data := newTenantData(uuid.New())
+	db, isolation := openIsolationDB(uuid.Nil, data.OnShopfloor()...)
+	router := isolationRouter(t, data.customer.ID, data, db, uuid.New())
+	rules := isolationRules(data)
+
+	for _, key := range apiRoutes(router.Routes()) {
+		rule := rules[key]
+		if rule.check != onShopfloor {
+			continue
+		}
+		method, route, _ := strings.Cut(key, " ")
+		path := isolationPath(data, route, rule.check)
+		if res := serve(t, router, method, path, rule.body); res.Code != http.StatusNotFound {
+			t.Errorf("%s %s from another shop floor answered %d, want 404: %s", method, path, res.Code, res.Body)
+		}
+		for _, statement := range isolation.Refusals() {
+			t.Errorf("%s %s from another shop floor reached its records: %s", method, path, statement)
+		}
+	}
+}
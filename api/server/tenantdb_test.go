@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// errRefused is what the isolation database answers to the statements about
+// records the caller must not reach
+var errRefused = errors.New("statement about a record of another tenant")
+
+// isolationDB is the database behind the repositories of the isolation test.
+// It holds no rows, and it refuses and records every statement whose
+// arguments name one of the refused IDs but not the tenant of the caller: a
+// service only sends those when it works on a record it should have hidden.
+// Statements bound to the tenant of the caller can't reach the records of
+// another one, whatever else they filter on.
+type isolationDB struct {
+	tenant  uuid.UUID
+	refused []uuid.UUID
+
+	mu      sync.Mutex
+	refusal []string
+}
+
+// openIsolationDB returns a database refusing the statements about refused
+// that aren't bound to tenant
+func openIsolationDB(tenant uuid.UUID, refused ...uuid.UUID) (*sql.DB, *isolationDB) {
+	db := &isolationDB{tenant: tenant, refused: refused}
+	return sql.OpenDB(db), db
+}
+
+// Refusals returns the statements refused since the last call
+func (d *isolationDB) Refusals() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	refusal := d.refusal
+	d.refusal = nil
+	return refusal
+}
+
+func (d *isolationDB) check(query string, args []driver.NamedValue) error {
+	var values []string
+	for _, arg := range args {
+		if b, ok := arg.Value.([]byte); ok {
+			values = append(values, string(b))
+		} else {
+			values = append(values, fmt.Sprint(arg.Value))
+		}
+	}
+	named := strings.Join(values, " ")
+	if d.tenant != uuid.Nil && strings.Contains(named, d.tenant.String()) {
+		return nil
+	}
+	for _, id := range d.refused {
+		if strings.Contains(named, id.String()) {
+			d.mu.Lock()
+			d.refusal = append(d.refusal, strings.Join(strings.Fields(query), " "))
+			d.mu.Unlock()
+			return errRefused
+		}
+	}
+	return nil
+}
+
+func (d *isolationDB) Connect(ctx context.Context) (driver.Conn, error) {
+	return isolationConn{d}, nil
+}
+
+func (d *isolationDB) Driver() driver.Driver {
+	return isolationDriver{d}
+}
+
+type isolationDriver struct{ db *isolationDB }
+
+func (d isolationDriver) Open(name string) (driver.Conn, error) {
+	return isolationConn{d.db}, nil
+}
+
+type isolationConn struct{ db *isolationDB }
+
+func (c isolationConn) Prepare(query string) (driver.Stmt, error) {
+	return isolationStmt{c.db, query}, nil
+}
+
+func (c isolationConn) Close() error { return nil }
+
+func (c isolationConn) Begin() (driver.Tx, error) { return isolationTx{}, nil }
+
+func (c isolationConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.db.check(query, args); err != nil {
+		return nil, err
+	}
+	return isolationRows{}, nil
+}
+
+func (c isolationConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.check(query, args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+type isolationStmt struct {
+	db    *isolationDB
+	query string
+}
+
+func (s isolationStmt) Close() error  { return nil }
+func (s isolationStmt) NumInput() int { return -1 }
+
+func (s isolationStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return isolationConn{s.db}.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s isolationStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return isolationConn{s.db}.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return values
+}
+
+type isolationTx struct{}
+
+func (isolationTx) Commit() error   { return nil }
+func (isolationTx) Rollback() error { return nil }
+
+// isolationRows is an empty result
+type isolationRows struct{}
+
+func (isolationRows) Columns() []string              { return nil }
+func (isolationRows) Close() error                   { return nil }
+func (isolationRows) Next(dest []driver.Value) error { return io.EOF }
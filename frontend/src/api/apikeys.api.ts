@@ -0,0 +1,42 @@
+import api from "./http";
+
+// Scopes are permissions of the role catalogue, e.g. "jobs:write"
+export interface ApiKey {
+  id: string;
+  customer_id: string;
+  name: string;
+  prefix: string;
+  scopes: string[];
+  expires_at: string | null;
+  last_used_at: string | null;
+  created_by: string | null;
+  created_at: string;
+  updated_at: string;
+  key?: string; // only returned on creation
+}
+
+export interface ApiKeyRequest {
+  customer_id?: string;
+  name: string;
+  scopes: string[];
+  expires_at?: string | null;
+}
+
+interface ApiResponse<T> {
+  data: T;
+  message: string;
+}
+
+export const apiKeysApi = {
+  list: async (): Promise<ApiResponse<ApiKey[]>> => {
+    const response = await api.get<ApiResponse<ApiKey[]>>("/api/api-keys");
+    return response.data;
+  },
+  create: async (data: ApiKeyRequest): Promise<ApiResponse<ApiKey>> => {
+    const response = await api.post<ApiResponse<ApiKey>>("/api/api-keys", data);
+    return response.data;
+  },
+  delete: async (id: string) => {
+    await api.delete(`/api/api-keys/${id}`);
+  },
+};
@@ -0,0 +1,46 @@
+import api from "./http";
+
+export interface AuditEntry {
+  id: string;
+  customer_id: string | null;
+  actor_id: string | null;
+  impersonated_by: string | null;
+  api_key_id: string | null;
+  request_id: string;
+  action: "create" | "update" | "delete" | "restore" | "request";
+  entity: string;
+  entity_id: string | null;
+  before: Record<string, unknown> | null; // only the fields that changed
+  after: Record<string, unknown> | null;
+  method?: string;
+  path?: string;
+  status?: number;
+  ip?: string;
+  created_at: string;
+}
+
+export interface AuditFilter {
+  customer_id?: string;
+  actor_id?: string;
+  entity?: string;
+  entity_id?: string;
+  action?: string;
+  request_id?: string;
+  from?: string; // RFC 3339
+  to?: string;
+  limit?: number;
+}
+
+interface ApiResponse<T> {
+  data: T;
+  message: string;
+}
+
+export const auditApi = {
+  list: async (params?: AuditFilter): Promise<ApiResponse<AuditEntry[]>> => {
+    const response = await api.get<ApiResponse<AuditEntry[]>>("/api/audit", {
+      params,
+    });
+    return response.data;
+  },
+};
@@ -6,21 +6,201 @@ export interface User {
   username: string;
   email: string;
   is_admin: boolean;
+  role: string; // built-in role code or a custom role of the tenant
   is_active: boolean;
+  email_verified_at: string | null;
 }
 
+// Access tokens are short lived; the refresh token renews them and rotates
+// on every use
 export interface LoginResponse {
   token: string;
   expire: string;
+  refresh_token: string;
+  refresh_expire: string;
   user: User;
+  recovery_codes?: string[]; // only when the login enrolled the user in 2FA
+}
+
+// Returned by login instead of the tokens when a second factor is needed.
+// With two_factor_setup_required the user must enroll before logging in.
+export interface ChallengeResponse {
+  two_factor_required: boolean;
+  two_factor_setup_required: boolean;
+  two_factor_token: string;
+  expire: string;
+}
+
+export interface TwoFactorEnrollment {
+  secret: string;
+  uri: string; // otpauth:// provisioning URI to show as a QR code
+}
+
+export interface TwoFactorStatus {
+  enabled_at: string | null;
+  enabled: boolean;
+  required: boolean;
+  recovery_codes_left: number;
+}
+
+// A period in which a system admin acted as the user
+export interface Impersonation {
+  id: string;
+  user_id: string;
+  admin_id: string | null;
+  admin_email: string;
+  reason: string;
+  started_at: string;
+  expires_at: string;
+  ended_at: string | null;
+}
+
+export function isChallenge(
+  response: LoginResponse | ChallengeResponse
+): response is ChallengeResponse {
+  return "two_factor_token" in response;
+}
+
+export interface RefreshResponse {
+  token: string;
+  expire: string;
+  refresh_token: string;
+  refresh_expire: string;
 }
 
 export const authApi = {
-  login: async (email: string, password: string): Promise<LoginResponse> => {
-    const response = await api.post<LoginResponse>("/auth/login", {
+  login: async (
+    email: string,
+    password: string
+  ): Promise<LoginResponse | ChallengeResponse> => {
+    const response = await api.post<LoginResponse | ChallengeResponse>("/auth/login", {
       email,
       password,
     });
     return response.data;
   },
+
+  verifyTwoFactor: async (
+    token: string,
+    code: string
+  ): Promise<LoginResponse> => {
+    const response = await api.post<LoginResponse>("/auth/2fa/verify", {
+      token,
+      code,
+    });
+    return response.data;
+  },
+
+  startEnrollment: async (token: string): Promise<TwoFactorEnrollment> => {
+    const response = await api.post<{ data: TwoFactorEnrollment }>(
+      "/auth/2fa/enroll",
+      { token }
+    );
+    return response.data.data;
+  },
+
+  finishEnrollment: async (
+    token: string,
+    code: string
+  ): Promise<LoginResponse> => {
+    const response = await api.post<LoginResponse>("/auth/2fa/enroll/confirm", {
+      token,
+      code,
+    });
+    return response.data;
+  },
+
+  twoFactorStatus: async (): Promise<TwoFactorStatus> => {
+    const response = await api.get<{ data: TwoFactorStatus }>("/api/auth/2fa");
+    return response.data.data;
+  },
+
+  enrollTwoFactor: async (): Promise<TwoFactorEnrollment> => {
+    const response = await api.post<{ data: TwoFactorEnrollment }>(
+      "/api/auth/2fa/enroll"
+    );
+    return response.data.data;
+  },
+
+  // Returns the recovery codes, which are only shown once
+  confirmTwoFactor: async (code: string): Promise<string[]> => {
+    const response = await api.post<{ data: string[] }>(
+      "/api/auth/2fa/confirm",
+      { code }
+    );
+    return response.data.data;
+  },
+
+  disableTwoFactor: async (code: string): Promise<void> => {
+    await api.post("/api/auth/2fa/disable", { code });
+  },
+
+  regenerateRecoveryCodes: async (code: string): Promise<string[]> => {
+    const response = await api.post<{ data: string[] }>(
+      "/api/auth/2fa/recovery-codes",
+      { code }
+    );
+    return response.data.data;
+  },
+
+  // Where the browser goes to log in with the identity provider of the
+  // customer the email domain belongs to
+  ssoLoginUrl: (email: string): string => {
+    const params = new URLSearchParams({ email });
+    return `${api.defaults.baseURL ?? ""}/auth/sso/login?${params}`;
+  },
+
+  // Trades the one-time code of a single sign-on for the tokens
+  exchangeLoginCode: async (code: string): Promise<LoginResponse> => {
+    const response = await api.post<LoginResponse>("/auth/sso/exchange", {
+      token: code,
+    });
+    return response.data;
+  },
+
+  refresh: async (refreshToken: string): Promise<RefreshResponse> => {
+    const response = await api.post<RefreshResponse>("/auth/refresh_token", {
+      refresh_token: refreshToken,
+    });
+    return response.data;
+  },
+
+  // Takes the token explicitly since the caller clears the stored one
+  logout: async (token: string): Promise<void> => {
+    await api.post("/api/auth/logout", null, {
+      headers: { Authorization: `Bearer ${token}` },
+    });
+  },
+
+  logoutAll: async (): Promise<void> => {
+    await api.post("/api/auth/logout-all");
+  },
+
+  impersonations: async (): Promise<Impersonation[]> => {
+    const response = await api.get<{ data: Impersonation[] }>(
+      "/api/auth/impersonations"
+    );
+    return response.data.data;
+  },
+
+  // Always succeeds so the answer doesn't reveal which emails have an account
+  forgotPassword: async (email: string): Promise<void> => {
+    await api.post("/auth/password/forgot", { email });
+  },
+
+  resetPassword: async (token: string, password: string): Promise<void> => {
+    await api.post("/auth/password/reset", { token, password });
+  },
+
+  verifyEmail: async (token: string): Promise<void> => {
+    await api.post("/auth/email/verify", { token });
+  },
+
+  resendVerification: async (email: string): Promise<void> => {
+    await api.post("/auth/email/resend", { email });
+  },
+
+  acceptInvitation: async (token: string, password: string): Promise<void> => {
+    await api.post("/auth/invitations/accept", { token, password });
+  },
 };
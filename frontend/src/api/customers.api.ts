@@ -1,4 +1,5 @@
 import api from "./http";
+import type { PageMeta } from "./pagination";
 
 export interface Customer {
   id: string;
@@ -24,6 +25,7 @@ export interface Customer {
   max_shop_floors: number;
   max_users: number;
   max_jobs: number;
+  require_two_factor: boolean;
   created_at: string;
   updated_at: string;
 }
@@ -65,6 +67,7 @@ export interface CustomerListParams {
 export interface CustomerListResponse {
   data: Customer[];
   message: string;
+  meta?: PageMeta;
 }
 
 // Response wrapper for single customer
@@ -73,6 +76,39 @@ export interface CustomerResponse {
   message: string;
 }
 
+// OpenID Connect single sign-on of a customer. Users log in at the issuer
+// and get the role of the first rule whose value is in role_claim.
+export interface SSORoleRule {
+  value: string;
+  role: string;
+}
+
+export interface SSOConfig {
+  customer_id: string;
+  issuer: string;
+  client_id: string;
+  scopes: string[];
+  domains: string[]; // email domains that log in through this customer
+  role_claim: string;
+  role_mapping: SSORoleRule[];
+  default_role: string;
+  enabled: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface SSOConfigRequest {
+  issuer: string;
+  client_id: string;
+  client_secret?: string; // empty keeps the current secret
+  scopes?: string[];
+  domains: string[];
+  role_claim?: string;
+  role_mapping: SSORoleRule[];
+  default_role?: string;
+  enabled: boolean;
+}
+
 export const customersApi = {
   list: async (params: CustomerListParams): Promise<CustomerListResponse> => {
     // Ensuring query params are handled
@@ -102,4 +138,35 @@ export const customersApi = {
     );
     return response.data;
   },
+
+  // Only system admins can require two-factor authentication for a tenant
+  setTwoFactor: async (
+    id: string,
+    required: boolean
+  ): Promise<CustomerResponse> => {
+    const response = await api.put<CustomerResponse>(
+      `/api/customers/${id}/two-factor`,
+      { required }
+    );
+    return response.data;
+  },
+
+  getSSO: async (id: string): Promise<SSOConfig> => {
+    const response = await api.get<{ data: SSOConfig }>(
+      `/api/customers/${id}/sso`
+    );
+    return response.data.data;
+  },
+
+  saveSSO: async (id: string, data: SSOConfigRequest): Promise<SSOConfig> => {
+    const response = await api.put<{ data: SSOConfig }>(
+      `/api/customers/${id}/sso`,
+      data
+    );
+    return response.data.data;
+  },
+
+  deleteSSO: async (id: string): Promise<void> => {
+    await api.delete(`/api/customers/${id}/sso`);
+  },
 };
@@ -0,0 +1,155 @@
+import api from "./http";
+import type { PageMeta } from "./pagination";
+
+export type DowntimeKind = "planned" | "unplanned";
+
+export interface DowntimeReason {
+  id: string;
+  customer_id: string;
+  code: string;
+  name: string;
+  kind: DowntimeKind;
+  is_active: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface DowntimeReasonRequest {
+  customer_id?: string;
+  code: string;
+  name: string;
+  kind: DowntimeKind;
+  is_active: boolean;
+}
+
+export interface Downtime {
+  id: string;
+  customer_id: string;
+  workcenter_id: string;
+  reason_id: string | null;
+  kind: DowntimeKind;
+  start_time: string;
+  end_time: string | null; // null while the workcenter is still down
+  notes: string;
+  source: "manual" | "machine";
+  created_at: string;
+  updated_at: string;
+}
+
+export interface DowntimeRequest {
+  customer_id?: string;
+  workcenter_id: string;
+  reason_id?: string;
+  kind?: DowntimeKind; // defaults to the kind of the reason
+  start_time: string;
+  end_time?: string | null;
+  notes?: string;
+}
+
+export interface DowntimeListParams {
+  customer_id?: string;
+  workcenter_id?: string;
+  reason_id?: string;
+  kind?: DowntimeKind;
+  from?: string; // YYYY-MM-DD or RFC3339
+  to?: string;
+}
+
+export interface DowntimeReasonSummary {
+  reason_id: string | null;
+  code: string;
+  name: string;
+  kind: DowntimeKind;
+  occurrences: number;
+  total_minutes: number;
+}
+
+export interface DowntimeReport {
+  from: string;
+  to: string;
+  reasons: DowntimeReasonSummary[];
+  total_minutes: number;
+}
+
+interface ApiResponse<T> {
+  data: T;
+  message: string;
+  meta?: PageMeta;
+}
+
+export const downtimesApi = {
+  listReasons: async (params?: {
+    customer_id?: string;
+  }): Promise<ApiResponse<DowntimeReason[]>> => {
+    const response = await api.get<ApiResponse<DowntimeReason[]>>(
+      "/api/downtime-reasons",
+      { params }
+    );
+    return response.data;
+  },
+  createReason: async (
+    data: DowntimeReasonRequest
+  ): Promise<ApiResponse<DowntimeReason>> => {
+    const response = await api.post<ApiResponse<DowntimeReason>>(
+      "/api/downtime-reasons",
+      data
+    );
+    return response.data;
+  },
+  updateReason: async (
+    id: string,
+    data: DowntimeReasonRequest
+  ): Promise<ApiResponse<DowntimeReason>> => {
+    const response = await api.put<ApiResponse<DowntimeReason>>(
+      `/api/downtime-reasons/${id}`,
+      data
+    );
+    return response.data;
+  },
+  deleteReason: async (id: string) => {
+    await api.delete(`/api/downtime-reasons/${id}`);
+  },
+  list: async (
+    params?: DowntimeListParams
+  ): Promise<ApiResponse<Downtime[]>> => {
+    const response = await api.get<ApiResponse<Downtime[]>>("/api/downtimes", {
+      params,
+    });
+    return response.data;
+  },
+  get: async (id: string): Promise<ApiResponse<Downtime>> => {
+    const response = await api.get<ApiResponse<Downtime>>(
+      `/api/downtimes/${id}`
+    );
+    return response.data;
+  },
+  create: async (data: DowntimeRequest): Promise<ApiResponse<Downtime>> => {
+    const response = await api.post<ApiResponse<Downtime>>(
+      "/api/downtimes",
+      data
+    );
+    return response.data;
+  },
+  update: async (
+    id: string,
+    data: DowntimeRequest
+  ): Promise<ApiResponse<Downtime>> => {
+    const response = await api.put<ApiResponse<Downtime>>(
+      `/api/downtimes/${id}`,
+      data
+    );
+    return response.data;
+  },
+  delete: async (id: string) => {
+    await api.delete(`/api/downtimes/${id}`);
+  },
+  report: async (
+    params?: DowntimeListParams
+  ): Promise<ApiResponse<DowntimeReport>> => {
+    const response = await api.get<ApiResponse<DowntimeReport>>(
+      "/api/downtimes/report",
+      { params }
+    );
+    return response.data;
+  },
+};
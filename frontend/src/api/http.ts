@@ -21,13 +21,56 @@ api.interceptors.request.use(
   }
 );
 
+// Concurrent 401s share a single refresh, since each refresh token works once
+let refreshing: Promise<string> | null = null;
+
+function refreshAccessToken(): Promise<string> {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem("refresh_token");
+    refreshing = (
+      refreshToken
+        ? axios
+            .post(`${api.defaults.baseURL ?? ""}/auth/refresh_token`, {
+              refresh_token: refreshToken,
+            })
+            .then((response) => {
+              localStorage.setItem("token", response.data.token);
+              localStorage.setItem("refresh_token", response.data.refresh_token);
+              return response.data.token as string;
+            })
+        : Promise.reject(new Error("no refresh token"))
+    ).finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+}
+
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
+  async (error) => {
+    const request = error.config;
+    // Login endpoints answer 401 for wrong credentials or codes; the view handles those
+    if (request?.url?.startsWith("/auth/")) {
+      return Promise.reject(error);
+    }
     if (error.response && error.response.status === 401) {
+      // Renew the access token once and replay the request
+      if (request && !request._retried) {
+        request._retried = true;
+        try {
+          const token = await refreshAccessToken();
+          request.headers.Authorization = `Bearer ${token}`;
+          return api(request);
+        } catch (e) {
+          // Fall through to the login page
+        }
+      }
       // Clear auth data
       localStorage.removeItem("token");
+      localStorage.removeItem("refresh_token");
       localStorage.removeItem("user");
+      localStorage.removeItem("admin_session");
       // Force reload/redirect to login
       window.location.href = "/login";
     }
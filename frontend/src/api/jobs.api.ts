@@ -1,4 +1,5 @@
 import api from "./http";
+import type { PageMeta } from "./pagination";
 
 export interface Job {
   id: string;
@@ -9,8 +10,13 @@ export interface Job {
   product_code: string;
   description: string;
   estimated_duration: number;
+  due_date: string | null;
+  priority: number;
+  customer_order_ref: string;
+  ideal_cycle_seconds: number; // ideal time per piece, used by OEE
   created_at: string;
   updated_at: string;
+  deleted_at?: string;
 }
 
 export interface JobRequest {
@@ -21,6 +27,21 @@ export interface JobRequest {
   product_code: string;
   description: string;
   estimated_duration: number;
+  due_date?: string | null;
+  priority?: number;
+  customer_order_ref?: string;
+  ideal_cycle_seconds?: number;
+}
+
+export interface LateJob extends Job {
+  last_scheduled_end: string;
+  delay_minutes: number;
+}
+
+export interface LatenessReport {
+  late: LateJob[];
+  unscheduled: Job[];
+  horizon_days: number;
 }
 
 export interface JobListParams {
@@ -36,6 +57,7 @@ export interface JobListParams {
 export interface JobListResponse {
   data: Job[];
   message: string;
+  meta?: PageMeta;
 }
 
 // Response wrapper for single job
@@ -44,6 +66,20 @@ export interface JobResponse {
   message: string;
 }
 
+export interface JobImportRowError {
+  row: number;
+  field?: string;
+  message: string;
+}
+
+export interface JobImportResult {
+  dry_run: boolean;
+  total_rows: number;
+  created: number;
+  updated: number;
+  errors: JobImportRowError[];
+}
+
 export const jobsApi = {
   list: async (params?: JobListParams): Promise<JobListResponse> => {
     const response = await api.get<JobListResponse>("/api/jobs", { params });
@@ -61,7 +97,43 @@ export const jobsApi = {
     const response = await api.put<JobResponse>(`/api/jobs/${id}`, data);
     return response.data;
   },
-  delete: async (id: string) => {
-    await api.delete(`/api/jobs/${id}`);
+  // Records with dependents answer 409 with the preview unless confirmed
+  delete: async (id: string, confirm = false) => {
+    await api.delete(`/api/jobs/${id}`, {
+      params: confirm ? { confirm: true } : undefined,
+    });
+  },
+  trash: async (params?: { customer_id?: string }): Promise<JobListResponse> => {
+    const response = await api.get<JobListResponse>("/api/jobs/trash", {
+      params,
+    });
+    return response.data;
+  },
+  restore: async (id: string): Promise<JobResponse> => {
+    const response = await api.post<JobResponse>(`/api/jobs/${id}/restore`);
+    return response.data;
+  },
+  lateness: async (params?: {
+    horizon_days?: number;
+    customer_id?: string;
+  }): Promise<{ data: LatenessReport; message: string }> => {
+    const response = await api.get("/api/jobs/lateness", { params });
+    return response.data;
+  },
+  import: async (
+    file: File,
+    options?: {
+      dryRun?: boolean;
+      mapping?: Record<string, string>;
+      customerId?: string;
+    }
+  ): Promise<{ data: JobImportResult; message: string }> => {
+    const form = new FormData();
+    form.append("file", file);
+    form.append("dry_run", String(options?.dryRun ?? false));
+    if (options?.mapping) form.append("mapping", JSON.stringify(options.mapping));
+    if (options?.customerId) form.append("customer_id", options.customerId);
+    const response = await api.post("/api/jobs/import", form);
+    return response.data;
   },
 };
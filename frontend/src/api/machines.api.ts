@@ -0,0 +1,93 @@
+import api from "./http";
+
+export type MachineSignalKind = "state" | "count" | "scrap";
+
+export interface MachineSignal {
+  id: string;
+  customer_id: string;
+  workcenter_id: string;
+  topic: string;
+  signal: MachineSignalKind;
+  is_active: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface MachineSignalRequest {
+  workcenter_id: string;
+  topic: string; // concrete MQTT topic, no wildcards
+  signal: MachineSignalKind;
+  is_active: boolean;
+}
+
+export interface MachineEvent {
+  id: string;
+  customer_id: string;
+  workcenter_id: string;
+  signal_id: string | null;
+  signal: MachineSignalKind;
+  state: "" | "run" | "stop";
+  quantity: number;
+  payload: string;
+  received_at: string;
+}
+
+export interface MachineEventParams {
+  customer_id?: string;
+  workcenter_id?: string;
+  limit?: number;
+}
+
+interface ApiResponse<T> {
+  data: T;
+  message: string;
+}
+
+export const machinesApi = {
+  listSignals: async (
+    customerId?: string
+  ): Promise<ApiResponse<MachineSignal[]>> => {
+    const response = await api.get<ApiResponse<MachineSignal[]>>(
+      "/api/machine-signals",
+      { params: customerId ? { customer_id: customerId } : undefined }
+    );
+    return response.data;
+  },
+  getSignal: async (id: string): Promise<ApiResponse<MachineSignal>> => {
+    const response = await api.get<ApiResponse<MachineSignal>>(
+      `/api/machine-signals/${id}`
+    );
+    return response.data;
+  },
+  createSignal: async (
+    data: MachineSignalRequest
+  ): Promise<ApiResponse<MachineSignal>> => {
+    const response = await api.post<ApiResponse<MachineSignal>>(
+      "/api/machine-signals",
+      data
+    );
+    return response.data;
+  },
+  updateSignal: async (
+    id: string,
+    data: MachineSignalRequest
+  ): Promise<ApiResponse<MachineSignal>> => {
+    const response = await api.put<ApiResponse<MachineSignal>>(
+      `/api/machine-signals/${id}`,
+      data
+    );
+    return response.data;
+  },
+  deleteSignal: async (id: string) => {
+    await api.delete(`/api/machine-signals/${id}`);
+  },
+  listEvents: async (
+    params?: MachineEventParams
+  ): Promise<ApiResponse<MachineEvent[]>> => {
+    const response = await api.get<ApiResponse<MachineEvent[]>>(
+      "/api/machine-signals/events",
+      { params }
+    );
+    return response.data;
+  },
+};
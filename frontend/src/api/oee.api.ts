@@ -0,0 +1,101 @@
+import api from "./http";
+
+export interface ProductionCount {
+  id: string;
+  customer_id: string;
+  workcenter_id: string;
+  job_id: string | null;
+  recorded_at: string;
+  good_qty: number;
+  scrap_qty: number;
+  source: "manual" | "machine";
+  created_at: string;
+}
+
+export interface ProductionCountRequest {
+  workcenter_id: string;
+  job_id?: string;
+  recorded_at?: string; // defaults to now
+  good_qty: number;
+  scrap_qty: number;
+}
+
+export interface OeeParams {
+  from?: string; // YYYY-MM-DD, defaults to today
+  to?: string;
+  shop_floor_id?: string;
+  workcenter_id?: string;
+  customer_id?: string;
+}
+
+// Ratios go from 0 to 1
+export interface OeeFigures {
+  planned_minutes: number;
+  planned_downtime_minutes: number;
+  unplanned_downtime_minutes: number;
+  run_minutes: number;
+  ideal_minutes: number;
+  good_qty: number;
+  scrap_qty: number;
+  availability: number;
+  performance: number;
+  quality: number;
+  oee: number;
+}
+
+export interface OeeCell extends OeeFigures {
+  date: string;
+  shift_id: string;
+  shift_name: string;
+  workcenter_id: string;
+  workcenter_name: string;
+}
+
+export interface OeeWorkcenterSummary extends OeeFigures {
+  workcenter_id: string;
+  customer_id: string;
+  workcenter_name: string;
+}
+
+export interface OeeReport {
+  from: string;
+  to: string;
+  cells: OeeCell[];
+  workcenters: OeeWorkcenterSummary[];
+  total: OeeFigures;
+}
+
+interface ApiResponse<T> {
+  data: T;
+  message: string;
+}
+
+export const oeeApi = {
+  report: async (params?: OeeParams): Promise<ApiResponse<OeeReport>> => {
+    const response = await api.get<ApiResponse<OeeReport>>("/api/oee", {
+      params,
+    });
+    return response.data;
+  },
+  listCounts: async (
+    params?: OeeParams
+  ): Promise<ApiResponse<ProductionCount[]>> => {
+    const response = await api.get<ApiResponse<ProductionCount[]>>(
+      "/api/oee/counts",
+      { params }
+    );
+    return response.data;
+  },
+  recordCount: async (
+    data: ProductionCountRequest
+  ): Promise<ApiResponse<ProductionCount>> => {
+    const response = await api.post<ApiResponse<ProductionCount>>(
+      "/api/oee/counts",
+      data
+    );
+    return response.data;
+  },
+  deleteCount: async (id: string) => {
+    await api.delete(`/api/oee/counts/${id}`);
+  },
+};
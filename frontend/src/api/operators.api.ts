@@ -1,4 +1,5 @@
 import api from "./http";
+import type { PageMeta } from "./pagination";
 
 export interface Operator {
   id: string;
@@ -11,6 +12,7 @@ export interface Operator {
   is_active: boolean;
   created_at: string;
   updated_at: string;
+  deleted_at?: string;
 }
 
 export interface OperatorRequest {
@@ -36,6 +38,7 @@ export interface OperatorListParams {
 export interface OperatorListResponse {
   data: Operator[];
   message: string;
+  meta?: PageMeta;
 }
 
 // Response wrapper for single operator
@@ -55,6 +58,15 @@ export const operatorsApi = {
     const response = await api.get<OperatorResponse>(`/api/operators/${id}`);
     return response.data;
   },
+  // Operators of the shop floor and of every area and line below it
+  listByShopFloor: async (
+    shopFloorId: string
+  ): Promise<OperatorListResponse> => {
+    const response = await api.get<OperatorListResponse>(
+      `/api/operators/shopfloor/${shopFloorId}`
+    );
+    return response.data;
+  },
   findByCode: async (code: string): Promise<OperatorResponse> => {
     const response = await api.get<OperatorResponse>(
       `/api/operators/code/${code}`
@@ -75,7 +87,20 @@ export const operatorsApi = {
     );
     return response.data;
   },
-  delete: async (id: string) => {
-    await api.delete(`/api/operators/${id}`);
+  // Records with dependents answer 409 with the preview unless confirmed
+  delete: async (id: string, confirm = false) => {
+    await api.delete(`/api/operators/${id}`, {
+      params: confirm ? { confirm: true } : undefined,
+    });
+  },
+  trash: async (params?: { customer_id?: string }): Promise<OperatorListResponse> => {
+    const response = await api.get<OperatorListResponse>("/api/operators/trash", {
+      params,
+    });
+    return response.data;
+  },
+  restore: async (id: string): Promise<OperatorResponse> => {
+    const response = await api.post<OperatorResponse>(`/api/operators/${id}/restore`);
+    return response.data;
   },
 };
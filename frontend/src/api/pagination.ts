@@ -0,0 +1,26 @@
+// Where a page of a list endpoint is, as returned in its "meta"
+export interface PageMeta {
+  total: number;
+  limit: number;
+  offset: number;
+  next_cursor?: string;
+}
+
+// Largest page the API returns, see listing.MaxLimit
+export const MAX_PAGE_SIZE = 1000;
+
+// fetchAll follows next_cursor until the last page, for the callers that need
+// every row of a list (name lookups, reports)
+export async function fetchAll<T>(
+  list: (params: any) => Promise<{ data: T[]; meta?: PageMeta }>,
+  params: Record<string, any> = {}
+): Promise<T[]> {
+  const rows: T[] = [];
+  let cursor: string | undefined;
+  do {
+    const page = await list({ ...params, limit: MAX_PAGE_SIZE, cursor });
+    rows.push(...(page.data || []));
+    cursor = page.meta?.next_cursor;
+  } while (cursor);
+  return rows;
+}
@@ -1,4 +1,5 @@
 import api from "./http";
+import type { PageMeta } from "./pagination";
 
 export interface Payment {
   id: string;
@@ -26,6 +27,7 @@ export interface PaymentRequest {
 export interface PaymentListResponse {
   data: Payment[];
   message: string;
+  meta?: PageMeta;
 }
 
 export interface PaymentResponse {
@@ -43,9 +45,13 @@ export const paymentsApi = {
     await api.delete(`/api/payments/${id}`);
   },
 
-  findByCustomer: async (customerId: string): Promise<PaymentListResponse> => {
+  findByCustomer: async (
+    customerId: string,
+    params?: any
+  ): Promise<PaymentListResponse> => {
     const response = await api.get<PaymentListResponse>(
-      `/api/payments/customer/${customerId}`
+      `/api/payments/customer/${customerId}`,
+      { params }
     );
     return response.data;
   },
@@ -0,0 +1,58 @@
+import api from "./http";
+
+export type BuiltInRole =
+  | "owner"
+  | "planner"
+  | "supervisor"
+  | "operator"
+  | "read_only";
+
+// Permissions look like "jobs:read" or "planning:write"
+export interface Role {
+  id: string | null; // null for built-in roles
+  customer_id: string | null;
+  code: string;
+  name: string;
+  permissions: string[];
+  is_built_in: boolean;
+  created_at?: string;
+  updated_at?: string;
+}
+
+export interface RoleRequest {
+  customer_id?: string;
+  code: string; // can't change after creation
+  name: string;
+  permissions: string[];
+}
+
+interface ApiResponse<T> {
+  data: T;
+  message: string;
+}
+
+export const rolesApi = {
+  permissions: async (): Promise<ApiResponse<string[]>> => {
+    const response = await api.get<ApiResponse<string[]>>(
+      "/api/roles/permissions"
+    );
+    return response.data;
+  },
+  list: async (customerId?: string): Promise<ApiResponse<Role[]>> => {
+    const response = await api.get<ApiResponse<Role[]>>("/api/roles", {
+      params: customerId ? { customer_id: customerId } : undefined,
+    });
+    return response.data;
+  },
+  create: async (data: RoleRequest): Promise<ApiResponse<Role>> => {
+    const response = await api.post<ApiResponse<Role>>("/api/roles", data);
+    return response.data;
+  },
+  update: async (id: string, data: RoleRequest): Promise<ApiResponse<Role>> => {
+    const response = await api.put<ApiResponse<Role>>(`/api/roles/${id}`, data);
+    return response.data;
+  },
+  delete: async (id: string) => {
+    await api.delete(`/api/roles/${id}`);
+  },
+};
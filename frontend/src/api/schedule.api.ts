@@ -26,7 +26,20 @@ export interface ScheduleEntryRequest {
   end_time?: string | null;
   is_completed: boolean;
 }
+
+// Planned entries overlapping a workcenter downtime; they don't block saving
+export interface ScheduleWarning {
+  entry_id: string;
+  workcenter_id: string;
+  downtime_id: string;
+  kind: "planned" | "unplanned";
+  reason: string;
+  start_time: string;
+  end_time?: string | null;
+  message: string;
+}
 import api from "./http";
+import { fetchAll } from "./pagination";
 
 export const scheduleApi = {
   create: async (data: ScheduleEntryRequest): Promise<ScheduleEntry> => {
@@ -59,12 +72,22 @@ export const scheduleApi = {
     shopfloorId: string,
     date: string,
     entries: ScheduleEntryRequest[]
-  ): Promise<void> => {
-    await api.post("/api/schedule-entries/sync", {
+  ): Promise<ScheduleWarning[]> => {
+    const response = await api.post<any>("/api/schedule-entries/sync", {
       shopfloor_id: shopfloorId,
       date,
       entries,
     });
+    return response.data.warnings || [];
+  },
+  validate: async (
+    shopfloorId: string,
+    date: string
+  ): Promise<ScheduleWarning[]> => {
+    const response = await api.get<any>("/api/schedule-entries/validate", {
+      params: { shopfloor_id: shopfloorId, date },
+    });
+    return response.data.data || [];
   },
   getOperatorPlanning: async (
     operatorId: string,
@@ -75,8 +98,12 @@ export const scheduleApi = {
     });
     return response.data.data || [];
   },
-  list: async (params?: any): Promise<ScheduleEntry[]> => {
-    const response = await api.get<any>("/api/schedule-entries", { params });
-    return response.data.data || [];
-  },
+  // Every entry matching params, the report shows them all
+  list: async (params?: any): Promise<ScheduleEntry[]> =>
+    fetchAll<ScheduleEntry>(async (page) => {
+      const response = await api.get<any>("/api/schedule-entries", {
+        params: page,
+      });
+      return response.data;
+    }, params),
 };
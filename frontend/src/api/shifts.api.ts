@@ -1,4 +1,5 @@
 import api from "./http";
+import type { PageMeta } from "./pagination";
 
 export interface Shift {
   id: string;
@@ -11,6 +12,7 @@ export interface Shift {
   is_active: boolean;
   created_at: string;
   updated_at: string;
+  deleted_at?: string;
 }
 
 export interface ShiftRequest {
@@ -37,6 +39,7 @@ export interface ShiftListParams {
 export interface ShiftListResponse {
   data: Shift[];
   message: string;
+  meta?: PageMeta;
 }
 
 // Response wrapper for single shift
@@ -76,7 +79,20 @@ export const shiftsApi = {
     const response = await api.put<ShiftResponse>(`/api/shifts/${id}`, data);
     return response.data;
   },
-  delete: async (id: string) => {
-    await api.delete(`/api/shifts/${id}`);
+  // Records with dependents answer 409 with the preview unless confirmed
+  delete: async (id: string, confirm = false) => {
+    await api.delete(`/api/shifts/${id}`, {
+      params: confirm ? { confirm: true } : undefined,
+    });
+  },
+  trash: async (params?: { customer_id?: string }): Promise<ShiftListResponse> => {
+    const response = await api.get<ShiftListResponse>("/api/shifts/trash", {
+      params,
+    });
+    return response.data;
+  },
+  restore: async (id: string): Promise<ShiftResponse> => {
+    const response = await api.post<ShiftResponse>(`/api/shifts/${id}/restore`);
+    return response.data;
   },
 };
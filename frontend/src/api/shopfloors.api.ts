@@ -1,18 +1,30 @@
 import api from "./http";
+import type { PageMeta } from "./pagination";
+
+export type ShopfloorKind = "site" | "area" | "line";
 
 export interface Shopfloor {
   id: string;
   customer_id: string;
+  parent_id: string | null;
+  kind: ShopfloorKind;
   name: string;
   created_at: string;
   updated_at: string;
+  deleted_at?: string;
 }
 
 export interface ShopfloorRequest {
   customer_id?: string;
+  parent_id?: string; // required for areas and lines
+  kind?: ShopfloorKind; // defaults to site
   name: string;
 }
 
+export interface ShopfloorNode extends Shopfloor {
+  children: ShopfloorNode[];
+}
+
 export interface ShopfloorListParams {
   page?: number;
   page_size?: number;
@@ -26,6 +38,7 @@ export interface ShopfloorListParams {
 export interface ShopfloorListResponse {
   data: Shopfloor[];
   message: string;
+  meta?: PageMeta;
 }
 
 // Response wrapper for single shopfloor
@@ -43,6 +56,22 @@ export const shopfloorsApi = {
     });
     return response.data;
   },
+  tree: async (
+    customerId?: string
+  ): Promise<{ data: ShopfloorNode[]; message: string }> => {
+    const response = await api.get<{ data: ShopfloorNode[]; message: string }>(
+      "/api/shopfloors/tree",
+      { params: customerId ? { customer_id: customerId } : undefined }
+    );
+    return response.data;
+  },
+  // The shop floor and every area and line below it
+  subtree: async (id: string): Promise<ShopfloorListResponse> => {
+    const response = await api.get<ShopfloorListResponse>(
+      `/api/shopfloors/${id}/subtree`
+    );
+    return response.data;
+  },
   get: async (id: string): Promise<ShopfloorResponse> => {
     const response = await api.get<ShopfloorResponse>(`/api/shopfloors/${id}`);
     return response.data;
@@ -61,7 +90,20 @@ export const shopfloorsApi = {
     );
     return response.data;
   },
-  delete: async (id: string) => {
-    await api.delete(`/api/shopfloors/${id}`);
+  // Records with dependents answer 409 with the preview unless confirmed
+  delete: async (id: string, confirm = false) => {
+    await api.delete(`/api/shopfloors/${id}`, {
+      params: confirm ? { confirm: true } : undefined,
+    });
+  },
+  trash: async (params?: { customer_id?: string }): Promise<ShopfloorListResponse> => {
+    const response = await api.get<ShopfloorListResponse>("/api/shopfloors/trash", {
+      params,
+    });
+    return response.data;
+  },
+  restore: async (id: string): Promise<ShopfloorResponse> => {
+    const response = await api.post<ShopfloorResponse>(`/api/shopfloors/${id}/restore`);
+    return response.data;
   },
 };
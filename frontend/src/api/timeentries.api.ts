@@ -1,4 +1,5 @@
 import api from "./http";
+import type { PageMeta } from "./pagination";
 
 export interface TimeEntry {
   id: string;
@@ -30,6 +31,7 @@ export interface TimeEntryResponse {
 export interface TimeEntryListResponse {
   data: TimeEntry[];
   message: string;
+  meta?: PageMeta;
 }
 
 export const timeentriesApi = {
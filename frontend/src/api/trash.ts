@@ -0,0 +1,59 @@
+// Counts of the records that depend on the one being deleted, by kind
+export type Dependents = Record<string, number>;
+
+// DeletePreview is what a delete does to the records that depend on the one
+// deleted: the ones moved to the trash along with it, and the history that
+// has no trash and is kept as it is
+export interface DeletePreview {
+  dependents: Dependents;
+  kept: Dependents;
+}
+
+type Translate = (key: string, values?: Record<string, unknown>) => string;
+
+// previewOf returns the preview of a delete that needs confirmation, or null
+// when the error is anything else
+export function previewOf(err: any): DeletePreview | null {
+  if (err?.response?.data?.code !== "unconfirmed_delete") return null;
+  return {
+    dependents: err.response.data.dependents ?? {},
+    kept: err.response.data.kept ?? {},
+  };
+}
+
+// listDependents lists the dependents as "3 jobs, 12 schedule entries"
+function listDependents(dependents: Dependents, t: Translate): string {
+  return Object.entries(dependents)
+    .filter(([, count]) => count > 0)
+    .map(([kind, count]) => `${count} ${t(`trash.dependents.${kind}`)}`)
+    .join(", ");
+}
+
+// describeDependents lists the preview as "3 jobs, 12 schedule entries
+// (40 production counts are kept)"
+export function describeDependents(preview: DeletePreview, t: Translate): string {
+  const deleted = listDependents(preview.dependents, t);
+  const kept = listDependents(preview.kept, t);
+  if (!kept) return deleted;
+  const note = t("trash.kept", { list: kept });
+  return deleted ? `${deleted} ${note}` : note;
+}
+
+// deleteConfirmed deletes without confirming first and, when the record has
+// dependents, deletes it again once ask accepts the preview. It resolves to
+// false when the deletion was cancelled.
+export async function deleteConfirmed(
+  remove: (confirm: boolean) => Promise<void>,
+  ask: (preview: DeletePreview) => boolean
+): Promise<boolean> {
+  try {
+    await remove(false);
+    return true;
+  } catch (err) {
+    const preview = previewOf(err);
+    if (!preview) throw err;
+    if (!ask(preview)) return false;
+  }
+  await remove(true);
+  return true;
+}
@@ -1,5 +1,6 @@
 import api from "./http";
-import type { User } from "./auth.api";
+import type { PageMeta } from "./pagination";
+import type { Impersonation, User } from "./auth.api";
 
 // Reusing User interface from auth.api to avoid duplication
 export type { User };
@@ -13,11 +14,21 @@ export interface UserRequest {
   password?: string; // Optional for updates
   is_active: boolean;
   is_admin: boolean;
+  role?: string; // defaults to read_only on create, kept on update
+}
+
+// Invited users stay inactive until they accept the emailed invitation
+export interface InviteRequest {
+  customer_id?: string;
+  username?: string;
+  email: string;
+  role?: string;
 }
 
 export interface UsersListResponse {
   data: User[];
   message: string;
+  meta?: PageMeta;
 }
 
 export interface UserResponse {
@@ -25,17 +36,58 @@ export interface UserResponse {
   message: string;
 }
 
+// Shop floors a user is restricted to; an empty list gives access to the
+// whole tenant. Areas and lines below an assigned shop floor are included.
+export interface UserShopfloorsResponse {
+  data: string[];
+  message: string;
+}
+
+// An entry of the login audit trail
+export interface LoginAttempt {
+  id: string;
+  user_id: string | null;
+  customer_id: string | null;
+  email: string;
+  ip: string;
+  user_agent: string;
+  success: boolean;
+  reason: string;
+  created_at: string;
+}
+
+export interface LoginListParams {
+  user_id?: string;
+  success?: boolean;
+  limit?: number;
+}
+
+export interface LoginListResponse {
+  data: LoginAttempt[];
+  message: string;
+}
+
+// The token can't be refreshed and expires with the impersonation
+export interface ImpersonationResponse {
+  token: string;
+  expire: string;
+  user: User;
+  impersonation: Impersonation;
+}
+
 export const usersApi = {
   list: async (): Promise<UsersListResponse> => {
     const response = await api.get<UsersListResponse>("/api/users");
     return response.data;
   },
 
-  listByCustomer: async (customerId: string): Promise<UsersListResponse> => {
-    // Assuming backend supports filtering by query param or specific endpoint
-    // Given typical pattern, likely /api/users?customer_id=...
+  listByCustomer: async (
+    customerId: string,
+    params?: any
+  ): Promise<UsersListResponse> => {
     const response = await api.get<UsersListResponse>(
-      `/api/users/customer/${customerId}`
+      `/api/users/customer/${customerId}`,
+      { params }
     );
     return response.data;
   },
@@ -56,4 +108,54 @@ export const usersApi = {
   delete: async (id: string): Promise<void> => {
     await api.delete(`/api/users/${id}`);
   },
+
+  invite: async (data: InviteRequest): Promise<UserResponse> => {
+    const response = await api.post<UserResponse>("/api/users/invite", data);
+    return response.data;
+  },
+
+  resendInvitation: async (id: string): Promise<void> => {
+    await api.post(`/api/users/${id}/invite`);
+  },
+
+  getShopfloors: async (id: string): Promise<UserShopfloorsResponse> => {
+    const response = await api.get<UserShopfloorsResponse>(
+      `/api/users/${id}/shopfloors`
+    );
+    return response.data;
+  },
+
+  setShopfloors: async (
+    id: string,
+    shopfloorIds: string[]
+  ): Promise<UserShopfloorsResponse> => {
+    const response = await api.put<UserShopfloorsResponse>(
+      `/api/users/${id}/shopfloors`,
+      { shopfloor_ids: shopfloorIds }
+    );
+    return response.data;
+  },
+
+  logins: async (params: LoginListParams): Promise<LoginListResponse> => {
+    const response = await api.get<LoginListResponse>("/api/users/logins", {
+      params,
+    });
+    return response.data;
+  },
+
+  unlock: async (id: string): Promise<void> => {
+    await api.post(`/api/users/${id}/unlock`);
+  },
+
+  // Only system admins can impersonate; the user sees the reason
+  impersonate: async (
+    id: string,
+    reason: string
+  ): Promise<ImpersonationResponse> => {
+    const response = await api.post<ImpersonationResponse>(
+      `/api/users/${id}/impersonate`,
+      { reason }
+    );
+    return response.data;
+  },
 };
@@ -0,0 +1,100 @@
+import api from "./http";
+
+export interface WebhookEndpoint {
+  id: string;
+  customer_id: string;
+  url: string;
+  secret?: string; // only returned on create
+  events: string[]; // empty = all events
+  is_active: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface WebhookEndpointRequest {
+  customer_id?: string;
+  url: string;
+  secret?: string;
+  events: string[];
+  is_active: boolean;
+}
+
+export interface WebhookDelivery {
+  id: string;
+  customer_id: string;
+  endpoint_id: string;
+  event_id: string;
+  event_type: string;
+  status: "pending" | "retrying" | "delivered" | "failed";
+  attempts: number;
+  last_status_code?: number | null;
+  last_error: string;
+  next_attempt_at?: string | null;
+  delivered_at?: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+interface ApiResponse<T> {
+  data: T;
+  message: string;
+}
+
+export const webhooksApi = {
+  events: async (): Promise<ApiResponse<string[]>> => {
+    const response = await api.get<ApiResponse<string[]>>(
+      "/api/webhooks/events"
+    );
+    return response.data;
+  },
+  list: async (params?: {
+    customer_id?: string;
+  }): Promise<ApiResponse<WebhookEndpoint[]>> => {
+    const response = await api.get<ApiResponse<WebhookEndpoint[]>>(
+      "/api/webhooks",
+      { params }
+    );
+    return response.data;
+  },
+  get: async (id: string): Promise<ApiResponse<WebhookEndpoint>> => {
+    const response = await api.get<ApiResponse<WebhookEndpoint>>(
+      `/api/webhooks/${id}`
+    );
+    return response.data;
+  },
+  create: async (
+    data: WebhookEndpointRequest
+  ): Promise<ApiResponse<WebhookEndpoint>> => {
+    const response = await api.post<ApiResponse<WebhookEndpoint>>(
+      "/api/webhooks",
+      data
+    );
+    return response.data;
+  },
+  update: async (
+    id: string,
+    data: WebhookEndpointRequest
+  ): Promise<ApiResponse<WebhookEndpoint>> => {
+    const response = await api.put<ApiResponse<WebhookEndpoint>>(
+      `/api/webhooks/${id}`,
+      data
+    );
+    return response.data;
+  },
+  delete: async (id: string): Promise<void> => {
+    await api.delete(`/api/webhooks/${id}`);
+  },
+  deliveries: async (
+    id: string,
+    limit?: number
+  ): Promise<ApiResponse<WebhookDelivery[]>> => {
+    const response = await api.get<ApiResponse<WebhookDelivery[]>>(
+      `/api/webhooks/${id}/deliveries`,
+      { params: { limit } }
+    );
+    return response.data;
+  },
+  retryDelivery: async (deliveryId: string): Promise<void> => {
+    await api.post(`/api/webhooks/deliveries/${deliveryId}/retry`);
+  },
+};
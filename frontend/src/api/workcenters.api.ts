@@ -1,4 +1,5 @@
 import api from "./http";
+import type { PageMeta } from "./pagination";
 
 export interface Workcenter {
   id: string;
@@ -6,8 +7,12 @@ export interface Workcenter {
   shop_floor_id: string | null;
   name: string;
   is_active: boolean;
+  hours_per_shift: number;
+  parallel_slots: number;
+  efficiency: number; // 1 = 100%
   created_at: string;
   updated_at: string;
+  deleted_at?: string;
 }
 
 export interface WorkcenterRequest {
@@ -15,6 +20,43 @@ export interface WorkcenterRequest {
   shop_floor_id?: string | null;
   name: string;
   is_active: boolean;
+  hours_per_shift?: number;
+  parallel_slots?: number;
+  efficiency?: number;
+}
+
+export interface CalendarDay {
+  workcenter_id: string;
+  date: string; // YYYY-MM-DD
+  hours_per_shift: number;
+  note: string;
+}
+
+export interface UtilizationParams {
+  from?: string; // YYYY-MM-DD
+  to?: string;
+  shop_floor_id?: string; // includes the areas and lines below it
+  customer_id?: string;
+}
+
+export interface UtilizationCell {
+  date: string;
+  shift_id: string;
+  shift_name: string;
+  workcenter_id: string;
+  workcenter_name: string;
+  scheduled_minutes: number;
+  downtime_minutes: number;
+  available_minutes: number; // after downtime
+  utilization: number;
+  overloaded: boolean;
+}
+
+export interface UtilizationReport {
+  from: string;
+  to: string;
+  cells: UtilizationCell[];
+  overloaded: number;
 }
 
 export interface WorkcenterListParams {
@@ -30,6 +72,7 @@ export interface WorkcenterListParams {
 export interface WorkcenterListResponse {
   data: Workcenter[];
   message: string;
+  meta?: PageMeta;
 }
 
 // Response wrapper for single workcenter
@@ -47,6 +90,15 @@ export const workcentersApi = {
     });
     return response.data;
   },
+  // Workcenters of the shop floor and of every area and line below it
+  listByShopFloor: async (
+    shopFloorId: string
+  ): Promise<WorkcenterListResponse> => {
+    const response = await api.get<WorkcenterListResponse>(
+      `/api/workcenters/shopfloor/${shopFloorId}`
+    );
+    return response.data;
+  },
   get: async (id: string): Promise<WorkcenterResponse> => {
     const response = await api.get<WorkcenterResponse>(
       `/api/workcenters/${id}`
@@ -70,7 +122,47 @@ export const workcentersApi = {
     );
     return response.data;
   },
-  delete: async (id: string) => {
-    await api.delete(`/api/workcenters/${id}`);
+  // Records with dependents answer 409 with the preview unless confirmed
+  delete: async (id: string, confirm = false) => {
+    await api.delete(`/api/workcenters/${id}`, {
+      params: confirm ? { confirm: true } : undefined,
+    });
+  },
+  trash: async (params?: { customer_id?: string }): Promise<WorkcenterListResponse> => {
+    const response = await api.get<WorkcenterListResponse>("/api/workcenters/trash", {
+      params,
+    });
+    return response.data;
+  },
+  restore: async (id: string): Promise<WorkcenterResponse> => {
+    const response = await api.post<WorkcenterResponse>(`/api/workcenters/${id}/restore`);
+    return response.data;
+  },
+  calendar: async (
+    id: string,
+    params?: { from?: string; to?: string }
+  ): Promise<{ data: CalendarDay[]; message: string }> => {
+    const response = await api.get(`/api/workcenters/${id}/calendar`, {
+      params,
+    });
+    return response.data;
+  },
+  setCalendarDay: async (
+    id: string,
+    data: { date: string; hours_per_shift: number; note?: string }
+  ): Promise<{ data: CalendarDay; message: string }> => {
+    const response = await api.put(`/api/workcenters/${id}/calendar`, data);
+    return response.data;
+  },
+  deleteCalendarDay: async (id: string, date: string) => {
+    await api.delete(`/api/workcenters/${id}/calendar/${date}`);
+  },
+  utilization: async (
+    params?: UtilizationParams
+  ): Promise<{ data: UtilizationReport; message: string }> => {
+    const response = await api.get("/api/workcenters/utilization", {
+      params,
+    });
+    return response.data;
   },
 };
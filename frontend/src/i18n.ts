@@ -46,6 +46,21 @@ const messages = {
       password_required: "La contrasenya és obligatòria",
       login_error_title: "Error",
       login_error_credentials: "Email o contrasenya incorrectes",
+      login_error_locked: "Massa intents fallits. Torna-ho a provar més tard",
+      sso_login: "Inicia sessió amb SSO",
+      sso_error_not_configured: "La teva empresa no té l'inici de sessió únic configurat",
+      sso_error_max_users: "La teva empresa ha arribat al màxim d'usuaris",
+      sso_error_failed: "No s'ha pogut iniciar sessió amb el proveïdor d'identitat",
+      two_factor_code: "Codi de verificació",
+      enter_two_factor_code: "Codi de l'aplicació o de recuperació",
+      two_factor_code_required: "El codi és obligatori",
+      two_factor_code_invalid: "Codi incorrecte",
+      two_factor_setup_hint:
+        "El teu compte requereix verificació en dos passos. Afegeix aquesta clau a la teva aplicació d'autenticació i introdueix el codi que genera.",
+      recovery_codes_hint:
+        "Desa aquests codis de recuperació en un lloc segur. Cada un es pot fer servir una vegada si perds el dispositiu.",
+      verify: "Verificar",
+      continue: "Continuar",
       login_error_server:
         "Error en el servei d’autenticació. Torna-ho a provar més tard",
       no_customer_info:
@@ -227,6 +242,20 @@ const messages = {
       no_payments: "No s'han trobat pagaments.",
       no_entries: "No s'han trobat entrades.",
     },
+    trash: {
+      confirm_dependents: "Aquest registre té dades relacionades que també es veuran afectades: {list}. Vols eliminar-lo igualment?",
+      kept: "({list} es conserven)",
+      dependents: {
+        jobs: "feines",
+        schedule_entries: "entrades de planificació",
+        time_entries: "registres horaris",
+        workcenters: "centres",
+        operators: "operaris",
+        shifts: "torns",
+        downtimes: "aturades",
+        production_counts: "registres de producció",
+      },
+    },
   },
   es: {
     menu: {
@@ -273,6 +302,21 @@ const messages = {
       password_required: "La contraseña es obligatoria",
       login_error_title: "Error",
       login_error_credentials: "Email o contraseña incorrectos",
+      login_error_locked: "Demasiados intentos fallidos. Vuelve a intentarlo más tarde",
+      sso_login: "Iniciar sesión con SSO",
+      sso_error_not_configured: "Tu empresa no tiene el inicio de sesión único configurado",
+      sso_error_max_users: "Tu empresa ha alcanzado el máximo de usuarios",
+      sso_error_failed: "No se ha podido iniciar sesión con el proveedor de identidad",
+      two_factor_code: "Código de verificación",
+      enter_two_factor_code: "Código de la aplicación o de recuperación",
+      two_factor_code_required: "El código es obligatorio",
+      two_factor_code_invalid: "Código incorrecto",
+      two_factor_setup_hint:
+        "Tu cuenta requiere verificación en dos pasos. Añade esta clave a tu aplicación de autenticación e introduce el código que genera.",
+      recovery_codes_hint:
+        "Guarda estos códigos de recuperación en un lugar seguro. Cada uno se puede usar una vez si pierdes el dispositivo.",
+      verify: "Verificar",
+      continue: "Continuar",
       login_error_server:
         "Error en el servicio de autenticación. Inténtalo más tarde",
       no_customer_info:
@@ -454,6 +498,20 @@ const messages = {
       no_payments: "No se han encontrado pagos.",
       no_entries: "No se han encontrado entradas.",
     },
+    trash: {
+      confirm_dependents: "Este registro tiene datos relacionados que también se verán afectados: {list}. ¿Quieres eliminarlo igualmente?",
+      kept: "({list} se conservan)",
+      dependents: {
+        jobs: "trabajos",
+        schedule_entries: "entradas de planificación",
+        time_entries: "registros horarios",
+        workcenters: "centros",
+        operators: "operarios",
+        shifts: "turnos",
+        downtimes: "paradas",
+        production_counts: "registros de producción",
+      },
+    },
   },
   en: {
     menu: {
@@ -500,6 +558,21 @@ const messages = {
       password_required: "Password is required",
       login_error_title: "Error",
       login_error_credentials: "Incorrect email or password",
+      login_error_locked: "Too many failed attempts. Try again later",
+      sso_login: "Log in with SSO",
+      sso_error_not_configured: "Your company hasn't set up single sign-on",
+      sso_error_max_users: "Your company has reached its maximum number of users",
+      sso_error_failed: "Unable to log in with the identity provider",
+      two_factor_code: "Verification code",
+      enter_two_factor_code: "Code from your app or a recovery code",
+      two_factor_code_required: "The code is required",
+      two_factor_code_invalid: "Incorrect code",
+      two_factor_setup_hint:
+        "Your account requires two-step verification. Add this key to your authenticator app and enter the code it shows.",
+      recovery_codes_hint:
+        "Keep these recovery codes somewhere safe. Each one can be used once if you lose your device.",
+      verify: "Verify",
+      continue: "Continue",
       login_error_server: "Authentication service error. Try again later",
       no_customer_info:
         "No customer information found associated with your user.",
@@ -680,6 +753,20 @@ const messages = {
       no_payments: "No payments found.",
       no_entries: "No entries found.",
     },
+    trash: {
+      confirm_dependents: "This record has related data that will be affected too: {list}. Delete it anyway?",
+      kept: "({list} are kept)",
+      dependents: {
+        jobs: "jobs",
+        schedule_entries: "schedule entries",
+        time_entries: "time entries",
+        workcenters: "workcenters",
+        operators: "operators",
+        shifts: "shifts",
+        downtimes: "downtimes",
+        production_counts: "production counts",
+      },
+    },
   },
 };
 
@@ -12,6 +12,13 @@ const router = createRouter({
       component: LoginView,
       meta: { public: true },
     },
+    {
+      // The identity provider sends single sign-on logins back here
+      path: "/sso/callback",
+      name: "sso-callback",
+      component: LoginView,
+      meta: { public: true },
+    },
     {
       path: "/",
       component: () => import("../layouts/MainLayout.vue"),
@@ -1,6 +1,13 @@
 import { defineStore } from "pinia";
 import { ref, computed } from "vue";
-import { authApi, type User } from "../api/auth.api";
+import {
+  authApi,
+  isChallenge,
+  type ChallengeResponse,
+  type LoginResponse,
+  type User,
+} from "../api/auth.api";
+import { usersApi } from "../api/users.api";
 
 export const useAuthStore = defineStore("auth", () => {
   const user = ref<User | null>(null);
@@ -8,21 +15,98 @@ export const useAuthStore = defineStore("auth", () => {
 
   const isAuthenticated = computed(() => !!token.value);
   const isAdmin = computed(() => !!user.value?.is_admin);
+  // While an admin impersonates a user their own session is kept aside
+  const isImpersonating = ref(!!localStorage.getItem("admin_session"));
 
-  async function login(email: string, password: string) {
+  // Returns the challenge when the login still needs the second factor
+  async function login(
+    email: string,
+    password: string
+  ): Promise<ChallengeResponse | null> {
     const response = await authApi.login(email, password);
+    if (isChallenge(response)) {
+      return response;
+    }
+    setSession(response);
+    return null;
+  }
+
+  async function verifyTwoFactor(challengeToken: string, code: string) {
+    setSession(await authApi.verifyTwoFactor(challengeToken, code));
+  }
+
+  // Returns the recovery codes to show to the user
+  async function finishEnrollment(
+    challengeToken: string,
+    code: string
+  ): Promise<string[]> {
+    const response = await authApi.finishEnrollment(challengeToken, code);
+    setSession(response);
+    return response.recovery_codes ?? [];
+  }
 
+  async function exchangeLoginCode(code: string) {
+    setSession(await authApi.exchangeLoginCode(code));
+  }
+
+  function setSession(response: LoginResponse) {
     token.value = response.token;
     user.value = response.user;
 
     localStorage.setItem("token", response.token);
+    localStorage.setItem("refresh_token", response.refresh_token);
     localStorage.setItem("user", JSON.stringify(response.user));
   }
 
+  async function impersonate(userId: string, reason: string) {
+    const response = await usersApi.impersonate(userId, reason);
+    localStorage.setItem(
+      "admin_session",
+      JSON.stringify({
+        token: localStorage.getItem("token"),
+        refresh_token: localStorage.getItem("refresh_token"),
+        user: localStorage.getItem("user"),
+      })
+    );
+    token.value = response.token;
+    user.value = response.user;
+    localStorage.setItem("token", response.token);
+    localStorage.removeItem("refresh_token");
+    localStorage.setItem("user", JSON.stringify(response.user));
+    isImpersonating.value = true;
+  }
+
+  // Ends the impersonation and returns to the admin's session
+  function stopImpersonation() {
+    if (token.value) {
+      authApi.logout(token.value).catch(() => {});
+    }
+    const adminSession = JSON.parse(localStorage.getItem("admin_session") ?? "{}");
+    localStorage.removeItem("admin_session");
+    isImpersonating.value = false;
+    for (const key of ["token", "refresh_token", "user"]) {
+      if (adminSession[key]) {
+        localStorage.setItem(key, adminSession[key]);
+      } else {
+        localStorage.removeItem(key);
+      }
+    }
+    loadFromStorage();
+  }
+
   function logout() {
+    if (isImpersonating.value) {
+      stopImpersonation();
+      return;
+    }
+    // End the session on the server too; the local logout happens regardless
+    if (token.value) {
+      authApi.logout(token.value).catch(() => {});
+    }
     token.value = null;
     user.value = null;
     localStorage.removeItem("token");
+    localStorage.removeItem("refresh_token");
     localStorage.removeItem("user");
   }
 
@@ -48,7 +132,13 @@ export const useAuthStore = defineStore("auth", () => {
     token,
     isAuthenticated,
     isAdmin,
+    isImpersonating,
     login,
+    verifyTwoFactor,
+    finishEnrollment,
+    exchangeLoginCode,
+    impersonate,
+    stopImpersonation,
     logout,
     loadFromStorage,
   };
@@ -6,6 +6,7 @@ import {
   type CustomerRequest,
   type CustomerListParams,
 } from "../api/customers.api";
+import { fetchAll } from "../api/pagination";
 
 export const useCustomersStore = defineStore("customers", () => {
   const customers = ref<Customer[]>([]);
@@ -22,9 +23,26 @@ export const useCustomersStore = defineStore("customers", () => {
     try {
       const response = await customersApi.list(params);
       customers.value = response.data;
-      // Backend does not return total count yet, so we use the length of the data array or 0 if empty
-      // Ideally backend should return total count for server-side pagination
-      total.value = response.data ? response.data.length : 0;
+      total.value = response.meta?.total ?? response.data?.length ?? 0;
+    } catch (err: any) {
+      console.error("Error fetching customers", err);
+      if (err.response && err.response.status === 403) {
+        isForbidden.value = true;
+      }
+      error.value = err.message || "Error carregant clients";
+    } finally {
+      loading.value = false;
+    }
+  }
+
+  // Every customer, for the selectors that can't work on a page
+  async function fetchAllCustomers(params: CustomerListParams = {}) {
+    loading.value = true;
+    error.value = null;
+    isForbidden.value = false;
+    try {
+      customers.value = await fetchAll(customersApi.list, params);
+      total.value = customers.value.length;
     } catch (err: any) {
       console.error("Error fetching customers", err);
       if (err.response && err.response.status === 403) {
@@ -90,6 +108,7 @@ export const useCustomersStore = defineStore("customers", () => {
     error,
     isForbidden,
     fetchCustomers,
+    fetchAllCustomers,
     fetchCustomer,
     createCustomer,
     updateCustomer,
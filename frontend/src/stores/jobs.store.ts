@@ -6,6 +6,7 @@ import {
   type JobRequest,
   type JobListParams,
 } from "../api/jobs.api";
+import { fetchAll } from "../api/pagination";
 
 export const useJobsStore = defineStore("jobs", () => {
   const jobs = ref<Job[]>([]);
@@ -20,7 +21,22 @@ export const useJobsStore = defineStore("jobs", () => {
     try {
       const response = await jobsApi.list(params);
       jobs.value = response.data;
-      total.value = response.data ? response.data.length : 0;
+      total.value = response.meta?.total ?? response.data?.length ?? 0;
+    } catch (err: any) {
+      console.error("Error fetching jobs", err);
+      error.value = err.message || "Error carregant feines";
+    } finally {
+      loading.value = false;
+    }
+  }
+
+  // Every jobs, for the lookups that can't work on a page
+  async function fetchAllJobs(params: JobListParams = {}) {
+    loading.value = true;
+    error.value = null;
+    try {
+      jobs.value = await fetchAll(jobsApi.list, params);
+      total.value = jobs.value.length;
     } catch (err: any) {
       console.error("Error fetching jobs", err);
       error.value = err.message || "Error carregant feines";
@@ -74,11 +90,11 @@ export const useJobsStore = defineStore("jobs", () => {
     }
   }
 
-  async function deleteJob(id: string) {
+  async function deleteJob(id: string, confirm = false) {
     loading.value = true;
     error.value = null;
     try {
-      await jobsApi.delete(id);
+      await jobsApi.delete(id, confirm);
     } catch (err: any) {
       console.error("Error deleting job", err);
       error.value = err.message || "Error eliminant feina";
@@ -95,6 +111,7 @@ export const useJobsStore = defineStore("jobs", () => {
     loading,
     error,
     fetchJobs,
+    fetchAllJobs,
     fetchJob,
     createJob,
     updateJob,
@@ -20,7 +20,7 @@ export const useOperatorsStore = defineStore("operators", () => {
     try {
       const response = await operatorsApi.list(params);
       operators.value = response.data;
-      total.value = response.data ? response.data.length : 0;
+      total.value = response.meta?.total ?? response.data?.length ?? 0;
     } catch (err: any) {
       console.error("Error fetching operators", err);
       error.value = err.message || "Error carregant operaris";
@@ -74,11 +74,11 @@ export const useOperatorsStore = defineStore("operators", () => {
     }
   }
 
-  async function deleteOperator(id: string) {
+  async function deleteOperator(id: string, confirm = false) {
     loading.value = true;
     error.value = null;
     try {
-      await operatorsApi.delete(id);
+      await operatorsApi.delete(id, confirm);
     } catch (err: any) {
       console.error("Error deleting operator", err);
       error.value = err.message || "Error eliminant operari";
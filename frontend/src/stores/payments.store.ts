@@ -5,6 +5,7 @@ import {
   type Payment,
   type PaymentRequest,
 } from "../api/payments.api";
+import { fetchAll } from "../api/pagination";
 
 export const usePaymentsStore = defineStore("payments", () => {
   const payments = ref<Payment[]>([]);
@@ -15,8 +16,9 @@ export const usePaymentsStore = defineStore("payments", () => {
     loading.value = true;
     error.value = null;
     try {
-      const response = await paymentsApi.findByCustomer(customerId);
-      payments.value = response.data || [];
+      payments.value = await fetchAll((params) =>
+        paymentsApi.findByCustomer(customerId, params)
+      );
     } catch (err: any) {
       console.error("Error fetching payments", err);
       error.value = err.message || "Error carregant pagaments";
@@ -9,6 +9,7 @@ import { operatorsApi } from "../api/operators.api";
 import { workcentersApi } from "../api/workcenters.api";
 import { jobsApi } from "../api/jobs.api";
 import { shiftsApi } from "../api/shifts.api";
+import { fetchAll } from "../api/pagination";
 
 export const usePlanningStore = defineStore("planning", () => {
   // State
@@ -29,25 +30,17 @@ export const usePlanningStore = defineStore("planning", () => {
     loading.value = true;
     try {
       // Load all necessary info for the Shopfloor
-      const [opsRes, wcsRes, jobsRes, shiftsRes] = await Promise.all([
-        operatorsApi.list({
-          page: 1,
-          page_size: 1000,
-          // shop_floor_id was error, checking Api definition would be best but let's assume valid param or fix later
-          // Removing for now to stop red noise if unsure, or check API file.
-        } as any),
-        workcentersApi.list({
-          page: 1,
-          page_size: 1000,
-        } as any),
-        jobsApi.list({ page: 1, page_size: 1000 } as any),
-        shiftsApi.listByShopfloor(shopfloorId),
+      const [operators, workcenters, jobs, shifts] = await Promise.all([
+        fetchAll(operatorsApi.list),
+        fetchAll(workcentersApi.list),
+        fetchAll(jobsApi.list),
+        fetchAll((params) => shiftsApi.listByShopfloor(shopfloorId, params)),
       ]);
 
-      resources.value.operators = opsRes.data || [];
-      resources.value.workcenters = wcsRes.data || [];
-      resources.value.jobs = jobsRes.data || [];
-      resources.value.shifts = shiftsRes.data || [];
+      resources.value.operators = operators;
+      resources.value.workcenters = workcenters;
+      resources.value.jobs = jobs;
+      resources.value.shifts = shifts;
     } catch (e: any) {
       console.error("Error loading resources", e);
       error.value = "Failed to load resources";
@@ -20,7 +20,7 @@ export const useShiftsStore = defineStore("shifts", () => {
     try {
       const response = await shiftsApi.list(params);
       shifts.value = response.data;
-      total.value = response.data ? response.data.length : 0;
+      total.value = response.meta?.total ?? response.data?.length ?? 0;
     } catch (err: any) {
       console.error("Error fetching shifts", err);
       const msg =
@@ -41,7 +41,7 @@ export const useShiftsStore = defineStore("shifts", () => {
     try {
       const response = await shiftsApi.listByShopfloor(shopfloorId, params);
       shifts.value = response.data;
-      total.value = response.data ? response.data.length : 0;
+      total.value = response.meta?.total ?? response.data?.length ?? 0;
     } catch (err: any) {
       console.error("Error fetching shifts", err);
       const msg =
@@ -101,11 +101,11 @@ export const useShiftsStore = defineStore("shifts", () => {
     }
   }
 
-  async function deleteShift(id: string) {
+  async function deleteShift(id: string, confirm = false) {
     loading.value = true;
     error.value = null;
     try {
-      await shiftsApi.delete(id);
+      await shiftsApi.delete(id, confirm);
     } catch (err: any) {
       console.error("Error deleting shift", err);
       const msg =
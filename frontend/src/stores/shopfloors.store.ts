@@ -20,7 +20,7 @@ export const useShopfloorsStore = defineStore("shopfloors", () => {
     try {
       const response = await shopfloorsApi.list(params);
       shopfloors.value = response.data;
-      total.value = response.data ? response.data.length : 0;
+      total.value = response.meta?.total ?? response.data?.length ?? 0;
     } catch (err: any) {
       console.error("Error fetching shopfloors", err);
       error.value = err.message || "Error carregant plantes";
@@ -74,11 +74,11 @@ export const useShopfloorsStore = defineStore("shopfloors", () => {
     }
   }
 
-  async function deleteShopfloor(id: string) {
+  async function deleteShopfloor(id: string, confirm = false) {
     loading.value = true;
     error.value = null;
     try {
-      await shopfloorsApi.delete(id);
+      await shopfloorsApi.delete(id, confirm);
     } catch (err: any) {
       console.error("Error deleting shopfloor", err);
       error.value = err.message || "Error eliminant planta";
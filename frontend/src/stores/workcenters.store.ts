@@ -6,6 +6,7 @@ import {
   type WorkcenterRequest,
   type WorkcenterListParams,
 } from "../api/workcenters.api";
+import { fetchAll } from "../api/pagination";
 
 export const useWorkcentersStore = defineStore("workcenters", () => {
   const workcenters = ref<Workcenter[]>([]);
@@ -20,7 +21,22 @@ export const useWorkcentersStore = defineStore("workcenters", () => {
     try {
       const response = await workcentersApi.list(params);
       workcenters.value = response.data;
-      total.value = response.data ? response.data.length : 0;
+      total.value = response.meta?.total ?? response.data?.length ?? 0;
+    } catch (err: any) {
+      console.error("Error fetching workcenters", err);
+      error.value = err.message || "Error carregant centres de treball";
+    } finally {
+      loading.value = false;
+    }
+  }
+
+  // Every workcenters, for the lookups that can't work on a page
+  async function fetchAllWorkcenters(params: WorkcenterListParams = {}) {
+    loading.value = true;
+    error.value = null;
+    try {
+      workcenters.value = await fetchAll(workcentersApi.list, params);
+      total.value = workcenters.value.length;
     } catch (err: any) {
       console.error("Error fetching workcenters", err);
       error.value = err.message || "Error carregant centres de treball";
@@ -76,11 +92,11 @@ export const useWorkcentersStore = defineStore("workcenters", () => {
     }
   }
 
-  async function deleteWorkcenter(id: string) {
+  async function deleteWorkcenter(id: string, confirm = false) {
     loading.value = true;
     error.value = null;
     try {
-      await workcentersApi.delete(id);
+      await workcentersApi.delete(id, confirm);
     } catch (err: any) {
       console.error("Error deleting workcenter", err);
       error.value = err.message || "Error eliminant centre de treball";
@@ -97,6 +113,7 @@ export const useWorkcentersStore = defineStore("workcenters", () => {
     loading,
     error,
     fetchWorkcenters,
+    fetchAllWorkcenters,
     fetchWorkcenter,
     createWorkcenter,
     updateWorkcenter,